@@ -11,15 +11,80 @@ import (
 type AWS struct {
 	BaseScraper         `json:",inline"`
 	*AWSConnection      `json:",inline"`
-	PatchStates         bool          `json:"patch_states,omitempty"`
-	PatchDetails        bool          `json:"patch_details,omitempty"`
-	Inventory           bool          `json:"inventory,omitempty"`
-	Compliance          bool          `json:"compliance,omitempty"`
-	CloudTrail          CloudTrail    `json:"cloudtrail,omitempty"`
-	TrustedAdvisorCheck bool          `json:"trusted_advisor_check,omitempty"`
-	Include             []string      `json:"include,omitempty"`
-	Exclude             []string      `json:"exclude,omitempty"`
-	CostReporting       CostReporting `json:"cost_reporting,omitempty"`
+	PatchStates         bool              `json:"patch_states,omitempty"`
+	PatchDetails        bool              `json:"patch_details,omitempty"`
+	Inventory           bool              `json:"inventory,omitempty"`
+	Compliance          bool              `json:"compliance,omitempty"`
+	CloudTrail          CloudTrail        `json:"cloudtrail,omitempty"`
+	CertificateExpiry   CertificateExpiry `json:"certificate_expiry,omitempty"`
+	SecretRotation      SecretRotation    `json:"secret_rotation,omitempty"`
+	TrustedAdvisorCheck bool              `json:"trusted_advisor_check,omitempty"`
+	ComputeOptimizer    bool              `json:"compute_optimizer,omitempty"`
+	SecurityFindings    bool              `json:"security_findings,omitempty"`
+	Include             []string          `json:"include,omitempty"`
+	Exclude             []string          `json:"exclude,omitempty"`
+	CostReporting       CostReporting     `json:"cost_reporting,omitempty"`
+	Organization        *AWSOrganization  `json:"organization,omitempty"`
+	// MaxConcurrency is the number of services scraped in parallel across all regions, defaults to 10
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Incremental scrapes only the services with changes between full scrapes
+	Incremental *AWSIncremental `json:"incremental,omitempty"`
+}
+
+// AWSIncremental consumes change events from an SQS queue, the queue can receive CloudTrail
+// events from an EventBridge rule or AWS Config notifications from EventBridge or SNS
+type AWSIncremental struct {
+	QueueURL string `json:"queue_url"`
+	// FullScrapeInterval between full scrapes, defaults to 24h
+	FullScrapeInterval string `json:"full_scrape_interval,omitempty"`
+}
+
+func (i AWSIncremental) GetFullScrapeInterval() time.Duration {
+	if i.FullScrapeInterval == "" {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(i.FullScrapeInterval)
+	if err != nil {
+		logger.Warnf("Invalid full scrape interval %s: %v", i.FullScrapeInterval, err)
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// AWSOrganization scrapes every account in the organization by assuming a role in each of them,
+// the connection must have access to the organization management (or delegated administrator) account
+type AWSOrganization struct {
+	// RoleName is assumed in each member account, defaults to OrganizationAccountAccessRole
+	RoleName string `json:"roleName,omitempty"`
+	// Accounts limits scraping to the given account ids
+	Accounts []string `json:"accounts,omitempty"`
+	// ExcludeAccounts skips the given account ids
+	ExcludeAccounts []string `json:"excludeAccounts,omitempty"`
+}
+
+func (org AWSOrganization) GetRoleName() string {
+	if org.RoleName == "" {
+		return "OrganizationAccountAccessRole"
+	}
+	return org.RoleName
+}
+
+// Includes returns true if the account should be scraped
+func (org AWSOrganization) Includes(account string) bool {
+	for _, exclude := range org.ExcludeAccounts {
+		if exclude == account {
+			return false
+		}
+	}
+	if len(org.Accounts) == 0 {
+		return true
+	}
+	for _, include := range org.Accounts {
+		if include == account {
+			return true
+		}
+	}
+	return false
 }
 
 type CloudTrail struct {
@@ -39,30 +104,148 @@ func (c CloudTrail) GetMaxAge() time.Duration {
 	return d
 }
 
+// CertificateExpiry is the window before the expiry of ACM and IAM server certificates in which they are reported,
+// as warnings and then as criticals
+type CertificateExpiry struct {
+	// Warning defaults to 720h (30 days)
+	Warning string `json:"warning,omitempty"`
+	// Critical defaults to 168h (7 days)
+	Critical string `json:"critical,omitempty"`
+}
+
+func (c CertificateExpiry) GetWarning() time.Duration {
+	return parseWindow(c.Warning, 30*24*time.Hour)
+}
+
+func (c CertificateExpiry) GetCritical() time.Duration {
+	return parseWindow(c.Critical, 7*24*time.Hour)
+}
+
+// SecretRotation is the longest time Secrets Manager secrets and SSM SecureString parameters can go without being
+// rotated or changed before they are reported as stale
+type SecretRotation struct {
+	// MaxAge defaults to 2160h (90 days)
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+func (s SecretRotation) GetMaxAge() time.Duration {
+	return parseWindow(s.MaxAge, 90*24*time.Hour)
+}
+
+func parseWindow(window string, def time.Duration) time.Duration {
+	if window == "" {
+		return def
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		logger.Warnf("Invalid window %s: %v", window, err)
+		return def
+	}
+	return d
+}
+
 type CostReporting struct {
 	S3BucketPath string `json:"s3_bucket_path,omitempty"`
 	Table        string `json:"table,omitempty"`
 	Database     string `json:"database,omitempty"`
 	Region       string `json:"region,omitempty"`
+	// Workgroup the queries run in, it must exist as it is not created
+	Workgroup string `json:"workgroup,omitempty"`
+	// CacheTTL is how long the costs of a day are reused before the report is queried again, defaults to 24h and 0
+	// disables the cache
+	CacheTTL string `json:"cache_ttl,omitempty"`
+}
+
+func (c CostReporting) GetCacheTTL() time.Duration {
+	return parseWindow(c.CacheTTL, 24*time.Hour)
 }
 
 const (
-	AWSEC2Instance        = "AWS::EC2::Instance"
-	AWSEKSCluster         = "AWS::EKS::Cluster"
-	AWSS3Bucket           = "AWS::S3::Bucket"
-	AWSLoadBalancer       = "AWS::ElasticLoadBalancing::LoadBalancer"
-	AWSLoadBalancerV2     = "AWS::ElasticLoadBalancingV2::LoadBalancer"
-	AWSEBSVolume          = "AWS::EBS::Volume"
-	AWSRDSInstance        = "AWS::RDS::DBInstance"
-	AWSEC2VPC             = "AWS::EC2::VPC"
-	AWSEC2Subnet          = "AWS::EC2::Subnet"
-	AWSAccount            = "AWS::::Account"
-	AWSEC2SecurityGroup   = "AWS::EC2::SecurityGroup"
-	AWSIAMUser            = "AWS::IAM::User"
-	AWSIAMRole            = "AWS::IAM::Role"
-	AWSIAMInstanceProfile = "AWS::IAM::InstanceProfile"
-	AWSEC2AMI             = "AWS::EC2::AMI"
-	AWSEC2DHCPOptions     = "AWS::EC2::DHCPOptions"
+	AWSEC2Instance                    = "AWS::EC2::Instance"
+	AWSEKSCluster                     = "AWS::EKS::Cluster"
+	AWSS3Bucket                       = "AWS::S3::Bucket"
+	AWSLoadBalancer                   = "AWS::ElasticLoadBalancing::LoadBalancer"
+	AWSLoadBalancerV2                 = "AWS::ElasticLoadBalancingV2::LoadBalancer"
+	AWSEBSVolume                      = "AWS::EBS::Volume"
+	AWSRDSInstance                    = "AWS::RDS::DBInstance"
+	AWSRDSCluster                     = "AWS::RDS::DBCluster"
+	AWSRDSSnapshot                    = "AWS::RDS::DBSnapshot"
+	AWSRDSClusterSnapshot             = "AWS::RDS::DBClusterSnapshot"
+	AWSRDSParameterGroup              = "AWS::RDS::DBParameterGroup"
+	AWSRDSClusterParameterGroup       = "AWS::RDS::DBClusterParameterGroup"
+	AWSRDSOptionGroup                 = "AWS::RDS::OptionGroup"
+	AWSRDSSubnetGroup                 = "AWS::RDS::DBSubnetGroup"
+	AWSEC2VPC                         = "AWS::EC2::VPC"
+	AWSEC2Subnet                      = "AWS::EC2::Subnet"
+	AWSEC2RouteTable                  = "AWS::EC2::RouteTable"
+	AWSEC2InternetGateway             = "AWS::EC2::InternetGateway"
+	AWSEC2NATGateway                  = "AWS::EC2::NatGateway"
+	AWSEC2VPCPeeringConnection        = "AWS::EC2::VPCPeeringConnection"
+	AWSEC2TransitGateway              = "AWS::EC2::TransitGateway"
+	AWSEC2TransitGatewayAttachment    = "AWS::EC2::TransitGatewayAttachment"
+	AWSAccount                        = "AWS::::Account"
+	AWSOrganizationsOrganization      = "AWS::Organizations::Organization"
+	AWSOrganizationalUnit             = "AWS::Organizations::OrganizationalUnit"
+	AWSServiceControlPolicy           = "AWS::Organizations::Policy"
+	AWSEC2SecurityGroup               = "AWS::EC2::SecurityGroup"
+	AWSIAMUser                        = "AWS::IAM::User"
+	AWSIAMRole                        = "AWS::IAM::Role"
+	AWSIAMGroup                       = "AWS::IAM::Group"
+	AWSIAMPolicy                      = "AWS::IAM::ManagedPolicy"
+	AWSEKSNodeGroup                   = "AWS::EKS::Nodegroup"
+	AWSEKSAddon                       = "AWS::EKS::Addon"
+	AWSEKSFargateProfile              = "AWS::EKS::FargateProfile"
+	AWSAutoScalingGroup               = "AWS::AutoScaling::AutoScalingGroup"
+	AWSAutoScalingLaunchConfiguration = "AWS::AutoScaling::LaunchConfiguration"
+	AWSEC2LaunchTemplate              = "AWS::EC2::LaunchTemplate"
+	AWSIAMInstanceProfile             = "AWS::IAM::InstanceProfile"
+	AWSEC2AMI                         = "AWS::EC2::AMI"
+	AWSEBSSnapshot                    = "AWS::EC2::Snapshot"
+	AWSEFSFileSystem                  = "AWS::EFS::FileSystem"
+	AWSEFSMountTarget                 = "AWS::EFS::MountTarget"
+	AWSFSxFileSystem                  = "AWS::FSx::FileSystem"
+	AWSBackupPlan                     = "AWS::Backup::BackupPlan"
+	AWSBackupVault                    = "AWS::Backup::BackupVault"
+	AWSBackupRecoveryPoint            = "AWS::Backup::RecoveryPoint"
+	AWSECRRepository                  = "AWS::ECR::Repository"
+	AWSEC2DHCPOptions                 = "AWS::EC2::DHCPOptions"
+	AWSACMCertificate                 = "AWS::CertificateManager::Certificate"
+	AWSIAMServerCertificate           = "AWS::IAM::ServerCertificate"
+	AWSKMSKey                         = "AWS::KMS::Key"
+	AWSSecretsManagerSecret           = "AWS::SecretsManager::Secret"
+	AWSSSMParameter                   = "AWS::SSM::Parameter"
+	AWSRoute53HostedZone              = "AWS::Route53::HostedZone"
+	AWSRoute53RecordSet               = "AWS::Route53::RecordSet"
+	AWSCloudFrontDistribution         = "AWS::CloudFront::Distribution"
+	AWSCloudWatchAlarm                = "AWS::CloudWatch::Alarm"
+	AWSElastiCacheCluster             = "AWS::ElastiCache::CacheCluster"
+	AWSElastiCacheReplicationGroup    = "AWS::ElastiCache::ReplicationGroup"
+	AWSElastiCacheNode                = "AWS::ElastiCache::CacheNode"
+	AWSMemoryDBCluster                = "AWS::MemoryDB::Cluster"
+	AWSMemoryDBNode                   = "AWS::MemoryDB::Node"
+	AWSOpenSearchDomain               = "AWS::OpenSearchService::Domain"
+	AWSRedshiftCluster                = "AWS::Redshift::Cluster"
+	AWSEMRCluster                     = "AWS::EMR::Cluster"
+	AWSGlueJob                        = "AWS::Glue::Job"
+	AWSGlueCrawler                    = "AWS::Glue::Crawler"
+	AWSGlueDatabase                   = "AWS::Glue::Database"
+	AWSKinesisStream                  = "AWS::Kinesis::Stream"
+	AWSKinesisFirehose                = "AWS::KinesisFirehose::DeliveryStream"
+	AWSMSKCluster                     = "AWS::MSK::Cluster"
+	AWSStepFunctionsStateMachine      = "AWS::StepFunctions::StateMachine"
+	AWSEventBridgeEventBus            = "AWS::Events::EventBus"
+	AWSEventBridgeRule                = "AWS::Events::Rule"
+	AWSSQSQueue                       = "AWS::SQS::Queue"
+	AWSSNSTopic                       = "AWS::SNS::Topic"
+	AWSSNSSubscription                = "AWS::SNS::Subscription"
+	AWSLambdaFunction                 = "AWS::Lambda::Function"
+	AWSAPIGatewayRestAPI              = "AWS::ApiGateway::RestApi"
+	AWSAPIGatewayStage                = "AWS::ApiGateway::Stage"
+	AWSAPIGatewayRoute                = "AWS::ApiGateway::Method"
+	AWSAPIGatewayV2API                = "AWS::ApiGatewayV2::Api"
+	AWSAPIGatewayV2Stage              = "AWS::ApiGatewayV2::Stage"
+	AWSAPIGatewayV2Route              = "AWS::ApiGatewayV2::Route"
+	AWSAPIGatewayV2Integration        = "AWS::ApiGatewayV2::Integration"
 )
 
 func (aws AWS) Includes(resource string) bool {
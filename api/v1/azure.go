@@ -1,6 +1,10 @@
 package v1
 
-import "github.com/flanksource/kommons"
+import (
+	"strings"
+
+	"github.com/flanksource/kommons"
+)
 
 type AzureDevops struct {
 	BaseScraper         `json:",inline"`
@@ -9,3 +13,49 @@ type AzureDevops struct {
 	Projects            []string       `yaml:"projects" json:"projects"`
 	Pipelines           []string       `yaml:"pipelines" json:"pipelines"`
 }
+
+// Azure scrapes the directory of a tenant and the resources of a subscription using the credentials of a
+// service principal
+type Azure struct {
+	BaseScraper `json:",inline"`
+	TenantID    string `json:"tenantID"`
+	// SubscriptionID of the resources to scrape, only the directory is scraped when empty
+	SubscriptionID string         `json:"subscriptionID,omitempty"`
+	ClientID       kommons.EnvVar `json:"clientID"`
+	ClientSecret   kommons.EnvVar `json:"clientSecret"`
+	// Include are the services to scrape e.g. ActiveDirectory, RoleAssignments, AKS, Costs, every service is scraped
+	// when empty
+	Include []string `json:"include,omitempty"`
+	// Exclude are the services not to scrape
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Includes returns true if the service should be scraped
+func (azure Azure) Includes(service string) bool {
+	for _, exclude := range azure.Exclude {
+		if strings.EqualFold(exclude, service) {
+			return false
+		}
+	}
+	if len(azure.Include) == 0 {
+		return true
+	}
+	for _, include := range azure.Include {
+		if strings.EqualFold(include, service) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	AzureSubscription       = "Azure::Subscription"
+	AzureResourceGroup      = "Azure::ResourceGroup"
+	AzureADApplication      = "Azure::AD::Application"
+	AzureADServicePrincipal = "Azure::AD::ServicePrincipal"
+	AzureADGroup            = "Azure::AD::Group"
+	AzureRoleAssignment     = "Azure::Authorization::RoleAssignment"
+	AzureAKSCluster         = "Azure::ContainerService::ManagedCluster"
+	AzureAKSNodePool        = "Azure::ContainerService::AgentPool"
+	AzureVMScaleSet         = "Azure::Compute::VirtualMachineScaleSet"
+)
@@ -84,8 +84,21 @@ func (s MaskList) String() string {
 	return fmt.Sprintf("total_masks=%d", len(s))
 }
 
+// Redact replaces secrets and personal data found anywhere in the config
+type Redact struct {
+	// Detectors are the built-in detectors to use: aws_access_key, aws_secret_key, private_key, jwt,
+	// password, kubernetes_secret and email, defaults to all of them
+	Detectors []string `json:"detectors,omitempty"`
+	// Patterns are regular expressions of values to redact
+	Patterns []string `json:"patterns,omitempty"`
+	// JSONPaths of fields to redact
+	JSONPaths []string `json:"jsonpaths,omitempty"`
+	// Replacement of redacted values, md5sum replaces them with their hash, defaults to [REDACTED]
+	Replacement string `json:"replacement,omitempty"`
+}
+
 type Transform struct {
-	Script  Script   `yaml:",inline" json:",inline"`
+	Script  Script   `yaml:"script,omitempty" json:"script,omitempty"`
 	Include []Filter `json:"include,omitempty"`
 	// Fields to remove from the config, useful for removing sensitive data and fields
 	// that change often without a material impact i.e. Last Scraped Time
@@ -93,10 +106,15 @@ type Transform struct {
 	// Masks consist of configurations to replace sensitive fields
 	// with hash functions or static string.
 	Masks MaskList `json:"mask,omitempty"`
+	// ChangeExclusions are JSONPath expressions of fields that are kept in the config but ignored
+	// when detecting changes, e.g. timestamps or observedGeneration that change on every scrape
+	ChangeExclusions []string `json:"changeExclusions,omitempty"`
+	// Redact secrets and personal data before the config is saved
+	Redact *Redact `json:"redact,omitempty"`
 }
 
 func (t Transform) IsEmpty() bool {
-	return t.Script.IsEmpty() && len(t.Include) == 0 && len(t.Exclude) == 0 && t.Masks.IsEmpty()
+	return t.Script.IsEmpty() && len(t.Include) == 0 && len(t.Exclude) == 0 && t.Masks.IsEmpty() && len(t.ChangeExclusions) == 0 && t.Redact == nil
 }
 
 func (t Transform) String() string {
@@ -119,6 +137,65 @@ func (t Transform) String() string {
 	return s
 }
 
+// TransformStep is one step of a transform pipeline, exactly one of the steps must be set.
+// Templates are go templates rendered with the .config and the .result
+type TransformStep struct {
+	// Name of the step in errors, defaults to the index and kind of the step
+	Name string `json:"name,omitempty"`
+	// Filter is a template that must render true for the item to be kept
+	Filter string `json:"filter,omitempty"`
+	// Mask replaces the value of a JSONPath with a hash function or a static string
+	Mask *Mask `json:"mask,omitempty"`
+	// Rename changes the name, type or namespace of the item to the rendered templates
+	Rename *Rename `json:"rename,omitempty"`
+	// Relate creates a relationship from the item to another config item
+	Relate *Relate `json:"relate,omitempty"`
+	// Script replaces the item with the items returned by the script
+	Script *Script `json:"script,omitempty"`
+}
+
+// Kind returns the kind of step that is set
+func (t TransformStep) Kind() string {
+	switch {
+	case t.Filter != "":
+		return "filter"
+	case t.Mask != nil:
+		return "mask"
+	case t.Rename != nil:
+		return "rename"
+	case t.Relate != nil:
+		return "relate"
+	case t.Script != nil:
+		return "script"
+	}
+	return ""
+}
+
+type Rename struct {
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Relate struct {
+	// ID is a template of the external id of the related item
+	ID string `json:"id"`
+	// ExternalType of the related item
+	ExternalType string `json:"externalType"`
+	Relationship string `json:"relationship,omitempty"`
+}
+
+// Mapping re-identifies scraped items, templates that render empty keep the scraped value
+type Mapping struct {
+	ID   Template `json:"id,omitempty"`
+	Name Template `json:"name,omitempty"`
+	Type Template `json:"type,omitempty"`
+	// Parent is the external id of the parent item
+	Parent Template `json:"parent,omitempty"`
+	// ParentType is the external type of the parent item, defaults to the external type of the item
+	ParentType Template `json:"parentType,omitempty"`
+}
+
 type BaseScraper struct {
 	// A static value or JSONPath expression to use as the ID for the resource.
 	ID string `json:"id,omitempty"`
@@ -130,6 +207,10 @@ type BaseScraper struct {
 	// A static value or JSONPath expression to use as the type for the resource.
 	Type      string    `json:"type,omitempty"`
 	Transform Transform `json:"transform,omitempty"`
+	// Transforms are applied in order to every item after the transform
+	Transforms []TransformStep `json:"transforms,omitempty"`
+	// Mapping overrides the id, name, type or parent of every item with templates of the .config and .result
+	Mapping *Mapping `json:"mapping,omitempty"`
 	// Format of config item, defaults to JSON, available options are JSON, properties
 	Format string `json:"format,omitempty"`
 }
@@ -149,6 +230,10 @@ func (base BaseScraper) String() string {
 		s += fmt.Sprintf(" transform=%s", base.Transform)
 	}
 
+	if len(base.Transforms) > 0 {
+		s += fmt.Sprintf(" transforms=%d", len(base.Transforms))
+	}
+
 	return s
 }
 
@@ -182,14 +267,25 @@ func (auth Authentication) GetDomain() string {
 	return ""
 }
 
-// AWSConnection ...
+// AWSConnection uses the access and secret keys when they are set, otherwise the default credential chain:
+// environment variables, shared config, IRSA web identity tokens, and ECS or EC2 instance roles
 type AWSConnection struct {
-	AccessKey     kommons.EnvVar `yaml:"accessKey,omitempty" json:"accessKey,omitempty"`
-	SecretKey     kommons.EnvVar `yaml:"secretKey,omitempty" json:"secretKey,omitempty"`
-	Region        []string       `yaml:"region,omitempty" json:"region"`
-	Endpoint      string         `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
-	SkipTLSVerify bool           `yaml:"skipTLSVerify,omitempty" json:"skipTLSVerify,omitempty"`
-	AssumeRole    string         `yaml:"assumeRole,omitempty" json:"assumeRole,omitempty"`
+	AccessKey kommons.EnvVar `yaml:"accessKey,omitempty" json:"accessKey,omitempty"`
+	SecretKey kommons.EnvVar `yaml:"secretKey,omitempty" json:"secretKey,omitempty"`
+	// Region to scrape, use "all" to scrape every region enabled in the account
+	Region        []string `yaml:"region,omitempty" json:"region"`
+	Endpoint      string   `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	SkipTLSVerify bool     `yaml:"skipTLSVerify,omitempty" json:"skipTLSVerify,omitempty"`
+	// AssumeRole is the ARN of a role to assume with the credentials above
+	AssumeRole string `yaml:"assumeRole,omitempty" json:"assumeRole,omitempty"`
+	// ExternalID is passed when assuming the role and the roles of the organization member accounts,
+	// required by roles that trust a third party
+	ExternalID string `yaml:"externalID,omitempty" json:"externalID,omitempty"`
+	// SessionName of the assumed role session, shows up in CloudTrail
+	SessionName string `yaml:"sessionName,omitempty" json:"sessionName,omitempty"`
+	// MaxRetries of a throttled or failed API call, calls are retried with an adaptive backoff
+	// that rate limits requests to an API once it starts throttling, defaults to 10
+	MaxRetries int `yaml:"maxRetries,omitempty" json:"maxRetries,omitempty"`
 }
 
 // GCPConnection ...
@@ -240,3 +336,7 @@ type Template struct {
 	Expression string `yaml:"expr,omitempty" json:"expr,omitempty"`
 	Javascript string `yaml:"javascript,omitempty" json:"javascript,omitempty"`
 }
+
+func (t Template) IsEmpty() bool {
+	return t.Template == "" && t.JSONPath == "" && t.GSONPath == "" && t.Expression == "" && t.Javascript == ""
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"regexp"
@@ -60,6 +61,10 @@ func parseConfig(configfile string) ([]ConfigScraper, error) {
 			return nil, err
 		}
 
+		config.Name = configfile
+		if len(scrapers) > 0 {
+			config.Name = fmt.Sprintf("%s#%d", configfile, len(scrapers))
+		}
 		scrapers = append(scrapers, config)
 	}
 
@@ -0,0 +1,32 @@
+package v1
+
+import "github.com/flanksource/kommons"
+
+// GitHub scrapes the repositories of an organization with the protection rules of their branches, its teams and
+// the webhooks of the organization and its repositories
+type GitHub struct {
+	BaseScraper         `json:",inline"`
+	Organization        string         `yaml:"organization" json:"organization"`
+	PersonalAccessToken kommons.EnvVar `yaml:"personalAccessToken" json:"personalAccessToken"`
+	// Repositories to scrape, every repository of the organization is scraped when empty
+	Repositories []string `yaml:"repositories,omitempty" json:"repositories,omitempty"`
+}
+
+// GitHubActions scrapes the workflows of the repositories of an owner, the recent runs of a workflow are recorded
+// as its changes
+type GitHubActions struct {
+	BaseScraper         `json:",inline"`
+	Owner               string         `yaml:"owner" json:"owner"`
+	PersonalAccessToken kommons.EnvVar `yaml:"personalAccessToken" json:"personalAccessToken"`
+	Repositories        []string       `yaml:"repositories" json:"repositories"`
+	Workflows           []string       `yaml:"workflows" json:"workflows"`
+}
+
+const (
+	GitHubOrganization     = "GitHub::Organization"
+	GitHubRepository       = "GitHub::Repository"
+	GitHubBranchProtection = "GitHub::BranchProtection"
+	GitHubTeam             = "GitHub::Team"
+	GitHubWebhook          = "GitHub::Webhook"
+	GitHubActionsWorkflow  = "GitHubActions::Workflow"
+)
@@ -2,13 +2,17 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/kommons"
+	"github.com/patrickmn/go-cache"
 )
 
 // Scraper ...
@@ -108,6 +112,26 @@ func (s *ScrapeResults) Errorf(e error, msg string, args ...interface{}) ScrapeR
 	return *s
 }
 
+// ItemErrorf records an error of a single item, e.g. a config item whose cost could not be updated, the item is
+// recorded with the error in the scrape run so that missing data can be traced back to it
+func (s *ScrapeResults) ItemErrorf(externalType, externalID string, e error, msg string, args ...interface{}) ScrapeResults {
+	msg = fmt.Sprintf(msg, args...)
+	logger.Errorf("[%s/%s] %s: %v", externalType, externalID, msg, e)
+	*s = append(*s, ScrapeResult{
+		ExternalType: externalType,
+		ID:           externalID,
+		Error:        fmt.Errorf("%s: %w", msg, e),
+	})
+	return *s
+}
+
+// ScrapeError is an error of a single item of a scrape
+type ScrapeError struct {
+	ExternalType string `json:"external_type,omitempty"`
+	ExternalID   string `json:"external_id"`
+	Message      string `json:"message"`
+}
+
 // ScrapeResult ...
 // +kubebuilder:object:generate=false
 type ScrapeResult struct {
@@ -122,6 +146,7 @@ type ScrapeResult struct {
 	Zone                string              `json:"zone,omitempty"`
 	Name                string              `json:"name,omitempty"`
 	Namespace           string              `json:"namespace,omitempty"`
+	Description         string              `json:"description,omitempty"`
 	ID                  string              `json:"id,omitempty"`
 	Aliases             []string            `json:"aliases,omitempty"`
 	Source              string              `json:"source,omitempty"`
@@ -200,11 +225,290 @@ type QueryResult struct {
 	Results []map[string]interface{} `json:"results"`
 }
 
+// IngestResponse is the number of results saved by an ingestion request
+type IngestResponse struct {
+	Items int `json:"items"`
+}
+
+// IngestRequest is a config item pushed to POST /config, unlike the json of a scrape result it includes the parent,
+// the changes and the relationships of the item
+// +kubebuilder:object:generate=false
+type IngestRequest struct {
+	ID                 string               `json:"id"`
+	Aliases            []string             `json:"aliases,omitempty"`
+	Type               string               `json:"type"`
+	ExternalType       string               `json:"external_type,omitempty"`
+	Name               string               `json:"name,omitempty"`
+	Namespace          string               `json:"namespace,omitempty"`
+	Description        string               `json:"description,omitempty"`
+	Account            string               `json:"account,omitempty"`
+	Region             string               `json:"region,omitempty"`
+	Zone               string               `json:"zone,omitempty"`
+	Network            string               `json:"network,omitempty"`
+	Subnet             string               `json:"subnet,omitempty"`
+	Source             string               `json:"source,omitempty"`
+	Config             interface{}          `json:"config,omitempty"`
+	Format             string               `json:"format,omitempty"`
+	Tags               JSONStringMap        `json:"tags,omitempty"`
+	CreatedAt          *time.Time           `json:"created_at,omitempty"`
+	ParentExternalID   string               `json:"parent_external_id,omitempty"`
+	ParentExternalType string               `json:"parent_external_type,omitempty"`
+	Analysis           *AnalysisResult      `json:"analysis,omitempty"`
+	Changes            []IngestChange       `json:"changes,omitempty"`
+	Relationships      []IngestRelationship `json:"relationships,omitempty"`
+}
+
+// IngestChange is a change of a pushed config item
+// +kubebuilder:object:generate=false
+type IngestChange struct {
+	ExternalChangeID string                 `json:"external_change_id,omitempty"`
+	Action           ChangeAction           `json:"action,omitempty"`
+	ChangeType       string                 `json:"change_type"`
+	Severity         string                 `json:"severity,omitempty"`
+	Source           string                 `json:"source,omitempty"`
+	Summary          string                 `json:"summary,omitempty"`
+	Patches          string                 `json:"patches,omitempty"`
+	Details          map[string]interface{} `json:"details,omitempty"`
+	CreatedAt        *time.Time             `json:"created_at,omitempty"`
+}
+
+// IngestRelationship relates a pushed config item to another config item
+// +kubebuilder:object:generate=false
+type IngestRelationship struct {
+	RelatedExternalID   string `json:"related_external_id"`
+	RelatedExternalType string `json:"related_external_type"`
+	Relationship        string `json:"relationship"`
+}
+
+// NewIngestRequest returns the request that pushes the result
+func NewIngestRequest(result ScrapeResult) IngestRequest {
+	req := IngestRequest{
+		ID:                 result.ID,
+		Aliases:            result.Aliases,
+		Type:               result.Type,
+		ExternalType:       result.ExternalType,
+		Name:               result.Name,
+		Namespace:          result.Namespace,
+		Description:        result.Description,
+		Account:            result.Account,
+		Region:             result.Region,
+		Zone:               result.Zone,
+		Network:            result.Network,
+		Subnet:             result.Subnet,
+		Source:             result.Source,
+		Config:             result.Config,
+		Format:             result.Format,
+		Tags:               result.Tags,
+		CreatedAt:          result.CreatedAt,
+		ParentExternalID:   result.ParentExternalID,
+		ParentExternalType: result.ParentExternalType,
+		Analysis:           result.AnalysisResult,
+	}
+	for _, change := range result.Changes {
+		req.Changes = append(req.Changes, IngestChange{
+			ExternalChangeID: change.ExternalChangeID,
+			Action:           change.Action,
+			ChangeType:       change.ChangeType,
+			Severity:         change.Severity,
+			Source:           change.Source,
+			Summary:          change.Summary,
+			Patches:          change.Patches,
+			Details:          change.Details,
+			CreatedAt:        change.CreatedAt,
+		})
+	}
+	for _, relationship := range result.RelationshipResults {
+		if len(relationship.RelatedExternalID.ExternalID) == 0 {
+			continue
+		}
+		req.Relationships = append(req.Relationships, IngestRelationship{
+			RelatedExternalID:   relationship.RelatedExternalID.ExternalID[0],
+			RelatedExternalType: relationship.RelatedExternalID.ExternalType,
+			Relationship:        relationship.Relationship,
+		})
+	}
+	return req
+}
+
+// ScrapeResult returns the result that saves the pushed config item
+func (req IngestRequest) ScrapeResult() ScrapeResult {
+	result := ScrapeResult{
+		ID:                 req.ID,
+		Aliases:            req.Aliases,
+		Type:               req.Type,
+		ExternalType:       req.ExternalType,
+		Name:               req.Name,
+		Namespace:          req.Namespace,
+		Description:        req.Description,
+		Account:            req.Account,
+		Region:             req.Region,
+		Zone:               req.Zone,
+		Network:            req.Network,
+		Subnet:             req.Subnet,
+		Source:             req.Source,
+		Config:             req.Config,
+		Format:             req.Format,
+		Tags:               req.Tags,
+		CreatedAt:          req.CreatedAt,
+		ParentExternalID:   req.ParentExternalID,
+		ParentExternalType: req.ParentExternalType,
+		AnalysisResult:     req.Analysis,
+	}
+	if result.ExternalType == "" {
+		result.ExternalType = result.Type
+	}
+	for _, change := range req.Changes {
+		result.Changes = append(result.Changes, ChangeResult{
+			ExternalID:       req.ID,
+			ExternalType:     result.ExternalType,
+			ExternalChangeID: change.ExternalChangeID,
+			Action:           change.Action,
+			ChangeType:       change.ChangeType,
+			Severity:         change.Severity,
+			Source:           change.Source,
+			Summary:          change.Summary,
+			Patches:          change.Patches,
+			Details:          change.Details,
+			CreatedAt:        change.CreatedAt,
+		})
+	}
+	self := ExternalID{ExternalID: []string{req.ID}, ExternalType: result.ExternalType}
+	for _, relationship := range req.Relationships {
+		result.RelationshipResults = append(result.RelationshipResults, RelationshipResult{
+			ConfigExternalID:  self,
+			RelatedExternalID: ExternalID{ExternalID: []string{relationship.RelatedExternalID}, ExternalType: relationship.RelatedExternalType},
+			Relationship:      relationship.Relationship,
+		})
+	}
+	return result
+}
+
 // QueryRequest ...
 type QueryRequest struct {
 	Query string `json:"query"`
 }
 
+// SearchRequest filters config items, all the filters are combined
+type SearchRequest struct {
+	// Text is searched in the config of the items
+	Text string `json:"text,omitempty"`
+	// JSONPath that must match the config, e.g. $.State ? (@.Name == "running")
+	JSONPath     string            `json:"jsonpath,omitempty"`
+	Type         string            `json:"type,omitempty"`
+	ExternalType string            `json:"external_type,omitempty"`
+	Account      string            `json:"account,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Limit        int               `json:"limit,omitempty"`
+	Offset       int               `json:"offset,omitempty"`
+	// MinCost30d is the minimum cost over the last 30 days
+	MinCost30d float64 `json:"min_cost_30d,omitempty"`
+	SortBy     string  `json:"sort_by,omitempty"`
+	// Order is either asc or desc
+	Order string `json:"order,omitempty"`
+	// Cursor of the page to return, from the previous page, instead of the offset
+	Cursor string `json:"-"`
+	// Fields of the items to return, all when it is empty
+	Fields []string `json:"-"`
+	// Tenant restricts the search to the items of the tenant, it is set from the caller
+	Tenant string `json:"-"`
+}
+
+// View is a named search that is materialized on demand at /view/{name}
+type View struct {
+	Name          string `json:"name"`
+	SearchRequest `json:",inline"`
+	// Fields are jsonpath expressions selecting values from the config of every item, keyed by column
+	Fields map[string]string `json:"fields,omitempty"`
+	// Template is a go template rendered with the .rows of the view instead of returning them as JSON
+	Template string `json:"template,omitempty"`
+}
+
+// DriftRequest compares the config items selected by Left with the ones selected by Right, e.g. two accounts
+type DriftRequest struct {
+	Left  SearchRequest `json:"left"`
+	Right SearchRequest `json:"right"`
+	// MatchTags are the tags that must be equal for two items without a common external id to match, in addition
+	// to the type and name
+	MatchTags []string `json:"match_tags,omitempty"`
+	// Exclude are JSONPath expressions of fields that are expected to differ, e.g. $.Tags or $.metadata.uid
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// DriftItem is a pair of matching config items whose configs differ
+// +kubebuilder:object:generate=false
+type DriftItem struct {
+	ConfigType string `json:"config_type"`
+	Name       string `json:"name"`
+	LeftID     string `json:"left_id"`
+	RightID    string `json:"right_id"`
+	// Patches is the RFC 6902 JSON patch from the left config to the right config
+	Patches json.RawMessage `json:"patches"`
+	Summary string          `json:"summary"`
+}
+
+// DriftMissing is a config item that has no match on the other side
+// +kubebuilder:object:generate=false
+type DriftMissing struct {
+	ID         string `json:"id"`
+	ConfigType string `json:"config_type"`
+	Name       string `json:"name"`
+}
+
+// DriftReport lists the differences between two sets of config items
+// +kubebuilder:object:generate=false
+type DriftReport struct {
+	// Matched is the number of items found on both sides, including the drifted ones
+	Matched   int            `json:"matched"`
+	Drifted   []DriftItem    `json:"drifted"`
+	OnlyLeft  []DriftMissing `json:"only_left"`
+	OnlyRight []DriftMissing `json:"only_right"`
+}
+
+// GraphNode is a config item in a relationship graph
+// +kubebuilder:object:generate=false
+type GraphNode struct {
+	ID           string  `json:"id"`
+	Name         *string `json:"name,omitempty"`
+	ConfigType   string  `json:"config_type"`
+	ExternalType *string `json:"external_type,omitempty"`
+	// Depth is the number of edges from the root of the graph
+	Depth int `json:"depth"`
+}
+
+// GraphEdge is a directed relationship between two config items, parent
+// relationships have the "parent" relation from the child to the parent
+// +kubebuilder:object:generate=false
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// ConfigGraph is the neighborhood of a config item
+// +kubebuilder:object:generate=false
+type ConfigGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Diagnostic is the outcome of checking one connection of a scraper without scraping it
+// +kubebuilder:object:generate=false
+type Diagnostic struct {
+	Scraper string `json:"scraper,omitempty"`
+	// Check describes what was checked, e.g. "aws sts us-east-1"
+	Check string `json:"check"`
+	// Error is empty when the check passed
+	Error string `json:"error,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Error != "" {
+		return fmt.Sprintf("FAIL %s %s: %s", d.Scraper, d.Check, d.Error)
+	}
+	return fmt.Sprintf("OK   %s %s", d.Scraper, d.Check)
+}
+
 // ScrapeContext ...
 // +kubebuilder:object:generate=false
 type ScrapeContext struct {
@@ -212,6 +516,149 @@ type ScrapeContext struct {
 	Namespace string
 	Kommons   *kommons.Client
 	Scraper   *ConfigScraper
+	// Targets are the ids of resources that triggered the scrape, scrapers that support it only scrape those
+	Targets []string
+	// Connections caches sessions and clients of connections, nothing is cached when it is nil
+	Connections *ConnectionCache
+	// DryRun scrapes without recording anything in the database
+	DryRun bool
+	// Checkpoint records the tasks that completed, scrapers that support it skip the tasks completed
+	// by an interrupted scrape, nothing is recorded when it is nil
+	Checkpoint *Checkpoint
+	// RunID of the scrape run, recorded in the audit log of the items the run changes
+	RunID string
+	// Actor is the identity of the API caller that pushed the results, scrapers are the actor when it is empty
+	Actor string
+	// Tenant the saved items belong to
+	Tenant string
+	// AfterSave are run once the results of the scrape are saved, e.g. to delete the events they were scraped from,
+	// nothing is run when it is nil
+	AfterSave *Hooks
+}
+
+// Hooks are functions run once an operation completes
+// +kubebuilder:object:generate=false
+type Hooks struct {
+	lock  sync.Mutex
+	hooks []func()
+}
+
+// Add registers a function to run
+func (h *Hooks) Add(fn func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// Run runs and removes the registered functions
+func (h *Hooks) Run() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.lock.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Checkpoint is the set of tasks of a scrape that completed, e.g. the services of an AWS region
+// +kubebuilder:object:generate=false
+type Checkpoint struct {
+	lock      sync.Mutex
+	completed map[string]bool
+}
+
+func NewCheckpoint(completed []string) *Checkpoint {
+	c := &Checkpoint{completed: make(map[string]bool)}
+	for _, task := range completed {
+		c.completed[task] = true
+	}
+	return c
+}
+
+// Done is true when the task completed
+func (c *Checkpoint) Done(task string) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.completed[task]
+}
+
+// Complete records that the task completed
+func (c *Checkpoint) Complete(task string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.completed[task] = true
+}
+
+// Completed returns the tasks that completed
+func (c *Checkpoint) Completed() []string {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var tasks []string
+	for task := range c.completed {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	return tasks
+}
+
+// ConnectionCache reuses the sessions and clients created for a connection until the ttl expires,
+// so that rotated credentials are eventually picked up, expired entries are evicted in the background
+// +kubebuilder:object:generate=false
+type ConnectionCache struct {
+	lock    sync.Mutex
+	entries *cache.Cache
+}
+
+type connectionEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func NewConnectionCache(ttl time.Duration) *ConnectionCache {
+	return &ConnectionCache{entries: cache.New(ttl, ttl)}
+}
+
+// Get returns the cached value of the key or creates it, concurrent calls for a key create it once
+// and errors are not cached
+func (c *ConnectionCache) Get(key string, create func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return create()
+	}
+	c.lock.Lock()
+	var entry *connectionEntry
+	if cached, ok := c.entries.Get(key); ok {
+		entry = cached.(*connectionEntry)
+	} else {
+		entry = &connectionEntry{}
+		c.entries.SetDefault(key, entry)
+	}
+	c.lock.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = create()
+	})
+	if entry.err != nil {
+		c.lock.Lock()
+		if cached, ok := c.entries.Get(key); ok && cached == entry {
+			c.entries.Delete(key)
+		}
+		c.lock.Unlock()
+	}
+	return entry.value, entry.err
 }
 
 func (ctx ScrapeContext) Find(path string) ([]string, error) {
@@ -237,6 +684,11 @@ func (ctx ScrapeContext) GetNamespace() string {
 	return ctx.Namespace
 }
 
+// Cancelled is true when the scrape was cancelled, e.g. on shutdown
+func (ctx ScrapeContext) Cancelled() bool {
+	return ctx.Context != nil && ctx.Err() != nil
+}
+
 // IsTrace ...
 func (ctx ScrapeContext) IsTrace() bool {
 	return ctx.Scraper != nil && ctx.Scraper.IsTrace()
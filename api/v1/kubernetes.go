@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"path"
 	"strings"
 
 	"github.com/flanksource/kommons"
@@ -19,11 +20,72 @@ type Kubernetes struct {
 	MaxInflight     int64           `json:"maxInflight,omitempty"`
 	Exclusions      []string        `json:"exclusions,omitempty"`
 	Kubeconfig      *kommons.EnvVar `json:"kubeconfig,omitempty"`
+	// LabelSelector of the resources to scrape e.g. app.kubernetes.io/managed-by=Helm, same as selector
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Contexts of the kubeconfig to scrape concurrently, each as a cluster named after its context instead of the
+	// cluster name, * scrapes every context of the kubeconfig
+	Contexts []string `json:"contexts,omitempty"`
+	// Include are the group/kinds of the resources to scrape e.g. cert-manager.io/Certificate, every discovered
+	// resource including custom resources is scraped when empty
+	Include []string `json:"include,omitempty"`
+	// Exclude are the group/kinds of the resources not to scrape e.g. networking.istio.io/*, and the analyzers
+	// not to run e.g. kubernetes-rbac-wildcard-verbs
+	Exclude []string `json:"exclude,omitempty"`
+	// NamespaceSelector limits the namespaced resources scraped to the namespaces matching it, cluster scoped
+	// resources are still scraped
+	NamespaceSelector *NamespaceSelector `json:"namespaceSelector,omitempty"`
+	// KeepVolatileFields keeps the managed fields and resource version of objects and records the changes of their
+	// status, by default they are removed and the status is ignored when detecting changes
+	KeepVolatileFields bool `json:"keepVolatileFields,omitempty"`
+	// VulnerabilityReports attaches the vulnerabilities found by the Trivy operator as analyses of the scanned
+	// container images
+	VulnerabilityReports bool `json:"vulnerabilityReports,omitempty"`
+}
+
+// NamespaceSelector matches namespaces by name and labels, all the fields that are set must match
+type NamespaceSelector struct {
+	// Names are globs of the namespaces to scrape e.g. team-*
+	Names []string `json:"names,omitempty"`
+	// Exclude are globs of the namespaces not to scrape e.g. kube-system, ci-*
+	Exclude []string `json:"exclude,omitempty"`
+	// LabelSelector of the namespaces to scrape e.g. environment in (staging, production)
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// Includes returns true if resources of the group and kind should be scraped, patterns are either a kind matching
+// all groups or a group/kind where both support wildcards e.g. Pod, apps/*, *.istio.io/VirtualService
+func (k Kubernetes) Includes(group, kind string) bool {
+	for _, exclude := range k.Exclude {
+		if matchGroupKind(exclude, group, kind) {
+			return false
+		}
+	}
+	if len(k.Include) == 0 {
+		return true
+	}
+	for _, include := range k.Include {
+		if matchGroupKind(include, group, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGroupKind(pattern, group, kind string) bool {
+	patternGroup, patternKind := "*", pattern
+	if i := strings.LastIndex(pattern, "/"); i >= 0 {
+		patternGroup, patternKind = pattern[:i], pattern[i+1:]
+	}
+	if ok, _ := path.Match(patternGroup, group); !ok {
+		return false
+	}
+	ok, _ := path.Match(strings.ToLower(patternKind), strings.ToLower(kind))
+	return ok
 }
 
 type KubernetesFile struct {
 	BaseScraper `json:",inline"`
-	Selector    ResourceSelector `json:"selector,inline"`
+	Selector    ResourceSelector `json:"selector"`
 	Container   string           `json:"container,omitempty"`
 	Files       []PodFile        `json:"files,omitempty"`
 }
@@ -0,0 +1,29 @@
+package v1
+
+// Ownership normalizes the tags and labels identifying the owner of config items, so that items from
+// AWS, Azure and Kubernetes can be grouped by the same tags, e.g.
+//
+//	ownership:
+//	  tags:
+//	    team: [team, Team, owner, app.kubernetes.io/team]
+//	    app: [app, Application, app.kubernetes.io/name]
+//	  owners:
+//	    - tag: team
+//	      type: Team
+type Ownership struct {
+	// Tags maps a normalized tag to the tags or labels it is read from, in order of precedence,
+	// tags are matched case insensitively
+	Tags map[string][]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Owners create a config item for every value of a normalized tag, related to the items with that value
+	Owners []OwnerTag `json:"owners,omitempty" yaml:"owners,omitempty"`
+}
+
+// OwnerTag creates config items of Type from the values of Tag
+type OwnerTag struct {
+	// Tag is the normalized tag
+	Tag string `json:"tag" yaml:"tag"`
+	// Type of the config items created for the values of the tag, e.g. Team or Component
+	Type string `json:"type" yaml:"type"`
+	// Relation of the items to their owner, defaults to owner
+	Relation string `json:"relation,omitempty" yaml:"relation,omitempty"`
+}
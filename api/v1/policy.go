@@ -0,0 +1,23 @@
+package v1
+
+// Policy is a Rego policy evaluated against every config item returned by the scraper.
+// The policy is evaluated with the config as the input and must define a deny rule,
+// every element of deny is a violation: either a message or an object with msg, severity and remediation fields, e.g.
+//
+//	package s3
+//	deny[{"msg": msg, "remediation": "Enable versioning"}] {
+//	  input.Versioning.Status != "Enabled"
+//	  msg := "versioning is disabled"
+//	}
+type Policy struct {
+	// Name of the policy, used as the analyzer of the analysis results
+	Name string `json:"name" yaml:"name"`
+	// Rego source of the policy
+	Rego string `json:"rego" yaml:"rego"`
+	// Types of config items the policy applies to, defaults to all of them
+	Types []string `json:"types,omitempty" yaml:"types,omitempty"`
+	// Severity of violations that do not specify one, defaults to warning
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	// Category is the analysis type of the violations, defaults to compliance
+	Category string `json:"category,omitempty" yaml:"category,omitempty"`
+}
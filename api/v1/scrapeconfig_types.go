@@ -28,10 +28,22 @@ type ScrapeConfigSpec struct {
 // ScrapeConfigStatus defines the observed state of ScrapeConfig
 type ScrapeConfigStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,3,opt,name=observedGeneration"`
+	// LastScrapeTime is when the last scrape finished
+	LastScrapeTime *metav1.Time `json:"lastScrapeTime,omitempty"`
+	// ItemsScraped by the last scrape
+	ItemsScraped int `json:"itemsScraped,omitempty"`
+	// Errors returned by the last scrape
+	Errors int `json:"errors,omitempty"`
+	// Conditions has a Scraped condition with the outcome of the last scrape
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Last Scrape",type=date,JSONPath=`.status.lastScrapeTime`
+//+kubebuilder:printcolumn:name="Items",type=integer,JSONPath=`.status.itemsScraped`
+//+kubebuilder:printcolumn:name="Errors",type=integer,JSONPath=`.status.errors`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Scraped")].reason`
 
 // ScrapeConfig is the Schema for the scrapeconfigs API
 type ScrapeConfig struct {
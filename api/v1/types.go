@@ -3,7 +3,9 @@ package v1
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/flanksource/commons/logger"
 	"github.com/lib/pq"
 
 	"gorm.io/gorm"
@@ -11,14 +13,91 @@ import (
 
 // ConfigScraper ...
 type ConfigScraper struct {
-	LogLevel       string           `json:"logLevel,omitempty"`
-	Schedule       string           `json:"schedule,omitempty"`
+	// Name identifies the scraper in scrape runs, it is set from the file or resource the scraper is loaded from.
+	// ID is the uid of the resource, the scraped items are saved with it as their scraper id
+	Name     string `json:"-" yaml:"-"`
+	ID       string `json:"-" yaml:"-"`
+	LogLevel string `json:"logLevel,omitempty"`
+	// Tenant the scraped items belong to, callers scoped to another tenant cannot see them.
+	// Items without a tenant are only visible to callers that are not scoped to a tenant
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// Schedule is a cron expression or @every <duration>, defaults to --default-schedule
+	Schedule string `json:"schedule,omitempty"`
+	// Jitter delays every scheduled run by a random duration up to it, e.g. 5m, so that scrapers
+	// with the same schedule do not all call the same APIs at once
+	Jitter         string           `json:"jitter,omitempty" yaml:"jitter,omitempty"`
 	AWS            []AWS            `json:"aws,omitempty" yaml:"aws,omitempty"`
 	File           []File           `json:"file,omitempty" yaml:"file,omitempty"`
 	Kubernetes     []Kubernetes     `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
 	KubernetesFile []KubernetesFile `json:"kubernetesFile,omitempty" yaml:"kubernetesFile,omitempty"`
+	Azure          []Azure          `json:"azure,omitempty" yaml:"azure,omitempty"`
 	AzureDevops    []AzureDevops    `json:"azureDevops,omitempty" yaml:"azureDevops,omitempty"`
+	GitHub         []GitHub         `json:"github,omitempty" yaml:"github,omitempty"`
+	GitHubActions  []GitHubActions  `json:"githubActions,omitempty" yaml:"githubActions,omitempty"`
 	SQL            []SQL            `json:"sql,omitempty" yaml:"sql,omitempty"`
+	Trigger        *Trigger         `json:"trigger,omitempty" yaml:"trigger,omitempty"`
+	Retention      *Retention       `json:"retention,omitempty" yaml:"retention,omitempty"`
+	Views          []View           `json:"views,omitempty" yaml:"views,omitempty"`
+	Policies       []Policy         `json:"policies,omitempty" yaml:"policies,omitempty"`
+	Ownership      *Ownership       `json:"ownership,omitempty" yaml:"ownership,omitempty"`
+	// Includes keep only the items matching one of the selectors, defaults to all the items
+	Includes []ConfigSelector `json:"includes,omitempty" yaml:"includes,omitempty"`
+	// Excludes drop the items matching one of the selectors
+	Excludes []ConfigSelector `json:"excludes,omitempty" yaml:"excludes,omitempty"`
+	// Libraries are javascript modules that transform scripts can load with require("name")
+	Libraries []Library `json:"libraries,omitempty" yaml:"libraries,omitempty"`
+	// Secrets are the secrets that transform scripts and filters can read with getSecret, as <secret>/<key> of a
+	// kubernetes secret in the namespace of the scraper or secret store references
+	Secrets []string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+}
+
+// Library is a javascript module loaded from a file, a config map or a URL, the module assigns
+// what it exports to module.exports or exports, e.g. exports.tag = function(config, key) {...}
+type Library struct {
+	Name      string        `json:"name" yaml:"name"`
+	File      string        `json:"file,omitempty" yaml:"file,omitempty"`
+	ConfigMap *ConfigMapKey `json:"configMap,omitempty" yaml:"configMap,omitempty"`
+	URL       string        `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
+// ConfigMapKey selects a key of a config map, the namespace defaults to the namespace of the scraper
+type ConfigMapKey struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Key       string `json:"key" yaml:"key"`
+}
+
+// ConfigSelector matches scraped items, all the fields that are set must match
+type ConfigSelector struct {
+	// Types are globs of the type, one of them must match
+	Types []string `json:"types,omitempty" yaml:"types,omitempty"`
+	// Names are globs of the name, one of them must match
+	Names []string `json:"names,omitempty" yaml:"names,omitempty"`
+	// Tags are globs of the values of tags, all of them must match
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Expr is a CEL expression with the id, name, type, namespace, tags and config of the item, e.g.
+	// type == "EC2Instance" && config.State.Name == "terminated"
+	Expr string `json:"expr,omitempty" yaml:"expr,omitempty"`
+}
+
+// Retention overrides the global retention for the config types returned by the scraper, 0 uses the global value
+type Retention struct {
+	// ChangeDays after which changes are deleted
+	ChangeDays int `json:"changeDays,omitempty" yaml:"changeDays,omitempty"`
+	// DeletedItemDays after which deleted config items are hard deleted
+	DeletedItemDays int `json:"deletedItemDays,omitempty" yaml:"deletedItemDays,omitempty"`
+}
+
+// Trigger runs the scraper when a message is received, in addition to the schedule
+type Trigger struct {
+	SQS *SQSTrigger `json:"sqs,omitempty" yaml:"sqs,omitempty"`
+}
+
+// SQSTrigger long polls an SQS queue, an EventBridge rule can target the queue to trigger a scrape on events.
+// Messages with resource ARNs (an EventBridge "resources" list or an "arn" field) only scrape those resources
+type SQSTrigger struct {
+	AWSConnection `json:",inline" yaml:",inline"`
+	QueueURL      string `json:"queue_url" yaml:"queue_url"`
 }
 
 // IsEmpty ...
@@ -26,6 +105,18 @@ func (c ConfigScraper) IsEmpty() bool {
 	return len(c.AWS) == 0 && len(c.File) == 0
 }
 
+func (c ConfigScraper) GetJitter() time.Duration {
+	if c.Jitter == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.Jitter)
+	if err != nil {
+		logger.Warnf("Invalid jitter %s: %v", c.Jitter, err)
+		return 0
+	}
+	return d
+}
+
 func (c ConfigScraper) IsTrace() bool {
 	return c.LogLevel == "trace"
 }
@@ -43,10 +134,10 @@ func (e ExternalID) IsEmpty() bool {
 	return e.ExternalType == "" && len(e.ExternalID) == 0
 }
 
-func (e ExternalID) CacheKey() string {
-	return fmt.Sprintf("external_id:%s:%s", e.ExternalType, strings.Join(e.ExternalID, ","))
+func (e ExternalID) CacheKey(tenant string) string {
+	return fmt.Sprintf("external_id:%s:%s:%s", tenant, e.ExternalType, strings.Join(e.ExternalID, ","))
 }
 
-func (e ExternalID) WhereClause(db *gorm.DB) *gorm.DB {
-	return db.Where("external_type = ? AND external_id  @> ?", e.ExternalType, pq.StringArray(e.ExternalID))
+func (e ExternalID) WhereClause(db *gorm.DB, tenant string) *gorm.DB {
+	return db.Where("external_type = ? AND external_id  @> ? AND tenant = ?", e.ExternalType, pq.StringArray(e.ExternalID), tenant)
 }
@@ -0,0 +1,22 @@
+package v1
+
+// Webhook is notified of the config changes and analysis results matching its filter
+type Webhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Format of the payload, one of json (default), slack or teams
+	Format string        `json:"format,omitempty"`
+	Filter WebhookFilter `json:"filter,omitempty"`
+	// Headers are added to every request, e.g. an Authorization header
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// WebhookFilter selects the events sent to a webhook, an empty list matches everything
+type WebhookFilter struct {
+	// Events is a list of event kinds, change or analysis
+	Events []string `json:"events,omitempty"`
+	// Types match either the config type or the external type of the config item
+	Types       []string `json:"types,omitempty"`
+	Severities  []string `json:"severities,omitempty"`
+	ChangeTypes []string `json:"changeTypes,omitempty"`
+}
@@ -23,6 +23,7 @@ package v1
 
 import (
 	"github.com/flanksource/kommons"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -36,6 +37,8 @@ func (in *AWS) DeepCopyInto(out *AWS) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.CloudTrail.DeepCopyInto(&out.CloudTrail)
+	out.CertificateExpiry = in.CertificateExpiry
+	out.SecretRotation = in.SecretRotation
 	if in.Include != nil {
 		in, out := &in.Include, &out.Include
 		*out = make([]string, len(*in))
@@ -47,6 +50,16 @@ func (in *AWS) DeepCopyInto(out *AWS) {
 		copy(*out, *in)
 	}
 	out.CostReporting = in.CostReporting
+	if in.Organization != nil {
+		in, out := &in.Organization, &out.Organization
+		*out = new(AWSOrganization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Incremental != nil {
+		in, out := &in.Incremental, &out.Incremental
+		*out = new(AWSIncremental)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWS.
@@ -81,6 +94,46 @@ func (in *AWSConnection) DeepCopy() *AWSConnection {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSIncremental) DeepCopyInto(out *AWSIncremental) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSIncremental.
+func (in *AWSIncremental) DeepCopy() *AWSIncremental {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSIncremental)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSOrganization) DeepCopyInto(out *AWSOrganization) {
+	*out = *in
+	if in.Accounts != nil {
+		in, out := &in.Accounts, &out.Accounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeAccounts != nil {
+		in, out := &in.ExcludeAccounts, &out.ExcludeAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSOrganization.
+func (in *AWSOrganization) DeepCopy() *AWSOrganization {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSOrganization)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Authentication) DeepCopyInto(out *Authentication) {
 	*out = *in
@@ -98,6 +151,34 @@ func (in *Authentication) DeepCopy() *Authentication {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Azure) DeepCopyInto(out *Azure) {
+	*out = *in
+	in.BaseScraper.DeepCopyInto(&out.BaseScraper)
+	in.ClientID.DeepCopyInto(&out.ClientID)
+	in.ClientSecret.DeepCopyInto(&out.ClientSecret)
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Azure.
+func (in *Azure) DeepCopy() *Azure {
+	if in == nil {
+		return nil
+	}
+	out := new(Azure)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureDevops) DeepCopyInto(out *AzureDevops) {
 	*out = *in
@@ -129,6 +210,18 @@ func (in *AzureDevops) DeepCopy() *AzureDevops {
 func (in *BaseScraper) DeepCopyInto(out *BaseScraper) {
 	*out = *in
 	in.Transform.DeepCopyInto(&out.Transform)
+	if in.Transforms != nil {
+		in, out := &in.Transforms, &out.Transforms
+		*out = make([]TransformStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Mapping != nil {
+		in, out := &in.Mapping, &out.Mapping
+		*out = new(Mapping)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseScraper.
@@ -141,6 +234,21 @@ func (in *BaseScraper) DeepCopy() *BaseScraper {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateExpiry) DeepCopyInto(out *CertificateExpiry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateExpiry.
+func (in *CertificateExpiry) DeepCopy() *CertificateExpiry {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateExpiry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudTrail) DeepCopyInto(out *CloudTrail) {
 	*out = *in
@@ -161,6 +269,21 @@ func (in *CloudTrail) DeepCopy() *CloudTrail {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKey) DeepCopyInto(out *ConfigMapKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKey.
+func (in *ConfigMapKey) DeepCopy() *ConfigMapKey {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigScraper) DeepCopyInto(out *ConfigScraper) {
 	*out = *in
@@ -192,6 +315,13 @@ func (in *ConfigScraper) DeepCopyInto(out *ConfigScraper) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = make([]Azure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AzureDevops != nil {
 		in, out := &in.AzureDevops, &out.AzureDevops
 		*out = make([]AzureDevops, len(*in))
@@ -199,6 +329,20 @@ func (in *ConfigScraper) DeepCopyInto(out *ConfigScraper) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.GitHub != nil {
+		in, out := &in.GitHub, &out.GitHub
+		*out = make([]GitHub, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GitHubActions != nil {
+		in, out := &in.GitHubActions, &out.GitHubActions
+		*out = make([]GitHubActions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.SQL != nil {
 		in, out := &in.SQL, &out.SQL
 		*out = make([]SQL, len(*in))
@@ -206,6 +350,61 @@ func (in *ConfigScraper) DeepCopyInto(out *ConfigScraper) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Trigger != nil {
+		in, out := &in.Trigger, &out.Trigger
+		*out = new(Trigger)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(Retention)
+		**out = **in
+	}
+	if in.Views != nil {
+		in, out := &in.Views, &out.Views
+		*out = make([]View, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]Policy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Ownership != nil {
+		in, out := &in.Ownership, &out.Ownership
+		*out = new(Ownership)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Includes != nil {
+		in, out := &in.Includes, &out.Includes
+		*out = make([]ConfigSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Excludes != nil {
+		in, out := &in.Excludes, &out.Excludes
+		*out = make([]ConfigSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Libraries != nil {
+		in, out := &in.Libraries, &out.Libraries
+		*out = make([]Library, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigScraper.
@@ -218,6 +417,38 @@ func (in *ConfigScraper) DeepCopy() *ConfigScraper {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigSelector) DeepCopyInto(out *ConfigSelector) {
+	*out = *in
+	if in.Types != nil {
+		in, out := &in.Types, &out.Types
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigSelector.
+func (in *ConfigSelector) DeepCopy() *ConfigSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Connection) DeepCopyInto(out *Connection) {
 	*out = *in
@@ -249,6 +480,33 @@ func (in *CostReporting) DeepCopy() *CostReporting {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftRequest) DeepCopyInto(out *DriftRequest) {
+	*out = *in
+	in.Left.DeepCopyInto(&out.Left)
+	in.Right.DeepCopyInto(&out.Right)
+	if in.MatchTags != nil {
+		in, out := &in.MatchTags, &out.MatchTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftRequest.
+func (in *DriftRequest) DeepCopy() *DriftRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalID) DeepCopyInto(out *ExternalID) {
 	*out = *in
@@ -345,6 +603,55 @@ func (in *GCPConnection) DeepCopy() *GCPConnection {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHub) DeepCopyInto(out *GitHub) {
+	*out = *in
+	in.BaseScraper.DeepCopyInto(&out.BaseScraper)
+	in.PersonalAccessToken.DeepCopyInto(&out.PersonalAccessToken)
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHub.
+func (in *GitHub) DeepCopy() *GitHub {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHub)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubActions) DeepCopyInto(out *GitHubActions) {
+	*out = *in
+	in.BaseScraper.DeepCopyInto(&out.BaseScraper)
+	in.PersonalAccessToken.DeepCopyInto(&out.PersonalAccessToken)
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Workflows != nil {
+		in, out := &in.Workflows, &out.Workflows
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubActions.
+func (in *GitHubActions) DeepCopy() *GitHubActions {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubActions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitLocation) DeepCopyInto(out *GitLocation) {
 	*out = *in
@@ -360,6 +667,21 @@ func (in *GitLocation) DeepCopy() *GitLocation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngestResponse) DeepCopyInto(out *IngestResponse) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngestResponse.
+func (in *IngestResponse) DeepCopy() *IngestResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(IngestResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in JSONStringMap) DeepCopyInto(out *JSONStringMap) {
 	{
@@ -395,6 +717,26 @@ func (in *Kubernetes) DeepCopyInto(out *Kubernetes) {
 		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Contexts != nil {
+		in, out := &in.Contexts, &out.Contexts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(NamespaceSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kubernetes.
@@ -431,6 +773,46 @@ func (in *KubernetesFile) DeepCopy() *KubernetesFile {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Library) DeepCopyInto(out *Library) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapKey)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Library.
+func (in *Library) DeepCopy() *Library {
+	if in == nil {
+		return nil
+	}
+	out := new(Library)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Mapping) DeepCopyInto(out *Mapping) {
+	*out = *in
+	out.ID = in.ID
+	out.Name = in.Name
+	out.Type = in.Type
+	out.Parent = in.Parent
+	out.ParentType = in.ParentType
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Mapping.
+func (in *Mapping) DeepCopy() *Mapping {
+	if in == nil {
+		return nil
+	}
+	out := new(Mapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Mask) DeepCopyInto(out *Mask) {
 	*out = *in
@@ -481,6 +863,31 @@ func (in *MaskSelector) DeepCopy() *MaskSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSelector) DeepCopyInto(out *NamespaceSelector) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSelector.
+func (in *NamespaceSelector) DeepCopy() *NamespaceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenAPIFieldRef) DeepCopyInto(out *OpenAPIFieldRef) {
 	*out = *in
@@ -496,6 +903,56 @@ func (in *OpenAPIFieldRef) DeepCopy() *OpenAPIFieldRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OwnerTag) DeepCopyInto(out *OwnerTag) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OwnerTag.
+func (in *OwnerTag) DeepCopy() *OwnerTag {
+	if in == nil {
+		return nil
+	}
+	out := new(OwnerTag)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Ownership) DeepCopyInto(out *Ownership) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]OwnerTag, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ownership.
+func (in *Ownership) DeepCopy() *Ownership {
+	if in == nil {
+		return nil
+	}
+	out := new(Ownership)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodFile) DeepCopyInto(out *PodFile) {
 	*out = *in
@@ -516,6 +973,26 @@ func (in *PodFile) DeepCopy() *PodFile {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Policy) DeepCopyInto(out *Policy) {
+	*out = *in
+	if in.Types != nil {
+		in, out := &in.Types, &out.Types
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
+func (in *Policy) DeepCopy() *Policy {
+	if in == nil {
+		return nil
+	}
+	out := new(Policy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in Properties) DeepCopyInto(out *Properties) {
 	{
@@ -597,6 +1074,51 @@ func (in *QueryRequest) DeepCopy() *QueryRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Redact) DeepCopyInto(out *Redact) {
+	*out = *in
+	if in.Detectors != nil {
+		in, out := &in.Detectors, &out.Detectors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.JSONPaths != nil {
+		in, out := &in.JSONPaths, &out.JSONPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Redact.
+func (in *Redact) DeepCopy() *Redact {
+	if in == nil {
+		return nil
+	}
+	out := new(Redact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Relate) DeepCopyInto(out *Relate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Relate.
+func (in *Relate) DeepCopy() *Relate {
+	if in == nil {
+		return nil
+	}
+	out := new(Relate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RelationshipResult) DeepCopyInto(out *RelationshipResult) {
 	*out = *in
@@ -635,6 +1157,21 @@ func (in RelationshipResults) DeepCopy() RelationshipResults {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Rename) DeepCopyInto(out *Rename) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Rename.
+func (in *Rename) DeepCopy() *Rename {
+	if in == nil {
+		return nil
+	}
+	out := new(Rename)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSelector) DeepCopyInto(out *ResourceSelector) {
 	*out = *in
@@ -650,6 +1187,21 @@ func (in *ResourceSelector) DeepCopy() *ResourceSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Retention) DeepCopyInto(out *Retention) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Retention.
+func (in *Retention) DeepCopy() *Retention {
+	if in == nil {
+		return nil
+	}
+	out := new(Retention)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SQL) DeepCopyInto(out *SQL) {
 	*out = *in
@@ -667,13 +1219,29 @@ func (in *SQL) DeepCopy() *SQL {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQSTrigger) DeepCopyInto(out *SQSTrigger) {
+	*out = *in
+	in.AWSConnection.DeepCopyInto(&out.AWSConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQSTrigger.
+func (in *SQSTrigger) DeepCopy() *SQSTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(SQSTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScrapeConfig) DeepCopyInto(out *ScrapeConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScrapeConfig.
@@ -745,6 +1313,17 @@ func (in *ScrapeConfigSpec) DeepCopy() *ScrapeConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScrapeConfigStatus) DeepCopyInto(out *ScrapeConfigStatus) {
 	*out = *in
+	if in.LastScrapeTime != nil {
+		in, out := &in.LastScrapeTime, &out.LastScrapeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScrapeConfigStatus.
@@ -772,6 +1351,48 @@ func (in *Script) DeepCopy() *Script {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchRequest) DeepCopyInto(out *SearchRequest) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchRequest.
+func (in *SearchRequest) DeepCopy() *SearchRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretRotation) DeepCopyInto(out *SecretRotation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretRotation.
+func (in *SecretRotation) DeepCopy() *SecretRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Template) DeepCopyInto(out *Template) {
 	*out = *in
@@ -806,6 +1427,16 @@ func (in *Transform) DeepCopyInto(out *Transform) {
 		*out = make(MaskList, len(*in))
 		copy(*out, *in)
 	}
+	if in.ChangeExclusions != nil {
+		in, out := &in.ChangeExclusions, &out.ChangeExclusions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Redact != nil {
+		in, out := &in.Redact, &out.Redact
+		*out = new(Redact)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Transform.
@@ -817,3 +1448,139 @@ func (in *Transform) DeepCopy() *Transform {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransformStep) DeepCopyInto(out *TransformStep) {
+	*out = *in
+	if in.Mask != nil {
+		in, out := &in.Mask, &out.Mask
+		*out = new(Mask)
+		**out = **in
+	}
+	if in.Rename != nil {
+		in, out := &in.Rename, &out.Rename
+		*out = new(Rename)
+		**out = **in
+	}
+	if in.Relate != nil {
+		in, out := &in.Relate, &out.Relate
+		*out = new(Relate)
+		**out = **in
+	}
+	if in.Script != nil {
+		in, out := &in.Script, &out.Script
+		*out = new(Script)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformStep.
+func (in *TransformStep) DeepCopy() *TransformStep {
+	if in == nil {
+		return nil
+	}
+	out := new(TransformStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Trigger) DeepCopyInto(out *Trigger) {
+	*out = *in
+	if in.SQS != nil {
+		in, out := &in.SQS, &out.SQS
+		*out = new(SQSTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Trigger.
+func (in *Trigger) DeepCopy() *Trigger {
+	if in == nil {
+		return nil
+	}
+	out := new(Trigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *View) DeepCopyInto(out *View) {
+	*out = *in
+	in.SearchRequest.DeepCopyInto(&out.SearchRequest)
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new View.
+func (in *View) DeepCopy() *View {
+	if in == nil {
+		return nil
+	}
+	out := new(View)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Webhook) DeepCopyInto(out *Webhook) {
+	*out = *in
+	in.Filter.DeepCopyInto(&out.Filter)
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Webhook.
+func (in *Webhook) DeepCopy() *Webhook {
+	if in == nil {
+		return nil
+	}
+	out := new(Webhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookFilter) DeepCopyInto(out *WebhookFilter) {
+	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Types != nil {
+		in, out := &in.Types, &out.Types
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Severities != nil {
+		in, out := &in.Severities, &out.Severities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChangeTypes != nil {
+		in, out := &in.ChangeTypes, &out.ChangeTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookFilter.
+func (in *WebhookFilter) DeepCopy() *WebhookFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookFilter)
+	in.DeepCopyInto(out)
+	return out
+}
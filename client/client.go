@@ -0,0 +1,187 @@
+// Package client is a Go client of the config-db HTTP API, the API is described by GET /openapi.json
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+)
+
+// Client of a config-db instance
+type Client struct {
+	// URL of config-db, e.g. http://config-db:8080
+	URL string
+	// Token is sent as a bearer token when it is set
+	Token string
+	HTTP  *http.Client
+}
+
+// Error is returned when config-db responds with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("config-db responded with %d: %s", e.StatusCode, e.Message)
+}
+
+// New returns a client of the config-db at the url, authenticated with the token when it is not empty
+func New(url, token string) *Client {
+	return &Client{
+		URL:   strings.TrimSuffix(url, "/"),
+		Token: token,
+		HTTP:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Search returns a page of the config items matching all the filters of the request
+func (c *Client) Search(ctx context.Context, request v1.SearchRequest) (*models.SearchResult, error) {
+	params := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	set("text", request.Text)
+	set("jsonpath", request.JSONPath)
+	set("type", request.Type)
+	set("external_type", request.ExternalType)
+	set("account", request.Account)
+	set("region", request.Region)
+	set("sort_by", request.SortBy)
+	set("order", request.Order)
+	set("cursor", request.Cursor)
+	set("fields", strings.Join(request.Fields, ","))
+	for key, value := range request.Tags {
+		params.Add("tag", key+"="+value)
+	}
+	if request.Limit > 0 {
+		params.Set("limit", strconv.Itoa(request.Limit))
+	}
+	if request.Offset > 0 {
+		params.Set("offset", strconv.Itoa(request.Offset))
+	}
+	if request.MinCost30d > 0 {
+		params.Set("min_cost_30d", strconv.FormatFloat(request.MinCost30d, 'f', -1, 64))
+	}
+	var result models.SearchResult
+	if err := c.do(ctx, http.MethodGet, "/query", params, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List returns a page of the config items of the type
+func (c *Client) List(ctx context.Context, configType string, limit, offset int) (*models.SearchResult, error) {
+	return c.Search(ctx, v1.SearchRequest{Type: configType, Limit: limit, Offset: offset})
+}
+
+// Get returns the config item with the id
+func (c *Client) Get(ctx context.Context, id string) (*models.ConfigItem, error) {
+	return c.getConfig(ctx, id, nil)
+}
+
+// GetAt returns the config item with the id as it was at the time
+func (c *Client) GetAt(ctx context.Context, id string, at time.Time) (*models.ConfigItem, error) {
+	return c.getConfig(ctx, id, url.Values{"at": {at.Format(time.RFC3339)}})
+}
+
+func (c *Client) getConfig(ctx context.Context, id string, params url.Values) (*models.ConfigItem, error) {
+	var ci models.ConfigItem
+	if err := c.do(ctx, http.MethodGet, "/config/"+url.PathEscape(id), params, nil, &ci); err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// Changes returns a page of the changes of the config item with the id, newest first
+func (c *Client) Changes(ctx context.Context, id string, limit, offset int) ([]models.ConfigChange, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		params.Set("offset", strconv.Itoa(offset))
+	}
+	var changes []models.ConfigChange
+	if err := c.do(ctx, http.MethodGet, "/config/"+url.PathEscape(id)+"/changes", params, nil, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Ingest pushes the results, it requires a token with the ingest role and returns the number of results saved
+func (c *Client) Ingest(ctx context.Context, results ...v1.ScrapeResult) (int, error) {
+	requests := make([]v1.IngestRequest, 0, len(results))
+	for _, result := range results {
+		requests = append(requests, v1.NewIngestRequest(result))
+	}
+	var resp v1.IngestResponse
+	err := c.do(ctx, http.MethodPost, "/config", nil, requests, &resp)
+	return resp.Items, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body, out interface{}) error {
+	endpoint := c.URL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		// echo responds with {"message": "..."}
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
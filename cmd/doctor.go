@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/scrapers"
+	"github.com/spf13/cobra"
+)
+
+// Doctor ...
+var Doctor = &cobra.Command{
+	Use:   "doctor [scraper.yaml...]",
+	Short: "Check the database and the connections of scrapers without scraping",
+	Long:  "Check the database and the connections of scrapers without scraping, the scrape configs saved in the database are checked when a database is configured",
+	Run: func(cmd *cobra.Command, configFiles []string) {
+		scraperConfigs, err := v1.ParseConfigs(configFiles...)
+		if err != nil {
+			logger.Fatalf(err.Error())
+		}
+
+		var diagnostics []v1.Diagnostic
+		if db.ConnectionString != "" {
+			d := v1.Diagnostic{Check: "database"}
+			if err := db.Init(db.ConnectionString); err != nil {
+				d.Error = err.Error()
+			} else {
+				defer db.Close()
+				scraperConfigs = append(scraperConfigs, savedScrapeConfigs(&d)...)
+			}
+			diagnostics = append(diagnostics, d)
+		}
+
+		for _, scraper := range scraperConfigs {
+			_scraper := scraper
+			ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient, Scraper: &_scraper, Connections: scrapers.Connections}
+			diagnostics = append(diagnostics, scrapers.Diagnose(ctx, scraper)...)
+		}
+
+		failed := 0
+		for _, d := range diagnostics {
+			fmt.Println(d)
+			if d.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("%d of %d checks failed\n", failed, len(diagnostics))
+			os.Exit(1)
+		}
+	},
+}
+
+// savedScrapeConfigs returns the scrape configs saved in the database, errors are recorded in the diagnostic
+func savedScrapeConfigs(d *v1.Diagnostic) []v1.ConfigScraper {
+	saved, err := db.GetScrapeConfigs()
+	if err != nil {
+		d.Error = err.Error()
+		return nil
+	}
+	var configs []v1.ConfigScraper
+	for _, scrapeConfig := range saved {
+		scraper, err := scrapeConfig.V1ConfigScraper()
+		if err != nil {
+			d.Error = fmt.Sprintf("invalid scrape config %s: %v", scrapeConfig.ID, err)
+			continue
+		}
+		scraper.Name = scrapeConfig.ID.String()
+		configs = append(configs, scraper)
+	}
+	return configs
+}
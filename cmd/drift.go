@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/spf13/cobra"
+)
+
+var driftLeft, driftRight []string
+var driftRequest v1.DriftRequest
+
+// Drift ...
+var Drift = &cobra.Command{
+	Use:     "drift",
+	Short:   "Compare the config items of two accounts, regions or types",
+	Example: "config-db drift --left account=staging --right account=production --match-tag app --exclude '$.Tags'",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		if driftRequest.Left, err = parseSearch(driftLeft); err != nil {
+			logger.Fatalf("Invalid --left: %v", err)
+		}
+		if driftRequest.Right, err = parseSearch(driftRight); err != nil {
+			logger.Fatalf("Invalid --right: %v", err)
+		}
+		db.MustInit()
+		defer db.Close()
+		report, err := db.GetDrift(driftRequest)
+		if err != nil {
+			logger.Fatalf("Failed to compare config items: %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			logger.Fatalf("Failed to write report: %v", err)
+		}
+	},
+}
+
+// parseSearch parses key=value filters into a search, tags are filtered with tag.<key>=<value>
+func parseSearch(filters []string) (v1.SearchRequest, error) {
+	var search v1.SearchRequest
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok {
+			return search, fmt.Errorf("expected key=value, got %s", filter)
+		}
+		switch {
+		case key == "type":
+			search.Type = value
+		case key == "external_type":
+			search.ExternalType = value
+		case key == "account":
+			search.Account = value
+		case key == "region":
+			search.Region = value
+		case key == "jsonpath":
+			search.JSONPath = value
+		case strings.HasPrefix(key, "tag."):
+			if search.Tags == nil {
+				search.Tags = make(map[string]string)
+			}
+			search.Tags[strings.TrimPrefix(key, "tag.")] = value
+		default:
+			return search, fmt.Errorf("unknown filter %s, expected type, external_type, account, region, jsonpath or tag.<key>", key)
+		}
+	}
+	return search, nil
+}
+
+func init() {
+	Drift.Flags().StringSliceVar(&driftLeft, "left", nil, "Filters selecting the left items, e.g. account=123,tag.env=staging")
+	Drift.Flags().StringSliceVar(&driftRight, "right", nil, "Filters selecting the right items")
+	Drift.Flags().StringSliceVar(&driftRequest.MatchTags, "match-tag", nil, "Tags that must be equal for items without a common external id to match, in addition to the type and name")
+	Drift.Flags().StringArrayVar(&driftRequest.Exclude, "exclude", nil, "JSONPath expression of a field that is ignored, can be repeated")
+}
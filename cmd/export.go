@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/config-db/db"
+	"github.com/spf13/cobra"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+var exportFormat, exportPath string
+
+const importBatchSize = 500
+
+// parquetRecord stores the exported rows as JSON, so that every table fits in one file
+type parquetRecord struct {
+	Kind string `parquet:"name=kind, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID   string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Data string `parquet:"name=data, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// Export ...
+var Export = &cobra.Command{
+	Use:   "export",
+	Short: "Export the config items, changes and analyses to a file or s3://bucket/key",
+	Run: func(cmd *cobra.Command, args []string) {
+		db.MustInit()
+		defer db.Close()
+		out, err := createExport(exportPath)
+		if err != nil {
+			logger.Fatalf("Failed to create %s: %v", exportPath, err)
+		}
+		count, err := exportInventory(out, exportFormat)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			logger.Fatalf("Failed to export to %s: %v", exportPath, err)
+		}
+		logger.Infof("Exported %d records to %s", count, exportPath)
+	},
+}
+
+// Import ...
+var Import = &cobra.Command{
+	Use:   "import",
+	Short: "Import an export of another instance, existing config items and analyses are replaced",
+	Run: func(cmd *cobra.Command, args []string) {
+		db.MustInit()
+		defer db.Close()
+		data, err := readExport(exportPath)
+		if err != nil {
+			logger.Fatalf("Failed to read %s: %v", exportPath, err)
+		}
+		format := exportFormat
+		if !cmd.Flags().Changed("format") && strings.HasSuffix(exportPath, ".parquet") {
+			format = "parquet"
+		}
+		count, err := importInventory(data, format)
+		if err != nil {
+			logger.Fatalf("Failed to import after %d records: %v", count, err)
+		}
+		logger.Infof("Imported %d records from %s", count, exportPath)
+	},
+}
+
+func exportInventory(w io.Writer, format string) (int, error) {
+	count := 0
+	switch format {
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		err := db.ExportInventory(func(r db.Record) error {
+			count++
+			return encoder.Encode(r)
+		})
+		return count, err
+	case "parquet":
+		pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRecord), 4)
+		if err != nil {
+			return 0, err
+		}
+		if err := db.ExportInventory(func(r db.Record) error {
+			count++
+			return pw.Write(parquetRecord{Kind: r.Kind, ID: r.ID, Data: string(r.Data)})
+		}); err != nil {
+			return count, err
+		}
+		return count, pw.WriteStop()
+	}
+	return 0, fmt.Errorf("unknown format %s, expected jsonl or parquet", format)
+}
+
+func importInventory(data []byte, format string) (int, error) {
+	count := 0
+	importer := db.NewImporter()
+	var batch []db.Record
+	add := func(r db.Record) error {
+		batch = append(batch, r)
+		if len(batch) < importBatchSize {
+			return nil
+		}
+		err := importer.Import(batch)
+		if err == nil {
+			count += len(batch)
+		}
+		batch = nil
+		return err
+	}
+
+	switch format {
+	case "jsonl":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var r db.Record
+			if err := decoder.Decode(&r); err != nil {
+				return count, err
+			}
+			if err := add(r); err != nil {
+				return count, err
+			}
+		}
+	case "parquet":
+		pr, err := reader.NewParquetReader(buffer.NewBufferFileFromBytes(data), new(parquetRecord), 4)
+		if err != nil {
+			return 0, err
+		}
+		defer pr.ReadStop()
+		for remaining := int(pr.GetNumRows()); remaining > 0; remaining -= importBatchSize {
+			rows := make([]parquetRecord, min(remaining, importBatchSize))
+			if err := pr.Read(&rows); err != nil {
+				return count, err
+			}
+			for _, row := range rows {
+				if err := add(db.Record{Kind: row.Kind, ID: row.ID, Data: json.RawMessage(row.Data)}); err != nil {
+					return count, err
+				}
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unknown format %s, expected jsonl or parquet", format)
+	}
+
+	if len(batch) > 0 {
+		if err := importer.Import(batch); err != nil {
+			return count, err
+		}
+		count += len(batch)
+	}
+	return count, importer.Finish()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// createExport returns a writer to the file or to an upload to s3, the upload completes when the writer is closed
+func createExport(path string) (io.WriteCloser, error) {
+	bucket, key, ok := parseS3URL(path)
+	if !ok {
+		return os.Create(path)
+	}
+	client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	upload := &s3Upload{PipeWriter: writer, done: make(chan error, 1)}
+	go func() {
+		_, err := manager.NewUploader(client).Upload(context.Background(), &s3.PutObjectInput{Bucket: &bucket, Key: &key, Body: reader})
+		// unblock the writer when the upload fails
+		_ = reader.CloseWithError(err)
+		upload.done <- err
+	}()
+	return upload, nil
+}
+
+// s3Upload streams the export to a multipart upload
+type s3Upload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (u *s3Upload) Close() error {
+	if err := u.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-u.done
+}
+
+func readExport(path string) ([]byte, error) {
+	bucket, key, ok := parseS3URL(path)
+	if !ok {
+		return os.ReadFile(path)
+	}
+	client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := client.GetObject(context.Background(), &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	return io.ReadAll(obj.Body)
+}
+
+func parseS3URL(path string) (bucket, key string, ok bool) {
+	if !strings.HasPrefix(path, "s3://") {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(path, "s3://"), "/")
+	return bucket, key, true
+}
+
+func s3Client() (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg), nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{Export, Import} {
+		cmd.Flags().StringVar(&exportFormat, "format", "jsonl", "Format of the export, jsonl or parquet")
+	}
+	Export.Flags().StringVar(&exportPath, "out", "", "File or s3://bucket/key to export to")
+	Import.Flags().StringVar(&exportPath, "in", "", "File or s3://bucket/key to import from")
+	_ = Export.MarkFlagRequired("out")
+	_ = Import.MarkFlagRequired("in")
+}
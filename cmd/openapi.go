@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/config-db/query"
+	"github.com/spf13/cobra"
+)
+
+// OpenAPI ...
+var OpenAPI = &cobra.Command{
+	Use:   "openapi",
+	Short: "Print the OpenAPI 3 document of the HTTP API",
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := query.OpenAPI()
+		if err != nil {
+			logger.Fatalf("Failed to generate the OpenAPI document: %v", err)
+		}
+		fmt.Println(string(data))
+	},
+}
@@ -3,10 +3,18 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/events"
+	"github.com/flanksource/config-db/ingest"
+	"github.com/flanksource/config-db/query"
+	"github.com/flanksource/config-db/reports"
 	"github.com/flanksource/config-db/scrapers"
+	"github.com/flanksource/config-db/scrapers/processors"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/config-db/upstream"
 	"github.com/flanksource/config-db/utils/kube"
 	"github.com/flanksource/kommons"
 	"github.com/spf13/cobra"
@@ -14,11 +22,12 @@ import (
 )
 
 var dev bool
-var httpPort, metricsPort, devGuiPort int
+var httpPort, metricsPort, devGuiPort, grpcPort int
 var disableKubernetes bool
 var kommonsClient *kommons.Client
 var publicEndpoint = "http://localhost:8080"
 var disablePostgrest bool
+var shutdownTimeout time.Duration
 var (
 	version = "dev"
 	commit  = "none"
@@ -66,6 +75,31 @@ func ServerFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&dev, "dev", false, "Run in development mode")
 	flags.BoolVar(&disablePostgrest, "disable-postgrest", false, "Disable the postgrest server")
 	flags.StringVar(&scrapers.DefaultSchedule, "default-schedule", "@every 60m", "Default schedule for configs that don't specfiy one")
+	flags.StringVar(&scrapers.RetentionSchedule, "retention-schedule", "@every 1h", "Schedule of the job that prunes old changes and deleted config items")
+	flags.StringVar(&scrapers.CostSchedule, "cost-schedule", "@daily", "Schedule of the job that allocates the cost of Kubernetes nodes to namespaces and deployments and records the daily costs of config items to forecast them and flag anomalies, disabled when empty")
+	flags.IntVar(&scrapers.CostTrailingDays, "cost-trailing-days", 7, "Number of days of daily costs that forecasts and anomalies are based on")
+	flags.Float64Var(&scrapers.CostAnomalyFactor, "cost-anomaly-factor", 3, "A daily cost this many times the trailing average is flagged as an anomaly")
+	flags.StringVar(&scrapers.ReportSchedule, "report-schedule", "@weekly", "Schedule of the job that exports the posture report to --report-output")
+	flags.StringVar(&reports.Output, "report-output", "", "Directory or s3://bucket/prefix to export reports to, reports are disabled when empty")
+	flags.StringSliceVar(&reports.Formats, "report-formats", []string{"json", "csv", "html"}, "Formats of the exported reports")
+	flags.StringSliceVar(&query.IngestTokens, "ingest-token", nil, "Bearer tokens with only the ingest role, accepted by POST /config to push config items, or tenant:token to save the pushed items in the tenant")
+	flags.StringSliceVar(&query.APITokens, "api-token", nil, "Bearer tokens of API callers as role:token with the roles read, ingest or admin, or tenant/role:token to scope the caller to a tenant. The API is open to anyone when neither tokens nor an OIDC issuer are set")
+	flags.StringVar(&query.OIDCIssuer, "oidc-issuer", "", "Issuer of the JWTs accepted as bearer tokens")
+	flags.StringVar(&query.OIDCClientID, "oidc-client-id", "", "Audience of the accepted JWTs, not checked when empty")
+	flags.StringVar(&query.OIDCRoleClaim, "oidc-role-claim", "roles", "Claim of the JWTs with the roles of the caller")
+	flags.StringVar(&query.OIDCTenantClaim, "oidc-tenant-claim", "", "Claim of the JWTs with the tenant the caller is scoped to, JWTs without it are rejected. Callers see every tenant when empty")
+	flags.StringToStringVar(&query.OIDCRoles, "oidc-roles", nil, "Maps values of the role claim to roles, e.g. platform-team=admin, values that are role names map to themselves")
+	flags.IntVar(&grpcPort, "grpc-port", 0, "Port of the gRPC ingestion service, disabled when 0")
+	flags.IntVar(&ingest.MaxConcurrentStreams, "grpc-max-concurrent-streams", 4, "Number of gRPC streams saving batches at the same time")
+	flags.StringVar(&upstream.URL, "upstream-url", "", "URL of a hub to reconcile the scraped config items to, enables the agent mode")
+	flags.StringVar(&upstream.Token, "upstream-token", "", "Ingest token of the hub")
+	flags.StringVar(&upstream.Agent, "agent-name", "", "Name of this agent on the hub")
+	flags.StringVar(&scrapers.UpstreamSchedule, "upstream-schedule", "@every 5m", "Schedule of the reconciliation with the hub")
+	flags.BoolVar(&scrapers.ScrapeQueue, "scrape-queue", false, "Queue scheduled scrapes as tasks, split by AWS region, for the workers of all the replicas instead of running them")
+	flags.IntVar(&scrapers.QueueWorkers, "queue-workers", 0, "Number of workers running queued scrape tasks in this replica")
+	flags.IntVar(&scrapers.QueueMaxAttempts, "queue-max-attempts", 3, "Attempts of a failed scrape task before it is dead")
+	flags.DurationVar(&scrapers.QueueTaskTimeout, "queue-task-timeout", time.Hour, "Time after which a running scrape task is returned to the queue, as its worker is assumed to have stopped")
+	flags.DurationVar(&shutdownTimeout, "shutdown-timeout", 25*time.Second, "Time running scrapes have on shutdown to save their results and checkpoints")
 	flags.StringVar(&publicEndpoint, "public-endpoint", "http://localhost:8080", "Public endpoint that this instance is exposed under")
 }
 
@@ -85,6 +119,9 @@ func init() {
 	})
 
 	db.Flags(Root.PersistentFlags())
+	events.Flags(Root.PersistentFlags())
+	secrets.Flags(Root.PersistentFlags())
+	Root.PersistentFlags().BoolVar(&processors.RedactSecrets, "redact-secrets", false, "Redact secrets and emails found by the built-in detectors in scrapers that do not configure transform.redact")
 
-	Root.AddCommand(Run, Analyze, Serve, GoOffline, Operator)
+	Root.AddCommand(Run, Analyze, Serve, GoOffline, Operator, Export, Import, Drift, Doctor, Validate, OpenAPI)
 }
@@ -9,12 +9,15 @@ import (
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/events"
 	"github.com/flanksource/config-db/scrapers"
 	"github.com/spf13/cobra"
 )
 
 var outputDir string
 var filename string
+var dryRun bool
+var dryRunOutput string
 
 // Run ...
 var Run = &cobra.Command{
@@ -27,36 +30,85 @@ var Run = &cobra.Command{
 			logger.Fatalf(err.Error())
 		}
 
-		ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient}
+		ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient, Connections: scrapers.Connections, DryRun: dryRun}
 
-		if db.ConnectionString != "" {
-			db.MustInit()
+		if dryRun {
+			// the database is only read to diff the results against the current config items
+			if db.ConnectionString != "" {
+				db.MustInit()
+				defer db.Close()
+			}
+			var dryRunResults []db.DryRunResult
+			for _, config := range scraperConfigs {
+				// the items of each scraper are compared to the items of its tenant
+				results, err := scrapers.Run(ctx, config)
+				if err != nil {
+					logger.Fatalf(err.Error())
+				}
+				diff, err := db.DiffResults(results, config.Tenant)
+				if err != nil {
+					logger.Fatalf("Failed to diff the results: %v", err)
+				}
+				dryRunResults = append(dryRunResults, diff...)
+			}
+			if err := writeDryRun(dryRunResults, dryRunOutput); err != nil {
+				logger.Fatalf("Failed to write dry run: %v", err)
+			}
+			return
 		}
-		results, err := scrapers.Run(ctx, scraperConfigs...)
-		if err != nil {
-			logger.Fatalf(err.Error())
+
+		if db.ConnectionString != "" || db.Driver == db.DriverEmbedded {
+			db.MustInit()
+			defer db.Close()
+			if err := events.Start(); err != nil {
+				logger.Fatalf(err.Error())
+			}
+			defer events.Stop()
 		}
-		if db.ConnectionString != "" {
-			logger.Infof("Exporting %d resources to DB", len(results))
-			if err = db.SaveResults(ctx, results); err != nil {
-				logger.Errorf("Failed to update db: %+v", err)
+		for _, config := range scraperConfigs {
+			// the items of each scraper are saved to its tenant
+			ctx.Tenant, ctx.AfterSave = config.Tenant, &v1.Hooks{}
+			results, err := scrapers.Run(ctx, config)
+			if err != nil {
+				logger.Fatalf(err.Error())
 			}
-		} else if outputDir != "" {
-			logger.Infof("Exporting %d resources to %s", len(results), outputDir)
+			if db.ConnectionString != "" || db.Driver == db.DriverEmbedded {
+				logger.Infof("Exporting %d resources to DB", len(results))
+				if err = db.SaveResults(ctx, results); err != nil {
+					logger.Errorf("Failed to update db: %+v", err)
+				}
+			} else if outputDir != "" {
+				logger.Infof("Exporting %d resources to %s", len(results), outputDir)
 
-			for _, result := range results {
-				if err := exportResource(result, filename, outputDir); err != nil {
-					logger.Fatalf("failed to export results %v", err)
+				for _, result := range results {
+					if err := exportResource(result, filename, outputDir); err != nil {
+						logger.Fatalf("failed to export results %v", err)
+					}
 				}
-			}
 
-		} else {
-			logger.Fatalf("skipping export: neither --output-dir or --db is specified")
+			} else {
+				logger.Fatalf("skipping export: neither --output-dir or --db is specified")
+			}
 		}
 
 	},
 }
 
+// writeDryRun writes the results and their diff against the database as JSON to the output, or stdout when it is empty
+func writeDryRun(dryRun []db.DryRunResult, output string) error {
+	var err error
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.Create(output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dryRun)
+}
+
 func exportResource(resource v1.ScrapeResult, filename, outputDir string) error {
 	if resource.Config == nil && resource.AnalysisResult != nil {
 		logger.Debugf("%s/%s => %s", resource.ExternalType, resource.ID, *resource.AnalysisResult)
@@ -76,4 +128,6 @@ func exportResource(resource v1.ScrapeResult, filename, outputDir string) error
 func init() {
 	Run.Flags().StringVarP(&outputDir, "output-dir", "o", "configs", "The output folder for configurations")
 	Run.Flags().StringVarP(&filename, "filename", "f", ".id", "The filename to save seach resource under")
+	Run.Flags().BoolVar(&dryRun, "dry-run", false, "Print the results and their diff against the database as JSON without saving them")
+	Run.Flags().StringVar(&dryRunOutput, "dry-run-output", "", "File to write the dry run to, defaults to stdout")
 }
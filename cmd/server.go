@@ -1,17 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/flanksource/commons/logger"
-	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/events"
+	"github.com/flanksource/config-db/ingest"
+	"github.com/flanksource/config-db/notifications"
 	"github.com/flanksource/config-db/query"
 
 	"github.com/flanksource/config-db/scrapers"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +33,13 @@ var Serve = &cobra.Command{
 }
 
 func serve(configFiles []string) {
+	if err := query.ValidateAuth(); err != nil {
+		logger.Fatalf("Invalid authentication settings: %v", err)
+	}
 	db.MustInit()
+	if err := events.Start(); err != nil {
+		logger.Fatalf(err.Error())
+	}
 	e := echo.New()
 	// PostgREST needs to know how it is exposed to create the correct links
 	db.HTTPEndpoint = publicEndpoint + "/db"
@@ -34,42 +49,103 @@ func serve(configFiles []string) {
 	}
 	if !disablePostgrest {
 		go db.StartPostgrest()
-		forward(e, "/db", db.PostgRESTEndpoint())
+		forward(e, "/db", db.PostgRESTEndpoint(), query.RequireMethodRole(), query.RequireGlobal, withoutAuthorization)
 		forward(e, "/live", db.PostgRESTAdminEndpoint())
-		forward(e, "/ready", db.PostgRESTAdminEndpoint())
 	} else {
 		e.GET("/live", func(c echo.Context) error {
 			return c.String(200, "OK")
 		})
+	}
+	// probes, metrics and the OpenAPI document are served without authentication, the other routes require a role once it is enabled
+	read, push, admin := query.RequireRole(query.RoleRead), query.RequireRole(query.RoleIngest), query.RequireRole(query.RoleAdmin)
+	e.GET("/ready", query.ReadyHandler)
+	e.GET("/health", query.HealthHandler)
+	e.GET("/diagnostics", query.DiagnosticsHandler, admin, query.RequireGlobal)
+	e.POST("/config", query.IngestHandler, middleware.BodyLimit(ingestBodyLimit), push)
+	e.POST("/upstream/push", query.UpstreamPushHandler, middleware.BodyLimit(ingestBodyLimit), push)
+	e.GET("/query", query.Handler, read)
+	e.GET("/scrape_runs", query.ScrapeRunsHandler, read)
+	e.GET("/scrape_tasks", query.ScrapeTasksHandler, read, query.RequireGlobal)
+	e.GET("/audit", query.AuditHandler, read)
+	e.GET("/graphql", query.GraphQLHandler, read)
+	e.POST("/graphql", query.GraphQLHandler, read)
+	e.GET("/config/:id", query.ConfigHandler, read)
+	e.GET("/config/:id/graph", query.GraphHandler, read)
+	e.GET("/config/:id/changes", query.ChangesHandler, read)
+	e.GET("/changes/stats", query.ChangeStatsHandler, read)
+	e.GET("/changes/stream", query.ChangesStreamHandler, read)
+	e.GET("/snapshot", query.SnapshotHandler, read)
+	e.POST("/drift", query.DriftHandler, read)
+	e.GET("/views", query.ListViewsHandler, read)
+	e.PUT("/views/:name", query.SaveViewHandler, admin, query.RequireGlobal)
+	e.DELETE("/views/:name", query.DeleteViewHandler, admin, query.RequireGlobal)
+	e.GET("/view/:name", query.ViewHandler, read)
+	e.GET("/webhooks", query.ListWebhooksHandler, admin, query.RequireGlobal)
+	e.PUT("/webhooks/:name", query.SaveWebhookHandler, admin, query.RequireGlobal)
+	e.DELETE("/webhooks/:name", query.DeleteWebhookHandler, admin, query.RequireGlobal)
+	e.GET("/report", query.ReportHandler, read, query.RequireGlobal)
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	e.GET("/openapi.json", query.OpenAPIHandler)
 
-		e.GET("/ready", func(c echo.Context) error {
-			return c.String(200, "OK")
-		})
+	if grpcPort > 0 {
+		go func() {
+			if err := ingest.Serve(grpcPort); err != nil {
+				logger.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
 	}
-	e.GET("/query", query.Handler)
 
 	// Run this in a goroutine to make it non-blocking for server start
 	go startScraperCron(configFiles)
+	scrapers.StartRetention()
+	scrapers.StartReports()
+	scrapers.StartCosts()
+	scrapers.StartUpstream()
+	scrapers.StartQueue()
+	notifications.Webhooks = db.GetWebhooks
+	query.HealthChecks = scrapers.DiagnoseScheduled
 
-	if err := e.Start(fmt.Sprintf(":%d", httpPort)); err != nil {
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+		<-quit
+		logger.Infof("Shutting down, waiting up to %s for running scrapes to save their results", shutdownTimeout)
+		scrapers.Shutdown(shutdownTimeout)
+		events.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := e.Shutdown(ctx); err != nil {
+			logger.Errorf("Failed to shutdown the server: %v", err)
+		}
+	}()
+
+	if err := e.Start(fmt.Sprintf(":%d", httpPort)); err != nil && err != http.ErrServerClosed {
 		e.Logger.Fatal(err)
 	}
 }
 
+const ingestBodyLimit = "50M"
+
 func startScraperCron(configFiles []string) {
-	scraperConfigsFiles, err := v1.ParseConfigs(configFiles...)
+	files, err := scrapers.ConfigFiles(configFiles)
 	if err != nil {
 		logger.Fatalf(err.Error())
 	}
-	for _, scraper := range scraperConfigsFiles {
-		_scraper := scraper
-		scrapers.AddToCron(_scraper, "")
-		fn := func() {
-			if err := scrapers.RunScraper(_scraper); err != nil {
-				logger.Errorf("Error running scraper: %v", err)
-			}
+	for _, file := range files {
+		scraperConfigsFiles, err := scrapers.LoadConfigFile(file)
+		if err != nil {
+			logger.Fatalf(err.Error())
+		}
+		for _, scraper := range scraperConfigsFiles {
+			// the first runs hold the lock of the scraper, so that replicas starting together scrape once
+			defer scrapers.RunExclusive(scraper, scrapers.FileScraperID(scraper))
+		}
+	}
+	if len(configFiles) > 0 {
+		// scrapers are rescheduled when their files change, without restarting
+		if err := scrapers.WatchConfigFiles(configFiles); err != nil {
+			logger.Errorf("Failed to watch config files: %v", err)
 		}
-		defer fn()
 	}
 
 	scraperConfigsDB, err := db.GetScrapeConfigs()
@@ -81,22 +157,18 @@ func startScraperCron(configFiles []string) {
 		if err != nil {
 			logger.Fatalf("Error parsing config scraper: %v", err)
 		}
+		_scraper.Name = scraper.ID.String()
 		scrapers.AddToCron(_scraper, scraper.ID.String())
-		fn := func() {
-			if err := scrapers.RunScraper(_scraper); err != nil {
-				logger.Errorf("Error running scraper: %v", err)
-			}
-		}
-		defer fn()
+		defer scrapers.RunExclusive(_scraper, scraper.ID.String())
 	}
 }
 
-func forward(e *echo.Echo, prefix string, target string) {
+func forward(e *echo.Echo, prefix string, target string, m ...echo.MiddlewareFunc) {
 	targetURL, err := url.Parse(target)
 	if err != nil {
 		e.Logger.Fatal(err)
 	}
-	e.Group(prefix).Use(middleware.ProxyWithConfig(middleware.ProxyConfig{
+	e.Group(prefix, m...).Use(middleware.ProxyWithConfig(middleware.ProxyConfig{
 		Rewrite: map[string]string{
 			fmt.Sprintf("^%s/*", prefix): "/$1",
 		},
@@ -108,6 +180,15 @@ func forward(e *echo.Echo, prefix string, target string) {
 	}))
 }
 
+// withoutAuthorization removes the bearer token of an authenticated caller, PostgREST rejects tokens
+// as it is not configured with a JWT secret
+func withoutAuthorization(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Request().Header.Del(echo.HeaderAuthorization)
+		return next(c)
+	}
+}
+
 func init() {
 	ServerFlags(Serve.Flags())
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/scrapers"
+	"github.com/spf13/cobra"
+)
+
+var validateNamespace string
+
+// Validate ...
+var Validate = &cobra.Command{
+	Use:   "validate <scraper.yaml>...",
+	Short: "Validate scraper files against the schema, compile their templates and check that their secrets exist",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, files []string) {
+		ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient, Namespace: validateNamespace}
+		failed := 0
+		for _, file := range files {
+			for _, err := range scrapers.Validate(ctx, file) {
+				fmt.Println(err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("%d errors found\n", failed)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	Validate.Flags().StringVarP(&validateNamespace, "namespace", "n", "default", "Namespace of the kubernetes secrets and config maps referenced by the scrapers")
+}
@@ -0,0 +1,9 @@
+// Package schemas embeds the JSON schemas generated by make manifests
+package schemas
+
+import _ "embed"
+
+// Scraper is the schema of scraper files, the spec of the scrape config CRD
+//
+//go:embed scraper.schema.json
+var Scraper []byte
@@ -18,14 +18,21 @@ package controllers
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	configsv1 "github.com/flanksource/config-db/api/v1"
 	v1 "github.com/flanksource/config-db/api/v1"
@@ -76,6 +83,7 @@ func (r *ScrapeConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
 		}
 		scrapers.RemoveFromCron(string(scrapeConfig.GetUID()))
+		scrapeConfigs.Delete(string(scrapeConfig.GetUID()))
 		controllerutil.RemoveFinalizer(scrapeConfig, ScrapeConfigFinalizerName)
 		return ctrl.Result{}, r.Update(ctx, scrapeConfig)
 	}
@@ -95,9 +103,15 @@ func (r *ScrapeConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
+	scrapeConfigs.Store(string(scrapeConfig.GetUID()), req.NamespacedName)
+	scrapeConfig.Spec.ConfigScraper.Name = req.NamespacedName.String()
+	scrapeConfig.Spec.ConfigScraper.ID = string(scrapeConfig.GetUID())
+
 	// Sync jobs if new scrape config is created
 	if changed || scrapeConfig.Generation == 1 {
-		if err := scrapers.RunScraper(scrapeConfig.Spec.ConfigScraper); err != nil {
+		summary, err := scrapers.RunScraperWithSummary(scrapeConfig.Spec.ConfigScraper, nil)
+		r.updateStatus(ctx, req.NamespacedName, summary, err)
+		if err != nil {
 			logger.Error(err, "failed to run scraper")
 			return ctrl.Result{Requeue: true, RequeueAfter: 2 * time.Minute}, err
 		}
@@ -107,9 +121,58 @@ func (r *ScrapeConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	return ctrl.Result{}, nil
 }
 
+// scrapeConfigs maps the uid of the scrape configs to their name, so that the status can be updated after scheduled runs
+var scrapeConfigs = sync.Map{}
+
+// updateStatus records the outcome of a scrape in the status of the scrape config
+func (r *ScrapeConfigReconciler) updateStatus(ctx context.Context, name types.NamespacedName, summary scrapers.ScrapeSummary, scrapeErr error) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scrapeConfig := &v1.ScrapeConfig{}
+		if err := r.Get(ctx, name, scrapeConfig); err != nil {
+			return err
+		}
+
+		now := metav1.Now()
+		if !summary.Time.IsZero() {
+			now = metav1.NewTime(summary.Time)
+		}
+		scrapeConfig.Status.ObservedGeneration = scrapeConfig.Generation
+		scrapeConfig.Status.LastScrapeTime = &now
+		scrapeConfig.Status.ItemsScraped = summary.Items
+		scrapeConfig.Status.Errors = summary.Errors
+
+		condition := metav1.Condition{
+			Type:               "Scraped",
+			Status:             metav1.ConditionTrue,
+			Reason:             "ScrapeSucceeded",
+			ObservedGeneration: scrapeConfig.Generation,
+		}
+		switch {
+		case scrapeErr != nil:
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "ScrapeFailed"
+			condition.Message = scrapeErr.Error()
+		case summary.Errors > 0:
+			condition.Reason = "ScrapeErrors"
+			condition.Message = summary.LastError
+		}
+		meta.SetStatusCondition(&scrapeConfig.Status.Conditions, condition)
+		return r.Status().Update(ctx, scrapeConfig)
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		r.Log.Error(err, "failed to update scrape config status", "scrape_config", name)
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ScrapeConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	scrapers.OnScrapeComplete = func(id string, summary scrapers.ScrapeSummary, err error) {
+		if name, ok := scrapeConfigs.Load(id); ok {
+			r.updateStatus(context.Background(), name.(types.NamespacedName), summary, err)
+		}
+	}
+	// status updates do not change the generation and must not trigger another scrape
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&configsv1.ScrapeConfig{}).
+		For(&configsv1.ScrapeConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -17,18 +17,35 @@ func GetAnalysis(analysis models.Analysis) (*models.Analysis, error) {
 	return &existing, err
 }
 
-func CreateAnalysis(analysis models.Analysis) error {
+// CreateAnalysis creates or updates the analysis of the analyzer on the config item,
+// it returns true when the analysis is new or its status changed
+func CreateAnalysis(analysis models.Analysis) (bool, error) {
 	// get analysis by config_id, and summary
 	existingAnalysis, err := GetAnalysis(analysis)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if existingAnalysis != nil {
 		analysis.ID = existingAnalysis.ID
-		return db.Model(&analysis).Updates(map[string]interface{}{
+		return existingAnalysis.Status != analysis.Status, db.Model(&analysis).Updates(map[string]interface{}{
 			"last_observed": gorm.Expr("now()"),
 			"message":       analysis.Message,
 			"status":        analysis.Status}).Error
 	}
-	return db.Create(&analysis).Error
+	// a resolved analysis only closes a previous one
+	if analysis.Status == "resolved" {
+		return false, nil
+	}
+	return true, db.Create(&analysis).Error
+}
+
+// GetConfigAnalyses returns the latest analyses of the config item, only those with the status when it is set
+func GetConfigAnalyses(configID, status string, limit int) ([]models.Analysis, error) {
+	var analyses []models.Analysis
+	tx := db.Where("config_id = ?", configID)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	err := tx.Order("last_observed DESC").Limit(limit).Find(&analyses).Error
+	return analyses, err
 }
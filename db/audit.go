@@ -0,0 +1,113 @@
+package db
+
+import (
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
+	"gorm.io/gorm"
+)
+
+// ActorRetention is the actor of the changes made by the retention policies
+const ActorRetention = "retention"
+
+// AuditFilter of the audit log, empty fields match everything
+type AuditFilter struct {
+	ConfigID string
+	Actor    string
+	Scraper  string
+	RunID    string
+	Action   string
+	Tenant   string
+	Since    time.Time
+}
+
+// auditActor returns the API caller, or the scraper of the context
+func auditActor(ctx *v1.ScrapeContext) (actor, scraper string) {
+	if ctx == nil {
+		return "", ""
+	}
+	if ctx.Scraper != nil {
+		scraper = ctx.Scraper.Name
+	}
+	if ctx.Actor != "" {
+		return ctx.Actor, scraper
+	}
+	if scraper != "" {
+		return "scraper:" + scraper, scraper
+	}
+	return "", scraper
+}
+
+// newAuditLog returns the audit log entry of an action of the context on the config item
+func newAuditLog(ctx *v1.ScrapeContext, action string, ci models.ConfigItem, summary string) models.AuditLog {
+	actor, scraper := auditActor(ctx)
+	entry := models.AuditLog{
+		Action:     action,
+		ConfigID:   &ci.ID,
+		ExternalID: ci.ExternalID,
+		Actor:      actor,
+		Scraper:    scraper,
+		Source:     stringValue(ci.Source),
+		Summary:    summary,
+		Tenant:     ci.Tenant,
+	}
+	entry.ExternalType = stringValue(ci.ExternalType)
+	if ctx != nil {
+		entry.RunID = ctx.RunID
+		if entry.Tenant == "" {
+			entry.Tenant = ctx.Tenant
+		}
+	}
+	return entry
+}
+
+// saveAuditLog inserts the entries with the transaction
+func saveAuditLog(tx *gorm.DB, entries ...models.AuditLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i := range entries {
+		entries[i].ID = ulid.MustNew().AsUUID()
+	}
+	return tx.CreateInBatches(&entries, BatchSize).Error
+}
+
+// GetAuditLog returns a page of the latest entries of the audit log matching the filter, and the cursor of the next page
+func GetAuditLog(filter AuditFilter, page Page) ([]models.AuditLog, Cursor, error) {
+	var entries []models.AuditLog
+	tx := db.Model(&models.AuditLog{})
+	if filter.ConfigID != "" {
+		tx = tx.Where("config_id = ?", filter.ConfigID)
+	}
+	if filter.Actor != "" {
+		tx = tx.Where("actor = ?", filter.Actor)
+	}
+	if filter.Scraper != "" {
+		tx = tx.Where("scraper = ?", filter.Scraper)
+	}
+	if filter.RunID != "" {
+		tx = tx.Where("run_id = ?", filter.RunID)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if filter.Tenant != "" {
+		tx = tx.Where("tenant = ?", filter.Tenant)
+	}
+	if !filter.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.Since)
+	}
+	tx, err := page.paginate(tx, true, "created_at", "id")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+	return entries, page.next(len(entries), func() Cursor {
+		last := entries[len(entries)-1]
+		return Cursor{last.CreatedAt, last.ID}
+	}), nil
+}
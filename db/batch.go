@@ -0,0 +1,245 @@
+package db
+
+import (
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
+	"github.com/flanksource/config-db/events"
+	"github.com/flanksource/config-db/metrics"
+	"github.com/flanksource/config-db/notifications"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BatchSize is the number of config items upserted in a transaction
+var BatchSize = 500
+
+// upsertColumns are updated when a scraped config item already exists, the parent and path are only
+// updated once they are known, as parents scraped later in the same run are not found
+var upsertColumns = append(clause.AssignmentColumns([]string{
+	"config_type", "external_id", "external_type", "account", "region", "zone", "network", "subnet",
+	"name", "namespace", "description", "scraper_id", "source", "tags", "config", "updated_at",
+}),
+	clause.Assignment{Column: clause.Column{Name: "parent_id"}, Value: gorm.Expr("COALESCE(EXCLUDED.parent_id, config_items.parent_id)")},
+	clause.Assignment{Column: clause.Column{Name: "path"}, Value: gorm.Expr("COALESCE(NULLIF(EXCLUDED.path, ''), config_items.path)")},
+)
+
+// saveConfigItems upserts the config items of the results and records the changes from the existing items, the
+// parents of the items that are in the same results are set once the items are saved
+func saveConfigItems(ctx *v1.ScrapeContext, results []v1.ScrapeResult) error {
+	var items []models.ConfigItem
+	var exclusions [][]string
+	var parents []string
+	for _, result := range dedupeResults(results) {
+		ci, err := NewConfigItemFromResult(result, ctx.Tenant)
+		if err != nil {
+			return errors.Wrapf(err, "unable to create config item: %s", result)
+		}
+		ci.ScraperID = scraperID(ctx)
+		items = append(items, *ci)
+		exclusions = append(exclusions, result.BaseScraper.Transform.ChangeExclusions)
+		parent := ""
+		if result.ParentExternalID != "" && result.ParentExternalType != "" {
+			parent = result.ParentExternalType + "/" + result.ParentExternalID
+		}
+		parents = append(parents, parent)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	defer metrics.Since(metrics.DBWriteDuration.WithLabelValues("upsert_config_items"), time.Now())
+
+	existing, err := findExistingConfigItems(items, ctx.Tenant)
+	if err != nil {
+		return errors.Wrap(err, "unable to lookup existing configs")
+	}
+
+	var created, updated []models.ConfigItem
+	var changes []models.ConfigChange
+	var audit []models.AuditLog
+	changed := make(map[string]*models.ConfigChange)
+	ids := make(map[string]string)
+	for i, ci := range items {
+		current, ok := existing[*ci.ExternalType+"/"+ci.ID]
+		if ok {
+			ci.ID = current.ID
+		} else {
+			ci.ID = ulid.MustNew().AsUUID()
+		}
+		items[i].ID = ci.ID
+		for _, id := range ci.ExternalID {
+			ids[*ci.ExternalType+"/"+id] = ci.ID
+		}
+		if !ok {
+			created = append(created, ci)
+			audit = append(audit, newAuditLog(ctx, models.AuditCreated, ci, ""))
+			continue
+		}
+		updated = append(updated, ci)
+		change, err := generateDiff(ci, *current, exclusions[i])
+		if err != nil {
+			logger.Errorf("[%s] failed to check for changes: %v", ci, err)
+		} else if change != nil {
+			logger.Infof("[%s/%s] detected changes", ci.ConfigType, ci.ExternalID[0])
+			changes = append(changes, *change)
+			changed[ci.ID] = change
+			audit = append(audit, newAuditLog(ctx, models.AuditUpdated, ci, change.Summary))
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(created) > 0 {
+			if err := tx.CreateInBatches(&created, BatchSize).Error; err != nil {
+				return errors.Wrap(err, "failed to create config items")
+			}
+		}
+		if len(updated) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: upsertColumns,
+			}).CreateInBatches(&updated, BatchSize).Error; err != nil {
+				return errors.Wrap(err, "failed to update config items")
+			}
+		}
+		for _, ci := range resolveParents(items, parents, ids) {
+			if err := tx.Model(&models.ConfigItem{}).Where("id = ?", ci.ID).
+				Updates(map[string]interface{}{"parent_id": ci.ParentID, "path": ci.Path}).Error; err != nil {
+				return errors.Wrap(err, "failed to update the parents of config items")
+			}
+		}
+		if len(changes) > 0 {
+			if err := tx.CreateInBatches(&changes, BatchSize).Error; err != nil {
+				return errors.Wrap(err, "failed to save config changes")
+			}
+		}
+		if err := saveAuditLog(tx, audit...); err != nil {
+			return errors.Wrap(err, "failed to save the audit log")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	recordChanges(changes...)
+
+	for _, ci := range created {
+		metrics.ConfigItems.WithLabelValues(ci.ConfigType, "created").Inc()
+		events.Publish(configEvent(events.ConfigCreated, ci, nil))
+	}
+	for _, ci := range updated {
+		metrics.ConfigItems.WithLabelValues(ci.ConfigType, "updated").Inc()
+		change := changed[ci.ID]
+		events.Publish(configEvent(events.ConfigUpdated, ci, change))
+		if change == nil {
+			continue
+		}
+		metrics.ConfigChanges.WithLabelValues(ci.ConfigType).Inc()
+		notifications.Notify(notifications.Event{
+			Event:        notifications.EventChange,
+			ConfigID:     ci.ID,
+			ConfigType:   ci.ConfigType,
+			ExternalType: *ci.ExternalType,
+			Name:         ci.GetName(),
+			ChangeType:   change.ChangeType,
+			Summary:      change.Summary,
+		})
+	}
+	return nil
+}
+
+// dedupeResults returns the results with a config, a config item scraped more than once is saved from its last
+// result at the position of its first one
+func dedupeResults(results []v1.ScrapeResult) []v1.ScrapeResult {
+	var deduped []v1.ScrapeResult
+	index := make(map[string]int)
+	for _, result := range results {
+		if result.Config == nil {
+			continue
+		}
+		key := result.ExternalType + "/" + result.ID
+		if i, ok := index[key]; ok {
+			deduped[i] = result
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, result)
+	}
+	return deduped
+}
+
+// resolveParents returns the items whose parent was not found when they were created as it is saved with them, with
+// the parent and path set from the ids of the saved items by external type and external id
+func resolveParents(items []models.ConfigItem, parents []string, ids map[string]string) []models.ConfigItem {
+	byID := make(map[string]*models.ConfigItem)
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	var resolved []models.ConfigItem
+	for i := range items {
+		parentID, ok := ids[parents[i]]
+		if items[i].ParentID != nil || !ok {
+			continue
+		}
+		items[i].ParentID = &parentID
+		items[i].Path = parentID
+		// the path of an item is its parent id followed by the ids of the ancestors of the parent
+		for parent, depth := byID[parentID], 0; parent != nil && depth < len(items); depth++ {
+			if parent.ParentID == nil {
+				break
+			}
+			if parent.Path != "" {
+				items[i].Path += "." + parent.Path
+				break
+			}
+			items[i].Path += "." + *parent.ParentID
+			parent = byID[*parent.ParentID]
+		}
+		resolved = append(resolved, items[i])
+	}
+	return resolved
+}
+
+// findExistingConfigItems returns the existing config items of the tenant matching any of the items, keyed by external type and external id
+func findExistingConfigItems(items []models.ConfigItem, tenant string) (map[string]*models.ConfigItem, error) {
+	var ids pq.StringArray
+	for _, ci := range items {
+		ids = append(ids, ci.ID)
+	}
+	var rows []models.ConfigItem
+	if err := db.Where("external_id && ? AND tenant = ?", ids, tenant).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*models.ConfigItem)
+	for i := range rows {
+		for _, id := range rows[i].ExternalID {
+			existing[stringValue(rows[i].ExternalType)+"/"+id] = &rows[i]
+		}
+	}
+	return existing, nil
+}
+
+// scraperID returns the id of the scrape config of the scrape, scrapers loaded from files have none
+func scraperID(ctx *v1.ScrapeContext) *string {
+	if ctx.Scraper == nil {
+		return nil
+	}
+	if _, err := uuid.Parse(ctx.Scraper.ID); err != nil {
+		return nil
+	}
+	id := ctx.Scraper.ID
+	return &id
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/google/uuid"
+)
+
+func TestDedupeResults(t *testing.T) {
+	results := []v1.ScrapeResult{
+		{ExternalType: "Kubernetes::Pod", ID: "a", Name: "first", Config: "{}"},
+		{ExternalType: "Kubernetes::Pod", ID: "b", Config: "{}"},
+		{ExternalType: "Kubernetes::Pod", ID: "c"},
+		{ExternalType: "Kubernetes::Pod", ID: "a", Name: "last", Config: "{}"},
+		{ExternalType: "Kubernetes::Node", ID: "a", Config: "{}"},
+	}
+	deduped := dedupeResults(results)
+	if len(deduped) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(deduped))
+	}
+	if deduped[0].ID != "a" || deduped[0].Name != "last" {
+		t.Errorf("expected the last result of a at its first position, got %s %s", deduped[0].ID, deduped[0].Name)
+	}
+	if deduped[2].ExternalType != "Kubernetes::Node" {
+		t.Errorf("expected the node a to be kept, got %s", deduped[2].ExternalType)
+	}
+}
+
+func TestResolveParents(t *testing.T) {
+	root := "root"
+	items := []models.ConfigItem{
+		{ID: "pod"},
+		{ID: "deployment"},
+		{ID: "namespace", ParentID: &root, Path: root},
+	}
+	parents := []string{"Kubernetes::Deployment/deployment", "Kubernetes::Namespace/namespace", ""}
+	ids := map[string]string{
+		"Kubernetes::Deployment/deployment": "deployment",
+		"Kubernetes::Namespace/namespace":   "namespace",
+	}
+	resolved := resolveParents(items, parents, ids)
+	if len(resolved) != 2 {
+		t.Fatalf("expected 2 resolved items, got %d", len(resolved))
+	}
+	if *items[0].ParentID != "deployment" || items[0].Path != "deployment" {
+		t.Errorf("pod: unexpected parent %s and path %s", *items[0].ParentID, items[0].Path)
+	}
+	if *items[1].ParentID != "namespace" || items[1].Path != "namespace.root" {
+		t.Errorf("deployment: unexpected parent %s and path %s", *items[1].ParentID, items[1].Path)
+	}
+}
+
+func TestSaveConfigItems(t *testing.T) {
+	setupTestDB(t)
+	suffix := uuid.New().String()
+	results := []v1.ScrapeResult{
+		{Type: "Pod", ExternalType: "Kubernetes::Pod", ID: "pod-" + suffix, Config: map[string]string{"version": "1"},
+			ParentExternalType: "Kubernetes::Namespace", ParentExternalID: "ns-" + suffix},
+		{Type: "Namespace", ExternalType: "Kubernetes::Namespace", ID: "ns-" + suffix, Config: map[string]string{}},
+		{Type: "Pod", ExternalType: "Kubernetes::Pod", ID: "pod-" + suffix, Config: map[string]string{"version": "2"},
+			ParentExternalType: "Kubernetes::Namespace", ParentExternalID: "ns-" + suffix},
+	}
+	if err := saveConfigItems(&v1.ScrapeContext{Context: context.Background()}, results); err != nil {
+		t.Fatalf("failed to save config items: %v", err)
+	}
+
+	var pods []models.ConfigItem
+	if err := db.Where("? = ANY(external_id)", "pod-"+suffix).Find(&pods).Error; err != nil {
+		t.Fatalf("failed to get the pod: %v", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod, got %d", len(pods))
+	}
+	namespaceID, err := FindConfigItemID(v1.ExternalID{ExternalType: "Kubernetes::Namespace", ExternalID: []string{"ns-" + suffix}}, "")
+	if err != nil || namespaceID == nil {
+		t.Fatalf("failed to find the namespace: %v", err)
+	}
+	if pods[0].ParentID == nil || *pods[0].ParentID != *namespaceID {
+		t.Errorf("expected the parent of the pod to be the namespace %s, got %v", *namespaceID, pods[0].ParentID)
+	}
+	if pods[0].Config == nil || *pods[0].Config != `{
+  "version": "2"
+}` {
+		t.Errorf("expected the config of the last result, got %v", stringValue(pods[0].Config))
+	}
+}
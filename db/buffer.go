@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/metrics"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// BufferPath of the file results are buffered in while the database is unavailable, buffering is disabled when empty
+	BufferPath string
+	// BufferMaxSizeMB caps the size of the buffered results, results are dropped once it is reached
+	BufferMaxSizeMB int
+)
+
+const bufferFlushInterval = 30 * time.Second
+
+// bufferMaxAttempts is the number of times a buffered result is saved while the database is available before it
+// is dropped
+const bufferMaxAttempts = 5
+
+var (
+	bufferBucket = []byte("results")
+	buffer       *bolt.DB
+	bufferLock   sync.Mutex
+	bufferSize   int
+)
+
+// bufferedResult keeps the fields of a result that are not serialized to JSON
+type bufferedResult struct {
+	Result             v1.ScrapeResult        `json:"result"`
+	Changes            []v1.ChangeResult      `json:"changes,omitempty"`
+	Relationships      v1.RelationshipResults `json:"relationships,omitempty"`
+	ChangeExclusions   []string               `json:"change_exclusions,omitempty"`
+	ParentExternalID   string                 `json:"parent_external_id,omitempty"`
+	ParentExternalType string                 `json:"parent_external_type,omitempty"`
+	Attempts           int                    `json:"attempts,omitempty"`
+	// the context the result was saved with
+	Tenant  string `json:"tenant,omitempty"`
+	Actor   string `json:"actor,omitempty"`
+	Scraper string `json:"scraper,omitempty"`
+	RunID   string `json:"run_id,omitempty"`
+}
+
+func openBuffer() error {
+	var err error
+	buffer, err = bolt.Open(BufferPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open buffer %s: %v", BufferPath, err)
+	}
+	count := 0
+	err = buffer.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bufferBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			bufferSize += len(v)
+			count++
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	metrics.BufferedResults.Set(float64(count))
+	if count > 0 {
+		logger.Infof("%d results are buffered in %s", count, BufferPath)
+	}
+	go func() {
+		for range time.Tick(bufferFlushInterval) {
+			if err := flushBuffer(&v1.ScrapeContext{Context: context.Background()}); err != nil {
+				logger.Debugf("failed to flush buffered results: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// bufferResults stores results to be saved with the tenant and actor of the context once the database is available again
+func bufferResults(ctx *v1.ScrapeContext, results []v1.ScrapeResult) error {
+	bufferLock.Lock()
+	defer bufferLock.Unlock()
+	buffered, dropped := 0, 0
+	err := buffer.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bufferBucket)
+		for _, result := range results {
+			value, err := encodeResult(ctx, result)
+			if err != nil {
+				return err
+			}
+			if bufferSize+len(value) > BufferMaxSizeMB*1024*1024 {
+				dropped++
+				continue
+			}
+			seq, _ := b.NextSequence()
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, seq)
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
+			bufferSize += len(value)
+			buffered++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	metrics.BufferedResults.Add(float64(buffered))
+	if dropped > 0 {
+		metrics.BufferDropped.Add(float64(dropped))
+		logger.Errorf("buffer %s is full, dropped %d results", BufferPath, dropped)
+	}
+	return nil
+}
+
+// flushBuffer saves the buffered results in the order they were buffered, it stops at the first error, a result
+// that fails to save while the database is available is dropped after bufferMaxAttempts
+func flushBuffer(ctx *v1.ScrapeContext) error {
+	bufferLock.Lock()
+	defer bufferLock.Unlock()
+	if bufferSize == 0 {
+		return nil
+	}
+	if err := Ping(); err != nil {
+		return err
+	}
+	// every result is deleted in its own transaction once saved, so that a failure does not save it twice
+	flushed := 0
+	var err error
+	for {
+		var key, value []byte
+		if err = buffer.View(func(tx *bolt.Tx) error {
+			k, v := tx.Bucket(bufferBucket).Cursor().First()
+			key, value = append([]byte{}, k...), append([]byte{}, v...)
+			return nil
+		}); err != nil || len(key) == 0 {
+			break
+		}
+		resultCtx, result, attempts, decodeErr := decodeResult(ctx, value)
+		if decodeErr != nil {
+			logger.Errorf("dropping buffered result that cannot be decoded: %v", decodeErr)
+		} else if _, err = saveResults(resultCtx, []v1.ScrapeResult{result}); err != nil {
+			if Ping() != nil {
+				break
+			}
+			if attempts+1 < bufferMaxAttempts {
+				updated := setAttempts(value, attempts+1)
+				if updateErr := buffer.Update(func(tx *bolt.Tx) error {
+					return tx.Bucket(bufferBucket).Put(key, updated)
+				}); updateErr != nil {
+					logger.Errorf("failed to update buffered result %s: %v", result, updateErr)
+				} else {
+					bufferSize += len(updated) - len(value)
+				}
+				break
+			}
+			logger.Errorf("dropping buffered result %s after %d attempts: %v", result, attempts+1, err)
+			metrics.BufferDropped.Inc()
+			err = nil
+		}
+		if err = buffer.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bufferBucket).Delete(key)
+		}); err != nil {
+			break
+		}
+		bufferSize -= len(value)
+		flushed++
+	}
+	metrics.BufferedResults.Sub(float64(flushed))
+	if flushed > 0 {
+		logger.Infof("Saved %d buffered results", flushed)
+	}
+	return err
+}
+
+func encodeResult(ctx *v1.ScrapeContext, result v1.ScrapeResult) ([]byte, error) {
+	if result.AnalysisResult != nil {
+		analysis := *result.AnalysisResult
+		analysis.Error = nil
+		result.AnalysisResult = &analysis
+	}
+	buffered := bufferedResult{
+		Result:             result,
+		Changes:            result.Changes,
+		Relationships:      result.RelationshipResults,
+		ChangeExclusions:   result.BaseScraper.Transform.ChangeExclusions,
+		ParentExternalID:   result.ParentExternalID,
+		ParentExternalType: result.ParentExternalType,
+		Tenant:             ctx.Tenant,
+		Actor:              ctx.Actor,
+		RunID:              ctx.RunID,
+	}
+	if ctx.Scraper != nil {
+		buffered.Scraper = ctx.Scraper.Name
+	}
+	return json.Marshal(buffered)
+}
+
+// setAttempts returns the buffered result with the number of attempts to save it
+func setAttempts(data []byte, attempts int) []byte {
+	var buffered map[string]json.RawMessage
+	if err := json.Unmarshal(data, &buffered); err != nil {
+		return data
+	}
+	buffered["attempts"], _ = json.Marshal(attempts)
+	updated, err := json.Marshal(buffered)
+	if err != nil {
+		return data
+	}
+	return updated
+}
+
+// decodeResult returns the result, a copy of the context with the tenant and actor it was buffered with and the
+// number of attempts to save it
+func decodeResult(ctx *v1.ScrapeContext, data []byte) (*v1.ScrapeContext, v1.ScrapeResult, int, error) {
+	var buffered bufferedResult
+	if err := json.Unmarshal(data, &buffered); err != nil {
+		return nil, v1.ScrapeResult{}, 0, err
+	}
+	resultCtx := *ctx
+	resultCtx.Tenant, resultCtx.Actor, resultCtx.RunID = buffered.Tenant, buffered.Actor, buffered.RunID
+	if buffered.Scraper != "" {
+		resultCtx.Scraper = &v1.ConfigScraper{Name: buffered.Scraper}
+	}
+	result := buffered.Result
+	result.Changes = buffered.Changes
+	result.RelationshipResults = buffered.Relationships
+	result.BaseScraper.Transform.ChangeExclusions = buffered.ChangeExclusions
+	result.ParentExternalID, result.ParentExternalType = buffered.ParentExternalID, buffered.ParentExternalType
+	return &resultCtx, result, buffered.Attempts, nil
+}
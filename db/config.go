@@ -11,13 +11,14 @@ import (
 	"github.com/lib/pq"
 	"github.com/ohler55/ojg/oj"
 	"github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-// GetConfigItem returns a single config item result
-func GetConfigItem(extType, extID string) (*models.ConfigItem, error) {
+// GetConfigItem returns a single config item of the tenant
+func GetConfigItem(extType, extID, tenant string) (*models.ConfigItem, error) {
 	ci := models.ConfigItem{}
-	tx := db.Limit(1).Find(&ci, "external_type = ? and external_id  @> ?", extType, pq.StringArray{extID})
+	tx := db.Limit(1).Find(&ci, "external_type = ? and external_id  @> ? and tenant = ?", extType, pq.StringArray{extID}, tenant)
 	if tx.RowsAffected == 0 {
 		return nil, nil
 	}
@@ -35,14 +36,14 @@ func GetConfigItemFromID(id string) (*models.ConfigItem, error) {
 	return &ci, err
 }
 
-// FindConfigItemID returns the uuid of config_item which matches the externalUID
-func FindConfigItemID(externalID v1.ExternalID) (*string, error) {
-	if ciID, exists := cacheStore.Get(externalID.CacheKey()); exists {
+// FindConfigItemID returns the uuid of the config_item of the tenant which matches the externalUID
+func FindConfigItemID(externalID v1.ExternalID, tenant string) (*string, error) {
+	if ciID, exists := cacheStore.Get(externalID.CacheKey(tenant)); exists {
 		return ciID.(*string), nil
 	}
 
 	var ci models.ConfigItem
-	queryDB := externalID.WhereClause(db)
+	queryDB := externalID.WhereClause(db, tenant)
 	tx := queryDB.Select("id").Limit(1).Find(&ci)
 	if tx.RowsAffected == 0 {
 		return nil, nil
@@ -51,10 +52,23 @@ func FindConfigItemID(externalID v1.ExternalID) (*string, error) {
 		return nil, tx.Error
 	}
 
-	cacheStore.Set(externalID.CacheKey(), &ci.ID, cache.DefaultExpiration)
+	cacheStore.Set(externalID.CacheKey(tenant), &ci.ID, cache.DefaultExpiration)
 	return &ci.ID, nil
 }
 
+// GetCostTotal30d returns the cost over the last 30 days of the config item of the tenant matching the external id,
+// 0 when the item is not found or there is no database
+func GetCostTotal30d(externalID v1.ExternalID, tenant string) float64 {
+	if db == nil {
+		return 0
+	}
+	var ci models.ConfigItem
+	if err := externalID.WhereClause(db, tenant).Select("cost_total_30d").Limit(1).Find(&ci).Error; err != nil {
+		logger.Warnf("failed to get cost of %s: %v", externalID, err)
+	}
+	return ci.CostTotal30d
+}
+
 func FindConfigItemFromType(configType string) ([]models.ConfigItem, error) {
 	var ci []models.ConfigItem
 	err := db.Find(&ci, "external_type = @type OR config_type = @type", sql.Named("type", configType)).Error
@@ -114,8 +128,8 @@ func QueryConfigItems(request v1.QueryRequest) (*v1.QueryResult, error) {
 	return &response, nil
 }
 
-// NewConfigItemFromResult creates a new config item instance from result
-func NewConfigItemFromResult(result v1.ScrapeResult) (*models.ConfigItem, error) {
+// NewConfigItemFromResult creates a new config item instance of the tenant from result
+func NewConfigItemFromResult(result v1.ScrapeResult, tenant string) (*models.ConfigItem, error) {
 	var dataStr string
 	switch data := result.Config.(type) {
 	case string:
@@ -137,9 +151,12 @@ func NewConfigItemFromResult(result v1.ScrapeResult) (*models.ConfigItem, error)
 		Network:      &result.Network,
 		Subnet:       &result.Subnet,
 		Name:         &result.Name,
+		Namespace:    &result.Namespace,
+		Description:  &result.Description,
 		Source:       &result.Source,
 		Tags:         &result.Tags,
 		Config:       &dataStr,
+		Tenant:       tenant,
 	}
 
 	if result.CreatedAt != nil {
@@ -153,7 +170,7 @@ func NewConfigItemFromResult(result v1.ScrapeResult) (*models.ConfigItem, error)
 		}
 
 		var err error
-		ci.ParentID, err = FindConfigItemID(parentExternalID)
+		ci.ParentID, err = FindConfigItemID(parentExternalID, tenant)
 		if err != nil {
 			logger.Errorf("Error fetching parent for %v", parentExternalID)
 		}
@@ -161,7 +178,7 @@ func NewConfigItemFromResult(result v1.ScrapeResult) (*models.ConfigItem, error)
 		// Path will be correct after second iteration of scraping since
 		// the first iteration will populate the parent_ids
 		// in a non deterministic order
-		ci.Path = getParentPath(parentExternalID)
+		ci.Path = getParentPath(parentExternalID, tenant)
 	}
 
 	return ci, nil
@@ -186,3 +203,73 @@ func UpdateConfigRelatonships(relationships []models.ConfigRelationship) error {
 	}).Create(&relationships)
 	return tx.Error
 }
+
+// GetConfigChanges returns a page of the latest changes of a config item and the cursor of the next page, from ClickHouse
+// when it is configured and from postgres when it is not or when ClickHouse cannot be queried
+func GetConfigChanges(configID string, page Page) ([]models.ConfigChange, Cursor, error) {
+	var changes []models.ConfigChange
+	var err error
+	if clickHouse != nil {
+		if changes, err = getClickHouseChanges(configID, page); err != nil {
+			logger.Warnf("Failed to get the changes of %s from ClickHouse, falling back to postgres: %v", configID, err)
+		}
+	}
+	if clickHouse == nil || err != nil {
+		var tx *gorm.DB
+		if tx, err = page.paginate(db.Where("config_id = ?", configID), true, "created_at", "id"); err == nil {
+			err = tx.Find(&changes).Error
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return changes, page.next(len(changes), func() Cursor {
+		last := changes[len(changes)-1]
+		return Cursor{last.CreatedAt, last.ID}
+	}), nil
+}
+
+// FindConfigItemByName returns the config item of the tenant of the type with the name, or nil when there is none
+func FindConfigItemByName(configType, name, tenant string) (*models.ConfigItem, error) {
+	var ci models.ConfigItem
+	tx := db.Limit(1).Find(&ci, "config_type = ? AND name = ? AND tenant = ? AND deleted_at IS NULL", configType, name, tenant)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &ci, nil
+}
+
+// GetConfigItems returns the config items with the ids, only those of the tenant when it is set
+func GetConfigItems(ids []string, tenant string) ([]models.ConfigItem, error) {
+	var items []models.ConfigItem
+	if len(ids) == 0 {
+		return items, nil
+	}
+	tx := db.Where("id IN ?", ids)
+	if tenant != "" {
+		tx = tx.Where("tenant = ?", tenant)
+	}
+	err := tx.Find(&items).Error
+	return items, err
+}
+
+// GetChildConfigItems returns the config items that are not deleted whose parent is the config item
+func GetChildConfigItems(parentID, tenant string) ([]models.ConfigItem, error) {
+	var items []models.ConfigItem
+	tx := db.Where("parent_id = ? AND deleted_at IS NULL", parentID)
+	if tenant != "" {
+		tx = tx.Where("tenant = ?", tenant)
+	}
+	err := tx.Order("name").Find(&items).Error
+	return items, err
+}
+
+// GetConfigRelationships returns the relationships from and to the config item
+func GetConfigRelationships(configID string) ([]models.ConfigRelationship, error) {
+	var relationships []models.ConfigRelationship
+	err := db.Where("config_id = ? OR related_id = ?", configID, configID).Find(&relationships).Error
+	return relationships, err
+}
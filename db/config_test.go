@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/google/uuid"
+)
+
+func TestTenantScopedLookups(t *testing.T) {
+	setupTestDB(t)
+	suffix := uuid.New().String()
+	namespace := v1.ExternalID{ExternalType: "Kubernetes::Namespace", ExternalID: []string{"ns-" + suffix}}
+	ids := make(map[string]string)
+	for _, tenant := range []string{"a-" + suffix, "b-" + suffix} {
+		ctx := &v1.ScrapeContext{Context: context.Background(), Tenant: tenant}
+		result := v1.ScrapeResult{Type: "Namespace", ExternalType: namespace.ExternalType, ID: namespace.ExternalID[0], Config: map[string]string{}}
+		if err := saveConfigItems(ctx, []v1.ScrapeResult{result}); err != nil {
+			t.Fatalf("failed to save the namespace of %s: %v", tenant, err)
+		}
+		id, err := FindConfigItemID(namespace, tenant)
+		if err != nil || id == nil {
+			t.Fatalf("failed to find the namespace of %s: %v", tenant, err)
+		}
+		ids[tenant] = *id
+
+		ci, err := GetConfigItem(namespace.ExternalType, namespace.ExternalID[0], tenant)
+		if err != nil || ci == nil || ci.ID != *id || ci.Tenant != tenant {
+			t.Fatalf("expected the namespace %s of %s, got %v: %v", *id, tenant, ci, err)
+		}
+	}
+	if ids["a-"+suffix] == ids["b-"+suffix] {
+		t.Errorf("expected a namespace per tenant, got %s for both", ids["a-"+suffix])
+	}
+
+	if id, err := FindConfigItemID(namespace, "c-"+suffix); err != nil || id != nil {
+		t.Errorf("expected no namespace for another tenant, got %v: %v", id, err)
+	}
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm"
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+// CostHistoryDays is how long the daily costs of config items are kept
+var CostHistoryDays = 90
+
+// CostAnomaly is the cost of a config item today compared to its average daily cost
+type CostAnomaly struct {
+	ConfigID string  `gorm:"column:config_id"`
+	Cost     float64 `gorm:"column:cost"`
+	Average  float64 `gorm:"column:average"`
+}
+
+// RecordDailyCosts records the cost over the last day of every config item with a cost, a second recording on the
+// same day replaces the first one
+func RecordDailyCosts() (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+	tx := db.Exec(`
+        INSERT INTO config_costs (config_id, date, cost)
+        SELECT id, current_date, cost_total_1d FROM config_items WHERE cost_total_1d > 0 AND deleted_at IS NULL
+        ON CONFLICT (config_id, date) DO UPDATE SET cost = excluded.cost`)
+	return tx.RowsAffected, tx.Error
+}
+
+// PruneCosts deletes the daily costs older than CostHistoryDays
+func PruneCosts() error {
+	if db == nil || CostHistoryDays <= 0 {
+		return nil
+	}
+	return db.Where("date < current_date - ?::int", CostHistoryDays).Delete(&models.ConfigCost{}).Error
+}
+
+// ForecastCosts sets the cost forecast of the next 30 days of config items to their average daily cost over the
+// trailing days
+func ForecastCosts(days int) error {
+	if db == nil {
+		return nil
+	}
+	return db.Exec(`
+        UPDATE config_items SET cost_forecast_30d = forecast.cost
+        FROM (
+            SELECT config_id, AVG(cost) * 30 AS cost FROM config_costs
+            WHERE date > current_date - ?::int GROUP BY config_id
+        ) AS forecast
+        WHERE config_items.id = forecast.config_id`, days).Error
+}
+
+// GetCostAnomalies returns the config items whose cost today is more than factor times their average daily cost
+// over the trailing days, together with the items that still have an open anomaly so that it can be resolved
+func GetCostAnomalies(analyzer string, factor float64, days int) ([]CostAnomaly, error) {
+	if db == nil {
+		return nil, nil
+	}
+	var anomalies []CostAnomaly
+	err := db.Raw(`
+        SELECT today.config_id, today.cost, AVG(history.cost) AS average
+        FROM config_costs today
+        JOIN config_costs history ON history.config_id = today.config_id
+            AND history.date < today.date AND history.date >= today.date - ?::int
+        WHERE today.date = current_date
+        GROUP BY today.config_id, today.cost
+        HAVING today.cost > ? * AVG(history.cost)
+            OR today.config_id IN (SELECT config_id FROM config_analysis WHERE analyzer = ? AND status = 'open')`,
+		days, factor, analyzer).Scan(&anomalies).Error
+	return anomalies, err
+}
+
+// clusterPod is a pod with the costs of the instance of the node it runs on
+type clusterPod struct {
+	ID            string  `gorm:"column:id"`
+	Namespace     string  `gorm:"column:namespace"`
+	Tenant        string  `gorm:"column:tenant"`
+	ScraperID     string  `gorm:"column:scraper_id"`
+	ParentID      string  `gorm:"column:parent_id"`
+	ParentType    string  `gorm:"column:parent_type"`
+	NodeID        string  `gorm:"column:node_id"`
+	Containers    string  `gorm:"column:containers"`
+	CostPerMinute float64 `gorm:"column:cost_per_minute"`
+	CostTotal1d   float64 `gorm:"column:cost_total_1d"`
+	CostTotal7d   float64 `gorm:"column:cost_total_7d"`
+	CostTotal30d  float64 `gorm:"column:cost_total_30d"`
+}
+
+// clusterCost is the cost allocated to a namespace or workload
+type clusterCost struct {
+	CostPerMinute, CostTotal1d, CostTotal7d, CostTotal30d float64
+}
+
+func (c *clusterCost) add(pod clusterPod, share float64) {
+	c.CostPerMinute += pod.CostPerMinute * share
+	c.CostTotal1d += pod.CostTotal1d * share
+	c.CostTotal7d += pod.CostTotal7d * share
+	c.CostTotal30d += pod.CostTotal30d * share
+}
+
+// podRequests returns the CPU in millicores and the memory in bytes requested by the containers of a pod
+func podRequests(containers string) (cpu, memory int64) {
+	var specs []k8sv1.Container
+	if err := json.Unmarshal([]byte(containers), &specs); err != nil {
+		return 0, 0
+	}
+	for _, container := range specs {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		memory += container.Resources.Requests.Memory().Value()
+	}
+	return cpu, memory
+}
+
+// AllocateClusterCosts splits the cost of the instances of Kubernetes nodes across the pods running on them by their
+// share of the CPU and memory requested on the node, and sets the costs of namespaces and deployments to the sum of
+// the costs of their pods
+func AllocateClusterCosts() (int, error) {
+	if db == nil {
+		return 0, nil
+	}
+	var pods []clusterPod
+	err := db.Raw(`
+        SELECT pod.id, pod.namespace, pod.tenant, COALESCE(pod.scraper_id::text, '') AS scraper_id, pod.parent_id, parent.external_type AS parent_type,
+            node.related_id AS node_id, pod.config::jsonb->'spec'->'containers' AS containers,
+            instance.cost_per_minute, instance.cost_total_1d, instance.cost_total_7d, instance.cost_total_30d
+        FROM config_items pod
+        JOIN config_relationships node ON node.config_id = pod.id AND node.relation = 'NodePod'
+        JOIN config_relationships host ON host.related_id = node.related_id AND host.relation = 'Instance-KuberenetesNode'
+        JOIN config_items instance ON instance.id = host.config_id AND instance.tenant = pod.tenant AND instance.deleted_at IS NULL
+        LEFT JOIN config_items parent ON parent.id = pod.parent_id
+        WHERE pod.external_type = 'Kubernetes::Pod' AND pod.deleted_at IS NULL
+            AND pod.config::jsonb->'status'->>'phase' = 'Running'`).Scan(&pods).Error
+	if err != nil {
+		return 0, err
+	}
+
+	namespaces, deployments := allocateCosts(pods)
+	return len(namespaces) + len(deployments), db.Transaction(func(tx *gorm.DB) error {
+		// namespaces and deployments without running pods no longer cost anything
+		if err := tx.Exec(`
+            UPDATE config_items SET cost_per_minute = 0, cost_total_1d = 0, cost_total_7d = 0, cost_total_30d = 0
+            WHERE external_type IN ('Kubernetes::Namespace', 'Kubernetes::Deployment') AND cost_total_30d > 0`).Error; err != nil {
+			return err
+		}
+		for namespace, cost := range namespaces {
+			if err := tx.Exec(`
+                UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?
+                WHERE external_type = 'Kubernetes::Namespace' AND tenant = ? AND COALESCE(scraper_id::text, '') = ? AND name = ? AND deleted_at IS NULL`,
+				cost.CostPerMinute, cost.CostTotal1d, cost.CostTotal7d, cost.CostTotal30d, namespace[0], namespace[1], namespace[2]).Error; err != nil {
+				return err
+			}
+		}
+		for id, cost := range deployments {
+			if err := tx.Exec(`
+                UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?
+                WHERE id = ?`, cost.CostPerMinute, cost.CostTotal1d, cost.CostTotal7d, cost.CostTotal30d, id).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// allocateCosts returns the costs of the pods by the tenant, scraper id and name of their namespace, and by the id of
+// their deployment
+func allocateCosts(pods []clusterPod) (map[[3]string]*clusterCost, map[string]*clusterCost) {
+	type requests struct{ cpu, memory int64 }
+	podRequested := make(map[string]requests)
+	nodeRequested := make(map[string]requests)
+	nodePods := make(map[string]int)
+	for _, pod := range pods {
+		cpu, memory := podRequests(pod.Containers)
+		podRequested[pod.ID] = requests{cpu, memory}
+		node := nodeRequested[pod.NodeID]
+		nodeRequested[pod.NodeID] = requests{node.cpu + cpu, node.memory + memory}
+		nodePods[pod.NodeID]++
+	}
+
+	namespaces := make(map[[3]string]*clusterCost)
+	deployments := make(map[string]*clusterCost)
+	for _, pod := range pods {
+		pr, nr := podRequested[pod.ID], nodeRequested[pod.NodeID]
+		var shares []float64
+		if nr.cpu > 0 {
+			shares = append(shares, float64(pr.cpu)/float64(nr.cpu))
+		}
+		if nr.memory > 0 {
+			shares = append(shares, float64(pr.memory)/float64(nr.memory))
+		}
+		// the cost of nodes without requests is split evenly
+		share := 1 / float64(nodePods[pod.NodeID])
+		if len(shares) > 0 {
+			share = 0
+			for _, s := range shares {
+				share += s / float64(len(shares))
+			}
+		}
+
+		namespace := [3]string{pod.Tenant, pod.ScraperID, pod.Namespace}
+		if namespaces[namespace] == nil {
+			namespaces[namespace] = &clusterCost{}
+		}
+		namespaces[namespace].add(pod, share)
+		if pod.ParentType == "Kubernetes::Deployment" {
+			if deployments[pod.ParentID] == nil {
+				deployments[pod.ParentID] = &clusterCost{}
+			}
+			deployments[pod.ParentID].add(pod, share)
+		}
+	}
+
+	return namespaces, deployments
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/google/uuid"
+)
+
+func TestAllocateCosts(t *testing.T) {
+	pods := []clusterPod{
+		{ID: "a", Namespace: "default", NodeID: "node", ParentID: "deployment", ParentType: "Kubernetes::Deployment",
+			Containers: `[{"resources":{"requests":{"cpu":"300m","memory":"300Mi"}}}]`, CostTotal30d: 100},
+		{ID: "b", Namespace: "kube-system", NodeID: "node",
+			Containers: `[{"resources":{"requests":{"cpu":"100m","memory":"100Mi"}}}]`, CostTotal30d: 100},
+		{ID: "c", Namespace: "default", NodeID: "empty", CostTotal30d: 50},
+		{ID: "d", Namespace: "kube-system", NodeID: "empty", CostTotal30d: 50},
+	}
+	namespaces, deployments := allocateCosts(pods)
+
+	cases := []struct {
+		name     string
+		actual   *clusterCost
+		expected float64
+	}{
+		// 3/4 of the requests of the node, and half of the node without requests
+		{"default", namespaces[[3]string{"", "", "default"}], 75 + 25},
+		{"kube-system", namespaces[[3]string{"", "", "kube-system"}], 25 + 25},
+		{"deployment", deployments["deployment"], 75},
+	}
+	for _, c := range cases {
+		if c.actual == nil {
+			t.Errorf("%s: no cost allocated", c.name)
+			continue
+		}
+		if math.Abs(c.actual.CostTotal30d-c.expected) > 0.001 {
+			t.Errorf("%s: allocated %.2f, expected %.2f", c.name, c.actual.CostTotal30d, c.expected)
+		}
+	}
+}
+
+func TestAllocateClusterCosts(t *testing.T) {
+	setupTestDB(t)
+	suffix := uuid.New().String()
+	instance := v1.ExternalID{ExternalType: "AWS::EC2::Instance", ExternalID: []string{"i-" + suffix}}
+	node := v1.ExternalID{ExternalType: "Kubernetes::Node", ExternalID: []string{"node-" + suffix}}
+	pod := func(name, cpu string) v1.ScrapeResult {
+		return v1.ScrapeResult{
+			Type: "Pod", ExternalType: "Kubernetes::Pod", ID: name + "-" + suffix, Name: name, Namespace: "ns-" + suffix,
+			Config: map[string]interface{}{
+				"spec":   map[string]interface{}{"containers": []interface{}{map[string]interface{}{"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": cpu}}}}},
+				"status": map[string]interface{}{"phase": "Running"},
+			},
+			RelationshipResults: v1.RelationshipResults{{
+				ConfigExternalID:  v1.ExternalID{ExternalType: "Kubernetes::Pod", ExternalID: []string{name + "-" + suffix}},
+				RelatedExternalID: node,
+				Relationship:      "NodePod",
+			}},
+		}
+	}
+	results := []v1.ScrapeResult{
+		{Type: "EC2Instance", ExternalType: instance.ExternalType, ID: instance.ExternalID[0], Config: map[string]string{}},
+		{Type: "Node", ExternalType: node.ExternalType, ID: node.ExternalID[0], Config: map[string]string{},
+			RelationshipResults: v1.RelationshipResults{{ConfigExternalID: instance, RelatedExternalID: node, Relationship: "Instance-KuberenetesNode"}}},
+		{Type: "Namespace", ExternalType: "Kubernetes::Namespace", ID: "ns-" + suffix, Name: "ns-" + suffix, Config: map[string]string{}},
+		pod("a", "300m"),
+		pod("b", "100m"),
+	}
+	if err := SaveResults(&v1.ScrapeContext{Context: context.Background()}, results); err != nil {
+		t.Fatalf("failed to save results: %v", err)
+	}
+	if err := db.Exec(`UPDATE config_items SET cost_total_30d = 100 WHERE ? = ANY(external_id)`, instance.ExternalID[0]).Error; err != nil {
+		t.Fatalf("failed to set the cost of the instance: %v", err)
+	}
+
+	if _, err := AllocateClusterCosts(); err != nil {
+		t.Fatalf("failed to allocate costs: %v", err)
+	}
+	var cost float64
+	if err := db.Raw(`SELECT cost_total_30d FROM config_items WHERE ? = ANY(external_id)`, "ns-"+suffix).Scan(&cost).Error; err != nil {
+		t.Fatalf("failed to get the cost of the namespace: %v", err)
+	}
+	if math.Abs(cost-100) > 0.001 {
+		t.Errorf("allocated %.2f to the namespace, expected 100", cost)
+	}
+}
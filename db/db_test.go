@@ -0,0 +1,26 @@
+package db
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+var testDBOnce sync.Once
+var testDBErr error
+
+// setupTestDB connects to the database of TEST_DB_URL and migrates it, tests that need a database are skipped
+// when it is not set
+func setupTestDB(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	testDBOnce.Do(func() {
+		runMigrations = true
+		testDBErr = Init(url)
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to initialize the database: %v", testDBErr)
+	}
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"gomodules.xyz/jsonpatch/v2"
+)
+
+// maxDriftItems is the most config items selected on each side of a drift comparison
+const maxDriftItems = 10000
+
+// GetDrift diffs the configs of the items selected by the left and right searches, items are matched by their
+// external type and one of their external ids, or else by their type, name and the match tags
+func GetDrift(request v1.DriftRequest) (*v1.DriftReport, error) {
+	left, err := driftItems(request.Left)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select left items: %v", err)
+	}
+	right, err := driftItems(request.Right)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select right items: %v", err)
+	}
+
+	byExternalID := make(map[string][]models.ConfigItem)
+	byName := make(map[string][]models.ConfigItem)
+	for _, item := range right {
+		for _, key := range externalKeys(item) {
+			byExternalID[key] = append(byExternalID[key], item)
+		}
+		key := nameKey(item, request.MatchTags)
+		byName[key] = append(byName[key], item)
+	}
+
+	report := &v1.DriftReport{Drifted: []v1.DriftItem{}, OnlyLeft: []v1.DriftMissing{}, OnlyRight: []v1.DriftMissing{}}
+	matched := make(map[string]bool)
+	match := func(candidates []models.ConfigItem) *models.ConfigItem {
+		for i := range candidates {
+			if !matched[candidates[i].ID] {
+				return &candidates[i]
+			}
+		}
+		return nil
+	}
+	for _, l := range left {
+		var r *models.ConfigItem
+		for _, key := range externalKeys(l) {
+			if r = match(byExternalID[key]); r != nil {
+				break
+			}
+		}
+		if r == nil {
+			r = match(byName[nameKey(l, request.MatchTags)])
+		}
+		if r == nil {
+			report.OnlyLeft = append(report.OnlyLeft, driftMissing(l))
+			continue
+		}
+		matched[r.ID] = true
+		report.Matched++
+		drift, err := diffItems(l, *r, request.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		if drift != nil {
+			report.Drifted = append(report.Drifted, *drift)
+		}
+	}
+	for _, r := range right {
+		if !matched[r.ID] {
+			report.OnlyRight = append(report.OnlyRight, driftMissing(r))
+		}
+	}
+	return report, nil
+}
+
+// driftItems returns the items matching the search sorted by their type, name and id
+func driftItems(request v1.SearchRequest) ([]models.ConfigItem, error) {
+	var items []models.ConfigItem
+	tx := searchQuery(request).Limit(maxDriftItems).Find(&items)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == maxDriftItems {
+		return nil, fmt.Errorf("more than %d items selected, narrow down the search", maxDriftItems)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ConfigType != items[j].ConfigType {
+			return items[i].ConfigType < items[j].ConfigType
+		}
+		if items[i].GetName() != items[j].GetName() {
+			return items[i].GetName() < items[j].GetName()
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+// externalKeys returns a key of every external id of the item with its external type
+func externalKeys(item models.ConfigItem) []string {
+	var keys []string
+	for _, id := range item.ExternalID {
+		keys = append(keys, stringValue(item.ExternalType)+"/"+id)
+	}
+	return keys
+}
+
+// nameKey returns the key of the type, name and match tags of the item
+func nameKey(item models.ConfigItem, matchTags []string) string {
+	key := []string{item.ConfigType, item.GetName()}
+	for _, tag := range matchTags {
+		value := ""
+		if item.Tags != nil {
+			value = (*item.Tags)[tag]
+		}
+		key = append(key, value)
+	}
+	return strings.Join(key, "/")
+}
+
+func diffItems(left, right models.ConfigItem, exclude []string) (*v1.DriftItem, error) {
+	leftConfig, rightConfig := "{}", "{}"
+	if left.Config != nil {
+		leftConfig = *left.Config
+	}
+	if right.Config != nil {
+		rightConfig = *right.Config
+	}
+	before, after := []byte(leftConfig), []byte(rightConfig)
+	if len(exclude) > 0 {
+		var err error
+		if before, err = excludeChangeFields(leftConfig, exclude); err != nil {
+			return nil, err
+		}
+		if after, err = excludeChangeFields(rightConfig, exclude); err != nil {
+			return nil, err
+		}
+	}
+
+	operations, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s and %s: %v", left, right, err)
+	}
+	if len(operations) == 0 {
+		return nil, nil
+	}
+	sort.Sort(jsonpatch.ByPath(operations))
+	patch, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.DriftItem{
+		ConfigType: left.ConfigType,
+		Name:       left.GetName(),
+		LeftID:     left.ID,
+		RightID:    right.ID,
+		Patches:    patch,
+		Summary:    patchSummary(operations),
+	}, nil
+}
+
+func driftMissing(ci models.ConfigItem) v1.DriftMissing {
+	return v1.DriftMissing{ID: ci.ID, ConfigType: ci.ConfigType, Name: ci.GetName()}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"encoding/json"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/pkg/errors"
+)
+
+// DryRunResult is a scraped result with what saving it would change
+type DryRunResult struct {
+	// Action is create, update or unchanged for config items, it is empty when the database is unavailable
+	// or the result has no config
+	Action  string          `json:"action,omitempty"`
+	Patch   json.RawMessage `json:"patch,omitempty"`
+	Summary string          `json:"summary,omitempty"`
+	Result  v1.ScrapeResult `json:"result"`
+}
+
+// DiffResults compares the results with the config items of the tenant in the database without saving them
+func DiffResults(results []v1.ScrapeResult, tenant string) ([]DryRunResult, error) {
+	var dryRun []DryRunResult
+	var items []models.ConfigItem
+	var itemResults []int
+	for i, result := range results {
+		dryRun = append(dryRun, DryRunResult{Result: result})
+		if result.Config == nil || db == nil {
+			continue
+		}
+		ci, err := NewConfigItemFromResult(result, tenant)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create config item: %s", result)
+		}
+		items = append(items, *ci)
+		itemResults = append(itemResults, i)
+	}
+	if len(items) == 0 {
+		return dryRun, nil
+	}
+
+	existing, err := findExistingConfigItems(items, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lookup existing configs")
+	}
+	for i, ci := range items {
+		result := &dryRun[itemResults[i]]
+		current, ok := existing[*ci.ExternalType+"/"+ci.ID]
+		if !ok {
+			result.Action = "create"
+			continue
+		}
+		change, err := generateDiff(ci, *current, result.Result.BaseScraper.Transform.ChangeExclusions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check %s for changes", ci)
+		}
+		if change == nil {
+			result.Action = "unchanged"
+			continue
+		}
+		result.Action = "update"
+		result.Patch = json.RawMessage(change.Patches)
+		result.Summary = change.Summary
+	}
+	return dryRun, nil
+}
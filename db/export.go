@@ -0,0 +1,199 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// kinds of the exported records, in the order they are exported and imported
+const (
+	KindConfigItem   = "config_item"
+	KindConfigChange = "config_change"
+	KindAnalysis     = "config_analysis"
+)
+
+// Record is an exported row of the inventory
+type Record struct {
+	Kind string          `json:"kind"`
+	ID   string          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ExportInventory calls fn with every config item that is not deleted, then their changes and analyses,
+// so that imports create the config items before the rows referencing them
+func ExportInventory(fn func(Record) error) error {
+	live := "config_id IN (SELECT id FROM config_items WHERE deleted_at IS NULL)"
+	if err := exportTable(KindConfigItem, db.Where("deleted_at IS NULL"), &models.ConfigItem{}, fn); err != nil {
+		return err
+	}
+	if err := exportTable(KindConfigChange, db.Where(live), &models.ConfigChange{}, fn); err != nil {
+		return err
+	}
+	return exportTable(KindAnalysis, db.Where(live), &models.Analysis{}, fn)
+}
+
+// exportTable streams the rows of the query to fn with a cursor, model is a pointer to the model of the table
+func exportTable(kind string, query *gorm.DB, model interface{}, fn func(Record) error) error {
+	rows, err := query.Model(model).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	modelType := reflect.TypeOf(model).Elem()
+	for rows.Next() {
+		row := reflect.New(modelType).Interface()
+		if err := db.ScanRows(rows, row); err != nil {
+			return err
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		var id struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(data, &id)
+		if err := fn(Record{Kind: kind, ID: id.ID, Data: data}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Importer upserts batches of exported records. Config items are matched on their external id, external type and
+// tenant, so an item that exists with another id keeps its id and the changes, analyses and children of the exported
+// item are imported with that id
+type Importer struct {
+	// ids of the exported config items that exist with another id
+	ids map[string]string
+}
+
+func NewImporter() *Importer {
+	return &Importer{ids: make(map[string]string)}
+}
+
+// Import upserts a batch of exported records, existing config items and analyses are replaced
+func (importer *Importer) Import(records []Record) error {
+	var items []models.ConfigItem
+	var changes []models.ConfigChange
+	var analyses []models.Analysis
+	for _, r := range records {
+		var err error
+		switch r.Kind {
+		case KindConfigItem:
+			var item models.ConfigItem
+			err = json.Unmarshal(r.Data, &item)
+			items = append(items, item)
+		case KindConfigChange:
+			var change models.ConfigChange
+			err = json.Unmarshal(r.Data, &change)
+			changes = append(changes, change)
+		case KindAnalysis:
+			var analysis models.Analysis
+			err = json.Unmarshal(r.Data, &analysis)
+			analyses = append(analyses, analysis)
+		default:
+			err = fmt.Errorf("unknown kind %s", r.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid record %s/%s: %v", r.Kind, r.ID, err)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := importer.matchItems(tx, items); err != nil {
+			return err
+		}
+		// an existing row can only be upserted once per statement, the last of the items matching it is kept
+		byID := make(map[string]int, len(items))
+		var upserts []models.ConfigItem
+		for _, item := range items {
+			if item.ParentID != nil {
+				item.ParentID = importer.id(item.ParentID)
+			}
+			if i, ok := byID[item.ID]; ok {
+				upserts[i] = item
+				continue
+			}
+			byID[item.ID] = len(upserts)
+			upserts = append(upserts, item)
+		}
+		for i := range changes {
+			changes[i].ConfigID = *importer.id(&changes[i].ConfigID)
+		}
+		for i := range analyses {
+			analyses[i].ConfigID = *importer.id(&analyses[i].ConfigID)
+		}
+
+		upsert := tx.Clauses(clause.OnConflict{UpdateAll: true})
+		if len(upserts) > 0 {
+			if err := upsert.Create(&upserts).Error; err != nil {
+				return err
+			}
+		}
+		if len(changes) > 0 {
+			// changes are immutable, the ones that exist are skipped
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&changes).Error; err != nil {
+				return err
+			}
+		}
+		if len(analyses) > 0 {
+			if err := upsert.Create(&analyses).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// matchItems replaces the ids of the items that exist with another id
+func (importer *Importer) matchItems(tx *gorm.DB, items []models.ConfigItem) error {
+	for i, item := range items {
+		if len(item.ExternalID) == 0 {
+			continue
+		}
+		var ids []string
+		err := tx.Model(&models.ConfigItem{}).Select("id").
+			Where("COALESCE(external_type, '') = ? AND tenant = ? AND external_id && ? AND deleted_at IS NULL",
+				stringValue(item.ExternalType), item.Tenant, item.ExternalID).
+			Limit(1).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 && ids[0] != item.ID {
+			importer.ids[item.ID] = ids[0]
+			items[i].ID = ids[0]
+		}
+	}
+	return nil
+}
+
+// id returns the id on this instance of an exported config item
+func (importer *Importer) id(exported *string) *string {
+	if id, ok := importer.ids[*exported]; ok {
+		return &id
+	}
+	return exported
+}
+
+// Finish points the children of the config items that exist with another id to that id, once every record is
+// imported as the parents of items can be imported after them
+func (importer *Importer) Finish() error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for exported, id := range importer.ids {
+			if err := tx.Exec("UPDATE config_items SET parent_id = ? WHERE parent_id = ?", id, exported).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE config_items SET path = replace(path, ?, ?) WHERE path LIKE ?", exported, id, "%"+exported+"%").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/flanksource/config-db/db/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestImportMatchesExistingItems(t *testing.T) {
+	setupTestDB(t)
+	externalID := "import-" + uuid.New().String()
+	externalType := "Test::Import"
+	existing := models.ConfigItem{ID: uuid.New().String(), ConfigType: "Test", ExternalType: &externalType, ExternalID: pq.StringArray{externalID}}
+	if err := db.Create(&existing).Error; err != nil {
+		t.Fatalf("failed to create the config item: %v", err)
+	}
+
+	config := `{"imported": true}`
+	exported := models.ConfigItem{ID: uuid.New().String(), ConfigType: "Test", ExternalType: &externalType, ExternalID: pq.StringArray{externalID}, Config: &config}
+	change := models.ConfigChange{ID: uuid.New().String(), ConfigID: exported.ID, ChangeType: "diff"}
+	itemData, _ := json.Marshal(exported)
+	changeData, _ := json.Marshal(change)
+
+	importer := NewImporter()
+	if err := importer.Import([]Record{{Kind: KindConfigItem, ID: exported.ID, Data: itemData}, {Kind: KindConfigChange, ID: change.ID, Data: changeData}}); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if err := importer.Finish(); err != nil {
+		t.Fatalf("failed to finish the import: %v", err)
+	}
+
+	var items []models.ConfigItem
+	if err := db.Where("? = ANY(external_id)", externalID).Find(&items).Error; err != nil {
+		t.Fatalf("failed to get the config items: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != existing.ID {
+		t.Fatalf("expected the existing config item to be replaced, got %d items", len(items))
+	}
+	var imported models.ConfigChange
+	if err := db.First(&imported, "id = ?", change.ID).Error; err != nil {
+		t.Fatalf("failed to get the change: %v", err)
+	}
+	if imported.ConfigID != existing.ID {
+		t.Errorf("expected the change of the existing config item, got %s", imported.ConfigID)
+	}
+}
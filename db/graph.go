@@ -0,0 +1,117 @@
+package db
+
+import (
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm"
+)
+
+// ParentRelation is the relation of the edge from a config item to its parent
+const ParentRelation = "parent"
+
+// GetConfigGraph returns the config items within depth relationships (in either direction) of the config item, only
+// the items of the tenant when it is set
+func GetConfigGraph(id string, depth int, tenant string) (*v1.ConfigGraph, error) {
+	root, err := GetConfigItemFromID(id)
+	if err != nil {
+		return nil, err
+	}
+	if root.ID == "" || (tenant != "" && root.Tenant != tenant) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	scope := func(tx *gorm.DB) *gorm.DB {
+		if tenant != "" {
+			return tx.Where("tenant = ?", tenant)
+		}
+		return tx
+	}
+
+	graph := &v1.ConfigGraph{}
+	visited := map[string]bool{root.ID: true}
+	edges := make(map[v1.GraphEdge]bool)
+	graph.Nodes = append(graph.Nodes, graphNode(*root, 0))
+
+	frontier := []string{root.ID}
+	parents := map[string]*string{root.ID: root.ParentID}
+	for level := 1; level <= depth && len(frontier) > 0; level++ {
+		var next []string
+		addEdge := func(edge v1.GraphEdge, neighbour string) {
+			if !edges[edge] {
+				edges[edge] = true
+				graph.Edges = append(graph.Edges, edge)
+			}
+			if !visited[neighbour] {
+				visited[neighbour] = true
+				next = append(next, neighbour)
+			}
+		}
+
+		var relationships []models.ConfigRelationship
+		if err := db.Where("config_id IN ? OR related_id IN ?", frontier, frontier).Find(&relationships).Error; err != nil {
+			return nil, err
+		}
+		frontierSet := make(map[string]bool)
+		for _, id := range frontier {
+			frontierSet[id] = true
+			if parent := parents[id]; parent != nil {
+				addEdge(v1.GraphEdge{From: id, To: *parent, Relation: ParentRelation}, *parent)
+			}
+		}
+		for _, r := range relationships {
+			edge := v1.GraphEdge{From: r.ConfigID, To: r.RelatedID, Relation: r.Relation}
+			if frontierSet[r.ConfigID] {
+				addEdge(edge, r.RelatedID)
+			}
+			if frontierSet[r.RelatedID] {
+				addEdge(edge, r.ConfigID)
+			}
+		}
+
+		var children []models.ConfigItem
+		if err := db.Omit("config").Scopes(scope).Where("parent_id IN ? AND deleted_at IS NULL", frontier).Find(&children).Error; err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			addEdge(v1.GraphEdge{From: child.ID, To: *child.ParentID, Relation: ParentRelation}, child.ID)
+		}
+
+		if len(next) == 0 {
+			break
+		}
+		var items []models.ConfigItem
+		if err := db.Omit("config").Scopes(scope).Where("id IN ?", next).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		// the graph is not traversed through the items of other tenants
+		frontier = nil
+		for _, item := range items {
+			parents[item.ID] = item.ParentID
+			graph.Nodes = append(graph.Nodes, graphNode(item, level))
+			frontier = append(frontier, item.ID)
+		}
+	}
+
+	// drop edges to items that could not be loaded, e.g. hard deleted ones
+	var valid []v1.GraphEdge
+	loaded := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		loaded[node.ID] = true
+	}
+	for _, edge := range graph.Edges {
+		if loaded[edge.From] && loaded[edge.To] {
+			valid = append(valid, edge)
+		}
+	}
+	graph.Edges = valid
+	return graph, nil
+}
+
+func graphNode(ci models.ConfigItem, depth int) v1.GraphNode {
+	return v1.GraphNode{
+		ID:           ci.ID,
+		Name:         ci.Name,
+		ConfigType:   ci.ConfigType,
+		ExternalType: ci.ExternalType,
+		Depth:        depth,
+	}
+}
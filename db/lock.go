@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/flanksource/config-db/db/models"
+)
+
+// TryLock takes the postgres session advisory lock of the key, so that a job runs on only one of the replicas
+// sharing the database. It returns false when another session holds the lock, unlock releases the lock and its
+// connection. Without a database the lock is always taken
+func TryLock(ctx context.Context, key string) (unlock func(), ok bool, err error) {
+	if Pool == nil {
+		return func() {}, true, nil
+	}
+	conn, err := Pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	id := lockID(key)
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return func() {
+		// the lock is released with the session if unlocking fails, so the connection is not reused
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", id); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, true, nil
+}
+
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// LastScrapeRun returns the latest run of the scraper, or nil when it never ran
+func LastScrapeRun(name string) (*models.ScrapeRun, error) {
+	if db == nil {
+		return nil, nil
+	}
+	runs, _, err := GetScrapeRuns(name, "", "", "", Page{Limit: 1})
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
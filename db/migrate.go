@@ -0,0 +1,49 @@
+package db
+
+import (
+	"github.com/flanksource/config-db/db/models"
+)
+
+// indexes that are not part of the duty schema
+var indexes = []string{
+	`CREATE INDEX IF NOT EXISTS config_items_config_search ON config_items USING GIN (to_tsvector('simple', config))`,
+	`CREATE INDEX IF NOT EXISTS config_items_config_path ON config_items USING GIN (config jsonb_path_ops)`,
+	`CREATE INDEX IF NOT EXISTS config_items_tags ON config_items USING GIN (tags)`,
+	`CREATE INDEX IF NOT EXISTS config_items_tenant ON config_items (tenant)`,
+}
+
+// migrate creates the tables and indexes owned by config-db
+func migrate() error {
+	if err := db.AutoMigrate(&models.ScrapeRun{}, &models.ScrapeTask{}, &models.ScrapeCheckpoint{}, &models.AuditLog{}, &models.View{}, &models.Webhook{}, &models.ConfigCost{}); err != nil {
+		return err
+	}
+	// config_changes is owned by the duty schema, only the reverse patches used for snapshots are added to it
+	if !db.Migrator().HasColumn(&models.ConfigChange{}, "ReversePatches") {
+		if err := db.Migrator().AddColumn(&models.ConfigChange{}, "ReversePatches"); err != nil {
+			return err
+		}
+	}
+	// config items are scoped to the tenant of the scraper or API caller that saved them, and record the time of
+	// their newest change deleted by retention as snapshots before it cannot be reconstructed
+	for _, column := range []string{"Tenant", "ChangesPrunedAt"} {
+		if !db.Migrator().HasColumn(&models.ConfigItem{}, column) {
+			if err := db.Migrator().AddColumn(&models.ConfigItem{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	// amortized costs and commitment coverage are only reported by the AWS cost scraper, forecasts by the cost job
+	for _, column := range []string{"CostAmortized30d", "CommitmentCoverage30d", "CommitmentUtilization30d", "CostForecast30d"} {
+		if !db.Migrator().HasColumn(&models.ConfigItem{}, column) {
+			if err := db.Migrator().AddColumn(&models.ConfigItem{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	for _, index := range indexes {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audit actions
+const (
+	AuditCreated  = "created"
+	AuditUpdated  = "updated"
+	AuditDeleted  = "deleted"
+	AuditChanged  = "changed"
+	AuditAnalyzed = "analyzed"
+	AuditPurged   = "purged"
+	AuditPruned   = "pruned"
+)
+
+// AuditLog records who changed a config item, entries are never updated or deleted
+type AuditLog struct {
+	ID           string         `gorm:"primaryKey;type:uuid" json:"id"`
+	CreatedAt    time.Time      `gorm:"index" json:"created_at"`
+	Action       string         `gorm:"index" json:"action"`
+	ConfigID     *string        `gorm:"type:uuid;index" json:"config_id,omitempty"`
+	ExternalType string         `json:"external_type,omitempty"`
+	ExternalID   pq.StringArray `gorm:"type:text[]" json:"external_id,omitempty"`
+	// Actor is the scraper, API token or job that made the change
+	Actor   string `gorm:"index" json:"actor"`
+	Scraper string `gorm:"index" json:"scraper,omitempty"`
+	RunID   string `gorm:"index" json:"run_id,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Tenant  string `gorm:"index" json:"tenant,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
@@ -22,6 +22,7 @@ type ConfigChange struct {
 	Source           string     `gorm:"column:source" json:"source"`
 	Summary          string     `gorm:"column:summary;default:null" json:"summary,omitempty"`
 	Patches          string     `gorm:"column:patches;default:null" json:"patches,omitempty"`
+	ReversePatches   string     `gorm:"column:reverse_patches;default:null" json:"reverse_patches,omitempty"`
 	Details          v1.JSON    `gorm:"column:details" json:"details,omitempty"`
 	CreatedAt        *time.Time `gorm:"column:created_at" json:"created_at"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ConfigCost is the cost of a config item over the day before it was recorded, costs are recorded daily from the
+// cost_total_1d of the config items to forecast costs and detect anomalies
+type ConfigCost struct {
+	ConfigID string    `gorm:"primaryKey;column:config_id" json:"config_id"`
+	Date     time.Time `gorm:"primaryKey;column:date;type:date" json:"date"`
+	Cost     float64   `gorm:"column:cost" json:"cost"`
+}
+
+func (ConfigCost) TableName() string {
+	return "config_costs"
+}
@@ -11,36 +11,60 @@ import (
 
 // ConfigItem represents the config item database table
 type ConfigItem struct {
-	ID            string            `gorm:"primaryKey;unique_index;not null;column:id" json:"id"  `
-	ScraperID     *string           `gorm:"column:scraper_id;default:null" json:"scraper_id,omitempty"  `
-	ConfigType    string            `gorm:"column:config_type;default:''" json:"config_type"  `
-	ExternalID    pq.StringArray    `gorm:"column:external_id;type:[]text" json:"external_id,omitempty"  `
-	ExternalType  *string           `gorm:"column:external_type;default:null" json:"external_type,omitempty"  `
-	Name          *string           `gorm:"column:name;default:null" json:"name,omitempty"  `
-	Namespace     *string           `gorm:"column:namespace;default:null" json:"namespace,omitempty"  `
-	Description   *string           `gorm:"column:description;default:null" json:"description,omitempty"  `
-	Account       *string           `gorm:"column:account;default:null" json:"account,omitempty"  `
-	Region        *string           `gorm:"column:region;default:null" json:"region,omitempty"  `
-	Zone          *string           `gorm:"column:zone;default:null" json:"zone,omitempty"  `
-	Network       *string           `gorm:"column:network;default:null" json:"network,omitempty"  `
-	Subnet        *string           `gorm:"column:subnet;default:null" json:"subnet,omitempty"  `
-	Config        *string           `gorm:"column:config;default:null" json:"config,omitempty"  `
-	Source        *string           `gorm:"column:source;default:null" json:"source,omitempty"  `
-	ParentID      *string           `gorm:"column:parent_id;default:null" json:"parent_id,omitempty"`
-	Path          string            `gorm:"column:path;default:null" json:"path,omitempty"`
-	CostPerMinute float64           `gorm:"column:cost_per_minute;default:null" json:"cost_per_minute,omitempty"`
-	CostTotal1d   float64           `gorm:"column:cost_total_1d;default:null" json:"cost_total_1d,omitempty"`
-	CostTotal7d   float64           `gorm:"column:cost_total_7d;default:null" json:"cost_total_7d,omitempty"`
-	CostTotal30d  float64           `gorm:"column:cost_total_30d;default:null" json:"cost_total_30d,omitempty"`
-	Tags          *v1.JSONStringMap `gorm:"column:tags;default:null" json:"tags,omitempty"  `
-	CreatedAt     time.Time         `gorm:"column:created_at" json:"created_at"  `
-	UpdatedAt     time.Time         `gorm:"column:updated_at" json:"updated_at"  `
+	ID                       string            `gorm:"primaryKey;unique_index;not null;column:id" json:"id"  `
+	ScraperID                *string           `gorm:"column:scraper_id;default:null" json:"scraper_id,omitempty"  `
+	ConfigType               string            `gorm:"column:config_type;default:''" json:"config_type"  `
+	ExternalID               pq.StringArray    `gorm:"column:external_id;type:[]text" json:"external_id,omitempty"  `
+	ExternalType             *string           `gorm:"column:external_type;default:null" json:"external_type,omitempty"  `
+	Name                     *string           `gorm:"column:name;default:null" json:"name,omitempty"  `
+	Namespace                *string           `gorm:"column:namespace;default:null" json:"namespace,omitempty"  `
+	Description              *string           `gorm:"column:description;default:null" json:"description,omitempty"  `
+	Account                  *string           `gorm:"column:account;default:null" json:"account,omitempty"  `
+	Region                   *string           `gorm:"column:region;default:null" json:"region,omitempty"  `
+	Zone                     *string           `gorm:"column:zone;default:null" json:"zone,omitempty"  `
+	Network                  *string           `gorm:"column:network;default:null" json:"network,omitempty"  `
+	Subnet                   *string           `gorm:"column:subnet;default:null" json:"subnet,omitempty"  `
+	Config                   *string           `gorm:"column:config;default:null" json:"config,omitempty"  `
+	Source                   *string           `gorm:"column:source;default:null" json:"source,omitempty"  `
+	ParentID                 *string           `gorm:"column:parent_id;default:null" json:"parent_id,omitempty"`
+	Path                     string            `gorm:"column:path;default:null" json:"path,omitempty"`
+	CostPerMinute            float64           `gorm:"column:cost_per_minute;default:null" json:"cost_per_minute,omitempty"`
+	CostTotal1d              float64           `gorm:"column:cost_total_1d;default:null" json:"cost_total_1d,omitempty"`
+	CostTotal7d              float64           `gorm:"column:cost_total_7d;default:null" json:"cost_total_7d,omitempty"`
+	CostTotal30d             float64           `gorm:"column:cost_total_30d;default:null" json:"cost_total_30d,omitempty"`
+	CostAmortized30d         float64           `gorm:"column:cost_amortized_30d;default:null" json:"cost_amortized_30d,omitempty"`
+	CommitmentCoverage30d    float64           `gorm:"column:commitment_coverage_30d;default:null" json:"commitment_coverage_30d,omitempty"`
+	CommitmentUtilization30d float64           `gorm:"column:commitment_utilization_30d;default:null" json:"commitment_utilization_30d,omitempty"`
+	CostForecast30d          float64           `gorm:"column:cost_forecast_30d;default:null" json:"cost_forecast_30d,omitempty"`
+	Tags                     *v1.JSONStringMap `gorm:"column:tags;default:null" json:"tags,omitempty"  `
+	Tenant                   string            `gorm:"column:tenant;default:''" json:"tenant,omitempty"`
+	ChangesPrunedAt          *time.Time        `gorm:"column:changes_pruned_at;default:null" json:"changes_pruned_at,omitempty"`
+	CreatedAt                time.Time         `gorm:"column:created_at" json:"created_at"  `
+	UpdatedAt                time.Time         `gorm:"column:updated_at" json:"updated_at"  `
+}
+
+// SearchResult is a page of config items matching a search
+type SearchResult struct {
+	Total   int64        `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+	Results []ConfigItem `json:"results"`
+	// NextCursor is passed as the cursor of the next search to get the next page, it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func (ci ConfigItem) String() string {
 	return fmt.Sprintf("%s/%s", ci.ConfigType, ci.ID)
 }
 
+// GetName returns the name of the config item, or an empty string when it has no name
+func (ci ConfigItem) GetName() string {
+	if ci.Name == nil {
+		return ""
+	}
+	return *ci.Name
+}
+
 func (ci ConfigItem) ConfigJSONStringMap() (map[string]interface{}, error) {
 	var m map[string]interface{}
 	err := json.Unmarshal([]byte(*ci.Config), &m)
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScrapeCheckpoint records the tasks completed by an interrupted scrape of a scraper
+type ScrapeCheckpoint struct {
+	Name      string         `gorm:"primaryKey" json:"name"`
+	Completed pq.StringArray `gorm:"type:text[]" json:"completed"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (ScrapeCheckpoint) TableName() string {
+	return "scrape_checkpoints"
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/lib/pq"
+)
+
+// ScrapeRun records a single execution of a scraper
+type ScrapeRun struct {
+	ID           string         `gorm:"primaryKey;type:uuid" json:"id"`
+	Name         string         `gorm:"index" json:"name"`
+	Tenant       string         `gorm:"index" json:"tenant,omitempty"`
+	Status       string         `json:"status"`
+	StartTime    time.Time      `gorm:"index" json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	Items        int            `json:"items"`
+	Errors       int            `json:"errors"`
+	ErrorSummary pq.StringArray `gorm:"type:text[]" json:"error_summary,omitempty"`
+	// ItemErrors are the errors of single items, up to a limit per run
+	ItemErrors []v1.ScrapeError `gorm:"type:jsonb;serializer:json" json:"item_errors,omitempty"`
+}
+
+func (ScrapeRun) TableName() string {
+	return "scrape_runs"
+}
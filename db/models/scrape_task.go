@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Statuses of scrape tasks, failed tasks are retried until they are dead
+const (
+	ScrapeTaskPending   = "pending"
+	ScrapeTaskRunning   = "running"
+	ScrapeTaskSucceeded = "succeeded"
+	ScrapeTaskDead      = "dead"
+)
+
+// ScrapeTask is a scrape of a scraper, or of one region of it, queued for a worker
+type ScrapeTask struct {
+	ID   string `gorm:"primaryKey;type:uuid" json:"id"`
+	Name string `gorm:"index" json:"name"`
+	// ScraperID is the id the scraper is scheduled with, it identifies the scrape config to update with the outcome
+	ScraperID string `json:"scraper_id,omitempty"`
+	// Region is the region of the AWS scrapers of the task, empty when the task is not split by region
+	Region string `json:"region,omitempty"`
+	// Spec is the JSON of the scraper to run
+	Spec     string `json:"-"`
+	Status   string `gorm:"index" json:"status"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// NotBefore delays the retries of failed tasks
+	NotBefore time.Time  `gorm:"index" json:"not_before"`
+	Worker    string     `json:"worker,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (ScrapeTask) TableName() string {
+	return "scrape_tasks"
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// View is a saved view created through the API
+type View struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	Spec      string    `gorm:"type:jsonb" json:"spec"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (View) TableName() string {
+	return "views"
+}
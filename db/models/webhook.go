@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Webhook is a webhook registered through the API
+type Webhook struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	Spec      string    `gorm:"type:jsonb" json:"spec"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
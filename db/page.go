@@ -0,0 +1,77 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Cursor is the position of the last item of a page, the values of the columns the list is sorted by
+type Cursor []interface{}
+
+// String encodes the cursor for API callers, they pass it back unchanged to get the next page
+func (c Cursor) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal([]interface{}(c))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor returned with a previous page
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor) == 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
+}
+
+// Page of a list, the page after the cursor when it is set, otherwise the page at the offset
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor Cursor
+}
+
+// paginate sorts the query by the columns, the last of which must be unique, and selects the page
+func (p Page) paginate(tx *gorm.DB, desc bool, columns ...string) (*gorm.DB, error) {
+	order, op := "ASC", ">"
+	if desc {
+		order, op = "DESC", "<"
+	}
+	sorts := make([]string, len(columns))
+	for i, column := range columns {
+		sorts[i] = column + " " + order
+	}
+	tx = tx.Order(strings.Join(sorts, ", "))
+	if p.Limit > 0 {
+		tx = tx.Limit(p.Limit)
+	}
+	if len(p.Cursor) == 0 {
+		return tx.Offset(p.Offset), nil
+	}
+	if len(p.Cursor) != len(columns) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return tx.Where(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), p.Cursor...), nil
+}
+
+// next returns the cursor of the page after a page of n items, or nil when it is the last page
+func (p Page) next(n int, last func() Cursor) Cursor {
+	if n == 0 || p.Limit <= 0 || n < p.Limit {
+		return nil
+	}
+	return last()
+}
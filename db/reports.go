@@ -0,0 +1,23 @@
+package db
+
+// AnalysisSummary is the number of open analysis results of a severity in an account and namespace
+type AnalysisSummary struct {
+	Account      string `json:"account"`
+	Namespace    string `json:"namespace"`
+	Severity     string `json:"severity"`
+	AnalysisType string `json:"analysis_type"`
+	Count        int    `json:"count"`
+}
+
+// GetAnalysisSummary aggregates the analysis results that are not resolved on config items that are not deleted
+func GetAnalysisSummary() ([]AnalysisSummary, error) {
+	var rows []AnalysisSummary
+	err := db.Raw(`SELECT COALESCE(ci.account, '') AS account, COALESCE(ci.namespace, '') AS namespace,
+			COALESCE(a.severity, '') AS severity, COALESCE(a.analysis_type, '') AS analysis_type, COUNT(*) AS count
+		FROM config_analysis a
+		JOIN config_items ci ON ci.id = a.config_id
+		WHERE ci.deleted_at IS NULL AND a.status IS DISTINCT FROM 'resolved'
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 2, 3, 4`).Scan(&rows).Error
+	return rows, err
+}
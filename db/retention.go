@@ -0,0 +1,195 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/metrics"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Retention defaults, 0 disables the policy
+var (
+	ChangeRetentionDays      = 0
+	DeletedItemRetentionDays = 0
+	MaxChangesPerItem        = 0
+	AuditRetentionDays       = 0
+)
+
+// typeFilter restricts a query on config items to the given types, or to every other type when exclude is set
+func typeFilter(tx *gorm.DB, column string, types []string, exclude bool) *gorm.DB {
+	if len(types) == 0 {
+		return tx
+	}
+	if exclude {
+		return tx.Where(fmt.Sprintf("%s NOT IN (SELECT id FROM config_items WHERE external_type = ANY(?))", column), pq.StringArray(types))
+	}
+	return tx.Where(fmt.Sprintf("%s IN (SELECT id FROM config_items WHERE external_type = ANY(?))", column), pq.StringArray(types))
+}
+
+// ScraperConfigTypes returns the external types of the config items saved by the scrape config
+func ScraperConfigTypes(scraperID string) ([]string, error) {
+	var types []string
+	err := db.Table("config_items").Where("scraper_id = ?", scraperID).Distinct().Pluck("external_type", &types).Error
+	return types, err
+}
+
+// PruneChanges deletes changes older than days of config items with (or without when exclude is set) the given types
+func PruneChanges(days int, types []string, exclude bool) (int64, error) {
+	if db == nil || days <= 0 {
+		return 0, nil
+	}
+	var pruned int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		changes := func() *gorm.DB {
+			return typeFilter(tx.Table("config_changes").Where("created_at < NOW() - make_interval(days => ?)", days), "config_id", types, exclude)
+		}
+		if err := markPruned(tx, changes().Select("config_id, MAX(created_at) AS created_at").Group("config_id")); err != nil {
+			return err
+		}
+		result := changes().Delete(&models.ConfigChange{})
+		if result.Error != nil {
+			return result.Error
+		}
+		pruned = result.RowsAffected
+		return auditPruned(tx, pruned, fmt.Sprintf("deleted %d changes older than %d days", pruned, days))
+	})
+	if err != nil {
+		return 0, err
+	}
+	metrics.RetentionPurged.WithLabelValues("config_changes").Add(float64(pruned))
+	return pruned, nil
+}
+
+// markPruned records the time of the newest deleted change of the config items of the pruned rows, which are the
+// config_id and the created_at of the newest change of every item
+func markPruned(tx *gorm.DB, pruned interface{}) error {
+	return tx.Exec(`UPDATE config_items SET changes_pruned_at = GREATEST(config_items.changes_pruned_at, pruned.created_at)
+		FROM (?) AS pruned WHERE config_items.id = pruned.config_id`, pruned).Error
+}
+
+// purgeBatchSize limits the config items purged in a transaction, as their ids are bound as query parameters
+const purgeBatchSize = 1000
+
+// PurgeDeletedItems hard deletes config items that were soft deleted more than days ago, together with
+// their changes, analysis, costs and relationships
+func PurgeDeletedItems(days int, types []string, exclude bool) (int64, error) {
+	if db == nil || days <= 0 {
+		return 0, nil
+	}
+
+	var purged int64
+	for {
+		found, deleted, err := purgeDeletedBatch(days, types, exclude)
+		purged += deleted
+		metrics.RetentionPurged.WithLabelValues("config_items").Add(float64(deleted))
+		if err != nil || found < purgeBatchSize {
+			return purged, err
+		}
+	}
+}
+
+// purgeDeletedBatch purges up to purgeBatchSize config items in a transaction, it returns how many items were found
+// and how many of them were deleted
+func purgeDeletedBatch(days int, types []string, exclude bool) (int, int64, error) {
+	var ids []string
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("config_items").Where("deleted_at < NOW() - make_interval(days => ?)", days)
+		if len(types) > 0 && exclude {
+			query = query.Where("external_type <> ALL(?)", pq.StringArray(types))
+		} else if len(types) > 0 {
+			query = query.Where("external_type = ANY(?)", pq.StringArray(types))
+		}
+		if err := query.Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		for _, table := range []string{"config_changes", "config_analysis", "config_costs"} {
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE config_id IN ?", table), ids).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM config_relationships WHERE config_id IN ? OR related_id IN ?", ids, ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE config_items SET parent_id = NULL WHERE parent_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		result := tx.Exec("DELETE FROM config_items WHERE id IN ?", ids)
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+		var audit []models.AuditLog
+		for i := range ids {
+			audit = append(audit, models.AuditLog{
+				Action:   models.AuditPurged,
+				ConfigID: &ids[i],
+				Actor:    ActorRetention,
+				Summary:  fmt.Sprintf("deleted more than %d days ago", days),
+			})
+		}
+		return saveAuditLog(tx, audit...)
+	})
+	if err != nil {
+		return len(ids), 0, err
+	}
+	return len(ids), purged, nil
+}
+
+// CompactChanges keeps only the latest max changes of every config item
+func CompactChanges(max int) (int64, error) {
+	if db == nil || max <= 0 {
+		return 0, nil
+	}
+	var compacted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		ranked := tx.Raw(`SELECT id, config_id, created_at FROM (
+			SELECT id, config_id, created_at, ROW_NUMBER() OVER (PARTITION BY config_id ORDER BY created_at DESC) AS rn FROM config_changes
+		) ranked WHERE rn > ?`, max)
+		if err := markPruned(tx, tx.Table("(?) AS excess", ranked).Select("config_id, MAX(created_at) AS created_at").Group("config_id")); err != nil {
+			return err
+		}
+		result := tx.Exec(`DELETE FROM config_changes WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY config_id ORDER BY created_at DESC) AS rn FROM config_changes
+			) ranked WHERE rn > ?)`, max)
+		if result.Error != nil {
+			return result.Error
+		}
+		compacted = result.RowsAffected
+		return auditPruned(tx, compacted, fmt.Sprintf("deleted %d changes exceeding %d per item", compacted, max))
+	})
+	if err != nil {
+		return 0, err
+	}
+	metrics.RetentionPurged.WithLabelValues("config_changes").Add(float64(compacted))
+	return compacted, nil
+}
+
+// auditPruned records the changes deleted by a retention policy, they are not recorded per config item
+// as a policy can delete millions of them
+func auditPruned(tx *gorm.DB, deleted int64, summary string) error {
+	if deleted == 0 {
+		return nil
+	}
+	return saveAuditLog(tx, models.AuditLog{Action: models.AuditPruned, Actor: ActorRetention, Summary: summary})
+}
+
+// PruneAuditLog deletes audit log entries older than days
+func PruneAuditLog(days int) (int64, error) {
+	if db == nil || days <= 0 {
+		return 0, nil
+	}
+	result := db.Exec("DELETE FROM audit_log WHERE created_at < NOW() - make_interval(days => ?)", days)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	metrics.RetentionPurged.WithLabelValues("audit_log").Add(float64(result.RowsAffected))
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"time"
+
+	"github.com/flanksource/config-db/db/models"
+)
+
+// GetScrapeCheckpoint returns the tasks completed by the interrupted scrape of the scraper, checkpoints older
+// than maxAge are ignored as the items they scraped are stale
+func GetScrapeCheckpoint(name string, maxAge time.Duration) ([]string, error) {
+	if db == nil {
+		return nil, nil
+	}
+	var checkpoint models.ScrapeCheckpoint
+	tx := db.Limit(1).Find(&checkpoint, "name = ? AND updated_at > ?", name, time.Now().Add(-maxAge))
+	return checkpoint.Completed, tx.Error
+}
+
+// SaveScrapeCheckpoint records the tasks completed by an interrupted scrape
+func SaveScrapeCheckpoint(name string, completed []string) error {
+	if db == nil {
+		return nil
+	}
+	return db.Save(&models.ScrapeCheckpoint{Name: name, Completed: completed, UpdatedAt: time.Now()}).Error
+}
+
+// DeleteScrapeCheckpoint deletes the checkpoint once a scrape completed
+func DeleteScrapeCheckpoint(name string) error {
+	if db == nil {
+		return nil
+	}
+	return db.Delete(&models.ScrapeCheckpoint{}, "name = ?", name).Error
+}
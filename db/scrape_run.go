@@ -0,0 +1,52 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
+)
+
+func PersistScrapeRun(run *models.ScrapeRun) error {
+	if db == nil {
+		return nil
+	}
+	if run.ID == "" {
+		run.ID = ulid.MustNew().AsUUID()
+	}
+	return db.Create(run).Error
+}
+
+// GetScrapeRuns returns a page of the latest runs, optionally filtered by scraper name, status, an item that had an
+// error and tenant, and the cursor of the next page
+func GetScrapeRuns(name, status, externalID, tenant string, page Page) ([]models.ScrapeRun, Cursor, error) {
+	var runs []models.ScrapeRun
+	tx := db.Model(&models.ScrapeRun{})
+	if name != "" {
+		tx = tx.Where("name = ?", name)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if externalID != "" {
+		filter, err := json.Marshal([]map[string]string{{"external_id": externalID}})
+		if err != nil {
+			return nil, nil, err
+		}
+		tx = tx.Where("item_errors @> ?::jsonb", string(filter))
+	}
+	if tenant != "" {
+		tx = tx.Where("tenant = ?", tenant)
+	}
+	tx, err := page.paginate(tx, true, "start_time", "id")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Find(&runs).Error; err != nil {
+		return nil, nil, err
+	}
+	return runs, page.next(len(runs), func() Cursor {
+		last := runs[len(runs)-1]
+		return Cursor{last.StartTime, last.ID}
+	}), nil
+}
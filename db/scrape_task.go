@@ -0,0 +1,130 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnqueueScrapeTasks queues the tasks, tasks of a scraper and region that are still pending are not queued again
+func EnqueueScrapeTasks(tasks []models.ScrapeTask) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, task := range tasks {
+			var pending int64
+			err := tx.Model(&models.ScrapeTask{}).
+				Where("name = ? AND region = ? AND status = ?", task.Name, task.Region, models.ScrapeTaskPending).
+				Count(&pending).Error
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				continue
+			}
+			task.ID = ulid.MustNew().AsUUID()
+			task.Status = models.ScrapeTaskPending
+			task.NotBefore = time.Now()
+			if err := tx.Create(&task).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimScrapeTask marks the oldest pending task as running by the worker, concurrent workers never claim
+// the same task. It returns nil when there are no tasks due
+func ClaimScrapeTask(worker string) (*models.ScrapeTask, error) {
+	var task models.ScrapeTask
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND not_before <= ?", models.ScrapeTaskPending, time.Now()).
+			Order("not_before").
+			Limit(1).
+			Find(&task)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		now := time.Now()
+		task.Status = models.ScrapeTaskRunning
+		task.Worker = worker
+		task.ClaimedAt = &now
+		task.Attempts++
+		return tx.Save(&task).Error
+	})
+	if err != nil || task.ID == "" {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ErrScrapeTaskNotClaimed is returned when a task completes after it was requeued, e.g. because it ran for longer
+// than the timeout, the outcome of the task is then left to the worker that claimed it again
+var ErrScrapeTaskNotClaimed = errors.New("scrape task is no longer claimed by the worker")
+
+// CompleteScrapeTask records the outcome of a task if it is still claimed by its worker, a failed task is retried
+// with an increasing delay and is dead after maxAttempts
+func CompleteScrapeTask(task *models.ScrapeTask, taskErr error, maxAttempts int) error {
+	task.Status = models.ScrapeTaskSucceeded
+	task.Error = ""
+	if taskErr != nil {
+		task.Error = taskErr.Error()
+		task.Status = models.ScrapeTaskPending
+		task.NotBefore = time.Now().Add(time.Duration(task.Attempts*task.Attempts) * time.Minute)
+		if task.Attempts >= maxAttempts {
+			task.Status = models.ScrapeTaskDead
+		}
+	}
+	tx := db.Model(&models.ScrapeTask{}).
+		Where("id = ? AND worker = ? AND status = ?", task.ID, task.Worker, models.ScrapeTaskRunning).
+		Updates(map[string]interface{}{"status": task.Status, "error": task.Error, "not_before": task.NotBefore})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrScrapeTaskNotClaimed
+	}
+	return nil
+}
+
+// RequeueStaleScrapeTasks returns the tasks that have been running for longer than the timeout to the queue,
+// their worker is assumed to have stopped
+func RequeueStaleScrapeTasks(timeout time.Duration) (int64, error) {
+	tx := db.Model(&models.ScrapeTask{}).
+		Where("status = ? AND claimed_at < ?", models.ScrapeTaskRunning, time.Now().Add(-timeout)).
+		Updates(map[string]interface{}{"status": models.ScrapeTaskPending, "worker": "", "not_before": time.Now()})
+	return tx.RowsAffected, tx.Error
+}
+
+// DeleteScrapeTasks deletes the succeeded and dead tasks last updated before the time
+func DeleteScrapeTasks(before time.Time) error {
+	return db.Where("status IN ? AND updated_at < ?", []string{models.ScrapeTaskSucceeded, models.ScrapeTaskDead}, before).
+		Delete(&models.ScrapeTask{}).Error
+}
+
+// GetScrapeTasks returns a page of the latest tasks, optionally filtered by scraper name and status, and the cursor
+// of the next page
+func GetScrapeTasks(name, status string, page Page) ([]models.ScrapeTask, Cursor, error) {
+	var tasks []models.ScrapeTask
+	tx := db.Model(&models.ScrapeTask{})
+	if name != "" {
+		tx = tx.Where("name = ?", name)
+	}
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	tx, err := page.paginate(tx, true, "created_at", "id")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Find(&tasks).Error; err != nil {
+		return nil, nil, err
+	}
+	return tasks, page.next(len(tasks), func() Cursor {
+		last := tasks[len(tasks)-1]
+		return Cursor{last.CreatedAt, last.ID}
+	}), nil
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 1000
+)
+
+type sortColumn struct {
+	// expression sorted by, nulls are coalesced so that every item can be compared with a cursor
+	expression string
+	// value of the expression for the item, the cursor of the next page holds the value of the last item
+	value func(ci models.ConfigItem) interface{}
+}
+
+// sortable columns of config items
+var searchSortColumns = map[string]sortColumn{
+	"name":           {"COALESCE(name, '')", func(ci models.ConfigItem) interface{} { return ci.GetName() }},
+	"config_type":    {"config_type", func(ci models.ConfigItem) interface{} { return ci.ConfigType }},
+	"external_type":  {"COALESCE(external_type, '')", func(ci models.ConfigItem) interface{} { return deref(ci.ExternalType) }},
+	"account":        {"COALESCE(account, '')", func(ci models.ConfigItem) interface{} { return deref(ci.Account) }},
+	"region":         {"COALESCE(region, '')", func(ci models.ConfigItem) interface{} { return deref(ci.Region) }},
+	"created_at":     {"created_at", func(ci models.ConfigItem) interface{} { return ci.CreatedAt }},
+	"updated_at":     {"updated_at", func(ci models.ConfigItem) interface{} { return ci.UpdatedAt }},
+	"cost_total_30d": {"COALESCE(cost_total_30d, 0)", func(ci models.ConfigItem) interface{} { return ci.CostTotal30d }},
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// searchQuery selects the config items matching all the filters of the request
+func searchQuery(request v1.SearchRequest) *gorm.DB {
+	tx := db.Model(&models.ConfigItem{}).Where("deleted_at IS NULL")
+	if request.Text != "" {
+		tx = tx.Where("to_tsvector('simple', config) @@ plainto_tsquery('simple', ?)", request.Text)
+	}
+	if request.JSONPath != "" {
+		tx = tx.Where("config @? ?::jsonpath", request.JSONPath)
+	}
+	if request.Type != "" {
+		tx = tx.Where("config_type = ?", request.Type)
+	}
+	if request.ExternalType != "" {
+		tx = tx.Where("external_type = ?", request.ExternalType)
+	}
+	if request.Account != "" {
+		tx = tx.Where("account = ?", request.Account)
+	}
+	if request.Region != "" {
+		tx = tx.Where("region = ?", request.Region)
+	}
+	if request.MinCost30d > 0 {
+		tx = tx.Where("cost_total_30d >= ?", request.MinCost30d)
+	}
+	if len(request.Tags) > 0 {
+		tx = tx.Where("tags @> ?", v1.JSONStringMap(request.Tags))
+	}
+	if request.Tenant != "" {
+		tx = tx.Where("tenant = ?", request.Tenant)
+	}
+	return tx
+}
+
+// SearchConfigItems returns the config items matching all the filters of the request
+func SearchConfigItems(request v1.SearchRequest) (*models.SearchResult, error) {
+	tx := searchQuery(request)
+
+	result := models.SearchResult{Limit: request.Limit, Offset: request.Offset}
+	if result.Limit <= 0 {
+		result.Limit = defaultSearchLimit
+	}
+	if result.Limit > maxSearchLimit {
+		result.Limit = maxSearchLimit
+	}
+	if err := tx.Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count config items: %v", err)
+	}
+
+	sortBy := "name"
+	if request.SortBy != "" {
+		if _, ok := searchSortColumns[request.SortBy]; !ok {
+			return nil, fmt.Errorf("cannot sort by %s", request.SortBy)
+		}
+		sortBy = request.SortBy
+	}
+	sort := searchSortColumns[sortBy]
+
+	if len(request.Fields) > 0 {
+		columns, err := configColumns(request.Fields)
+		if err != nil {
+			return nil, err
+		}
+		// the cursor of the next page needs the id and the sorted column
+		tx = tx.Select(append(columns, "id", sortBy))
+	}
+
+	cursor, err := ParseCursor(request.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	page := Page{Limit: result.Limit, Offset: result.Offset, Cursor: cursor}
+	tx, err = page.paginate(tx, strings.EqualFold(request.Order, "desc"), sort.expression, "id")
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Find(&result.Results).Error; err != nil {
+		return nil, fmt.Errorf("failed to search config items: %v", err)
+	}
+	result.NextCursor = page.next(len(result.Results), func() Cursor {
+		last := result.Results[len(result.Results)-1]
+		return Cursor{sort.value(last), last.ID}
+	}).String()
+	return &result, nil
+}
+
+// configColumns returns the columns of the config item fields, an error when one of them is not a column
+func configColumns(fields []string) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&models.ConfigItem{}); err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, field := range fields {
+		if _, ok := stmt.Schema.FieldsByDBName[field]; !ok {
+			return nil, fmt.Errorf("unknown field %s", field)
+		}
+		columns = append(columns, field)
+	}
+	return columns, nil
+}
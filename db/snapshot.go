@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/flanksource/config-db/db/models"
+)
+
+// ErrHistoryPruned is returned for snapshots before changes that were deleted by retention
+var ErrHistoryPruned = errors.New("the changes of the config item at that time were deleted by retention")
+
+// checkRetention refuses snapshots older than the change retention, as the changes after them may be deleted
+func checkRetention(at time.Time) error {
+	if ChangeRetentionDays > 0 && at.Before(time.Now().AddDate(0, 0, -ChangeRetentionDays)) {
+		return fmt.Errorf("%w, changes are kept for %d days", ErrHistoryPruned, ChangeRetentionDays)
+	}
+	return nil
+}
+
+// GetConfigSnapshot reconstructs the config item as it was at the given time by applying the reverse patches
+// of the diffs recorded after it, newest first
+func GetConfigSnapshot(id string, at time.Time) (*models.ConfigItem, error) {
+	if err := checkRetention(at); err != nil {
+		return nil, err
+	}
+	var ci models.ConfigItem
+	err := db.Where("id = ? AND created_at <= ? AND (deleted_at IS NULL OR deleted_at > ?)", id, at, at).First(&ci).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := applyReversePatches(&ci, at); err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+// GetConfigSnapshots reconstructs a page of the config items of a type and/or account that existed at the given time,
+// restricted to the items of the tenant when it is set, and returns the cursor of the next page
+func GetConfigSnapshots(configType, account, tenant string, at time.Time, page Page) ([]models.ConfigItem, Cursor, error) {
+	if err := checkRetention(at); err != nil {
+		return nil, nil, err
+	}
+	query := db.Where("created_at <= ? AND (deleted_at IS NULL OR deleted_at > ?)", at, at)
+	if configType != "" {
+		query = query.Where("config_type = ?", configType)
+	}
+	if account != "" {
+		query = query.Where("account = ?", account)
+	}
+	if tenant != "" {
+		query = query.Where("tenant = ?", tenant)
+	}
+	query, err := page.paginate(query, false, "config_type", "COALESCE(name, '')", "id")
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []models.ConfigItem
+	if err := query.Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	for i := range items {
+		if err := applyReversePatches(&items[i], at); err != nil {
+			return nil, nil, err
+		}
+	}
+	return items, page.next(len(items), func() Cursor {
+		last := items[len(items)-1]
+		return Cursor{last.ConfigType, last.GetName(), last.ID}
+	}), nil
+}
+
+func applyReversePatches(ci *models.ConfigItem, at time.Time) error {
+	if ci.Config == nil {
+		return nil
+	}
+	if ci.ChangesPrunedAt != nil && at.Before(*ci.ChangesPrunedAt) {
+		return fmt.Errorf("%w: changes of %s up to %s were deleted", ErrHistoryPruned, ci, ci.ChangesPrunedAt.Format(time.RFC3339))
+	}
+	var changes []models.ConfigChange
+	err := db.Select("id, reverse_patches, created_at").
+		Where("config_id = ? AND change_type = ? AND created_at > ?", ci.ID, "diff", at).
+		Order("created_at DESC").
+		Find(&changes).Error
+	if err != nil {
+		return err
+	}
+
+	config := []byte(*ci.Config)
+	for _, change := range changes {
+		if change.ReversePatches == "" {
+			return fmt.Errorf("change %s of %s was recorded without a reverse patch, the config before it cannot be reconstructed", change.ID, ci)
+		}
+		patch, err := jsonpatch.DecodePatch([]byte(change.ReversePatches))
+		if err != nil {
+			return fmt.Errorf("invalid reverse patch of change %s: %v", change.ID, err)
+		}
+		if config, err = patch.Apply(config); err != nil {
+			return fmt.Errorf("failed to apply reverse patch of change %s: %v", change.ID, err)
+		}
+	}
+	snapshot := string(config)
+	ci.Config = &snapshot
+	return nil
+}
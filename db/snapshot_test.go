@@ -0,0 +1,27 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/config-db/db/models"
+)
+
+func TestSnapshotHistoryPruned(t *testing.T) {
+	defer func(days int) { ChangeRetentionDays = days }(ChangeRetentionDays)
+	ChangeRetentionDays = 7
+	if err := checkRetention(time.Now().AddDate(0, 0, -8)); !errors.Is(err, ErrHistoryPruned) {
+		t.Errorf("expected a snapshot older than the retention to be refused, got %v", err)
+	}
+	if err := checkRetention(time.Now().AddDate(0, 0, -6)); err != nil {
+		t.Errorf("expected a snapshot within the retention, got %v", err)
+	}
+
+	config := "{}"
+	pruned := time.Now().Add(-time.Hour)
+	ci := models.ConfigItem{ID: "id", Config: &config, ChangesPrunedAt: &pruned}
+	if err := applyReversePatches(&ci, pruned.Add(-time.Minute)); !errors.Is(err, ErrHistoryPruned) {
+		t.Errorf("expected a snapshot before the pruned changes to be refused, got %v", err)
+	}
+}
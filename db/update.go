@@ -1,16 +1,25 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
-	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db/models"
 	"github.com/flanksource/config-db/db/ulid"
+	"github.com/flanksource/config-db/events"
+	"github.com/flanksource/config-db/metrics"
+	"github.com/flanksource/config-db/notifications"
 	"github.com/lib/pq"
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
+	"gomodules.xyz/jsonpatch/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -23,19 +32,45 @@ func deleteChangeHandler(ctx *v1.ScrapeContext, change v1.ChangeResult) error {
 		deletedAt = gorm.Expr("NOW()")
 	}
 	configs := []models.ConfigItem{}
-	tx := db.Model(&configs).
-		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
-		Where("external_type = ? and external_id  @> ?", change.ExternalType, pq.StringArray{change.ExternalID}).
-		Update("deleted_at", deletedAt)
-
-	if tx.Error != nil {
-		return errors.Wrapf(tx.Error, "unable to delete config item %s/%s", change.ExternalType, change.ExternalID)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&configs).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+			Where("external_type = ? and external_id  @> ? and tenant = ?", change.ExternalType, pq.StringArray{change.ExternalID}, ctx.Tenant).
+			Update("deleted_at", deletedAt)
+		if result.Error != nil {
+			return errors.Wrapf(result.Error, "unable to delete config item %s/%s", change.ExternalType, change.ExternalID)
+		}
+		if result.RowsAffected == 0 || len(configs) == 0 {
+			return nil
+		}
+		var audit []models.AuditLog
+		for _, ci := range configs {
+			ci.ExternalType = &change.ExternalType
+			ci.ExternalID = pq.StringArray{change.ExternalID}
+			ci.Source = &change.Source
+			audit = append(audit, newAuditLog(ctx, models.AuditDeleted, ci, change.Summary))
+		}
+		return errors.Wrap(saveAuditLog(tx, audit...), "failed to save the audit log")
+	})
+	if err != nil {
+		return err
 	}
-	if tx.RowsAffected == 0 || len(configs) == 0 {
+	if len(configs) == 0 {
 		logger.Warnf("Attempt to delete non-existent config item %s/%s", change.ExternalType, change.ExternalID)
 		return nil
 	}
 
+	metrics.ConfigItems.WithLabelValues(change.ExternalType, "deleted").Add(float64(len(configs)))
+	for _, ci := range configs {
+		events.Publish(events.Event{
+			Type:         events.ConfigDeleted,
+			ConfigID:     ci.ID,
+			ExternalType: change.ExternalType,
+			ExternalID:   []string{change.ExternalID},
+			Tenant:       ctx.Tenant,
+			Summary:      change.Summary,
+		})
+	}
 	logger.Infof("Deleted %s from change %s", configs[0].ID, change)
 	return nil
 }
@@ -58,9 +93,9 @@ func getConfigItemParentID(id string) string {
 	return *ci.ParentID
 }
 
-func getParentPath(parentExternalUID v1.ExternalID) string {
+func getParentPath(parentExternalUID v1.ExternalID, tenant string) string {
 	var path string
-	parentID, _ := FindConfigItemID(parentExternalUID)
+	parentID, _ := FindConfigItemID(parentExternalUID, tenant)
 	if parentID == nil {
 		return ""
 	}
@@ -77,37 +112,26 @@ func getParentPath(parentExternalUID v1.ExternalID) string {
 	return path
 }
 
-func updateCI(ctx *v1.ScrapeContext, ci models.ConfigItem) error {
-	existing, err := GetConfigItem(*ci.ExternalType, ci.ID)
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return errors.Wrapf(err, "unable to lookup existing config: %s", ci)
-	}
-	if existing == nil {
-		ci.ID = ulid.MustNew().AsUUID()
-		if err := CreateConfigItem(&ci); err != nil {
-			logger.Errorf("[%s] failed to create item %v", ci, err)
-		}
-		return nil
-	}
-
-	ci.ID = existing.ID
-	if err := UpdateConfigItem(&ci); err != nil {
-		if err := CreateConfigItem(&ci); err != nil {
-			return fmt.Errorf("[%s] failed to update item %v", ci, err)
-		}
+// configEvent returns the event of a config item that was created or updated with the changes
+func configEvent(eventType string, ci models.ConfigItem, changes *models.ConfigChange) events.Event {
+	event := events.Event{
+		Type:         eventType,
+		ConfigID:     ci.ID,
+		ConfigType:   ci.ConfigType,
+		ExternalType: *ci.ExternalType,
+		ExternalID:   ci.ExternalID,
+		Name:         ci.GetName(),
+		Tenant:       ci.Tenant,
 	}
-	changes, err := generateDiff(ci, *existing)
-	if err != nil {
-		logger.Errorf("[%s] failed to check for changes: %v", ci, err)
+	if ci.Config != nil {
+		event.Config = json.RawMessage(*ci.Config)
 	}
-
 	if changes != nil {
-		logger.Infof("[%s/%s] detected changes", ci.ConfigType, ci.ExternalID[0])
-		if err := db.Create(changes).Error; err != nil {
-			logger.Errorf("[%s] failed to update with changes %v", ci, err)
-		}
+		event.ChangeType = changes.ChangeType
+		event.Summary = changes.Summary
+		event.Patches = changes.Patches
 	}
-	return nil
+	return event
 }
 
 func updateChange(ctx *v1.ScrapeContext, result *v1.ScrapeResult) error {
@@ -126,7 +150,7 @@ func updateChange(ctx *v1.ScrapeContext, result *v1.ScrapeResult) error {
 
 		change := models.NewConfigChangeFromV1(*result, change)
 
-		id, err := FindConfigItemID(change.GetExternalID())
+		id, err := FindConfigItemID(change.GetExternalID(), ctx.Tenant)
 		if id == nil {
 			logger.Warnf("[%s/%s] unable to find config item for change: %v", change.ExternalType, change.ExternalID, change.ChangeType)
 			return nil
@@ -136,16 +160,53 @@ func updateChange(ctx *v1.ScrapeContext, result *v1.ScrapeResult) error {
 
 		change.ConfigID = *id
 
-		if err := db.Create(change).Error; err != nil {
+		entry := newAuditLog(ctx, models.AuditChanged, models.ConfigItem{ID: change.ConfigID, ExternalType: &change.ExternalType, ExternalID: pq.StringArray{change.ExternalID}, Source: &change.Source}, change.ChangeType+": "+change.Summary)
+		var created bool
+		err = db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Create(change)
+			if result.Error != nil {
+				return result.Error
+			}
+			// changes with the id of a saved change are ignored, e.g. events that are received again
+			if created = result.RowsAffected > 0; !created {
+				return nil
+			}
+			return errors.Wrap(saveAuditLog(tx, entry), "failed to save the audit log")
+		})
+		if err != nil {
 			return err
 		}
+		if !created {
+			continue
+		}
+		recordChanges(*change)
+		metrics.ConfigChanges.WithLabelValues(change.ExternalType).Inc()
+		events.Publish(events.Event{
+			Type:         events.ConfigChanged,
+			ConfigID:     change.ConfigID,
+			ExternalType: change.ExternalType,
+			ExternalID:   []string{change.ExternalID},
+			Tenant:       ctx.Tenant,
+			ChangeType:   change.ChangeType,
+			Severity:     change.Severity,
+			Summary:      change.Summary,
+			Patches:      change.Patches,
+		})
+		notifications.Notify(notifications.Event{
+			Event:        notifications.EventChange,
+			ConfigID:     change.ConfigID,
+			ExternalType: change.ExternalType,
+			ChangeType:   change.ChangeType,
+			Severity:     change.Severity,
+			Summary:      change.Summary,
+		})
 	}
 	return nil
 }
 
 func updateAnalysis(ctx *v1.ScrapeContext, result *v1.ScrapeResult) error {
 	analysis := models.NewAnalysisFromV1(*result.AnalysisResult)
-	ci, err := GetConfigItem(analysis.ExternalType, analysis.ExternalID)
+	ci, err := GetConfigItem(analysis.ExternalType, analysis.ExternalID, ctx.Tenant)
 	if ci == nil {
 		logger.Warnf("[%s/%s] unable to find config item for analysis: %+v", analysis.ExternalType, analysis.ExternalID, analysis)
 		return nil
@@ -157,70 +218,202 @@ func updateAnalysis(ctx *v1.ScrapeContext, result *v1.ScrapeResult) error {
 	analysis.ConfigID = ci.ID
 	analysis.ID = ulid.MustNew().AsUUID()
 
-	return CreateAnalysis(analysis)
-
+	changed, err := CreateAnalysis(analysis)
+	if err != nil || !changed {
+		return err
+	}
+	if err := saveAuditLog(db, newAuditLog(ctx, models.AuditAnalyzed, *ci, analysis.Analyzer+": "+analysis.Status)); err != nil {
+		return errors.Wrap(err, "failed to save the audit log")
+	}
+	notifications.Notify(notifications.Event{
+		Event:        notifications.EventAnalysis,
+		ConfigID:     ci.ID,
+		ConfigType:   ci.ConfigType,
+		ExternalType: analysis.ExternalType,
+		Name:         ci.GetName(),
+		ChangeType:   analysis.AnalysisType,
+		Analyzer:     analysis.Analyzer,
+		Severity:     analysis.Severity,
+		Summary:      analysis.Summary,
+	})
+	return nil
 }
 
-// SaveResults creates or update a configuartion with config changes
+// SaveResults creates or update a configuartion with config changes, when the database becomes
+// unavailable the remaining results are buffered if a buffer is configured. The after save hooks of the context
+// are run once the results are saved or buffered
 func SaveResults(ctx *v1.ScrapeContext, results []v1.ScrapeResult) error {
-	for _, result := range results {
+	err := saveOrBufferResults(ctx, results)
+	if err == nil {
+		ctx.AfterSave.Run()
+	}
+	return err
+}
 
-		if result.Config != nil {
-			ci, err := NewConfigItemFromResult(result)
-			if err != nil {
-				return errors.Wrapf(err, "unable to create config item: %s", result)
-			}
+func saveOrBufferResults(ctx *v1.ScrapeContext, results []v1.ScrapeResult) error {
+	defer metrics.Since(metrics.DBWriteDuration.WithLabelValues("save_results"), time.Now())
+	if buffer != nil {
+		// buffered results are older and must be saved first
+		if err := flushBuffer(ctx); err != nil {
+			logger.Warnf("database is unavailable, buffering %d results: %v", len(results), err)
+			return bufferResults(ctx, results)
+		}
+	}
+	saved, err := saveResults(ctx, results)
+	if err != nil && buffer != nil && Ping() != nil {
+		logger.Warnf("database is unavailable, buffering %d results: %v", len(results)-saved, err)
+		return bufferResults(ctx, results[saved:])
+	}
+	return err
+}
 
-			if err := updateCI(ctx, *ci); err != nil {
-				return err
-			}
+// saveResults returns the number of results saved before an error, the config items of every
+// chunk of BatchSize results are upserted in a transaction before saving the analysis, changes and relationships
+func saveResults(ctx *v1.ScrapeContext, results []v1.ScrapeResult) (int, error) {
+	for start := 0; start < len(results); {
+		end := chunkEnd(results, start)
+		chunk := results[start:end]
+		if err := saveConfigItems(ctx, chunk); err != nil {
+			return start, err
 		}
 
-		if result.AnalysisResult != nil {
-			if err := updateAnalysis(ctx, &result); err != nil {
-				return err
+		for _, result := range chunk {
+			if result.AnalysisResult != nil {
+				if err := updateAnalysis(ctx, &result); err != nil {
+					return start, err
+				}
 			}
-		}
 
-		if err := updateChange(ctx, &result); err != nil {
-			return err
-		}
+			if err := updateChange(ctx, &result); err != nil {
+				return start, err
+			}
 
-		if result.RelationshipResults != nil {
-			if err := relationshipResultHandler(result.RelationshipResults); err != nil {
-				return err
+			if result.RelationshipResults != nil {
+				if err := relationshipResultHandler(ctx, result.RelationshipResults); err != nil {
+					return start, err
+				}
 			}
 		}
+		start = end
 	}
 
-	return nil
+	return len(results), nil
+}
+
+// chunkEnd returns the end of the chunk starting at start, a chunk has at most BatchSize config items
+// and ends before a config item that is already in it, as a row can only be upserted once per statement
+func chunkEnd(results []v1.ScrapeResult, start int) int {
+	size := BatchSize
+	if size < 1 {
+		size = 1
+	}
+	seen := make(map[string]bool)
+	end := start
+	for ; end < len(results); end++ {
+		result := results[end]
+		if result.Config == nil {
+			continue
+		}
+		key := result.ExternalType + "/" + result.ID
+		if len(seen) == size || seen[key] {
+			break
+		}
+		seen[key] = true
+	}
+	return end
 }
 
-func generateDiff(a, b models.ConfigItem) (*models.ConfigChange, error) {
-	patch, err := jsonpatch.CreateMergePatch([]byte(*a.Config), []byte(*b.Config))
+// generateDiff returns a change with the RFC 6902 JSON patch from the existing config b to the new config a
+// and the reverse patch from a to b, fields matching the change exclusions are not compared
+func generateDiff(a, b models.ConfigItem, changeExclusions []string) (*models.ConfigChange, error) {
+	before, after := []byte(*b.Config), []byte(*a.Config)
+	if len(changeExclusions) > 0 {
+		var err error
+		if before, err = excludeChangeFields(*b.Config, changeExclusions); err != nil {
+			return nil, err
+		}
+		if after, err = excludeChangeFields(*a.Config, changeExclusions); err != nil {
+			return nil, err
+		}
+	}
+
+	operations, err := jsonpatch.CreatePatch(before, after)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(patch) <= 2 { // no patch or empty array
+	if len(operations) == 0 {
 		return nil, nil
 	}
+	sort.Sort(jsonpatch.ByPath(operations))
 
-	patchStr := string(patch)
+	patch, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reverse patch restores the previous config, it is used to reconstruct past snapshots
+	reverse, err := jsonpatch.CreatePatch(after, before)
+	if err != nil {
+		return nil, err
+	}
+	reversePatch, err := json.Marshal(reverse)
+	if err != nil {
+		return nil, err
+	}
 
 	return &models.ConfigChange{
-		ConfigID:   a.ID,
-		ChangeType: "diff",
-		ID:         ulid.MustNew().AsUUID(),
-		Patches:    patchStr,
+		ConfigID:       a.ID,
+		ChangeType:     "diff",
+		ID:             ulid.MustNew().AsUUID(),
+		Patches:        string(patch),
+		ReversePatches: string(reversePatch),
+		Summary:        patchSummary(operations),
 	}, nil
 
 }
 
-func relationshipResultHandler(relationships v1.RelationshipResults) error {
+// excludeChangeFields removes the fields matching the exclusions from the config
+func excludeChangeFields(config string, exclusions []string) ([]byte, error) {
+	obj, err := oj.ParseString(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, exclusion := range exclusions {
+		expr, err := jp.ParseString(exclusion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse change exclusion %s: %v", exclusion, err)
+		}
+		if err := expr.Del(obj); err != nil {
+			return nil, fmt.Errorf("failed to apply change exclusion %s: %v", exclusion, err)
+		}
+	}
+	return []byte(oj.JSON(obj)), nil
+}
+
+const maxSummaryPaths = 5
+
+// patchSummary describes the fields changed by a patch, e.g. "replaced /spec/replicas, added /metadata/labels/app"
+func patchSummary(operations []jsonpatch.Operation) string {
+	var parts []string
+	for _, op := range operations {
+		if len(parts) == maxSummaryPaths {
+			parts = append(parts, fmt.Sprintf("and %d more", len(operations)-maxSummaryPaths))
+			break
+		}
+		verb := op.Operation + "d"
+		if op.Operation == "add" {
+			verb = "added"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", verb, op.Path))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func relationshipResultHandler(ctx *v1.ScrapeContext, relationships v1.RelationshipResults) error {
 	var configItemRelationships []models.ConfigRelationship
 	for _, relationship := range relationships {
-		configID, err := FindConfigItemID(relationship.ConfigExternalID)
+		configID, err := FindConfigItemID(relationship.ConfigExternalID, ctx.Tenant)
 		if err != nil {
 			logger.Errorf("Error fetching config item id: %v", err)
 			continue
@@ -230,7 +423,7 @@ func relationshipResultHandler(relationships v1.RelationshipResults) error {
 			continue
 		}
 
-		relatedID, err := FindConfigItemID(relationship.RelatedExternalID)
+		relatedID, err := FindConfigItemID(relationship.RelatedExternalID, ctx.Tenant)
 		if err != nil {
 			logger.Errorf("Error fetching config item id: %v", err)
 			continue
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/events"
+	"github.com/google/uuid"
+)
+
+func TestUpdateChangeIsIdempotent(t *testing.T) {
+	setupTestDB(t)
+	suffix := uuid.New().String()
+	ctx := &v1.ScrapeContext{Context: context.Background()}
+	client, queue := clickHouse, clickHouseQueue
+	clickHouse, clickHouseQueue = &clickHouseClient{}, make(chan clickHouseChange, 10)
+	t.Cleanup(func() { clickHouse, clickHouseQueue = client, queue })
+	published, unsubscribe := events.Subscribe(10)
+	defer unsubscribe()
+	pod := v1.ScrapeResult{Type: "Pod", ExternalType: "Kubernetes::Pod", ID: "pod-" + suffix, Config: map[string]string{}}
+	if err := saveConfigItems(ctx, []v1.ScrapeResult{pod}); err != nil {
+		t.Fatalf("failed to save the pod: %v", err)
+	}
+
+	result := v1.ScrapeResult{Changes: []v1.ChangeResult{{
+		ExternalType:     pod.ExternalType,
+		ExternalID:       pod.ID,
+		ExternalChangeID: "event-" + suffix,
+		ChangeType:       "Killing",
+		Summary:          "Stopping container",
+	}}}
+	for i := 0; i < 2; i++ {
+		if err := updateChange(ctx, &result); err != nil {
+			t.Fatalf("failed to save the change: %v", err)
+		}
+	}
+
+	var changes int64
+	if err := db.Model(&models.ConfigChange{}).Where("external_change_id = ?", "event-"+suffix).Count(&changes).Error; err != nil {
+		t.Fatalf("failed to count the changes: %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("expected the change to be saved once, got %d", changes)
+	}
+	var audit int64
+	if err := db.Model(&models.AuditLog{}).Where("action = ? AND ? = ANY(external_id)", models.AuditChanged, pod.ID).Count(&audit).Error; err != nil {
+		t.Fatalf("failed to count the audit log: %v", err)
+	}
+	if audit != 1 {
+		t.Errorf("expected the change to be audited once, got %d", audit)
+	}
+	if len(clickHouseQueue) != 1 {
+		t.Errorf("expected the change to be written to ClickHouse once, got %d", len(clickHouseQueue))
+	}
+	var changed int
+	for len(published) > 0 {
+		if event := <-published; event.Type == events.ConfigChanged && event.ExternalID[0] == pod.ID {
+			changed++
+		}
+	}
+	if changed != 1 {
+		t.Errorf("expected 1 changed event, got %d", changed)
+	}
+}
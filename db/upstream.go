@@ -0,0 +1,75 @@
+package db
+
+import (
+	"time"
+
+	"github.com/flanksource/config-db/db/models"
+)
+
+// zeroUUID sorts before every config item id
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// GetConfigItemsUpdatedSince returns the next page of config items that are not deleted, ordered by updated_at and id,
+// after the item with the updated_at and id of the previous page
+func GetConfigItemsUpdatedSince(since time.Time, afterID string, limit int) ([]models.ConfigItem, error) {
+	if afterID == "" {
+		afterID = zeroUUID
+	}
+	var items []models.ConfigItem
+	err := db.Where("deleted_at IS NULL AND updated_at >= ? AND (updated_at > ? OR id > ?)", since, since, afterID).
+		Order("updated_at, id").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// DeletedConfigItem is a config item that was soft deleted
+type DeletedConfigItem struct {
+	ID           string
+	ExternalType string
+	ExternalID   string
+	DeletedAt    time.Time
+}
+
+// GetConfigItemsDeletedSince returns the next page of config items deleted after the item with the deleted_at and id
+// of the previous page, with the last of their external ids
+func GetConfigItemsDeletedSince(since time.Time, afterID string, limit int) ([]DeletedConfigItem, error) {
+	if afterID == "" {
+		afterID = zeroUUID
+	}
+	var items []DeletedConfigItem
+	err := db.Raw(`SELECT id, COALESCE(external_type, '') AS external_type, external_id[array_upper(external_id, 1)] AS external_id, deleted_at
+		FROM config_items WHERE deleted_at >= ? AND (deleted_at > ? OR id > ?) ORDER BY deleted_at, id LIMIT ?`, since, since, afterID, limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// ItemChange is a config change with the external type and the last external id of its config item
+type ItemChange struct {
+	models.ConfigChange
+	ConfigExternalType string
+	ConfigExternalID   string
+}
+
+// GetConfigChangesSince returns the next page of config changes, ordered by created_at and id, after the change
+// with the created_at and id of the previous page
+func GetConfigChangesSince(since time.Time, afterID string, limit int) ([]ItemChange, error) {
+	if afterID == "" {
+		afterID = zeroUUID
+	}
+	var changes []ItemChange
+	err := db.Raw(`SELECT config_changes.*, COALESCE(config_items.external_type, '') AS config_external_type,
+		config_items.external_id[array_upper(config_items.external_id, 1)] AS config_external_id
+		FROM config_changes JOIN config_items ON config_items.id = config_changes.config_id
+		WHERE config_changes.created_at >= ? AND (config_changes.created_at > ? OR config_changes.id > ?)
+		ORDER BY config_changes.created_at, config_changes.id LIMIT ?`, since, since, afterID, limit).Scan(&changes).Error
+	return changes, err
+}
+
+// GetRelationshipsFrom returns the relationships from the config items
+func GetRelationshipsFrom(ids []string) ([]models.ConfigRelationship, error) {
+	var relationships []models.ConfigRelationship
+	if len(ids) == 0 {
+		return relationships, nil
+	}
+	err := db.Where("config_id IN ?", ids).Find(&relationships).Error
+	return relationships, err
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"encoding/json"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm/clause"
+)
+
+func GetViews() ([]v1.View, error) {
+	var rows []models.View
+	if err := db.Order("name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var views []v1.View
+	for _, row := range rows {
+		var view v1.View
+		if err := json.Unmarshal([]byte(row.Spec), &view); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// GetView returns the view with the name, or nil when it does not exist
+func GetView(name string) (*v1.View, error) {
+	var rows []models.View
+	if err := db.Where("name = ?", name).Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	var view v1.View
+	err := json.Unmarshal([]byte(rows[0].Spec), &view)
+	return &view, err
+}
+
+func PersistView(view v1.View) error {
+	spec, err := json.Marshal(view)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.View{Name: view.Name, Spec: string(spec)}).Error
+}
+
+func DeleteView(name string) error {
+	return db.Delete(&models.View{Name: name}).Error
+}
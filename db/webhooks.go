@@ -0,0 +1,37 @@
+package db
+
+import (
+	"encoding/json"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db/models"
+	"gorm.io/gorm/clause"
+)
+
+func GetWebhooks() ([]v1.Webhook, error) {
+	var rows []models.Webhook
+	if err := db.Order("name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var webhooks []v1.Webhook
+	for _, row := range rows {
+		var webhook v1.Webhook
+		if err := json.Unmarshal([]byte(row.Spec), &webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func PersistWebhook(webhook v1.Webhook) error {
+	spec, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.Webhook{Name: webhook.Name, Spec: string(spec)}).Error
+}
+
+func DeleteWebhook(name string) error {
+	return db.Delete(&models.Webhook{Name: name}).Error
+}
@@ -0,0 +1,242 @@
+// Package events streams config item events to external systems such as Kafka or NATS.
+//
+// Every message is a JSON encoded Event:
+//
+//	{
+//	  "id": "0185c2a6-...",            // unique id of the event
+//	  "type": "config.updated",        // config.created, config.updated, config.deleted or config.changed
+//	  "time": "2023-01-02T15:04:05Z",
+//	  "config_id": "0185c2a5-...",     // id of the config item in config-db
+//	  "config_type": "EC2Instance",
+//	  "external_type": "AWS::EC2::Instance",
+//	  "external_id": ["i-0123"],
+//	  "name": "web-1",
+//	  "tenant": "team-a",              // tenant of the config item, if any
+//	  "change_type": "diff",           // config.changed and config.updated only
+//	  "severity": "high",              // config.changed only
+//	  "summary": "replaced /State/Name",
+//	  "patches": "[{...}]",            // RFC 6902 patch of the change, if any
+//	  "config": {...}                  // config.created and config.updated only
+//	}
+//
+// Messages are keyed by the config id, so the events of an item are kept in order on a partitioned topic.
+//
+// The events are also delivered to the subscribers of this process, e.g. the clients of GET /changes/stream.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/config-db/db/ulid"
+	"github.com/spf13/pflag"
+)
+
+const (
+	ConfigCreated = "config.created"
+	ConfigUpdated = "config.updated"
+	ConfigDeleted = "config.deleted"
+	ConfigChanged = "config.changed"
+)
+
+// Event is the message published to the sinks
+type Event struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Time         time.Time       `json:"time"`
+	ConfigID     string          `json:"config_id"`
+	ConfigType   string          `json:"config_type,omitempty"`
+	ExternalType string          `json:"external_type,omitempty"`
+	ExternalID   []string        `json:"external_id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Tenant       string          `json:"tenant,omitempty"`
+	ChangeType   string          `json:"change_type,omitempty"`
+	Severity     string          `json:"severity,omitempty"`
+	Summary      string          `json:"summary,omitempty"`
+	Patches      string          `json:"patches,omitempty"`
+	Config       json.RawMessage `json:"config,omitempty"`
+}
+
+// Sink publishes batches of events
+type Sink interface {
+	Publish(events []Event) error
+	Close() error
+}
+
+// SinkURLs are the sinks events are published to, e.g. kafka://broker-1:9092,broker-2:9092/topic or nats://nats:4222/subject
+var SinkURLs []string
+
+func Flags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&SinkURLs, "event-sink", nil, "Publish config events to a sink, e.g. kafka://broker:9092/topic or nats://nats:4222/subject")
+}
+
+const (
+	queueSize     = 10000
+	batchSize     = 100
+	flushInterval = time.Second
+)
+
+var (
+	sinks []Sink
+	queue chan Event
+	done  chan struct{}
+)
+
+// Start connects to the sinks, events are dropped when no sink is configured
+func Start() error {
+	for _, url := range SinkURLs {
+		sink, err := NewSink(url)
+		if err != nil {
+			return fmt.Errorf("failed to create event sink %s: %v", url, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	queue = make(chan Event, queueSize)
+	done = make(chan struct{})
+	go publish()
+	return nil
+}
+
+// Stop closes the subscriptions, publishes the queued events and closes the sinks
+func Stop() {
+	subscribersLock.Lock()
+	for subscription := range subscribers {
+		close(subscription)
+	}
+	subscribers = nil
+	subscribersLock.Unlock()
+
+	publishLock.Lock()
+	if queue == nil {
+		publishLock.Unlock()
+		return
+	}
+	close(queue)
+	queue = nil
+	publishLock.Unlock()
+	<-done
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logger.Errorf("failed to close event sink: %v", err)
+		}
+	}
+}
+
+// NewSink returns the sink for a kafka:// or nats:// url
+func NewSink(url string) (Sink, error) {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return nil, fmt.Errorf("missing scheme")
+	}
+	hosts, topic, _ := strings.Cut(rest, "/")
+	if hosts == "" || topic == "" {
+		return nil, fmt.Errorf("expected %s://<hosts>/<topic>", scheme)
+	}
+	switch scheme {
+	case "kafka":
+		return newKafkaSink(strings.Split(hosts, ","), topic), nil
+	case "nats":
+		return newNATSSink(hosts, topic)
+	}
+	return nil, fmt.Errorf("unsupported sink %s, expected kafka or nats", scheme)
+}
+
+var publishLock sync.RWMutex
+
+var (
+	subscribers     map[chan Event]bool
+	subscribersLock sync.Mutex
+)
+
+// Subscribe returns a channel receiving the events published from now on and a function that stops the subscription.
+// Events are dropped when the subscriber is slower than the buffer allows, the channel is closed by Stop
+func Subscribe(buffer int) (<-chan Event, func()) {
+	subscription := make(chan Event, buffer)
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	if subscribers == nil {
+		subscribers = make(map[chan Event]bool)
+	}
+	subscribers[subscription] = true
+	return subscription, func() {
+		subscribersLock.Lock()
+		defer subscribersLock.Unlock()
+		if subscribers[subscription] {
+			delete(subscribers, subscription)
+			close(subscription)
+		}
+	}
+}
+
+func notifySubscribers(event Event) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	for subscription := range subscribers {
+		select {
+		case subscription <- event:
+		default:
+			logger.Debugf("subscriber is too slow, dropping %s event for %s", event.Type, event.ConfigID)
+		}
+	}
+}
+
+// Publish delivers an event to the subscribers and queues it for the sinks without blocking, the event is dropped
+// when the queue is full
+func Publish(event Event) {
+	event.ID = ulid.MustNew().AsUUID()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	notifySubscribers(event)
+
+	publishLock.RLock()
+	defer publishLock.RUnlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- event:
+	default:
+		logger.Warnf("event queue is full, dropping %s event for %s", event.Type, event.ConfigID)
+	}
+}
+
+func publish() {
+	defer close(done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	var batch []Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		for _, sink := range sinks {
+			if err := sink.Publish(batch); err != nil {
+				logger.Errorf("failed to publish %d events: %v", len(batch), err)
+			}
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case event, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+type natsSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// newNATSSink connects to a comma separated list of servers
+func newNATSSink(servers, subject string) (*natsSink, error) {
+	conn, err := nats.Connect(servers, nats.Name("config-db"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{conn: conn, subject: subject}, nil
+}
+
+func (s *natsSink) Publish(events []Event) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := s.conn.Publish(s.subject, data); err != nil {
+			return err
+		}
+	}
+	return s.conn.Flush()
+}
+
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
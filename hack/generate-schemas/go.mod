@@ -7,6 +7,8 @@ require (
 	github.com/flanksource/commons v1.6.2
 	github.com/flanksource/config-db v0.0.65
 	github.com/spf13/cobra v1.6.1
+	k8s.io/apiextensions-apiserver v0.26.0
+	sigs.k8s.io/yaml v1.3.0
 )
 
 require (
@@ -153,7 +155,6 @@ require (
 	gorm.io/gorm v1.24.3 // indirect
 	inet.af/netaddr v0.0.0-20220811202034-502d2d690317 // indirect
 	k8s.io/api v0.26.0 // indirect
-	k8s.io/apiextensions-apiserver v0.26.0 // indirect
 	k8s.io/apimachinery v0.26.0 // indirect
 	k8s.io/cli-runtime v0.24.4 // indirect
 	k8s.io/client-go v0.26.0 // indirect
@@ -166,5 +167,4 @@ require (
 	sigs.k8s.io/kustomize/api v0.12.1 // indirect
 	sigs.k8s.io/kustomize/kyaml v0.13.9 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.2.3 // indirect
-	sigs.k8s.io/yaml v1.3.0 // indirect
 )
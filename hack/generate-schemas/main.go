@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"path"
 
@@ -8,6 +9,8 @@ import (
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/spf13/cobra"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
 )
 
 var schemas = map[string]any{
@@ -31,13 +34,41 @@ var generateSchema = &cobra.Command{
 			}
 			logger.Infof("Saved OpenAPI schema to %s", p)
 		}
+		if err := generateScraperSchema(); err != nil {
+			logger.Fatalf("unable to generate scraper schema: %v", err)
+		}
 	},
 }
 
-var schemaPath string
+// generateScraperSchema saves the schema of the spec of the scrape config CRD, it is the schema of scraper files
+// and is embedded to validate them
+func generateScraperSchema() error {
+	data, err := os.ReadFile(crdPath)
+	if err != nil {
+		return err
+	}
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		return err
+	}
+	spec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	schema, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := path.Join(schemaPath, "scraper.schema.json")
+	if err := os.WriteFile(p, schema, 0644); err != nil {
+		return err
+	}
+	logger.Infof("Saved scraper schema to %s", p)
+	return nil
+}
+
+var schemaPath, crdPath string
 
 func main() {
 	generateSchema.Flags().StringVar(&schemaPath, "schema-path", "../../config/schemas", "Path to save JSON schema to")
+	generateSchema.Flags().StringVar(&crdPath, "crd-path", "../../chart/crds/configs.flanksource.com_scrapeconfigs.yaml", "Path of the scrape config CRD")
 	if err := generateSchema.Execute(); err != nil {
 		os.Exit(1)
 	}
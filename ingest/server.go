@@ -0,0 +1,200 @@
+// Package ingest serves the gRPC ingestion service for agents pushing large batches of scrape results
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+	pb "github.com/flanksource/config-db/api/ingest"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MaxConcurrentStreams limits the streams saving batches at the same time, further streams wait for a slot
+var MaxConcurrentStreams = 4
+
+const maxMessageSize = 64 * 1024 * 1024
+
+type server struct {
+	pb.UnimplementedIngestServer
+	slots chan struct{}
+}
+
+// Serve listens on port until the listener fails
+func Serve(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.StreamInterceptor(authenticate),
+	)
+	pb.RegisterIngestServer(s, &server{slots: make(chan struct{}, MaxConcurrentStreams)})
+	logger.Infof("Serving gRPC ingestion on :%d", port)
+	return s.Serve(listener)
+}
+
+// authenticate requires a bearer token with the ingest role in the authorization metadata
+func authenticate(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !query.IngestEnabled() {
+		return status.Error(codes.PermissionDenied, "ingestion is disabled, set --ingest-token or --api-token to enable it")
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	for _, auth := range md.Get("authorization") {
+		caller, err := query.Authenticate(stream.Context(), strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			continue
+		}
+		if !caller.HasRole(query.RoleIngest) {
+			return status.Errorf(codes.PermissionDenied, "%s does not have the ingest role", caller.Actor)
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), callerKey{}, caller)})
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
+
+type callerKey struct{}
+
+// authenticatedStream carries the caller in its context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// Push saves the batches of a stream in order, the next batch is only read once the previous one is acknowledged
+func (s *server) Push(stream pb.Ingest_PushServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.save(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) save(ctx context.Context, req *pb.PushRequest) (*pb.PushResponse, error) {
+	resp := &pb.PushResponse{Batch: req.Batch}
+	var results []v1.ScrapeResult
+	for i, r := range req.Results {
+		result, err := toScrapeResult(r)
+		if err == nil {
+			err = query.ValidateResult(&result)
+		}
+		if err != nil {
+			resp.Rejected++
+			resp.Errors = append(resp.Errors, fmt.Sprintf("result %d: %v", i, err))
+			continue
+		}
+		results = append(results, result)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.slots }()
+
+	scrapeCtx := &v1.ScrapeContext{Context: ctx}
+	if caller, ok := ctx.Value(callerKey{}).(*query.Caller); ok {
+		scrapeCtx.Actor, scrapeCtx.Tenant = caller.Actor, caller.Tenant
+	}
+	if err := db.SaveResults(scrapeCtx, results); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to save batch %s: %v", req.Batch, err)
+	}
+	resp.Accepted = int32(len(results))
+	return resp, nil
+}
+
+// toScrapeResult converts the result to the request of POST /config, so that pushed results are defaulted the same
+func toScrapeResult(r *pb.ScrapeResult) (v1.ScrapeResult, error) {
+	req := v1.IngestRequest{
+		ID:                 r.Id,
+		Type:               r.Type,
+		ExternalType:       r.ExternalType,
+		Name:               r.Name,
+		Namespace:          r.Namespace,
+		Account:            r.Account,
+		Region:             r.Region,
+		Zone:               r.Zone,
+		Network:            r.Network,
+		Subnet:             r.Subnet,
+		Source:             r.Source,
+		Aliases:            r.Aliases,
+		Tags:               r.Tags,
+		ParentExternalID:   r.ParentExternalId,
+		ParentExternalType: r.ParentExternalType,
+	}
+	if len(r.Config) > 0 {
+		if err := json.Unmarshal(r.Config, &req.Config); err != nil {
+			return v1.ScrapeResult{}, fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	if r.CreatedAt != nil {
+		t := r.CreatedAt.AsTime()
+		req.CreatedAt = &t
+	}
+	for _, c := range r.Changes {
+		change := v1.IngestChange{
+			ExternalChangeID: c.ExternalChangeId,
+			ChangeType:       c.ChangeType,
+			Action:           v1.ChangeAction(c.Action),
+			Severity:         c.Severity,
+			Source:           c.Source,
+			Summary:          c.Summary,
+			Patches:          c.Patches,
+		}
+		if len(c.Details) > 0 {
+			if err := json.Unmarshal(c.Details, &change.Details); err != nil {
+				return v1.ScrapeResult{}, fmt.Errorf("invalid change details: %v", err)
+			}
+		}
+		if c.CreatedAt != nil {
+			t := c.CreatedAt.AsTime()
+			change.CreatedAt = &t
+		}
+		req.Changes = append(req.Changes, change)
+	}
+	for _, rel := range r.Relationships {
+		req.Relationships = append(req.Relationships, v1.IngestRelationship{
+			RelatedExternalID:   rel.RelatedExternalId,
+			RelatedExternalType: rel.RelatedExternalType,
+			Relationship:        rel.Relationship,
+		})
+	}
+	if a := r.Analysis; a != nil {
+		req.Analysis = &v1.AnalysisResult{
+			Analyzer:     a.Analyzer,
+			AnalysisType: a.AnalysisType,
+			Severity:     a.Severity,
+			Status:       a.Status,
+			Summary:      a.Summary,
+			Messages:     a.Messages,
+		}
+	}
+	return req.ScrapeResult(), nil
+}
@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"testing"
+
+	pb "github.com/flanksource/config-db/api/ingest"
+	"github.com/flanksource/config-db/query"
+)
+
+func TestToScrapeResult(t *testing.T) {
+	result, err := toScrapeResult(&pb.ScrapeResult{
+		Id:      "web",
+		Type:    "Service",
+		Config:  []byte(`{"port": 80}`),
+		Changes: []*pb.Change{{ChangeType: "Deployed", Details: []byte(`{"version": "2"}`)}},
+		Relationships: []*pb.Relationship{
+			{RelatedExternalId: "db", RelatedExternalType: "Database", Relationship: "ServiceDatabase"},
+		},
+	})
+	if err == nil {
+		err = query.ValidateResult(&result)
+	}
+	if err != nil {
+		t.Fatalf("failed to convert the result: %v", err)
+	}
+
+	if result.ExternalType != "Service" {
+		t.Errorf("expected the external type to default to the type, got %q", result.ExternalType)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].ExternalType != "Service" || result.Changes[0].ExternalID != "web" ||
+		result.Changes[0].Details["version"] != "2" {
+		t.Errorf("expected a change of Service/web, got %+v", result.Changes)
+	}
+	if len(result.RelationshipResults) != 1 {
+		t.Fatalf("expected 1 relationship, got %d", len(result.RelationshipResults))
+	}
+	relationship := result.RelationshipResults[0]
+	if relationship.ConfigExternalID.String() != "Service/web" || relationship.RelatedExternalID.String() != "Database/db" ||
+		relationship.Relationship != "ServiceDatabase" {
+		t.Errorf("unexpected relationship %+v", relationship)
+	}
+
+	if _, err := toScrapeResult(&pb.ScrapeResult{Id: "web", Type: "Service", Config: []byte("{")}); err == nil {
+		t.Errorf("expected an invalid config to be rejected")
+	}
+}
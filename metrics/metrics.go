@@ -0,0 +1,86 @@
+// Package metrics exposes prometheus metrics about the health of the scrapers
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ScrapeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "config_db_scrape_duration_seconds",
+		Help:    "Duration of a scraper run",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"scraper", "type"})
+
+	ScrapeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_scrape_errors_total",
+		Help: "Number of errors returned by scrapers",
+	}, []string{"scraper", "type"})
+
+	ScrapeResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_scrape_results_total",
+		Help: "Number of results returned by scrapers",
+	}, []string{"scraper", "type"})
+
+	ConfigItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_config_items_total",
+		Help: "Number of config items created, updated or deleted",
+	}, []string{"type", "action"})
+
+	ConfigChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_config_changes_total",
+		Help: "Number of config changes recorded",
+	}, []string{"type"})
+
+	CostQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "config_db_cost_query_duration_seconds",
+		Help:    "Latency of cost queries",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	}, []string{"source", "status"})
+
+	DBWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "config_db_db_write_duration_seconds",
+		Help:    "Latency of database writes",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	RetentionPurged = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_retention_purged_rows_total",
+		Help: "Number of rows deleted by the retention policy",
+	}, []string{"table"})
+
+	Notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_db_notifications_total",
+		Help: "Number of webhook notifications sent",
+	}, []string{"webhook", "status"})
+
+	BufferedResults = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "config_db_buffered_results",
+		Help: "Number of results buffered while the database is unavailable",
+	})
+
+	BufferDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "config_db_buffer_dropped_results_total",
+		Help: "Number of results dropped because the buffer is full or they failed to save",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(ScrapeDuration, ScrapeErrors, ScrapeResults, ConfigItems, ConfigChanges, CostQueryDuration, DBWriteDuration, RetentionPurged, Notifications,
+		BufferedResults, BufferDropped)
+}
+
+// Since observes the seconds elapsed since start
+func Since(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+// Status returns the status label of an operation
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
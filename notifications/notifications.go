@@ -0,0 +1,104 @@
+// Package notifications posts config changes and analysis results to the registered webhooks
+package notifications
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils"
+)
+
+const (
+	EventChange   = "change"
+	EventAnalysis = "analysis"
+)
+
+// Event is the payload posted to webhooks in the json format
+type Event struct {
+	// Event is either change or analysis
+	Event        string    `json:"event"`
+	ConfigID     string    `json:"config_id"`
+	ConfigType   string    `json:"config_type,omitempty"`
+	ExternalType string    `json:"external_type,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	ChangeType   string    `json:"change_type,omitempty"`
+	Analyzer     string    `json:"analyzer,omitempty"`
+	Severity     string    `json:"severity,omitempty"`
+	Summary      string    `json:"summary,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+// Webhooks loads the registered webhooks, notifications are disabled until it is set
+var Webhooks func() ([]v1.Webhook, error)
+
+const (
+	queueSize     = 1000
+	cacheDuration = time.Minute
+)
+
+var (
+	queue = make(chan Event, queueSize)
+	start sync.Once
+
+	cacheLock sync.Mutex
+	cached    []v1.Webhook
+	cachedAt  time.Time
+)
+
+// Notify queues an event for the webhooks, it never blocks the caller and drops the event when the queue is full
+func Notify(event Event) {
+	if Webhooks == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	start.Do(func() { go dispatch() })
+	select {
+	case queue <- event:
+	default:
+		logger.Warnf("notification queue is full, dropping %s event for %s", event.Event, event.ConfigID)
+	}
+}
+
+// Refresh reloads the webhooks on the next event, called after they are modified
+func Refresh() {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cachedAt = time.Time{}
+}
+
+func getWebhooks() []v1.Webhook {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if time.Since(cachedAt) < cacheDuration {
+		return cached
+	}
+	webhooks, err := Webhooks()
+	if err != nil {
+		logger.Errorf("failed to load webhooks: %v", err)
+		return cached
+	}
+	cached, cachedAt = webhooks, time.Now()
+	return cached
+}
+
+func dispatch() {
+	for event := range queue {
+		for _, webhook := range getWebhooks() {
+			if Matches(webhook.Filter, event) {
+				send(webhook, event)
+			}
+		}
+	}
+}
+
+// Matches returns true if the event passes every filter of the webhook, filters support * and ! negations
+func Matches(filter v1.WebhookFilter, event Event) bool {
+	return utils.MatchItems(event.Event, filter.Events...) &&
+		(utils.MatchItems(event.ConfigType, filter.Types...) || utils.MatchItems(event.ExternalType, filter.Types...)) &&
+		utils.MatchItems(event.Severity, filter.Severities...) &&
+		utils.MatchItems(event.ChangeType, filter.ChangeTypes...)
+}
@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/metrics"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func send(webhook v1.Webhook, event Event) {
+	err := post(webhook, event)
+	if err != nil {
+		logger.Errorf("failed to notify webhook %s: %v", webhook.Name, err)
+	}
+	metrics.Notifications.WithLabelValues(webhook.Name, metrics.Status(err)).Inc()
+}
+
+func post(webhook v1.Webhook, event Event) error {
+	body, err := json.Marshal(payload(webhook.Format, event))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range webhook.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// payload formats the event for the webhook, slack and teams incoming webhooks only accept messages
+func payload(format string, event Event) interface{} {
+	switch format {
+	case "slack":
+		return map[string]string{"text": message(event)}
+	case "teams":
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "http://schema.org/extensions",
+			"summary":  title(event),
+			"text":     message(event),
+		}
+	}
+	return event
+}
+
+func title(event Event) string {
+	name := event.Name
+	if name == "" {
+		name = event.ConfigID
+	}
+	configType := event.ConfigType
+	if configType == "" {
+		configType = event.ExternalType
+	}
+	kind := event.ChangeType
+	if event.Event == EventAnalysis {
+		kind = event.Analyzer
+	}
+	return fmt.Sprintf("%s %s on %s %s", event.Event, kind, configType, name)
+}
+
+func message(event Event) string {
+	s := title(event)
+	if event.Severity != "" {
+		s = fmt.Sprintf("[%s] %s", event.Severity, s)
+	}
+	if event.Summary != "" {
+		s += ": " + event.Summary
+	}
+	return s
+}
+
+// Validate returns an error if the webhook cannot be used
+func Validate(webhook v1.Webhook) error {
+	if webhook.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if webhook.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	switch webhook.Format {
+	case "", "json", "slack", "teams":
+		return nil
+	}
+	return fmt.Errorf("unknown format %s, expected one of json, slack or teams", webhook.Format)
+}
@@ -0,0 +1,39 @@
+package query
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultAuditLimit = 100
+
+// AuditHandler returns a page of the latest entries of the audit log, filtered by the config_id, actor, scraper,
+// run_id, action and since query params
+func AuditHandler(c echo.Context) error {
+	page, err := parsePage(c, defaultAuditLimit)
+	if err != nil {
+		return err
+	}
+	filter := db.AuditFilter{
+		ConfigID: c.QueryParam("config_id"),
+		Actor:    c.QueryParam("actor"),
+		Scraper:  c.QueryParam("scraper"),
+		RunID:    c.QueryParam("run_id"),
+		Action:   c.QueryParam("action"),
+		Tenant:   requestTenant(c),
+	}
+	if since := c.QueryParam("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid since, expected an RFC3339 timestamp")
+		}
+	}
+
+	entries, next, err := db.GetAuditLog(filter, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondPage(c, entries, next)
+}
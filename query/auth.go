@@ -0,0 +1,282 @@
+package query
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+// Roles of API callers, the admin role includes the others
+const (
+	RoleRead   = "read"
+	RoleIngest = "ingest"
+	RoleAdmin  = "admin"
+)
+
+var (
+	// APITokens are the static bearer tokens of API callers as role:token, or tenant/role:token for callers
+	// that only see the items of the tenant
+	APITokens []string
+	// OIDCIssuer of the JWTs accepted as bearer tokens, OIDC is disabled when empty
+	OIDCIssuer string
+	// OIDCClientID is the audience of the accepted JWTs, the audience is not checked when empty
+	OIDCClientID string
+	// OIDCRoleClaim is the claim with the roles of the caller, either a string or a list of strings
+	OIDCRoleClaim = "roles"
+	// OIDCRoles maps values of the role claim to roles, values that are role names map to themselves
+	OIDCRoles map[string]string
+	// OIDCTenantClaim is the claim with the tenant of the caller, JWTs without it are rejected. Callers see every
+	// tenant when it is not set
+	OIDCTenantClaim string
+)
+
+// keys of the identity and tenant of the API caller in the request context
+const (
+	actorKey  = "actor"
+	tenantKey = "tenant"
+)
+
+var (
+	oidcVerifier *oidc.IDTokenVerifier
+	oidcLock     sync.Mutex
+)
+
+// Caller is an authenticated API caller
+type Caller struct {
+	// Actor identifies the caller in the audit log
+	Actor string
+	Roles []string
+	// Tenant the caller is scoped to, callers without a tenant see the items of every tenant
+	Tenant string
+}
+
+// HasRole returns true if the caller has the role or is an admin
+func (c Caller) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthEnabled is true when API tokens or an OIDC issuer are set, the API is open to anyone otherwise
+func AuthEnabled() bool {
+	return len(APITokens) > 0 || OIDCIssuer != ""
+}
+
+// IngestEnabled is true when callers can authenticate to push config items
+func IngestEnabled() bool {
+	return len(IngestTokens) > 0 || AuthEnabled()
+}
+
+func isRole(role string) bool {
+	return role == RoleRead || role == RoleIngest || role == RoleAdmin
+}
+
+// ValidateAuth checks the API tokens and role mappings, so that mistakes are reported on startup
+func ValidateAuth() error {
+	for i, t := range APITokens {
+		_, role, token := parseAPIToken(t)
+		if token == "" {
+			return fmt.Errorf("api token %d must be role:token or tenant/role:token", i)
+		}
+		if !isRole(role) {
+			return fmt.Errorf("api token %d has an invalid role %q, expected read, ingest or admin", i, role)
+		}
+	}
+	for value, role := range OIDCRoles {
+		if !isRole(role) {
+			return fmt.Errorf("oidc role mapping %s has an invalid role %q, expected read, ingest or admin", value, role)
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the caller of the bearer token, which is an ingest token, an API token or a JWT of the OIDC issuer
+func Authenticate(ctx context.Context, token string) (*Caller, error) {
+	if tenant, ok := ingestTokenTenant(token); ok {
+		return &Caller{Actor: tokenActor(token), Roles: []string{RoleIngest}, Tenant: tenant}, nil
+	}
+	for _, t := range APITokens {
+		tenant, role, secret := parseAPIToken(t)
+		if secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			return &Caller{Actor: tokenActor(token), Roles: []string{role}, Tenant: tenant}, nil
+		}
+	}
+	if OIDCIssuer != "" && strings.Count(token, ".") == 2 {
+		return authenticateJWT(ctx, token)
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// parseAPIToken splits an API token of the form [tenant/]role:token
+func parseAPIToken(t string) (tenant, role, token string) {
+	role, token, _ = strings.Cut(t, ":")
+	if i := strings.Index(role, "/"); i >= 0 {
+		tenant, role = role[:i], role[i+1:]
+	}
+	return tenant, role, token
+}
+
+// getVerifier discovers the keys of the OIDC issuer on the first request, discovery is retried until it succeeds
+func getVerifier() (*oidc.IDTokenVerifier, error) {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+	if oidcVerifier != nil {
+		return oidcVerifier, nil
+	}
+	// the keys are refreshed with the context of the provider, it must outlive the request
+	provider, err := oidc.NewProvider(context.Background(), OIDCIssuer)
+	if err != nil {
+		return nil, err
+	}
+	oidcVerifier = provider.Verifier(&oidc.Config{ClientID: OIDCClientID, SkipClientIDCheck: OIDCClientID == ""})
+	return oidcVerifier, nil
+}
+
+func authenticateJWT(ctx context.Context, token string) (*Caller, error) {
+	verifier, err := getVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the OIDC issuer %s: %v", OIDCIssuer, err)
+	}
+	idToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+
+	caller := &Caller{Actor: "oidc:" + idToken.Subject}
+	if OIDCTenantClaim != "" {
+		// a token without the claim would see every tenant
+		tenant, _ := claims[OIDCTenantClaim].(string)
+		if tenant == "" {
+			return nil, fmt.Errorf("invalid token: the %s claim is missing", OIDCTenantClaim)
+		}
+		caller.Tenant = tenant
+	}
+	if email, ok := claims["email"].(string); ok && email != "" {
+		caller.Actor = "oidc:" + email
+	}
+	var values []string
+	switch v := claims[OIDCRoleClaim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []interface{}:
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, value := range values {
+		if role, ok := OIDCRoles[value]; ok {
+			caller.Roles = append(caller.Roles, role)
+		} else if isRole(value) {
+			caller.Roles = append(caller.Roles, value)
+		}
+	}
+	return caller, nil
+}
+
+// tokenActor identifies the caller by a fingerprint of its token, the token itself is never recorded
+func tokenActor(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// requestActor returns the identity of the API caller, or its address when it did not authenticate
+func requestActor(c echo.Context) string {
+	if actor, ok := c.Get(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return "anonymous@" + c.RealIP()
+}
+
+// requestTenant returns the tenant the API caller is scoped to, or an empty string when it sees every tenant
+func requestTenant(c echo.Context) string {
+	tenant, _ := c.Get(tenantKey).(string)
+	return tenant
+}
+
+// RequireRole authenticates the caller with its bearer token and requires the role. When authentication is disabled
+// only ingestion requires a token, one of the ingest tokens
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if role == RoleIngest && !IngestEnabled() {
+				return echo.NewHTTPError(http.StatusForbidden, "ingestion is disabled, set --ingest-token or --api-token to enable it")
+			}
+			if role != RoleIngest && !AuthEnabled() {
+				return next(c)
+			}
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			if token == "" || token == auth {
+				return echo.NewHTTPError(http.StatusUnauthorized, "a bearer token is required")
+			}
+			caller, err := Authenticate(c.Request().Context(), token)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if !caller.HasRole(role) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("%s does not have the %s role", caller.Actor, role))
+			}
+			c.Set(actorKey, caller.Actor)
+			c.Set(tenantKey, caller.Tenant)
+			return next(c)
+		}
+	}
+}
+
+// RequireGlobal rejects callers scoped to a tenant, it protects the routes that cannot be restricted to the items
+// of a tenant, e.g. raw SQL queries. It must follow the middleware authenticating the caller
+func RequireGlobal(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if tenant := requestTenant(c); tenant != "" {
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("callers of the tenant %s cannot access %s", tenant, c.Path()))
+		}
+		return next(c)
+	}
+}
+
+// authorizeConfig returns a not found error when the config item is not visible to the tenant of the caller
+func authorizeConfig(c echo.Context, id string) error {
+	tenant := requestTenant(c)
+	if tenant == "" {
+		return nil
+	}
+	ci, err := db.GetConfigItemFromID(id)
+	if err != nil || ci == nil || ci.Tenant != tenant {
+		return echo.NewHTTPError(http.StatusNotFound, "config item not found")
+	}
+	return nil
+}
+
+// RequireMethodRole requires the read role for GET and HEAD requests and the admin role for the other methods,
+// it protects APIs with routes that are not known, e.g. PostgREST
+func RequireMethodRole() echo.MiddlewareFunc {
+	read, admin := RequireRole(RoleRead), RequireRole(RoleAdmin)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		readNext, adminNext := read(next), admin(next)
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return readNext(c)
+			}
+			return adminNext(c)
+		}
+	}
+}
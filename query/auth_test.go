@@ -0,0 +1,94 @@
+package query
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+// withOIDC verifies JWTs signed by the returned key, as tokens of testIssuer with the tenant claim
+func withOIDC(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	verifier := oidc.NewVerifier(testIssuer, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}},
+		&oidc.Config{SkipClientIDCheck: true})
+	issuer, claim, current := OIDCIssuer, OIDCTenantClaim, oidcVerifier
+	OIDCIssuer, OIDCTenantClaim, oidcVerifier = testIssuer, "tenant", verifier
+	t.Cleanup(func() { OIDCIssuer, OIDCTenantClaim, oidcVerifier = issuer, claim, current })
+	return key
+}
+
+// signJWT returns a RS256 JWT of the claims
+func signJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	payload := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign the token: %v", err)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticateJWTTenant(t *testing.T) {
+	key := withOIDC(t)
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": testIssuer, "sub": "user", "exp": time.Now().Add(time.Hour).Unix(), "roles": "read"}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	caller, err := Authenticate(context.Background(), signJWT(t, key, claims(map[string]interface{}{"tenant": "acme"})))
+	if err != nil {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+	if caller.Tenant != "acme" || !caller.HasRole(RoleRead) {
+		t.Errorf("expected a reader of acme, got %+v", caller)
+	}
+
+	for name, extra := range map[string]map[string]interface{}{
+		"missing":    nil,
+		"empty":      {"tenant": ""},
+		"not string": {"tenant": []string{"acme"}},
+	} {
+		if caller, err := Authenticate(context.Background(), signJWT(t, key, claims(extra))); err == nil {
+			t.Errorf("%s tenant claim: expected the token to be rejected, got %+v", name, caller)
+		}
+	}
+}
+
+func TestAuthenticateIngestTokenTenant(t *testing.T) {
+	tokens := IngestTokens
+	IngestTokens = []string{"unbound", "acme:secret"}
+	t.Cleanup(func() { IngestTokens = tokens })
+
+	for token, tenant := range map[string]string{"unbound": "", "secret": "acme"} {
+		caller, err := Authenticate(context.Background(), token)
+		if err != nil {
+			t.Fatalf("%s: failed to authenticate: %v", token, err)
+		}
+		if caller.Tenant != tenant || !caller.HasRole(RoleIngest) || caller.HasRole(RoleRead) {
+			t.Errorf("%s: expected an ingest caller of %q, got %+v", token, tenant, caller)
+		}
+	}
+	if _, err := Authenticate(context.Background(), "acme:secret"); err == nil {
+		t.Errorf("expected the tenant and token to be rejected as a token")
+	}
+}
@@ -0,0 +1,49 @@
+package query
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultChangesLimit = 50
+
+// ChangesHandler returns a page of the changes of a config item with their JSON patches, newest first
+func ChangesHandler(c echo.Context) error {
+	page, err := parsePage(c, defaultChangesLimit)
+	if err != nil {
+		return err
+	}
+	if err := authorizeConfig(c, c.Param("id")); err != nil {
+		return err
+	}
+	changes, next, err := db.GetConfigChanges(c.Param("id"), page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondPage(c, changes, next)
+}
+
+// ChangeStatsHandler counts the changes by day and change type between the since and until query params, defaults to the last 30 days
+func ChangeStatsHandler(c echo.Context) error {
+	until := time.Now()
+	since := until.AddDate(0, 0, -30)
+	var err error
+	if s := c.QueryParam("since"); s != "" {
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid since, expected RFC3339")
+		}
+	}
+	if u := c.QueryParam("until"); u != "" {
+		if until, err = time.Parse(time.RFC3339, u); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid until, expected RFC3339")
+		}
+	}
+	stats, err := db.GetChangeStats(since, until, requestTenant(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, stats, "  ")
+}
@@ -0,0 +1,23 @@
+package query
+
+import (
+	"net/http"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+// DriftHandler returns the drift report between the left and right searches of the request
+func DriftHandler(c echo.Context) error {
+	var request v1.DriftRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	request.Left.Tenant, request.Right.Tenant = requestTenant(c), requestTenant(c)
+	report, err := db.GetDrift(request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, report, "  ")
+}
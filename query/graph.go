@@ -0,0 +1,37 @@
+package query
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultGraphDepth = 1
+	maxGraphDepth     = 5
+)
+
+// GraphHandler returns the relationship graph of a config item up to the depth query param
+func GraphHandler(c echo.Context) error {
+	depth := defaultGraphDepth
+	if d := c.QueryParam("depth"); d != "" {
+		var err error
+		if depth, err = strconv.Atoi(d); err != nil || depth < 0 || depth > maxGraphDepth {
+			return echo.NewHTTPError(http.StatusBadRequest, "depth must be between 0 and 5")
+		}
+	}
+
+	if err := authorizeConfig(c, c.Param("id")); err != nil {
+		return err
+	}
+	graph, err := db.GetConfigGraph(c.Param("id"), depth, requestTenant(c))
+	if err == gorm.ErrRecordNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "config item not found")
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, graph, "  ")
+}
@@ -0,0 +1,454 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxGraphQLDepth of the nested fields of a query, every level of e.g. children is a query per config item
+	maxGraphQLDepth        = 10
+	defaultGraphQLListSize = 20
+	maxGraphQLListSize     = 100
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// graphQLTenant is the key of the tenant of the caller in the context of the resolvers
+type graphQLTenant struct{}
+
+// relatedConfig is the config item at the other end of a relationship
+type relatedConfig struct {
+	Relation string `json:"relation"`
+	// Direction is outgoing when the relationship is from the config item, incoming when it is to the config item
+	Direction string            `json:"direction"`
+	Config    models.ConfigItem `json:"config"`
+}
+
+var (
+	graphQLSchema     graphql.Schema
+	graphQLSchemaErr  error
+	graphQLSchemaOnce sync.Once
+)
+
+// jsonScalar is any JSON value, e.g. the config of an item
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return valueAST.GetValue()
+	},
+})
+
+func tenantOf(p graphql.ResolveParams) string {
+	tenant, _ := p.Context.Value(graphQLTenant{}).(string)
+	return tenant
+}
+
+func configOf(p graphql.ResolveParams) models.ConfigItem {
+	switch source := p.Source.(type) {
+	case models.ConfigItem:
+		return source
+	case *models.ConfigItem:
+		return *source
+	}
+	return models.ConfigItem{}
+}
+
+// listSize returns the limit argument of the field, capped at maxGraphQLListSize
+func listSize(p graphql.ResolveParams) int {
+	limit, ok := p.Args["limit"].(int)
+	if !ok || limit <= 0 {
+		return defaultGraphQLListSize
+	}
+	if limit > maxGraphQLListSize {
+		return maxGraphQLListSize
+	}
+	return limit
+}
+
+func stringArg(p graphql.ResolveParams, name string) string {
+	value, _ := p.Args[name].(string)
+	return value
+}
+
+func intArg(p graphql.ResolveParams, name string) int {
+	value, _ := p.Args[name].(int)
+	return value
+}
+
+func floatArg(p graphql.ResolveParams, name string) float64 {
+	value, _ := p.Args[name].(float64)
+	return value
+}
+
+// getConfig returns the config item with the id, or nil when it is not visible to the tenant
+func getConfig(id, tenant string) (interface{}, error) {
+	items, err := db.GetConfigItems([]string{id}, tenant)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func newGraphQLSchema() (graphql.Schema, error) {
+	limitArg := &graphql.ArgumentConfig{Type: graphql.Int, Description: fmt.Sprintf("Maximum number of results, at most %d", maxGraphQLListSize)}
+
+	changeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ConfigChange",
+		Fields: graphql.Fields{
+			"id":                 &graphql.Field{Type: graphql.String},
+			"config_id":          &graphql.Field{Type: graphql.String},
+			"external_change_id": &graphql.Field{Type: graphql.String},
+			"change_type":        &graphql.Field{Type: graphql.String},
+			"severity":           &graphql.Field{Type: graphql.String},
+			"source":             &graphql.Field{Type: graphql.String},
+			"summary":            &graphql.Field{Type: graphql.String},
+			"patches":            &graphql.Field{Type: graphql.String},
+			"details":            &graphql.Field{Type: jsonScalar},
+			"created_at":         &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	analysisType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ConfigAnalysis",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.String},
+			"config_id": &graphql.Field{Type: graphql.String},
+			"analyzer":  &graphql.Field{Type: graphql.String},
+			"analysis_type": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Analysis).AnalysisType, nil
+			}},
+			"message":        &graphql.Field{Type: graphql.String},
+			"summary":        &graphql.Field{Type: graphql.String},
+			"status":         &graphql.Field{Type: graphql.String},
+			"severity":       &graphql.Field{Type: graphql.String},
+			"analysis":       &graphql.Field{Type: jsonScalar},
+			"first_observed": &graphql.Field{Type: graphql.DateTime},
+			"last_observed":  &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	costsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Costs",
+		Fields: graphql.Fields{
+			"per_minute":                 &graphql.Field{Type: graphql.Float},
+			"total_1d":                   &graphql.Field{Type: graphql.Float},
+			"total_7d":                   &graphql.Field{Type: graphql.Float},
+			"total_30d":                  &graphql.Field{Type: graphql.Float},
+			"amortized_30d":              &graphql.Field{Type: graphql.Float},
+			"commitment_coverage_30d":    &graphql.Field{Type: graphql.Float},
+			"commitment_utilization_30d": &graphql.Field{Type: graphql.Float},
+			"forecast_30d":               &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	configType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ConfigItem",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.String},
+			"scraper_id":    &graphql.Field{Type: graphql.String},
+			"config_type":   &graphql.Field{Type: graphql.String},
+			"external_id":   &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"external_type": &graphql.Field{Type: graphql.String},
+			"name":          &graphql.Field{Type: graphql.String},
+			"namespace":     &graphql.Field{Type: graphql.String},
+			"description":   &graphql.Field{Type: graphql.String},
+			"account":       &graphql.Field{Type: graphql.String},
+			"region":        &graphql.Field{Type: graphql.String},
+			"zone":          &graphql.Field{Type: graphql.String},
+			"network":       &graphql.Field{Type: graphql.String},
+			"subnet":        &graphql.Field{Type: graphql.String},
+			"source":        &graphql.Field{Type: graphql.String},
+			"path":          &graphql.Field{Type: graphql.String},
+			"tags":          &graphql.Field{Type: jsonScalar},
+			"tenant":        &graphql.Field{Type: graphql.String},
+			"created_at":    &graphql.Field{Type: graphql.DateTime},
+			"updated_at":    &graphql.Field{Type: graphql.DateTime},
+			"config": &graphql.Field{Type: jsonScalar, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				ci := configOf(p)
+				if ci.Config == nil {
+					return nil, nil
+				}
+				var config interface{}
+				if err := json.Unmarshal([]byte(*ci.Config), &config); err != nil {
+					return nil, err
+				}
+				return config, nil
+			}},
+			"costs": &graphql.Field{Type: costsType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				ci := configOf(p)
+				return map[string]interface{}{
+					"per_minute":                 ci.CostPerMinute,
+					"total_1d":                   ci.CostTotal1d,
+					"total_7d":                   ci.CostTotal7d,
+					"total_30d":                  ci.CostTotal30d,
+					"amortized_30d":              ci.CostAmortized30d,
+					"commitment_coverage_30d":    ci.CommitmentCoverage30d,
+					"commitment_utilization_30d": ci.CommitmentUtilization30d,
+					"forecast_30d":               ci.CostForecast30d,
+				}, nil
+			}},
+			"changes": &graphql.Field{
+				Type:        graphql.NewList(changeType),
+				Description: "Latest changes, newest first",
+				Args: graphql.FieldConfigArgument{
+					"limit":  limitArg,
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					changes, _, err := db.GetConfigChanges(configOf(p).ID, db.Page{Limit: listSize(p), Offset: intArg(p, "offset")})
+					return changes, err
+				},
+			},
+			"analyses": &graphql.Field{
+				Type:        graphql.NewList(analysisType),
+				Description: "Latest analyses, most recently observed first",
+				Args: graphql.FieldConfigArgument{
+					"limit":  limitArg,
+					"status": &graphql.ArgumentConfig{Type: graphql.String, Description: "Only the analyses with the status, e.g. open"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return db.GetConfigAnalyses(configOf(p).ID, stringArg(p, "status"), listSize(p))
+				},
+			},
+		},
+	})
+
+	relatedType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RelatedConfig",
+		Fields: graphql.Fields{
+			"relation":  &graphql.Field{Type: graphql.String},
+			"direction": &graphql.Field{Type: graphql.String, Description: "outgoing or incoming"},
+			"config":    &graphql.Field{Type: configType},
+		},
+	})
+
+	configType.AddFieldConfig("parent", &graphql.Field{Type: configType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		ci := configOf(p)
+		if ci.ParentID == nil {
+			return nil, nil
+		}
+		return getConfig(*ci.ParentID, tenantOf(p))
+	}})
+	configType.AddFieldConfig("children", &graphql.Field{Type: graphql.NewList(configType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return db.GetChildConfigItems(configOf(p).ID, tenantOf(p))
+	}})
+	configType.AddFieldConfig("relationships", &graphql.Field{
+		Type:        graphql.NewList(relatedType),
+		Description: "Relationships from and to the config item, other than parent and children",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id := configOf(p).ID
+			relationships, err := db.GetConfigRelationships(id)
+			if err != nil {
+				return nil, err
+			}
+			var ids []string
+			for _, r := range relationships {
+				if r.ConfigID == id {
+					ids = append(ids, r.RelatedID)
+				} else {
+					ids = append(ids, r.ConfigID)
+				}
+			}
+			items, err := db.GetConfigItems(ids, tenantOf(p))
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]models.ConfigItem, len(items))
+			for _, item := range items {
+				byID[item.ID] = item
+			}
+			var related []relatedConfig
+			for _, r := range relationships {
+				direction, other := "outgoing", r.RelatedID
+				if r.ConfigID != id {
+					direction, other = "incoming", r.ConfigID
+				}
+				// items of other tenants and hard deleted items are left out
+				if item, ok := byID[other]; ok {
+					related = append(related, relatedConfig{Relation: r.Relation, Direction: direction, Config: item})
+				}
+			}
+			return related, nil
+		},
+	})
+
+	searchType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ConfigSearch",
+		Fields: graphql.Fields{
+			"total":       &graphql.Field{Type: graphql.Int},
+			"limit":       &graphql.Field{Type: graphql.Int},
+			"offset":      &graphql.Field{Type: graphql.Int},
+			"results":     &graphql.Field{Type: graphql.NewList(configType)},
+			"next_cursor": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"config": &graphql.Field{
+				Type:        configType,
+				Description: "Returns the config item with the id",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return getConfig(stringArg(p, "id"), tenantOf(p))
+				},
+			},
+			"configs": &graphql.Field{
+				Type:        searchType,
+				Description: "Searches the config items that are not deleted, like GET /query",
+				Args: graphql.FieldConfigArgument{
+					"text":          &graphql.ArgumentConfig{Type: graphql.String},
+					"jsonpath":      &graphql.ArgumentConfig{Type: graphql.String},
+					"type":          &graphql.ArgumentConfig{Type: graphql.String},
+					"external_type": &graphql.ArgumentConfig{Type: graphql.String},
+					"account":       &graphql.ArgumentConfig{Type: graphql.String},
+					"region":        &graphql.ArgumentConfig{Type: graphql.String},
+					"min_cost_30d":  &graphql.ArgumentConfig{Type: graphql.Float},
+					"sort_by":       &graphql.ArgumentConfig{Type: graphql.String},
+					"order":         &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":         &graphql.ArgumentConfig{Type: graphql.Int},
+					"offset":        &graphql.ArgumentConfig{Type: graphql.Int},
+					"cursor":        &graphql.ArgumentConfig{Type: graphql.String, Description: "next_cursor of the previous page"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return db.SearchConfigItems(v1.SearchRequest{
+						Text:         stringArg(p, "text"),
+						JSONPath:     stringArg(p, "jsonpath"),
+						Type:         stringArg(p, "type"),
+						ExternalType: stringArg(p, "external_type"),
+						Account:      stringArg(p, "account"),
+						Region:       stringArg(p, "region"),
+						MinCost30d:   floatArg(p, "min_cost_30d"),
+						SortBy:       stringArg(p, "sort_by"),
+						Order:        stringArg(p, "order"),
+						Limit:        intArg(p, "limit"),
+						Offset:       intArg(p, "offset"),
+						Cursor:       stringArg(p, "cursor"),
+						Tenant:       tenantOf(p),
+					})
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// queryDepth returns the deepest nesting of fields in the operations of the document
+func queryDepth(document *ast.Document) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	var depthOf func(set *ast.SelectionSet, visited map[string]bool) int
+	depthOf = func(set *ast.SelectionSet, visited map[string]bool) int {
+		if set == nil {
+			return 0
+		}
+		depth := 0
+		for _, selection := range set.Selections {
+			d := 0
+			switch s := selection.(type) {
+			case *ast.Field:
+				if s.SelectionSet != nil {
+					d = 1 + depthOf(s.SelectionSet, visited)
+				}
+			case *ast.InlineFragment:
+				d = depthOf(s.SelectionSet, visited)
+			case *ast.FragmentSpread:
+				// cycles of fragments are rejected by the validation of the query
+				if fragment := fragments[s.Name.Value]; fragment != nil && !visited[s.Name.Value] {
+					visited[s.Name.Value] = true
+					d = depthOf(fragment.SelectionSet, visited)
+					delete(visited, s.Name.Value)
+				}
+			}
+			if d > depth {
+				depth = d
+			}
+		}
+		return depth
+	}
+
+	depth := 0
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if d := depthOf(operation.SelectionSet, map[string]bool{}); d > depth {
+				depth = d
+			}
+		}
+	}
+	return depth
+}
+
+// GraphQLHandler runs the GraphQL query of the body, or of the query param of GET requests, over the config items,
+// their changes, analyses, relationships and costs
+func GraphQLHandler(c echo.Context) error {
+	var request graphQLRequest
+	if c.Request().Method == http.MethodGet {
+		request.Query = c.QueryParam("query")
+		request.OperationName = c.QueryParam("operationName")
+		if variables := c.QueryParam("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid variables: %v", err))
+			}
+		}
+	} else if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if request.Query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "query is required")
+	}
+
+	graphQLSchemaOnce.Do(func() {
+		graphQLSchema, graphQLSchemaErr = newGraphQLSchema()
+	})
+	if graphQLSchemaErr != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, graphQLSchemaErr.Error())
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if depth := queryDepth(document); depth > maxGraphQLDepth {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("query is nested %d levels deep, at most %d are allowed", depth, maxGraphQLDepth))
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        context.WithValue(c.Request().Context(), graphQLTenant{}, requestTenant(c)),
+	})
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,61 @@
+package query
+
+import (
+	"net/http"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthChecks returns the diagnostics of the connections of the scheduled scrapers
+var HealthChecks func() []v1.Diagnostic
+
+// HealthStatus is returned by the health and readiness endpoints, the errors of the database are logged rather
+// than returned as the endpoints are not authenticated
+type HealthStatus struct {
+	Database string `json:"database"`
+}
+
+// DiagnosticsStatus is returned by the diagnostics endpoint
+type DiagnosticsStatus struct {
+	Connections []v1.Diagnostic `json:"connections"`
+}
+
+// ReadyHandler responds with 503 until the database can be reached
+func ReadyHandler(c echo.Context) error {
+	status := HealthStatus{Database: "ok"}
+	if err := db.Ping(); err != nil {
+		logger.Warnf("database is not ready: %v", err)
+		status.Database = "unavailable"
+		return c.JSONPretty(http.StatusServiceUnavailable, status, "  ")
+	}
+	return c.JSONPretty(http.StatusOK, status, "  ")
+}
+
+// HealthHandler is the liveness check, it responds with 200 as long as the server is running and reports whether
+// the database can be reached
+func HealthHandler(c echo.Context) error {
+	status := HealthStatus{Database: "ok"}
+	if err := db.Ping(); err != nil {
+		status.Database = "unavailable"
+	}
+	return c.JSONPretty(http.StatusOK, status, "  ")
+}
+
+// DiagnosticsHandler responds with the cached diagnostics of the connections of the scheduled scrapers, and 503
+// when the credentials of a scraper are invalid
+func DiagnosticsHandler(c echo.Context) error {
+	code := http.StatusOK
+	var status DiagnosticsStatus
+	if HealthChecks != nil {
+		status.Connections = HealthChecks()
+	}
+	for _, d := range status.Connections {
+		if d.Error != "" {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	return c.JSONPretty(code, status, "  ")
+}
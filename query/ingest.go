@@ -0,0 +1,103 @@
+package query
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+// IngestTokens are bearer tokens with only the ingest role, accepted by POST /config and the gRPC ingestion service.
+// Tokens of the form tenant:token save the pushed items in the tenant, the others in the default tenant
+var IngestTokens []string
+
+const ingestSource = "push"
+
+// ingestTokenTenant returns the tenant bound to the token and true if the token is one of the ingest tokens
+func ingestTokenTenant(token string) (string, bool) {
+	for _, t := range IngestTokens {
+		tenant, secret, bound := strings.Cut(t, ":")
+		if !bound {
+			tenant, secret = "", t
+		}
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1 {
+			return tenant, true
+		}
+	}
+	return "", false
+}
+
+// IngestHandler saves the config items pushed in the body, either a single scrape result or a list of them
+func IngestHandler(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	results, err := parseResults(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	for i := range results {
+		if err := ValidateResult(&results[i]); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("result %d: %v", i, err))
+		}
+	}
+
+	ctx := &v1.ScrapeContext{Context: c.Request().Context(), Actor: requestActor(c), Tenant: requestTenant(c)}
+	if err := db.SaveResults(ctx, results); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusAccepted, v1.IngestResponse{Items: len(results)})
+}
+
+// parseResults decodes the body into ingest requests, the json of a scrape result skips the parent, the changes and
+// the relationships
+func parseResults(body []byte) ([]v1.ScrapeResult, error) {
+	var requests []v1.IngestRequest
+	if err := json.Unmarshal(body, &requests); err != nil {
+		var req v1.IngestRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		requests = []v1.IngestRequest{req}
+	}
+	results := make([]v1.ScrapeResult, 0, len(requests))
+	for _, req := range requests {
+		results = append(results, req.ScrapeResult())
+	}
+	return results, nil
+}
+
+// ValidateResult checks the required fields of a pushed result and sets the defaults of the others
+func ValidateResult(result *v1.ScrapeResult) error {
+	if result.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if result.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if result.Config == nil && result.AnalysisResult == nil && len(result.Changes) == 0 {
+		return fmt.Errorf("config, analysis or changes are required")
+	}
+	if result.ExternalType == "" {
+		result.ExternalType = result.Type
+	}
+	if result.Source == "" {
+		result.Source = ingestSource
+	}
+	if result.AnalysisResult != nil {
+		if result.AnalysisResult.ExternalID == "" {
+			result.AnalysisResult.ExternalID = result.ID
+		}
+		if result.AnalysisResult.ExternalType == "" {
+			result.AnalysisResult.ExternalType = result.ExternalType
+		}
+	}
+	return nil
+}
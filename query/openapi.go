@@ -0,0 +1,227 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/jsonschema"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/upstream"
+	"github.com/labstack/echo/v4"
+)
+
+// operation of the HTTP API in the OpenAPI document, the request and response are reflected to their schemas
+type operation struct {
+	method   string
+	path     string
+	summary  string
+	role     string
+	params   []parameter
+	request  interface{}
+	response interface{}
+}
+
+type parameter struct {
+	name        string
+	in          string
+	description string
+	schema      string
+}
+
+func pathParam(name, description string) parameter {
+	return parameter{name: name, in: "path", description: description, schema: "string"}
+}
+
+func queryParam(name, schema, description string) parameter {
+	return parameter{name: name, in: "query", description: description, schema: schema}
+}
+
+var (
+	limitParam  = queryParam("limit", "integer", "Maximum number of results")
+	offsetParam = queryParam("offset", "integer", "Number of results to skip")
+	cursorParam = queryParam("cursor", "string", "Cursor of the next page, from the "+NextCursorHeader+" header of the previous page, instead of the offset")
+	fieldsParam = queryParam("fields", "string", "Comma separated fields of the results to return, all when it is not set")
+	idParam     = pathParam("id", "ID of the config item")
+	atParam     = queryParam("at", "string", "RFC3339 time to reconstruct the config at, defaults to now")
+)
+
+var operations = []operation{
+	{method: http.MethodGet, path: "/query", summary: "Searches config items, or runs the raw SQL query of the query param", role: RoleRead,
+		params: []parameter{
+			queryParam("query", "string", "Raw SQL query, not available to callers scoped to a tenant"),
+			queryParam("text", "string", "Text searched in the config of the items"),
+			queryParam("jsonpath", "string", "JSONPath that must match the config"),
+			queryParam("type", "string", "Config type"),
+			queryParam("external_type", "string", "External type"),
+			queryParam("account", "string", "Account"),
+			queryParam("region", "string", "Region"),
+			queryParam("tag", "string", "Tag as key=value, repeatable"),
+			queryParam("min_cost_30d", "number", "Minimum cost over the last 30 days"),
+			queryParam("sort_by", "string", "Column to sort by"),
+			queryParam("order", "string", "asc or desc"),
+			limitParam, offsetParam, cursorParam, fieldsParam,
+		},
+		response: models.SearchResult{}},
+	{method: http.MethodPost, path: "/graphql", summary: "Runs a GraphQL query over the config items, their changes, analyses, relationships and costs", role: RoleRead,
+		request: graphQLRequest{}, response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/config/{id}", summary: "Returns a config item", role: RoleRead,
+		params: []parameter{idParam, atParam}, response: models.ConfigItem{}},
+	{method: http.MethodGet, path: "/config/{id}/changes", summary: "Returns the changes of a config item, newest first", role: RoleRead,
+		params: []parameter{idParam, limitParam, offsetParam, cursorParam, fieldsParam}, response: []models.ConfigChange{}},
+	{method: http.MethodGet, path: "/config/{id}/graph", summary: "Returns the relationship graph of a config item", role: RoleRead,
+		params: []parameter{idParam, queryParam("depth", "integer", "Depth of the graph, between 0 and 5")}, response: v1.ConfigGraph{}},
+	{method: http.MethodGet, path: "/changes/stats", summary: "Counts the changes by day and change type", role: RoleRead,
+		params:   []parameter{queryParam("since", "string", "RFC3339 start, defaults to 30 days ago"), queryParam("until", "string", "RFC3339 end, defaults to now")},
+		response: []db.ChangeStats{}},
+	{method: http.MethodGet, path: "/changes/stream", summary: "Streams the config events of the replica as text/event-stream server-sent events", role: RoleRead,
+		params: []parameter{
+			queryParam("event", "string", "Comma separated event types, e.g. config.changed,config.deleted"),
+			queryParam("config_type", "string", "Comma separated config types"),
+			queryParam("external_type", "string", "Comma separated external types"),
+			queryParam("change_type", "string", "Comma separated change types"),
+			queryParam("severity", "string", "Comma separated severities"),
+			queryParam("config", "boolean", "Send the config of created and updated items"),
+		}},
+	{method: http.MethodGet, path: "/snapshot", summary: "Returns the config items of a type and/or account at a time", role: RoleRead,
+		params: []parameter{queryParam("type", "string", "Config type"), queryParam("account", "string", "Account"), atParam, limitParam, cursorParam, fieldsParam}, response: []models.ConfigItem{}},
+	{method: http.MethodPost, path: "/drift", summary: "Compares the config items of two searches", role: RoleRead,
+		request: v1.DriftRequest{}, response: v1.DriftReport{}},
+	{method: http.MethodGet, path: "/scrape_runs", summary: "Returns the latest scrape runs", role: RoleRead,
+		params: []parameter{queryParam("name", "string", "Scraper name"), queryParam("status", "string", "Status of the run"), queryParam("external_id", "string", "External id of an item that had an error in the run"), limitParam, cursorParam, fieldsParam}, response: []models.ScrapeRun{}},
+	{method: http.MethodGet, path: "/scrape_tasks", summary: "Returns the latest queued scrape tasks", role: RoleRead,
+		params: []parameter{queryParam("name", "string", "Scraper name"), queryParam("status", "string", "Status of the task"), limitParam, cursorParam, fieldsParam}, response: []models.ScrapeTask{}},
+	{method: http.MethodGet, path: "/audit", summary: "Returns the latest entries of the audit log", role: RoleRead,
+		params: []parameter{
+			queryParam("config_id", "string", "ID of the config item"),
+			queryParam("actor", "string", "Scraper, token or job that made the change"),
+			queryParam("scraper", "string", "Scraper name"),
+			queryParam("run_id", "string", "ID of the scrape run"),
+			queryParam("action", "string", "Action, e.g. created, updated or deleted"),
+			queryParam("since", "string", "RFC3339 time of the oldest entry"),
+			limitParam,
+		},
+		response: []models.AuditLog{}},
+	{method: http.MethodGet, path: "/views", summary: "Returns all the views", role: RoleRead, response: []v1.View{}},
+	{method: http.MethodPut, path: "/views/{name}", summary: "Creates or updates a view", role: RoleAdmin,
+		params: []parameter{pathParam("name", "Name of the view")}, request: v1.View{}, response: v1.View{}},
+	{method: http.MethodDelete, path: "/views/{name}", summary: "Deletes a view", role: RoleAdmin,
+		params: []parameter{pathParam("name", "Name of the view")}},
+	{method: http.MethodGet, path: "/view/{name}", summary: "Returns the rows of a view", role: RoleRead,
+		params: []parameter{pathParam("name", "Name of the view")}, response: map[string]interface{}{}},
+	{method: http.MethodGet, path: "/webhooks", summary: "Returns all the webhooks", role: RoleAdmin, response: []v1.Webhook{}},
+	{method: http.MethodPut, path: "/webhooks/{name}", summary: "Creates or updates a webhook", role: RoleAdmin,
+		params: []parameter{pathParam("name", "Name of the webhook")}, request: v1.Webhook{}, response: v1.Webhook{}},
+	{method: http.MethodDelete, path: "/webhooks/{name}", summary: "Deletes a webhook", role: RoleAdmin,
+		params: []parameter{pathParam("name", "Name of the webhook")}},
+	{method: http.MethodGet, path: "/report", summary: "Generates the posture report", role: RoleRead,
+		params: []parameter{queryParam("format", "string", "json, csv or html")}},
+	{method: http.MethodPost, path: "/config", summary: "Saves the pushed config items, either a scrape result or a list of them", role: RoleIngest,
+		request: []v1.IngestRequest{}, response: v1.IngestResponse{}},
+	{method: http.MethodPost, path: "/upstream/push", summary: "Saves the config items pushed by an agent", role: RoleIngest,
+		request: upstream.PushRequest{}, response: upstream.PushResponse{}},
+	{method: http.MethodGet, path: "/health", summary: "Checks the server is running and the database", response: HealthStatus{}},
+	{method: http.MethodGet, path: "/diagnostics", summary: "Checks the connections of the scheduled scrapers", role: RoleAdmin,
+		response: DiagnosticsStatus{}},
+	{method: http.MethodGet, path: "/ready", summary: "Checks the database", response: HealthStatus{}},
+}
+
+var (
+	openAPI     []byte
+	openAPILock sync.Mutex
+)
+
+// OpenAPI returns the OpenAPI 3 document of the HTTP API
+func OpenAPI() ([]byte, error) {
+	openAPILock.Lock()
+	defer openAPILock.Unlock()
+	if openAPI != nil {
+		return openAPI, nil
+	}
+
+	// OpenAPI 3.0 schemas do not declare a JSON schema version
+	jsonschema.Version = ""
+	reflector := &jsonschema.Reflector{
+		// types of different packages share names, e.g. v1.View and models.View
+		TypeNamer: func(t reflect.Type) string {
+			return t.String()
+		},
+	}
+	schemas := make(map[string]interface{})
+	schemaOf := func(v interface{}) interface{} {
+		schema := reflector.Reflect(v)
+		for name, definition := range schema.Definitions {
+			schemas[name] = definition
+		}
+		return schema.Type
+	}
+
+	paths := make(map[string]map[string]interface{})
+	for _, op := range operations {
+		o := map[string]interface{}{"summary": op.summary}
+		if op.role != "" {
+			o["description"] = "Requires the " + op.role + " role once authentication is enabled"
+			o["security"] = []map[string][]string{{"bearer": {}}}
+		}
+		var params []map[string]interface{}
+		for _, p := range op.params {
+			params = append(params, map[string]interface{}{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.in == "path",
+				"schema":      map[string]string{"type": p.schema},
+			})
+		}
+		if len(params) > 0 {
+			o["parameters"] = params
+		}
+		if op.request != nil {
+			o["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": schemaOf(op.request)}},
+			}
+		}
+		response := map[string]interface{}{"description": "OK"}
+		if op.response != nil {
+			response["content"] = map[string]interface{}{echo.MIMEApplicationJSON: map[string]interface{}{"schema": schemaOf(op.response)}}
+		}
+		o["responses"] = map[string]interface{}{"200": response}
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]interface{})
+		}
+		paths[op.path][strings.ToLower(op.method)] = o
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "config-db", "version": "v1"},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// the reflected schemas reference draft-04 definitions
+	openAPI = []byte(strings.ReplaceAll(string(data), `"#/definitions/`, `"#/components/schemas/`))
+	return openAPI, nil
+}
+
+// OpenAPIHandler returns the OpenAPI 3 document of the HTTP API
+func OpenAPIHandler(c echo.Context) error {
+	data, err := OpenAPI()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, data)
+}
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxPageLimit of the lists, larger limits are reduced to it
+	maxPageLimit = 1000
+	// NextCursorHeader of the responses of lists holds the cursor of the next page, it is not set on the last page
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// parsePage reads the limit, offset and cursor query params of a list, the limit defaults to defaultLimit
+func parsePage(c echo.Context, defaultLimit int) (db.Page, error) {
+	page := db.Page{Limit: defaultLimit}
+	var err error
+	if l := c.QueryParam("limit"); l != "" {
+		if page.Limit, err = strconv.Atoi(l); err != nil || page.Limit <= 0 {
+			return page, echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+	}
+	if page.Limit > maxPageLimit {
+		page.Limit = maxPageLimit
+	}
+	if o := c.QueryParam("offset"); o != "" {
+		if page.Offset, err = strconv.Atoi(o); err != nil || page.Offset < 0 {
+			return page, echo.NewHTTPError(http.StatusBadRequest, "invalid offset")
+		}
+	}
+	if page.Cursor, err = db.ParseCursor(c.QueryParam("cursor")); err != nil {
+		return page, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if page.Cursor != nil && page.Offset > 0 {
+		return page, echo.NewHTTPError(http.StatusBadRequest, "cursor and offset cannot be combined")
+	}
+	return page, nil
+}
+
+// sparseFields returns the comma separated fields query param
+func sparseFields(c echo.Context) []string {
+	var fields []string
+	for _, field := range strings.Split(c.QueryParam("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// sparse returns the items of the list with only the fields, or the list when there are no fields
+func sparse(list interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return list, nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	trimmed := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		trimmed[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := item[field]; ok {
+				trimmed[i][field] = value
+			}
+		}
+	}
+	return trimmed, nil
+}
+
+// respondPage writes the page of a list with only the fields of the fields query param, and the cursor of the next page
+func respondPage(c echo.Context, list interface{}, next db.Cursor) error {
+	if len(next) > 0 {
+		c.Response().Header().Set(NextCursorHeader, next.String())
+	}
+	body, err := sparse(list, sparseFields(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, body, "  ")
+}
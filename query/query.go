@@ -1,15 +1,25 @@
 package query
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
 	"github.com/labstack/echo/v4"
 )
 
-// Handler ...
+// Handler runs a raw SQL query when the query param is set, otherwise it searches config items
 func Handler(c echo.Context) error {
+	if c.QueryParam("query") == "" {
+		return searchHandler(c)
+	}
+	if tenant := requestTenant(c); tenant != "" {
+		return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("raw queries cannot be restricted to the tenant %s, use a search", tenant))
+	}
+
 	request := v1.QueryRequest{
 		Query: c.QueryParam("query"),
 	}
@@ -21,3 +31,65 @@ func Handler(c echo.Context) error {
 	return c.JSONPretty(http.StatusOK, resp, "  ")
 
 }
+
+// searchHandler searches config items with the text, jsonpath, type, external_type, account, region,
+// tag (key=value, repeatable), min_cost_30d, limit, offset, cursor, sort_by, order and fields query params
+func searchHandler(c echo.Context) error {
+	page, err := parsePage(c, 0)
+	if err != nil {
+		return err
+	}
+	request := v1.SearchRequest{
+		Text:         c.QueryParam("text"),
+		JSONPath:     c.QueryParam("jsonpath"),
+		Type:         c.QueryParam("type"),
+		ExternalType: c.QueryParam("external_type"),
+		Account:      c.QueryParam("account"),
+		Region:       c.QueryParam("region"),
+		SortBy:       c.QueryParam("sort_by"),
+		Order:        c.QueryParam("order"),
+		Limit:        page.Limit,
+		Offset:       page.Offset,
+		Cursor:       c.QueryParam("cursor"),
+		Fields:       sparseFields(c),
+		Tenant:       requestTenant(c),
+	}
+
+	if m := c.QueryParam("min_cost_30d"); m != "" {
+		if request.MinCost30d, err = strconv.ParseFloat(m, 64); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid min_cost_30d")
+		}
+	}
+	for _, tag := range c.QueryParams()["tag"] {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "tags must be of the form key=value")
+		}
+		if request.Tags == nil {
+			request.Tags = make(map[string]string)
+		}
+		request.Tags[key] = value
+	}
+
+	resp, err := db.SearchConfigItems(request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if resp.NextCursor != "" {
+		c.Response().Header().Set(NextCursorHeader, resp.NextCursor)
+	}
+	if len(request.Fields) == 0 {
+		return c.JSONPretty(http.StatusOK, resp, "  ")
+	}
+	results, err := sparse(resp.Results, request.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{
+		"total":       resp.Total,
+		"limit":       resp.Limit,
+		"offset":      resp.Offset,
+		"results":     results,
+		"next_cursor": resp.NextCursor,
+	}, "  ")
+}
@@ -0,0 +1,35 @@
+package query
+
+import (
+	"net/http"
+
+	"github.com/flanksource/config-db/reports"
+	"github.com/labstack/echo/v4"
+)
+
+var reportContentTypes = map[string]string{
+	"json": echo.MIMEApplicationJSONCharsetUTF8,
+	"csv":  "text/csv; charset=utf-8",
+	"html": echo.MIMETextHTMLCharsetUTF8,
+}
+
+// ReportHandler generates the posture report, in the format of the format query param
+func ReportHandler(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	contentType, ok := reportContentTypes[format]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be one of json, csv or html")
+	}
+	report, err := reports.Generate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	data, err := report.Render(format)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.Blob(http.StatusOK, contentType, data)
+}
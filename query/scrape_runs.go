@@ -0,0 +1,36 @@
+package query
+
+import (
+	"net/http"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultScrapeRunsLimit = 50
+
+// ScrapeRunsHandler returns a page of the latest scrape runs, filtered by the name, status and external_id query params
+func ScrapeRunsHandler(c echo.Context) error {
+	page, err := parsePage(c, defaultScrapeRunsLimit)
+	if err != nil {
+		return err
+	}
+	runs, next, err := db.GetScrapeRuns(c.QueryParam("name"), c.QueryParam("status"), c.QueryParam("external_id"), requestTenant(c), page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondPage(c, runs, next)
+}
+
+// ScrapeTasksHandler returns a page of the latest queued scrape tasks, filtered by the name and status query params
+func ScrapeTasksHandler(c echo.Context) error {
+	page, err := parsePage(c, defaultScrapeRunsLimit)
+	if err != nil {
+		return err
+	}
+	tasks, next, err := db.GetScrapeTasks(c.QueryParam("name"), c.QueryParam("status"), page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondPage(c, tasks, next)
+}
@@ -0,0 +1,70 @@
+package query
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const defaultSnapshotLimit = 500
+
+// ConfigHandler returns a config item, or what it looked like at the time of the at query param
+func ConfigHandler(c echo.Context) error {
+	at, err := parseAt(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeConfig(c, c.Param("id")); err != nil {
+		return err
+	}
+	ci, err := db.GetConfigSnapshot(c.Param("id"), at)
+	if err == gorm.ErrRecordNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "config item not found")
+	} else if errors.Is(err, db.ErrHistoryPruned) {
+		return echo.NewHTTPError(http.StatusGone, err.Error())
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, ci, "  ")
+}
+
+// SnapshotHandler returns a page of the config items of the type and/or account query params at the time of the at
+// query param
+func SnapshotHandler(c echo.Context) error {
+	at, err := parseAt(c)
+	if err != nil {
+		return err
+	}
+	page, err := parsePage(c, defaultSnapshotLimit)
+	if err != nil {
+		return err
+	}
+	configType, account := c.QueryParam("type"), c.QueryParam("account")
+	if configType == "" && account == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "type or account is required")
+	}
+	items, next, err := db.GetConfigSnapshots(configType, account, requestTenant(c), at, page)
+	if errors.Is(err, db.ErrHistoryPruned) {
+		return echo.NewHTTPError(http.StatusGone, err.Error())
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return respondPage(c, items, next)
+}
+
+// parseAt returns the at query param, defaults to now
+func parseAt(c echo.Context) (time.Time, error) {
+	at := c.QueryParam("at")
+	if at == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return t, echo.NewHTTPError(http.StatusBadRequest, "invalid at, expected RFC3339")
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flanksource/config-db/events"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	streamBuffer    = 100
+	streamKeepAlive = 30 * time.Second
+)
+
+// streamFilter matches the events with one of the values of every comma separated query param that is set
+type streamFilter map[string]map[string]bool
+
+func newStreamFilter(c echo.Context, params ...string) streamFilter {
+	filter := make(streamFilter)
+	for _, param := range params {
+		for _, value := range strings.Split(c.QueryParam(param), ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			if filter[param] == nil {
+				filter[param] = make(map[string]bool)
+			}
+			filter[param][strings.ToLower(value)] = true
+		}
+	}
+	return filter
+}
+
+func (f streamFilter) matches(param, value string) bool {
+	return f[param] == nil || f[param][strings.ToLower(value)]
+}
+
+// ChangesStreamHandler streams the config events of this replica as server-sent events, filtered by the event
+// (e.g. config.changed), config_type, external_type, change_type and severity query params. The config of created and
+// updated items is only sent when the config query param is true
+func ChangesStreamHandler(c echo.Context) error {
+	filter := newStreamFilter(c, "event", "config_type", "external_type", "change_type", "severity")
+	withConfig := c.QueryParam("config") == "true"
+	tenant := requestTenant(c)
+
+	subscription, stop := events.Subscribe(streamBuffer)
+	defer stop()
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	// disables the buffering of nginx ingresses
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			response.Flush()
+		case event, ok := <-subscription:
+			if !ok {
+				// the server is shutting down
+				return nil
+			}
+			if tenant != "" && event.Tenant != tenant {
+				continue
+			}
+			if !filter.matches("event", event.Type) || !filter.matches("config_type", event.ConfigType) ||
+				!filter.matches("external_type", event.ExternalType) || !filter.matches("change_type", event.ChangeType) ||
+				!filter.matches("severity", event.Severity) {
+				continue
+			}
+			if !withConfig {
+				event.Config = nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(response, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return nil
+			}
+			response.Flush()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"net/http"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/upstream"
+	"github.com/labstack/echo/v4"
+)
+
+// UpstreamPushHandler saves the items reconciled by an agent, items owned by another agent are returned as conflicts
+func UpstreamPushHandler(c echo.Context) error {
+	var req upstream.PushRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Agent == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "agent is required")
+	}
+	source := upstream.SourcePrefix + req.Agent
+	tenant := requestTenant(c)
+
+	var resp upstream.PushResponse
+	var results []v1.ScrapeResult
+	for _, item := range req.Items {
+		result := item.ScrapeResult()
+		if err := ValidateResult(&result); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		owned, err := ownedByOtherAgent(result.ExternalType, result.ID, source, tenant)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		if owned {
+			resp.Conflicts = append(resp.Conflicts, result.ID)
+			continue
+		}
+		result.Source = source
+		results = append(results, result)
+	}
+	for _, change := range req.Changes {
+		owned, err := ownedByOtherAgent(change.ExternalType, change.ExternalID, source, tenant)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		if owned {
+			resp.Conflicts = append(resp.Conflicts, change.ExternalID)
+			continue
+		}
+		results = append(results, v1.ScrapeResult{Changes: []v1.ChangeResult{change.ChangeResult()}})
+		resp.Changes++
+	}
+	for _, deleted := range req.Deleted {
+		owned, err := ownedByOtherAgent(deleted.ExternalType, deleted.ExternalID, source, tenant)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		if owned {
+			resp.Conflicts = append(resp.Conflicts, deleted.ExternalID)
+			continue
+		}
+		results = append(results, v1.ScrapeResult{Changes: []v1.ChangeResult{{
+			ExternalID:   deleted.ExternalID,
+			ExternalType: deleted.ExternalType,
+			Action:       v1.Delete,
+			ChangeType:   "delete",
+			Source:       source,
+		}}})
+		resp.Deleted++
+	}
+
+	ctx := &v1.ScrapeContext{Context: c.Request().Context(), Actor: requestActor(c), Tenant: tenant}
+	if err := db.SaveResults(ctx, results); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	resp.Accepted = len(results) - resp.Changes - resp.Deleted
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ownedByOtherAgent returns true if the item of the tenant on the hub was pushed by another agent
+func ownedByOtherAgent(externalType, externalID, source, tenant string) (bool, error) {
+	existing, err := db.GetConfigItem(externalType, externalID, tenant)
+	if err != nil || existing == nil || existing.Source == nil {
+		return false, err
+	}
+	return strings.HasPrefix(*existing.Source, upstream.SourcePrefix) && *existing.Source != source, nil
+}
@@ -0,0 +1,166 @@
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/flanksource/commons/text"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/labstack/echo/v4"
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// configViews are the views defined in scraper configs, keyed by name
+var (
+	configViewsLock sync.RWMutex
+	configViews     = make(map[string]configView)
+)
+
+type configView struct {
+	owner string
+	view  v1.View
+}
+
+// RegisterViews adds the views of a scraper config, replacing the views previously registered by the owner
+func RegisterViews(owner string, views []v1.View) {
+	configViewsLock.Lock()
+	defer configViewsLock.Unlock()
+	for name, v := range configViews {
+		if v.owner == owner {
+			delete(configViews, name)
+		}
+	}
+	for _, view := range views {
+		configViews[view.Name] = configView{owner: owner, view: view}
+	}
+}
+
+// getView returns the view from the scraper configs, falling back to the views created through the API
+func getView(name string) (*v1.View, error) {
+	configViewsLock.RLock()
+	v, ok := configViews[name]
+	configViewsLock.RUnlock()
+	if ok {
+		return &v.view, nil
+	}
+	return db.GetView(name)
+}
+
+// ListViewsHandler returns all the views
+func ListViewsHandler(c echo.Context) error {
+	views, err := db.GetViews()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	configViewsLock.RLock()
+	for _, v := range configViews {
+		views = append(views, v.view)
+	}
+	configViewsLock.RUnlock()
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return c.JSONPretty(http.StatusOK, views, "  ")
+}
+
+// SaveViewHandler creates or updates a view from the request body
+func SaveViewHandler(c echo.Context) error {
+	var view v1.View
+	if err := c.Bind(&view); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	view.Name = c.Param("name")
+	if err := validateView(view); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := db.PersistView(view); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, view, "  ")
+}
+
+func DeleteViewHandler(c echo.Context) error {
+	if err := db.DeleteView(c.Param("name")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ViewHandler materializes a view
+func ViewHandler(c echo.Context) error {
+	view, err := getView(c.Param("name"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if view == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "view not found")
+	}
+
+	request := view.SearchRequest
+	request.Tenant = requestTenant(c)
+	result, err := db.SearchConfigItems(request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	fields, err := parseFields(view.Fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	var rows []map[string]interface{}
+	for _, item := range result.Results {
+		row := map[string]interface{}{
+			"id":             item.ID,
+			"name":           item.Name,
+			"config_type":    item.ConfigType,
+			"cost_total_30d": item.CostTotal30d,
+		}
+		if len(fields) > 0 && item.Config != nil {
+			config, err := oj.ParseString(*item.Config)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			for column, expr := range fields {
+				if values := expr.Get(config); len(values) > 0 {
+					row[column] = values[0]
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if view.Template != "" {
+		out, err := text.Template(view.Template, map[string]interface{}{"rows": rows, "total": result.Total})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return c.String(http.StatusOK, out)
+	}
+	return c.JSONPretty(http.StatusOK, map[string]interface{}{
+		"name":  view.Name,
+		"total": result.Total,
+		"rows":  rows,
+	}, "  ")
+}
+
+func validateView(view v1.View) error {
+	if view.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	_, err := parseFields(view.Fields)
+	return err
+}
+
+func parseFields(fields map[string]string) (map[string]jp.Expr, error) {
+	exprs := make(map[string]jp.Expr)
+	for column, path := range fields {
+		expr, err := jp.ParseString(path)
+		if err != nil {
+			return nil, err
+		}
+		exprs[column] = expr
+	}
+	return exprs, nil
+}
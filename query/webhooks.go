@@ -0,0 +1,43 @@
+package query
+
+import (
+	"net/http"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/notifications"
+	"github.com/labstack/echo/v4"
+)
+
+func ListWebhooksHandler(c echo.Context) error {
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSONPretty(http.StatusOK, webhooks, "  ")
+}
+
+// SaveWebhookHandler creates or updates a webhook from the request body
+func SaveWebhookHandler(c echo.Context) error {
+	var webhook v1.Webhook
+	if err := c.Bind(&webhook); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	webhook.Name = c.Param("name")
+	if err := notifications.Validate(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := db.PersistWebhook(webhook); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	notifications.Refresh()
+	return c.JSONPretty(http.StatusOK, webhook, "  ")
+}
+
+func DeleteWebhookHandler(c echo.Context) error {
+	if err := db.DeleteWebhook(c.Param("name")); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	notifications.Refresh()
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,151 @@
+// Package reports renders a summary of the open analysis results and exports it to a local path or S3
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/config-db/db"
+)
+
+var (
+	// Output is a directory or an s3://bucket/prefix url the reports are written to
+	Output string
+	// Formats of the exported reports, json, csv or html
+	Formats []string
+)
+
+// Report of the open analysis results
+type Report struct {
+	GeneratedAt time.Time            `json:"generated_at"`
+	Total       int                  `json:"total"`
+	BySeverity  map[string]int       `json:"by_severity"`
+	Rows        []db.AnalysisSummary `json:"rows"`
+}
+
+// Generate aggregates the analysis results
+func Generate() (*Report, error) {
+	rows, err := db.GetAnalysisSummary()
+	if err != nil {
+		return nil, err
+	}
+	report := Report{GeneratedAt: time.Now().UTC(), BySeverity: make(map[string]int), Rows: rows}
+	for _, row := range rows {
+		report.Total += row.Count
+		report.BySeverity[row.Severity] += row.Count
+	}
+	return &report, nil
+}
+
+// Render the report in the format
+func (r Report) Render(format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(r, "", "  ")
+	case "csv":
+		return r.csv()
+	case "html":
+		var buf bytes.Buffer
+		err := htmlTemplate.Execute(&buf, r)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("unknown report format %s, expected one of json, csv or html", format)
+}
+
+func (r Report) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"account", "namespace", "severity", "analysis_type", "count"})
+	for _, row := range r.Rows {
+		_ = w.Write([]string{row.Account, row.Namespace, row.Severity, row.AnalysisType, strconv.Itoa(row.Count)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Severities returns the severities of the report sorted by name
+func (r Report) Severities() []string {
+	var severities []string
+	for s := range r.BySeverity {
+		severities = append(severities, s)
+	}
+	sort.Strings(severities)
+	return severities
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>config-db posture report {{ .GeneratedAt.Format "2006-01-02" }}</title></head>
+<body>
+<h1>Posture report</h1>
+<p>Generated at {{ .GeneratedAt.Format "2006-01-02 15:04 MST" }}, {{ .Total }} open findings</p>
+<ul>
+{{- range $s := .Severities }}
+<li>{{ $s }}: {{ index $.BySeverity $s }}</li>
+{{- end }}
+</ul>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>Account</th><th>Namespace</th><th>Severity</th><th>Type</th><th>Count</th></tr>
+{{- range .Rows }}
+<tr><td>{{ .Account }}</td><td>{{ .Namespace }}</td><td>{{ .Severity }}</td><td>{{ .AnalysisType }}</td><td>{{ .Count }}</td></tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
+
+// Export generates a report and writes it in every format to the output
+func Export() error {
+	report, err := Generate()
+	if err != nil {
+		return err
+	}
+	name := "report-" + report.GeneratedAt.Format("2006-01-02T150405")
+	for _, format := range Formats {
+		data, err := report.Render(format)
+		if err != nil {
+			return err
+		}
+		if err := write(name+"."+format, data); err != nil {
+			return fmt.Errorf("failed to write %s report: %v", format, err)
+		}
+	}
+	return nil
+}
+
+func write(name string, data []byte) error {
+	if strings.HasPrefix(Output, "s3://") {
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(Output, "s3://"), "/")
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			return err
+		}
+		_, err = s3.NewFromConfig(cfg).PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket: &bucket,
+			Key:    strPtr(path.Join(prefix, name)),
+			Body:   bytes.NewReader(data),
+		})
+		return err
+	}
+	if err := os.MkdirAll(Output, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(Output, name), data, 0644)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
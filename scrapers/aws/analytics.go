@@ -0,0 +1,286 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/emr"
+	emrTypes "github.com/aws/aws-sdk-go-v2/service/emr/types"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/redshift"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// activeEMRClusterStates excludes terminated clusters, which are listed for up to 2 months after termination
+var activeEMRClusterStates = []emrTypes.ClusterState{
+	emrTypes.ClusterStateStarting,
+	emrTypes.ClusterStateBootstrapping,
+	emrTypes.ClusterStateRunning,
+	emrTypes.ClusterStateWaiting,
+	emrTypes.ClusterStateTerminating,
+}
+
+// iamRoleArn returns the ARN of a role that is referenced by name or ARN
+func iamRoleArn(role, account string) string {
+	if role == "" || strings.HasPrefix(role, "arn:") {
+		return role
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", account, role)
+}
+
+func (aws Scraper) redshiftClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Redshift") {
+		return
+	}
+	Redshift := ctx.client("redshift", func() interface{} { return redshift.NewFromConfig(*ctx.Session) }).(*redshift.Client)
+	paginator := redshift.NewDescribeClustersPaginator(Redshift, &redshift.DescribeClustersInput{})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe redshift clusters")
+			return
+		}
+		for _, cluster := range clusters.Clusters {
+			tags := make(v1.JSONStringMap)
+			for _, tag := range cluster.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+
+			// the cost and usage report identifies clusters by this ARN rather than by their namespace ARN
+			arn := fmt.Sprintf("arn:aws:redshift:%s:%s:cluster:%s", ctx.Session.Region, *ctx.Caller.Account, *cluster.ClusterIdentifier)
+			self := v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSRedshiftCluster}
+			var relationships v1.RelationshipResults
+			for _, group := range cluster.VpcSecurityGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  self,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{deref(group.VpcSecurityGroupId)}, ExternalType: v1.AWSEC2SecurityGroup},
+					Relationship:      "RedshiftClusterSecurityGroup",
+				})
+			}
+			for _, role := range cluster.IamRoles {
+				relationships = append(relationships, roleRelationship(deref(role.IamRoleArn), self, "RedshiftClusterRole"))
+			}
+			if cluster.Encrypted {
+				relationships = append(relationships, encryptedBy(self, deref(cluster.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSRedshiftCluster,
+				CreatedAt:           cluster.ClusterCreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster,
+				Type:                "RedshiftCluster",
+				Name:                *cluster.ClusterIdentifier,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Zone:                deref(cluster.AvailabilityZone),
+				Network:             deref(cluster.VpcId),
+				Aliases:             []string{*cluster.ClusterIdentifier, "AmazonRedshift/" + arn},
+				ID:                  arn,
+				Ignore:              []string{"ClusterCreateTime", "NextMaintenanceWindowStartTime", "ExpectedNextSnapshotScheduleTime", "ExpectedNextSnapshotScheduleTimeStatus"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// emrClusters scrapes the clusters that are not terminated, the EC2 instances of a cluster are scraped as instances
+func (aws Scraper) emrClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("EMR") {
+		return
+	}
+	EMR := ctx.client("emr", func() interface{} { return emr.NewFromConfig(*ctx.Session) }).(*emr.Client)
+	paginator := emr.NewListClustersPaginator(EMR, &emr.ListClustersInput{ClusterStates: activeEMRClusterStates})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list emr clusters")
+			return
+		}
+		for _, summary := range clusters.Clusters {
+			output, err := EMR.DescribeCluster(ctx, &emr.DescribeClusterInput{ClusterId: summary.Id})
+			if err != nil {
+				results.Errorf(err, "failed to describe emr cluster %s", *summary.Id)
+				continue
+			}
+			cluster := output.Cluster
+
+			tags := make(v1.JSONStringMap)
+			for _, tag := range cluster.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*cluster.Id}, ExternalType: v1.AWSEMRCluster}
+			var relationships v1.RelationshipResults
+			if role := deref(cluster.ServiceRole); role != "" {
+				relationships = append(relationships, roleRelationship(iamRoleArn(role, *ctx.Caller.Account), self, "EMRClusterRole"))
+			}
+			var subnet, zone string
+			if attributes := cluster.Ec2InstanceAttributes; attributes != nil {
+				subnet, zone = deref(attributes.Ec2SubnetId), deref(attributes.Ec2AvailabilityZone)
+				for _, group := range []*string{attributes.EmrManagedMasterSecurityGroup, attributes.EmrManagedSlaveSecurityGroup} {
+					if group == nil {
+						continue
+					}
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  self,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{*group}, ExternalType: v1.AWSEC2SecurityGroup},
+						Relationship:      "EMRClusterSecurityGroup",
+					})
+				}
+			}
+
+			var createdAt *time.Time
+			if cluster.Status != nil && cluster.Status.Timeline != nil {
+				createdAt = cluster.Status.Timeline.CreationDateTime
+			}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEMRCluster,
+				CreatedAt:           createdAt,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster,
+				Type:                "EMRCluster",
+				Name:                getName(tags, deref(cluster.Name)),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Subnet:              subnet,
+				Zone:                zone,
+				Aliases:             []string{deref(cluster.ClusterArn), "ElasticMapReduce/" + deref(cluster.ClusterArn)},
+				ID:                  *cluster.Id,
+				Ignore:              []string{"NormalizedInstanceHours", "Status"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// glue scrapes the jobs, crawlers and databases of the data catalog of the account
+func (aws Scraper) glue(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Glue") {
+		return
+	}
+	Glue := ctx.client("glue", func() interface{} { return glue.NewFromConfig(*ctx.Session) }).(*glue.Client)
+	glueArn := func(kind, name string) string {
+		return fmt.Sprintf("arn:aws:glue:%s:%s:%s/%s", ctx.Session.Region, *ctx.Caller.Account, kind, name)
+	}
+	glueTags := func(arn string) v1.JSONStringMap {
+		tags := make(v1.JSONStringMap)
+		output, err := Glue.GetTags(ctx, &glue.GetTagsInput{ResourceArn: &arn})
+		if err != nil {
+			results.Errorf(err, "failed to get tags of %s", arn)
+			return tags
+		}
+		for k, v := range output.Tags {
+			tags[k] = v
+		}
+		return tags
+	}
+
+	databases := glue.NewGetDatabasesPaginator(Glue, &glue.GetDatabasesInput{})
+	for databases.HasMorePages() {
+		page, err := databases.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get glue databases")
+			break
+		}
+		for _, database := range page.DatabaseList {
+			arn := glueArn("database", *database.Name)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSGlueDatabase,
+				CreatedAt:          database.CreateTime,
+				BaseScraper:        config.BaseScraper,
+				Config:             database,
+				Type:               "GlueDatabase",
+				Name:               *database.Name,
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{"AWSGlue/" + arn},
+				ID:                 arn,
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+
+	jobs := glue.NewGetJobsPaginator(Glue, &glue.GetJobsInput{})
+	for jobs.HasMorePages() {
+		page, err := jobs.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get glue jobs")
+			break
+		}
+		for _, job := range page.Jobs {
+			arn := glueArn("job", *job.Name)
+			self := v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSGlueJob}
+			var relationships v1.RelationshipResults
+			if role := deref(job.Role); role != "" {
+				relationships = append(relationships, roleRelationship(iamRoleArn(role, *ctx.Caller.Account), self, "GlueJobRole"))
+			}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSGlueJob,
+				CreatedAt:           job.CreatedOn,
+				Tags:                glueTags(arn),
+				BaseScraper:         config.BaseScraper,
+				Config:              job,
+				Type:                "GlueJob",
+				Name:                *job.Name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{"AWSGlue/" + arn},
+				ID:                  arn,
+				Ignore:              []string{"LastModifiedOn"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+
+	crawlers := glue.NewGetCrawlersPaginator(Glue, &glue.GetCrawlersInput{})
+	for crawlers.HasMorePages() {
+		page, err := crawlers.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get glue crawlers")
+			break
+		}
+		for _, crawler := range page.Crawlers {
+			arn := glueArn("crawler", *crawler.Name)
+			self := v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSGlueCrawler}
+			var relationships v1.RelationshipResults
+			if role := deref(crawler.Role); role != "" {
+				relationships = append(relationships, roleRelationship(iamRoleArn(role, *ctx.Caller.Account), self, "GlueCrawlerRole"))
+			}
+			if database := deref(crawler.DatabaseName); database != "" {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  self,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{glueArn("database", database)}, ExternalType: v1.AWSGlueDatabase},
+					Relationship:      "GlueCrawlerDatabase",
+				})
+			}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSGlueCrawler,
+				CreatedAt:           crawler.CreationTime,
+				Tags:                glueTags(arn),
+				BaseScraper:         config.BaseScraper,
+				Config:              crawler,
+				Type:                "GlueCrawler",
+				Name:                *crawler.Name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{"AWSGlue/" + arn},
+				ID:                  arn,
+				Ignore:              []string{"LastCrawl", "LastUpdated", "CrawlElapsedTime", "State"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/apigateway"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewayv2"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) apiGateways(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("APIGateway") {
+		return
+	}
+
+	aws.restAPIs(ctx, config, results)
+	aws.httpAPIs(ctx, config, results)
+}
+
+func (aws Scraper) restAPIs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	APIGateway := ctx.client("apigateway", func() interface{} { return apigateway.NewFromConfig(*ctx.Session) }).(*apigateway.Client)
+
+	// VPC links point a REST API integration at one or more network load balancers
+	vpcLinks := map[string][]string{}
+	links := apigateway.NewGetVpcLinksPaginator(APIGateway, &apigateway.GetVpcLinksInput{})
+	for links.HasMorePages() {
+		output, err := links.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get api gateway vpc links")
+			break
+		}
+		for _, link := range output.Items {
+			vpcLinks[*link.Id] = link.TargetArns
+		}
+	}
+
+	apis := apigateway.NewGetRestApisPaginator(APIGateway, &apigateway.GetRestApisInput{})
+	for apis.HasMorePages() {
+		output, err := apis.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get api gateway rest apis")
+			return
+		}
+
+		for _, api := range output.Items {
+			arn := fmt.Sprintf("arn:aws:apigateway:%s::/restapis/%s", ctx.Session.Region, *api.Id)
+			tags := v1.JSONStringMap(api.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSAPIGatewayRestAPI,
+				CreatedAt:          api.CreatedDate,
+				BaseScraper:        config.BaseScraper,
+				Config:             api,
+				Tags:               tags,
+				Type:               "APIGateway",
+				Name:               getName(tags, *api.Name),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{arn, "AmazonApiGateway/" + arn},
+				ID:                 *api.Id,
+				Ignore:             []string{"createdDate"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+
+			stages, err := APIGateway.GetStages(ctx, &apigateway.GetStagesInput{RestApiId: api.Id})
+			if err != nil {
+				results.Errorf(err, "failed to get stages of rest api %s", *api.Id)
+			} else {
+				for _, stage := range stages.Item {
+					*results = append(*results, v1.ScrapeResult{
+						ExternalType:       v1.AWSAPIGatewayStage,
+						CreatedAt:          stage.CreatedDate,
+						BaseScraper:        config.BaseScraper,
+						Config:             stage,
+						Tags:               stage.Tags,
+						Type:               "APIGatewayStage",
+						Name:               *api.Name + "/" + *stage.StageName,
+						Account:            *ctx.Caller.Account,
+						Region:             ctx.Session.Region,
+						ID:                 *api.Id + "/" + *stage.StageName,
+						Ignore:             []string{"createdDate", "lastUpdatedDate"},
+						ParentExternalID:   *api.Id,
+						ParentExternalType: v1.AWSAPIGatewayRestAPI,
+					})
+				}
+			}
+
+			// REST APIs have no separate routes or integrations, every method on a resource is a
+			// route and carries its integration inline
+			resources := apigateway.NewGetResourcesPaginator(APIGateway, &apigateway.GetResourcesInput{
+				RestApiId: api.Id,
+				Embed:     []string{"methods"},
+			})
+			for resources.HasMorePages() {
+				output, err := resources.NextPage(ctx)
+				if err != nil {
+					results.Errorf(err, "failed to get resources of rest api %s", *api.Id)
+					break
+				}
+				for _, resource := range output.Items {
+					for httpMethod, method := range resource.ResourceMethods {
+						id := fmt.Sprintf("%s/%s/%s", *api.Id, *resource.Id, httpMethod)
+
+						var relationships v1.RelationshipResults
+						if integration := method.MethodIntegration; integration != nil {
+							if fn := getLambdaFunctionArn(deref(integration.Uri)); fn != "" {
+								relationships = append(relationships, apiGatewayRelationship(id, v1.AWSAPIGatewayRoute, fn, v1.AWSLambdaFunction, "APIGatewayLambda"))
+							}
+							if integration.ConnectionId != nil {
+								for _, target := range vpcLinks[*integration.ConnectionId] {
+									relationships = append(relationships, apiGatewayRelationship(id, v1.AWSAPIGatewayRoute, target, v1.AWSLoadBalancerV2, "APIGatewayLoadBalancer"))
+								}
+							}
+						}
+
+						*results = append(*results, v1.ScrapeResult{
+							ExternalType:        v1.AWSAPIGatewayRoute,
+							BaseScraper:         config.BaseScraper,
+							Config:              method,
+							Type:                "APIGatewayRoute",
+							Name:                fmt.Sprintf("%s %s", httpMethod, deref(resource.Path)),
+							Account:             *ctx.Caller.Account,
+							Region:              ctx.Session.Region,
+							ID:                  id,
+							ParentExternalID:    *api.Id,
+							ParentExternalType:  v1.AWSAPIGatewayRestAPI,
+							RelationshipResults: relationships,
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+func (aws Scraper) httpAPIs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	APIGateway := ctx.client("apigatewayv2", func() interface{} { return apigatewayv2.NewFromConfig(*ctx.Session) }).(*apigatewayv2.Client)
+
+	input := &apigatewayv2.GetApisInput{}
+	for {
+		output, err := APIGateway.GetApis(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to get api gateway v2 apis")
+			return
+		}
+
+		for _, api := range output.Items {
+			arn := fmt.Sprintf("arn:aws:apigateway:%s::/apis/%s", ctx.Session.Region, *api.ApiId)
+			tags := v1.JSONStringMap(api.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSAPIGatewayV2API,
+				CreatedAt:          api.CreatedDate,
+				BaseScraper:        config.BaseScraper,
+				Config:             api,
+				Tags:               tags,
+				Type:               "APIGateway",
+				Name:               getName(tags, *api.Name),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{arn, "AmazonApiGateway/" + arn},
+				ID:                 *api.ApiId,
+				Ignore:             []string{"createdDate"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+
+			aws.httpAPIStages(ctx, config, APIGateway, *api.ApiId, *api.Name, results)
+			aws.httpAPIRoutes(ctx, config, APIGateway, *api.ApiId, results)
+			aws.httpAPIIntegrations(ctx, config, APIGateway, *api.ApiId, results)
+		}
+
+		if output.NextToken == nil {
+			return
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (aws Scraper) httpAPIStages(ctx *AWSContext, config v1.AWS, client *apigatewayv2.Client, apiID, apiName string, results *v1.ScrapeResults) {
+	input := &apigatewayv2.GetStagesInput{ApiId: &apiID}
+	for {
+		output, err := client.GetStages(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to get stages of api %s", apiID)
+			return
+		}
+		for _, stage := range output.Items {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSAPIGatewayV2Stage,
+				CreatedAt:          stage.CreatedDate,
+				BaseScraper:        config.BaseScraper,
+				Config:             stage,
+				Tags:               stage.Tags,
+				Type:               "APIGatewayStage",
+				Name:               apiName + "/" + *stage.StageName,
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				ID:                 apiID + "/" + *stage.StageName,
+				Ignore:             []string{"createdDate", "lastUpdatedDate", "lastDeploymentStatusMessage"},
+				ParentExternalID:   apiID,
+				ParentExternalType: v1.AWSAPIGatewayV2API,
+			})
+		}
+		if output.NextToken == nil {
+			return
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (aws Scraper) httpAPIRoutes(ctx *AWSContext, config v1.AWS, client *apigatewayv2.Client, apiID string, results *v1.ScrapeResults) {
+	input := &apigatewayv2.GetRoutesInput{ApiId: &apiID}
+	for {
+		output, err := client.GetRoutes(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to get routes of api %s", apiID)
+			return
+		}
+		for _, route := range output.Items {
+			id := apiID + "/" + *route.RouteId
+
+			var relationships v1.RelationshipResults
+			if target := deref(route.Target); strings.HasPrefix(target, "integrations/") {
+				integrationID := apiID + "/" + strings.TrimPrefix(target, "integrations/")
+				relationships = append(relationships, apiGatewayRelationship(id, v1.AWSAPIGatewayV2Route, integrationID, v1.AWSAPIGatewayV2Integration, "APIGatewayRouteIntegration"))
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSAPIGatewayV2Route,
+				BaseScraper:         config.BaseScraper,
+				Config:              route,
+				Type:                "APIGatewayRoute",
+				Name:                *route.RouteKey,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  id,
+				ParentExternalID:    apiID,
+				ParentExternalType:  v1.AWSAPIGatewayV2API,
+				RelationshipResults: relationships,
+			})
+		}
+		if output.NextToken == nil {
+			return
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (aws Scraper) httpAPIIntegrations(ctx *AWSContext, config v1.AWS, client *apigatewayv2.Client, apiID string, results *v1.ScrapeResults) {
+	input := &apigatewayv2.GetIntegrationsInput{ApiId: &apiID}
+	for {
+		output, err := client.GetIntegrations(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to get integrations of api %s", apiID)
+			return
+		}
+		for _, integration := range output.Items {
+			id := apiID + "/" + *integration.IntegrationId
+			uri := deref(integration.IntegrationUri)
+
+			var relationships v1.RelationshipResults
+			if fn := getLambdaFunctionArn(uri); fn != "" {
+				relationships = append(relationships, apiGatewayRelationship(id, v1.AWSAPIGatewayV2Integration, fn, v1.AWSLambdaFunction, "APIGatewayLambda"))
+			} else if lb := getLoadBalancerArnFromListener(uri); lb != "" {
+				relationships = append(relationships, apiGatewayRelationship(id, v1.AWSAPIGatewayV2Integration, lb, v1.AWSLoadBalancerV2, "APIGatewayLoadBalancer"))
+			}
+
+			name := string(integration.IntegrationType)
+			if uri != "" {
+				name += " " + uri
+			}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSAPIGatewayV2Integration,
+				BaseScraper:         config.BaseScraper,
+				Config:              integration,
+				Type:                "APIGatewayIntegration",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  id,
+				ParentExternalID:    apiID,
+				ParentExternalType:  v1.AWSAPIGatewayV2API,
+				RelationshipResults: relationships,
+			})
+		}
+		if output.NextToken == nil {
+			return
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func apiGatewayRelationship(id, externalType, relatedID, relatedType, relationship string) v1.RelationshipResult {
+	return v1.RelationshipResult{
+		ConfigExternalID: v1.ExternalID{
+			ExternalID:   []string{id},
+			ExternalType: externalType,
+		},
+		RelatedExternalID: v1.ExternalID{
+			ExternalID:   []string{relatedID},
+			ExternalType: relatedType,
+		},
+		Relationship: relationship,
+	}
+}
+
+// getLoadBalancerArnFromListener converts an ALB/NLB listener ARN used by private integrations
+// arn:aws:elasticloadbalancing:<region>:<account>:listener/app/<name>/<lb-id>/<listener-id>
+// into the ARN of the load balancer it belongs to
+func getLoadBalancerArnFromListener(arn string) string {
+	if !strings.HasPrefix(arn, "arn:aws:elasticloadbalancing:") || !strings.Contains(arn, ":listener/") {
+		return ""
+	}
+	parts := strings.Split(arn, "/")
+	if len(parts) != 5 {
+		return ""
+	}
+	return strings.Replace(strings.Join(parts[:4], "/"), ":listener/", ":loadbalancer/", 1)
+}
@@ -0,0 +1,256 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	autoscalingTypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go/ptr"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// scalingActivities is the number of the latest scaling activities of a group that are recorded as changes
+const scalingActivities = 20
+
+func (aws Scraper) autoScalingGroups(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("AutoScaling") {
+		return
+	}
+
+	AutoScaling := ctx.client("autoscaling", func() interface{} { return autoscaling.NewFromConfig(*ctx.Session) }).(*autoscaling.Client)
+	groups := autoscaling.NewDescribeAutoScalingGroupsPaginator(AutoScaling, &autoscaling.DescribeAutoScalingGroupsInput{})
+	for groups.HasMorePages() {
+		output, err := groups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe auto scaling groups")
+			return
+		}
+		for _, group := range output.AutoScalingGroups {
+			id := v1.ExternalID{
+				ExternalID:   []string{*group.AutoScalingGroupName},
+				ExternalType: v1.AWSAutoScalingGroup,
+			}
+			var relationships v1.RelationshipResults
+			for _, instance := range group.Instances {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  id,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{*instance.InstanceId}, ExternalType: v1.AWSEC2Instance},
+					Relationship:      "AutoScalingGroupInstance",
+				})
+			}
+			for _, lb := range group.LoadBalancerNames {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{lb}, ExternalType: v1.AWSLoadBalancer},
+					RelatedExternalID: id,
+					Relationship:      "LoadBalancerAutoScalingGroup",
+				})
+			}
+			if template := launchTemplateOf(group); template != nil && template.LaunchTemplateId != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*template.LaunchTemplateId}, ExternalType: v1.AWSEC2LaunchTemplate},
+					RelatedExternalID: id,
+					Relationship:      "LaunchTemplateAutoScalingGroup",
+				})
+			}
+			if group.LaunchConfigurationName != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*group.LaunchConfigurationName}, ExternalType: v1.AWSAutoScalingLaunchConfiguration},
+					RelatedExternalID: id,
+					Relationship:      "LaunchConfigurationAutoScalingGroup",
+				})
+			}
+
+			tags := make(v1.JSONStringMap)
+			for _, tag := range group.Tags {
+				tags[*tag.Key] = deref(tag.Value)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType: v1.AWSAutoScalingGroup,
+				CreatedAt:    group.CreatedTime,
+				Tags:         tags,
+				BaseScraper:  config.BaseScraper,
+				Config:       group,
+				Type:         "AutoScalingGroup",
+				Name:         getName(tags, *group.AutoScalingGroupName),
+				Account:      *ctx.Caller.Account,
+				Region:       ctx.Session.Region,
+				Aliases:      []string{*group.AutoScalingGroupARN},
+				ID:           *group.AutoScalingGroupName,
+				// the instances are related to the group, their churn is recorded as scaling activities
+				Ignore:              []string{"CreatedTime", "Instances"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+
+			aws.scalingActivities(ctx, AutoScaling, *group.AutoScalingGroupName, results)
+		}
+	}
+
+	aws.launchConfigurations(ctx, config, AutoScaling, results)
+}
+
+// launchTemplateOf returns the launch template of the group, either its own or the one of its mixed instances policy
+func launchTemplateOf(group autoscalingTypes.AutoScalingGroup) *autoscalingTypes.LaunchTemplateSpecification {
+	if group.LaunchTemplate != nil {
+		return group.LaunchTemplate
+	}
+	if group.MixedInstancesPolicy != nil && group.MixedInstancesPolicy.LaunchTemplate != nil {
+		return group.MixedInstancesPolicy.LaunchTemplate.LaunchTemplateSpecification
+	}
+	return nil
+}
+
+// scalingActivities records the latest scaling activities of the group as changes of the group, so that
+// instances launched and terminated by the group are not mistaken for unrelated changes
+func (aws Scraper) scalingActivities(ctx *AWSContext, client *autoscaling.Client, group string, results *v1.ScrapeResults) {
+	activities, err := client.DescribeScalingActivities(ctx, &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: strPtr(group),
+		MaxRecords:           ptr.Int32(scalingActivities),
+	})
+	if err != nil {
+		results.Errorf(err, "failed to describe scaling activities of %s", group)
+		return
+	}
+	for _, activity := range activities.Activities {
+		// activities are only recorded once they are done
+		if activity.EndTime == nil {
+			continue
+		}
+		change := v1.ChangeResult{
+			ExternalID:       group,
+			ExternalType:     v1.AWSAutoScalingGroup,
+			ExternalChangeID: *activity.ActivityId,
+			ChangeType:       "ScalingActivity",
+			Summary:          deref(activity.Description),
+			Source:           fmt.Sprintf("AWS::AutoScaling::%s:%s", ctx.Session.Region, *ctx.Caller.Account),
+			CreatedAt:        activity.StartTime,
+			Details: map[string]interface{}{
+				"cause":         deref(activity.Cause),
+				"status":        string(activity.StatusCode),
+				"statusMessage": deref(activity.StatusMessage),
+			},
+		}
+		if activity.StatusCode == autoscalingTypes.ScalingActivityStatusCodeFailed {
+			change.Severity = "high"
+		}
+		results.AddChange(change)
+	}
+}
+
+func (aws Scraper) launchConfigurations(ctx *AWSContext, config v1.AWS, client *autoscaling.Client, results *v1.ScrapeResults) {
+	configurations := autoscaling.NewDescribeLaunchConfigurationsPaginator(client, &autoscaling.DescribeLaunchConfigurationsInput{})
+	for configurations.HasMorePages() {
+		output, err := configurations.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe launch configurations")
+			return
+		}
+		for _, configuration := range output.LaunchConfigurations {
+			relationships := launchRelationships(v1.ExternalID{
+				ExternalID:   []string{*configuration.LaunchConfigurationName},
+				ExternalType: v1.AWSAutoScalingLaunchConfiguration,
+			}, configuration.ImageId, configuration.SecurityGroups)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSAutoScalingLaunchConfiguration,
+				CreatedAt:           configuration.CreatedTime,
+				BaseScraper:         config.BaseScraper,
+				Config:              configuration,
+				Type:                "LaunchConfiguration",
+				Name:                *configuration.LaunchConfigurationName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*configuration.LaunchConfigurationARN},
+				ID:                  *configuration.LaunchConfigurationName,
+				Ignore:              []string{"CreatedTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// launchTemplate is a launch template with the data of its latest version
+type launchTemplate struct {
+	types.LaunchTemplate
+	LatestVersion *types.ResponseLaunchTemplateData `json:"LatestVersion,omitempty"`
+}
+
+func (aws Scraper) launchTemplates(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("LaunchTemplate") {
+		return
+	}
+
+	templates := ec2.NewDescribeLaunchTemplatesPaginator(ctx.EC2, &ec2.DescribeLaunchTemplatesInput{})
+	for templates.HasMorePages() {
+		output, err := templates.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe launch templates")
+			return
+		}
+		for _, template := range output.LaunchTemplates {
+			result := launchTemplate{LaunchTemplate: template}
+			versions, err := ctx.EC2.DescribeLaunchTemplateVersions(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+				LaunchTemplateId: template.LaunchTemplateId,
+				Versions:         []string{"$Latest"},
+			})
+			if err != nil {
+				results.Errorf(err, "failed to describe the latest version of launch template %s", *template.LaunchTemplateId)
+			} else if len(versions.LaunchTemplateVersions) > 0 {
+				result.LatestVersion = versions.LaunchTemplateVersions[0].LaunchTemplateData
+			}
+
+			var relationships v1.RelationshipResults
+			if data := result.LatestVersion; data != nil {
+				relationships = launchRelationships(v1.ExternalID{
+					ExternalID:   []string{*template.LaunchTemplateId},
+					ExternalType: v1.AWSEC2LaunchTemplate,
+				}, data.ImageId, data.SecurityGroupIds)
+			}
+
+			tags := getTags(template.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2LaunchTemplate,
+				CreatedAt:           template.CreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              result,
+				Type:                "LaunchTemplate",
+				Name:                getName(tags, *template.LaunchTemplateName),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*template.LaunchTemplateName},
+				ID:                  *template.LaunchTemplateId,
+				Ignore:              []string{"CreateTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// launchRelationships relates the AMI and security groups that instances are launched with to the launch template
+// or configuration
+func launchRelationships(id v1.ExternalID, imageID *string, securityGroups []string) v1.RelationshipResults {
+	var relationships v1.RelationshipResults
+	if imageID != nil {
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  id,
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{*imageID}, ExternalType: v1.AWSEC2AMI},
+			Relationship:      "LaunchAMI",
+		})
+	}
+	for _, sg := range securityGroups {
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  id,
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{sg}, ExternalType: v1.AWSEC2SecurityGroup},
+			Relationship:      "LaunchSecurityGroup",
+		})
+	}
+	return relationships
+}
@@ -2,23 +2,17 @@ package aws
 
 import (
 	"fmt"
+	"sort"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/configservice"
 	ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/ecr"
-	"github.com/aws/aws-sdk-go-v2/service/efs"
-	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/aws/aws-sdk-go-v2/service/rds"
-	"github.com/aws/aws-sdk-go-v2/service/route53"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/aws/smithy-go/ptr"
 
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
@@ -44,6 +38,33 @@ type AWSContext struct {
 	SSM     *ssm.Client
 	Config  *configservice.Client
 	Subnets map[string]Zone
+	// clients of the other services, shared by the contexts of the same connection, region and role
+	clients *sync.Map
+}
+
+// awsClients are the clients of a connection that are reused across scrapes
+type awsClients struct {
+	session *aws.Config
+	caller  *sts.GetCallerIdentityOutput
+	sts     *sts.Client
+	support *support.Client
+	ec2     *ec2.Client
+	ssm     *ssm.Client
+	iam     *iam.Client
+	config  *configservice.Client
+	others  *sync.Map
+}
+
+// client returns the client of a service, created once per connection
+func (ctx *AWSContext) client(service string, create func() interface{}) interface{} {
+	if ctx.clients == nil {
+		return create()
+	}
+	if client, ok := ctx.clients.Load(service); ok {
+		return client
+	}
+	client, _ := ctx.clients.LoadOrStore(service, create())
+	return client
 }
 
 func getTags(tags []types.Tag) v1.JSONStringMap {
@@ -58,31 +79,55 @@ func (ctx AWSContext) String() string {
 	return fmt.Sprintf("account=%s user=%s region=%s", *ctx.Caller.Account, *ctx.Caller.UserId, ctx.Session.Region)
 }
 
-func (aws Scraper) getContext(ctx *v1.ScrapeContext, awsConfig v1.AWS, region string) (*AWSContext, error) {
-	session, err := NewSession(ctx, *awsConfig.AWSConnection, region)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create AWS session")
-	}
-	STS := sts.NewFromConfig(*session)
-	caller, err := STS.GetCallerIdentity(ctx, nil)
+// getContext creates a session for the region, when accountRole is set the role is assumed
+// on top of the connection credentials to scrape another account of the organization
+func (aws Scraper) getContext(ctx *v1.ScrapeContext, awsConfig v1.AWS, region string, accountRole string) (*AWSContext, error) {
+	key := fmt.Sprintf("aws/context/%s/%s/%s", connectionKey(ctx, *awsConfig.AWSConnection), region, accountRole)
+	cached, err := ctx.Connections.Get(key, func() (interface{}, error) {
+		session, err := NewSession(ctx, *awsConfig.AWSConnection, region)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create AWS session")
+		}
+		if accountRole != "" {
+			assumeRole(session, accountRole, awsConfig.ExternalID, awsConfig.SessionName)
+		}
+		STS := sts.NewFromConfig(*session)
+		caller, err := STS.GetCallerIdentity(ctx, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get identity")
+		}
+
+		usEast1 := session.Copy()
+		usEast1.Region = "us-east-1"
+		return &awsClients{
+			session: session,
+			caller:  caller,
+			sts:     STS,
+			support: support.NewFromConfig(usEast1),
+			ec2:     ec2.NewFromConfig(*session),
+			ssm:     ssm.NewFromConfig(*session),
+			iam:     iam.NewFromConfig(*session),
+			config:  configservice.NewFromConfig(*session),
+			others:  &sync.Map{},
+		}, nil
+	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get identity")
+		return nil, err
 	}
-
-	usEast1 := session.Copy()
-	usEast1.Region = "us-east-1"
+	clients := cached.(*awsClients)
 
 	return &AWSContext{
 		ScrapeContext: ctx,
-		Session:       session,
-		Caller:        caller,
-		STS:           STS,
-		Support:       support.NewFromConfig(usEast1),
-		EC2:           ec2.NewFromConfig(*session),
-		SSM:           ssm.NewFromConfig(*session),
-		IAM:           iam.NewFromConfig(*session),
+		Session:       clients.session,
+		Caller:        clients.caller,
+		STS:           clients.sts,
+		Support:       clients.support,
+		EC2:           clients.ec2,
+		SSM:           clients.ssm,
+		IAM:           clients.iam,
 		Subnets:       make(map[string]Zone),
-		Config:        configservice.NewFromConfig(*session),
+		Config:        clients.config,
+		clients:       clients.others,
 	}, nil
 }
 
@@ -101,106 +146,6 @@ type Zone struct {
 	Region, Zone string
 }
 
-func (aws Scraper) containerImages(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("ECR") {
-		return
-	}
-
-	ECR := ecr.NewFromConfig(*ctx.Session)
-	images, err := ECR.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{})
-	if err != nil {
-		results.Errorf(err, "failed to get ecr")
-		return
-	}
-	for _, image := range images.Repositories {
-		*results = append(*results, v1.ScrapeResult{
-			CreatedAt:    image.CreatedAt,
-			ExternalType: "AWS::ECR::Repository",
-			BaseScraper:  config.BaseScraper,
-			Config:       image,
-			Type:         "Container",
-			Name:         *image.RepositoryName,
-			Aliases:      []string{*image.RepositoryArn, "AmazonECR/" + *image.RepositoryArn},
-			Account:      *ctx.Caller.Account,
-			ID:           *image.RepositoryUri,
-			Ignore: []string{
-				"CreatedAt", "RepositoryArn", "RepositoryUri", "RegistryId", "RepositoryName",
-			},
-			ParentExternalID:   *ctx.Caller.Account,
-			ParentExternalType: v1.AWSAccount,
-		})
-	}
-}
-
-func (aws Scraper) eksClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("EKS") {
-		return
-	}
-	EKS := eks.NewFromConfig(*ctx.Session)
-	clusters, err := EKS.ListClusters(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to list clusters")
-		return
-	}
-	for _, clusterName := range clusters.Clusters {
-		cluster, err := EKS.DescribeCluster(ctx, &eks.DescribeClusterInput{
-			Name: strPtr(clusterName),
-		})
-		if err != nil {
-			results.Errorf(err, "failed to describe cluster")
-			continue
-		}
-
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:       v1.AWSEKSCluster,
-			CreatedAt:          cluster.Cluster.CreatedAt,
-			Tags:               cluster.Cluster.Tags,
-			BaseScraper:        config.BaseScraper,
-			Config:             cluster.Cluster,
-			Type:               "EKS",
-			Network:            *cluster.Cluster.ResourcesVpcConfig.VpcId,
-			Name:               getName(cluster.Cluster.Tags, clusterName),
-			Account:            *ctx.Caller.Account,
-			Aliases:            []string{*cluster.Cluster.Arn, "AmazonEKS/" + *cluster.Cluster.Arn},
-			ID:                 *cluster.Cluster.Name,
-			Ignore:             []string{"createdAt", "name"},
-			ParentExternalID:   *cluster.Cluster.ResourcesVpcConfig.VpcId,
-			ParentExternalType: v1.AWSEC2VPC,
-		})
-	}
-}
-
-func (aws Scraper) efs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("EFS") {
-		return
-	}
-	describeInput := &efs.DescribeFileSystemsInput{}
-	EFS := efs.NewFromConfig(*ctx.Session)
-	describeOutput, err := EFS.DescribeFileSystems(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to get efs")
-		return
-	}
-	for _, fs := range describeOutput.FileSystems {
-
-		tags := make(v1.JSONStringMap)
-		for _, tag := range fs.Tags {
-			tags[*tag.Key] = *tag.Value
-		}
-
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: "AWS::EFS::FileSystem",
-			Tags:         tags,
-			BaseScraper:  config.BaseScraper,
-			Config:       fs,
-			Type:         "EFS",
-			Name:         getName(tags, *fs.FileSystemId),
-			Account:      *ctx.Caller.Account,
-			ID:           *fs.FileSystemId,
-		})
-	}
-}
-
 func (aws Scraper) account(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
 	if !config.Includes("Account") {
 		return
@@ -265,32 +210,6 @@ func (aws Scraper) account(ctx *AWSContext, config v1.AWS, results *v1.ScrapeRes
 	}
 }
 
-func (aws Scraper) users(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("User") {
-		return
-	}
-	users, err := ctx.IAM.ListUsers(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to get users")
-		return
-	}
-
-	for _, user := range users.Users {
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: "AWS::IAM::User",
-			CreatedAt:    user.CreateDate,
-			BaseScraper:  config.BaseScraper,
-			Config:       user,
-			Type:         "User",
-			Name:         *user.UserName,
-			Account:      *ctx.Caller.Account,
-			Aliases:      []string{*user.UserId, *user.Arn},
-			Ignore:       []string{"arn", "userId", "createDate", "userName"},
-			ID:           *user.UserName, // UserId is not often referenced
-		})
-	}
-}
-
 func (aws Scraper) ebs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
 	if !config.Includes("EBS") {
 		return
@@ -312,101 +231,12 @@ func (aws Scraper) ebs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults
 			Aliases:      []string{"AmazonEC2/" + *volume.VolumeId},
 			Name:         getName(tags, *volume.VolumeId),
 			Account:      *ctx.Caller.Account,
+			Region:       ctx.Session.Region,
 			ID:           *volume.VolumeId,
-		})
-	}
-}
-
-func (aws Scraper) rds(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("RDS") {
-		return
-	}
-	describeInput := &rds.DescribeDBInstancesInput{}
-	RDS := rds.NewFromConfig(*ctx.Session)
-	describeOutput, err := RDS.DescribeDBInstances(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to get rds")
-		return
-	}
-	for _, instance := range describeOutput.DBInstances {
-		tags := make(v1.JSONStringMap)
-		for _, tag := range instance.TagList {
-			tags[*tag.Key] = *tag.Value
-		}
-
-		var relationships v1.RelationshipResults
-		// SecurityGroup relationships
-		for _, sg := range instance.VpcSecurityGroups {
-			relationships = append(relationships, v1.RelationshipResult{
-				ConfigExternalID: v1.ExternalID{
-					ExternalID:   []string{*instance.DBInstanceIdentifier},
-					ExternalType: v1.AWSRDSInstance,
-				},
-				RelatedExternalID: v1.ExternalID{
-					ExternalID:   []string{*sg.VpcSecurityGroupId},
-					ExternalType: v1.AWSEC2SecurityGroup,
-				},
-				Relationship: "RDSSecurityGroup",
-			})
-		}
-
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:        v1.AWSRDSInstance,
-			Tags:                tags,
-			BaseScraper:         config.BaseScraper,
-			Config:              instance,
-			Type:                "RDS",
-			Name:                getName(tags, *instance.DBInstanceIdentifier),
-			Account:             *ctx.Caller.Account,
-			ID:                  *instance.DBInstanceIdentifier,
-			Aliases:             []string{"AmazonRDS/" + *instance.DBInstanceArn},
-			ParentExternalID:    *instance.DBSubnetGroup.VpcId,
-			ParentExternalType:  v1.AWSEC2VPC,
-			RelationshipResults: relationships,
-		})
-	}
-}
-
-func (aws Scraper) vpcs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("VPC") {
-		return
-	}
-	describeInput := &ec2.DescribeVpcsInput{}
-	describeOutput, err := ctx.EC2.DescribeVpcs(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to get vpcs")
-		return
-	}
-	for _, vpc := range describeOutput.Vpcs {
-		var relationships v1.RelationshipResults
-		// DHCPOptions relationship
-		relationships = append(relationships, v1.RelationshipResult{
-			ConfigExternalID: v1.ExternalID{
-				ExternalID:   []string{*vpc.VpcId},
-				ExternalType: v1.AWSEC2VPC,
-			},
-			RelatedExternalID: v1.ExternalID{
-				ExternalID:   []string{*vpc.DhcpOptionsId},
-				ExternalType: v1.AWSEC2DHCPOptions,
-			},
-			Relationship: "VPCDHCPOptions",
-		})
-
-		tags := getTags(vpc.Tags)
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:        v1.AWSEC2VPC,
-			Tags:                tags,
-			BaseScraper:         config.BaseScraper,
-			Config:              vpc,
-			Type:                "VPC",
-			Network:             *vpc.VpcId,
-			Name:                getName(tags, *vpc.VpcId),
-			Account:             *ctx.Caller.Account,
-			ID:                  *vpc.VpcId,
-			Aliases:             []string{"AmazonEC2/" + *vpc.VpcId},
-			ParentExternalID:    *ctx.Caller.Account,
-			ParentExternalType:  v1.AWSAccount,
-			RelationshipResults: relationships,
+			RelationshipResults: encryptedBy(v1.ExternalID{
+				ExternalID:   []string{*volume.VolumeId},
+				ExternalType: v1.AWSEBSVolume,
+			}, deref(volume.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account),
 		})
 	}
 }
@@ -456,6 +286,17 @@ func (aws Scraper) instances(ctx *AWSContext, config v1.AWS, results *v1.ScrapeR
 						Relationship: "EKSNode",
 					})
 				}
+				// instances launched by a group are replaced by it, their churn is recorded as its scaling activities
+				if *tag.Key == "aws:autoscaling:groupName" {
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID: v1.ExternalID{
+							ExternalID:   []string{*tag.Value},
+							ExternalType: v1.AWSAutoScalingGroup,
+						},
+						RelatedExternalID: selfExternalID,
+						Relationship:      "AutoScalingGroupInstance",
+					})
+				}
 			}
 
 			// Volume relationships
@@ -522,145 +363,11 @@ func (aws Scraper) instances(ctx *AWSContext, config v1.AWS, results *v1.ScrapeR
 	}
 }
 
-func (aws Scraper) securityGroups(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("SecurityGroup") {
-		return
-	}
-	describeInput := &ec2.DescribeSecurityGroupsInput{}
-	describeOutput, err := ctx.EC2.DescribeSecurityGroups(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to describe security groups")
-		return
-	}
-	for _, sg := range describeOutput.SecurityGroups {
-		tags := getTags(sg.Tags)
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:       "AWS::EC2::SecurityGroup",
-			Tags:               tags,
-			BaseScraper:        config.BaseScraper,
-			Config:             sg,
-			Type:               "SecurityGroup",
-			Network:            *sg.VpcId,
-			Name:               getName(tags, *sg.GroupId),
-			Account:            *ctx.Caller.Account,
-			ID:                 *sg.GroupId,
-			ParentExternalID:   *sg.VpcId,
-			ParentExternalType: v1.AWSEC2VPC,
-		})
-	}
-}
-
-func (aws Scraper) routes(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("Route") {
-		return
-	}
-	describeInput := &ec2.DescribeRouteTablesInput{}
-	describeOutput, err := ctx.EC2.DescribeRouteTables(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to describe route tables")
-		return
-	}
-	for _, r := range describeOutput.RouteTables {
-		tags := getTags(r.Tags)
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:       "AWS::EC2::RouteTable",
-			Tags:               tags,
-			BaseScraper:        config.BaseScraper,
-			Config:             r,
-			Type:               "Route",
-			Network:            *r.VpcId,
-			Name:               getName(tags, *r.RouteTableId),
-			Account:            *ctx.Caller.Account,
-			ID:                 *r.RouteTableId,
-			ParentExternalID:   *r.VpcId,
-			ParentExternalType: v1.AWSEC2VPC,
-		})
-	}
-}
-
-func (aws Scraper) dhcp(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("DHCP") {
-		return
-	}
-	describeInput := &ec2.DescribeDhcpOptionsInput{}
-	describeOutput, err := ctx.EC2.DescribeDhcpOptions(ctx, describeInput)
-	if err != nil {
-		results.Errorf(err, "failed to describe dhcp options")
-		return
-	}
-	for _, d := range describeOutput.DhcpOptions {
-		tags := getTags(d.Tags)
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: v1.AWSEC2DHCPOptions,
-			Tags:         tags,
-			BaseScraper:  config.BaseScraper,
-			Config:       d,
-			Type:         "DHCP",
-			Name:         getName(tags, *d.DhcpOptionsId),
-			Account:      *ctx.Caller.Account,
-			ID:           *d.DhcpOptionsId,
-		})
-	}
-}
-
-func (aws Scraper) s3Buckets(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("S3Bucket") {
-		return
-	}
-	S3 := s3.NewFromConfig(*ctx.Session)
-	buckets, err := S3.ListBuckets(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to list s3 buckets")
-		return
-	}
-	for _, bucket := range buckets.Buckets {
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:       v1.AWSS3Bucket,
-			CreatedAt:          bucket.CreationDate,
-			BaseScraper:        config.BaseScraper,
-			Config:             bucket,
-			Type:               "S3Bucket",
-			Name:               *bucket.Name,
-			Ignore:             []string{"name", "creationDate"},
-			Aliases:            []string{"AmazonS3/" + *bucket.Name},
-			ID:                 *bucket.Name,
-			ParentExternalID:   *ctx.Caller.Account,
-			ParentExternalType: v1.AWSAccount,
-		})
-	}
-}
-
-func (aws Scraper) dnsZones(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("DNSZone") {
-		return
-	}
-	Route53 := route53.NewFromConfig(*ctx.Session)
-	zones, err := Route53.ListHostedZones(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to describe hosted zones")
-		return
-	}
-	for _, zone := range zones.HostedZones {
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType:       "AWS::Route53::HostedZone",
-			BaseScraper:        config.BaseScraper,
-			Config:             zone,
-			Type:               "DNSZone",
-			Name:               *zone.Name,
-			Account:            *ctx.Caller.Account,
-			Aliases:            []string{*zone.Id, *zone.Name, "AmazonRoute53/arn:aws:route53:::hostedzone/" + *zone.Id},
-			ID:                 strings.ReplaceAll(*zone.Id, "/hostedzone/", ""),
-			ParentExternalID:   *ctx.Caller.Account,
-			ParentExternalType: v1.AWSAccount,
-		})
-	}
-}
-
 func (aws Scraper) loadBalancers(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
 	if !config.Includes("LoadBalancer") {
 		return
 	}
-	elb := elasticloadbalancing.NewFromConfig(*ctx.Session)
+	elb := ctx.client("elasticloadbalancing", func() interface{} { return elasticloadbalancing.NewFromConfig(*ctx.Session) }).(*elasticloadbalancing.Client)
 
 	loadbalancers, err := elb.DescribeLoadBalancers(ctx, nil)
 	if err != nil {
@@ -732,7 +439,7 @@ func (aws Scraper) loadBalancers(ctx *AWSContext, config v1.AWS, results *v1.Scr
 		})
 	}
 
-	elbv2 := elasticloadbalancingv2.NewFromConfig(*ctx.Session)
+	elbv2 := ctx.client("elasticloadbalancingv2", func() interface{} { return elasticloadbalancingv2.NewFromConfig(*ctx.Session) }).(*elasticloadbalancingv2.Client)
 	loadbalancersv2, err := elbv2.DescribeLoadBalancers(ctx, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
 	if err != nil {
 		results.Errorf(err, "failed to describe load balancers")
@@ -788,172 +495,230 @@ func (aws Scraper) loadBalancers(ctx *AWSContext, config v1.AWS, results *v1.Scr
 
 }
 
-func (aws Scraper) subnets(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	// we always need to scrape subnets to get the zone for other resources
-	subnets, err := ctx.EC2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{})
-	if err != nil {
-		results.Errorf(err, "failed to get subnets")
-	}
-	for _, subnet := range subnets.Subnets {
+// Scrape ...
+func (aws Scraper) Scrape(ctx *v1.ScrapeContext, config v1.ConfigScraper) v1.ScrapeResults {
+	results := &v1.ScrapeResults{}
 
-		// Subnet tags are of the form [{Key: "<key>", Value:
-		// "<value>"}, ...]
-		tags := make(v1.JSONStringMap)
-		for _, tag := range subnet.Tags {
-			tags[*tag.Key] = *tag.Value
+	for _, awsConfig := range config.AWS {
+		// triggered scrapes and incremental scrapes between full scrapes only scrape the changed services
+		var changed changedServices
+		if len(ctx.Targets) > 0 {
+			changed = targetChanges(ctx.Targets)
+		} else if awsConfig.Incremental != nil {
+			changed = aws.incrementalChanges(ctx, awsConfig, results)
 		}
 
-		az := *subnet.AvailabilityZone
-
-		ctx.Subnets[*subnet.SubnetId] = Zone{Zone: az, Region: az[0 : len(az)-1]}
+		if awsConfig.Organization == nil {
+			aws.scrapeAccount(ctx, awsConfig, "", changed, results)
+			continue
+		}
 
-		if !config.Includes("subnet") {
-			return
+		start := len(*results)
+		var parents map[string]string
+		if changed == nil {
+			parents = aws.organization(ctx, awsConfig, results)
 		}
-		result := v1.ScrapeResult{
-			ExternalType:       "AWS::EC2::Subnet",
-			BaseScraper:        config.BaseScraper,
-			Tags:               tags,
-			Type:               "Subnet",
-			ID:                 *subnet.SubnetId,
-			Subnet:             *subnet.SubnetId,
-			Config:             subnet,
-			Account:            *ctx.Caller.Account,
-			Network:            *subnet.VpcId,
-			Zone:               az,
-			Region:             az[0 : len(az)-1],
-			ParentExternalID:   *subnet.VpcId,
-			ParentExternalType: v1.AWSEC2VPC,
+		accounts, err := aws.organizationAccounts(ctx, awsConfig)
+		if err != nil {
+			results.Errorf(err, "failed to list organization accounts")
+			continue
 		}
-		*results = append(*results, result)
+		for _, accountRole := range accounts {
+			aws.scrapeAccount(ctx, awsConfig, accountRole, changed, results)
+		}
+		setAccountParents(parents, (*results)[start:])
 	}
+
+	return *results
 }
 
-func (aws Scraper) iamRoles(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("Roles") {
+// scrapeAccount scrapes every service of the account, when changed is set only the services
+// of the regions with changes are scraped
+func (aws Scraper) scrapeAccount(ctx *v1.ScrapeContext, awsConfig v1.AWS, accountRole string, changed changedServices, results *v1.ScrapeResults) {
+	start := len(*results)
+	awsCtx, err := aws.getContext(ctx, awsConfig, "us-east-1", accountRole)
+	if err != nil {
+		results.Errorf(err, "failed to create AWS context")
 		return
 	}
-	roles, err := ctx.IAM.ListRoles(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to get roles")
+	account := *awsCtx.Caller.Account
+	if changed != nil && !changed.hasAccount(account) {
 		return
 	}
 
-	for _, role := range roles.Roles {
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: v1.AWSIAMRole,
-			CreatedAt:    role.CreateDate,
-			BaseScraper:  config.BaseScraper,
-			Config:       role,
-			Type:         "Role",
-			Name:         *role.RoleName,
-			Account:      *ctx.Caller.Account,
-			Aliases:      []string{*role.RoleName, *role.Arn},
-			ID:           *role.RoleId,
-		})
+	regions, err := aws.regions(ctx, awsConfig, accountRole)
+	if err != nil {
+		results.Errorf(err, "failed to discover regions")
+		return
 	}
-}
 
-func (aws Scraper) iamProfiles(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("Profiles") {
-		return
+	var tasks []scrapeTask
+	for _, region := range regions {
+		if changed != nil && !changed.hasRegion(account, region) {
+			continue
+		}
+		tasks = append(tasks, aws.regionTasks(ctx, awsConfig, region, accountRole, changed.filter(account, region), results)...)
 	}
-	profiles, err := ctx.IAM.ListInstanceProfiles(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "failed to get profiles")
-		return
+	tasks = append(tasks, awsCtx.tasks(awsConfig, aws.globalScrapers(), changed.filter(account, globalRegion))...)
+
+	complete := runTasks(ctx, awsConfig.MaxConcurrency, tasks, results)
+
+	scraped := make([]v1.ScrapeResult, len(*results)-start)
+	copy(scraped, (*results)[start:])
+	aws.securityAnalysis(awsConfig, scraped, results)
+	// waste and resources without backups can only be found when the whole account was scraped, services that were
+	// skipped on resume or failed would report their volumes, snapshots and backups as missing
+	if changed == nil && complete {
+		aws.wasteAnalysis(ctx, awsConfig, scraped, results)
+		aws.backupAnalysis(awsConfig, scraped, results)
+	} else if changed == nil {
+		logger.Infof("skipping the waste and backup analysis of %s, not every service was scraped", account)
 	}
+}
 
-	for _, profile := range profiles.InstanceProfiles {
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: v1.AWSIAMInstanceProfile,
-			CreatedAt:    profile.CreateDate,
-			BaseScraper:  config.BaseScraper,
-			Config:       profile,
-			Type:         "Profile",
-			Name:         *profile.InstanceProfileName,
-			Account:      *ctx.Caller.Account,
-			Aliases:      []string{*profile.InstanceProfileName, *profile.Arn},
-			ID:           *profile.InstanceProfileId,
-		})
+type scrapeFunc func(*AWSContext, v1.AWS, *v1.ScrapeResults)
+
+// regionScrapers are run in every region
+func (aws Scraper) regionScrapers() map[string]scrapeFunc {
+	return map[string]scrapeFunc{
+		"instances":         aws.instances,
+		"vpcs":              aws.vpcs,
+		"securityGroups":    aws.securityGroups,
+		"routes":            aws.routes,
+		"dhcp":              aws.dhcp,
+		"internetGateways":  aws.internetGateways,
+		"natGateways":       aws.natGateways,
+		"vpcPeering":        aws.vpcPeering,
+		"transitGateways":   aws.transitGateways,
+		"eksClusters":       aws.eksClusters,
+		"autoScalingGroups": aws.autoScalingGroups,
+		"launchTemplates":   aws.launchTemplates,
+		"acmCertificates":   aws.acmCertificates,
+		"kmsKeys":           aws.kmsKeys,
+		"secrets":           aws.secretsManagerSecrets,
+		"ssmParameters":     aws.ssmParameters,
+		"ebs":               aws.ebs,
+		"efs":               aws.efs,
+		"fsx":               aws.fsx,
+		"rds":               aws.rds,
+		"rdsClusters":       aws.rdsClusters,
+		"rdsSnapshots":      aws.rdsSnapshots,
+		"rdsGroups":         aws.rdsGroups,
+		"elastiCache":       aws.elastiCache,
+		"memoryDB":          aws.memoryDB,
+		"openSearchDomains": aws.openSearchDomains,
+		"redshiftClusters":  aws.redshiftClusters,
+		"emrClusters":       aws.emrClusters,
+		"glue":              aws.glue,
+		"kinesisStreams":    aws.kinesisStreams,
+		"firehoses":         aws.firehoses,
+		"mskClusters":       aws.mskClusters,
+		"stepFunctions":     aws.stepFunctions,
+		"eventBridgeRules":  aws.eventBridgeRules,
+		"guardDuty":         aws.guardDutyFindings,
+		"securityHub":       aws.securityHubFindings,
+		"healthEvents":      aws.healthEvents,
+		"sqs":               aws.sqs,
+		"sns":               aws.sns,
+		"lambdaFunctions":   aws.lambdaFunctions,
+		"apiGateways":       aws.apiGateways,
+		"computeOptimizer":  aws.computeOptimizer,
+		"config":            aws.config,
+		"cloudtrail":        aws.cloudtrail,
+		"cloudwatchAlarms":  aws.cloudwatchAlarms,
+		"backups":           aws.backups,
+		"loadBalancers":     aws.loadBalancers,
+		"containerImages":   aws.containerImages,
+		"ebsSnapshots":      aws.ebsSnapshots,
+		"ami":               aws.ami,
 	}
 }
 
-//nolint:all
-func (aws Scraper) ami(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
-	if !config.Includes("Images") {
-		return
+// globalScrapers are run once per account in us-east-1
+func (aws Scraper) globalScrapers() map[string]scrapeFunc {
+	return map[string]scrapeFunc{
+		"account":            aws.account,
+		"iam":                aws.iam,
+		"iamProfiles":        aws.iamProfiles,
+		"dnsZones":           aws.dnsZones,
+		"cloudfront":         aws.cloudfront,
+		"trustedAdvisor":     aws.trustedAdvisor,
+		"s3Buckets":          aws.s3Buckets,
+		"serverCertificates": aws.serverCertificates,
 	}
-	amis, err := ctx.EC2.DescribeImages(ctx, &ec2.DescribeImagesInput{})
+}
+
+// regionTasks returns a task for every service of the region, a region that cannot be
+// reached only records an error and returns no tasks
+func (aws Scraper) regionTasks(ctx *v1.ScrapeContext, awsConfig v1.AWS, region, accountRole string, filter func(string) bool, results *v1.ScrapeResults) []scrapeTask {
+	awsCtx, err := aws.getContext(ctx, awsConfig, region, accountRole)
 	if err != nil {
-		results.Errorf(err, "failed to get amis")
-		return
+		results.Errorf(err, "failed to create AWS context for %s", region)
+		return nil
 	}
+	logger.Infof("Scrapping %s", awsCtx)
+	// subnets are scraped upfront as instances lookup their zone from them
+	aws.subnets(awsCtx, awsConfig, results)
+	return awsCtx.tasks(awsConfig, aws.regionScrapers(), filter)
+}
 
-	for _, image := range amis.Images {
-		createdAt, err := time.Parse(time.RFC3339, *image.CreationDate)
-		if err != nil {
-			createdAt = time.Now()
+// tasks wraps the scrape functions accepted by filter into tasks sorted by name so that results are returned in a stable order
+func (ctx *AWSContext) tasks(awsConfig v1.AWS, fns map[string]scrapeFunc, filter func(string) bool) []scrapeTask {
+	var names []string
+	for name := range fns {
+		if filter(name) {
+			names = append(names, name)
 		}
+	}
+	sort.Strings(names)
 
-		*results = append(*results, v1.ScrapeResult{
-			ExternalType: v1.AWSEC2AMI,
-			CreatedAt:    &createdAt,
-			BaseScraper:  config.BaseScraper,
-			Config:       image,
-			Type:         "Image",
-			Name:         ptr.ToString(image.Name),
-			Account:      *ctx.Caller.Account,
-			ID:           *image.ImageId,
+	var tasks []scrapeTask
+	for _, name := range names {
+		fn := fns[name]
+		tasks = append(tasks, scrapeTask{
+			name: fmt.Sprintf("%s/%s/%s", *ctx.Caller.Account, ctx.Session.Region, name),
+			fn: func(results *v1.ScrapeResults) {
+				fn(ctx, awsConfig, results)
+			},
 		})
 	}
+	return tasks
 }
 
-// Scrape ...
-func (aws Scraper) Scrape(ctx *v1.ScrapeContext, config v1.ConfigScraper) v1.ScrapeResults {
-	results := &v1.ScrapeResults{}
-
-	for _, awsConfig := range config.AWS {
-		for _, region := range awsConfig.Region {
-			awsCtx, err := aws.getContext(ctx, awsConfig, region)
-			if err != nil {
-				return results.Errorf(err, "failed to create AWS context")
-			}
-			logger.Infof("Scrapping %s", awsCtx)
-			aws.subnets(awsCtx, awsConfig, results)
-			aws.instances(awsCtx, awsConfig, results)
-			aws.vpcs(awsCtx, awsConfig, results)
-			aws.securityGroups(awsCtx, awsConfig, results)
-			aws.routes(awsCtx, awsConfig, results)
-			aws.dhcp(awsCtx, awsConfig, results)
-			aws.eksClusters(awsCtx, awsConfig, results)
-			aws.ebs(awsCtx, awsConfig, results)
-			aws.efs(awsCtx, awsConfig, results)
-			aws.rds(awsCtx, awsConfig, results)
-			aws.config(awsCtx, awsConfig, results)
-			aws.cloudtrail(awsCtx, awsConfig, results)
-			aws.loadBalancers(awsCtx, awsConfig, results)
-			aws.containerImages(awsCtx, awsConfig, results)
-			// We are querying half a million amis, need to optimize for this
-			// aws.ami(awsCtx, awsConfig, results)
+// regions returns the configured regions, expanding "all" to the regions enabled in the account
+func (aws Scraper) regions(ctx *v1.ScrapeContext, awsConfig v1.AWS, accountRole string) ([]string, error) {
+	var regions []string
+	all := false
+	for _, region := range awsConfig.Region {
+		if strings.EqualFold(region, "all") {
+			all = true
+		} else {
+			regions = append(regions, region)
 		}
+	}
+	if !all {
+		return regions, nil
+	}
 
-		awsCtx, err := aws.getContext(ctx, awsConfig, "us-east-1")
-		if err != nil {
-			return results.Errorf(err, "failed to create AWS context")
+	awsCtx, err := aws.getContext(ctx, awsConfig, "us-east-1", accountRole)
+	if err != nil {
+		return nil, err
+	}
+	// without AllRegions only regions that are enabled (opted-in or not requiring opt-in) are returned
+	output, err := ec2.NewFromConfig(*awsCtx.Session).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, region := range regions {
+		seen[region] = true
+	}
+	for _, region := range output.Regions {
+		if !seen[*region.RegionName] {
+			regions = append(regions, *region.RegionName)
 		}
-
-		aws.account(awsCtx, awsConfig, results)
-		aws.users(awsCtx, awsConfig, results)
-		aws.iamRoles(awsCtx, awsConfig, results)
-		aws.iamProfiles(awsCtx, awsConfig, results)
-		aws.dnsZones(awsCtx, awsConfig, results)
-
-		aws.trustedAdvisor(awsCtx, awsConfig, results)
-		aws.s3Buckets(awsCtx, awsConfig, results)
 	}
-
-	return *results
+	return regions, nil
 }
 
 func getExternalTypeById(id string) string {
@@ -3,10 +3,12 @@ package aws
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/secrets"
 	"github.com/flanksource/kommons"
 	"github.com/henvic/httpretty"
 
@@ -17,20 +19,52 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
+const defaultMaxRetries = 10
+
 func isEmpty(val kommons.EnvVar) bool {
 	return val.Value == "" && val.ValueFrom == nil
 }
 
-// NewSession ...
+// NewSession returns a copy of the session of the connection in the region, sessions are cached so that
+// their credentials are only resolved and refreshed once across scrapes
 func NewSession(ctx *v1.ScrapeContext, conn v1.AWSConnection, region string) (*aws.Config, error) {
-	cfg, err := loadConfig(ctx, conn, region)
+	key := fmt.Sprintf("aws/session/%s/%s", connectionKey(ctx, conn), region)
+	cached, err := ctx.Connections.Get(key, func() (interface{}, error) {
+		cfg, err := loadConfig(ctx, conn, region)
+		if err != nil {
+			return nil, err
+		}
+		if conn.AssumeRole != "" {
+			assumeRole(cfg, conn.AssumeRole, conn.ExternalID, conn.SessionName)
+		}
+		return cfg, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if conn.AssumeRole != "" {
-		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), conn.AssumeRole))
-	}
-	return cfg, nil
+	cfg := cached.(*aws.Config).Copy()
+	return &cfg, nil
+}
+
+// connectionKey identifies a connection in the connection cache, regions are part of the cache keys instead.
+// The same secret references resolve to other credentials in another namespace or tenant
+func connectionKey(ctx *v1.ScrapeContext, conn v1.AWSConnection) string {
+	conn.Region = nil
+	data, _ := json.Marshal(conn)
+	return fmt.Sprintf("%s/%s/%s", ctx.Tenant, ctx.GetNamespace(), data)
+}
+
+// assumeRole replaces the credentials of cfg with those of the role, assumed using the current credentials
+func assumeRole(cfg *aws.Config, roleArn, externalID, sessionName string) {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), roleArn, func(o *stscreds.AssumeRoleOptions) {
+		if externalID != "" {
+			o.ExternalID = &externalID
+		}
+		if sessionName != "" {
+			o.RoleSessionName = sessionName
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
 }
 
 // EndpointResolver ...
@@ -67,9 +101,16 @@ func loadConfig(ctx *v1.ScrapeContext, conn v1.AWSConnection, region string) (*a
 		tr = httplogger.RoundTripper(tr)
 	}
 
+	maxRetries := conn.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
 	options := []func(*config.LoadOptions) error{
 		config.WithRegion(region),
 		config.WithHTTPClient(&http.Client{Transport: tr}),
+		config.WithRetryMode(aws.RetryModeAdaptive),
+		config.WithRetryMaxAttempts(maxRetries),
 	}
 
 	if conn.Endpoint != "" {
@@ -93,11 +134,11 @@ func getAccessAndSecretKey(ctx *v1.ScrapeContext, conn v1.AWSConnection) (string
 	if isEmpty(conn.AccessKey) {
 		return "", "", nil
 	}
-	_, accessKey, err := ctx.Kommons.GetEnvValue(conn.AccessKey, namespace)
+	accessKey, err := secrets.GetEnvValue(ctx, conn.AccessKey, namespace)
 	if err != nil {
 		return "", "", fmt.Errorf("could not parse EC2 access key: %v", err)
 	}
-	_, secretKey, err := ctx.Kommons.GetEnvValue(conn.SecretKey, namespace)
+	secretKey, err := secrets.GetEnvValue(ctx, conn.SecretKey, namespace)
 	if err != nil {
 		return "", "", fmt.Errorf(fmt.Sprintf("could not parse EC2 secret key: %v", err))
 	}
@@ -0,0 +1,290 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	backupTypes "github.com/aws/aws-sdk-go-v2/service/backup/types"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// backupNotCovered reports databases, volumes and file systems that no backup plan selects, category and severity
+// are defined in scrapers/analysis/rules.yaml
+const backupNotCovered = "backup-not-covered"
+
+// BackupPlan is a plan with its rules and the selections of resources it backs up
+type BackupPlan struct {
+	BackupPlanId      string                        `json:"BackupPlanId"`
+	BackupPlanArn     string                        `json:"BackupPlanArn"`
+	BackupPlanName    string                        `json:"BackupPlanName"`
+	VersionId         string                        `json:"VersionId,omitempty"`
+	CreationDate      *time.Time                    `json:"CreationDate,omitempty"`
+	LastExecutionDate *time.Time                    `json:"LastExecutionDate,omitempty"`
+	Rules             []backupTypes.BackupRule      `json:"Rules,omitempty"`
+	Selections        []backupTypes.BackupSelection `json:"Selections,omitempty"`
+}
+
+func (aws Scraper) backups(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Backup") {
+		return
+	}
+	Backup := ctx.client("backup", func() interface{} { return backup.NewFromConfig(*ctx.Session) }).(*backup.Client)
+	aws.backupPlans(ctx, config, Backup, results)
+	aws.backupVaults(ctx, config, Backup, results)
+}
+
+func (aws Scraper) backupPlans(ctx *AWSContext, config v1.AWS, client *backup.Client, results *v1.ScrapeResults) {
+	paginator := backup.NewListBackupPlansPaginator(client, &backup.ListBackupPlansInput{})
+	for paginator.HasMorePages() {
+		plans, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list backup plans")
+			return
+		}
+		for _, member := range plans.BackupPlansList {
+			plan := BackupPlan{
+				BackupPlanId:      *member.BackupPlanId,
+				BackupPlanArn:     *member.BackupPlanArn,
+				BackupPlanName:    deref(member.BackupPlanName),
+				VersionId:         deref(member.VersionId),
+				CreationDate:      member.CreationDate,
+				LastExecutionDate: member.LastExecutionDate,
+			}
+
+			if output, err := client.GetBackupPlan(ctx, &backup.GetBackupPlanInput{BackupPlanId: member.BackupPlanId}); err != nil {
+				results.Errorf(err, "failed to get backup plan %s", plan.BackupPlanName)
+			} else if output.BackupPlan != nil {
+				plan.Rules = output.BackupPlan.Rules
+			}
+
+			selections := backup.NewListBackupSelectionsPaginator(client, &backup.ListBackupSelectionsInput{BackupPlanId: member.BackupPlanId})
+			for selections.HasMorePages() {
+				page, err := selections.NextPage(ctx)
+				if err != nil {
+					results.Errorf(err, "failed to list selections of backup plan %s", plan.BackupPlanName)
+					break
+				}
+				for _, selection := range page.BackupSelectionsList {
+					output, err := client.GetBackupSelection(ctx, &backup.GetBackupSelectionInput{BackupPlanId: member.BackupPlanId, SelectionId: selection.SelectionId})
+					if err != nil {
+						results.Errorf(err, "failed to get backup selection %s", deref(selection.SelectionName))
+						continue
+					}
+					plan.Selections = append(plan.Selections, *output.BackupSelection)
+				}
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSBackupPlan,
+				CreatedAt:          plan.CreationDate,
+				BaseScraper:        config.BaseScraper,
+				Config:             plan,
+				Type:               "BackupPlan",
+				Name:               plan.BackupPlanName,
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{plan.BackupPlanId},
+				ID:                 plan.BackupPlanArn,
+				Ignore:             []string{"CreationDate", "LastExecutionDate"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+}
+
+func (aws Scraper) backupVaults(ctx *AWSContext, config v1.AWS, client *backup.Client, results *v1.ScrapeResults) {
+	paginator := backup.NewListBackupVaultsPaginator(client, &backup.ListBackupVaultsInput{})
+	for paginator.HasMorePages() {
+		vaults, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list backup vaults")
+			return
+		}
+		for _, vault := range vaults.BackupVaultList {
+			self := v1.ExternalID{ExternalID: []string{*vault.BackupVaultArn}, ExternalType: v1.AWSBackupVault}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSBackupVault,
+				CreatedAt:           vault.CreationDate,
+				BaseScraper:         config.BaseScraper,
+				Config:              vault,
+				Type:                "BackupVault",
+				Name:                *vault.BackupVaultName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *vault.BackupVaultArn,
+				Ignore:              []string{"CreationDate", "NumberOfRecoveryPoints"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: encryptedBy(self, deref(vault.EncryptionKeyArn), ctx.Session.Region, *ctx.Caller.Account),
+			})
+
+			points := backup.NewListRecoveryPointsByBackupVaultPaginator(client, &backup.ListRecoveryPointsByBackupVaultInput{BackupVaultName: vault.BackupVaultName})
+			for points.HasMorePages() {
+				page, err := points.NextPage(ctx)
+				if err != nil {
+					results.Errorf(err, "failed to list recovery points of backup vault %s", *vault.BackupVaultName)
+					break
+				}
+				for _, point := range page.RecoveryPoints {
+					var relationships v1.RelationshipResults
+					if resource := arnExternalID(deref(point.ResourceArn)); resource.ExternalType != "" {
+						relationships = append(relationships, v1.RelationshipResult{
+							ConfigExternalID:  resource,
+							RelatedExternalID: v1.ExternalID{ExternalID: []string{*point.RecoveryPointArn}, ExternalType: v1.AWSBackupRecoveryPoint},
+							Relationship:      "BackupRecoveryPoint",
+						})
+					}
+
+					*results = append(*results, v1.ScrapeResult{
+						ExternalType:        v1.AWSBackupRecoveryPoint,
+						CreatedAt:           point.CreationDate,
+						BaseScraper:         config.BaseScraper,
+						Config:              point,
+						Type:                "BackupRecoveryPoint",
+						Name:                fmt.Sprintf("%s/%s", deref(point.ResourceType), point.CreationDate.Format(time.RFC3339)),
+						Account:             *ctx.Caller.Account,
+						Region:              ctx.Session.Region,
+						ID:                  *point.RecoveryPointArn,
+						Ignore:              []string{"CreationDate", "LastRestoreTime"},
+						ParentExternalID:    *vault.BackupVaultArn,
+						ParentExternalType:  v1.AWSBackupVault,
+						RelationshipResults: relationships,
+					})
+				}
+			}
+		}
+	}
+}
+
+// backupAnalysis reports the databases, volumes and file systems that are not selected by any backup plan of their
+// region, volumes of instances that are selected are covered by the backup of the instance
+func (aws Scraper) backupAnalysis(config v1.AWS, scraped []v1.ScrapeResult, results *v1.ScrapeResults) {
+	if !config.Includes("Backup") || config.Excludes(backupNotCovered) {
+		return
+	}
+
+	selections := make(map[string][]backupTypes.BackupSelection)
+	for _, item := range scraped {
+		if plan, ok := item.Config.(BackupPlan); ok {
+			selections[item.Region] = append(selections[item.Region], plan.Selections...)
+		}
+	}
+	covered := func(item v1.ScrapeResult, arn string) bool {
+		for _, selection := range selections[item.Region] {
+			if backupSelects(selection, arn, item.Tags) {
+				return true
+			}
+		}
+		return false
+	}
+
+	coveredVolumes := make(map[string]bool)
+	for _, item := range scraped {
+		if instance, ok := item.Config.(*Instance); ok && covered(item, fmt.Sprintf("arn:aws:ec2:%s:%s:instance/%s", item.Region, item.Account, item.ID)) {
+			for _, volume := range instance.BlockDeviceMappings {
+				if volume.Ebs != nil && volume.Ebs.VolumeId != nil {
+					coveredVolumes[*volume.Ebs.VolumeId] = true
+				}
+			}
+		}
+	}
+
+	for _, item := range scraped {
+		var arn, kind string
+		switch c := item.Config.(type) {
+		case rdsTypes.DBInstance:
+			// the instances of a cluster are backed up with the cluster
+			if c.DBClusterIdentifier != nil {
+				continue
+			}
+			arn, kind = deref(c.DBInstanceArn), "database"
+		case rdsTypes.DBCluster:
+			arn, kind = deref(c.DBClusterArn), "database cluster"
+		case ec2Types.Volume:
+			if coveredVolumes[item.ID] {
+				continue
+			}
+			arn, kind = fmt.Sprintf("arn:aws:ec2:%s:%s:volume/%s", item.Region, item.Account, item.ID), "volume"
+		case EFSFileSystem:
+			arn, kind = deref(c.FileSystemArn), "file system"
+		default:
+			continue
+		}
+		if covered(item, arn) {
+			continue
+		}
+		analysis := results.Analysis(backupNotCovered, item.ExternalType, item.ID)
+		analysis.Summary = fmt.Sprintf("%s %s is not covered by any backup plan", kind, item.Name)
+		analysis.Message(analysis.Summary)
+	}
+}
+
+// backupSelects returns true if the selection assigns the resource to its plan, either by ARN or by tag
+func backupSelects(selection backupTypes.BackupSelection, arn string, tags v1.JSONStringMap) bool {
+	for _, pattern := range selection.NotResources {
+		if arnMatches(pattern, arn) {
+			return false
+		}
+	}
+	if !backupConditionsMatch(selection.Conditions, tags) {
+		return false
+	}
+	for _, pattern := range selection.Resources {
+		if arnMatches(pattern, arn) {
+			return true
+		}
+	}
+	for _, condition := range selection.ListOfTags {
+		if tags[deref(condition.ConditionKey)] == deref(condition.ConditionValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// backupConditionsMatch returns true if the tags match every condition of the selection
+func backupConditionsMatch(conditions *backupTypes.Conditions, tags v1.JSONStringMap) bool {
+	if conditions == nil {
+		return true
+	}
+	value := func(c backupTypes.ConditionParameter) (string, string) {
+		return tags[strings.TrimPrefix(deref(c.ConditionKey), "aws:ResourceTag/")], deref(c.ConditionValue)
+	}
+	for _, c := range conditions.StringEquals {
+		if tag, expected := value(c); tag != expected {
+			return false
+		}
+	}
+	for _, c := range conditions.StringNotEquals {
+		if tag, expected := value(c); tag == expected {
+			return false
+		}
+	}
+	for _, c := range conditions.StringLike {
+		if tag, expected := value(c); !arnMatches(expected, tag) {
+			return false
+		}
+	}
+	for _, c := range conditions.StringNotLike {
+		if tag, expected := value(c); arnMatches(expected, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// arnMatches matches an ARN against a pattern where * matches any characters
+func arnMatches(pattern, arn string) bool {
+	if pattern == "*" {
+		return true
+	}
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, arn)
+	return matched
+}
@@ -0,0 +1,169 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	acmTypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// certificateExpiring reports certificates that expire within the certificate expiry window, on the certificates and
+// on the load balancers and distributions that serve them
+const certificateExpiring = "certificate-expiring"
+
+func (aws Scraper) acmCertificates(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Certificate") {
+		return
+	}
+	ACM := ctx.client("acm", func() interface{} { return acm.NewFromConfig(*ctx.Session) }).(*acm.Client)
+	// only RSA 2048 certificates are listed unless the key types are set
+	paginator := acm.NewListCertificatesPaginator(ACM, &acm.ListCertificatesInput{
+		Includes: &acmTypes.Filters{KeyTypes: acmTypes.KeyAlgorithm("").Values()},
+	})
+	for paginator.HasMorePages() {
+		certificates, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list certificates")
+			return
+		}
+		for _, summary := range certificates.CertificateSummaryList {
+			certificate, err := ACM.DescribeCertificate(ctx, &acm.DescribeCertificateInput{CertificateArn: summary.CertificateArn})
+			if err != nil {
+				results.Errorf(err, "failed to describe certificate %s", *summary.CertificateArn)
+				continue
+			}
+			detail := certificate.Certificate
+
+			tags := make(v1.JSONStringMap)
+			if output, err := ACM.ListTagsForCertificate(ctx, &acm.ListTagsForCertificateInput{CertificateArn: detail.CertificateArn}); err != nil {
+				results.Errorf(err, "failed to list tags of certificate %s", *detail.CertificateArn)
+			} else {
+				for _, tag := range output.Tags {
+					tags[*tag.Key] = deref(tag.Value)
+				}
+			}
+
+			id := v1.ExternalID{ExternalID: []string{*detail.CertificateArn}, ExternalType: v1.AWSACMCertificate}
+			var relationships v1.RelationshipResults
+			var users []v1.ExternalID
+			for _, arn := range detail.InUseBy {
+				user, relationship := certificateUser(arn)
+				if user.ExternalType == "" {
+					continue
+				}
+				users = append(users, user)
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  id,
+					RelatedExternalID: user,
+					Relationship:      relationship,
+				})
+			}
+
+			name := deref(detail.DomainName)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSACMCertificate,
+				CreatedAt:           detail.CreatedAt,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              detail,
+				Type:                "Certificate",
+				Name:                getName(tags, name),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *detail.CertificateArn,
+				Ignore:              []string{"CreatedAt"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+
+			certificateExpiry(config, name, detail.NotAfter, append([]v1.ExternalID{id}, users...), results)
+		}
+	}
+}
+
+// certificateUser returns the load balancer or distribution of the arn and the relationship of a certificate to it
+func certificateUser(arn string) (v1.ExternalID, string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return v1.ExternalID{}, ""
+	}
+	switch resource := parts[5]; {
+	case parts[2] == "cloudfront":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSCloudFrontDistribution}, "CertificateCloudFront"
+	case parts[2] == "elasticloadbalancing" && (strings.HasPrefix(resource, "loadbalancer/app/") || strings.HasPrefix(resource, "loadbalancer/net/")):
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSLoadBalancerV2}, "CertificateLoadBalancer"
+	case parts[2] == "elasticloadbalancing" && strings.HasPrefix(resource, "loadbalancer/"):
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSLoadBalancer}, "CertificateLoadBalancer"
+	}
+	return v1.ExternalID{}, ""
+}
+
+// serverCertificates scrapes the certificates uploaded to IAM, which are used by load balancers in regions without ACM
+func (aws Scraper) serverCertificates(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Certificate") {
+		return
+	}
+	paginator := iam.NewListServerCertificatesPaginator(ctx.IAM, &iam.ListServerCertificatesInput{})
+	for paginator.HasMorePages() {
+		certificates, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list server certificates")
+			return
+		}
+		for _, certificate := range certificates.ServerCertificateMetadataList {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSIAMServerCertificate,
+				CreatedAt:          certificate.UploadDate,
+				BaseScraper:        config.BaseScraper,
+				Config:             certificate,
+				Type:               "ServerCertificate",
+				Name:               *certificate.ServerCertificateName,
+				Account:            *ctx.Caller.Account,
+				Aliases:            []string{*certificate.Arn},
+				ID:                 *certificate.ServerCertificateId,
+				Ignore:             []string{"UploadDate"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+
+			certificateExpiry(config, *certificate.ServerCertificateName, certificate.Expiration, []v1.ExternalID{{
+				ExternalID:   []string{*certificate.ServerCertificateId},
+				ExternalType: v1.AWSIAMServerCertificate,
+			}}, results)
+		}
+	}
+}
+
+// certificateExpiry reports the certificate on the items when it expires within the critical window as critical, or
+// within the warning window as a warning
+func certificateExpiry(config v1.AWS, name string, notAfter *time.Time, items []v1.ExternalID, results *v1.ScrapeResults) {
+	if notAfter == nil || config.Excludes(certificateExpiring) {
+		return
+	}
+	remaining := time.Until(*notAfter)
+	if remaining > config.CertificateExpiry.GetWarning() {
+		return
+	}
+
+	severity := "warning"
+	if remaining <= config.CertificateExpiry.GetCritical() {
+		severity = "critical"
+	}
+	message := fmt.Sprintf("certificate %s expires in %d days", name, int(remaining.Hours()/24))
+	if remaining <= 0 {
+		message = fmt.Sprintf("certificate %s expired on %s", name, notAfter.Format("2006-01-02"))
+	}
+	for _, item := range items {
+		analysis := results.Analysis(certificateExpiring, item.ExternalType, item.ExternalID[0])
+		analysis.AnalysisType = "reliability"
+		analysis.Severity = severity
+		analysis.Summary = message
+		analysis.Message(message)
+		analysis.Analysis = map[string]string{"certificate": name, "expiry": notAfter.Format(time.RFC3339)}
+	}
+}
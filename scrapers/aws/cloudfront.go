@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) cloudfront(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("CloudFront") {
+		return
+	}
+
+	CloudFront := ctx.client("cloudfront", func() interface{} { return cloudfront.NewFromConfig(*ctx.Session) }).(*cloudfront.Client)
+	paginator := cloudfront.NewListDistributionsPaginator(CloudFront, &cloudfront.ListDistributionsInput{})
+	for paginator.HasMorePages() {
+		distributions, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list cloudfront distributions")
+			return
+		}
+		if distributions.DistributionList == nil {
+			return
+		}
+
+		for _, distribution := range distributions.DistributionList.Items {
+			tags := make(v1.JSONStringMap)
+			tagsOutput, err := CloudFront.ListTagsForResource(ctx, &cloudfront.ListTagsForResourceInput{Resource: distribution.ARN})
+			if err != nil {
+				logger.Errorf("error while fetching cloudfront tags: %v", err)
+			} else if tagsOutput.Tags != nil {
+				for _, tag := range tagsOutput.Tags.Items {
+					tags[*tag.Key] = deref(tag.Value)
+				}
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSCloudFrontDistribution,
+				BaseScraper:         config.BaseScraper,
+				Config:              distribution,
+				Tags:                tags,
+				Type:                "CloudFront",
+				Name:                getName(tags, *distribution.DomainName),
+				Account:             *ctx.Caller.Account,
+				Aliases:             []string{*distribution.ARN, *distribution.DomainName, "AmazonCloudFront/" + *distribution.ARN},
+				ID:                  *distribution.Id,
+				Ignore:              []string{"lastModifiedTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: getCloudFrontOriginRelationships(distribution),
+			})
+		}
+	}
+}
+
+// getCloudFrontOriginRelationships links a distribution to the S3 buckets it serves content from
+func getCloudFrontOriginRelationships(distribution types.DistributionSummary) v1.RelationshipResults {
+	var relationships v1.RelationshipResults
+	if distribution.Origins == nil {
+		return relationships
+	}
+
+	for _, origin := range distribution.Origins.Items {
+		if origin.DomainName == nil {
+			continue
+		}
+		bucket := getS3BucketFromDomain(*origin.DomainName)
+		if bucket == "" {
+			continue
+		}
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID: v1.ExternalID{
+				ExternalID:   []string{*distribution.Id},
+				ExternalType: v1.AWSCloudFrontDistribution,
+			},
+			RelatedExternalID: v1.ExternalID{
+				ExternalID:   []string{bucket},
+				ExternalType: v1.AWSS3Bucket,
+			},
+			Relationship: "CloudFrontOrigin",
+		})
+	}
+	return relationships
+}
+
+// getS3BucketFromDomain returns the bucket name for S3 origin domains of the form
+// <bucket>.s3.amazonaws.com or <bucket>.s3.<region>.amazonaws.com
+func getS3BucketFromDomain(domain string) string {
+	if !strings.HasSuffix(domain, ".amazonaws.com") {
+		return ""
+	}
+	if i := strings.Index(domain, ".s3."); i > 0 {
+		return domain[:i]
+	}
+	if i := strings.Index(domain, ".s3-"); i > 0 {
+		return domain[:i]
+	}
+	return ""
+}
@@ -15,7 +15,7 @@ import (
 
 func lookupEvents(ctx *AWSContext, input *cloudtrail.LookupEventsInput, c chan types.Event) error {
 	logger.Debugf("Looking up events from %s", input.StartTime)
-	CloudTrail := cloudtrail.NewFromConfig(*ctx.Session)
+	CloudTrail := ctx.client("cloudtrail", func() interface{} { return cloudtrail.NewFromConfig(*ctx.Session) }).(*cloudtrail.Client)
 	defer func() {
 		close(c)
 	}()
@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// cloudwatchAlarm prefixes the analyzer of every alarm, so that each alarm on a resource has its own analysis
+const cloudwatchAlarm = "cloudwatch-alarm"
+
+// alarmDimensions maps the dimensions of metrics to the resources they identify
+var alarmDimensions = map[string]func(ctx *AWSContext, value string) v1.ExternalID{
+	"InstanceId": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSEC2Instance}
+	},
+	"VolumeId": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSEBSVolume}
+	},
+	"AutoScalingGroupName": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSAutoScalingGroup}
+	},
+	"DBInstanceIdentifier": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSRDSInstance}
+	},
+	"DBClusterIdentifier": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSRDSCluster}
+	},
+	"CacheClusterId": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSElastiCacheCluster}
+	},
+	"LoadBalancerName": func(ctx *AWSContext, value string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: v1.AWSLoadBalancer}
+	},
+	// application and network load balancers are identified by the suffix of their ARN, e.g. app/web/50dc6c495c0c9188
+	"LoadBalancer": func(ctx *AWSContext, value string) v1.ExternalID {
+		arn := fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/%s", ctx.Session.Region, *ctx.Caller.Account, value)
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSLoadBalancerV2}
+	},
+	"QueueName": func(ctx *AWSContext, value string) v1.ExternalID {
+		arn := fmt.Sprintf("arn:aws:sqs:%s:%s:%s", ctx.Session.Region, *ctx.Caller.Account, value)
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSSQSQueue}
+	},
+	"TopicName": func(ctx *AWSContext, value string) v1.ExternalID {
+		arn := fmt.Sprintf("arn:aws:sns:%s:%s:%s", ctx.Session.Region, *ctx.Caller.Account, value)
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSSNSTopic}
+	},
+	"FunctionName": func(ctx *AWSContext, value string) v1.ExternalID {
+		arn := fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", ctx.Session.Region, *ctx.Caller.Account, value)
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSLambdaFunction}
+	},
+}
+
+// alarmResources returns the resources monitored by the metrics of the alarm, including the metrics of math expressions
+func alarmResources(ctx *AWSContext, alarm cloudwatchTypes.MetricAlarm) []v1.ExternalID {
+	dimensions := alarm.Dimensions
+	for _, query := range alarm.Metrics {
+		if query.MetricStat != nil && query.MetricStat.Metric != nil {
+			dimensions = append(dimensions, query.MetricStat.Metric.Dimensions...)
+		}
+	}
+
+	var resources []v1.ExternalID
+	seen := make(map[string]bool)
+	for _, dimension := range dimensions {
+		resource, ok := alarmDimensions[deref(dimension.Name)]
+		if !ok || dimension.Value == nil {
+			continue
+		}
+		id := resource(ctx, *dimension.Value)
+		if key := id.ExternalType + "/" + id.ExternalID[0]; !seen[key] {
+			seen[key] = true
+			resources = append(resources, id)
+		}
+	}
+	return resources
+}
+
+// cloudwatchAlarms scrapes metric alarms and reports their state on the resources they monitor, an alarm in the
+// ALARM state opens an analysis that is resolved once the alarm returns to OK
+func (aws Scraper) cloudwatchAlarms(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("CloudWatchAlarm") {
+		return
+	}
+	CloudWatch := ctx.client("cloudwatch", func() interface{} { return cloudwatch.NewFromConfig(*ctx.Session) }).(*cloudwatch.Client)
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(CloudWatch, &cloudwatch.DescribeAlarmsInput{})
+	for paginator.HasMorePages() {
+		alarms, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe cloudwatch alarms")
+			return
+		}
+		for _, alarm := range alarms.MetricAlarms {
+			self := v1.ExternalID{ExternalID: []string{*alarm.AlarmArn}, ExternalType: v1.AWSCloudWatchAlarm}
+			resources := alarmResources(ctx, alarm)
+			var relationships v1.RelationshipResults
+			for _, resource := range resources {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  self,
+					RelatedExternalID: resource,
+					Relationship:      "AlarmResource",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSCloudWatchAlarm,
+				BaseScraper:         config.BaseScraper,
+				Config:              alarm,
+				Type:                "Alarm",
+				Name:                *alarm.AlarmName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*alarm.AlarmName},
+				ID:                  *alarm.AlarmArn,
+				Ignore:              []string{"StateReason", "StateReasonData", "StateUpdatedTimestamp"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+
+			if config.Excludes(cloudwatchAlarm) {
+				continue
+			}
+			var status, severity string
+			switch alarm.StateValue {
+			case cloudwatchTypes.StateValueAlarm:
+				status, severity = "open", "critical"
+			case cloudwatchTypes.StateValueOk:
+				status, severity = "resolved", "info"
+			default:
+				// alarms without enough data keep the state they were last reported with
+				continue
+			}
+			message := fmt.Sprintf("alarm %s is %s: %s", *alarm.AlarmName, alarm.StateValue, deref(alarm.StateReason))
+			for _, resource := range append([]v1.ExternalID{self}, resources...) {
+				analysis := results.Analysis(cloudwatchAlarm+":"+*alarm.AlarmName, resource.ExternalType, resource.ExternalID[0])
+				analysis.AnalysisType = "availability"
+				analysis.Severity = severity
+				analysis.Status = status
+				analysis.Summary = message
+				analysis.Message(message)
+				analysis.Analysis = map[string]string{
+					"alarm":  *alarm.AlarmArn,
+					"metric": deref(alarm.Namespace) + "/" + deref(alarm.MetricName),
+					"state":  string(alarm.StateValue),
+				}
+			}
+		}
+	}
+}
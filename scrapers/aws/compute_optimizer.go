@@ -0,0 +1,221 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// Compute Optimizer findings, the findings of lambda functions and ebs volumes are either Optimized or NotOptimized
+const (
+	findingOptimized        = "Optimized"
+	findingNotOptimized     = "NotOptimized"
+	findingUnderProvisioned = "Underprovisioned"
+)
+
+type savingsOpportunity struct {
+	SavingsOpportunityPercentage float64 `json:"savingsOpportunityPercentage"`
+	EstimatedMonthlySavings      *struct {
+		Currency string  `json:"currency"`
+		Value    float64 `json:"value"`
+	} `json:"estimatedMonthlySavings"`
+}
+
+type ec2InstanceRecommendations struct {
+	NextToken               *string `json:"nextToken"`
+	InstanceRecommendations []struct {
+		InstanceArn           string   `json:"instanceArn"`
+		CurrentInstanceType   string   `json:"currentInstanceType"`
+		Finding               string   `json:"finding"`
+		FindingReasonCodes    []string `json:"findingReasonCodes"`
+		RecommendationOptions []struct {
+			InstanceType       string              `json:"instanceType"`
+			Rank               int                 `json:"rank"`
+			SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+		} `json:"recommendationOptions"`
+	} `json:"instanceRecommendations"`
+}
+
+type ebsVolumeRecommendations struct {
+	NextToken             *string `json:"nextToken"`
+	VolumeRecommendations []struct {
+		VolumeArn            string `json:"volumeArn"`
+		Finding              string `json:"finding"`
+		CurrentConfiguration *struct {
+			VolumeType string `json:"volumeType"`
+			VolumeSize int    `json:"volumeSize"`
+		} `json:"currentConfiguration"`
+		VolumeRecommendationOptions []struct {
+			Configuration *struct {
+				VolumeType string `json:"volumeType"`
+			} `json:"configuration"`
+			Rank               int                 `json:"rank"`
+			SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+		} `json:"volumeRecommendationOptions"`
+	} `json:"volumeRecommendations"`
+}
+
+type lambdaFunctionRecommendations struct {
+	NextToken                     *string `json:"nextToken"`
+	LambdaFunctionRecommendations []struct {
+		FunctionArn                     string   `json:"functionArn"`
+		CurrentMemorySize               int      `json:"currentMemorySize"`
+		Finding                         string   `json:"finding"`
+		FindingReasonCodes              []string `json:"findingReasonCodes"`
+		MemorySizeRecommendationOptions []struct {
+			Rank               int                 `json:"rank"`
+			MemorySize         int                 `json:"memorySize"`
+			SavingsOpportunity *savingsOpportunity `json:"savingsOpportunity"`
+		} `json:"memorySizeRecommendationOptions"`
+	} `json:"lambdaFunctionRecommendations"`
+}
+
+// recommendationsInput is the input of the Get*Recommendations operations
+type recommendationsInput struct {
+	NextToken *string `json:"nextToken,omitempty"`
+}
+
+func (aws Scraper) computeOptimizer(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.ComputeOptimizer {
+		return
+	}
+
+	client := ctx.client("computeoptimizer", func() interface{} {
+		return &jsonClient{
+			cfg:            *ctx.Session,
+			endpointPrefix: "compute-optimizer",
+			signingName:    "compute-optimizer",
+			targetPrefix:   "ComputeOptimizerService",
+			contentType:    "application/x-amz-json-1.0",
+		}
+	}).(*jsonClient)
+
+	ec2Input := recommendationsInput{}
+	for {
+		var output ec2InstanceRecommendations
+		if err := client.call(ctx, "GetEC2InstanceRecommendations", ec2Input, &output); err != nil {
+			results.Errorf(err, "failed to get compute optimizer ec2 recommendations")
+			break
+		}
+		for _, r := range output.InstanceRecommendations {
+			finding := r.Finding
+			if finding == findingOptimized {
+				continue
+			}
+			analysis := results.Analysis("compute-optimizer-ec2", v1.AWSEC2Instance, arnResourceID(r.InstanceArn))
+			analysis.AnalysisType = computeOptimizerCategory(r.Finding)
+			analysis.Severity = "warning"
+			analysis.Summary = fmt.Sprintf("%s is %s", r.CurrentInstanceType, strings.ToLower(finding))
+			analysis.Analysis = map[string]string{
+				"Finding":               finding,
+				"Current Instance Type": r.CurrentInstanceType,
+			}
+			for _, option := range r.RecommendationOptions {
+				if option.Rank == 1 {
+					analysis.Analysis["Recommended Instance Type"] = option.InstanceType
+					addSavings(analysis, option.SavingsOpportunity)
+				}
+			}
+			for _, reason := range r.FindingReasonCodes {
+				analysis.Message(reason)
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		ec2Input.NextToken = output.NextToken
+	}
+
+	ebsInput := recommendationsInput{}
+	for {
+		var output ebsVolumeRecommendations
+		if err := client.call(ctx, "GetEBSVolumeRecommendations", ebsInput, &output); err != nil {
+			results.Errorf(err, "failed to get compute optimizer ebs recommendations")
+			break
+		}
+		for _, r := range output.VolumeRecommendations {
+			finding := r.Finding
+			if finding == findingOptimized {
+				continue
+			}
+			analysis := results.Analysis("compute-optimizer-ebs", v1.AWSEBSVolume, arnResourceID(r.VolumeArn))
+			analysis.AnalysisType = "cost"
+			analysis.Severity = "warning"
+			analysis.Analysis = map[string]string{"Finding": finding}
+			if current := r.CurrentConfiguration; current != nil {
+				analysis.Summary = fmt.Sprintf("%s volume of %dGiB is not optimized", current.VolumeType, current.VolumeSize)
+				analysis.Analysis["Current Volume Type"] = current.VolumeType
+			}
+			for _, option := range r.VolumeRecommendationOptions {
+				if option.Rank == 1 {
+					if option.Configuration != nil {
+						analysis.Analysis["Recommended Volume Type"] = option.Configuration.VolumeType
+					}
+					addSavings(analysis, option.SavingsOpportunity)
+				}
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		ebsInput.NextToken = output.NextToken
+	}
+
+	lambdaInput := recommendationsInput{}
+	for {
+		var output lambdaFunctionRecommendations
+		if err := client.call(ctx, "GetLambdaFunctionRecommendations", lambdaInput, &output); err != nil {
+			results.Errorf(err, "failed to get compute optimizer lambda recommendations")
+			break
+		}
+		for _, r := range output.LambdaFunctionRecommendations {
+			finding := r.Finding
+			if finding != findingNotOptimized {
+				continue
+			}
+			analysis := results.Analysis("compute-optimizer-lambda", v1.AWSLambdaFunction, getLambdaFunctionArn(r.FunctionArn))
+			analysis.AnalysisType = "cost"
+			analysis.Severity = "warning"
+			analysis.Summary = fmt.Sprintf("%dMB memory is not optimized", r.CurrentMemorySize)
+			analysis.Analysis = map[string]string{
+				"Finding":             finding,
+				"Current Memory Size": fmt.Sprintf("%d", r.CurrentMemorySize),
+			}
+			for _, option := range r.MemorySizeRecommendationOptions {
+				if option.Rank == 1 {
+					analysis.Analysis["Recommended Memory Size"] = fmt.Sprintf("%d", option.MemorySize)
+					addSavings(analysis, option.SavingsOpportunity)
+				}
+			}
+			for _, reason := range r.FindingReasonCodes {
+				analysis.Message(reason)
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		lambdaInput.NextToken = output.NextToken
+	}
+}
+
+func computeOptimizerCategory(finding string) string {
+	if finding == findingUnderProvisioned {
+		return "performance"
+	}
+	return "cost"
+}
+
+func addSavings(analysis *v1.AnalysisResult, savings *savingsOpportunity) {
+	if savings == nil || savings.EstimatedMonthlySavings == nil {
+		return
+	}
+	analysis.Analysis["Estimated Monthly Savings"] = fmt.Sprintf("%.2f %s",
+		savings.EstimatedMonthlySavings.Value, savings.EstimatedMonthlySavings.Currency)
+	analysis.Analysis["Savings Opportunity Percentage"] = fmt.Sprintf("%.0f", savings.SavingsOpportunityPercentage)
+}
+
+// arnResourceID returns the id of a resource from an ARN of the form arn:aws:ec2:<region>:<account>:<type>/<id>
+func arnResourceID(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
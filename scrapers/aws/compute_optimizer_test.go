@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// testContext returns a context whose requests are sent to the handler
+func testContext(t *testing.T, handler http.HandlerFunc) *AWSContext {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &AWSContext{
+		ScrapeContext: &v1.ScrapeContext{Context: context.Background()},
+		Session: &aws.Config{
+			Region:                      "eu-west-1",
+			Credentials:                 credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+			EndpointResolverWithOptions: EndpointResolver{Endpoint: server.URL},
+			RetryMaxAttempts:            2,
+		},
+		Caller: &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")},
+	}
+}
+
+func TestComputeOptimizer(t *testing.T) {
+	responses := map[string]string{
+		"GetEC2InstanceRecommendations": `{"instanceRecommendations": [
+			{"instanceArn": "arn:aws:ec2:eu-west-1:123456789012:instance/i-1", "currentInstanceType": "m5.xlarge",
+			 "finding": "Overprovisioned", "findingReasonCodes": ["CPUOverprovisioned"],
+			 "recommendationOptions": [{"instanceType": "m5.large", "rank": 1,
+			   "savingsOpportunity": {"savingsOpportunityPercentage": 50, "estimatedMonthlySavings": {"currency": "USD", "value": 70.08}}}]},
+			{"instanceArn": "arn:aws:ec2:eu-west-1:123456789012:instance/i-2", "currentInstanceType": "t3.small", "finding": "Optimized"}]}`,
+		"GetEBSVolumeRecommendations":      `{"volumeRecommendations": []}`,
+		"GetLambdaFunctionRecommendations": `{"lambdaFunctionRecommendations": []}`,
+	}
+	var targets []string
+	ctx := testContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("expected a signed request, got %q", r.Header.Get("Authorization"))
+		}
+		target := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "ComputeOptimizerService.")
+		targets = append(targets, target)
+		_, _ = w.Write([]byte(responses[target]))
+	})
+
+	var results v1.ScrapeResults
+	Scraper{}.computeOptimizer(ctx, v1.AWS{ComputeOptimizer: true}, &results)
+	if len(targets) != 3 {
+		t.Fatalf("expected 3 operations, got %v", targets)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 analysis, got %d", len(results))
+	}
+	analysis := results[0].AnalysisResult
+	if analysis == nil || analysis.ExternalID != "i-1" {
+		t.Fatalf("expected the analysis of i-1, got %v", analysis)
+	}
+	if analysis.Analysis["Recommended Instance Type"] != "m5.large" || analysis.Analysis["Estimated Monthly Savings"] != "70.08 USD" {
+		t.Errorf("unexpected analysis %v", analysis.Analysis)
+	}
+}
+
+func TestJSONClientRetriesThrottledRequests(t *testing.T) {
+	attempts := 0
+	ctx := testContext(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type": "com.amazonaws#ThrottlingException", "message": "Rate exceeded"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"nextToken": "next"}`))
+	})
+	client := &jsonClient{cfg: *ctx.Session, endpointPrefix: "compute-optimizer", signingName: "compute-optimizer",
+		targetPrefix: "ComputeOptimizerService", contentType: "application/x-amz-json-1.0"}
+
+	var output recommendationsInput
+	if err := client.call(ctx, "GetEC2InstanceRecommendations", recommendationsInput{}, &output); err != nil {
+		t.Fatalf("expected the throttled request to be retried, got %v", err)
+	}
+	if attempts != 2 || deref(output.NextToken) != "next" {
+		t.Errorf("expected 2 attempts and the next token, got %d and %v", attempts, output.NextToken)
+	}
+}
+
+func TestJSONClientErrors(t *testing.T) {
+	ctx := testContext(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"__type": "OptInRequiredException", "message": "not opted in"})
+	})
+	client := &jsonClient{cfg: *ctx.Session, endpointPrefix: "compute-optimizer", signingName: "compute-optimizer",
+		targetPrefix: "ComputeOptimizerService", contentType: "application/x-amz-json-1.0"}
+
+	err := client.call(ctx, "GetEC2InstanceRecommendations", recommendationsInput{}, &recommendationsInput{})
+	if err == nil || err.Error() != "GetEC2InstanceRecommendations: OptInRequiredException: not opted in" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
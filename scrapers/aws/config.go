@@ -6,24 +6,41 @@ import (
 	v1 "github.com/flanksource/config-db/api/v1"
 )
 
+// configResourceTypes maps the AWS Config resource types whose name differs from the external type of the scraped item
+var configResourceTypes = map[string]string{
+	"AWS::EC2::Volume": v1.AWSEBSVolume,
+	"AWS::IAM::Policy": v1.AWSIAMPolicy,
+}
+
+// config imports the evaluations of the AWS Config rules as analysis of the evaluated resources, compliant
+// evaluations resolve the analysis opened by a previous non-compliant evaluation
 func (aws Scraper) config(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
 	if !config.Compliance {
 		return
 	}
 
-	rules, err := ctx.Config.DescribeConfigRules(ctx, nil)
-	if err != nil {
-		results.Errorf(err, "Failed to describe config rules")
-		return
+	rules := configservice.NewDescribeConfigRulesPaginator(ctx.Config, &configservice.DescribeConfigRulesInput{})
+	for rules.HasMorePages() {
+		page, err := rules.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe config rules")
+			return
+		}
+		for _, rule := range page.ConfigRules {
+			aws.configRuleCompliance(ctx, rule, results)
+		}
 	}
+}
 
-	for _, rule := range rules.ConfigRules {
-		details, err := ctx.Config.GetComplianceDetailsByConfigRule(ctx, &configservice.GetComplianceDetailsByConfigRuleInput{
-			ConfigRuleName:  rule.ConfigRuleName,
-			ComplianceTypes: []types.ComplianceType{types.ComplianceTypeNonCompliant},
-		})
+func (aws Scraper) configRuleCompliance(ctx *AWSContext, rule types.ConfigRule, results *v1.ScrapeResults) {
+	paginator := configservice.NewGetComplianceDetailsByConfigRulePaginator(ctx.Config, &configservice.GetComplianceDetailsByConfigRuleInput{
+		ConfigRuleName:  rule.ConfigRuleName,
+		ComplianceTypes: []types.ComplianceType{types.ComplianceTypeNonCompliant, types.ComplianceTypeCompliant},
+	})
+	for paginator.HasMorePages() {
+		details, err := paginator.NextPage(ctx)
 		if err != nil {
-			results.Errorf(err, "Failed to describe config rules")
+			results.Errorf(err, "failed to get compliance details of config rule %s", *rule.ConfigRuleName)
 			return
 		}
 		for _, compliance := range details.EvaluationResults {
@@ -34,10 +51,26 @@ func (aws Scraper) config(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResu
 				continue
 			}
 			obj := compliance.EvaluationResultIdentifier.EvaluationResultQualifier
-			results.Analysis(*obj.ConfigRuleName, *obj.ResourceType, *obj.ResourceId).
-				Message(deref(rule.Description)).
+			resourceType := deref(obj.ResourceType)
+			if externalType, ok := configResourceTypes[resourceType]; ok {
+				resourceType = externalType
+			}
+
+			status := "open"
+			if compliance.ComplianceType == types.ComplianceTypeCompliant {
+				status = "resolved"
+			}
+			analysis := results.Analysis(*obj.ConfigRuleName, resourceType, deref(obj.ResourceId))
+			analysis.AnalysisType = "compliance"
+			analysis.Severity = "warning"
+			analysis.Status = status
+			analysis.Summary = deref(rule.Description)
+			analysis.Message(deref(rule.Description)).
 				Message(deref(compliance.Annotation))
+			analysis.Analysis = map[string]string{
+				"rule":       deref(rule.ConfigRuleArn),
+				"compliance": string(compliance.ComplianceType),
+			}
 		}
 	}
-
 }
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/metrics"
 	athena "github.com/uber/athenadriver/go"
 )
 
@@ -47,6 +50,26 @@ const costQueryTemplate = `
     ON cost_30d.line_item_product_code = items.line_item_product_code AND items.line_item_resource_id = cost_30d.line_item_resource_id
 `
 
+// commitmentQueryTemplate returns the amortized cost and the on-demand cost of the last 30 days of every resource, and
+// how much of it was covered by Reserved Instances and Savings Plans. The expressions that read the reservation and
+// savings plan columns are only added when the report has them
+const commitmentQueryTemplate = `
+    WITH
+        max_end_date AS (SELECT MAX(line_item_usage_end_date) as end_date FROM $table WHERE line_item_usage_end_date <= now()
+    )
+
+    SELECT
+        line_item_product_code, line_item_resource_id,
+        SUM($amortized) as amortized_30d,
+        SUM(CASE WHEN line_item_line_item_type IN ('DiscountedUsage', 'SavingsPlanCoveredUsage') THEN pricing_public_on_demand_cost ELSE 0 END) as covered_30d,
+        SUM(CASE WHEN line_item_line_item_type IN ('Usage', 'DiscountedUsage', 'SavingsPlanCoveredUsage') THEN pricing_public_on_demand_cost ELSE 0 END) as on_demand_30d,
+        SUM($used) as commitment_used_30d,
+        SUM($unused) as commitment_unused_30d
+    FROM $table
+    WHERE line_item_usage_start_date >= (SELECT date_add('day', -30, end_date) FROM max_end_date)
+    GROUP BY line_item_product_code, line_item_resource_id
+`
+
 func getAWSAthenaConfig(ctx *v1.ScrapeContext, awsConfig v1.AWS) (*athena.Config, error) {
 	conf := athena.NewNoOpsConfig()
 
@@ -56,32 +79,218 @@ func getAWSAthenaConfig(ctx *v1.ScrapeContext, awsConfig v1.AWS) (*athena.Config
 	if err := conf.SetOutputBucket(awsConfig.CostReporting.S3BucketPath); err != nil {
 		return nil, err
 	}
+	if awsConfig.CostReporting.Workgroup != "" {
+		// the workgroup is managed outside of config-db, its settings e.g. the limit of scanned bytes are not changed
+		conf.SetWGRemoteCreationAllowed(false)
+		if err := conf.SetWorkGroup(athena.NewWG(awsConfig.CostReporting.Workgroup, nil, nil)); err != nil {
+			return nil, err
+		}
+	}
 
-	accessKey, secretKey, err := getAccessAndSecretKey(ctx, *awsConfig.AWSConnection)
+	// athenadriver only accepts static credentials, they are retrieved from the same chain as the other
+	// AWS clients so that instance profiles, IRSA web identity tokens and assumed roles work
+	session, err := NewSession(ctx, *awsConfig.AWSConnection, awsConfig.CostReporting.Region)
 	if err != nil {
 		return nil, err
 	}
-	if len(accessKey) > 0 && len(secretKey) > 0 {
-		if err = conf.SetAccessID(accessKey); err != nil {
-			return nil, err
-		}
-		if err = conf.SetSecretAccessKey(secretKey); err != nil {
-			return nil, err
-		}
+	creds, err := session.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials for athena: %v", err)
+	}
+	if err = conf.SetAccessID(creds.AccessKeyID); err != nil {
+		return nil, err
 	}
+	if err = conf.SetSecretAccessKey(creds.SecretAccessKey); err != nil {
+		return nil, err
+	}
+	conf.SetSessionToken(creds.SessionToken)
 	return conf, nil
 }
 
 type LineItemRow struct {
-	ProductCode string
-	ResourceID  string
-	Cost1h      float64
-	Cost1d      float64
-	Cost7d      float64
-	Cost30d     float64
+	ProductCode         string
+	ResourceID          string
+	Cost1h              float64
+	Cost1d              float64
+	Cost7d              float64
+	Cost30d             float64
+	CostAmortized30d    float64
+	Covered30d          float64
+	OnDemand30d         float64
+	CommitmentUsed30d   float64
+	CommitmentUnused30d float64
+	// Err is the error reading the row, rows that could not be scanned have no product code and resource id
+	Err error
+}
+
+// costParser parses the cost columns of a row, null costs are read as empty strings and are 0, the first column
+// that is not a number is kept as the error of the row
+type costParser struct {
+	err error
+}
+
+func (p *costParser) parse(column, value string) float64 {
+	if value == "" {
+		return 0
+	}
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid %s %q: %v", column, value, err)
+	}
+	return cost
+}
+
+// percentage returns part as a percentage of total, or nil when there is no total
+func percentage(part, total float64) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	p := part / total * 100
+	return &p
+}
+
+// Coverage returns the percentage of the on-demand cost covered by Reserved Instances and Savings Plans
+func (row LineItemRow) Coverage() *float64 {
+	return percentage(row.Covered30d, row.OnDemand30d)
+}
+
+// caseOf returns a CASE expression of the WHEN clauses, or the default when there are none
+func caseOf(whens []string, otherwise string) string {
+	if len(whens) == 0 {
+		return otherwise
+	}
+	return fmt.Sprintf("CASE %s ELSE %s END", strings.Join(whens, " "), otherwise)
+}
+
+// commitmentQuery returns the commitment query of the table, the reservation and savings plan columns are only
+// added to reports of accounts that purchased them
+func commitmentQuery(athenaDB *sql.DB, config v1.AWS) (string, error) {
+	rows, err := athenaDB.Query(fmt.Sprintf(`SELECT column_name FROM information_schema.columns WHERE table_schema = '%s' AND table_name = '%s'`,
+		strings.ToLower(config.CostReporting.Database), strings.ToLower(config.CostReporting.Table)))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return "", err
+		}
+		columns[column] = true
+	}
+
+	var amortized, used, unused []string
+	if columns["reservation_effective_cost"] {
+		riUnused := "reservation_unused_amortized_upfront_fee_for_billing_period + reservation_unused_recurring_fee"
+		amortized = append(amortized,
+			"WHEN line_item_line_item_type = 'DiscountedUsage' THEN reservation_effective_cost",
+			"WHEN line_item_line_item_type = 'RIFee' THEN "+riUnused,
+			"WHEN line_item_line_item_type = 'Fee' AND reservation_reservation_a_r_n <> '' THEN 0")
+		used = append(used, "WHEN line_item_line_item_type = 'DiscountedUsage' THEN reservation_effective_cost")
+		unused = append(unused, "WHEN line_item_line_item_type = 'RIFee' THEN "+riUnused)
+	}
+	if columns["savings_plan_savings_plan_effective_cost"] {
+		spUnused := "savings_plan_total_commitment_to_date - savings_plan_used_commitment"
+		amortized = append(amortized,
+			"WHEN line_item_line_item_type = 'SavingsPlanCoveredUsage' THEN savings_plan_savings_plan_effective_cost",
+			"WHEN line_item_line_item_type = 'SavingsPlanRecurringFee' THEN "+spUnused,
+			"WHEN line_item_line_item_type IN ('SavingsPlanNegation', 'SavingsPlanUpfrontFee') THEN 0")
+		used = append(used, "WHEN line_item_line_item_type = 'SavingsPlanRecurringFee' THEN savings_plan_used_commitment")
+		unused = append(unused, "WHEN line_item_line_item_type = 'SavingsPlanRecurringFee' THEN "+spUnused)
+	}
+
+	table := fmt.Sprintf("%s.%s", config.CostReporting.Database, config.CostReporting.Table)
+	return strings.NewReplacer(
+		"$table", table,
+		"$amortized", caseOf(amortized, "line_item_unblended_cost"),
+		"$used", caseOf(used, "0"),
+		"$unused", caseOf(unused, "0"),
+	).Replace(commitmentQueryTemplate), nil
+}
+
+// fetchCommitments adds the amortized cost and commitment coverage of the last 30 days to the rows, resources that
+// only have fees of reservations or savings plans are added as new rows
+func fetchCommitments(athenaDB *sql.DB, config v1.AWS, lineItemRows []LineItemRow) ([]LineItemRow, error) {
+	query, err := commitmentQuery(athenaDB, config)
+	if err != nil {
+		return lineItemRows, err
+	}
+	rows, err := athenaDB.Query(query)
+	if err != nil {
+		return lineItemRows, err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int)
+	for i, row := range lineItemRows {
+		index[row.ProductCode+"/"+row.ResourceID] = i
+	}
+	for rows.Next() {
+		var productCode, resourceID, amortized, covered, onDemand, used, unused string
+		if err := rows.Scan(&productCode, &resourceID, &amortized, &covered, &onDemand, &used, &unused); err != nil {
+			lineItemRows = append(lineItemRows, LineItemRow{Err: fmt.Errorf("failed to scan commitments: %v", err)})
+			continue
+		}
+
+		i, ok := index[productCode+"/"+resourceID]
+		if !ok {
+			lineItemRows = append(lineItemRows, LineItemRow{ProductCode: productCode, ResourceID: resourceID})
+			i = len(lineItemRows) - 1
+		}
+		row := &lineItemRows[i]
+		parser := costParser{err: row.Err}
+		row.CostAmortized30d = parser.parse("amortized_30d", amortized)
+		row.Covered30d = parser.parse("covered_30d", covered)
+		row.OnDemand30d = parser.parse("on_demand_30d", onDemand)
+		row.CommitmentUsed30d = parser.parse("commitment_used_30d", used)
+		row.CommitmentUnused30d = parser.parse("commitment_unused_30d", unused)
+		row.Err = parser.err
+	}
+	// an error while iterating means the rows are incomplete
+	return lineItemRows, rows.Err()
+}
+
+// costCache caches the rows of a report by day, the report is only updated a few times a day so that scrapes within
+// the cache TTL reuse the rows instead of scanning the report again
+var costCache = sync.Map{}
+
+type cachedCosts struct {
+	rows    []LineItemRow
+	expires time.Time
+}
+
+// costCacheKey identifies the rows of a report of the current day
+func costCacheKey(config v1.AWS) string {
+	report := config.CostReporting
+	return fmt.Sprintf("%s/%s/%s.%s/%s", report.Region, report.Workgroup, report.Database, report.Table, time.Now().UTC().Format("2006-01-02"))
 }
 
+// FetchCosts returns the costs of the resources in the report, rows fetched earlier on the same day are reused
+// until the cache TTL expires
 func FetchCosts(ctx *v1.ScrapeContext, config v1.AWS) ([]LineItemRow, error) {
+	ttl := config.CostReporting.GetCacheTTL()
+	key := costCacheKey(config)
+	if cached, ok := costCache.Load(key); ok && ttl > 0 && time.Now().Before(cached.(cachedCosts).expires) {
+		logger.Debugf("Reusing costs of %s", key)
+		return cached.(cachedCosts).rows, nil
+	}
+
+	rows, err := fetchCosts(ctx, config)
+	if err != nil || ttl <= 0 {
+		return rows, err
+	}
+	costCache.Range(func(k, v any) bool {
+		if time.Now().After(v.(cachedCosts).expires) {
+			costCache.Delete(k)
+		}
+		return true
+	})
+	costCache.Store(key, cachedCosts{rows: rows, expires: time.Now().Add(ttl)})
+	return rows, nil
+}
+
+func fetchCosts(ctx *v1.ScrapeContext, config v1.AWS) ([]LineItemRow, error) {
 	var lineItemRows []LineItemRow
 
 	athenaConf, err := getAWSAthenaConfig(ctx, config)
@@ -101,30 +310,31 @@ func FetchCosts(ctx *v1.ScrapeContext, config v1.AWS) ([]LineItemRow, error) {
 	if err != nil {
 		return lineItemRows, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var productCode, resourceID, cost1h, cost1d, cost7d, cost30d string
 		if err := rows.Scan(&productCode, &resourceID, &cost1h, &cost1d, &cost7d, &cost30d); err != nil {
-			logger.Errorf("Error scanning athena database rows: %v", err)
+			lineItemRows = append(lineItemRows, LineItemRow{Err: fmt.Errorf("failed to scan costs: %v", err)})
 			continue
 		}
 
-		cost1hFloat, _ := strconv.ParseFloat(cost1h, 64)
-		cost1dFloat, _ := strconv.ParseFloat(cost1d, 64)
-		cost7dFloat, _ := strconv.ParseFloat(cost7d, 64)
-		cost30dFloat, _ := strconv.ParseFloat(cost30d, 64)
-
+		var parser costParser
 		lineItemRows = append(lineItemRows, LineItemRow{
 			ProductCode: productCode,
 			ResourceID:  resourceID,
-			Cost1h:      cost1hFloat,
-			Cost1d:      cost1dFloat,
-			Cost7d:      cost7dFloat,
-			Cost30d:     cost30dFloat,
+			Cost1h:      parser.parse("cost_1h", cost1h),
+			Cost1d:      parser.parse("cost_1d", cost1d),
+			Cost7d:      parser.parse("cost_7d", cost7d),
+			Cost30d:     parser.parse("cost_30d", cost30d),
+			Err:         parser.err,
 		})
 	}
+	if err := rows.Err(); err != nil {
+		return lineItemRows, err
+	}
 
-	return lineItemRows, nil
+	return fetchCommitments(athenaDB, config, lineItemRows)
 }
 
 type CostScraper struct{}
@@ -135,29 +345,56 @@ func (awsCost CostScraper) Scrape(ctx *v1.ScrapeContext, config v1.ConfigScraper
 	for _, awsConfig := range config.AWS {
 		session, err := NewSession(ctx, *awsConfig.AWSConnection, awsConfig.Region[0])
 		if err != nil {
-			return results.Errorf(err, "failed to create AWS session")
+			results.Errorf(err, "failed to create AWS session")
+			continue
 		}
 		stsClient := sts.NewFromConfig(*session)
 		caller, err := stsClient.GetCallerIdentity(ctx, nil)
 		if err != nil {
-			return results.Errorf(err, "failed to get identity")
+			results.Errorf(err, "failed to get identity")
+			continue
 		}
 		accountID := *caller.Account
 
+		start := time.Now()
 		rows, err := FetchCosts(ctx, awsConfig)
+		metrics.Since(metrics.CostQueryDuration.WithLabelValues("aws_athena", metrics.Status(err)), start)
 		if err != nil {
-			return results.Errorf(err, "failed to fetch costs")
+			// costs are not updated from incomplete rows, as the costs of the account would be wrong
+			results.ItemErrorf(v1.AWSAccount, accountID, err, "failed to fetch costs")
+			continue
+		}
+		if ctx.DryRun {
+			logger.Infof("Dry run, skipping the update of the costs of %d AWS resources of %s", len(rows), accountID)
+			continue
 		}
 
 		gormDB := db.DefaultDB()
-		var accountTotal1h, accountTotal1d, accountTotal7d, accountTotal30d float64
+		var accountTotal1h, accountTotal1d, accountTotal7d, accountTotal30d, accountAmortized30d float64
+		// coverage and utilization of the account are over all of its resources, not only the ones that are not scraped
+		var covered, onDemand, used, unused float64
 		for _, row := range rows {
+			if row.Err != nil {
+				if row.ResourceID == "" {
+					results.Errorf(row.Err, "failed to read costs of account %s", accountID)
+				} else {
+					results.ItemErrorf("", row.ProductCode+"/"+row.ResourceID, row.Err, "failed to read costs")
+				}
+				continue
+			}
+			covered += row.Covered30d
+			onDemand += row.OnDemand30d
+			used += row.CommitmentUsed30d
+			unused += row.CommitmentUnused30d
+
 			tx := gormDB.Exec(`
-                UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?
-                WHERE ? = ANY(external_id)`, row.Cost1h/60, row.Cost1d, row.Cost7d, row.Cost30d, fmt.Sprintf("%s/%s", row.ProductCode, row.ResourceID))
+                UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?,
+                    cost_amortized_30d = ?, commitment_coverage_30d = ?
+                WHERE ? = ANY(external_id) AND tenant = ?`, row.Cost1h/60, row.Cost1d, row.Cost7d, row.Cost30d, row.CostAmortized30d, row.Coverage(),
+				fmt.Sprintf("%s/%s", row.ProductCode, row.ResourceID), ctx.Tenant)
 
 			if tx.Error != nil {
-				logger.Errorf("Error updating costs for config_item: %v", err)
+				results.ItemErrorf("", row.ProductCode+"/"+row.ResourceID, tx.Error, "failed to update costs")
 				continue
 			}
 
@@ -166,18 +403,22 @@ func (awsCost CostScraper) Scrape(ctx *v1.ScrapeContext, config v1.ConfigScraper
 				accountTotal1d += row.Cost1d
 				accountTotal7d += row.Cost7d
 				accountTotal30d += row.Cost30d
+				accountAmortized30d += row.CostAmortized30d
 				continue
 			}
 			logger.Infof("Updated cost for AWS Resource: %s/%s", row.ProductCode, row.ResourceID)
 		}
 
 		err = gormDB.Exec(`
-            UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?
-            WHERE external_type = 'AWS::::Account' AND ? = ANY(external_id)`,
-			accountTotal1h/60, accountTotal1d, accountTotal7d, accountTotal30d, accountID,
+            UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?,
+                cost_amortized_30d = ?, commitment_coverage_30d = ?, commitment_utilization_30d = ?
+            WHERE external_type = 'AWS::::Account' AND ? = ANY(external_id) AND tenant = ?`,
+			accountTotal1h/60, accountTotal1d, accountTotal7d, accountTotal30d,
+			accountAmortized30d, percentage(covered, onDemand), percentage(used, used+unused), accountID, ctx.Tenant,
 		).Error
 		if err != nil {
-			logger.Errorf("Error updating costs for account: %v", err)
+			results.ItemErrorf(v1.AWSAccount, accountID, err, "failed to update costs")
+			continue
 		}
 		logger.Infof("Updated cost for AWS Account: %s", accountID)
 	}
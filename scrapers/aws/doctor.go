@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// Diagnose verifies the credentials of the connection in the first region it scrapes and, when cost reporting is
+// configured, that the cost table exists in the data catalog, no athena query is run as queries are billed
+func Diagnose(ctx *v1.ScrapeContext, config v1.AWS) []v1.Diagnostic {
+	var diagnostics []v1.Diagnostic
+	check := func(name string, err error) {
+		d := v1.Diagnostic{Check: name}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	regions, err := Scraper{}.regions(ctx, config, "")
+	if err != nil {
+		check("aws regions", err)
+		return diagnostics
+	}
+	if len(regions) > 0 {
+		check("aws sts "+regions[0], checkCallerIdentity(ctx, config, regions[0]))
+	}
+	if config.Organization != nil {
+		_, err := Scraper{}.organizationAccounts(ctx, config)
+		check("aws organizations", err)
+	}
+	if config.CostReporting.Table != "" {
+		check("aws athena "+config.CostReporting.Database+"."+config.CostReporting.Table, checkCostTable(ctx, config))
+	}
+	return diagnostics
+}
+
+func checkCallerIdentity(ctx *v1.ScrapeContext, config v1.AWS, region string) error {
+	session, err := NewSession(ctx, *config.AWSConnection, region)
+	if err != nil {
+		return err
+	}
+	_, err = sts.NewFromConfig(*session).GetCallerIdentity(ctx, nil)
+	return err
+}
+
+// checkCostTable looks up the cost table in the glue data catalog athena queries, it fails on invalid credentials
+// or a missing database or table
+func checkCostTable(ctx *v1.ScrapeContext, config v1.AWS) error {
+	session, err := NewSession(ctx, *config.AWSConnection, config.CostReporting.Region)
+	if err != nil {
+		return err
+	}
+	_, err = glue.NewFromConfig(*session).GetTable(ctx, &glue.GetTableInput{
+		DatabaseName: aws.String(config.CostReporting.Database),
+		Name:         aws.String(config.CostReporting.Table),
+	})
+	return err
+}
@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// recentImages is the number of most recently pushed images recorded on a repository
+const recentImages = 10
+
+// ECRRepository is a repository together with its lifecycle policy and most recently pushed images
+type ECRRepository struct {
+	ecrTypes.Repository
+	LifecyclePolicy map[string]interface{} `json:"LifecyclePolicy,omitempty"`
+	RecentImages    []ECRImage             `json:"RecentImages,omitempty"`
+}
+
+// ECRImage is a pushed image with the summary of its latest scan
+type ECRImage struct {
+	Digest                string           `json:"Digest"`
+	Tags                  []string         `json:"Tags,omitempty"`
+	PushedAt              *time.Time       `json:"PushedAt,omitempty"`
+	SizeInBytes           int64            `json:"SizeInBytes,omitempty"`
+	ScanStatus            string           `json:"ScanStatus,omitempty"`
+	FindingSeverityCounts map[string]int32 `json:"FindingSeverityCounts,omitempty"`
+}
+
+func (aws Scraper) containerImages(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("ECR") {
+		return
+	}
+
+	ECR := ctx.client("ecr", func() interface{} { return ecr.NewFromConfig(*ctx.Session) }).(*ecr.Client)
+	paginator := ecr.NewDescribeRepositoriesPaginator(ECR, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		repositories, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get ecr")
+			return
+		}
+		for _, repository := range repositories.Repositories {
+			repo := ECRRepository{Repository: repository}
+
+			if policy, err := ECR.GetLifecyclePolicy(ctx, &ecr.GetLifecyclePolicyInput{RepositoryName: repository.RepositoryName}); err == nil {
+				if policy.LifecyclePolicyText != nil {
+					if err := json.Unmarshal([]byte(*policy.LifecyclePolicyText), &repo.LifecyclePolicy); err != nil {
+						repo.LifecyclePolicy = map[string]interface{}{"raw": *policy.LifecyclePolicyText}
+					}
+				}
+			} else if !isErrorCode(err, "LifecyclePolicyNotFoundException") {
+				results.Errorf(err, "failed to get lifecycle policy of repository %s", *repository.RepositoryName)
+			}
+
+			images, err := aws.recentImages(ctx, ECR, repository)
+			if err != nil {
+				results.Errorf(err, "failed to describe images of repository %s", *repository.RepositoryName)
+			}
+			repo.RecentImages = images
+
+			var relationships v1.RelationshipResults
+			if repository.EncryptionConfiguration != nil {
+				relationships = encryptedBy(v1.ExternalID{
+					ExternalID:   []string{*repository.RepositoryUri},
+					ExternalType: v1.AWSECRRepository,
+				}, deref(repository.EncryptionConfiguration.KmsKey), ctx.Session.Region, *ctx.Caller.Account)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				CreatedAt:    repository.CreatedAt,
+				ExternalType: v1.AWSECRRepository,
+				BaseScraper:  config.BaseScraper,
+				Config:       repo,
+				Type:         "Container",
+				Name:         *repository.RepositoryName,
+				Aliases:      []string{*repository.RepositoryArn, "AmazonECR/" + *repository.RepositoryArn},
+				Account:      *ctx.Caller.Account,
+				Region:       ctx.Session.Region,
+				ID:           *repository.RepositoryUri,
+				Ignore: []string{
+					"CreatedAt", "RepositoryArn", "RepositoryUri", "RegistryId", "RepositoryName",
+				},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// recentImages returns the most recently pushed images of the repository, newest first
+func (aws Scraper) recentImages(ctx *AWSContext, client *ecr.Client, repository ecrTypes.Repository) ([]ECRImage, error) {
+	var details []ecrTypes.ImageDetail
+	paginator := ecr.NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: repository.RepositoryName,
+		RegistryId:     repository.RegistryId,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, page.ImageDetails...)
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].ImagePushedAt == nil || details[j].ImagePushedAt == nil {
+			return details[j].ImagePushedAt == nil && details[i].ImagePushedAt != nil
+		}
+		return details[i].ImagePushedAt.After(*details[j].ImagePushedAt)
+	})
+	if len(details) > recentImages {
+		details = details[:recentImages]
+	}
+
+	var images []ECRImage
+	for _, detail := range details {
+		image := ECRImage{
+			Digest:   deref(detail.ImageDigest),
+			Tags:     detail.ImageTags,
+			PushedAt: detail.ImagePushedAt,
+		}
+		if detail.ImageSizeInBytes != nil {
+			image.SizeInBytes = *detail.ImageSizeInBytes
+		}
+		if detail.ImageScanStatus != nil {
+			image.ScanStatus = string(detail.ImageScanStatus.Status)
+		}
+		if detail.ImageScanFindingsSummary != nil {
+			image.FindingSeverityCounts = detail.ImageScanFindingsSummary.FindingSeverityCounts
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	efsTypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+	"github.com/aws/aws-sdk-go-v2/service/fsx"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// EFSFileSystem is a file system with the lifecycle policies that move its files between storage classes
+type EFSFileSystem struct {
+	efsTypes.FileSystemDescription
+	LifecyclePolicies []efsTypes.LifecyclePolicy `json:"LifecyclePolicies,omitempty"`
+}
+
+// EFSMountTarget is a mount target with the security groups of its network interface
+type EFSMountTarget struct {
+	efsTypes.MountTargetDescription
+	SecurityGroups []string `json:"SecurityGroups,omitempty"`
+}
+
+func (aws Scraper) efs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("EFS") {
+		return
+	}
+	EFS := ctx.client("efs", func() interface{} { return efs.NewFromConfig(*ctx.Session) }).(*efs.Client)
+	paginator := efs.NewDescribeFileSystemsPaginator(EFS, &efs.DescribeFileSystemsInput{})
+	for paginator.HasMorePages() {
+		describeOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get efs")
+			return
+		}
+		for _, fs := range describeOutput.FileSystems {
+			tags := make(v1.JSONStringMap)
+			for _, tag := range fs.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+
+			fileSystem := EFSFileSystem{FileSystemDescription: fs}
+			if lifecycle, err := EFS.DescribeLifecycleConfiguration(ctx, &efs.DescribeLifecycleConfigurationInput{FileSystemId: fs.FileSystemId}); err != nil {
+				results.Errorf(err, "failed to get lifecycle configuration of efs %s", *fs.FileSystemId)
+			} else {
+				fileSystem.LifecyclePolicies = lifecycle.LifecyclePolicies
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*fs.FileSystemId}, ExternalType: v1.AWSEFSFileSystem}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEFSFileSystem,
+				CreatedAt:           fs.CreationTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              fileSystem,
+				Type:                "EFS",
+				Name:                getName(tags, *fs.FileSystemId),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{deref(fs.FileSystemArn), "AmazonEFS/" + deref(fs.FileSystemArn)},
+				ID:                  *fs.FileSystemId,
+				Ignore:              []string{"SizeInBytes", "NumberOfMountTargets"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: encryptedBy(self, deref(fs.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account),
+			})
+
+			aws.efsMountTargets(ctx, config, EFS, *fs.FileSystemId, results)
+		}
+	}
+}
+
+// efsMountTargets scrapes the mount targets of a file system, which are its network interfaces in each zone
+func (aws Scraper) efsMountTargets(ctx *AWSContext, config v1.AWS, client *efs.Client, fileSystemID string, results *v1.ScrapeResults) {
+	targets, err := client.DescribeMountTargets(ctx, &efs.DescribeMountTargetsInput{FileSystemId: &fileSystemID})
+	if err != nil {
+		results.Errorf(err, "failed to get mount targets of efs %s", fileSystemID)
+		return
+	}
+	for _, target := range targets.MountTargets {
+		mountTarget := EFSMountTarget{MountTargetDescription: target}
+		groups, err := client.DescribeMountTargetSecurityGroups(ctx, &efs.DescribeMountTargetSecurityGroupsInput{MountTargetId: target.MountTargetId})
+		if err != nil {
+			results.Errorf(err, "failed to get security groups of mount target %s", *target.MountTargetId)
+		} else {
+			mountTarget.SecurityGroups = groups.SecurityGroups
+		}
+
+		self := v1.ExternalID{ExternalID: []string{*target.MountTargetId}, ExternalType: v1.AWSEFSMountTarget}
+		var relationships v1.RelationshipResults
+		for _, group := range mountTarget.SecurityGroups {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID:  self,
+				RelatedExternalID: v1.ExternalID{ExternalID: []string{group}, ExternalType: v1.AWSEC2SecurityGroup},
+				Relationship:      "MountTargetSecurityGroup",
+			})
+		}
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  v1.ExternalID{ExternalID: []string{deref(target.SubnetId)}, ExternalType: v1.AWSEC2Subnet},
+			RelatedExternalID: self,
+			Relationship:      "SubnetMountTarget",
+		})
+
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType:        v1.AWSEFSMountTarget,
+			BaseScraper:         config.BaseScraper,
+			Config:              mountTarget,
+			Type:                "EFSMountTarget",
+			Name:                *target.MountTargetId,
+			Account:             *ctx.Caller.Account,
+			Region:              ctx.Session.Region,
+			Network:             deref(target.VpcId),
+			Subnet:              deref(target.SubnetId),
+			Zone:                deref(target.AvailabilityZoneName),
+			ID:                  *target.MountTargetId,
+			ParentExternalID:    fileSystemID,
+			ParentExternalType:  v1.AWSEFSFileSystem,
+			RelationshipResults: relationships,
+		})
+	}
+}
+
+// fsx scrapes FSx for Lustre, Windows File Server, NetApp ONTAP and OpenZFS file systems
+func (aws Scraper) fsx(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("FSx") {
+		return
+	}
+	FSx := ctx.client("fsx", func() interface{} { return fsx.NewFromConfig(*ctx.Session) }).(*fsx.Client)
+	paginator := fsx.NewDescribeFileSystemsPaginator(FSx, &fsx.DescribeFileSystemsInput{})
+	for paginator.HasMorePages() {
+		describeOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get fsx file systems")
+			return
+		}
+		for _, fs := range describeOutput.FileSystems {
+			tags := make(v1.JSONStringMap)
+			for _, tag := range fs.Tags {
+				tags[*tag.Key] = *tag.Value
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*fs.FileSystemId}, ExternalType: v1.AWSFSxFileSystem}
+			relationships := encryptedBy(self, deref(fs.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)
+			for _, subnet := range fs.SubnetIds {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{subnet}, ExternalType: v1.AWSEC2Subnet},
+					RelatedExternalID: self,
+					Relationship:      "SubnetFileSystem",
+				})
+			}
+
+			var subnet string
+			if len(fs.SubnetIds) > 0 {
+				subnet = fs.SubnetIds[0]
+			}
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSFSxFileSystem,
+				CreatedAt:           fs.CreationTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              fs,
+				Type:                "FSx",
+				Name:                getName(tags, *fs.FileSystemId),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Network:             deref(fs.VpcId),
+				Subnet:              subnet,
+				Aliases:             []string{deref(fs.ResourceARN), "AmazonFSx/" + deref(fs.ResourceARN)},
+				ID:                  *fs.FileSystemId,
+				Ignore:              []string{"AdministrativeActions"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
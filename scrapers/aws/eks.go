@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) eksClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("EKS") {
+		return
+	}
+	EKS := ctx.client("eks", func() interface{} { return eks.NewFromConfig(*ctx.Session) }).(*eks.Client)
+	paginator := eks.NewListClustersPaginator(EKS, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list clusters")
+			return
+		}
+		for _, clusterName := range clusters.Clusters {
+			cluster, err := EKS.DescribeCluster(ctx, &eks.DescribeClusterInput{
+				Name: strPtr(clusterName),
+			})
+			if err != nil {
+				results.Errorf(err, "failed to describe cluster")
+				continue
+			}
+
+			var relationships v1.RelationshipResults
+			if cluster.Cluster.RoleArn != nil {
+				relationships = append(relationships, roleRelationship(*cluster.Cluster.RoleArn, v1.ExternalID{
+					ExternalID:   []string{clusterName},
+					ExternalType: v1.AWSEKSCluster,
+				}, "IAMRoleEKSCluster"))
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEKSCluster,
+				CreatedAt:           cluster.Cluster.CreatedAt,
+				Tags:                cluster.Cluster.Tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster.Cluster,
+				Type:                "EKS",
+				Network:             *cluster.Cluster.ResourcesVpcConfig.VpcId,
+				Name:                getName(cluster.Cluster.Tags, clusterName),
+				Account:             *ctx.Caller.Account,
+				Aliases:             []string{*cluster.Cluster.Arn, "AmazonEKS/" + *cluster.Cluster.Arn},
+				ID:                  *cluster.Cluster.Name,
+				Ignore:              []string{"createdAt", "name"},
+				ParentExternalID:    *cluster.Cluster.ResourcesVpcConfig.VpcId,
+				ParentExternalType:  v1.AWSEC2VPC,
+				RelationshipResults: relationships,
+			})
+
+			aws.eksNodeGroups(ctx, config, EKS, clusterName, results)
+			aws.eksAddons(ctx, config, EKS, clusterName, results)
+			aws.eksFargateProfiles(ctx, config, EKS, clusterName, results)
+		}
+	}
+}
+
+func (aws Scraper) eksNodeGroups(ctx *AWSContext, config v1.AWS, client *eks.Client, clusterName string, results *v1.ScrapeResults) {
+	paginator := eks.NewListNodegroupsPaginator(client, &eks.ListNodegroupsInput{ClusterName: strPtr(clusterName)})
+	for paginator.HasMorePages() {
+		nodegroups, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list nodegroups of cluster %s", clusterName)
+			return
+		}
+		for _, name := range nodegroups.Nodegroups {
+			nodegroup, err := client.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
+				ClusterName:   strPtr(clusterName),
+				NodegroupName: strPtr(name),
+			})
+			if err != nil {
+				results.Errorf(err, "failed to describe nodegroup %s", name)
+				continue
+			}
+
+			id := v1.ExternalID{
+				ExternalID:   []string{*nodegroup.Nodegroup.NodegroupArn},
+				ExternalType: v1.AWSEKSNodeGroup,
+			}
+			var relationships v1.RelationshipResults
+			if nodegroup.Nodegroup.NodeRole != nil {
+				relationships = append(relationships, roleRelationship(*nodegroup.Nodegroup.NodeRole, id, "IAMRoleEKSNodeGroup"))
+			}
+			// the auto scaling groups and launch template that back the nodes of the nodegroup
+			if nodegroup.Nodegroup.Resources != nil {
+				for _, group := range nodegroup.Nodegroup.Resources.AutoScalingGroups {
+					if group.Name == nil {
+						continue
+					}
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  id,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{*group.Name}, ExternalType: v1.AWSAutoScalingGroup},
+						Relationship:      "EKSNodeGroupAutoScalingGroup",
+					})
+				}
+			}
+			if template := nodegroup.Nodegroup.LaunchTemplate; template != nil && template.Id != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*template.Id}, ExternalType: v1.AWSEC2LaunchTemplate},
+					RelatedExternalID: id,
+					Relationship:      "LaunchTemplateEKSNodeGroup",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEKSNodeGroup,
+				CreatedAt:           nodegroup.Nodegroup.CreatedAt,
+				Tags:                nodegroup.Nodegroup.Tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              nodegroup.Nodegroup,
+				Type:                "EKSNodeGroup",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Aliases:             []string{clusterName + "/" + name},
+				ID:                  *nodegroup.Nodegroup.NodegroupArn,
+				Ignore:              []string{"createdAt", "modifiedAt"},
+				ParentExternalID:    clusterName,
+				ParentExternalType:  v1.AWSEKSCluster,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// eksAddons scrapes the managed addons of the cluster, e.g. vpc-cni or coredns
+func (aws Scraper) eksAddons(ctx *AWSContext, config v1.AWS, client *eks.Client, clusterName string, results *v1.ScrapeResults) {
+	paginator := eks.NewListAddonsPaginator(client, &eks.ListAddonsInput{ClusterName: strPtr(clusterName)})
+	for paginator.HasMorePages() {
+		addons, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list addons of cluster %s", clusterName)
+			return
+		}
+		for _, name := range addons.Addons {
+			addon, err := client.DescribeAddon(ctx, &eks.DescribeAddonInput{
+				ClusterName: strPtr(clusterName),
+				AddonName:   strPtr(name),
+			})
+			if err != nil {
+				results.Errorf(err, "failed to describe addon %s of cluster %s", name, clusterName)
+				continue
+			}
+
+			var relationships v1.RelationshipResults
+			if addon.Addon.ServiceAccountRoleArn != nil {
+				relationships = append(relationships, roleRelationship(*addon.Addon.ServiceAccountRoleArn, v1.ExternalID{
+					ExternalID:   []string{*addon.Addon.AddonArn},
+					ExternalType: v1.AWSEKSAddon,
+				}, "IAMRoleEKSAddon"))
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEKSAddon,
+				CreatedAt:           addon.Addon.CreatedAt,
+				Tags:                addon.Addon.Tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              addon.Addon,
+				Type:                "EKSAddon",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Aliases:             []string{clusterName + "/" + name},
+				ID:                  *addon.Addon.AddonArn,
+				Ignore:              []string{"createdAt", "modifiedAt"},
+				ParentExternalID:    clusterName,
+				ParentExternalType:  v1.AWSEKSCluster,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// eksFargateProfiles scrapes the Fargate profiles of the cluster, the pods they select run in their subnets
+func (aws Scraper) eksFargateProfiles(ctx *AWSContext, config v1.AWS, client *eks.Client, clusterName string, results *v1.ScrapeResults) {
+	paginator := eks.NewListFargateProfilesPaginator(client, &eks.ListFargateProfilesInput{ClusterName: strPtr(clusterName)})
+	for paginator.HasMorePages() {
+		profiles, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list fargate profiles of cluster %s", clusterName)
+			return
+		}
+		for _, name := range profiles.FargateProfileNames {
+			profile, err := client.DescribeFargateProfile(ctx, &eks.DescribeFargateProfileInput{
+				ClusterName:        strPtr(clusterName),
+				FargateProfileName: strPtr(name),
+			})
+			if err != nil {
+				results.Errorf(err, "failed to describe fargate profile %s of cluster %s", name, clusterName)
+				continue
+			}
+
+			id := v1.ExternalID{
+				ExternalID:   []string{*profile.FargateProfile.FargateProfileArn},
+				ExternalType: v1.AWSEKSFargateProfile,
+			}
+			var relationships v1.RelationshipResults
+			if profile.FargateProfile.PodExecutionRoleArn != nil {
+				relationships = append(relationships, roleRelationship(*profile.FargateProfile.PodExecutionRoleArn, id, "IAMRoleEKSFargateProfile"))
+			}
+			for _, subnet := range profile.FargateProfile.Subnets {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{subnet}, ExternalType: v1.AWSEC2Subnet},
+					RelatedExternalID: id,
+					Relationship:      "SubnetEKSFargateProfile",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEKSFargateProfile,
+				CreatedAt:           profile.FargateProfile.CreatedAt,
+				Tags:                profile.FargateProfile.Tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              profile.FargateProfile,
+				Type:                "EKSFargateProfile",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Aliases:             []string{clusterName + "/" + name},
+				ID:                  *profile.FargateProfile.FargateProfileArn,
+				Ignore:              []string{"createdAt"},
+				ParentExternalID:    clusterName,
+				ParentExternalType:  v1.AWSEKSCluster,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/smithy-go/ptr"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) elastiCache(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("ElastiCache") {
+		return
+	}
+
+	ElastiCache := ctx.client("elasticache", func() interface{} { return elasticache.NewFromConfig(*ctx.Session) }).(*elasticache.Client)
+
+	groups := elasticache.NewDescribeReplicationGroupsPaginator(ElastiCache, &elasticache.DescribeReplicationGroupsInput{})
+	for groups.HasMorePages() {
+		output, err := groups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe elasticache replication groups")
+			return
+		}
+		for _, group := range output.ReplicationGroups {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSElastiCacheReplicationGroup,
+				CreatedAt:          group.ReplicationGroupCreateTime,
+				BaseScraper:        config.BaseScraper,
+				Config:             group,
+				Type:               "ElastiCacheReplicationGroup",
+				Name:               *group.ReplicationGroupId,
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{*group.ARN, "AmazonElastiCache/" + *group.ARN},
+				ID:                 *group.ReplicationGroupId,
+				Ignore:             []string{"replicationGroupCreateTime"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+
+	clusters := elasticache.NewDescribeCacheClustersPaginator(ElastiCache, &elasticache.DescribeCacheClustersInput{
+		ShowCacheNodeInfo: ptr.Bool(true),
+	})
+	for clusters.HasMorePages() {
+		output, err := clusters.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe elasticache clusters")
+			return
+		}
+		for _, cluster := range output.CacheClusters {
+			var relationships v1.RelationshipResults
+			for _, sg := range cluster.SecurityGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{*cluster.CacheClusterId},
+						ExternalType: v1.AWSElastiCacheCluster,
+					},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*sg.SecurityGroupId},
+						ExternalType: v1.AWSEC2SecurityGroup,
+					},
+					Relationship: "ElastiCacheSecurityGroup",
+				})
+			}
+
+			// Redis clusters belong to a replication group, memcached clusters are standalone
+			parentID, parentType := *ctx.Caller.Account, v1.AWSAccount
+			if cluster.ReplicationGroupId != nil {
+				parentID, parentType = *cluster.ReplicationGroupId, v1.AWSElastiCacheReplicationGroup
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSElastiCacheCluster,
+				CreatedAt:           cluster.CacheClusterCreateTime,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster,
+				Type:                "ElastiCache",
+				Name:                *cluster.CacheClusterId,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Zone:                deref(cluster.PreferredAvailabilityZone),
+				Aliases:             []string{*cluster.ARN, "AmazonElastiCache/" + *cluster.ARN},
+				ID:                  *cluster.CacheClusterId,
+				Ignore:              []string{"cacheClusterCreateTime", "cacheNodes"},
+				ParentExternalID:    parentID,
+				ParentExternalType:  parentType,
+				RelationshipResults: relationships,
+			})
+
+			for _, node := range cluster.CacheNodes {
+				*results = append(*results, v1.ScrapeResult{
+					ExternalType:       v1.AWSElastiCacheNode,
+					CreatedAt:          node.CacheNodeCreateTime,
+					BaseScraper:        config.BaseScraper,
+					Config:             node,
+					Type:               "ElastiCacheNode",
+					Name:               *cluster.CacheClusterId + "/" + *node.CacheNodeId,
+					Account:            *ctx.Caller.Account,
+					Region:             ctx.Session.Region,
+					Zone:               deref(node.CustomerAvailabilityZone),
+					ID:                 *cluster.CacheClusterId + "/" + *node.CacheNodeId,
+					Ignore:             []string{"cacheNodeCreateTime"},
+					ParentExternalID:   *cluster.CacheClusterId,
+					ParentExternalType: v1.AWSElastiCacheCluster,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	guarddutyTypes "github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityhubTypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// getFindingsLimit is the maximum number of GuardDuty findings that can be fetched in a single request
+const getFindingsLimit = 50
+
+// arnExternalID returns the scraped item of an ARN, items that are not identified by their ARN are identified by
+// the id or name in the resource part of the ARN
+func arnExternalID(arn string) v1.ExternalID {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return v1.ExternalID{}
+	}
+	id := func(externalType, prefix string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{strings.TrimPrefix(parts[5], prefix)}, ExternalType: externalType}
+	}
+	self := func(externalType string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: externalType}
+	}
+
+	resource := parts[5]
+	switch parts[2] {
+	case "ec2":
+		switch {
+		case strings.HasPrefix(resource, "instance/"):
+			return id(v1.AWSEC2Instance, "instance/")
+		case strings.HasPrefix(resource, "volume/"):
+			return id(v1.AWSEBSVolume, "volume/")
+		case strings.HasPrefix(resource, "security-group/"):
+			return id(v1.AWSEC2SecurityGroup, "security-group/")
+		case strings.HasPrefix(resource, "vpc/"):
+			return id(v1.AWSEC2VPC, "vpc/")
+		case strings.HasPrefix(resource, "subnet/"):
+			return id(v1.AWSEC2Subnet, "subnet/")
+		case strings.HasPrefix(resource, "snapshot/"):
+			return id(v1.AWSEBSSnapshot, "snapshot/")
+		case strings.HasPrefix(resource, "image/"):
+			return id(v1.AWSEC2AMI, "image/")
+		}
+	case "rds":
+		switch {
+		case strings.HasPrefix(resource, "db:"):
+			return id(v1.AWSRDSInstance, "db:")
+		case strings.HasPrefix(resource, "cluster:"):
+			return id(v1.AWSRDSCluster, "cluster:")
+		}
+	case "elasticfilesystem":
+		if strings.HasPrefix(resource, "file-system/") {
+			return id(v1.AWSEFSFileSystem, "file-system/")
+		}
+	case "s3":
+		if !strings.Contains(resource, "/") {
+			return id(v1.AWSS3Bucket, "")
+		}
+	case "lambda":
+		if function := getLambdaFunctionArn(arn); function != "" {
+			return v1.ExternalID{ExternalID: []string{function}, ExternalType: v1.AWSLambdaFunction}
+		}
+	case "iam":
+		switch {
+		case strings.HasPrefix(resource, "user/"):
+			return self(v1.AWSIAMUser)
+		case strings.HasPrefix(resource, "role/"):
+			return self(v1.AWSIAMRole)
+		case strings.HasPrefix(resource, "policy/"):
+			return self(v1.AWSIAMPolicy)
+		}
+	case "eks":
+		if strings.HasPrefix(resource, "cluster/") {
+			return self(v1.AWSEKSCluster)
+		}
+	case "kms":
+		if strings.HasPrefix(resource, "key/") {
+			return self(v1.AWSKMSKey)
+		}
+	case "secretsmanager":
+		return self(v1.AWSSecretsManagerSecret)
+	case "ecr":
+		return self(v1.AWSECRRepository)
+	case "sqs":
+		return self(v1.AWSSQSQueue)
+	case "sns":
+		return self(v1.AWSSNSTopic)
+	case "es":
+		return self(v1.AWSOpenSearchDomain)
+	case "redshift":
+		if strings.HasPrefix(resource, "cluster:") {
+			return self(v1.AWSRedshiftCluster)
+		}
+	}
+	return v1.ExternalID{}
+}
+
+// guardDutySeverity maps the numeric severity of a finding to its label in the GuardDuty console
+func guardDutySeverity(severity float64) string {
+	switch {
+	case severity >= 7:
+		return "high"
+	case severity >= 4:
+		return "medium"
+	}
+	return "low"
+}
+
+// guardDutyResource returns the scraped item affected by a finding, or the account when the resource is not scraped
+func guardDutyResource(finding guarddutyTypes.Finding) v1.ExternalID {
+	account := v1.ExternalID{ExternalID: []string{deref(finding.AccountId)}, ExternalType: v1.AWSAccount}
+	resource := finding.Resource
+	if resource == nil {
+		return account
+	}
+	switch {
+	case resource.InstanceDetails != nil && resource.InstanceDetails.InstanceId != nil:
+		return v1.ExternalID{ExternalID: []string{*resource.InstanceDetails.InstanceId}, ExternalType: v1.AWSEC2Instance}
+	case resource.EksClusterDetails != nil && resource.EksClusterDetails.Name != nil:
+		return v1.ExternalID{ExternalID: []string{*resource.EksClusterDetails.Name}, ExternalType: v1.AWSEKSCluster}
+	case len(resource.S3BucketDetails) > 0 && resource.S3BucketDetails[0].Name != nil:
+		return v1.ExternalID{ExternalID: []string{*resource.S3BucketDetails[0].Name}, ExternalType: v1.AWSS3Bucket}
+	case resource.AccessKeyDetails != nil && deref(resource.AccessKeyDetails.UserType) == "IAMUser":
+		return v1.ExternalID{ExternalID: []string{deref(resource.AccessKeyDetails.UserName)}, ExternalType: v1.AWSIAMUser}
+	case resource.AccessKeyDetails != nil && deref(resource.AccessKeyDetails.UserType) == "Root":
+		return v1.ExternalID{ExternalID: []string{"root"}, ExternalType: v1.AWSIAMUser}
+	}
+	return account
+}
+
+// guardDutyFindings imports the findings of the detectors of the region as analysis of the affected resources,
+// archived findings resolve the analysis they opened
+func (aws Scraper) guardDutyFindings(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.SecurityFindings || !config.Includes("GuardDuty") {
+		return
+	}
+	GuardDuty := ctx.client("guardduty", func() interface{} { return guardduty.NewFromConfig(*ctx.Session) }).(*guardduty.Client)
+	detectors := guardduty.NewListDetectorsPaginator(GuardDuty, &guardduty.ListDetectorsInput{})
+	for detectors.HasMorePages() {
+		page, err := detectors.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list guardduty detectors")
+			return
+		}
+		for _, detector := range page.DetectorIds {
+			detectorID := detector
+			findings := guardduty.NewListFindingsPaginator(GuardDuty, &guardduty.ListFindingsInput{DetectorId: &detectorID, MaxResults: getFindingsLimit})
+			for findings.HasMorePages() {
+				ids, err := findings.NextPage(ctx)
+				if err != nil {
+					results.Errorf(err, "failed to list findings of guardduty detector %s", detectorID)
+					break
+				}
+				if len(ids.FindingIds) == 0 {
+					continue
+				}
+				output, err := GuardDuty.GetFindings(ctx, &guardduty.GetFindingsInput{DetectorId: &detectorID, FindingIds: ids.FindingIds})
+				if err != nil {
+					results.Errorf(err, "failed to get findings of guardduty detector %s", detectorID)
+					break
+				}
+				for _, finding := range output.Findings {
+					status := "open"
+					if finding.Service != nil && finding.Service.Archived {
+						status = "resolved"
+					}
+					resource := guardDutyResource(finding)
+					analysis := results.Analysis("guardduty:"+deref(finding.Type), resource.ExternalType, resource.ExternalID[0])
+					analysis.AnalysisType = "security"
+					analysis.Severity = guardDutySeverity(finding.Severity)
+					analysis.Status = status
+					analysis.Summary = deref(finding.Title)
+					analysis.Message(deref(finding.Description))
+					analysis.Analysis = map[string]string{
+						"finding":  deref(finding.Arn),
+						"type":     deref(finding.Type),
+						"severity": fmt.Sprintf("%.1f", finding.Severity),
+					}
+				}
+			}
+		}
+	}
+}
+
+// securityHubFindings imports the active findings of Security Hub as analysis of the resources they are about,
+// findings from GuardDuty are skipped as they are imported from GuardDuty directly
+func (aws Scraper) securityHubFindings(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.SecurityFindings || !config.Includes("SecurityHub") {
+		return
+	}
+	SecurityHub := ctx.client("securityhub", func() interface{} { return securityhub.NewFromConfig(*ctx.Session) }).(*securityhub.Client)
+	paginator := securityhub.NewGetFindingsPaginator(SecurityHub, &securityhub.GetFindingsInput{
+		Filters: &securityhubTypes.AwsSecurityFindingFilters{
+			RecordState: []securityhubTypes.StringFilter{{Value: strPtr("ACTIVE"), Comparison: securityhubTypes.StringFilterComparisonEquals}},
+			ProductName: []securityhubTypes.StringFilter{{Value: strPtr("GuardDuty"), Comparison: securityhubTypes.StringFilterComparisonNotEquals}},
+		},
+	})
+	for paginator.HasMorePages() {
+		findings, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get security hub findings")
+			return
+		}
+		for _, finding := range findings.Findings {
+			status := "open"
+			if finding.Workflow != nil && (finding.Workflow.Status == securityhubTypes.WorkflowStatusResolved || finding.Workflow.Status == securityhubTypes.WorkflowStatusSuppressed) {
+				status = "resolved"
+			}
+			if finding.Compliance != nil && finding.Compliance.Status == securityhubTypes.ComplianceStatusPassed {
+				status = "resolved"
+			}
+			severity := "info"
+			if finding.Severity != nil && finding.Severity.Label != securityhubTypes.SeverityLabelInformational {
+				severity = strings.ToLower(string(finding.Severity.Label))
+			}
+
+			for _, resource := range finding.Resources {
+				id := arnExternalID(deref(resource.Id))
+				if deref(resource.Type) == "AwsAccount" {
+					id = v1.ExternalID{ExternalID: []string{deref(finding.AwsAccountId)}, ExternalType: v1.AWSAccount}
+				}
+				if id.ExternalType == "" {
+					continue
+				}
+				analysis := results.Analysis("securityhub:"+deref(finding.GeneratorId), id.ExternalType, id.ExternalID[0])
+				analysis.AnalysisType = "security"
+				analysis.Severity = severity
+				analysis.Status = status
+				analysis.Summary = deref(finding.Title)
+				analysis.Message(deref(finding.Description))
+				analysis.Analysis = map[string]string{
+					"finding": deref(finding.Id),
+					"product": deref(finding.ProductName),
+				}
+			}
+		}
+	}
+}
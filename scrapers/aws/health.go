@@ -0,0 +1,122 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/health"
+	healthTypes "github.com/aws/aws-sdk-go-v2/service/health/types"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// describeEventDetailsLimit is the maximum number of events that can be described in a single request
+const describeEventDetailsLimit = 10
+
+// healthEntity returns the scraped item of an entity affected by a health event, entities are identified either
+// by their ARN or by their id e.g. an instance or volume id
+func healthEntity(entity healthTypes.AffectedEntity) v1.ExternalID {
+	value := deref(entity.EntityValue)
+	if strings.HasPrefix(value, "arn:") {
+		return arnExternalID(value)
+	}
+	if externalType := getExternalTypeById(value); externalType != "" {
+		return v1.ExternalID{ExternalID: []string{value}, ExternalType: externalType}
+	}
+	if entity.EntityArn != nil {
+		return arnExternalID(*entity.EntityArn)
+	}
+	return v1.ExternalID{}
+}
+
+// healthEvents records the open and upcoming scheduled changes of the region e.g. maintenance and retirements as
+// changes of the affected resources, the Health API requires a Business or Enterprise support plan
+func (aws Scraper) healthEvents(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Health") {
+		return
+	}
+	// the Health API is only served from us-east-1
+	Health := ctx.client("health", func() interface{} {
+		session := ctx.Session.Copy()
+		session.Region = globalRegion
+		return health.NewFromConfig(session)
+	}).(*health.Client)
+
+	var events []healthTypes.Event
+	paginator := health.NewDescribeEventsPaginator(Health, &health.DescribeEventsInput{
+		Filter: &healthTypes.EventFilter{
+			Regions:             []string{ctx.Session.Region},
+			EventTypeCategories: []healthTypes.EventTypeCategory{healthTypes.EventTypeCategoryScheduledChange},
+			EventStatusCodes:    []healthTypes.EventStatusCode{healthTypes.EventStatusCodeOpen, healthTypes.EventStatusCodeUpcoming},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if isErrorCode(err, "SubscriptionRequiredException") {
+			logger.Debugf("skipping health events of %s: the account does not have a business support plan", *ctx.Caller.Account)
+			return
+		}
+		if err != nil {
+			results.Errorf(err, "failed to describe health events")
+			return
+		}
+		events = append(events, page.Events...)
+	}
+
+	descriptions := make(map[string]string)
+	for start := 0; start < len(events); start += describeEventDetailsLimit {
+		end := start + describeEventDetailsLimit
+		if end > len(events) {
+			end = len(events)
+		}
+		var arns []string
+		for _, event := range events[start:end] {
+			arns = append(arns, *event.Arn)
+		}
+		details, err := Health.DescribeEventDetails(ctx, &health.DescribeEventDetailsInput{EventArns: arns})
+		if err != nil {
+			results.Errorf(err, "failed to describe health event details")
+			continue
+		}
+		for _, detail := range details.SuccessfulSet {
+			if detail.Event != nil && detail.EventDescription != nil {
+				descriptions[*detail.Event.Arn] = deref(detail.EventDescription.LatestDescription)
+			}
+		}
+	}
+
+	for _, event := range events {
+		entities := health.NewDescribeAffectedEntitiesPaginator(Health, &health.DescribeAffectedEntitiesInput{
+			Filter: &healthTypes.EntityFilter{EventArns: []string{*event.Arn}},
+		})
+		for entities.HasMorePages() {
+			page, err := entities.NextPage(ctx)
+			if err != nil {
+				results.Errorf(err, "failed to describe entities affected by health event %s", *event.Arn)
+				break
+			}
+			for _, entity := range page.Entities {
+				resource := healthEntity(entity)
+				if resource.ExternalType == "" {
+					continue
+				}
+				results.AddChange(v1.ChangeResult{
+					ExternalID:       resource.ExternalID[0],
+					ExternalType:     resource.ExternalType,
+					ExternalChangeID: *event.Arn + "/" + deref(entity.EntityValue),
+					ChangeType:       deref(event.EventTypeCode),
+					Summary:          descriptions[*event.Arn],
+					Severity:         "warning",
+					Source:           "AWS::Health",
+					CreatedAt:        event.LastUpdatedTime,
+					Details: map[string]interface{}{
+						"service":   deref(event.Service),
+						"status":    string(event.StatusCode),
+						"entity":    string(entity.StatusCode),
+						"startTime": event.StartTime,
+						"endTime":   event.EndTime,
+					},
+				})
+			}
+		}
+	}
+}
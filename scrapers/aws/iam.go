@@ -0,0 +1,434 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// IAMPolicy is an inline or managed policy with its document decoded
+type IAMPolicy struct {
+	PolicyName string         `json:"PolicyName"`
+	PolicyArn  string         `json:"PolicyArn,omitempty"`
+	Document   PolicyDocument `json:"Document,omitempty"`
+}
+
+// IAMUser is a user with all its inline and attached policies
+type IAMUser struct {
+	Arn                 string      `json:"Arn"`
+	UserId              string      `json:"UserId"`
+	UserName            string      `json:"UserName"`
+	Path                string      `json:"Path,omitempty"`
+	CreateDate          *time.Time  `json:"CreateDate,omitempty"`
+	Groups              []string    `json:"Groups,omitempty"`
+	InlinePolicies      []IAMPolicy `json:"InlinePolicies,omitempty"`
+	AttachedPolicies    []IAMPolicy `json:"AttachedPolicies,omitempty"`
+	PermissionsBoundary string      `json:"PermissionsBoundary,omitempty"`
+}
+
+// IAMGroup is a group with all its inline and attached policies
+type IAMGroup struct {
+	Arn              string      `json:"Arn"`
+	GroupId          string      `json:"GroupId"`
+	GroupName        string      `json:"GroupName"`
+	Path             string      `json:"Path,omitempty"`
+	CreateDate       *time.Time  `json:"CreateDate,omitempty"`
+	InlinePolicies   []IAMPolicy `json:"InlinePolicies,omitempty"`
+	AttachedPolicies []IAMPolicy `json:"AttachedPolicies,omitempty"`
+}
+
+// IAMRole is a role with its trust policy and all its inline and attached policies
+type IAMRole struct {
+	Arn                      string                 `json:"Arn"`
+	RoleId                   string                 `json:"RoleId"`
+	RoleName                 string                 `json:"RoleName"`
+	Path                     string                 `json:"Path,omitempty"`
+	CreateDate               *time.Time             `json:"CreateDate,omitempty"`
+	AssumeRolePolicyDocument PolicyDocument         `json:"AssumeRolePolicyDocument,omitempty"`
+	InlinePolicies           []IAMPolicy            `json:"InlinePolicies,omitempty"`
+	AttachedPolicies         []IAMPolicy            `json:"AttachedPolicies,omitempty"`
+	InstanceProfiles         []string               `json:"InstanceProfiles,omitempty"`
+	PermissionsBoundary      string                 `json:"PermissionsBoundary,omitempty"`
+	RoleLastUsed             *iamTypes.RoleLastUsed `json:"RoleLastUsed,omitempty"`
+}
+
+// IAMManagedPolicy is a customer managed policy with the document of its default version
+type IAMManagedPolicy struct {
+	Arn              string         `json:"Arn"`
+	PolicyId         string         `json:"PolicyId"`
+	PolicyName       string         `json:"PolicyName"`
+	Path             string         `json:"Path,omitempty"`
+	Description      string         `json:"Description,omitempty"`
+	DefaultVersionId string         `json:"DefaultVersionId,omitempty"`
+	AttachmentCount  int32          `json:"AttachmentCount"`
+	CreateDate       *time.Time     `json:"CreateDate,omitempty"`
+	UpdateDate       *time.Time     `json:"UpdateDate,omitempty"`
+	Document         PolicyDocument `json:"Document,omitempty"`
+}
+
+// PolicyDocument is a decoded IAM policy document
+type PolicyDocument map[string]interface{}
+
+// Statements returns the statements of the policy, NormalizePolicyDocument guarantees this is always a list
+func (p PolicyDocument) Statements() []map[string]interface{} {
+	var statements []map[string]interface{}
+	list, _ := p["Statement"].([]interface{})
+	for _, s := range list {
+		if statement, ok := s.(map[string]interface{}); ok {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// policyListKeys are statement keys that AWS accepts as either a string or a list
+var policyListKeys = []string{"Action", "NotAction", "Resource", "NotResource"}
+
+// NormalizePolicyDocument decodes a URL encoded policy document as returned by the IAM API and
+// normalizes it so that equivalent policies produce identical config:
+// Statement is always a list and Action/Resource are always sorted lists
+func NormalizePolicyDocument(document *string) PolicyDocument {
+	if document == nil || *document == "" {
+		return nil
+	}
+	raw, err := url.QueryUnescape(*document)
+	if err != nil {
+		raw = *document
+	}
+	return parsePolicyDocument(raw)
+}
+
+// parsePolicyDocument parses and normalizes a policy document that is not URL encoded
+func parsePolicyDocument(raw string) PolicyDocument {
+	var doc PolicyDocument
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return PolicyDocument{"raw": raw}
+	}
+
+	statements, ok := doc["Statement"].([]interface{})
+	if !ok && doc["Statement"] != nil {
+		statements = []interface{}{doc["Statement"]}
+	}
+	for _, s := range statements {
+		statement, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range policyListKeys {
+			switch v := statement[key].(type) {
+			case string:
+				statement[key] = []interface{}{v}
+			case []interface{}:
+				sort.Slice(v, func(i, j int) bool {
+					a, _ := v[i].(string)
+					b, _ := v[j].(string)
+					return a < b
+				})
+			}
+		}
+	}
+	if statements != nil {
+		doc["Statement"] = statements
+	}
+	return doc
+}
+
+func inlinePolicies(policies []iamTypes.PolicyDetail) []IAMPolicy {
+	var out []IAMPolicy
+	for _, p := range policies {
+		out = append(out, IAMPolicy{
+			PolicyName: deref(p.PolicyName),
+			Document:   NormalizePolicyDocument(p.PolicyDocument),
+		})
+	}
+	return out
+}
+
+func attachedPolicies(policies []iamTypes.AttachedPolicy, managed map[string]PolicyDocument) []IAMPolicy {
+	var out []IAMPolicy
+	for _, p := range policies {
+		out = append(out, IAMPolicy{
+			PolicyName: deref(p.PolicyName),
+			PolicyArn:  deref(p.PolicyArn),
+			Document:   managed[deref(p.PolicyArn)],
+		})
+	}
+	return out
+}
+
+// isAWSManagedPolicy returns true for policies owned by AWS, these are not scraped
+func isAWSManagedPolicy(arn string) bool {
+	return strings.HasPrefix(arn, "arn:aws:iam::aws:policy/")
+}
+
+func policyAttachments(self v1.ExternalID, policies []iamTypes.AttachedPolicy) v1.RelationshipResults {
+	var relationships v1.RelationshipResults
+	for _, p := range policies {
+		if isAWSManagedPolicy(deref(p.PolicyArn)) {
+			continue
+		}
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID: self,
+			RelatedExternalID: v1.ExternalID{
+				ExternalID:   []string{deref(p.PolicyArn)},
+				ExternalType: v1.AWSIAMPolicy,
+			},
+			Relationship: "IAMPolicyAttachment",
+		})
+	}
+	return relationships
+}
+
+// iam scrapes users, groups, roles and customer managed policies using a single
+// GetAccountAuthorizationDetails call which returns the entities together with their policies
+func (aws Scraper) iam(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	var filter []iamTypes.EntityType
+	if config.Includes("User") {
+		filter = append(filter, iamTypes.EntityTypeUser)
+	}
+	if config.Includes("Groups") {
+		filter = append(filter, iamTypes.EntityTypeGroup)
+	}
+	if config.Includes("Roles") {
+		filter = append(filter, iamTypes.EntityTypeRole)
+	}
+	if config.Includes("Policies") {
+		filter = append(filter, iamTypes.EntityTypeLocalManagedPolicy)
+	}
+	if len(filter) == 0 {
+		return
+	}
+
+	var (
+		users    []iamTypes.UserDetail
+		groups   []iamTypes.GroupDetail
+		roles    []iamTypes.RoleDetail
+		policies []iamTypes.ManagedPolicyDetail
+	)
+	paginator := iam.NewGetAccountAuthorizationDetailsPaginator(ctx.IAM, &iam.GetAccountAuthorizationDetailsInput{Filter: filter})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get account authorization details")
+			return
+		}
+		users = append(users, output.UserDetailList...)
+		groups = append(groups, output.GroupDetailList...)
+		roles = append(roles, output.RoleDetailList...)
+		policies = append(policies, output.Policies...)
+	}
+
+	managed := make(map[string]PolicyDocument)
+	for _, policy := range policies {
+		var document PolicyDocument
+		for _, version := range policy.PolicyVersionList {
+			if version.IsDefaultVersion {
+				document = NormalizePolicyDocument(version.Document)
+			}
+		}
+		managed[*policy.Arn] = document
+
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType: v1.AWSIAMPolicy,
+			CreatedAt:    policy.CreateDate,
+			BaseScraper:  config.BaseScraper,
+			Config: IAMManagedPolicy{
+				Arn:              *policy.Arn,
+				PolicyId:         deref(policy.PolicyId),
+				PolicyName:       deref(policy.PolicyName),
+				Path:             deref(policy.Path),
+				Description:      deref(policy.Description),
+				DefaultVersionId: deref(policy.DefaultVersionId),
+				AttachmentCount:  derefInt32(policy.AttachmentCount),
+				CreateDate:       policy.CreateDate,
+				UpdateDate:       policy.UpdateDate,
+				Document:         document,
+			},
+			Type:    "Policy",
+			Name:    deref(policy.PolicyName),
+			Account: *ctx.Caller.Account,
+			Aliases: []string{*policy.Arn, deref(policy.PolicyName)},
+			ID:      deref(policy.PolicyId),
+			Ignore:  []string{"AttachmentCount", "CreateDate", "UpdateDate"},
+		})
+	}
+
+	for _, group := range groups {
+		self := v1.ExternalID{ExternalID: []string{*group.GroupName}, ExternalType: v1.AWSIAMGroup}
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType: v1.AWSIAMGroup,
+			CreatedAt:    group.CreateDate,
+			BaseScraper:  config.BaseScraper,
+			Config: IAMGroup{
+				Arn:              deref(group.Arn),
+				GroupId:          deref(group.GroupId),
+				GroupName:        *group.GroupName,
+				Path:             deref(group.Path),
+				CreateDate:       group.CreateDate,
+				InlinePolicies:   inlinePolicies(group.GroupPolicyList),
+				AttachedPolicies: attachedPolicies(group.AttachedManagedPolicies, managed),
+			},
+			Type:                "Group",
+			Name:                *group.GroupName,
+			Account:             *ctx.Caller.Account,
+			Aliases:             []string{deref(group.GroupId), deref(group.Arn)},
+			ID:                  *group.GroupName,
+			Ignore:              []string{"CreateDate"},
+			RelationshipResults: policyAttachments(self, group.AttachedManagedPolicies),
+		})
+	}
+
+	for _, user := range users {
+		self := v1.ExternalID{ExternalID: []string{*user.UserName}, ExternalType: v1.AWSIAMUser}
+		relationships := policyAttachments(self, user.AttachedManagedPolicies)
+		for _, group := range user.GroupList {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID: self,
+				RelatedExternalID: v1.ExternalID{
+					ExternalID:   []string{group},
+					ExternalType: v1.AWSIAMGroup,
+				},
+				Relationship: "IAMUserGroup",
+			})
+		}
+
+		var boundary string
+		if user.PermissionsBoundary != nil {
+			boundary = deref(user.PermissionsBoundary.PermissionsBoundaryArn)
+		}
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType: v1.AWSIAMUser,
+			CreatedAt:    user.CreateDate,
+			BaseScraper:  config.BaseScraper,
+			Config: IAMUser{
+				Arn:                 deref(user.Arn),
+				UserId:              deref(user.UserId),
+				UserName:            *user.UserName,
+				Path:                deref(user.Path),
+				CreateDate:          user.CreateDate,
+				Groups:              user.GroupList,
+				InlinePolicies:      inlinePolicies(user.UserPolicyList),
+				AttachedPolicies:    attachedPolicies(user.AttachedManagedPolicies, managed),
+				PermissionsBoundary: boundary,
+			},
+			Type:                "User",
+			Name:                *user.UserName,
+			Account:             *ctx.Caller.Account,
+			Tags:                getIAMTags(user.Tags),
+			Aliases:             []string{deref(user.UserId), deref(user.Arn)},
+			Ignore:              []string{"Arn", "UserId", "CreateDate", "UserName"},
+			ID:                  *user.UserName, // UserId is not often referenced
+			RelationshipResults: relationships,
+		})
+	}
+
+	for _, role := range roles {
+		self := v1.ExternalID{ExternalID: []string{*role.RoleId}, ExternalType: v1.AWSIAMRole}
+		relationships := policyAttachments(self, role.AttachedManagedPolicies)
+
+		var profiles []string
+		for _, profile := range role.InstanceProfileList {
+			profiles = append(profiles, deref(profile.InstanceProfileName))
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID: self,
+				RelatedExternalID: v1.ExternalID{
+					ExternalID:   []string{deref(profile.InstanceProfileId)},
+					ExternalType: v1.AWSIAMInstanceProfile,
+				},
+				Relationship: "IAMRoleInstanceProfile",
+			})
+		}
+
+		var boundary string
+		if role.PermissionsBoundary != nil {
+			boundary = deref(role.PermissionsBoundary.PermissionsBoundaryArn)
+		}
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType: v1.AWSIAMRole,
+			CreatedAt:    role.CreateDate,
+			BaseScraper:  config.BaseScraper,
+			Config: IAMRole{
+				Arn:                      deref(role.Arn),
+				RoleId:                   *role.RoleId,
+				RoleName:                 deref(role.RoleName),
+				Path:                     deref(role.Path),
+				CreateDate:               role.CreateDate,
+				AssumeRolePolicyDocument: NormalizePolicyDocument(role.AssumeRolePolicyDocument),
+				InlinePolicies:           inlinePolicies(role.RolePolicyList),
+				AttachedPolicies:         attachedPolicies(role.AttachedManagedPolicies, managed),
+				InstanceProfiles:         profiles,
+				PermissionsBoundary:      boundary,
+				RoleLastUsed:             role.RoleLastUsed,
+			},
+			Type:                "Role",
+			Name:                deref(role.RoleName),
+			Account:             *ctx.Caller.Account,
+			Tags:                getIAMTags(role.Tags),
+			Aliases:             []string{deref(role.RoleName), deref(role.Arn)},
+			ID:                  *role.RoleId,
+			Ignore:              []string{"CreateDate", "RoleLastUsed"},
+			RelationshipResults: relationships,
+		})
+	}
+}
+
+func (aws Scraper) iamProfiles(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Profiles") {
+		return
+	}
+	paginator := iam.NewListInstanceProfilesPaginator(ctx.IAM, &iam.ListInstanceProfilesInput{})
+	for paginator.HasMorePages() {
+		profiles, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get profiles")
+			return
+		}
+
+		for _, profile := range profiles.InstanceProfiles {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType: v1.AWSIAMInstanceProfile,
+				CreatedAt:    profile.CreateDate,
+				BaseScraper:  config.BaseScraper,
+				Config:       profile,
+				Type:         "Profile",
+				Name:         *profile.InstanceProfileName,
+				Account:      *ctx.Caller.Account,
+				Aliases:      []string{*profile.InstanceProfileName, *profile.Arn},
+				ID:           *profile.InstanceProfileId,
+			})
+		}
+	}
+}
+
+// roleRelationship links an IAM role (by ARN) to a resource that assumes it
+func roleRelationship(roleArn string, related v1.ExternalID, relationship string) v1.RelationshipResult {
+	return v1.RelationshipResult{
+		ConfigExternalID: v1.ExternalID{
+			ExternalID:   []string{roleArn},
+			ExternalType: v1.AWSIAMRole,
+		},
+		RelatedExternalID: related,
+		Relationship:      relationship,
+	}
+}
+
+func getIAMTags(tags []iamTypes.Tag) v1.JSONStringMap {
+	result := make(v1.JSONStringMap)
+	for _, tag := range tags {
+		result[*tag.Key] = deref(tag.Value)
+	}
+	return result
+}
+
+func derefInt32(i *int32) int32 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
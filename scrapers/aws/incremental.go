@@ -0,0 +1,332 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// globalRegion is the region that global services are scraped from
+const globalRegion = "us-east-1"
+
+// anyAccount matches changes of resources whose ARN has no account
+const anyAccount = "*"
+
+// maxIncrementalBatches limits the number of receives from the queue in a single scrape
+const maxIncrementalBatches = 100
+
+// incrementalWaitSeconds is how long a receive waits for events when the queue is empty
+const incrementalWaitSeconds = 10
+
+// LastFullScrape records when the last full scrape of an incremental queue started
+var LastFullScrape = sync.Map{}
+
+// serviceTasks maps the service of an event to the scrape tasks that need to be re-run
+var serviceTasks = map[string][]string{
+	"ec2":                    {"instances", "vpcs", "securityGroups", "routes", "dhcp", "internetGateways", "natGateways", "vpcPeering", "transitGateways", "ebs", "launchTemplates", "ebsSnapshots", "ami"},
+	"autoscaling":            {"autoScalingGroups"},
+	"eks":                    {"eksClusters"},
+	"elasticfilesystem":      {"efs"},
+	"efs":                    {"efs"},
+	"fsx":                    {"fsx"},
+	"rds":                    {"rds", "rdsClusters", "rdsSnapshots", "rdsGroups"},
+	"elasticache":            {"elastiCache"},
+	"es":                     {"openSearchDomains"},
+	"opensearch":             {"openSearchDomains"},
+	"redshift":               {"redshiftClusters"},
+	"elasticmapreduce":       {"emrClusters"},
+	"glue":                   {"glue"},
+	"kinesis":                {"kinesisStreams"},
+	"firehose":               {"firehoses"},
+	"kafka":                  {"mskClusters"},
+	"states":                 {"stepFunctions"},
+	"events":                 {"eventBridgeRules"},
+	"sqs":                    {"sqs"},
+	"sns":                    {"sns"},
+	"lambda":                 {"lambdaFunctions"},
+	"apigateway":             {"apiGateways"},
+	"apigatewayv2":           {"apiGateways"},
+	"elasticloadbalancing":   {"loadBalancers"},
+	"elasticloadbalancingv2": {"loadBalancers"},
+	"ecr":                    {"containerImages"},
+	"iam":                    {"iam", "iamProfiles", "serverCertificates"},
+	"acm":                    {"acmCertificates"},
+	"kms":                    {"kmsKeys"},
+	"secretsmanager":         {"secrets"},
+	"ssm":                    {"ssmParameters"},
+	"route53":                {"dnsZones"},
+	"cloudfront":             {"cloudfront"},
+	"cloudwatch":             {"cloudwatchAlarms"},
+	"monitoring":             {"cloudwatchAlarms"},
+	"s3":                     {"s3Buckets"},
+	"backup":                 {"backups"},
+	"health":                 {"healthEvents"},
+}
+
+// resourceTypeTasks maps the type of the resource of an AWS Config change to the scrape tasks of the resource, the
+// changes of other types re-run the tasks of their service
+var resourceTypeTasks = map[string][]string{
+	"AWS::EC2::Instance":             {"instances"},
+	"AWS::EC2::VPC":                  {"vpcs"},
+	"AWS::EC2::Subnet":               {"vpcs"},
+	"AWS::EC2::SecurityGroup":        {"securityGroups"},
+	"AWS::EC2::RouteTable":           {"routes"},
+	"AWS::EC2::DHCPOptions":          {"dhcp"},
+	"AWS::EC2::InternetGateway":      {"internetGateways"},
+	"AWS::EC2::NatGateway":           {"natGateways"},
+	"AWS::EC2::VPCPeeringConnection": {"vpcPeering"},
+	"AWS::EC2::TransitGateway":       {"transitGateways"},
+	"AWS::EC2::Volume":               {"ebs"},
+	"AWS::EC2::LaunchTemplate":       {"launchTemplates"},
+	"AWS::RDS::DBInstance":           {"rds"},
+	"AWS::RDS::DBCluster":            {"rdsClusters"},
+	"AWS::RDS::DBSnapshot":           {"rdsSnapshots"},
+	"AWS::RDS::DBClusterSnapshot":    {"rdsSnapshots"},
+	"AWS::RDS::DBSubnetGroup":        {"rdsGroups"},
+	"AWS::IAM::Role":                 {"iam"},
+	"AWS::IAM::User":                 {"iam"},
+	"AWS::IAM::Group":                {"iam"},
+	"AWS::IAM::Policy":               {"iam"},
+}
+
+// changedServices records the scrape tasks affected by events by account and region
+type changedServices map[string]map[string]map[string]bool
+
+func (c changedServices) add(account, region, service string) {
+	c.addTasks(account, region, serviceTasks[strings.ToLower(service)])
+}
+
+func (c changedServices) addTasks(account, region string, tasks []string) {
+	if account == "" {
+		return
+	}
+	for _, task := range tasks {
+		taskRegion := region
+		if _, global := (Scraper{}).globalScrapers()[task]; global {
+			taskRegion = globalRegion
+		}
+		if c[account] == nil {
+			c[account] = make(map[string]map[string]bool)
+		}
+		if c[account][taskRegion] == nil {
+			c[account][taskRegion] = make(map[string]bool)
+		}
+		c[account][taskRegion][task] = true
+	}
+}
+
+// hasAccount returns whether the account has any changes
+func (c changedServices) hasAccount(account string) bool {
+	return len(c[account]) > 0 || len(c[anyAccount]) > 0
+}
+
+// hasRegion returns whether the region of the account has any changes
+func (c changedServices) hasRegion(account, region string) bool {
+	return len(c[account][region]) > 0 || len(c[anyAccount][region]) > 0
+}
+
+// filter returns whether a task of the region needs to be scraped, a nil set accepts every task
+func (c changedServices) filter(account, region string) func(string) bool {
+	return func(task string) bool {
+		if c == nil {
+			return true
+		}
+		return c[account][region][task] || c[anyAccount][region][task]
+	}
+}
+
+// queueEvent is either a CloudTrail event delivered by EventBridge, an AWS Config change
+// delivered by EventBridge, an AWS Health event delivered by EventBridge, or an AWS Config
+// notification delivered through SNS
+type queueEvent struct {
+	Source  string `json:"source"`
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Detail  struct {
+		EventSource       string             `json:"eventSource"`
+		ConfigurationItem *configurationItem `json:"configurationItem"`
+	} `json:"detail"`
+
+	// SNS envelope
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+
+	ConfigurationItem *configurationItem `json:"configurationItem"`
+}
+
+type configurationItem struct {
+	AccountID    string `json:"awsAccountId"`
+	Region       string `json:"awsRegion"`
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceId"`
+	Status       string `json:"configurationItemStatus"`
+	CaptureTime  string `json:"configurationItemCaptureTime"`
+}
+
+// service returns the service of an AWS::<Service>::<Type> resource type
+func (item configurationItem) service() string {
+	parts := strings.Split(item.ResourceType, "::")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// incrementalChanges returns the services changed since the last scrape, or nil when a full scrape is due
+func (aws Scraper) incrementalChanges(ctx *v1.ScrapeContext, awsConfig v1.AWS, results *v1.ScrapeResults) changedServices {
+	client, err := aws.incrementalQueue(ctx, awsConfig)
+	if err != nil {
+		results.Errorf(err, "failed to create incremental queue client, running a full scrape")
+		return nil
+	}
+
+	changed := make(changedServices)
+	full := true
+	if last, ok := LastFullScrape.Load(awsConfig.Incremental.QueueURL); ok {
+		full = time.Since(last.(time.Time)) > awsConfig.Incremental.GetFullScrapeInterval()
+	}
+	if full {
+		LastFullScrape.Store(awsConfig.Incremental.QueueURL, time.Now())
+	}
+
+	// events received before a full scrape are consumed as the full scrape picks up their changes, the events are
+	// deleted once the results are saved, so that they are received again when the scrape fails
+	var messages []sqsTypes.Message
+	for i := 0; i < maxIncrementalBatches; i++ {
+		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            &awsConfig.Incremental.QueueURL,
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     incrementalWaitSeconds,
+		})
+		if err != nil {
+			results.Errorf(err, "failed to receive incremental events")
+			return nil
+		}
+		if len(output.Messages) == 0 {
+			break
+		}
+		for _, message := range output.Messages {
+			if !full {
+				processQueueEvent(deref(message.Body), changed, results)
+			}
+			messages = append(messages, message)
+		}
+	}
+	count := len(messages)
+	// dry runs leave the events in the queue
+	if ctx.AfterSave != nil && !ctx.DryRun && count > 0 {
+		queueURL := awsConfig.Incremental.QueueURL
+		ctx.AfterSave.Add(func() { deleteMessages(client, queueURL, messages) })
+	}
+
+	if full {
+		logger.Infof("Running full scrape, consumed %d incremental events", count)
+		return nil
+	}
+	logger.Infof("Processed %d incremental events", count)
+	return changed
+}
+
+// deleteMessages deletes the received messages from the queue in batches of 10, the entries are identified by the
+// id of their message
+func deleteMessages(client *sqs.Client, queueURL string, messages []sqsTypes.Message) {
+	for start := 0; start < len(messages); start += 10 {
+		end := start + 10
+		if end > len(messages) {
+			end = len(messages)
+		}
+		var entries []sqsTypes.DeleteMessageBatchRequestEntry
+		for _, message := range messages[start:end] {
+			entries = append(entries, sqsTypes.DeleteMessageBatchRequestEntry{
+				Id:            message.MessageId,
+				ReceiptHandle: message.ReceiptHandle,
+			})
+		}
+		output, err := client.DeleteMessageBatch(context.Background(), &sqs.DeleteMessageBatchInput{
+			QueueUrl: &queueURL,
+			Entries:  entries,
+		})
+		if err != nil {
+			logger.Errorf("failed to delete %d incremental events: %v", len(entries), err)
+			continue
+		}
+		for _, failed := range output.Failed {
+			logger.Errorf("failed to delete incremental event %s: %s", deref(failed.Id), deref(failed.Message))
+		}
+	}
+}
+
+func processQueueEvent(body string, changed changedServices, results *v1.ScrapeResults) {
+	var event queueEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		logger.Warnf("Ignoring invalid incremental event: %v", err)
+		return
+	}
+	if event.Type == "Notification" && event.Message != "" {
+		processQueueEvent(event.Message, changed, results)
+		return
+	}
+
+	item := event.ConfigurationItem
+	if item == nil {
+		item = event.Detail.ConfigurationItem
+	}
+	if item != nil {
+		if item.Status == "ResourceDeleted" || item.Status == "ResourceDeletedNotRecorded" {
+			change := v1.ChangeResult{
+				ExternalID:   item.ResourceID,
+				ExternalType: item.ResourceType,
+				Action:       v1.Delete,
+				ChangeType:   "Delete",
+				Source:       fmt.Sprintf("AWS::Config::%s:%s", item.Region, item.AccountID),
+			}
+			if t, err := time.Parse(time.RFC3339, item.CaptureTime); err == nil {
+				change.CreatedAt = &t
+			}
+			results.AddChange(change)
+			return
+		}
+		if tasks, ok := resourceTypeTasks[item.ResourceType]; ok {
+			changed.addTasks(item.AccountID, item.Region, tasks)
+		} else {
+			changed.add(item.AccountID, item.Region, item.service())
+		}
+		return
+	}
+
+	if event.Detail.EventSource != "" {
+		changed.add(event.Account, event.Region, strings.TrimSuffix(event.Detail.EventSource, ".amazonaws.com"))
+	} else if event.Source == "aws.health" {
+		changed.add(event.Account, event.Region, "health")
+	}
+}
+
+func (aws Scraper) incrementalQueue(ctx *v1.ScrapeContext, awsConfig v1.AWS) (*sqs.Client, error) {
+	session, err := NewSession(ctx, *awsConfig.AWSConnection, queueRegion(awsConfig.Incremental.QueueURL))
+	if err != nil {
+		return nil, err
+	}
+	return sqs.NewFromConfig(*session), nil
+}
+
+// queueRegion returns the region of a queue url of the form https://sqs.<region>.amazonaws.com/<account>/<name>
+func queueRegion(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return globalRegion
+	}
+	parts := strings.Split(u.Host, ".")
+	if len(parts) < 3 || parts[0] != "sqs" {
+		return globalRegion
+	}
+	return parts[1]
+}
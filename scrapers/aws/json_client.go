@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// jsonClient calls the operations of a service using the AWS JSON protocol, it is used for the services whose SDK
+// client is not a dependency of config-db. Requests are signed with the credentials of the session and throttled
+// or failed requests are retried up to the max attempts of the session
+type jsonClient struct {
+	cfg aws.Config
+	// endpointPrefix is the host prefix of the service endpoints e.g. memory-db
+	endpointPrefix string
+	// signingName is the name of the service in request signatures e.g. memorydb
+	signingName string
+	// targetPrefix is the prefix of the X-Amz-Target header e.g. AmazonMemoryDB
+	targetPrefix string
+	// contentType is either application/x-amz-json-1.0 or application/x-amz-json-1.1
+	contentType string
+}
+
+// jsonError is the body of the responses of failed requests
+type jsonError struct {
+	Type         string `json:"__type"`
+	Message      string `json:"message"`
+	MessageUpper string `json:"Message"`
+}
+
+func (e jsonError) Error() string {
+	code := e.Type[strings.LastIndex(e.Type, "#")+1:]
+	message := e.Message
+	if message == "" {
+		message = e.MessageUpper
+	}
+	return fmt.Sprintf("%s: %s", code, message)
+}
+
+func (e jsonError) throttled() bool {
+	return strings.Contains(e.Type, "Throttling") || strings.Contains(e.Type, "LimitExceeded")
+}
+
+// endpoint returns the url of the service in the region of the session, or the custom endpoint of the connection
+func (c *jsonClient) endpoint() (string, error) {
+	if c.cfg.EndpointResolverWithOptions != nil {
+		endpoint, err := c.cfg.EndpointResolverWithOptions.ResolveEndpoint(c.endpointPrefix, c.cfg.Region)
+		if err == nil {
+			return endpoint.URL, nil
+		}
+		if _, notFound := err.(*aws.EndpointNotFoundError); !notFound {
+			return "", err
+		}
+	}
+	suffix := "amazonaws.com"
+	if strings.HasPrefix(c.cfg.Region, "cn-") {
+		suffix = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://%s.%s.%s", c.endpointPrefix, c.cfg.Region, suffix), nil
+}
+
+// call sends the input of the operation and decodes its response into output
+func (c *jsonClient) call(ctx context.Context, operation string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	attempts := c.cfg.RetryMaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxRetries
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		retry, err := c.send(ctx, operation, body, output)
+		if err == nil || !retry || attempt >= attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 20*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// send signs and sends a request, failed requests are retried when they were throttled or failed on the server
+func (c *jsonClient) send(ctx context.Context, operation string, body []byte, output interface{}) (bool, error) {
+	url, err := c.endpoint()
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", c.contentType)
+	req.Header.Set("X-Amz-Target", c.targetPrefix+"."+operation)
+
+	credentials, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve credentials: %v", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), c.signingName, c.cfg.Region, time.Now()); err != nil {
+		return false, err
+	}
+
+	var client aws.HTTPClient = http.DefaultClient
+	if c.cfg.HTTPClient != nil {
+		client = c.cfg.HTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	if resp.StatusCode >= 300 {
+		var failure jsonError
+		if json.Unmarshal(data, &failure) != nil || failure.Type == "" {
+			return resp.StatusCode >= 500, fmt.Errorf("%s %s: %s", operation, resp.Status, data)
+		}
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || failure.throttled()
+		return retry, fmt.Errorf("%s: %w", operation, failure)
+	}
+	return false, json.Unmarshal(data, output)
+}
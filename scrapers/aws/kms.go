@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// KMSKey is a key together with its aliases, rotation status and decoded key policy
+type KMSKey struct {
+	KeyId              string         `json:"KeyId"`
+	Arn                string         `json:"Arn"`
+	CreationDate       *time.Time     `json:"CreationDate,omitempty"`
+	Description        string         `json:"Description,omitempty"`
+	Enabled            bool           `json:"Enabled"`
+	KeyManager         string         `json:"KeyManager,omitempty"`
+	KeyState           string         `json:"KeyState,omitempty"`
+	KeyUsage           string         `json:"KeyUsage,omitempty"`
+	KeySpec            string         `json:"KeySpec,omitempty"`
+	Origin             string         `json:"Origin,omitempty"`
+	MultiRegion        bool           `json:"MultiRegion,omitempty"`
+	DeletionDate       *time.Time     `json:"DeletionDate,omitempty"`
+	KeyRotationEnabled bool           `json:"KeyRotationEnabled"`
+	Aliases            []string       `json:"Aliases,omitempty"`
+	Policy             PolicyDocument `json:"Policy,omitempty"`
+}
+
+func (aws Scraper) kmsKeys(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("KMS") {
+		return
+	}
+	KMS := ctx.client("kms", func() interface{} { return kms.NewFromConfig(*ctx.Session) }).(*kms.Client)
+
+	aliases := make(map[string][]string)
+	aliasPaginator := kms.NewListAliasesPaginator(KMS, &kms.ListAliasesInput{})
+	for aliasPaginator.HasMorePages() {
+		page, err := aliasPaginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list kms aliases")
+			return
+		}
+		for _, alias := range page.Aliases {
+			// aliases reserved for keys that are not created yet have no target
+			if alias.TargetKeyId == nil {
+				continue
+			}
+			aliases[*alias.TargetKeyId] = append(aliases[*alias.TargetKeyId], *alias.AliasName)
+		}
+	}
+
+	paginator := kms.NewListKeysPaginator(KMS, &kms.ListKeysInput{})
+	for paginator.HasMorePages() {
+		keys, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list kms keys")
+			return
+		}
+		for _, entry := range keys.Keys {
+			output, err := KMS.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: entry.KeyId})
+			if err != nil {
+				results.Errorf(err, "failed to describe kms key %s", *entry.KeyId)
+				continue
+			}
+			metadata := output.KeyMetadata
+			key := KMSKey{
+				KeyId:        *metadata.KeyId,
+				Arn:          *metadata.Arn,
+				CreationDate: metadata.CreationDate,
+				Description:  deref(metadata.Description),
+				Enabled:      metadata.Enabled,
+				KeyManager:   string(metadata.KeyManager),
+				KeyState:     string(metadata.KeyState),
+				KeyUsage:     string(metadata.KeyUsage),
+				KeySpec:      string(metadata.KeySpec),
+				Origin:       string(metadata.Origin),
+				MultiRegion:  metadata.MultiRegion != nil && *metadata.MultiRegion,
+				DeletionDate: metadata.DeletionDate,
+				Aliases:      aliases[*metadata.KeyId],
+			}
+
+			// keys pending deletion reject every other call
+			if metadata.KeyState != "PendingDeletion" {
+				if policy, err := KMS.GetKeyPolicy(ctx, &kms.GetKeyPolicyInput{KeyId: metadata.KeyId, PolicyName: strPtr("default")}); err != nil {
+					results.Errorf(err, "failed to get policy of kms key %s", key.KeyId)
+				} else if policy.Policy != nil {
+					key.Policy = parsePolicyDocument(*policy.Policy)
+				}
+				// the rotation of AWS managed keys is not configurable and the API is denied to most roles
+				if key.KeyManager == "CUSTOMER" {
+					if rotation, err := KMS.GetKeyRotationStatus(ctx, &kms.GetKeyRotationStatusInput{KeyId: metadata.KeyId}); err == nil {
+						key.KeyRotationEnabled = rotation.KeyRotationEnabled
+					}
+				}
+			}
+
+			tags := make(v1.JSONStringMap)
+			if key.KeyManager == "CUSTOMER" {
+				if output, err := KMS.ListResourceTags(ctx, &kms.ListResourceTagsInput{KeyId: metadata.KeyId}); err != nil {
+					results.Errorf(err, "failed to list tags of kms key %s", key.KeyId)
+				} else {
+					for _, tag := range output.Tags {
+						tags[*tag.TagKey] = deref(tag.TagValue)
+					}
+				}
+			}
+
+			name := key.KeyId
+			if len(key.Aliases) > 0 {
+				name = strings.TrimPrefix(key.Aliases[0], "alias/")
+			}
+			ids := []string{key.KeyId}
+			for _, alias := range key.Aliases {
+				ids = append(ids, kmsKeyArn(alias, ctx.Session.Region, *ctx.Caller.Account))
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSKMSKey,
+				CreatedAt:          key.CreationDate,
+				Tags:               tags,
+				BaseScraper:        config.BaseScraper,
+				Config:             key,
+				Type:               "KMSKey",
+				Name:               getName(tags, name),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            ids,
+				ID:                 key.Arn,
+				Ignore:             []string{"CreationDate"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+}
+
+// kmsKeyArn returns the ARN of a key or alias referenced by id, name or ARN, keys and aliases that are not referenced
+// by ARN are in the region and account of the resource referencing them
+func kmsKeyArn(key, region, account string) string {
+	if strings.HasPrefix(key, "arn:") {
+		return key
+	}
+	if strings.HasPrefix(key, "alias/") {
+		return fmt.Sprintf("arn:aws:kms:%s:%s:%s", region, account, key)
+	}
+	return fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", region, account, key)
+}
+
+// encryptedBy relates an item to the KMS key that encrypts it, no relationship is returned when key is empty
+func encryptedBy(item v1.ExternalID, key, region, account string) v1.RelationshipResults {
+	if key == "" {
+		return nil
+	}
+	return v1.RelationshipResults{{
+		ConfigExternalID: item,
+		RelatedExternalID: v1.ExternalID{
+			ExternalID:   []string{kmsKeyArn(key, region, account)},
+			ExternalType: v1.AWSKMSKey,
+		},
+		Relationship: "EncryptedBy",
+	}}
+}
@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) lambdaFunctions(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Lambda") {
+		return
+	}
+
+	Lambda := ctx.client("lambda", func() interface{} { return lambda.NewFromConfig(*ctx.Session) }).(*lambda.Client)
+	paginator := lambda.NewListFunctionsPaginator(Lambda, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		functions, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list lambda functions")
+			return
+		}
+
+		for _, function := range functions.Functions {
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := Lambda.ListTags(ctx, &lambda.ListTagsInput{Resource: function.FunctionArn}); err == nil {
+				for k, v := range tagsOutput.Tags {
+					tags[k] = v
+				}
+			}
+
+			// environment variables regularly contain credentials, only the keys are kept
+			if function.Environment != nil {
+				for k := range function.Environment.Variables {
+					function.Environment.Variables[k] = ""
+				}
+			}
+
+			var relationships v1.RelationshipResults
+			if function.Role != nil {
+				relationships = append(relationships, roleRelationship(*function.Role, v1.ExternalID{
+					ExternalID:   []string{*function.FunctionArn},
+					ExternalType: v1.AWSLambdaFunction,
+				}, "IAMRoleLambda"))
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSLambdaFunction,
+				BaseScraper:         config.BaseScraper,
+				Config:              function,
+				Tags:                tags,
+				Type:                "Lambda",
+				Name:                *function.FunctionName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*function.FunctionName, "AWSLambda/" + *function.FunctionArn},
+				ID:                  *function.FunctionArn,
+				Ignore:              []string{"lastModified", "lastUpdateStatus", "state"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// getLambdaFunctionArn returns the unqualified function ARN from either a function ARN
+// (optionally with a version or alias qualifier) or an API Gateway lambda invocation URI
+// e.g. arn:aws:apigateway:eu-west-1:lambda:path/2015-03-31/functions/arn:aws:lambda:eu-west-1:123:function:name/invocations
+func getLambdaFunctionArn(uri string) string {
+	if i := strings.Index(uri, "arn:aws:lambda:"); i >= 0 {
+		uri = strings.TrimSuffix(uri[i:], "/invocations")
+	} else {
+		return ""
+	}
+	if strings.Contains(uri, "$") {
+		// stage variables are only resolved at invocation time
+		return ""
+	}
+	parts := strings.Split(uri, ":")
+	if len(parts) < 7 {
+		return ""
+	}
+	return strings.Join(parts[:7], ":")
+}
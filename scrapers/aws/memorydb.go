@@ -0,0 +1,138 @@
+package aws
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+type memoryDBNode struct {
+	Name             string  `json:"Name"`
+	AvailabilityZone string  `json:"AvailabilityZone"`
+	CreateTime       float64 `json:"CreateTime"`
+}
+
+type memoryDBCluster struct {
+	Name           string `json:"Name"`
+	ARN            string `json:"ARN"`
+	SecurityGroups []struct {
+		SecurityGroupId string `json:"SecurityGroupId"`
+	} `json:"SecurityGroups"`
+	Shards []struct {
+		Name  string            `json:"Name"`
+		Nodes []json.RawMessage `json:"Nodes"`
+	} `json:"Shards"`
+}
+
+type describeMemoryDBClusters struct {
+	NextToken *string           `json:"NextToken"`
+	Clusters  []json.RawMessage `json:"Clusters"`
+}
+
+type describeMemoryDBClustersInput struct {
+	ShowShardDetails bool    `json:"ShowShardDetails"`
+	NextToken        *string `json:"NextToken,omitempty"`
+}
+
+func (aws Scraper) memoryDB(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("MemoryDB") {
+		return
+	}
+
+	client := ctx.client("memorydb", func() interface{} {
+		return &jsonClient{
+			cfg:            *ctx.Session,
+			endpointPrefix: "memory-db",
+			signingName:    "memorydb",
+			targetPrefix:   "AmazonMemoryDB",
+			contentType:    "application/x-amz-json-1.1",
+		}
+	}).(*jsonClient)
+
+	input := describeMemoryDBClustersInput{ShowShardDetails: true}
+	for {
+		var output describeMemoryDBClusters
+		if err := client.call(ctx, "DescribeClusters", input, &output); err != nil {
+			results.Errorf(err, "failed to describe memorydb clusters")
+			return
+		}
+		for _, raw := range output.Clusters {
+			var cluster memoryDBCluster
+			var clusterConfig map[string]interface{}
+			if err := json.Unmarshal(raw, &cluster); err != nil {
+				results.Errorf(err, "failed to decode memorydb cluster")
+				continue
+			}
+			_ = json.Unmarshal(raw, &clusterConfig)
+
+			var relationships v1.RelationshipResults
+			for _, sg := range cluster.SecurityGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{cluster.Name},
+						ExternalType: v1.AWSMemoryDBCluster,
+					},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{sg.SecurityGroupId},
+						ExternalType: v1.AWSEC2SecurityGroup,
+					},
+					Relationship: "MemoryDBSecurityGroup",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSMemoryDBCluster,
+				BaseScraper:         config.BaseScraper,
+				Config:              clusterConfig,
+				Type:                "MemoryDB",
+				Name:                cluster.Name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{cluster.ARN, "AmazonMemoryDB/" + cluster.ARN},
+				ID:                  cluster.Name,
+				Ignore:              []string{"Shards"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+
+			for _, shard := range cluster.Shards {
+				for _, rawNode := range shard.Nodes {
+					var node memoryDBNode
+					var nodeConfig map[string]interface{}
+					if err := json.Unmarshal(rawNode, &node); err != nil {
+						results.Errorf(err, "failed to decode memorydb node of %s", cluster.Name)
+						continue
+					}
+					_ = json.Unmarshal(rawNode, &nodeConfig)
+					var createdAt *time.Time
+					if node.CreateTime > 0 {
+						created := time.Unix(0, int64(node.CreateTime*float64(time.Second))).UTC()
+						createdAt = &created
+					}
+					*results = append(*results, v1.ScrapeResult{
+						ExternalType:       v1.AWSMemoryDBNode,
+						CreatedAt:          createdAt,
+						BaseScraper:        config.BaseScraper,
+						Config:             nodeConfig,
+						Type:               "MemoryDBNode",
+						Name:               cluster.Name + "/" + node.Name,
+						Account:            *ctx.Caller.Account,
+						Region:             ctx.Session.Region,
+						Zone:               node.AvailabilityZone,
+						Tags:               map[string]string{"shard": shard.Name},
+						ID:                 cluster.Name + "/" + node.Name,
+						Ignore:             []string{"CreateTime"},
+						ParentExternalID:   cluster.Name,
+						ParentExternalType: v1.AWSMemoryDBCluster,
+					})
+				}
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+}
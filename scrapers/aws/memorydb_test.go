@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func TestMemoryDB(t *testing.T) {
+	pages := []string{
+		`{"NextToken": "2", "Clusters": [{"Name": "sessions", "ARN": "arn:aws:memorydb:eu-west-1:123456789012:cluster/sessions",
+			"SecurityGroups": [{"SecurityGroupId": "sg-1", "Status": "active"}],
+			"Shards": [{"Name": "0001", "Nodes": [{"Name": "0001-001", "AvailabilityZone": "eu-west-1a", "CreateTime": 1.6e9}]}]}]}`,
+		`{"Clusters": [{"Name": "cache", "ARN": "arn:aws:memorydb:eu-west-1:123456789012:cluster/cache"}]}`,
+	}
+	var requests []describeMemoryDBClustersInput
+	ctx := testContext(t, func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "AmazonMemoryDB.DescribeClusters" {
+			t.Errorf("unexpected target %s", target)
+		}
+		var input describeMemoryDBClustersInput
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		requests = append(requests, input)
+		_, _ = w.Write([]byte(pages[len(requests)-1]))
+	})
+
+	var results v1.ScrapeResults
+	Scraper{}.memoryDB(ctx, v1.AWS{}, &results)
+	if len(requests) != 2 || !requests[0].ShowShardDetails || deref(requests[1].NextToken) != "2" {
+		t.Fatalf("expected 2 pages with shard details, got %v", requests)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 2 clusters and 1 node, got %d results", len(results))
+	}
+	cluster, node := results[0], results[1]
+	if cluster.ID != "sessions" || cluster.Aliases[0] != "arn:aws:memorydb:eu-west-1:123456789012:cluster/sessions" ||
+		len(cluster.RelationshipResults) != 1 {
+		t.Errorf("unexpected cluster %s %v %v", cluster.ID, cluster.Aliases, cluster.RelationshipResults)
+	}
+	if node.ID != "sessions/0001-001" || node.ParentExternalID != "sessions" || node.Zone != "eu-west-1a" ||
+		node.CreatedAt == nil || node.CreatedAt.Unix() != 1600000000 {
+		t.Errorf("unexpected node %s %s %s %v", node.ID, node.ParentExternalID, node.Zone, node.CreatedAt)
+	}
+}
@@ -0,0 +1,172 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) sqs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("SQS") {
+		return
+	}
+
+	SQS := ctx.client("sqs", func() interface{} { return sqs.NewFromConfig(*ctx.Session) }).(*sqs.Client)
+	paginator := sqs.NewListQueuesPaginator(SQS, &sqs.ListQueuesInput{})
+	for paginator.HasMorePages() {
+		queues, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list sqs queues")
+			return
+		}
+
+		for _, queueURL := range queues.QueueUrls {
+			attributes, err := SQS.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+				QueueUrl:       strPtr(queueURL),
+				AttributeNames: []sqsTypes.QueueAttributeName{sqsTypes.QueueAttributeNameAll},
+			})
+			if err != nil {
+				results.Errorf(err, "failed to get attributes of queue %s", queueURL)
+				continue
+			}
+
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := SQS.ListQueueTags(ctx, &sqs.ListQueueTagsInput{QueueUrl: strPtr(queueURL)}); err != nil {
+				logger.Errorf("error while fetching sqs tags: %v", err)
+			} else {
+				for k, v := range tagsOutput.Tags {
+					tags[k] = v
+				}
+			}
+
+			arn := attributes.Attributes[string(sqsTypes.QueueAttributeNameQueueArn)]
+			name := queueURL[strings.LastIndex(queueURL, "/")+1:]
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSSQSQueue,
+				BaseScraper:        config.BaseScraper,
+				Config:             attributes.Attributes,
+				Tags:               tags,
+				Type:               "SQS",
+				Name:               getName(tags, name),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{queueURL, "AWSQueueService/" + arn},
+				ID:                 arn,
+				Ignore:             []string{"ApproximateNumberOfMessages", "ApproximateNumberOfMessagesDelayed", "ApproximateNumberOfMessagesNotVisible", "LastModifiedTimestamp"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+}
+
+func (aws Scraper) sns(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("SNS") {
+		return
+	}
+
+	SNS := ctx.client("sns", func() interface{} { return sns.NewFromConfig(*ctx.Session) }).(*sns.Client)
+	paginator := sns.NewListTopicsPaginator(SNS, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() {
+		topics, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list sns topics")
+			return
+		}
+
+		for _, topic := range topics.Topics {
+			attributes, err := SNS.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: topic.TopicArn})
+			if err != nil {
+				results.Errorf(err, "failed to get attributes of topic %s", *topic.TopicArn)
+				continue
+			}
+
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := SNS.ListTagsForResource(ctx, &sns.ListTagsForResourceInput{ResourceArn: topic.TopicArn}); err != nil {
+				logger.Errorf("error while fetching sns tags: %v", err)
+			} else {
+				for _, tag := range tagsOutput.Tags {
+					tags[*tag.Key] = *tag.Value
+				}
+			}
+
+			arn := *topic.TopicArn
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSSNSTopic,
+				BaseScraper:        config.BaseScraper,
+				Config:             attributes.Attributes,
+				Tags:               tags,
+				Type:               "SNS",
+				Name:               getName(tags, arn[strings.LastIndex(arn, ":")+1:]),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{"AmazonSNS/" + arn},
+				ID:                 arn,
+				Ignore:             []string{"SubscriptionsConfirmed", "SubscriptionsDeleted", "SubscriptionsPending"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+
+			aws.snsSubscriptions(ctx, config, SNS, arn, results)
+		}
+	}
+}
+
+func (aws Scraper) snsSubscriptions(ctx *AWSContext, config v1.AWS, client *sns.Client, topicArn string, results *v1.ScrapeResults) {
+	paginator := sns.NewListSubscriptionsByTopicPaginator(client, &sns.ListSubscriptionsByTopicInput{TopicArn: &topicArn})
+	for paginator.HasMorePages() {
+		subscriptions, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list subscriptions of topic %s", topicArn)
+			return
+		}
+
+		for _, subscription := range subscriptions.Subscriptions {
+			id := getSubscriptionID(subscription)
+
+			var relationships v1.RelationshipResults
+			if deref(subscription.Protocol) == "sqs" && subscription.Endpoint != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{id},
+						ExternalType: v1.AWSSNSSubscription,
+					},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*subscription.Endpoint},
+						ExternalType: v1.AWSSQSQueue,
+					},
+					Relationship: "SubscriptionQueue",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSSNSSubscription,
+				BaseScraper:         config.BaseScraper,
+				Config:              subscription,
+				Type:                "SNSSubscription",
+				Name:                deref(subscription.Protocol) + ":" + deref(subscription.Endpoint),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  id,
+				ParentExternalID:    topicArn,
+				ParentExternalType:  v1.AWSSNSTopic,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// getSubscriptionID returns the subscription ARN, unconfirmed subscriptions don't have one yet
+// so they are identified by their topic, protocol and endpoint instead
+func getSubscriptionID(subscription snsTypes.Subscription) string {
+	arn := deref(subscription.SubscriptionArn)
+	if strings.HasPrefix(arn, "arn:") {
+		return arn
+	}
+	return strings.Join([]string{deref(subscription.TopicArn), deref(subscription.Protocol), deref(subscription.Endpoint)}, "/")
+}
@@ -0,0 +1,138 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/opensearch"
+	opensearchTypes "github.com/aws/aws-sdk-go-v2/service/opensearch/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// describeDomainsLimit is the maximum number of domains that can be described in a single request
+const describeDomainsLimit = 5
+
+// OpenSearchDomain is a domain with its parsed access policy
+type OpenSearchDomain struct {
+	opensearchTypes.DomainStatus
+	AccessPolicies PolicyDocument `json:"AccessPolicies,omitempty"`
+}
+
+// openSearchDomains scrapes OpenSearch and Elasticsearch domains, both are managed by the same API
+func (aws Scraper) openSearchDomains(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("OpenSearch") {
+		return
+	}
+	OpenSearch := ctx.client("opensearch", func() interface{} { return opensearch.NewFromConfig(*ctx.Session) }).(*opensearch.Client)
+	names, err := OpenSearch.ListDomainNames(ctx, &opensearch.ListDomainNamesInput{})
+	if err != nil {
+		results.Errorf(err, "failed to list opensearch domains")
+		return
+	}
+
+	var domainNames []string
+	for _, domain := range names.DomainNames {
+		domainNames = append(domainNames, *domain.DomainName)
+	}
+	for start := 0; start < len(domainNames); start += describeDomainsLimit {
+		end := start + describeDomainsLimit
+		if end > len(domainNames) {
+			end = len(domainNames)
+		}
+		domains, err := OpenSearch.DescribeDomains(ctx, &opensearch.DescribeDomainsInput{DomainNames: domainNames[start:end]})
+		if err != nil {
+			results.Errorf(err, "failed to describe opensearch domains")
+			continue
+		}
+		for _, status := range domains.DomainStatusList {
+			domain := OpenSearchDomain{DomainStatus: status}
+			if status.AccessPolicies != nil && *status.AccessPolicies != "" {
+				domain.AccessPolicies = parsePolicyDocument(*status.AccessPolicies)
+			}
+
+			tags := make(v1.JSONStringMap)
+			if output, err := OpenSearch.ListTags(ctx, &opensearch.ListTagsInput{ARN: status.ARN}); err != nil {
+				results.Errorf(err, "failed to get tags of opensearch domain %s", *status.DomainName)
+			} else {
+				for _, tag := range output.TagList {
+					tags[*tag.Key] = *tag.Value
+				}
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*status.ARN}, ExternalType: v1.AWSOpenSearchDomain}
+			var relationships v1.RelationshipResults
+			var network, subnet string
+			if vpc := status.VPCOptions; vpc != nil {
+				network = deref(vpc.VPCId)
+				if len(vpc.SubnetIds) > 0 {
+					subnet = vpc.SubnetIds[0]
+				}
+				for _, group := range vpc.SecurityGroupIds {
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  self,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{group}, ExternalType: v1.AWSEC2SecurityGroup},
+						Relationship:      "OpenSearchDomainSecurityGroup",
+					})
+				}
+			}
+			if encryption := status.EncryptionAtRestOptions; encryption != nil && encryption.Enabled != nil && *encryption.Enabled {
+				relationships = append(relationships, encryptedBy(self, deref(encryption.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSOpenSearchDomain,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              domain,
+				Type:                "OpenSearchDomain",
+				Name:                *status.DomainName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Network:             network,
+				Subnet:              subnet,
+				Aliases:             []string{*status.DomainName, "AmazonES/" + *status.ARN},
+				ID:                  *status.ARN,
+				Ignore:              []string{"Processing", "UpgradeProcessing", "ChangeProgressDetails", "ServiceSoftwareOptions"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// analyzeOpenSearchDomain reports domains with a public endpoint whose access policy allows anyone, without a
+// condition that restricts the callers e.g. by source IP
+func analyzeOpenSearchDomain(domain OpenSearchDomain) []string {
+	if domain.VPCOptions != nil {
+		return nil
+	}
+	for _, statement := range domain.AccessPolicies.Statements() {
+		if statement["Effect"] != "Allow" || statement["Condition"] != nil {
+			continue
+		}
+		if isPublicPrincipal(statement["Principal"]) {
+			return []string{fmt.Sprintf("domain %s is publicly accessible", *domain.DomainName)}
+		}
+	}
+	return nil
+}
+
+// isPublicPrincipal returns true for the principals "*" and {"AWS": "*"}
+func isPublicPrincipal(principal interface{}) bool {
+	switch p := principal.(type) {
+	case string:
+		return p == "*"
+	case map[string]interface{}:
+		switch aws := p["AWS"].(type) {
+		case string:
+			return aws == "*"
+		case []interface{}:
+			for _, a := range aws {
+				if a == "*" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
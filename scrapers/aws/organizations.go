@@ -0,0 +1,218 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// organizationAccounts returns the role to assume for every active account in the organization,
+// the account the connection belongs to is returned as an empty role as it is scraped directly
+func (aws Scraper) organizationAccounts(ctx *v1.ScrapeContext, awsConfig v1.AWS) ([]string, error) {
+	session, err := NewSession(ctx, *awsConfig.AWSConnection, "us-east-1")
+	if err != nil {
+		return nil, err
+	}
+	caller, err := sts.NewFromConfig(*session).GetCallerIdentity(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	partition := strings.Split(*caller.Arn, ":")[1]
+
+	var roles []string
+	paginator := organizations.NewListAccountsPaginator(organizations.NewFromConfig(*session), &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range output.Accounts {
+			if account.Status != types.AccountStatusActive || !awsConfig.Organization.Includes(*account.Id) {
+				continue
+			}
+			if *account.Id == *caller.Account {
+				roles = append(roles, "")
+				continue
+			}
+			roles = append(roles, fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, *account.Id, awsConfig.Organization.GetRoleName()))
+		}
+	}
+	logger.Infof("Scraping %d accounts of the organization", len(roles))
+	return roles, nil
+}
+
+// organization scrapes the organization, its organizational units and service control policies
+// and returns the parent (root or OU) of every account so that accounts can be placed in the tree
+func (aws Scraper) organization(ctx *v1.ScrapeContext, awsConfig v1.AWS, results *v1.ScrapeResults) map[string]string {
+	parents := make(map[string]string)
+	if !awsConfig.Includes("Organization") {
+		return parents
+	}
+
+	session, err := NewSession(ctx, *awsConfig.AWSConnection, "us-east-1")
+	if err != nil {
+		results.Errorf(err, "failed to create AWS session")
+		return parents
+	}
+	client := organizations.NewFromConfig(*session)
+
+	org, err := client.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err != nil {
+		results.Errorf(err, "failed to describe organization")
+		return parents
+	}
+	*results = append(*results, v1.ScrapeResult{
+		ExternalType: v1.AWSOrganizationsOrganization,
+		BaseScraper:  awsConfig.BaseScraper,
+		Config:       org.Organization,
+		Type:         "AWSOrganization",
+		Name:         *org.Organization.Id,
+		Account:      deref(org.Organization.MasterAccountId),
+		Aliases:      []string{*org.Organization.Arn},
+		ID:           *org.Organization.Id,
+	})
+
+	roots := organizations.NewListRootsPaginator(client, &organizations.ListRootsInput{})
+	for roots.HasMorePages() {
+		output, err := roots.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list organization roots")
+			return parents
+		}
+		for _, root := range output.Roots {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSOrganizationalUnit,
+				BaseScraper:        awsConfig.BaseScraper,
+				Config:             root,
+				Type:               "OrganizationalUnit",
+				Name:               *root.Name,
+				Account:            deref(org.Organization.MasterAccountId),
+				Aliases:            []string{*root.Arn},
+				ID:                 *root.Id,
+				ParentExternalID:   *org.Organization.Id,
+				ParentExternalType: v1.AWSOrganizationsOrganization,
+			})
+			aws.organizationalUnits(ctx, awsConfig, client, *root.Id, deref(org.Organization.MasterAccountId), parents, results)
+		}
+	}
+
+	aws.serviceControlPolicies(ctx, awsConfig, client, deref(org.Organization.MasterAccountId), results)
+	return parents
+}
+
+// organizationalUnits walks the tree below parentID recording the parent of every account
+func (aws Scraper) organizationalUnits(ctx *v1.ScrapeContext, awsConfig v1.AWS, client *organizations.Client, parentID, account string, parents map[string]string, results *v1.ScrapeResults) {
+	accounts := organizations.NewListAccountsForParentPaginator(client, &organizations.ListAccountsForParentInput{ParentId: &parentID})
+	for accounts.HasMorePages() {
+		output, err := accounts.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list accounts of %s", parentID)
+			break
+		}
+		for _, a := range output.Accounts {
+			parents[*a.Id] = parentID
+		}
+	}
+
+	units := organizations.NewListOrganizationalUnitsForParentPaginator(client, &organizations.ListOrganizationalUnitsForParentInput{ParentId: &parentID})
+	for units.HasMorePages() {
+		output, err := units.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list organizational units of %s", parentID)
+			return
+		}
+		for _, unit := range output.OrganizationalUnits {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSOrganizationalUnit,
+				BaseScraper:        awsConfig.BaseScraper,
+				Config:             unit,
+				Type:               "OrganizationalUnit",
+				Name:               *unit.Name,
+				Account:            account,
+				Aliases:            []string{*unit.Arn},
+				ID:                 *unit.Id,
+				ParentExternalID:   parentID,
+				ParentExternalType: v1.AWSOrganizationalUnit,
+			})
+			aws.organizationalUnits(ctx, awsConfig, client, *unit.Id, account, parents, results)
+		}
+	}
+}
+
+func (aws Scraper) serviceControlPolicies(ctx *v1.ScrapeContext, awsConfig v1.AWS, client *organizations.Client, account string, results *v1.ScrapeResults) {
+	policies := organizations.NewListPoliciesPaginator(client, &organizations.ListPoliciesInput{Filter: types.PolicyTypeServiceControlPolicy})
+	for policies.HasMorePages() {
+		output, err := policies.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list service control policies")
+			return
+		}
+		for _, summary := range output.Policies {
+			policy, err := client.DescribePolicy(ctx, &organizations.DescribePolicyInput{PolicyId: summary.Id})
+			if err != nil {
+				results.Errorf(err, "failed to describe policy %s", *summary.Id)
+				continue
+			}
+
+			var relationships v1.RelationshipResults
+			targets := organizations.NewListTargetsForPolicyPaginator(client, &organizations.ListTargetsForPolicyInput{PolicyId: summary.Id})
+			for targets.HasMorePages() {
+				output, err := targets.NextPage(ctx)
+				if err != nil {
+					results.Errorf(err, "failed to list targets of policy %s", *summary.Id)
+					break
+				}
+				for _, target := range output.Targets {
+					targetType := v1.AWSOrganizationalUnit
+					if target.Type == types.TargetTypeAccount {
+						targetType = v1.AWSAccount
+					}
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID: v1.ExternalID{
+							ExternalID:   []string{*summary.Id},
+							ExternalType: v1.AWSServiceControlPolicy,
+						},
+						RelatedExternalID: v1.ExternalID{
+							ExternalID:   []string{*target.TargetId},
+							ExternalType: targetType,
+						},
+						Relationship: "SCPAttachment",
+					})
+				}
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType: v1.AWSServiceControlPolicy,
+				BaseScraper:  awsConfig.BaseScraper,
+				Config: map[string]interface{}{
+					"PolicySummary": summary,
+					"Document":      parsePolicyDocument(deref(policy.Policy.Content)),
+				},
+				Type:                "ServiceControlPolicy",
+				Name:                *summary.Name,
+				Account:             account,
+				Aliases:             []string{*summary.Arn},
+				ID:                  *summary.Id,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// setAccountParents places the scraped accounts below their organizational unit
+func setAccountParents(parents map[string]string, results []v1.ScrapeResult) {
+	for i := range results {
+		if results[i].ExternalType != v1.AWSAccount {
+			continue
+		}
+		if parent, ok := parents[results[i].ID]; ok {
+			results[i].ParentExternalID = parent
+			results[i].ParentExternalType = v1.AWSOrganizationalUnit
+		}
+	}
+}
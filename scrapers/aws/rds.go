@@ -0,0 +1,373 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// rdsArn returns the ARN of an RDS resource of the region, groups and clusters are referenced by name
+func rdsArn(ctx *AWSContext, kind, name string) string {
+	return fmt.Sprintf("arn:aws:rds:%s:%s:%s:%s", ctx.Session.Region, *ctx.Caller.Account, kind, name)
+}
+
+func rdsTags(tagList []rdsTypes.Tag) v1.JSONStringMap {
+	tags := make(v1.JSONStringMap)
+	for _, tag := range tagList {
+		tags[*tag.Key] = deref(tag.Value)
+	}
+	return tags
+}
+
+func (aws Scraper) rds(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("RDS") {
+		return
+	}
+	RDS := ctx.client("rds", func() interface{} { return rds.NewFromConfig(*ctx.Session) }).(*rds.Client)
+	paginator := rds.NewDescribeDBInstancesPaginator(RDS, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		describeOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get rds")
+			return
+		}
+		for _, instance := range describeOutput.DBInstances {
+			tags := rdsTags(instance.TagList)
+			self := v1.ExternalID{
+				ExternalID:   []string{*instance.DBInstanceIdentifier},
+				ExternalType: v1.AWSRDSInstance,
+			}
+
+			var relationships v1.RelationshipResults
+			// SecurityGroup relationships
+			for _, sg := range instance.VpcSecurityGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*sg.VpcSecurityGroupId},
+						ExternalType: v1.AWSEC2SecurityGroup,
+					},
+					Relationship: "RDSSecurityGroup",
+				})
+			}
+			relationships = append(relationships, encryptedBy(self, deref(instance.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			for _, group := range instance.DBParameterGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{rdsArn(ctx, "pg", *group.DBParameterGroupName)},
+						ExternalType: v1.AWSRDSParameterGroup,
+					},
+					Relationship: "RDSParameterGroup",
+				})
+			}
+			for _, group := range instance.OptionGroupMemberships {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{rdsArn(ctx, "og", *group.OptionGroupName)},
+						ExternalType: v1.AWSRDSOptionGroup,
+					},
+					Relationship: "RDSOptionGroup",
+				})
+			}
+			if instance.DBSubnetGroup != nil && instance.DBSubnetGroup.DBSubnetGroupName != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{rdsArn(ctx, "subgrp", *instance.DBSubnetGroup.DBSubnetGroupName)},
+						ExternalType: v1.AWSRDSSubnetGroup,
+					},
+					Relationship: "RDSSubnetGroup",
+				})
+			}
+
+			aliases := []string{"AmazonRDS/" + *instance.DBInstanceArn}
+			// AWS Config identifies instances by their resource id
+			if instance.DbiResourceId != nil {
+				aliases = append(aliases, *instance.DbiResourceId)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSRDSInstance,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              instance,
+				Type:                "RDS",
+				Name:                getName(tags, *instance.DBInstanceIdentifier),
+				Account:             *ctx.Caller.Account,
+				ID:                  *instance.DBInstanceIdentifier,
+				Aliases:             aliases,
+				ParentExternalID:    *instance.DBSubnetGroup.VpcId,
+				ParentExternalType:  v1.AWSEC2VPC,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// rdsClusters scrapes Aurora and Multi-AZ DB clusters, the instances of a cluster are related to it
+func (aws Scraper) rdsClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("RDS") {
+		return
+	}
+	RDS := ctx.client("rds", func() interface{} { return rds.NewFromConfig(*ctx.Session) }).(*rds.Client)
+	paginator := rds.NewDescribeDBClustersPaginator(RDS, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds clusters")
+			return
+		}
+		for _, cluster := range clusters.DBClusters {
+			tags := rdsTags(cluster.TagList)
+			self := v1.ExternalID{
+				ExternalID:   []string{*cluster.DBClusterIdentifier},
+				ExternalType: v1.AWSRDSCluster,
+			}
+
+			var relationships v1.RelationshipResults
+			for _, member := range cluster.DBClusterMembers {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*member.DBInstanceIdentifier},
+						ExternalType: v1.AWSRDSInstance,
+					},
+					Relationship: "RDSClusterInstance",
+				})
+			}
+			for _, sg := range cluster.VpcSecurityGroups {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*sg.VpcSecurityGroupId},
+						ExternalType: v1.AWSEC2SecurityGroup,
+					},
+					Relationship: "RDSSecurityGroup",
+				})
+			}
+			if cluster.DBClusterParameterGroup != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{rdsArn(ctx, "cluster-pg", *cluster.DBClusterParameterGroup)},
+						ExternalType: v1.AWSRDSClusterParameterGroup,
+					},
+					Relationship: "RDSParameterGroup",
+				})
+			}
+			if cluster.DBSubnetGroup != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: self,
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{rdsArn(ctx, "subgrp", *cluster.DBSubnetGroup)},
+						ExternalType: v1.AWSRDSSubnetGroup,
+					},
+					Relationship: "RDSSubnetGroup",
+				})
+			}
+			relationships = append(relationships, encryptedBy(self, deref(cluster.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			aliases := []string{*cluster.DBClusterArn}
+			// Aurora storage and I/O are billed to the cluster by its resource id rather than its identifier, which is
+			// also how AWS Config identifies clusters
+			if cluster.DbClusterResourceId != nil {
+				aliases = append(aliases, "AmazonRDS/"+rdsArn(ctx, "cluster", *cluster.DbClusterResourceId), *cluster.DbClusterResourceId)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSRDSCluster,
+				CreatedAt:           cluster.ClusterCreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster,
+				Type:                "RDSCluster",
+				Name:                getName(tags, *cluster.DBClusterIdentifier),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *cluster.DBClusterIdentifier,
+				Aliases:             aliases,
+				Ignore:              []string{"ClusterCreateTime", "EarliestRestorableTime", "LatestRestorableTime", "PercentProgress"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// rdsSnapshots scrapes the manual and automated snapshots of instances and clusters
+func (aws Scraper) rdsSnapshots(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("RDSSnapshot") {
+		return
+	}
+	RDS := ctx.client("rds", func() interface{} { return rds.NewFromConfig(*ctx.Session) }).(*rds.Client)
+
+	paginator := rds.NewDescribeDBSnapshotsPaginator(RDS, &rds.DescribeDBSnapshotsInput{})
+	for paginator.HasMorePages() {
+		snapshots, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds snapshots")
+			break
+		}
+		for _, snapshot := range snapshots.DBSnapshots {
+			tags := rdsTags(snapshot.TagList)
+			self := v1.ExternalID{ExternalID: []string{*snapshot.DBSnapshotArn}, ExternalType: v1.AWSRDSSnapshot}
+			relationships := v1.RelationshipResults{{
+				ConfigExternalID: v1.ExternalID{
+					ExternalID:   []string{deref(snapshot.DBInstanceIdentifier)},
+					ExternalType: v1.AWSRDSInstance,
+				},
+				RelatedExternalID: self,
+				Relationship:      "RDSSnapshot",
+			}}
+			relationships = append(relationships, encryptedBy(self, deref(snapshot.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSRDSSnapshot,
+				CreatedAt:           snapshot.SnapshotCreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              snapshot,
+				Type:                "RDSSnapshot",
+				Name:                getName(tags, *snapshot.DBSnapshotIdentifier),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *snapshot.DBSnapshotArn,
+				Aliases:             []string{"AmazonRDS/" + *snapshot.DBSnapshotArn},
+				Ignore:              []string{"PercentProgress"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+
+	clusterPaginator := rds.NewDescribeDBClusterSnapshotsPaginator(RDS, &rds.DescribeDBClusterSnapshotsInput{})
+	for clusterPaginator.HasMorePages() {
+		snapshots, err := clusterPaginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds cluster snapshots")
+			return
+		}
+		for _, snapshot := range snapshots.DBClusterSnapshots {
+			tags := rdsTags(snapshot.TagList)
+			self := v1.ExternalID{ExternalID: []string{*snapshot.DBClusterSnapshotArn}, ExternalType: v1.AWSRDSClusterSnapshot}
+			relationships := v1.RelationshipResults{{
+				ConfigExternalID: v1.ExternalID{
+					ExternalID:   []string{deref(snapshot.DBClusterIdentifier)},
+					ExternalType: v1.AWSRDSCluster,
+				},
+				RelatedExternalID: self,
+				Relationship:      "RDSClusterSnapshot",
+			}}
+			relationships = append(relationships, encryptedBy(self, deref(snapshot.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSRDSClusterSnapshot,
+				CreatedAt:           snapshot.SnapshotCreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              snapshot,
+				Type:                "RDSClusterSnapshot",
+				Name:                getName(tags, *snapshot.DBClusterSnapshotIdentifier),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *snapshot.DBClusterSnapshotArn,
+				Aliases:             []string{"AmazonRDS/" + *snapshot.DBClusterSnapshotArn},
+				Ignore:              []string{"PercentProgress"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// rdsGroups scrapes the parameter, option and subnet groups that instances and clusters are related to
+func (aws Scraper) rdsGroups(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("RDS") {
+		return
+	}
+	RDS := ctx.client("rds", func() interface{} { return rds.NewFromConfig(*ctx.Session) }).(*rds.Client)
+	group := func(externalType, typ, name, arn string, group interface{}, parentID, parentType string) v1.ScrapeResult {
+		return v1.ScrapeResult{
+			ExternalType:       externalType,
+			BaseScraper:        config.BaseScraper,
+			Config:             group,
+			Type:               typ,
+			Name:               name,
+			Account:            *ctx.Caller.Account,
+			Region:             ctx.Session.Region,
+			ID:                 arn,
+			ParentExternalID:   parentID,
+			ParentExternalType: parentType,
+		}
+	}
+
+	parameterGroups := rds.NewDescribeDBParameterGroupsPaginator(RDS, &rds.DescribeDBParameterGroupsInput{})
+	for parameterGroups.HasMorePages() {
+		page, err := parameterGroups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds parameter groups")
+			break
+		}
+		for _, pg := range page.DBParameterGroups {
+			*results = append(*results, group(v1.AWSRDSParameterGroup, "RDSParameterGroup", *pg.DBParameterGroupName, *pg.DBParameterGroupArn, pg, *ctx.Caller.Account, v1.AWSAccount))
+		}
+	}
+
+	clusterParameterGroups := rds.NewDescribeDBClusterParameterGroupsPaginator(RDS, &rds.DescribeDBClusterParameterGroupsInput{})
+	for clusterParameterGroups.HasMorePages() {
+		page, err := clusterParameterGroups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds cluster parameter groups")
+			break
+		}
+		for _, pg := range page.DBClusterParameterGroups {
+			*results = append(*results, group(v1.AWSRDSClusterParameterGroup, "RDSClusterParameterGroup", *pg.DBClusterParameterGroupName, *pg.DBClusterParameterGroupArn, pg, *ctx.Caller.Account, v1.AWSAccount))
+		}
+	}
+
+	optionGroups := rds.NewDescribeOptionGroupsPaginator(RDS, &rds.DescribeOptionGroupsInput{})
+	for optionGroups.HasMorePages() {
+		page, err := optionGroups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds option groups")
+			break
+		}
+		for _, og := range page.OptionGroupsList {
+			*results = append(*results, group(v1.AWSRDSOptionGroup, "RDSOptionGroup", *og.OptionGroupName, *og.OptionGroupArn, og, *ctx.Caller.Account, v1.AWSAccount))
+		}
+	}
+
+	subnetGroups := rds.NewDescribeDBSubnetGroupsPaginator(RDS, &rds.DescribeDBSubnetGroupsInput{})
+	for subnetGroups.HasMorePages() {
+		page, err := subnetGroups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe rds subnet groups")
+			return
+		}
+		for _, sg := range page.DBSubnetGroups {
+			result := group(v1.AWSRDSSubnetGroup, "RDSSubnetGroup", *sg.DBSubnetGroupName, *sg.DBSubnetGroupArn, sg, deref(sg.VpcId), v1.AWSEC2VPC)
+			for _, subnet := range sg.Subnets {
+				result.RelationshipResults = append(result.RelationshipResults, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{*sg.DBSubnetGroupArn},
+						ExternalType: v1.AWSRDSSubnetGroup,
+					},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*subnet.SubnetIdentifier},
+						ExternalType: v1.AWSEC2Subnet,
+					},
+					Relationship: "RDSSubnetGroupSubnet",
+				})
+			}
+			*results = append(*results, result)
+		}
+	}
+}
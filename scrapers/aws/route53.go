@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) dnsZones(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("DNSZone") {
+		return
+	}
+	Route53 := ctx.client("route53", func() interface{} { return route53.NewFromConfig(*ctx.Session) }).(*route53.Client)
+	paginator := route53.NewListHostedZonesPaginator(Route53, &route53.ListHostedZonesInput{})
+	for paginator.HasMorePages() {
+		zones, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe hosted zones")
+			return
+		}
+		for _, zone := range zones.HostedZones {
+			zoneID := strings.ReplaceAll(*zone.Id, "/hostedzone/", "")
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSRoute53HostedZone,
+				BaseScraper:        config.BaseScraper,
+				Config:             zone,
+				Type:               "DNSZone",
+				Name:               *zone.Name,
+				Account:            *ctx.Caller.Account,
+				Aliases:            []string{*zone.Id, *zone.Name, "AmazonRoute53/arn:aws:route53:::hostedzone/" + zoneID},
+				ID:                 zoneID,
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+
+			aws.dnsRecords(ctx, config, Route53, zone, results)
+		}
+	}
+}
+
+func (aws Scraper) dnsRecords(ctx *AWSContext, config v1.AWS, client *route53.Client, zone types.HostedZone, results *v1.ScrapeResults) {
+	if !config.Includes("DNSRecord") {
+		return
+	}
+
+	zoneID := strings.ReplaceAll(*zone.Id, "/hostedzone/", "")
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: zone.Id}
+	for {
+		records, err := client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to list record sets for %s", *zone.Name)
+			return
+		}
+
+		for _, record := range records.ResourceRecordSets {
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSRoute53RecordSet,
+				BaseScraper:        config.BaseScraper,
+				Config:             record,
+				Type:               "DNSRecord",
+				Name:               fmt.Sprintf("%s %s", record.Type, *record.Name),
+				Account:            *ctx.Caller.Account,
+				ID:                 getRecordSetID(zoneID, record),
+				ParentExternalID:   zoneID,
+				ParentExternalType: v1.AWSRoute53HostedZone,
+			})
+		}
+
+		if !records.IsTruncated {
+			return
+		}
+		input.StartRecordName = records.NextRecordName
+		input.StartRecordType = records.NextRecordType
+		input.StartRecordIdentifier = records.NextRecordIdentifier
+	}
+}
+
+// getRecordSetID returns an ID that is unique within the account,
+// weighted/latency/failover records share a name and type and are only distinguished by their set identifier
+func getRecordSetID(zoneID string, record types.ResourceRecordSet) string {
+	id := fmt.Sprintf("%s/%s/%s", zoneID, *record.Name, record.Type)
+	if record.SetIdentifier != nil {
+		id += "/" + *record.SetIdentifier
+	}
+	return id
+}
@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// S3Bucket is a bucket together with its policy, public access, encryption, versioning and lifecycle configuration
+type S3Bucket struct {
+	Name              string                                     `json:"Name"`
+	CreationDate      *time.Time                                 `json:"CreationDate,omitempty"`
+	Region            string                                     `json:"Region,omitempty"`
+	IsPublic          bool                                       `json:"IsPublic"`
+	PublicAccessBlock *s3Types.PublicAccessBlockConfiguration    `json:"PublicAccessBlock,omitempty"`
+	Encryption        *s3Types.ServerSideEncryptionConfiguration `json:"Encryption,omitempty"`
+	Policy            PolicyDocument                             `json:"Policy,omitempty"`
+	Versioning        *S3BucketVersioning                        `json:"Versioning,omitempty"`
+	LifecycleRules    []s3Types.LifecycleRule                    `json:"LifecycleRules,omitempty"`
+}
+
+// S3BucketVersioning is only set once versioning has been enabled on the bucket
+type S3BucketVersioning struct {
+	Status    string `json:"Status,omitempty"`
+	MFADelete string `json:"MFADelete,omitempty"`
+}
+
+// kmsKeys returns the keys of the encryption rules of the bucket
+func (b S3Bucket) kmsKeys() []string {
+	var keys []string
+	if b.Encryption == nil {
+		return keys
+	}
+	for _, rule := range b.Encryption.Rules {
+		if rule.ApplyServerSideEncryptionByDefault != nil && rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID != nil {
+			keys = append(keys, *rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+		}
+	}
+	return keys
+}
+
+func (aws Scraper) s3Buckets(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("S3Bucket") {
+		return
+	}
+	S3 := ctx.client("s3", func() interface{} { return s3.NewFromConfig(*ctx.Session) }).(*s3.Client)
+	buckets, err := S3.ListBuckets(ctx, nil)
+	if err != nil {
+		results.Errorf(err, "failed to list s3 buckets")
+		return
+	}
+
+	// every bucket needs several calls, the details of config.MaxConcurrency buckets are fetched at a time
+	concurrency := config.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultMaxConcurrency
+	}
+	details := make([]S3Bucket, len(buckets.Buckets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, bucket := range buckets.Buckets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, bucket s3Types.Bucket) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			details[i] = aws.getBucket(ctx, S3, bucket)
+		}(i, bucket)
+	}
+	wg.Wait()
+
+	for i, bucket := range buckets.Buckets {
+		detail := details[i]
+		var relationships v1.RelationshipResults
+		for _, key := range detail.kmsKeys() {
+			relationships = append(relationships, encryptedBy(v1.ExternalID{
+				ExternalID:   []string{*bucket.Name},
+				ExternalType: v1.AWSS3Bucket,
+			}, key, detail.Region, *ctx.Caller.Account)...)
+		}
+
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType:        v1.AWSS3Bucket,
+			CreatedAt:           bucket.CreationDate,
+			BaseScraper:         config.BaseScraper,
+			Config:              detail,
+			Type:                "S3Bucket",
+			Name:                *bucket.Name,
+			Ignore:              []string{"Name", "CreationDate"},
+			Aliases:             []string{"AmazonS3/" + *bucket.Name},
+			ID:                  *bucket.Name,
+			ParentExternalID:    *ctx.Caller.Account,
+			ParentExternalType:  v1.AWSAccount,
+			RelationshipResults: relationships,
+		})
+	}
+}
+
+func (aws Scraper) getBucket(ctx *AWSContext, client *s3.Client, bucket s3Types.Bucket) S3Bucket {
+	result := S3Bucket{
+		Name:         *bucket.Name,
+		CreationDate: bucket.CreationDate,
+	}
+
+	location, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: bucket.Name})
+	if err != nil {
+		logger.Errorf("failed to get location of bucket %s: %v", *bucket.Name, err)
+		return result
+	}
+	// buckets in us-east-1 have an empty location constraint
+	result.Region = string(location.LocationConstraint)
+	if result.Region == "" {
+		result.Region = "us-east-1"
+	}
+
+	// the remaining bucket APIs must be called against the region the bucket is in
+	regional := ctx.client("s3/"+result.Region, func() interface{} {
+		return s3.NewFromConfig(*ctx.Session, func(o *s3.Options) {
+			o.Region = result.Region
+		})
+	}).(*s3.Client)
+
+	if status, err := regional.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: bucket.Name}); err == nil {
+		result.IsPublic = status.PolicyStatus != nil && status.PolicyStatus.IsPublic
+	} else if !isErrorCode(err, "NoSuchBucketPolicy") {
+		logger.Errorf("failed to get policy status of bucket %s: %v", *bucket.Name, err)
+	}
+
+	if block, err := regional.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: bucket.Name}); err == nil {
+		result.PublicAccessBlock = block.PublicAccessBlockConfiguration
+	} else if !isErrorCode(err, "NoSuchPublicAccessBlockConfiguration") {
+		logger.Errorf("failed to get public access block of bucket %s: %v", *bucket.Name, err)
+	}
+
+	if encryption, err := regional.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket.Name}); err == nil {
+		result.Encryption = encryption.ServerSideEncryptionConfiguration
+	} else if !isErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		logger.Errorf("failed to get encryption of bucket %s: %v", *bucket.Name, err)
+	}
+
+	if policy, err := regional.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: bucket.Name}); err == nil {
+		if policy.Policy != nil {
+			result.Policy = parsePolicyDocument(*policy.Policy)
+		}
+	} else if !isErrorCode(err, "NoSuchBucketPolicy") {
+		logger.Errorf("failed to get policy of bucket %s: %v", *bucket.Name, err)
+	}
+
+	if versioning, err := regional.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket.Name}); err != nil {
+		logger.Errorf("failed to get versioning of bucket %s: %v", *bucket.Name, err)
+	} else if versioning.Status != "" {
+		result.Versioning = &S3BucketVersioning{Status: string(versioning.Status), MFADelete: string(versioning.MFADelete)}
+	}
+
+	if lifecycle, err := regional.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket.Name}); err == nil {
+		result.LifecycleRules = lifecycle.Rules
+	} else if !isErrorCode(err, "NoSuchLifecycleConfiguration") {
+		logger.Errorf("failed to get lifecycle of bucket %s: %v", *bucket.Name, err)
+	}
+
+	return result
+}
+
+func isErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsmanagerTypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// secretsManagerSecrets scrapes the metadata of secrets, values are never read
+func (aws Scraper) secretsManagerSecrets(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Secret") {
+		return
+	}
+	SecretsManager := ctx.client("secretsmanager", func() interface{} { return secretsmanager.NewFromConfig(*ctx.Session) }).(*secretsmanager.Client)
+	paginator := secretsmanager.NewListSecretsPaginator(SecretsManager, &secretsmanager.ListSecretsInput{})
+	for paginator.HasMorePages() {
+		secrets, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list secrets")
+			return
+		}
+		for _, secret := range secrets.SecretList {
+			tags := make(v1.JSONStringMap)
+			for _, tag := range secret.Tags {
+				tags[*tag.Key] = deref(tag.Value)
+			}
+
+			// secrets without a key are encrypted by the aws/secretsmanager key of the account
+			key := deref(secret.KmsKeyId)
+			if key == "" {
+				key = "alias/aws/secretsmanager"
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSSecretsManagerSecret,
+				CreatedAt:          secret.CreatedDate,
+				Tags:               tags,
+				BaseScraper:        config.BaseScraper,
+				Config:             secret,
+				Type:               "Secret",
+				Name:               *secret.Name,
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{*secret.Name},
+				ID:                 *secret.ARN,
+				Ignore:             []string{"CreatedDate", "LastAccessedDate", "SecretVersionsToStages"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+				RelationshipResults: encryptedBy(v1.ExternalID{
+					ExternalID:   []string{*secret.ARN},
+					ExternalType: v1.AWSSecretsManagerSecret,
+				}, key, ctx.Session.Region, *ctx.Caller.Account),
+			})
+		}
+	}
+}
+
+// ssmParameters scrapes the metadata of parameters, values are never read
+func (aws Scraper) ssmParameters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Parameter") {
+		return
+	}
+	paginator := ssm.NewDescribeParametersPaginator(ctx.SSM, &ssm.DescribeParametersInput{})
+	for paginator.HasMorePages() {
+		parameters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe ssm parameters")
+			return
+		}
+		for _, parameter := range parameters.Parameters {
+			arn := fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/%s", ctx.Session.Region, *ctx.Caller.Account, strings.TrimPrefix(*parameter.Name, "/"))
+			var relationships v1.RelationshipResults
+			if parameter.Type == ssmTypes.ParameterTypeSecureString {
+				relationships = encryptedBy(v1.ExternalID{
+					ExternalID:   []string{arn},
+					ExternalType: v1.AWSSSMParameter,
+				}, deref(parameter.KeyId), ctx.Session.Region, *ctx.Caller.Account)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSSSMParameter,
+				BaseScraper:         config.BaseScraper,
+				Config:              parameter,
+				Type:                "Parameter",
+				Name:                *parameter.Name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*parameter.Name},
+				ID:                  arn,
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// secretLastRotated returns when the secret was last rotated, secrets that were never rotated were last changed
+// when their value was set
+func secretLastRotated(secret secretsmanagerTypes.SecretListEntry) *time.Time {
+	for _, date := range []*time.Time{secret.LastRotatedDate, secret.LastChangedDate, secret.CreatedDate} {
+		if date != nil {
+			return date
+		}
+	}
+	return nil
+}
+
+// analyzeSecretRotation returns a message when the secret was last rotated before the max age
+func analyzeSecretRotation(name string, rotated *time.Time, maxAge time.Duration) []string {
+	if rotated == nil || time.Since(*rotated) <= maxAge {
+		return nil
+	}
+	return []string{fmt.Sprintf("secret %s was not rotated in %d days", name, int(time.Since(*rotated).Hours()/24))}
+}
+
+// analyzeParameterRotation only reports SecureString parameters, other parameters are configuration and not secrets
+func analyzeParameterRotation(parameter ssmTypes.ParameterMetadata, maxAge time.Duration) []string {
+	if parameter.Type != ssmTypes.ParameterTypeSecureString {
+		return nil
+	}
+	return analyzeSecretRotation(*parameter.Name, parameter.LastModifiedDate, maxAge)
+}
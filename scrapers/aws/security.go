@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	secretsmanagerTypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// Security analyzers, category and severity are defined in scrapers/analysis/rules.yaml
+const (
+	s3BucketPublic            = "s3-bucket-public"
+	securityGroupOpenToWorld  = "security-group-open-to-world"
+	ebsVolumeUnencrypted      = "ebs-volume-unencrypted"
+	rdsInstanceUnencrypted    = "rds-instance-unencrypted"
+	iamPolicyWildcard         = "iam-policy-wildcard"
+	secretNotRotated          = "secret-not-rotated"
+	openSearchDomainPublic    = "opensearch-domain-public"
+	securityAnalysisExclusion = "security_analysis"
+)
+
+// commonly exposed web ports that are not reported when open to the world
+var publicPorts = map[int32]bool{80: true, 443: true}
+
+// securityAnalysis evaluates the scraped items against the built-in security rules,
+// each rule can be disabled by adding its name to the exclude list
+func (aws Scraper) securityAnalysis(config v1.AWS, scraped []v1.ScrapeResult, results *v1.ScrapeResults) {
+	if config.Excludes(securityAnalysisExclusion) {
+		return
+	}
+
+	analyze := func(analyzer string, item v1.ScrapeResult, messages []string) {
+		if len(messages) == 0 || config.Excludes(analyzer) {
+			return
+		}
+		analysis := results.Analysis(analyzer, item.ExternalType, item.ID)
+		analysis.Summary = messages[0]
+		for _, msg := range messages {
+			analysis.Message(msg)
+		}
+	}
+
+	for _, item := range scraped {
+		switch c := item.Config.(type) {
+		case S3Bucket:
+			analyze(s3BucketPublic, item, analyzeS3Bucket(c))
+		case SecurityGroup:
+			analyze(securityGroupOpenToWorld, item, analyzeSecurityGroup(c))
+		case ec2Types.Volume:
+			if c.Encrypted == nil || !*c.Encrypted {
+				analyze(ebsVolumeUnencrypted, item, []string{fmt.Sprintf("volume %s is not encrypted", item.ID)})
+			}
+		case rdsTypes.DBInstance:
+			if !c.StorageEncrypted {
+				analyze(rdsInstanceUnencrypted, item, []string{fmt.Sprintf("database %s storage is not encrypted", item.ID)})
+			}
+		case IAMManagedPolicy:
+			analyze(iamPolicyWildcard, item, wildcardStatements(c.PolicyName, c.Document))
+		case IAMRole:
+			analyze(iamPolicyWildcard, item, inlineWildcards(c.InlinePolicies))
+		case IAMUser:
+			analyze(iamPolicyWildcard, item, inlineWildcards(c.InlinePolicies))
+		case IAMGroup:
+			analyze(iamPolicyWildcard, item, inlineWildcards(c.InlinePolicies))
+		case secretsmanagerTypes.SecretListEntry:
+			analyze(secretNotRotated, item, analyzeSecretRotation(*c.Name, secretLastRotated(c), config.SecretRotation.GetMaxAge()))
+		case ssmTypes.ParameterMetadata:
+			analyze(secretNotRotated, item, analyzeParameterRotation(c, config.SecretRotation.GetMaxAge()))
+		case OpenSearchDomain:
+			analyze(openSearchDomainPublic, item, analyzeOpenSearchDomain(c))
+		}
+	}
+}
+
+func analyzeS3Bucket(bucket S3Bucket) []string {
+	if !bucket.IsPublic {
+		return nil
+	}
+	block := bucket.PublicAccessBlock
+	if block != nil && block.RestrictPublicBuckets {
+		// public policies are ignored when public buckets are restricted
+		return nil
+	}
+	return []string{fmt.Sprintf("bucket %s has a public bucket policy", bucket.Name)}
+}
+
+func analyzeSecurityGroup(sg SecurityGroup) []string {
+	// the open CIDRs of the rules with the same protocol and ports are reported together
+	var keys []string
+	open := make(map[string][]string)
+	for _, rule := range sg.Ingress {
+		if rule.CIDR != "0.0.0.0/0" && rule.CIDR != "::/0" {
+			continue
+		}
+		var key string
+		switch {
+		case rule.Protocol == "all":
+			key = "all traffic"
+		case rule.FromPort == nil || rule.ToPort == nil:
+			continue
+		case *rule.FromPort == *rule.ToPort && publicPorts[*rule.FromPort]:
+			continue
+		default:
+			key = rule.Protocol + "/" + rule.Ports()
+		}
+		if open[key] == nil {
+			keys = append(keys, key)
+		}
+		open[key] = append(open[key], rule.CIDR)
+	}
+
+	var messages []string
+	for _, key := range keys {
+		messages = append(messages, fmt.Sprintf("%s is allowed from %s", key, strings.Join(open[key], ",")))
+	}
+	return messages
+}
+
+func inlineWildcards(policies []IAMPolicy) []string {
+	var messages []string
+	for _, policy := range policies {
+		messages = append(messages, wildcardStatements(policy.PolicyName, policy.Document)...)
+	}
+	return messages
+}
+
+// wildcardStatements returns a message for every statement that allows all actions on all resources
+func wildcardStatements(name string, document PolicyDocument) []string {
+	var messages []string
+	for _, statement := range document.Statements() {
+		if statement["Effect"] != "Allow" {
+			continue
+		}
+		if containsWildcard(statement["Action"]) && containsWildcard(statement["Resource"]) {
+			id := name
+			if sid, _ := statement["Sid"].(string); sid != "" {
+				id += "/" + sid
+			}
+			messages = append(messages, fmt.Sprintf("policy %s allows * on *", id))
+		}
+	}
+	return messages
+}
+
+func containsWildcard(values interface{}) bool {
+	list, _ := values.([]interface{})
+	for _, v := range list {
+		if v == "*" || v == "*:*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// SecurityGroup is a security group with its permissions flattened into one rule per peer, so that adding or
+// removing a single CIDR or group shows up as a single rule in the changes of the group
+type SecurityGroup struct {
+	GroupID     string              `json:"GroupId"`
+	GroupName   string              `json:"GroupName"`
+	Description string              `json:"Description,omitempty"`
+	OwnerID     string              `json:"OwnerId,omitempty"`
+	VpcID       string              `json:"VpcId,omitempty"`
+	Ingress     []SecurityGroupRule `json:"Ingress"`
+	Egress      []SecurityGroupRule `json:"Egress"`
+}
+
+// SecurityGroupRule allows the traffic of a protocol and port range from (ingress) or to (egress) a single peer,
+// exactly one of CIDR, PrefixList or Group is set
+type SecurityGroupRule struct {
+	// Protocol is tcp, udp, icmp, icmpv6, all or the protocol number
+	Protocol string `json:"Protocol"`
+	// FromPort and ToPort are not set for all ports, for icmp they are the type and code
+	FromPort    *int32                  `json:"FromPort,omitempty"`
+	ToPort      *int32                  `json:"ToPort,omitempty"`
+	CIDR        string                  `json:"CIDR,omitempty"`
+	PrefixList  string                  `json:"PrefixList,omitempty"`
+	Group       *SecurityGroupReference `json:"Group,omitempty"`
+	Description string                  `json:"Description,omitempty"`
+}
+
+// SecurityGroupReference is a security group referenced by a rule, the group can be in a peered vpc or another account
+type SecurityGroupReference struct {
+	GroupID                string `json:"GroupId,omitempty"`
+	GroupName              string `json:"GroupName,omitempty"`
+	UserID                 string `json:"UserId,omitempty"`
+	VpcID                  string `json:"VpcId,omitempty"`
+	VpcPeeringConnectionID string `json:"VpcPeeringConnectionId,omitempty"`
+}
+
+// Ports returns the port range of the rule, e.g. 22 or 1024-2048, or empty for all ports
+func (r SecurityGroupRule) Ports() string {
+	if r.FromPort == nil || r.ToPort == nil {
+		return ""
+	}
+	if *r.FromPort == *r.ToPort {
+		return fmt.Sprintf("%d", *r.FromPort)
+	}
+	return fmt.Sprintf("%d-%d", *r.FromPort, *r.ToPort)
+}
+
+func (r SecurityGroupRule) peer() string {
+	switch {
+	case r.CIDR != "":
+		return r.CIDR
+	case r.PrefixList != "":
+		return r.PrefixList
+	case r.Group != nil:
+		return r.Group.UserID + "/" + r.Group.GroupID
+	}
+	return ""
+}
+
+func newSecurityGroup(sg ec2Types.SecurityGroup) SecurityGroup {
+	return SecurityGroup{
+		GroupID:     *sg.GroupId,
+		GroupName:   deref(sg.GroupName),
+		Description: deref(sg.Description),
+		OwnerID:     deref(sg.OwnerId),
+		VpcID:       deref(sg.VpcId),
+		Ingress:     securityGroupRules(sg.IpPermissions),
+		Egress:      securityGroupRules(sg.IpPermissionsEgress),
+	}
+}
+
+// securityGroupRules flattens the permissions into rules sorted by protocol, ports and peer
+func securityGroupRules(permissions []ec2Types.IpPermission) []SecurityGroupRule {
+	rules := []SecurityGroupRule{}
+	for _, permission := range permissions {
+		rule := SecurityGroupRule{Protocol: deref(permission.IpProtocol)}
+		switch rule.Protocol {
+		case "-1":
+			rule.Protocol = "all"
+		case "6":
+			rule.Protocol = "tcp"
+		case "17":
+			rule.Protocol = "udp"
+		case "1":
+			rule.Protocol = "icmp"
+		case "58":
+			rule.Protocol = "icmpv6"
+		}
+		// the ports of all protocols are -1 or unset, as is a port of -1 for the icmp types and codes
+		if rule.Protocol != "all" {
+			if permission.FromPort != nil && *permission.FromPort != -1 {
+				rule.FromPort = permission.FromPort
+			}
+			if permission.ToPort != nil && *permission.ToPort != -1 {
+				rule.ToPort = permission.ToPort
+			}
+		}
+
+		for _, r := range permission.IpRanges {
+			peer := rule
+			peer.CIDR, peer.Description = deref(r.CidrIp), deref(r.Description)
+			rules = append(rules, peer)
+		}
+		for _, r := range permission.Ipv6Ranges {
+			peer := rule
+			peer.CIDR, peer.Description = deref(r.CidrIpv6), deref(r.Description)
+			rules = append(rules, peer)
+		}
+		for _, p := range permission.PrefixListIds {
+			peer := rule
+			peer.PrefixList, peer.Description = deref(p.PrefixListId), deref(p.Description)
+			rules = append(rules, peer)
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			peer := rule
+			peer.Description = deref(pair.Description)
+			peer.Group = &SecurityGroupReference{
+				GroupID:                deref(pair.GroupId),
+				GroupName:              deref(pair.GroupName),
+				UserID:                 deref(pair.UserId),
+				VpcID:                  deref(pair.VpcId),
+				VpcPeeringConnectionID: deref(pair.VpcPeeringConnectionId),
+			}
+			rules = append(rules, peer)
+		}
+	}
+
+	port := func(p *int32) int32 {
+		if p == nil {
+			return -1
+		}
+		return *p
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if port(a.FromPort) != port(b.FromPort) {
+			return port(a.FromPort) < port(b.FromPort)
+		}
+		if port(a.ToPort) != port(b.ToPort) {
+			return port(a.ToPort) < port(b.ToPort)
+		}
+		return a.peer() < b.peer()
+	})
+	return rules
+}
+
+// securityGroupReferences relates the security groups that are allowed to send traffic to each other, an ingress
+// rule of a group referencing another group relates the other group to it, an egress rule relates it to the other group
+func securityGroupReferences(sg SecurityGroup) v1.RelationshipResults {
+	id := v1.ExternalID{ExternalID: []string{sg.GroupID}, ExternalType: v1.AWSEC2SecurityGroup}
+	var relationships v1.RelationshipResults
+	seen := make(map[string]bool)
+	add := func(rules []SecurityGroupRule, relationship string) {
+		for _, rule := range rules {
+			if rule.Group == nil || rule.Group.GroupID == "" || rule.Group.GroupID == sg.GroupID {
+				continue
+			}
+			if seen[relationship+rule.Group.GroupID] {
+				continue
+			}
+			seen[relationship+rule.Group.GroupID] = true
+			peer := v1.ExternalID{ExternalID: []string{rule.Group.GroupID}, ExternalType: v1.AWSEC2SecurityGroup}
+			result := v1.RelationshipResult{ConfigExternalID: peer, RelatedExternalID: id, Relationship: relationship}
+			if relationship == "SecurityGroupEgress" {
+				result.ConfigExternalID, result.RelatedExternalID = id, peer
+			}
+			relationships = append(relationships, result)
+		}
+	}
+	add(sg.Ingress, "SecurityGroupIngress")
+	add(sg.Egress, "SecurityGroupEgress")
+	return relationships
+}
+
+func (aws Scraper) securityGroups(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("SecurityGroup") {
+		return
+	}
+	groups := ec2.NewDescribeSecurityGroupsPaginator(ctx.EC2, &ec2.DescribeSecurityGroupsInput{})
+	for groups.HasMorePages() {
+		output, err := groups.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe security groups")
+			return
+		}
+		for _, group := range output.SecurityGroups {
+			sg := newSecurityGroup(group)
+			tags := getTags(group.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2SecurityGroup,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              sg,
+				Type:                "SecurityGroup",
+				Network:             sg.VpcID,
+				Name:                getName(tags, sg.GroupID),
+				Account:             *ctx.Caller.Account,
+				ID:                  sg.GroupID,
+				ParentExternalID:    sg.VpcID,
+				ParentExternalType:  v1.AWSEC2VPC,
+				RelationshipResults: securityGroupReferences(sg),
+			})
+		}
+	}
+}
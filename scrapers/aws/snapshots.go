@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go/ptr"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+)
+
+// Waste analyzers, category and severity are defined in scrapers/analysis/rules.yaml
+const (
+	ebsVolumeUnattached  = "ebs-volume-unattached"
+	ebsSnapshotOrphaned  = "ebs-snapshot-orphaned"
+	wasteAnalysisExclude = "waste_analysis"
+)
+
+// ebsSnapshots scrapes the snapshots owned by the account, public and shared snapshots are not scraped
+func (aws Scraper) ebsSnapshots(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("EBSSnapshot") {
+		return
+	}
+	paginator := ec2.NewDescribeSnapshotsPaginator(ctx.EC2, &ec2.DescribeSnapshotsInput{OwnerIds: []string{"self"}})
+	for paginator.HasMorePages() {
+		snapshots, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe ebs snapshots")
+			return
+		}
+		for _, snapshot := range snapshots.Snapshots {
+			tags := getTags(snapshot.Tags)
+			self := v1.ExternalID{ExternalID: []string{*snapshot.SnapshotId}, ExternalType: v1.AWSEBSSnapshot}
+			var relationships v1.RelationshipResults
+			// snapshots copied from another snapshot have the placeholder volume vol-ffffffff
+			if volume := deref(snapshot.VolumeId); volume != "" && volume != "vol-ffffffff" {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{volume},
+						ExternalType: v1.AWSEBSVolume,
+					},
+					RelatedExternalID: self,
+					Relationship:      "VolumeSnapshot",
+				})
+			}
+			relationships = append(relationships, encryptedBy(self, deref(snapshot.KmsKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEBSSnapshot,
+				CreatedAt:           snapshot.StartTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              snapshot,
+				Type:                "EBSSnapshot",
+				Name:                getName(tags, *snapshot.SnapshotId),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{"AmazonEC2/" + *snapshot.SnapshotId},
+				ID:                  *snapshot.SnapshotId,
+				Ignore:              []string{"Progress", "StartTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// ami scrapes the images owned by the account, without the owner filter every public image is returned
+func (aws Scraper) ami(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Images") {
+		return
+	}
+	// DescribeImages of ec2 v1.25.0 has no NextToken and returns every image in a single response, it can be replaced
+	// with ec2.NewDescribeImagesPaginator once the ec2 client is upgraded
+	amis, err := ctx.EC2.DescribeImages(ctx, &ec2.DescribeImagesInput{Owners: []string{"self"}})
+	if err != nil {
+		results.Errorf(err, "failed to get amis")
+		return
+	}
+
+	for _, image := range amis.Images {
+		createdAt, err := time.Parse(time.RFC3339, *image.CreationDate)
+		if err != nil {
+			createdAt = time.Now()
+		}
+
+		self := v1.ExternalID{ExternalID: []string{*image.ImageId}, ExternalType: v1.AWSEC2AMI}
+		var relationships v1.RelationshipResults
+		for _, device := range image.BlockDeviceMappings {
+			if device.Ebs == nil || device.Ebs.SnapshotId == nil {
+				continue
+			}
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID: self,
+				RelatedExternalID: v1.ExternalID{
+					ExternalID:   []string{*device.Ebs.SnapshotId},
+					ExternalType: v1.AWSEBSSnapshot,
+				},
+				Relationship: "AMISnapshot",
+			})
+		}
+
+		tags := getTags(image.Tags)
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType:        v1.AWSEC2AMI,
+			CreatedAt:           &createdAt,
+			Tags:                tags,
+			BaseScraper:         config.BaseScraper,
+			Config:              image,
+			Type:                "Image",
+			Name:                getName(tags, ptr.ToString(image.Name)),
+			Account:             *ctx.Caller.Account,
+			Region:              ctx.Session.Region,
+			ID:                  *image.ImageId,
+			ParentExternalID:    *ctx.Caller.Account,
+			ParentExternalType:  v1.AWSAccount,
+			RelationshipResults: relationships,
+		})
+	}
+}
+
+// wasteAnalysis reports volumes that are not attached to an instance and snapshots whose volume was deleted and that
+// no image is created from, together with what they cost over the last 30 days
+func (aws Scraper) wasteAnalysis(ctx *v1.ScrapeContext, config v1.AWS, scraped []v1.ScrapeResult, results *v1.ScrapeResults) {
+	if config.Excludes(wasteAnalysisExclude) || !config.Includes("EBS") || !config.Includes("EBSSnapshot") || !config.Includes("Images") {
+		return
+	}
+
+	volumes := make(map[string]bool)
+	imageSnapshots := make(map[string]bool)
+	for _, item := range scraped {
+		switch c := item.Config.(type) {
+		case types.Volume:
+			volumes[item.ID] = true
+		case types.Image:
+			for _, device := range c.BlockDeviceMappings {
+				if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+					imageSnapshots[*device.Ebs.SnapshotId] = true
+				}
+			}
+		}
+	}
+
+	analyze := func(analyzer string, item v1.ScrapeResult, message string) {
+		if config.Excludes(analyzer) {
+			return
+		}
+		cost := db.GetCostTotal30d(v1.ExternalID{ExternalID: []string{item.ID}, ExternalType: item.ExternalType}, ctx.Tenant)
+		if cost > 0 {
+			message = fmt.Sprintf("%s, costing $%.2f over the last 30 days", message, cost)
+		}
+		analysis := results.Analysis(analyzer, item.ExternalType, item.ID)
+		analysis.AnalysisType = "cost"
+		analysis.Summary = message
+		analysis.Message(message)
+		analysis.Analysis = map[string]string{"cost_total_30d": fmt.Sprintf("%.2f", cost)}
+	}
+
+	for _, item := range scraped {
+		switch c := item.Config.(type) {
+		case types.Volume:
+			if c.State == types.VolumeStateAvailable {
+				analyze(ebsVolumeUnattached, item, fmt.Sprintf("volume %s is not attached to any instance", item.ID))
+			}
+		case types.Snapshot:
+			if !volumes[deref(c.VolumeId)] && !imageSnapshots[item.ID] {
+				analyze(ebsSnapshotOrphaned, item, fmt.Sprintf("snapshot %s is of a deleted volume and not used by any image", item.ID))
+			}
+		}
+	}
+}
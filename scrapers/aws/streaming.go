@@ -0,0 +1,267 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/kafka"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// StreamConsumers are the functions that consume a Kinesis stream or MSK cluster, keyed by the ARN of the source
+type StreamConsumers map[string][]string
+
+// streamConsumers returns the functions with an event source mapping for each source
+func (ctx *AWSContext) streamConsumers(results *v1.ScrapeResults) StreamConsumers {
+	consumers := make(StreamConsumers)
+	Lambda := ctx.client("lambda", func() interface{} { return lambda.NewFromConfig(*ctx.Session) }).(*lambda.Client)
+	paginator := lambda.NewListEventSourceMappingsPaginator(Lambda, &lambda.ListEventSourceMappingsInput{})
+	for paginator.HasMorePages() {
+		mappings, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list lambda event source mappings")
+			break
+		}
+		for _, mapping := range mappings.EventSourceMappings {
+			source, function := deref(mapping.EventSourceArn), getLambdaFunctionArn(deref(mapping.FunctionArn))
+			if source != "" && function != "" {
+				consumers[source] = append(consumers[source], function)
+			}
+		}
+	}
+	return consumers
+}
+
+// relationships returns a relationship from the source to each of its consuming functions
+func (c StreamConsumers) relationships(source v1.ExternalID) v1.RelationshipResults {
+	var relationships v1.RelationshipResults
+	for _, function := range c[source.ExternalID[0]] {
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  source,
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{function}, ExternalType: v1.AWSLambdaFunction},
+			Relationship:      "StreamConsumer",
+		})
+	}
+	return relationships
+}
+
+func (aws Scraper) kinesisStreams(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Kinesis") {
+		return
+	}
+	Kinesis := ctx.client("kinesis", func() interface{} { return kinesis.NewFromConfig(*ctx.Session) }).(*kinesis.Client)
+	consumers := ctx.streamConsumers(results)
+
+	input := &kinesis.ListStreamsInput{}
+	for {
+		streams, err := Kinesis.ListStreams(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to list kinesis streams")
+			return
+		}
+		for _, name := range streams.StreamNames {
+			output, err := Kinesis.DescribeStreamSummary(ctx, &kinesis.DescribeStreamSummaryInput{StreamName: strPtr(name)})
+			if err != nil {
+				results.Errorf(err, "failed to describe kinesis stream %s", name)
+				continue
+			}
+			stream := output.StreamDescriptionSummary
+
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := Kinesis.ListTagsForStream(ctx, &kinesis.ListTagsForStreamInput{StreamName: strPtr(name)}); err != nil {
+				results.Errorf(err, "failed to get tags of kinesis stream %s", name)
+			} else {
+				for _, tag := range tagsOutput.Tags {
+					tags[*tag.Key] = deref(tag.Value)
+				}
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*stream.StreamARN}, ExternalType: v1.AWSKinesisStream}
+			relationships := consumers.relationships(self)
+			relationships = append(relationships, encryptedBy(self, deref(stream.KeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSKinesisStream,
+				CreatedAt:           stream.StreamCreationTimestamp,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              stream,
+				Type:                "KinesisStream",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{name, "AmazonKinesis/" + *stream.StreamARN},
+				ID:                  *stream.StreamARN,
+				Ignore:              []string{"StreamCreationTimestamp"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+		if streams.HasMoreStreams == nil || !*streams.HasMoreStreams || len(streams.StreamNames) == 0 {
+			return
+		}
+		input.ExclusiveStartStreamName = strPtr(streams.StreamNames[len(streams.StreamNames)-1])
+	}
+}
+
+// firehoses scrapes delivery streams with relationships to the stream they read from and the buckets and domains
+// they deliver to
+func (aws Scraper) firehoses(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Firehose") {
+		return
+	}
+	Firehose := ctx.client("firehose", func() interface{} { return firehose.NewFromConfig(*ctx.Session) }).(*firehose.Client)
+
+	input := &firehose.ListDeliveryStreamsInput{}
+	for {
+		streams, err := Firehose.ListDeliveryStreams(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to list firehose delivery streams")
+			return
+		}
+		for _, name := range streams.DeliveryStreamNames {
+			output, err := Firehose.DescribeDeliveryStream(ctx, &firehose.DescribeDeliveryStreamInput{DeliveryStreamName: strPtr(name)})
+			if err != nil {
+				results.Errorf(err, "failed to describe firehose delivery stream %s", name)
+				continue
+			}
+			stream := output.DeliveryStreamDescription
+
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := Firehose.ListTagsForDeliveryStream(ctx, &firehose.ListTagsForDeliveryStreamInput{DeliveryStreamName: strPtr(name)}); err != nil {
+				results.Errorf(err, "failed to get tags of firehose delivery stream %s", name)
+			} else {
+				for _, tag := range tagsOutput.Tags {
+					tags[*tag.Key] = deref(tag.Value)
+				}
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*stream.DeliveryStreamARN}, ExternalType: v1.AWSKinesisFirehose}
+			var relationships v1.RelationshipResults
+			if stream.Source != nil && stream.Source.KinesisStreamSourceDescription != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{deref(stream.Source.KinesisStreamSourceDescription.KinesisStreamARN)}, ExternalType: v1.AWSKinesisStream},
+					RelatedExternalID: self,
+					Relationship:      "StreamConsumer",
+				})
+			}
+			for _, destination := range stream.Destinations {
+				var bucket, domain string
+				switch {
+				case destination.ExtendedS3DestinationDescription != nil:
+					bucket = deref(destination.ExtendedS3DestinationDescription.BucketARN)
+				case destination.S3DestinationDescription != nil:
+					bucket = deref(destination.S3DestinationDescription.BucketARN)
+				case destination.AmazonopensearchserviceDestinationDescription != nil:
+					domain = deref(destination.AmazonopensearchserviceDestinationDescription.DomainARN)
+				case destination.ElasticsearchDestinationDescription != nil:
+					domain = deref(destination.ElasticsearchDestinationDescription.DomainARN)
+				}
+				if bucket != "" {
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  self,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{strings.TrimPrefix(bucket, "arn:aws:s3:::")}, ExternalType: v1.AWSS3Bucket},
+						Relationship:      "FirehoseDestination",
+					})
+				}
+				if domain != "" {
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  self,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{domain}, ExternalType: v1.AWSOpenSearchDomain},
+						Relationship:      "FirehoseDestination",
+					})
+				}
+			}
+			if encryption := stream.DeliveryStreamEncryptionConfiguration; encryption != nil {
+				relationships = append(relationships, encryptedBy(self, deref(encryption.KeyARN), ctx.Session.Region, *ctx.Caller.Account)...)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSKinesisFirehose,
+				CreatedAt:           stream.CreateTimestamp,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              stream,
+				Type:                "Firehose",
+				Name:                name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{name, "AmazonKinesisFirehose/" + *stream.DeliveryStreamARN},
+				ID:                  *stream.DeliveryStreamARN,
+				Ignore:              []string{"CreateTimestamp", "LastUpdateTimestamp"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+		if streams.HasMoreDeliveryStreams == nil || !*streams.HasMoreDeliveryStreams || len(streams.DeliveryStreamNames) == 0 {
+			return
+		}
+		input.ExclusiveStartDeliveryStreamName = strPtr(streams.DeliveryStreamNames[len(streams.DeliveryStreamNames)-1])
+	}
+}
+
+// mskClusters scrapes provisioned MSK clusters with their broker configuration
+func (aws Scraper) mskClusters(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("MSK") {
+		return
+	}
+	Kafka := ctx.client("kafka", func() interface{} { return kafka.NewFromConfig(*ctx.Session) }).(*kafka.Client)
+	consumers := ctx.streamConsumers(results)
+
+	paginator := kafka.NewListClustersPaginator(Kafka, &kafka.ListClustersInput{})
+	for paginator.HasMorePages() {
+		clusters, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list msk clusters")
+			return
+		}
+		for _, cluster := range clusters.ClusterInfoList {
+			tags := make(v1.JSONStringMap)
+			for k, v := range cluster.Tags {
+				tags[k] = v
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*cluster.ClusterArn}, ExternalType: v1.AWSMSKCluster}
+			relationships := consumers.relationships(self)
+			var subnet string
+			if brokers := cluster.BrokerNodeGroupInfo; brokers != nil {
+				if len(brokers.ClientSubnets) > 0 {
+					subnet = brokers.ClientSubnets[0]
+				}
+				for _, group := range brokers.SecurityGroups {
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  self,
+						RelatedExternalID: v1.ExternalID{ExternalID: []string{group}, ExternalType: v1.AWSEC2SecurityGroup},
+						Relationship:      "MSKClusterSecurityGroup",
+					})
+				}
+			}
+			if encryption := cluster.EncryptionInfo; encryption != nil && encryption.EncryptionAtRest != nil {
+				relationships = append(relationships, encryptedBy(self, deref(encryption.EncryptionAtRest.DataVolumeKMSKeyId), ctx.Session.Region, *ctx.Caller.Account)...)
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSMSKCluster,
+				CreatedAt:           cluster.CreationTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              cluster,
+				Type:                "MSKCluster",
+				Name:                *cluster.ClusterName,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Subnet:              subnet,
+				Aliases:             []string{*cluster.ClusterName, "AmazonMSK/" + *cluster.ClusterArn},
+				ID:                  *cluster.ClusterArn,
+				Ignore:              []string{"ActiveOperationArn", "StateInfo"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// triggerMessage is an EventBridge event with a list of resources, or a message naming a single ARN
+type triggerMessage struct {
+	Resources []string `json:"resources"`
+	ARN       string   `json:"arn"`
+}
+
+// ReceiveTrigger long polls the trigger queue and returns whether a scrape was triggered and the ARNs to scrape,
+// no ARNs are returned when any message asks for a full scrape
+func ReceiveTrigger(ctx *v1.ScrapeContext, trigger v1.SQSTrigger) (bool, []string, error) {
+	session, err := NewSession(ctx, trigger.AWSConnection, queueRegion(trigger.QueueURL))
+	if err != nil {
+		return false, nil, err
+	}
+	client := sqs.NewFromConfig(*session)
+
+	output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            &trigger.QueueURL,
+		MaxNumberOfMessages: 10,
+		WaitTimeSeconds:     20,
+	})
+	if err != nil || len(output.Messages) == 0 {
+		return false, nil, err
+	}
+
+	var targets []string
+	full := false
+	var entries []sqsTypes.DeleteMessageBatchRequestEntry
+	for i, message := range output.Messages {
+		var msg triggerMessage
+		if err := json.Unmarshal([]byte(deref(message.Body)), &msg); err != nil {
+			full = true
+		}
+		if msg.ARN != "" {
+			msg.Resources = append(msg.Resources, msg.ARN)
+		}
+		if len(msg.Resources) == 0 {
+			full = true
+		}
+		targets = append(targets, msg.Resources...)
+		entries = append(entries, sqsTypes.DeleteMessageBatchRequestEntry{
+			Id:            strPtr(fmt.Sprintf("%d", i)),
+			ReceiptHandle: message.ReceiptHandle,
+		})
+	}
+	if _, err := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: &trigger.QueueURL, Entries: entries}); err != nil {
+		return true, nil, err
+	}
+	if full {
+		return true, nil, nil
+	}
+	return true, targets, nil
+}
+
+// targetChanges returns the services to scrape for the ARNs, ARNs without an account (e.g. S3 buckets) match every account
+func targetChanges(arns []string) changedServices {
+	changed := make(changedServices)
+	for _, arn := range arns {
+		parts := strings.SplitN(arn, ":", 6)
+		if len(parts) < 6 || parts[0] != "arn" {
+			continue
+		}
+		account := parts[4]
+		if account == "" {
+			account = anyAccount
+		}
+		changed.add(account, parts[3], parts[2])
+	}
+	return changed
+}
@@ -1,6 +1,8 @@
 package aws
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/support"
@@ -60,8 +62,8 @@ func (aws Scraper) trustedAdvisor(ctx *AWSContext, config v1.AWS, results *v1.Sc
 			CheckId:  check.Id,
 		})
 		if err != nil {
-			results.Errorf(err, "Failed to describe trusted advisor check result")
-			return
+			results.Errorf(err, "Failed to describe trusted advisor check result of %s", *check.Name)
+			continue
 		}
 		if len(checks.Result.FlaggedResources) == 0 {
 			continue
@@ -90,6 +92,10 @@ func (aws Scraper) trustedAdvisor(ctx *AWSContext, config v1.AWS, results *v1.Sc
 					id = metadata["User Name (IAM or Root)"]
 					externalType = "AWS::IAM::User"
 					delete(metadata, "User Name (IAM or Root)")
+				} else if fn := getLambdaFunctionArn(metadata["Function ARN"]); fn != "" {
+					id = fn
+					externalType = v1.AWSLambdaFunction
+					delete(metadata, "Function ARN")
 				} else if metadata["Region"] != "" {
 					id = metadata["Region"]
 					externalType = "AWS::Region"
@@ -104,9 +110,10 @@ func (aws Scraper) trustedAdvisor(ctx *AWSContext, config v1.AWS, results *v1.Sc
 			analysis.Severity = mapSeverity(metadata["Status"])
 			delete(metadata, "Status")
 			analysis.Message(deref(check.Description))
+			addTrustedAdvisorSavings(metadata)
 			analysis.Analysis = metadata
 
-			logger.Infof("%s %s %s %v", *check.Name, externalType, id, metadata)
+			logger.Tracef("%s %s %s %v", *check.Name, externalType, id, metadata)
 		}
 	}
 }
@@ -124,3 +131,17 @@ func getMetadata(columns []string, values []string) (map[string]string, string)
 
 	return metadata, id
 }
+
+// addTrustedAdvisorSavings converts the estimated monthly savings of cost optimizing checks e.g. $12.34 to the
+// format of the savings of Compute Optimizer recommendations
+func addTrustedAdvisorSavings(metadata map[string]string) {
+	savings, ok := metadata["Estimated Monthly Savings"]
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(strings.NewReplacer("$", "", ",", "").Replace(strings.TrimSpace(savings)), 64)
+	if err != nil {
+		return
+	}
+	metadata["Estimated Monthly Savings"] = fmt.Sprintf("%.2f USD", value)
+}
@@ -0,0 +1,23 @@
+package aws
+
+import "testing"
+
+func TestTrustedAdvisorMetadata(t *testing.T) {
+	metadata, id := getMetadata(
+		[]string{"Region/AZ", "Instance ID", "Instance Name", "Estimated Monthly Savings"},
+		[]string{"eu-west-1a", "i-0123456789", "web", "$1,234.5"},
+	)
+	addTrustedAdvisorSavings(metadata)
+	if id != "i-0123456789" || getExternalTypeById(id) != "AWS::EC2::Instance" {
+		t.Errorf("expected the instance id, got %s", id)
+	}
+	if metadata["Estimated Monthly Savings"] != "1234.50 USD" {
+		t.Errorf("unexpected savings %s", metadata["Estimated Monthly Savings"])
+	}
+
+	metadata = map[string]string{"Estimated Monthly Savings": "n/a"}
+	addTrustedAdvisorSavings(metadata)
+	if metadata["Estimated Monthly Savings"] != "n/a" {
+		t.Errorf("expected unparsable savings to be kept, got %s", metadata["Estimated Monthly Savings"])
+	}
+}
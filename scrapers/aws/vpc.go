@@ -0,0 +1,424 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func (aws Scraper) vpcs(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("VPC") {
+		return
+	}
+	vpcs := ec2.NewDescribeVpcsPaginator(ctx.EC2, &ec2.DescribeVpcsInput{})
+	for vpcs.HasMorePages() {
+		output, err := vpcs.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get vpcs")
+			return
+		}
+		for _, vpc := range output.Vpcs {
+			var relationships v1.RelationshipResults
+			// DHCPOptions relationship
+			if vpc.DhcpOptionsId != nil {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{
+						ExternalID:   []string{*vpc.VpcId},
+						ExternalType: v1.AWSEC2VPC,
+					},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{*vpc.DhcpOptionsId},
+						ExternalType: v1.AWSEC2DHCPOptions,
+					},
+					Relationship: "VPCDHCPOptions",
+				})
+			}
+
+			tags := getTags(vpc.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2VPC,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              vpc,
+				Type:                "VPC",
+				Network:             *vpc.VpcId,
+				Name:                getName(tags, *vpc.VpcId),
+				Account:             *ctx.Caller.Account,
+				ID:                  *vpc.VpcId,
+				Aliases:             []string{"AmazonEC2/" + *vpc.VpcId},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+func (aws Scraper) subnets(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	// we always need to scrape subnets to get the zone for other resources
+	include := config.Includes("subnet")
+	subnets := ec2.NewDescribeSubnetsPaginator(ctx.EC2, &ec2.DescribeSubnetsInput{})
+	for subnets.HasMorePages() {
+		output, err := subnets.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to get subnets")
+			return
+		}
+		for _, subnet := range output.Subnets {
+			az := *subnet.AvailabilityZone
+			ctx.Subnets[*subnet.SubnetId] = Zone{Zone: az, Region: az[0 : len(az)-1]}
+
+			if !include {
+				continue
+			}
+			tags := getTags(subnet.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSEC2Subnet,
+				BaseScraper:        config.BaseScraper,
+				Tags:               tags,
+				Type:               "Subnet",
+				Name:               getName(tags, *subnet.SubnetId),
+				ID:                 *subnet.SubnetId,
+				Subnet:             *subnet.SubnetId,
+				Config:             subnet,
+				Account:            *ctx.Caller.Account,
+				Network:            *subnet.VpcId,
+				Zone:               az,
+				Region:             az[0 : len(az)-1],
+				ParentExternalID:   *subnet.VpcId,
+				ParentExternalType: v1.AWSEC2VPC,
+			})
+		}
+	}
+}
+
+func (aws Scraper) routes(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("Route") {
+		return
+	}
+	tables := ec2.NewDescribeRouteTablesPaginator(ctx.EC2, &ec2.DescribeRouteTablesInput{})
+	for tables.HasMorePages() {
+		output, err := tables.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe route tables")
+			return
+		}
+		for _, r := range output.RouteTables {
+			id := v1.ExternalID{ExternalID: []string{*r.RouteTableId}, ExternalType: v1.AWSEC2RouteTable}
+			var relationships v1.RelationshipResults
+			// the subnets whose traffic is routed by the table, subnets without an association use the main table of the vpc
+			for _, association := range r.Associations {
+				if association.SubnetId == nil {
+					continue
+				}
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*association.SubnetId}, ExternalType: v1.AWSEC2Subnet},
+					RelatedExternalID: id,
+					Relationship:      "SubnetRouteTable",
+				})
+			}
+			relationships = append(relationships, routeTargets(id, r.Routes)...)
+
+			tags := getTags(r.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2RouteTable,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              r,
+				Type:                "Route",
+				Network:             *r.VpcId,
+				Name:                getName(tags, *r.RouteTableId),
+				Account:             *ctx.Caller.Account,
+				ID:                  *r.RouteTableId,
+				ParentExternalID:    *r.VpcId,
+				ParentExternalType:  v1.AWSEC2VPC,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// routeTargets relates the route table to the gateways, peering connections and instances its routes send traffic to
+func routeTargets(table v1.ExternalID, routes []types.Route) v1.RelationshipResults {
+	var relationships v1.RelationshipResults
+	seen := make(map[string]bool)
+	add := func(target *string, externalType, relationship string) {
+		if target == nil || seen[*target] {
+			return
+		}
+		seen[*target] = true
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  table,
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{*target}, ExternalType: externalType},
+			Relationship:      relationship,
+		})
+	}
+	for _, route := range routes {
+		// the gateway of a route is also set to local for the routes within the vpc, or a virtual private gateway
+		if route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, "igw-") {
+			add(route.GatewayId, v1.AWSEC2InternetGateway, "RouteTableInternetGateway")
+		}
+		add(route.NatGatewayId, v1.AWSEC2NATGateway, "RouteTableNATGateway")
+		add(route.VpcPeeringConnectionId, v1.AWSEC2VPCPeeringConnection, "RouteTableVPCPeeringConnection")
+		add(route.TransitGatewayId, v1.AWSEC2TransitGateway, "RouteTableTransitGateway")
+		add(route.InstanceId, v1.AWSEC2Instance, "RouteTableInstance")
+	}
+	return relationships
+}
+
+func (aws Scraper) dhcp(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("DHCP") {
+		return
+	}
+	options := ec2.NewDescribeDhcpOptionsPaginator(ctx.EC2, &ec2.DescribeDhcpOptionsInput{})
+	for options.HasMorePages() {
+		output, err := options.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe dhcp options")
+			return
+		}
+		for _, d := range output.DhcpOptions {
+			tags := getTags(d.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType: v1.AWSEC2DHCPOptions,
+				Tags:         tags,
+				BaseScraper:  config.BaseScraper,
+				Config:       d,
+				Type:         "DHCP",
+				Name:         getName(tags, *d.DhcpOptionsId),
+				Account:      *ctx.Caller.Account,
+				ID:           *d.DhcpOptionsId,
+			})
+		}
+	}
+}
+
+func (aws Scraper) internetGateways(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("InternetGateway") {
+		return
+	}
+	gateways := ec2.NewDescribeInternetGatewaysPaginator(ctx.EC2, &ec2.DescribeInternetGatewaysInput{})
+	for gateways.HasMorePages() {
+		output, err := gateways.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe internet gateways")
+			return
+		}
+		for _, gateway := range output.InternetGateways {
+			id := v1.ExternalID{ExternalID: []string{*gateway.InternetGatewayId}, ExternalType: v1.AWSEC2InternetGateway}
+			result := v1.ScrapeResult{
+				ExternalType:       v1.AWSEC2InternetGateway,
+				BaseScraper:        config.BaseScraper,
+				Config:             gateway,
+				Type:               "InternetGateway",
+				Account:            *ctx.Caller.Account,
+				ID:                 *gateway.InternetGatewayId,
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			}
+			for _, attachment := range gateway.Attachments {
+				if attachment.VpcId == nil {
+					continue
+				}
+				// a gateway is attached to at most one vpc
+				result.Network = *attachment.VpcId
+				result.ParentExternalID, result.ParentExternalType = *attachment.VpcId, v1.AWSEC2VPC
+				result.RelationshipResults = append(result.RelationshipResults, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*attachment.VpcId}, ExternalType: v1.AWSEC2VPC},
+					RelatedExternalID: id,
+					Relationship:      "VPCInternetGateway",
+				})
+			}
+			result.Tags = getTags(gateway.Tags)
+			result.Name = getName(result.Tags, *gateway.InternetGatewayId)
+			*results = append(*results, result)
+		}
+	}
+}
+
+func (aws Scraper) natGateways(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("NATGateway") {
+		return
+	}
+	gateways := ec2.NewDescribeNatGatewaysPaginator(ctx.EC2, &ec2.DescribeNatGatewaysInput{})
+	for gateways.HasMorePages() {
+		output, err := gateways.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe nat gateways")
+			return
+		}
+		for _, gateway := range output.NatGateways {
+			// deleted gateways are still returned for about an hour
+			if gateway.State == types.NatGatewayStateDeleted {
+				continue
+			}
+			relationships := v1.RelationshipResults{{
+				ConfigExternalID:  v1.ExternalID{ExternalID: []string{*gateway.SubnetId}, ExternalType: v1.AWSEC2Subnet},
+				RelatedExternalID: v1.ExternalID{ExternalID: []string{*gateway.NatGatewayId}, ExternalType: v1.AWSEC2NATGateway},
+				Relationship:      "SubnetNATGateway",
+			}}
+
+			tags := getTags(gateway.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2NATGateway,
+				CreatedAt:           gateway.CreateTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              gateway,
+				Type:                "NATGateway",
+				Network:             *gateway.VpcId,
+				Subnet:              *gateway.SubnetId,
+				Zone:                ctx.Subnets[*gateway.SubnetId].Zone,
+				Region:              ctx.Session.Region,
+				Name:                getName(tags, *gateway.NatGatewayId),
+				Account:             *ctx.Caller.Account,
+				ID:                  *gateway.NatGatewayId,
+				Ignore:              []string{"CreateTime"},
+				ParentExternalID:    *gateway.VpcId,
+				ParentExternalType:  v1.AWSEC2VPC,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+func (aws Scraper) vpcPeering(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("VPCPeering") {
+		return
+	}
+	connections := ec2.NewDescribeVpcPeeringConnectionsPaginator(ctx.EC2, &ec2.DescribeVpcPeeringConnectionsInput{})
+	for connections.HasMorePages() {
+		output, err := connections.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe vpc peering connections")
+			return
+		}
+		for _, connection := range output.VpcPeeringConnections {
+			if connection.Status != nil && connection.Status.Code == types.VpcPeeringConnectionStateReasonCodeDeleted {
+				continue
+			}
+			id := v1.ExternalID{ExternalID: []string{*connection.VpcPeeringConnectionId}, ExternalType: v1.AWSEC2VPCPeeringConnection}
+			var relationships v1.RelationshipResults
+			// the vpcs on both sides of the connection, the accepter can be in another account or region
+			for _, vpc := range []*types.VpcPeeringConnectionVpcInfo{connection.RequesterVpcInfo, connection.AccepterVpcInfo} {
+				if vpc == nil || vpc.VpcId == nil {
+					continue
+				}
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*vpc.VpcId}, ExternalType: v1.AWSEC2VPC},
+					RelatedExternalID: id,
+					Relationship:      "VPCPeeringConnection",
+				})
+			}
+
+			tags := getTags(connection.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2VPCPeeringConnection,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              connection,
+				Type:                "VPCPeeringConnection",
+				Name:                getName(tags, *connection.VpcPeeringConnectionId),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *connection.VpcPeeringConnectionId,
+				Ignore:              []string{"ExpirationTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+func (aws Scraper) transitGateways(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("TransitGateway") {
+		return
+	}
+	gateways := ec2.NewDescribeTransitGatewaysPaginator(ctx.EC2, &ec2.DescribeTransitGatewaysInput{})
+	for gateways.HasMorePages() {
+		output, err := gateways.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe transit gateways")
+			return
+		}
+		for _, gateway := range output.TransitGateways {
+			if gateway.State == types.TransitGatewayStateDeleted {
+				continue
+			}
+			tags := getTags(gateway.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:       v1.AWSEC2TransitGateway,
+				CreatedAt:          gateway.CreationTime,
+				Tags:               tags,
+				BaseScraper:        config.BaseScraper,
+				Config:             gateway,
+				Type:               "TransitGateway",
+				Name:               getName(tags, *gateway.TransitGatewayId),
+				Account:            *ctx.Caller.Account,
+				Region:             ctx.Session.Region,
+				Aliases:            []string{*gateway.TransitGatewayArn},
+				ID:                 *gateway.TransitGatewayId,
+				Ignore:             []string{"CreationTime"},
+				ParentExternalID:   *ctx.Caller.Account,
+				ParentExternalType: v1.AWSAccount,
+			})
+		}
+	}
+
+	aws.transitGatewayAttachments(ctx, config, results)
+}
+
+// transitGatewayAttachments scrapes the attachments of the transit gateways, including the attachments of
+// gateways shared from other accounts to the vpcs of this account
+func (aws Scraper) transitGatewayAttachments(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	attachments := ec2.NewDescribeTransitGatewayAttachmentsPaginator(ctx.EC2, &ec2.DescribeTransitGatewayAttachmentsInput{})
+	for attachments.HasMorePages() {
+		output, err := attachments.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to describe transit gateway attachments")
+			return
+		}
+		for _, attachment := range output.TransitGatewayAttachments {
+			if attachment.State == types.TransitGatewayAttachmentStateDeleted {
+				continue
+			}
+			id := v1.ExternalID{ExternalID: []string{*attachment.TransitGatewayAttachmentId}, ExternalType: v1.AWSEC2TransitGatewayAttachment}
+			relationships := v1.RelationshipResults{{
+				ConfigExternalID:  v1.ExternalID{ExternalID: []string{*attachment.TransitGatewayId}, ExternalType: v1.AWSEC2TransitGateway},
+				RelatedExternalID: id,
+				Relationship:      "TransitGatewayAttachment",
+			}}
+			var network string
+			if attachment.ResourceType == types.TransitGatewayAttachmentResourceTypeVpc && attachment.ResourceId != nil {
+				network = *attachment.ResourceId
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{*attachment.ResourceId}, ExternalType: v1.AWSEC2VPC},
+					RelatedExternalID: id,
+					Relationship:      "VPCTransitGatewayAttachment",
+				})
+			}
+
+			tags := getTags(attachment.Tags)
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSEC2TransitGatewayAttachment,
+				CreatedAt:           attachment.CreationTime,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              attachment,
+				Type:                "TransitGatewayAttachment",
+				Network:             network,
+				Name:                getName(tags, *attachment.TransitGatewayAttachmentId),
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				ID:                  *attachment.TransitGatewayAttachmentId,
+				Ignore:              []string{"CreationTime"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
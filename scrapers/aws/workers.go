@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+const defaultMaxConcurrency = 10
+
+// scrapeTask scrapes a single service of a region into its own results
+type scrapeTask struct {
+	name string
+	fn   func(results *v1.ScrapeResults)
+}
+
+// runTasks executes the tasks with at most concurrency running at a time and appends their results
+// in task order, a failing or panicking task only records an error and does not stop the others.
+// It returns true when every task was run and none of them failed
+func runTasks(ctx *v1.ScrapeContext, concurrency int, tasks []scrapeTask, results *v1.ScrapeResults) bool {
+	if concurrency <= 0 {
+		concurrency = defaultMaxConcurrency
+	}
+
+	taskResults := make([]v1.ScrapeResults, len(tasks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	skipped := 0
+	for i, task := range tasks {
+		// tasks completed by an interrupted scrape are skipped and no task is started once the scrape is cancelled
+		if ctx.Checkpoint.Done(task.name) || ctx.Cancelled() {
+			skipped++
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(task scrapeTask, results *v1.ScrapeResults) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					results.Errorf(fmt.Errorf("%v", r), "panic while scraping %s", task.name)
+				}
+			}()
+			start := time.Now()
+			task.fn(results)
+			logger.Debugf("scraped %s in %s", task.name, time.Since(start))
+			if !ctx.Cancelled() {
+				ctx.Checkpoint.Complete(task.name)
+			}
+		}(task, &taskResults[i])
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, r := range taskResults {
+		for _, result := range r {
+			if result.Error != nil {
+				failed = append(failed, tasks[i].name)
+				break
+			}
+		}
+		*results = append(*results, r...)
+	}
+	if len(failed) > 0 {
+		logger.Warnf("%d/%d aws scrape tasks returned partial results: %v", len(failed), len(tasks), failed)
+	}
+	if skipped > 0 {
+		logger.Infof("skipped %d/%d aws scrape tasks completed before or after an interruption", skipped, len(tasks))
+	}
+	return len(failed) == 0 && skipped == 0
+}
@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func TestRunTasksComplete(t *testing.T) {
+	scrape := func(id string) scrapeTask {
+		return scrapeTask{name: id, fn: func(results *v1.ScrapeResults) {
+			*results = append(*results, v1.ScrapeResult{ID: id})
+		}}
+	}
+	failing := scrapeTask{name: "failing", fn: func(results *v1.ScrapeResults) {
+		results.Errorf(fmt.Errorf("access denied"), "failed to scrape")
+	}}
+
+	cases := []struct {
+		name     string
+		done     []string
+		tasks    []scrapeTask
+		complete bool
+		results  int
+	}{
+		{"every task ran", nil, []scrapeTask{scrape("a"), scrape("b")}, true, 2},
+		{"a task failed", nil, []scrapeTask{scrape("a"), failing}, false, 2},
+		{"a task was resumed", []string{"a"}, []scrapeTask{scrape("a"), scrape("b")}, false, 1},
+	}
+	for _, c := range cases {
+		ctx := &v1.ScrapeContext{Context: context.Background(), Checkpoint: v1.NewCheckpoint(c.done)}
+		var results v1.ScrapeResults
+		if complete := runTasks(ctx, 2, c.tasks, &results); complete != c.complete {
+			t.Errorf("%s: expected complete to be %v", c.name, c.complete)
+		}
+		if len(results) != c.results {
+			t.Errorf("%s: expected %d results, got %d", c.name, c.results, len(results))
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package aws
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgeTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// StateMachine is a state machine with its parsed definition
+type StateMachine struct {
+	sfn.DescribeStateMachineOutput
+	Definition map[string]interface{} `json:"Definition,omitempty"`
+}
+
+// EventBridgeRule is a rule with its parsed event pattern and the targets it triggers
+type EventBridgeRule struct {
+	eventbridgeTypes.Rule
+	EventPattern map[string]interface{}    `json:"EventPattern,omitempty"`
+	Targets      []eventbridgeTypes.Target `json:"Targets,omitempty"`
+}
+
+// targetResource returns the scraped item of the ARN of an EventBridge target, ECS clusters are not scraped
+func targetResource(arn string) v1.ExternalID {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return v1.ExternalID{}
+	}
+	switch parts[2] {
+	case "lambda":
+		return v1.ExternalID{ExternalID: []string{getLambdaFunctionArn(arn)}, ExternalType: v1.AWSLambdaFunction}
+	case "sqs":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSSQSQueue}
+	case "sns":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSSNSTopic}
+	case "states":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSStepFunctionsStateMachine}
+	case "kinesis":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSKinesisStream}
+	case "firehose":
+		return v1.ExternalID{ExternalID: []string{arn}, ExternalType: v1.AWSKinesisFirehose}
+	}
+	return v1.ExternalID{}
+}
+
+// definitionFunctions returns the functions invoked by the states of a definition, either as the resource of a
+// task or as the FunctionName parameter of the lambda:invoke integration
+func definitionFunctions(definition interface{}) []string {
+	var functions []string
+	switch d := definition.(type) {
+	case map[string]interface{}:
+		for _, v := range d {
+			functions = append(functions, definitionFunctions(v)...)
+		}
+	case []interface{}:
+		for _, v := range d {
+			functions = append(functions, definitionFunctions(v)...)
+		}
+	case string:
+		if arn := getLambdaFunctionArn(d); arn != "" {
+			functions = append(functions, arn)
+		}
+	}
+	return functions
+}
+
+func (aws Scraper) stepFunctions(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("StepFunctions") {
+		return
+	}
+	SFN := ctx.client("sfn", func() interface{} { return sfn.NewFromConfig(*ctx.Session) }).(*sfn.Client)
+	paginator := sfn.NewListStateMachinesPaginator(SFN, &sfn.ListStateMachinesInput{})
+	for paginator.HasMorePages() {
+		machines, err := paginator.NextPage(ctx)
+		if err != nil {
+			results.Errorf(err, "failed to list state machines")
+			return
+		}
+		for _, item := range machines.StateMachines {
+			output, err := SFN.DescribeStateMachine(ctx, &sfn.DescribeStateMachineInput{StateMachineArn: item.StateMachineArn})
+			if err != nil {
+				results.Errorf(err, "failed to describe state machine %s", *item.Name)
+				continue
+			}
+			machine := StateMachine{DescribeStateMachineOutput: *output}
+			if output.Definition != nil {
+				if err := json.Unmarshal([]byte(*output.Definition), &machine.Definition); err != nil {
+					machine.Definition = map[string]interface{}{"raw": *output.Definition}
+				}
+			}
+
+			tags := make(v1.JSONStringMap)
+			if tagsOutput, err := SFN.ListTagsForResource(ctx, &sfn.ListTagsForResourceInput{ResourceArn: item.StateMachineArn}); err != nil {
+				results.Errorf(err, "failed to get tags of state machine %s", *item.Name)
+			} else {
+				for _, tag := range tagsOutput.Tags {
+					tags[*tag.Key] = deref(tag.Value)
+				}
+			}
+
+			self := v1.ExternalID{ExternalID: []string{*output.StateMachineArn}, ExternalType: v1.AWSStepFunctionsStateMachine}
+			var relationships v1.RelationshipResults
+			if output.RoleArn != nil {
+				relationships = append(relationships, roleRelationship(*output.RoleArn, self, "StateMachineRole"))
+			}
+			seen := make(map[string]bool)
+			for _, function := range definitionFunctions(machine.Definition) {
+				if seen[function] {
+					continue
+				}
+				seen[function] = true
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  self,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{function}, ExternalType: v1.AWSLambdaFunction},
+					Relationship:      "StateMachineFunction",
+				})
+			}
+
+			*results = append(*results, v1.ScrapeResult{
+				ExternalType:        v1.AWSStepFunctionsStateMachine,
+				CreatedAt:           output.CreationDate,
+				Tags:                tags,
+				BaseScraper:         config.BaseScraper,
+				Config:              machine,
+				Type:                "StateMachine",
+				Name:                *output.Name,
+				Account:             *ctx.Caller.Account,
+				Region:              ctx.Session.Region,
+				Aliases:             []string{*output.Name},
+				ID:                  *output.StateMachineArn,
+				Ignore:              []string{"CreationDate", "ResultMetadata"},
+				ParentExternalID:    *ctx.Caller.Account,
+				ParentExternalType:  v1.AWSAccount,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
+
+// eventBridgeRules scrapes the rules of every event bus together with their targets
+func (aws Scraper) eventBridgeRules(ctx *AWSContext, config v1.AWS, results *v1.ScrapeResults) {
+	if !config.Includes("EventBridge") {
+		return
+	}
+	EventBridge := ctx.client("eventbridge", func() interface{} { return eventbridge.NewFromConfig(*ctx.Session) }).(*eventbridge.Client)
+
+	var buses []eventbridgeTypes.EventBus
+	busesInput := &eventbridge.ListEventBusesInput{}
+	for {
+		output, err := EventBridge.ListEventBuses(ctx, busesInput)
+		if err != nil {
+			results.Errorf(err, "failed to list event buses")
+			return
+		}
+		buses = append(buses, output.EventBuses...)
+		if output.NextToken == nil {
+			break
+		}
+		busesInput.NextToken = output.NextToken
+	}
+
+	for _, bus := range buses {
+		*results = append(*results, v1.ScrapeResult{
+			ExternalType:       v1.AWSEventBridgeEventBus,
+			BaseScraper:        config.BaseScraper,
+			Config:             bus,
+			Type:               "EventBus",
+			Name:               *bus.Name,
+			Account:            *ctx.Caller.Account,
+			Region:             ctx.Session.Region,
+			ID:                 *bus.Arn,
+			ParentExternalID:   *ctx.Caller.Account,
+			ParentExternalType: v1.AWSAccount,
+		})
+
+		rulesInput := &eventbridge.ListRulesInput{EventBusName: bus.Name}
+		for {
+			rules, err := EventBridge.ListRules(ctx, rulesInput)
+			if err != nil {
+				results.Errorf(err, "failed to list rules of event bus %s", *bus.Name)
+				break
+			}
+			for _, r := range rules.Rules {
+				aws.eventBridgeRule(ctx, config, EventBridge, bus, r, results)
+			}
+			if rules.NextToken == nil {
+				break
+			}
+			rulesInput.NextToken = rules.NextToken
+		}
+	}
+}
+
+func (aws Scraper) eventBridgeRule(ctx *AWSContext, config v1.AWS, client *eventbridge.Client, bus eventbridgeTypes.EventBus, r eventbridgeTypes.Rule, results *v1.ScrapeResults) {
+	rule := EventBridgeRule{Rule: r}
+	if r.EventPattern != nil {
+		if err := json.Unmarshal([]byte(*r.EventPattern), &rule.EventPattern); err != nil {
+			rule.EventPattern = map[string]interface{}{"raw": *r.EventPattern}
+		}
+	}
+
+	targetsInput := &eventbridge.ListTargetsByRuleInput{Rule: r.Name, EventBusName: bus.Name}
+	for {
+		targets, err := client.ListTargetsByRule(ctx, targetsInput)
+		if err != nil {
+			results.Errorf(err, "failed to list targets of rule %s", *r.Name)
+			break
+		}
+		rule.Targets = append(rule.Targets, targets.Targets...)
+		if targets.NextToken == nil {
+			break
+		}
+		targetsInput.NextToken = targets.NextToken
+	}
+
+	self := v1.ExternalID{ExternalID: []string{*r.Arn}, ExternalType: v1.AWSEventBridgeRule}
+	var relationships v1.RelationshipResults
+	if r.RoleArn != nil {
+		relationships = append(relationships, roleRelationship(*r.RoleArn, self, "EventRuleRole"))
+	}
+	for _, target := range rule.Targets {
+		if resource := targetResource(deref(target.Arn)); resource.ExternalType != "" {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID:  self,
+				RelatedExternalID: resource,
+				Relationship:      "EventRuleTarget",
+			})
+		}
+	}
+
+	tags := make(v1.JSONStringMap)
+	if tagsOutput, err := client.ListTagsForResource(ctx, &eventbridge.ListTagsForResourceInput{ResourceARN: r.Arn}); err != nil {
+		results.Errorf(err, "failed to get tags of rule %s", *r.Name)
+	} else {
+		for _, tag := range tagsOutput.Tags {
+			tags[*tag.Key] = deref(tag.Value)
+		}
+	}
+
+	*results = append(*results, v1.ScrapeResult{
+		ExternalType:        v1.AWSEventBridgeRule,
+		Tags:                tags,
+		BaseScraper:         config.BaseScraper,
+		Config:              rule,
+		Type:                "EventRule",
+		Name:                *r.Name,
+		Account:             *ctx.Caller.Account,
+		Region:              ctx.Session.Region,
+		ID:                  *r.Arn,
+		ParentExternalID:    *bus.Arn,
+		ParentExternalType:  v1.AWSEventBridgeEventBus,
+		RelationshipResults: relationships,
+	})
+}
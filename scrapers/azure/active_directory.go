@@ -0,0 +1,143 @@
+package azure
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// Credential is a password or certificate of an application, the secret itself is never returned
+type Credential struct {
+	KeyID         string     `json:"keyId"`
+	DisplayName   string     `json:"displayName,omitempty"`
+	Type          string     `json:"type,omitempty"`
+	StartDateTime *time.Time `json:"startDateTime,omitempty"`
+	EndDateTime   *time.Time `json:"endDateTime,omitempty"`
+}
+
+// Application is an app registration of the directory
+type Application struct {
+	ID                     string          `json:"id"`
+	AppID                  string          `json:"appId"`
+	DisplayName            string          `json:"displayName"`
+	SignInAudience         string          `json:"signInAudience,omitempty"`
+	CreatedDateTime        *time.Time      `json:"createdDateTime,omitempty"`
+	IdentifierUris         []string        `json:"identifierUris,omitempty"`
+	PasswordCredentials    []Credential    `json:"passwordCredentials,omitempty"`
+	KeyCredentials         []Credential    `json:"keyCredentials,omitempty"`
+	RequiredResourceAccess json.RawMessage `json:"requiredResourceAccess,omitempty"`
+}
+
+// ServicePrincipal is the identity of an application or a managed identity in the directory
+type ServicePrincipal struct {
+	ID                     string       `json:"id"`
+	AppID                  string       `json:"appId"`
+	DisplayName            string       `json:"displayName"`
+	ServicePrincipalType   string       `json:"servicePrincipalType"`
+	AccountEnabled         bool         `json:"accountEnabled"`
+	AppOwnerOrganizationID string       `json:"appOwnerOrganizationId,omitempty"`
+	ServicePrincipalNames  []string     `json:"servicePrincipalNames,omitempty"`
+	AlternativeNames       []string     `json:"alternativeNames,omitempty"`
+	Tags                   []string     `json:"tags,omitempty"`
+	PasswordCredentials    []Credential `json:"passwordCredentials,omitempty"`
+	KeyCredentials         []Credential `json:"keyCredentials,omitempty"`
+}
+
+// Group is a security or Microsoft 365 group of the directory
+type Group struct {
+	ID              string     `json:"id"`
+	DisplayName     string     `json:"displayName"`
+	Description     string     `json:"description,omitempty"`
+	Mail            string     `json:"mail,omitempty"`
+	MailEnabled     bool       `json:"mailEnabled"`
+	SecurityEnabled bool       `json:"securityEnabled"`
+	GroupTypes      []string   `json:"groupTypes,omitempty"`
+	CreatedDateTime *time.Time `json:"createdDateTime,omitempty"`
+}
+
+// activeDirectory scrapes the applications, service principals and groups of the directory, service principals
+// are related to the application they are created from
+func (azure Scraper) activeDirectory(client *Client, config v1.Azure, results *v1.ScrapeResults) {
+	applications := make(map[string]bool)
+	err := list(client.graph, "/v1.0/applications", func(items json.RawMessage) error {
+		var page []Application
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, application := range page {
+			applications[application.AppID] = true
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:  config.BaseScraper,
+				Type:         "Application",
+				ExternalType: v1.AzureADApplication,
+				ID:           application.ID,
+				Name:         application.DisplayName,
+				Config:       application,
+				CreatedAt:    application.CreatedDateTime,
+				Aliases:      []string{application.AppID},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list applications of %s", config.TenantID)
+	}
+
+	err = list(client.graph, "/v1.0/servicePrincipals", func(items json.RawMessage) error {
+		var page []ServicePrincipal
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, principal := range page {
+			var relationships v1.RelationshipResults
+			// service principals of applications registered in other tenants have no application in the directory
+			if applications[principal.AppID] {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID: v1.ExternalID{ExternalID: []string{principal.AppID}, ExternalType: v1.AzureADApplication},
+					RelatedExternalID: v1.ExternalID{
+						ExternalID:   []string{principal.ID},
+						ExternalType: v1.AzureADServicePrincipal,
+					},
+					Relationship: "ApplicationServicePrincipal",
+				})
+			}
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:         config.BaseScraper,
+				Type:                "ServicePrincipal",
+				ExternalType:        v1.AzureADServicePrincipal,
+				ID:                  principal.ID,
+				Name:                principal.DisplayName,
+				Config:              principal,
+				Aliases:             []string{principal.AppID},
+				RelationshipResults: relationships,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list service principals of %s", config.TenantID)
+	}
+
+	err = list(client.graph, "/v1.0/groups", func(items json.RawMessage) error {
+		var page []Group
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, group := range page {
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:  config.BaseScraper,
+				Type:         "Group",
+				ExternalType: v1.AzureADGroup,
+				ID:           group.ID,
+				Name:         group.DisplayName,
+				Config:       group,
+				CreatedAt:    group.CreatedDateTime,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list groups of %s", config.TenantID)
+	}
+}
@@ -0,0 +1,198 @@
+package azure
+
+import (
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+const (
+	containerServiceAPIVersion = "2022-09-01"
+	computeAPIVersion          = "2022-11-01"
+)
+
+// poolNameTags are the tags AKS sets on the scale sets of a node pool
+var poolNameTags = []string{"aks-managed-poolName", "poolName"}
+
+// resource is a resource of the Resource Manager API, the config of the resource is kept as returned by the API
+type resource struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name"`
+	Location string                 `json:"location"`
+	Tags     map[string]string      `json:"tags,omitempty"`
+	Config   map[string]interface{} `json:"-"`
+}
+
+func (r *resource) UnmarshalJSON(data []byte) error {
+	type fields resource
+	if err := json.Unmarshal(data, (*fields)(r)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.Config)
+}
+
+// property returns a string property of the resource
+func (r resource) property(name string) string {
+	properties, _ := r.Config["properties"].(map[string]interface{})
+	value, _ := properties[name].(string)
+	return value
+}
+
+// aksCluster is a managed cluster with its node pools
+type aksCluster struct {
+	resource
+	NodePools []aksNodePool
+}
+
+// NodeResourceGroup returns the lower cased id of the resource group of the nodes of the cluster
+func (cluster aksCluster) NodeResourceGroup() string {
+	name := cluster.property("nodeResourceGroup")
+	parts := strings.Split(strings.TrimPrefix(strings.ToLower(cluster.ID), "/"), "/")
+	if name == "" || len(parts) < 2 {
+		return ""
+	}
+	return "/" + parts[0] + "/" + parts[1] + "/resourcegroups/" + strings.ToLower(name)
+}
+
+// aksNodePool is a node pool with the scale sets of its nodes
+type aksNodePool struct {
+	resource
+	ScaleSets []resource
+}
+
+// resourceGroupID returns the lower cased id of the resource group of a resource
+func resourceGroupID(id string) string {
+	parts := strings.SplitN(strings.TrimPrefix(strings.ToLower(id), "/"), "/", 5)
+	if len(parts) < 4 {
+		return ""
+	}
+	return "/" + strings.Join(parts[:4], "/")
+}
+
+// listResources decodes every resource of a collection
+func listResources(client *Client, url string) ([]resource, error) {
+	var resources []resource
+	err := list(client.resources, url, func(items json.RawMessage) error {
+		var page []resource
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		resources = append(resources, page...)
+		return nil
+	})
+	return resources, err
+}
+
+// listAKSClusters returns the managed clusters of the subscription with their node pools, the scale sets of the
+// node pools are found in the node resource group by the pool name tag
+func listAKSClusters(client *Client, subscriptionID string) ([]aksCluster, error) {
+	clusters, err := listResources(client, "/subscriptions/"+subscriptionID+"/providers/Microsoft.ContainerService/managedClusters?api-version="+containerServiceAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []aksCluster
+	for _, item := range clusters {
+		cluster := aksCluster{resource: item}
+		pools, err := listResources(client, item.ID+"/agentPools?api-version="+containerServiceAPIVersion)
+		if err != nil {
+			return nil, err
+		}
+		var scaleSets []resource
+		if nodeResourceGroup := cluster.NodeResourceGroup(); nodeResourceGroup != "" {
+			if scaleSets, err = listResources(client, nodeResourceGroup+"/providers/Microsoft.Compute/virtualMachineScaleSets?api-version="+computeAPIVersion); err != nil {
+				return nil, err
+			}
+		}
+		for _, pool := range pools {
+			nodePool := aksNodePool{resource: pool}
+			for _, scaleSet := range scaleSets {
+				for _, tag := range poolNameTags {
+					if scaleSet.Tags[tag] == pool.Name {
+						nodePool.ScaleSets = append(nodePool.ScaleSets, scaleSet)
+						break
+					}
+				}
+			}
+			cluster.NodePools = append(cluster.NodePools, nodePool)
+		}
+		result = append(result, cluster)
+	}
+	return result, nil
+}
+
+// aksClusters scrapes the managed clusters of the subscription, their node pools and the scale sets of the nodes
+func (azure Scraper) aksClusters(client *Client, config v1.Azure, results *v1.ScrapeResults) {
+	clusters, err := listAKSClusters(client, config.SubscriptionID)
+	if err != nil {
+		results.Errorf(err, "failed to list AKS clusters of %s", config.SubscriptionID)
+		return
+	}
+
+	for _, cluster := range clusters {
+		clusterID := v1.ExternalID{ExternalID: []string{strings.ToLower(cluster.ID)}, ExternalType: v1.AzureAKSCluster}
+		var relationships v1.RelationshipResults
+		if nodeResourceGroup := cluster.NodeResourceGroup(); nodeResourceGroup != "" {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID:  clusterID,
+				RelatedExternalID: v1.ExternalID{ExternalID: []string{nodeResourceGroup}, ExternalType: v1.AzureResourceGroup},
+				Relationship:      "AKSClusterResourceGroup",
+			})
+		}
+		*results = append(*results, v1.ScrapeResult{
+			BaseScraper:         config.BaseScraper,
+			Type:                "AKS",
+			ExternalType:        v1.AzureAKSCluster,
+			ID:                  clusterID.ExternalID[0],
+			Name:                cluster.Name,
+			Account:             config.SubscriptionID,
+			Region:              cluster.Location,
+			Config:              cluster.Config,
+			Tags:                cluster.Tags,
+			ParentExternalID:    resourceGroupID(cluster.ID),
+			ParentExternalType:  v1.AzureResourceGroup,
+			RelationshipResults: relationships,
+		})
+
+		for _, pool := range cluster.NodePools {
+			poolID := v1.ExternalID{ExternalID: []string{strings.ToLower(pool.ID)}, ExternalType: v1.AzureAKSNodePool}
+			var relationships v1.RelationshipResults
+			for _, scaleSet := range pool.ScaleSets {
+				scaleSetID := strings.ToLower(scaleSet.ID)
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  poolID,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{scaleSetID}, ExternalType: v1.AzureVMScaleSet},
+					Relationship:      "AKSNodePoolVirtualMachineScaleSet",
+				})
+				*results = append(*results, v1.ScrapeResult{
+					BaseScraper:        config.BaseScraper,
+					Type:               "VirtualMachineScaleSet",
+					ExternalType:       v1.AzureVMScaleSet,
+					ID:                 scaleSetID,
+					Name:               scaleSet.Name,
+					Account:            config.SubscriptionID,
+					Region:             scaleSet.Location,
+					Config:             scaleSet.Config,
+					Tags:               scaleSet.Tags,
+					ParentExternalID:   resourceGroupID(scaleSet.ID),
+					ParentExternalType: v1.AzureResourceGroup,
+				})
+			}
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:         config.BaseScraper,
+				Type:                "AKSNodePool",
+				ExternalType:        v1.AzureAKSNodePool,
+				ID:                  poolID.ExternalID[0],
+				Name:                pool.Name,
+				Account:             config.SubscriptionID,
+				Region:              cluster.Location,
+				Config:              pool.Config,
+				Aliases:             []string{cluster.Name + "/" + pool.Name},
+				ParentExternalID:    clusterID.ExternalID[0],
+				ParentExternalType:  v1.AzureAKSCluster,
+				RelationshipResults: relationships,
+			})
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package azure
+
+import (
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+const resourcesAPIVersion = "2021-04-01"
+
+type Scraper struct {
+}
+
+// Subscription is the subscription the resources are scraped from
+type Subscription struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscriptionId"`
+	DisplayName    string `json:"displayName"`
+	State          string `json:"state"`
+	TenantID       string `json:"tenantId"`
+}
+
+// ResourceGroup is a resource group of the subscription
+type ResourceGroup struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Location   string            `json:"location"`
+	ManagedBy  string            `json:"managedBy,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Properties struct {
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"properties"`
+}
+
+// resourceTypes are the external types of the scraped resources by their lower cased resource type
+var resourceTypes = map[string]string{
+	"microsoft.containerservice/managedclusters":            v1.AzureAKSCluster,
+	"microsoft.containerservice/managedclusters/agentpools": v1.AzureAKSNodePool,
+	"microsoft.compute/virtualmachinescalesets":             v1.AzureVMScaleSet,
+}
+
+// resourceExternalID returns the scraped item of an Azure resource id, resource ids are case insensitive and are
+// scraped lower cased
+func resourceExternalID(id string) v1.ExternalID {
+	id = strings.ToLower(strings.TrimSuffix(id, "/"))
+	parts := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "subscriptions":
+		return v1.ExternalID{ExternalID: []string{id}, ExternalType: v1.AzureSubscription}
+	case len(parts) == 4 && parts[0] == "subscriptions" && parts[2] == "resourcegroups":
+		return v1.ExternalID{ExternalID: []string{id}, ExternalType: v1.AzureResourceGroup}
+	case len(parts) >= 8 && len(parts)%2 == 0 && parts[4] == "providers":
+		// e.g. /subscriptions/<id>/resourcegroups/<name>/providers/microsoft.compute/virtualmachinescalesets/<name>
+		resourceType := parts[5]
+		for i := 6; i < len(parts); i += 2 {
+			resourceType += "/" + parts[i]
+		}
+		if externalType, ok := resourceTypes[resourceType]; ok {
+			return v1.ExternalID{ExternalID: []string{id}, ExternalType: externalType}
+		}
+	}
+	return v1.ExternalID{}
+}
+
+// Scrape ...
+func (azure Scraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScraper) v1.ScrapeResults {
+	results := v1.ScrapeResults{}
+	for _, config := range configs.Azure {
+		client, err := NewClient(ctx, config)
+		if err != nil {
+			results.Errorf(err, "failed to create azure client for %s", config.TenantID)
+			continue
+		}
+
+		if config.Includes("ActiveDirectory") {
+			azure.activeDirectory(client, config, &results)
+		}
+		if config.SubscriptionID == "" {
+			continue
+		}
+		azure.subscription(client, config, &results)
+		if config.Includes("RoleAssignments") {
+			azure.roleAssignments(client, config, &results)
+		}
+		if config.Includes("AKS") {
+			azure.aksClusters(client, config, &results)
+		}
+	}
+	return results
+}
+
+func (azure Scraper) subscription(client *Client, config v1.Azure, results *v1.ScrapeResults) {
+	var subscription Subscription
+	if err := get(client.resources, "/subscriptions/"+config.SubscriptionID+"?api-version="+resourcesAPIVersion, &subscription); err != nil {
+		results.Errorf(err, "failed to get subscription %s", config.SubscriptionID)
+		return
+	}
+	subscriptionID := strings.ToLower(subscription.ID)
+	*results = append(*results, v1.ScrapeResult{
+		BaseScraper:  config.BaseScraper,
+		Type:         "Subscription",
+		ExternalType: v1.AzureSubscription,
+		ID:           subscriptionID,
+		Name:         subscription.DisplayName,
+		Account:      subscription.SubscriptionID,
+		Config:       subscription,
+		Aliases:      []string{subscription.SubscriptionID},
+	})
+
+	err := list(client.resources, "/subscriptions/"+config.SubscriptionID+"/resourcegroups?api-version="+resourcesAPIVersion, func(items json.RawMessage) error {
+		var groups []ResourceGroup
+		if err := json.Unmarshal(items, &groups); err != nil {
+			return err
+		}
+		for _, group := range groups {
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:        config.BaseScraper,
+				Type:               "ResourceGroup",
+				ExternalType:       v1.AzureResourceGroup,
+				ID:                 strings.ToLower(group.ID),
+				Name:               group.Name,
+				Account:            subscription.SubscriptionID,
+				Region:             group.Location,
+				Config:             group,
+				Tags:               group.Tags,
+				ParentExternalID:   subscriptionID,
+				ParentExternalType: v1.AzureSubscription,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list resource groups of %s", config.SubscriptionID)
+	}
+}
@@ -0,0 +1,89 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/config-db/utils"
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	graphURL      = "https://graph.microsoft.com"
+	resourcesURL  = "https://management.azure.com"
+	loginTokenURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+)
+
+// Client calls the Microsoft Graph API for the directory and the Resource Manager API for the subscription, both
+// authenticated as the service principal of the config
+type Client struct {
+	graph     *resty.Client
+	resources *resty.Client
+}
+
+func NewClient(ctx *v1.ScrapeContext, config v1.Azure) (*Client, error) {
+	clientID, err := secrets.GetEnvValue(ctx, config.ClientID, ctx.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client id: %v", err)
+	}
+	clientSecret, err := secrets.GetEnvValue(ctx, config.ClientSecret, ctx.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client secret: %v", err)
+	}
+
+	client := func(baseURL string) *resty.Client {
+		credentials := clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			TokenURL:     fmt.Sprintf(loginTokenURL, config.TenantID),
+			Scopes:       []string{baseURL + "/.default"},
+		}
+		return utils.RetryRateLimited(resty.NewWithClient(credentials.Client(context.Background()))).SetBaseURL(baseURL)
+	}
+	return &Client{graph: client(graphURL), resources: client(resourcesURL)}, nil
+}
+
+// page is a page of a collection of the Graph or Resource Manager API
+type page struct {
+	Value         json.RawMessage `json:"value"`
+	NextLink      string          `json:"nextLink"`
+	GraphNextLink string          `json:"@odata.nextLink"`
+}
+
+// list calls fn with the items of every page of a collection
+func list(client *resty.Client, url string, fn func(items json.RawMessage) error) error {
+	for url != "" {
+		var result page
+		response, err := client.R().SetResult(&result).Get(url)
+		if err != nil {
+			return err
+		}
+		if response.IsError() {
+			return fmt.Errorf("%s: %s", response.Status(), response.String())
+		}
+		if err := fn(result.Value); err != nil {
+			return err
+		}
+		url = result.NextLink
+		if url == "" {
+			url = result.GraphNextLink
+		}
+	}
+	return nil
+}
+
+// get decodes a single resource
+func get(client *resty.Client, url string, result interface{}) error {
+	response, err := client.R().SetResult(result).Get(url)
+	if err != nil {
+		return err
+	}
+	if response.IsError() {
+		return fmt.Errorf("%s: %s", response.Status(), response.String())
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/metrics"
+)
+
+const costManagementAPIVersion = "2021-10-01"
+
+// costQuery is the query of the daily actual cost of every resource of the last 30 days
+type costQuery struct {
+	Type       string `json:"type"`
+	Timeframe  string `json:"timeframe"`
+	TimePeriod struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"timePeriod"`
+	Dataset struct {
+		Granularity string                     `json:"granularity"`
+		Aggregation map[string]costAggregation `json:"aggregation"`
+		Grouping    []costGrouping             `json:"grouping"`
+	} `json:"dataset"`
+}
+
+type costAggregation struct {
+	Name     string `json:"name"`
+	Function string `json:"function"`
+}
+
+type costGrouping struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type costQueryResult struct {
+	Properties struct {
+		NextLink string `json:"nextLink"`
+		Columns  []struct {
+			Name string `json:"name"`
+		} `json:"columns"`
+		Rows [][]interface{} `json:"rows"`
+	} `json:"properties"`
+}
+
+// ResourceCost is the cost of a resource over the last day, 7 days and 30 days of the cost data
+type ResourceCost struct {
+	Cost1d  float64
+	Cost7d  float64
+	Cost30d float64
+}
+
+func (cost *ResourceCost) add(other ResourceCost) {
+	cost.Cost1d += other.Cost1d
+	cost.Cost7d += other.Cost7d
+	cost.Cost30d += other.Cost30d
+}
+
+// FetchCosts returns the costs of the resources of the subscription by lower cased resource id, the days are counted
+// back from the last day of the cost data as the costs of the current day are incomplete
+func FetchCosts(client *Client, subscriptionID string) (map[string]ResourceCost, error) {
+	var query costQuery
+	query.Type = "ActualCost"
+	query.Timeframe = "Custom"
+	query.TimePeriod.From = time.Now().UTC().AddDate(0, 0, -30).Format("2006-01-02T00:00:00Z")
+	query.TimePeriod.To = time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	query.Dataset.Granularity = "Daily"
+	query.Dataset.Aggregation = map[string]costAggregation{"totalCost": {Name: "Cost", Function: "Sum"}}
+	query.Dataset.Grouping = []costGrouping{{Type: "Dimension", Name: "ResourceId"}}
+
+	type dailyCost struct {
+		resourceID string
+		day        time.Time
+		cost       float64
+	}
+	var daily []dailyCost
+	var last time.Time
+
+	url := "/subscriptions/" + subscriptionID + "/providers/Microsoft.CostManagement/query?api-version=" + costManagementAPIVersion
+	for url != "" {
+		var result costQueryResult
+		response, err := client.resources.R().SetBody(query).SetResult(&result).Post(url)
+		if err != nil {
+			return nil, err
+		}
+		if response.IsError() {
+			return nil, fmt.Errorf("%s: %s", response.Status(), response.String())
+		}
+
+		columns := make(map[string]int)
+		for i, column := range result.Properties.Columns {
+			columns[strings.ToLower(column.Name)] = i
+		}
+		costColumn, ok := columns["cost"]
+		if !ok {
+			costColumn, ok = columns["pretaxcost"]
+		}
+		dateColumn, hasDate := columns["usagedate"]
+		resourceColumn, hasResource := columns["resourceid"]
+		if !ok || !hasDate || !hasResource {
+			return nil, fmt.Errorf("unexpected columns of cost query: %v", result.Properties.Columns)
+		}
+
+		for _, row := range result.Properties.Rows {
+			if len(row) != len(result.Properties.Columns) {
+				continue
+			}
+			cost, _ := row[costColumn].(float64)
+			resourceID, _ := row[resourceColumn].(string)
+			date, _ := row[dateColumn].(float64)
+			day, err := time.Parse("20060102", fmt.Sprintf("%.0f", date))
+			if err != nil || resourceID == "" {
+				continue
+			}
+			if day.After(last) {
+				last = day
+			}
+			daily = append(daily, dailyCost{resourceID: strings.ToLower(resourceID), day: day, cost: cost})
+		}
+		url = result.Properties.NextLink
+	}
+
+	costs := make(map[string]ResourceCost)
+	for _, row := range daily {
+		cost := costs[row.resourceID]
+		days := last.Sub(row.day).Hours() / 24
+		if days < 1 {
+			cost.Cost1d += row.cost
+		}
+		if days < 7 {
+			cost.Cost7d += row.cost
+		}
+		cost.Cost30d += row.cost
+		costs[row.resourceID] = cost
+	}
+	return costs, nil
+}
+
+// CostScraper updates the costs of the scraped resources of the subscription from Cost Management, the costs of AKS
+// clusters include the costs of the resources of their node resource group and the costs of node pools are the costs
+// of their scale sets
+type CostScraper struct{}
+
+func (azureCost CostScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScraper) v1.ScrapeResults {
+	var results v1.ScrapeResults
+	for _, config := range configs.Azure {
+		if config.SubscriptionID == "" || !config.Includes("Costs") {
+			continue
+		}
+		subscriptionID := "/subscriptions/" + strings.ToLower(config.SubscriptionID)
+		client, err := NewClient(ctx, config)
+		if err != nil {
+			results.Errorf(err, "failed to create azure client for %s", config.TenantID)
+			continue
+		}
+
+		start := time.Now()
+		costs, err := FetchCosts(client, config.SubscriptionID)
+		metrics.Since(metrics.CostQueryDuration.WithLabelValues("azure_cost_management", metrics.Status(err)), start)
+		if err != nil {
+			results.ItemErrorf(v1.AzureSubscription, subscriptionID, err, "failed to fetch costs")
+			continue
+		}
+
+		var total ResourceCost
+		for _, cost := range costs {
+			total.add(cost)
+		}
+		costs[subscriptionID] = total
+
+		if config.Includes("AKS") {
+			clusters, err := listAKSClusters(client, config.SubscriptionID)
+			if err != nil {
+				results.Errorf(err, "failed to list AKS clusters of %s", config.SubscriptionID)
+			}
+			for _, cluster := range clusters {
+				clusterID := strings.ToLower(cluster.ID)
+				clusterCost := costs[clusterID]
+				if nodeResourceGroup := cluster.NodeResourceGroup(); nodeResourceGroup != "" {
+					for id, cost := range costs {
+						if strings.HasPrefix(id, nodeResourceGroup+"/") {
+							clusterCost.add(cost)
+						}
+					}
+				}
+				costs[clusterID] = clusterCost
+
+				for _, pool := range cluster.NodePools {
+					var poolCost ResourceCost
+					for _, scaleSet := range pool.ScaleSets {
+						poolCost.add(costs[strings.ToLower(scaleSet.ID)])
+					}
+					costs[strings.ToLower(pool.ID)] = poolCost
+				}
+			}
+		}
+
+		if ctx.DryRun {
+			logger.Infof("Dry run, skipping the update of the costs of %d Azure resources of %s", len(costs), config.SubscriptionID)
+			continue
+		}
+		gormDB := db.DefaultDB()
+		updated := 0
+		for id, cost := range costs {
+			tx := gormDB.Exec(`
+                UPDATE config_items SET cost_per_minute = ?, cost_total_1d = ?, cost_total_7d = ?, cost_total_30d = ?
+                WHERE ? = ANY(external_id) AND tenant = ?`, cost.Cost1d/(24*60), cost.Cost1d, cost.Cost7d, cost.Cost30d, id, ctx.Tenant)
+			if tx.Error != nil {
+				results.ItemErrorf("", id, tx.Error, "failed to update costs")
+				continue
+			}
+			updated += int(tx.RowsAffected)
+		}
+		logger.Infof("Updated cost of %d Azure resources of %s", updated, config.SubscriptionID)
+	}
+	return results
+}
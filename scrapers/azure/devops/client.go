@@ -9,6 +9,7 @@ import (
 	"github.com/go-resty/resty/v2"
 
 	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/secrets"
 )
 
 type Project struct {
@@ -43,7 +44,10 @@ type Pipeline struct {
 }
 
 func (p Pipeline) GetTags() map[string]string {
-	var tags = p.TemplateParameters
+	var tags = make(map[string]string)
+	for k, v := range p.TemplateParameters {
+		tags[k] = v
+	}
 	for k, v := range p.Variables {
 		tags[k] = v.Value
 	}
@@ -92,7 +96,10 @@ type Run struct {
 }
 
 func (r Run) GetTags() map[string]string {
-	var tags = r.TemplateParameters
+	var tags = make(map[string]string)
+	for k, v := range r.TemplateParameters {
+		tags[k] = v
+	}
 	for k, v := range r.Variables {
 		tags[k] = v.Value
 	}
@@ -110,9 +117,13 @@ type AzureDevopsClient struct {
 }
 
 func NewAzureDevopsClient(ctx *v1.ScrapeContext, ado v1.AzureDevops) (*AzureDevopsClient, error) {
+	token, err := secrets.GetEnvValue(ctx, ado.PersonalAccessToken, ctx.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get personal access token: %v", err)
+	}
 	client := resty.New().
 		SetBaseURL(fmt.Sprintf("https://dev.azure.com/%s", ado.Organization)).
-		SetBasicAuth(ado.Organization, ado.PersonalAccessToken.Value)
+		SetBasicAuth(ado.Organization, token)
 
 	return &AzureDevopsClient{
 		ScrapeContext: ctx,
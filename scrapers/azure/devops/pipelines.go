@@ -3,6 +3,7 @@ package devops
 import (
 	"fmt"
 
+	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/utils"
 )
@@ -34,7 +35,7 @@ func (ado AzureDevopsScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScr
 				continue
 			}
 
-			fmt.Printf("%v\n", project.Name)
+			logger.Debugf("Scraping pipelines of %s", project.Name)
 			pipelines, err := client.GetPipelines(project.Name)
 			if err != nil {
 				results.Errorf(err, "failed to get pipelines for %s", project.Name)
@@ -48,7 +49,6 @@ func (ado AzureDevopsScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScr
 				}
 
 				var uniquePipelines = make(map[string]Pipeline)
-				fmt.Printf("\t->%v\n", pipeline.Name)
 
 				runs, err := client.GetPipelineRuns(project.Name, pipeline)
 				if err != nil {
@@ -94,6 +94,7 @@ func (ado AzureDevopsScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScr
 					var changes = pipeline.Runs
 					pipeline.Runs = nil
 					results = append(results, v1.ScrapeResult{
+						BaseScraper:  config.BaseScraper,
 						Type:         "Deployment",
 						Config:       pipeline,
 						ExternalType: PipelineRun,
@@ -0,0 +1,107 @@
+package azure
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+const authorizationAPIVersion = "2022-04-01"
+
+// RoleAssignment grants a role to a principal on a scope, the role is named after its definition
+type RoleAssignment struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	RoleName         string     `json:"roleName,omitempty"`
+	RoleDefinitionID string     `json:"roleDefinitionId"`
+	PrincipalID      string     `json:"principalId"`
+	PrincipalType    string     `json:"principalType"`
+	Scope            string     `json:"scope"`
+	Condition        string     `json:"condition,omitempty"`
+	Description      string     `json:"description,omitempty"`
+	CreatedOn        *time.Time `json:"createdOn,omitempty"`
+}
+
+// principalTypes are the scraped principals of role assignments, users are not scraped
+var principalTypes = map[string]string{
+	"ServicePrincipal": v1.AzureADServicePrincipal,
+	"Group":            v1.AzureADGroup,
+}
+
+// roleAssignments scrapes the role assignments of the subscription and relates them to their principal and scope
+func (azure Scraper) roleAssignments(client *Client, config v1.Azure, results *v1.ScrapeResults) {
+	roles := make(map[string]string)
+	err := list(client.resources, "/subscriptions/"+config.SubscriptionID+"/providers/Microsoft.Authorization/roleDefinitions?api-version="+authorizationAPIVersion, func(items json.RawMessage) error {
+		var page []struct {
+			ID         string `json:"id"`
+			Properties struct {
+				RoleName string `json:"roleName"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, role := range page {
+			roles[strings.ToLower(role.ID)] = role.Properties.RoleName
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list role definitions of %s", config.SubscriptionID)
+	}
+
+	err = list(client.resources, "/subscriptions/"+config.SubscriptionID+"/providers/Microsoft.Authorization/roleAssignments?api-version="+authorizationAPIVersion, func(items json.RawMessage) error {
+		var page []struct {
+			ID         string         `json:"id"`
+			Name       string         `json:"name"`
+			Properties RoleAssignment `json:"properties"`
+		}
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, item := range page {
+			assignment := item.Properties
+			assignment.ID, assignment.Name = item.ID, item.Name
+			assignment.RoleName = roles[strings.ToLower(assignment.RoleDefinitionID)]
+			self := v1.ExternalID{ExternalID: []string{strings.ToLower(assignment.ID)}, ExternalType: v1.AzureRoleAssignment}
+
+			var relationships v1.RelationshipResults
+			if principalType, ok := principalTypes[assignment.PrincipalType]; ok {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  v1.ExternalID{ExternalID: []string{assignment.PrincipalID}, ExternalType: principalType},
+					RelatedExternalID: self,
+					Relationship:      assignment.PrincipalType + "RoleAssignment",
+				})
+			}
+			if scope := resourceExternalID(assignment.Scope); scope.ExternalType != "" {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  scope,
+					RelatedExternalID: self,
+					Relationship:      "ScopeRoleAssignment",
+				})
+			}
+
+			name := assignment.RoleName
+			if name == "" {
+				name = assignment.Name
+			}
+			*results = append(*results, v1.ScrapeResult{
+				BaseScraper:         config.BaseScraper,
+				Type:                "RoleAssignment",
+				ExternalType:        v1.AzureRoleAssignment,
+				ID:                  self.ExternalID[0],
+				Name:                name,
+				Account:             config.SubscriptionID,
+				Config:              assignment,
+				CreatedAt:           assignment.CreatedOn,
+				RelationshipResults: relationships,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to list role assignments of %s", config.SubscriptionID)
+	}
+}
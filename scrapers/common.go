@@ -1,14 +1,17 @@
 package scrapers
 
 import (
-	"time"
+	"fmt"
 
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/scrapers/aws"
+	"github.com/flanksource/config-db/scrapers/azure"
 	"github.com/flanksource/config-db/scrapers/azure/devops"
 	"github.com/flanksource/config-db/scrapers/file"
+	"github.com/flanksource/config-db/scrapers/github"
 	"github.com/flanksource/config-db/scrapers/kubernetes"
 	"github.com/flanksource/config-db/scrapers/sql"
+	"github.com/flanksource/config-db/secrets"
 	"github.com/flanksource/kommons"
 	"github.com/flanksource/kommons/ktemplate"
 )
@@ -20,10 +23,43 @@ var All = []v1.Scraper{
 	file.FileScraper{},
 	kubernetes.KubernetesScraper{},
 	kubernetes.KubernetesFileScraper{},
+	azure.Scraper{},
+	azure.CostScraper{},
 	devops.AzureDevopsScraper{},
+	github.GitHubScraper{},
+	github.GitHubActionsScraper{},
 	sql.SqlScraper{},
 }
 
+// scraperType returns the type of a scraper as it is configured in a scrape config, it labels the scrape metrics
+func scraperType(scraper v1.Scraper) string {
+	switch scraper.(type) {
+	case aws.Scraper:
+		return "aws"
+	case aws.CostScraper:
+		return "aws/cost"
+	case file.FileScraper:
+		return "file"
+	case kubernetes.KubernetesScraper:
+		return "kubernetes"
+	case kubernetes.KubernetesFileScraper:
+		return "kubernetesFile"
+	case azure.Scraper:
+		return "azure"
+	case azure.CostScraper:
+		return "azure/cost"
+	case devops.AzureDevopsScraper:
+		return "azureDevops"
+	case github.GitHubScraper:
+		return "github"
+	case github.GitHubActionsScraper:
+		return "githubActions"
+	case sql.SqlScraper:
+		return "sql"
+	}
+	return fmt.Sprintf("%T", scraper)
+}
+
 func GetConnection(ctx *v1.ScrapeContext, conn *v1.Connection) (string, error) {
 	// TODO: this function should not be necessary, each check should be templated out individual
 	// however, the walk method only support high level values, not values from siblings.
@@ -81,14 +117,14 @@ func GetAuthValues(ctx *v1.ScrapeContext, auth *v1.Authentication) (*v1.Authenti
 	if auth == nil {
 		return authentication, nil
 	}
-	_, username, err := ctx.Kommons.GetEnvValueFromCache(auth.Username, ctx.Namespace, 5*time.Minute)
+	username, err := secrets.GetEnvValue(ctx, auth.Username, ctx.Namespace)
 	if err != nil {
 		return nil, err
 	}
 	authentication.Username = kommons.EnvVar{
 		Value: username,
 	}
-	_, password, err := ctx.Kommons.GetEnvValueFromCache(auth.Password, ctx.Namespace, 120*time.Second)
+	password, err := secrets.GetEnvValue(ctx, auth.Password, ctx.Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,87 @@
+package scrapers
+
+import (
+	"fmt"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
+)
+
+// costAnomalyAnalyzer is the analyzer of the analysis opened on config items with a cost anomaly
+const costAnomalyAnalyzer = "cost-anomaly"
+
+var (
+	// CostSchedule of the job that allocates the cost of Kubernetes nodes to namespaces and deployments, records the
+	// daily costs of config items, forecasts them and flags anomalies, the job is disabled when empty
+	CostSchedule string
+	// CostTrailingDays is the number of days the forecast and the anomalies are based on
+	CostTrailingDays int
+	// CostAnomalyFactor is how many times its trailing average the daily cost of a config item must be to be an anomaly
+	CostAnomalyFactor float64
+)
+
+// StartCosts schedules the cost job
+func StartCosts() {
+	if CostSchedule == "" {
+		return
+	}
+	if _, err := cronManger.AddFunc(CostSchedule, exclusive("costs", runCosts)); err != nil {
+		logger.Errorf("Failed to schedule costs using %s: %v", CostSchedule, err)
+	}
+}
+
+func runCosts() {
+	allocated, err := db.AllocateClusterCosts()
+	if err != nil {
+		logger.Errorf("Failed to allocate cluster costs: %v", err)
+	} else {
+		logger.Infof("Allocated cluster costs to %d namespaces and deployments", allocated)
+	}
+
+	recorded, err := db.RecordDailyCosts()
+	if err != nil {
+		logger.Errorf("Failed to record daily costs: %v", err)
+		return
+	}
+	if err := db.PruneCosts(); err != nil {
+		logger.Errorf("Failed to prune daily costs: %v", err)
+	}
+	if err := db.ForecastCosts(CostTrailingDays); err != nil {
+		logger.Errorf("Failed to forecast costs: %v", err)
+	}
+
+	anomalies, err := db.GetCostAnomalies(costAnomalyAnalyzer, CostAnomalyFactor, CostTrailingDays)
+	if err != nil {
+		logger.Errorf("Failed to get cost anomalies: %v", err)
+		return
+	}
+	opened := 0
+	for _, anomaly := range anomalies {
+		status := "resolved"
+		if anomaly.Cost > CostAnomalyFactor*anomaly.Average {
+			status = "open"
+			opened++
+		}
+		analysis := models.Analysis{
+			ID:           ulid.MustNew().AsUUID(),
+			ConfigID:     anomaly.ConfigID,
+			Analyzer:     costAnomalyAnalyzer,
+			AnalysisType: "cost",
+			Severity:     "warning",
+			Status:       status,
+			Summary:      fmt.Sprintf("daily cost of %.2f is %.1fx the %d day average", anomaly.Cost, anomaly.Cost/anomaly.Average, CostTrailingDays),
+			Message:      fmt.Sprintf("cost over the last day was %.2f, the average daily cost of the previous %d days was %.2f", anomaly.Cost, CostTrailingDays, anomaly.Average),
+			Analysis: v1.JSONStringMap{
+				"cost_1d": fmt.Sprintf("%.2f", anomaly.Cost),
+				"average": fmt.Sprintf("%.2f", anomaly.Average),
+			},
+		}
+		if _, err := db.CreateAnalysis(analysis); err != nil {
+			logger.Errorf("Failed to save cost anomaly of %s: %v", anomaly.ConfigID, err)
+		}
+	}
+	logger.Infof("Recorded %d daily costs, %d cost anomalies", recorded, opened)
+}
@@ -1,8 +1,13 @@
 package scrapers
 
 import (
+	"math/rand"
+	"sync"
+	"time"
+
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/query"
 	"github.com/robfig/cron/v3"
 )
 
@@ -10,21 +15,28 @@ var (
 	cronManger        *cron.Cron
 	DefaultSchedule   string
 	cronIDFunctionMap map[string]cron.EntryID
+	// cronLock guards cronIDFunctionMap, scrapers are scheduled by the controller and the config file watcher
+	cronLock sync.Mutex
+	// scheduled are the scrapers in the cron by their view owner, their connections are checked by health checks
+	scheduled sync.Map
 )
 
 func AddToCron(scraper v1.ConfigScraper, id string) {
+	schedule := getSchedule(scraper)
 	fn := func() {
-		if err := RunScraper(scraper); err != nil {
-			logger.Errorf("Error running scraper: %v", err)
+		scheduledAt := time.Now()
+		if jitter := scraper.GetJitter(); jitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(jitter))))
 		}
+		exclusiveRun(scraper, id, func() bool { return ranByAnotherReplica(scraper, schedule, scheduledAt) })()
 	}
-	schedule := scraper.Schedule
-	if schedule == "" {
-		schedule = DefaultSchedule
-	}
+
+	cronLock.Lock()
+	defer cronLock.Unlock()
 
 	// Remove existing cronjob
-	RemoveFromCron(id)
+	removeFromCron(id)
+	query.RegisterViews(viewOwner(scraper, id), scraper.Views)
 
 	// Schedule a new job
 	entryID, err := cronManger.AddFunc(schedule, fn)
@@ -33,16 +45,74 @@ func AddToCron(scraper v1.ConfigScraper, id string) {
 		return
 	}
 
+	scheduled.Store(viewOwner(scraper, id), scraper)
+
 	// Add non empty ids to the map
 	if id != "" {
 		cronIDFunctionMap[id] = entryID
 	}
+
+	if scraper.Trigger != nil {
+		StartTrigger(scraper, id)
+	}
+}
+
+// RunExclusive runs the scraper scheduled with the id now, unless another replica is running it
+func RunExclusive(scraper v1.ConfigScraper, id string) {
+	exclusiveRun(scraper, id, nil)()
+}
+
+// exclusiveRun returns the run of the scraper under its lock shared by the replicas, the run is skipped when
+// ranRecently is true
+func exclusiveRun(scraper v1.ConfigScraper, id string, ranRecently func() bool) func() {
+	return exclusive("scraper:"+viewOwner(scraper, id), func() {
+		if ranRecently != nil && ranRecently() {
+			logger.Debugf("%s was scraped by another replica", scraper.Name)
+			return
+		}
+		if ScrapeQueue {
+			enqueueScraper(scraper, id)
+			return
+		}
+		summary, err := RunScraperWithSummary(scraper, nil)
+		if err != nil {
+			logger.Errorf("Error running scraper: %v", err)
+		}
+		notifyScrapeComplete(id, summary, err)
+	})
+}
+
+func getSchedule(scraper v1.ConfigScraper) string {
+	if scraper.Schedule == "" {
+		return DefaultSchedule
+	}
+	return scraper.Schedule
 }
 
 func RemoveFromCron(id string) {
+	cronLock.Lock()
+	defer cronLock.Unlock()
+	removeFromCron(id)
+}
+
+func removeFromCron(id string) {
 	if entryID, exists := cronIDFunctionMap[id]; exists {
 		cronManger.Remove(entryID)
+		delete(cronIDFunctionMap, id)
+	}
+	StopTrigger(id)
+	if id != "" {
+		scheduled.Delete(id)
+		query.RegisterViews(id, nil)
+	}
+}
+
+// viewOwner identifies the scraper config that registered views
+func viewOwner(scraper v1.ConfigScraper, id string) string {
+	if id != "" {
+		return id
 	}
+	return scraper.Name
 }
 
 func init() {
@@ -0,0 +1,69 @@
+package scrapers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/scrapers/aws"
+	"github.com/flanksource/config-db/scrapers/kubernetes"
+	"github.com/flanksource/config-db/utils/kube"
+)
+
+// diagnosisTTL is how long the diagnostics of the scheduled scrapers are reused, so that requests do not call the
+// cloud APIs every time
+const diagnosisTTL = time.Minute
+
+var lastDiagnosis struct {
+	sync.Mutex
+	time        time.Time
+	running     bool
+	diagnostics []v1.Diagnostic
+}
+
+// Diagnose dry-runs the connections of the scraper, credentials are verified against the APIs
+// but nothing is scraped or saved
+func Diagnose(ctx *v1.ScrapeContext, scraper v1.ConfigScraper) []v1.Diagnostic {
+	var diagnostics []v1.Diagnostic
+	for _, config := range scraper.AWS {
+		diagnostics = append(diagnostics, aws.Diagnose(ctx, config)...)
+	}
+	for _, config := range scraper.Kubernetes {
+		diagnostics = append(diagnostics, kubernetes.Diagnose(ctx, config.ClusterName))
+	}
+	for range scraper.KubernetesFile {
+		diagnostics = append(diagnostics, kubernetes.Diagnose(ctx, ""))
+	}
+	for i := range diagnostics {
+		diagnostics[i].Scraper = scraper.Name
+	}
+	return diagnostics
+}
+
+// DiagnoseScheduled diagnoses the connections of every scheduled scraper, the last diagnostics are returned while
+// they are fresh or while another caller diagnoses the connections again
+func DiagnoseScheduled() []v1.Diagnostic {
+	lastDiagnosis.Lock()
+	if lastDiagnosis.running || time.Since(lastDiagnosis.time) < diagnosisTTL {
+		defer lastDiagnosis.Unlock()
+		return lastDiagnosis.diagnostics
+	}
+	lastDiagnosis.running = true
+	lastDiagnosis.Unlock()
+
+	kommonsClient, _ := kube.NewKommonsClient()
+	var diagnostics []v1.Diagnostic
+	scheduled.Range(func(_, value interface{}) bool {
+		scraper := value.(v1.ConfigScraper)
+		ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient, Scraper: &scraper, Connections: Connections}
+		diagnostics = append(diagnostics, Diagnose(ctx, scraper)...)
+		return true
+	})
+	lastDiagnosis.Lock()
+	defer lastDiagnosis.Unlock()
+	lastDiagnosis.time = time.Now()
+	lastDiagnosis.diagnostics = diagnostics
+	lastDiagnosis.running = false
+	return diagnostics
+}
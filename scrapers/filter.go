@@ -0,0 +1,190 @@
+package scrapers
+
+import (
+	"fmt"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/scrapers/processors"
+	"github.com/gobwas/glob"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+type selector struct {
+	types, names []glob.Glob
+	tags         map[string]glob.Glob
+	expr         cel.Program
+}
+
+// itemFilter applies the includes and excludes of a scraper to the scraped items
+type itemFilter struct {
+	includes, excludes []selector
+}
+
+func newItemFilter(ctx *v1.ScrapeContext, config v1.ConfigScraper) (*itemFilter, error) {
+	filter := &itemFilter{}
+	if len(config.Includes) == 0 && len(config.Excludes) == 0 {
+		return filter, nil
+	}
+	env, err := newCELEnv(processors.NewHelpers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if filter.includes, err = compileSelectors(env, config.Includes); err != nil {
+		return nil, fmt.Errorf("invalid includes: %v", err)
+	}
+	if filter.excludes, err = compileSelectors(env, config.Excludes); err != nil {
+		return nil, fmt.Errorf("invalid excludes: %v", err)
+	}
+	return filter, nil
+}
+
+func compileSelectors(env *cel.Env, selectors []v1.ConfigSelector) ([]selector, error) {
+	var compiled []selector
+	for _, s := range selectors {
+		var c selector
+		for _, t := range s.Types {
+			g, err := glob.Compile(t)
+			if err != nil {
+				return nil, fmt.Errorf("invalid type %s: %v", t, err)
+			}
+			c.types = append(c.types, g)
+		}
+		for _, n := range s.Names {
+			g, err := glob.Compile(n)
+			if err != nil {
+				return nil, fmt.Errorf("invalid name %s: %v", n, err)
+			}
+			c.names = append(c.names, g)
+		}
+		for key, value := range s.Tags {
+			g, err := glob.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag %s: %v", key, err)
+			}
+			if c.tags == nil {
+				c.tags = make(map[string]glob.Glob)
+			}
+			c.tags[key] = g
+		}
+		if s.Expr != "" {
+			program, err := compileExpr(env, s.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expr %s: %v", s.Expr, err)
+			}
+			c.expr = program
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// newCELEnv declares the variables of the item and the lookupConfig(type, name) and getSecret(name) helpers
+func newCELEnv(helpers processors.Helpers) (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("id", decls.String),
+			decls.NewVar("name", decls.String),
+			decls.NewVar("type", decls.String),
+			decls.NewVar("namespace", decls.String),
+			decls.NewVar("tags", decls.NewMapType(decls.String, decls.String)),
+			decls.NewVar("config", decls.Dyn),
+		),
+		cel.Function("lookupConfig",
+			cel.Overload("lookupConfig_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.DynType,
+				cel.BinaryBinding(func(configType, name ref.Val) ref.Val {
+					config, err := helpers.LookupConfig(fmt.Sprint(configType.Value()), fmt.Sprint(name.Value()))
+					if err != nil {
+						return types.NewErr(err.Error())
+					}
+					if config == nil {
+						return types.NullValue
+					}
+					return types.DefaultTypeAdapter.NativeToValue(config)
+				}))),
+		cel.Function("getSecret",
+			cel.Overload("getSecret_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(name ref.Val) ref.Val {
+					value, err := helpers.GetSecret(fmt.Sprint(name.Value()))
+					if err != nil {
+						return types.NewErr(err.Error())
+					}
+					return types.String(value)
+				}))),
+	)
+}
+
+func compileExpr(env *cel.Env, expr string) (cel.Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expected a bool expression, got %s", ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// Keep returns false when the item is excluded or not included
+func (f *itemFilter) Keep(result v1.ScrapeResult) (bool, error) {
+	for _, s := range f.excludes {
+		if matched, err := s.matches(result); err != nil || matched {
+			return false, err
+		}
+	}
+	if len(f.includes) == 0 {
+		return true, nil
+	}
+	for _, s := range f.includes {
+		if matched, err := s.matches(result); err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+func (s selector) matches(result v1.ScrapeResult) (bool, error) {
+	if len(s.types) > 0 && !matchAny(s.types, result.Type) {
+		return false, nil
+	}
+	if len(s.names) > 0 && !matchAny(s.names, result.Name) {
+		return false, nil
+	}
+	for key, g := range s.tags {
+		value, ok := result.Tags[key]
+		if !ok || !g.Match(value) {
+			return false, nil
+		}
+	}
+	if s.expr == nil {
+		return true, nil
+	}
+	tags := map[string]string(result.Tags)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	out, _, err := s.expr.Eval(map[string]interface{}{
+		"id":        result.ID,
+		"name":      result.Name,
+		"type":      result.Type,
+		"namespace": result.Namespace,
+		"tags":      tags,
+		"config":    result.Config,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate selector of %s: %v", result, err)
+	}
+	matched, _ := out.Value().(bool)
+	return matched, nil
+}
+
+func matchAny(globs []glob.Glob, value string) bool {
+	for _, g := range globs {
+		if g.Match(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils"
+)
+
+type Workflow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	State     string    `json:"state"`
+	HTMLURL   string    `json:"html_url"`
+	BadgeURL  string    `json:"badge_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Workflows struct {
+	TotalCount int        `json:"total_count"`
+	Workflows  []Workflow `json:"workflows"`
+}
+
+type Actor struct {
+	Login string `json:"login"`
+}
+
+type Run struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	RunNumber    int       `json:"run_number"`
+	RunAttempt   int       `json:"run_attempt"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status"`
+	Conclusion   string    `json:"conclusion"`
+	HeadBranch   string    `json:"head_branch"`
+	HeadSHA      string    `json:"head_sha"`
+	Actor        Actor     `json:"actor"`
+	HTMLURL      string    `json:"html_url"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	RunStartedAt time.Time `json:"run_started_at"`
+	//Duration in milliseconds
+	Duration int `json:"duration"`
+}
+
+type Runs struct {
+	TotalCount   int   `json:"total_count"`
+	WorkflowRuns []Run `json:"workflow_runs"`
+}
+
+func (gh *GitHubClient) GetWorkflows(repository string) ([]Workflow, error) {
+	var workflows []Workflow
+	for page := 1; ; page++ {
+		var response Workflows
+		if err := gh.get(fmt.Sprintf("/repos/%s/actions/workflows?per_page=%d&page=%d", repository, perPage, page), &response); err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, response.Workflows...)
+		if len(response.Workflows) < perPage {
+			return workflows, nil
+		}
+	}
+}
+
+// GetWorkflowRuns returns the most recent completed runs of a workflow, runs in progress are recorded once they
+// complete
+func (gh *GitHubClient) GetWorkflowRuns(repository string, workflow Workflow) ([]Run, error) {
+	var runs Runs
+	if err := gh.get(fmt.Sprintf("/repos/%s/actions/workflows/%d/runs?status=completed&per_page=%d", repository, workflow.ID, perPage), &runs); err != nil {
+		return nil, err
+	}
+	var results []Run
+	for _, run := range runs.WorkflowRuns {
+		if !run.RunStartedAt.IsZero() {
+			run.Duration = int(run.UpdatedAt.Sub(run.RunStartedAt).Milliseconds())
+		}
+		results = append(results, run)
+	}
+	return results, nil
+}
+
+type GitHubActionsScraper struct {
+}
+
+// Scrape ...
+func (gh GitHubActionsScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScraper) v1.ScrapeResults {
+	results := v1.ScrapeResults{}
+	for _, config := range configs.GitHubActions {
+		client, err := NewGitHubClient(ctx, config.PersonalAccessToken)
+		if err != nil {
+			results.Errorf(err, "failed to create github client for %s", config.Owner)
+			continue
+		}
+
+		repositories, err := client.GetRepositories(config.Owner)
+		if err != nil {
+			results.Errorf(err, "failed to get repositories for %s", config.Owner)
+			continue
+		}
+		for _, repository := range repositories {
+			if repository.Archived || !utils.MatchItems(repository.Name, config.Repositories...) {
+				continue
+			}
+
+			logger.Debugf("Scraping workflows of %s", repository.FullName)
+			workflows, err := client.GetWorkflows(repository.FullName)
+			if err != nil {
+				results.Errorf(err, "failed to get workflows for %s", repository.FullName)
+				continue
+			}
+			for _, workflow := range workflows {
+				if !utils.MatchItems(workflow.Name, config.Workflows...) {
+					continue
+				}
+
+				runs, err := client.GetWorkflowRuns(repository.FullName, workflow)
+				if err != nil {
+					results.Errorf(err, "failed to get workflow runs for %s/%s", repository.FullName, workflow.Name)
+					continue
+				}
+				id := fmt.Sprintf("%s/%d", repository.FullName, workflow.ID)
+				var changes []v1.ChangeResult
+				for _, _run := range runs {
+					var run = _run
+					severity := "info"
+					if run.Conclusion != "success" && run.Conclusion != "skipped" {
+						severity = "failed"
+					}
+					changes = append(changes, v1.ChangeResult{
+						ChangeType:       "WorkflowRun",
+						CreatedAt:        &run.CreatedAt,
+						Severity:         severity,
+						ExternalID:       id,
+						ExternalType:     v1.GitHubActionsWorkflow,
+						Source:           run.HTMLURL,
+						Summary:          fmt.Sprintf("#%d %s on %s by %s", run.RunNumber, run.Conclusion, run.HeadBranch, run.Actor.Login),
+						Details:          v1.NewJSON(run),
+						ExternalChangeID: fmt.Sprintf("%s/%d/%d", repository.FullName, run.ID, run.RunAttempt),
+					})
+				}
+
+				results = append(results, v1.ScrapeResult{
+					BaseScraper:  config.BaseScraper,
+					Type:         "Workflow",
+					Config:       workflow,
+					ExternalType: v1.GitHubActionsWorkflow,
+					ID:           id,
+					Name:         workflow.Name,
+					Tags:         map[string]string{"repository": repository.FullName},
+					Changes:      changes,
+					Aliases:      []string{repository.FullName + "/" + workflow.Path},
+				})
+			}
+		}
+	}
+	return results
+}
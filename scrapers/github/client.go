@@ -0,0 +1,119 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/config-db/utils"
+	"github.com/flanksource/kommons"
+	"github.com/go-resty/resty/v2"
+)
+
+const perPage = 100
+
+type GitHubClient struct {
+	*resty.Client
+	*v1.ScrapeContext
+}
+
+func NewGitHubClient(ctx *v1.ScrapeContext, token kommons.EnvVar) (*GitHubClient, error) {
+	value, err := secrets.GetEnvValue(ctx, token, ctx.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get personal access token: %v", err)
+	}
+	client := utils.RetryRateLimited(resty.New()).
+		SetBaseURL("https://api.github.com").
+		SetHeader("Accept", "application/vnd.github+json").
+		SetAuthToken(value)
+
+	return &GitHubClient{
+		ScrapeContext: ctx,
+		Client:        client,
+	}, nil
+}
+
+// errNotFound is returned for resources that do not exist or that the token cannot access
+var errNotFound = errors.New("not found")
+
+// get decodes a single page of a resource
+func (gh *GitHubClient) get(url string, result interface{}) error {
+	response, err := gh.R().SetResult(result).Get(url)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errNotFound, url)
+	}
+	if response.IsError() {
+		return fmt.Errorf("%s: %s", response.Status(), response.String())
+	}
+	return nil
+}
+
+// list calls fn with every item of a collection, pages are requested until one has less than perPage items
+func (gh *GitHubClient) list(url string, fn func(item json.RawMessage) error) error {
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	for page := 1; ; page++ {
+		var items []json.RawMessage
+		if err := gh.get(fmt.Sprintf("%s%sper_page=%d&page=%d", url, separator, perPage, page), &items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(items) < perPage {
+			return nil
+		}
+	}
+}
+
+type Repository struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description,omitempty"`
+	Private       bool      `json:"private"`
+	Visibility    string    `json:"visibility"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	DefaultBranch string    `json:"default_branch"`
+	Topics        []string  `json:"topics,omitempty"`
+	HTMLURL       string    `json:"html_url"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// GetRepositories returns the repositories of an organization, or of a user when the owner is not an organization
+func (gh *GitHubClient) GetRepositories(owner string) ([]Repository, error) {
+	var repositories []Repository
+	path := "/orgs/" + owner + "/repos"
+	for page := 1; ; page++ {
+		var result []Repository
+		response, err := gh.R().SetResult(&result).Get(fmt.Sprintf("%s?per_page=%d&page=%d", path, perPage, page))
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode() == http.StatusNotFound && page == 1 && path != "/users/"+owner+"/repos" {
+			path = "/users/" + owner + "/repos"
+			page = 0
+			continue
+		}
+		if response.IsError() {
+			return nil, fmt.Errorf("%s: %s", response.Status(), response.String())
+		}
+		repositories = append(repositories, result...)
+		if len(result) < perPage {
+			return repositories, nil
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils"
+)
+
+// defaultBranchUnprotected is the analyzer of repositories whose default branch has no protection rules, its
+// category and severity are defined in scrapers/analysis/rules.yaml
+const defaultBranchUnprotected = "github-default-branch-unprotected"
+
+type Organization struct {
+	ID                           int64     `json:"id"`
+	Login                        string    `json:"login"`
+	Name                         string    `json:"name,omitempty"`
+	Description                  string    `json:"description,omitempty"`
+	HTMLURL                      string    `json:"html_url"`
+	TwoFactorRequirementEnabled  bool      `json:"two_factor_requirement_enabled"`
+	DefaultRepositoryPermission  string    `json:"default_repository_permission,omitempty"`
+	MembersCanCreateRepositories bool      `json:"members_can_create_repositories"`
+	CreatedAt                    time.Time `json:"created_at"`
+}
+
+type Team struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description,omitempty"`
+	Privacy     string `json:"privacy"`
+	Parent      *struct {
+		Slug string `json:"slug"`
+	} `json:"parent,omitempty"`
+	// Repositories are the roles of the team on the repositories of the organization by full name
+	Repositories map[string]string `json:"repositories,omitempty"`
+}
+
+type Webhook struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string      `json:"url"`
+		ContentType string      `json:"content_type,omitempty"`
+		InsecureSSL interface{} `json:"insecure_ssl,omitempty"`
+	} `json:"config"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GitHubScraper struct {
+}
+
+// Scrape ...
+func (gh GitHubScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScraper) v1.ScrapeResults {
+	results := v1.ScrapeResults{}
+	for _, config := range configs.GitHub {
+		client, err := NewGitHubClient(ctx, config.PersonalAccessToken)
+		if err != nil {
+			results.Errorf(err, "failed to create github client for %s", config.Organization)
+			continue
+		}
+
+		var organization Organization
+		if err := client.get("/orgs/"+config.Organization, &organization); err != nil {
+			results.Errorf(err, "failed to get organization %s", config.Organization)
+			continue
+		}
+		results = append(results, v1.ScrapeResult{
+			BaseScraper:  config.BaseScraper,
+			Type:         "Organization",
+			ExternalType: v1.GitHubOrganization,
+			ID:           organization.Login,
+			Name:         organization.Login,
+			Config:       organization,
+			CreatedAt:    &organization.CreatedAt,
+		})
+		gh.webhooks(client, config, "/orgs/"+organization.Login+"/hooks", v1.GitHubOrganization, organization.Login, &results)
+
+		repositories, err := client.GetRepositories(organization.Login)
+		if err != nil {
+			results.Errorf(err, "failed to get repositories for %s", organization.Login)
+			continue
+		}
+		scraped := make(map[string]bool)
+		for _, _repository := range repositories {
+			var repository = _repository
+			if !utils.MatchItems(repository.Name, config.Repositories...) {
+				continue
+			}
+			scraped[repository.FullName] = true
+			results = append(results, v1.ScrapeResult{
+				BaseScraper:        config.BaseScraper,
+				Type:               "Repository",
+				ExternalType:       v1.GitHubRepository,
+				ID:                 repository.FullName,
+				Name:               repository.Name,
+				Config:             repository,
+				CreatedAt:          &repository.CreatedAt,
+				ParentExternalID:   organization.Login,
+				ParentExternalType: v1.GitHubOrganization,
+			})
+			gh.branchProtections(client, config, repository, &results)
+			gh.webhooks(client, config, "/repos/"+repository.FullName+"/hooks", v1.GitHubRepository, repository.FullName, &results)
+		}
+
+		gh.teams(client, config, organization, scraped, &results)
+	}
+	return results
+}
+
+// branchProtections scrapes the protection rules of the protected branches of a repository and flags the repository
+// when its default branch is not protected
+func (gh GitHubScraper) branchProtections(client *GitHubClient, config v1.GitHub, repository Repository, results *v1.ScrapeResults) {
+	var branches []string
+	err := client.list("/repos/"+repository.FullName+"/branches?protected=true", func(item json.RawMessage) error {
+		var branch struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(item, &branch); err != nil {
+			return err
+		}
+		branches = append(branches, branch.Name)
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to get protected branches of %s", repository.FullName)
+		return
+	}
+
+	defaultProtected := false
+	for _, branch := range branches {
+		if branch == repository.DefaultBranch {
+			defaultProtected = true
+		}
+		var protection map[string]interface{}
+		if err := client.get("/repos/"+repository.FullName+"/branches/"+url.PathEscape(branch)+"/protection", &protection); err != nil {
+			// branches protected by rulesets have no protection rules
+			if !errors.Is(err, errNotFound) {
+				results.Errorf(err, "failed to get protection of %s/%s", repository.FullName, branch)
+			}
+			continue
+		}
+		*results = append(*results, v1.ScrapeResult{
+			BaseScraper:        config.BaseScraper,
+			Type:               "BranchProtection",
+			ExternalType:       v1.GitHubBranchProtection,
+			ID:                 repository.FullName + "/" + branch,
+			Name:               branch,
+			Config:             protection,
+			ParentExternalID:   repository.FullName,
+			ParentExternalType: v1.GitHubRepository,
+		})
+	}
+
+	if !defaultProtected && !repository.Archived && repository.DefaultBranch != "" {
+		analysis := results.Analysis(defaultBranchUnprotected, v1.GitHubRepository, repository.FullName)
+		analysis.Summary = fmt.Sprintf("default branch %s is not protected", repository.DefaultBranch)
+		analysis.Message(fmt.Sprintf("the default branch %s of %s can be pushed to and deleted without review", repository.DefaultBranch, repository.FullName))
+	}
+}
+
+// webhooks scrapes the webhooks of an organization or a repository, webhooks are only visible to admins
+func (gh GitHubScraper) webhooks(client *GitHubClient, config v1.GitHub, path, parentType, parentID string, results *v1.ScrapeResults) {
+	err := client.list(path, func(item json.RawMessage) error {
+		var webhook Webhook
+		if err := json.Unmarshal(item, &webhook); err != nil {
+			return err
+		}
+		*results = append(*results, v1.ScrapeResult{
+			BaseScraper:        config.BaseScraper,
+			Type:               "Webhook",
+			ExternalType:       v1.GitHubWebhook,
+			ID:                 fmt.Sprintf("%s/%d", parentID, webhook.ID),
+			Name:               webhook.Config.URL,
+			Config:             webhook,
+			CreatedAt:          &webhook.CreatedAt,
+			ParentExternalID:   parentID,
+			ParentExternalType: parentType,
+		})
+		return nil
+	})
+	if errors.Is(err, errNotFound) {
+		logger.Debugf("Skipping webhooks of %s: %v", parentID, err)
+	} else if err != nil {
+		results.Errorf(err, "failed to get webhooks of %s", parentID)
+	}
+}
+
+// teams scrapes the teams of the organization with their roles on its repositories, teams are related to the
+// scraped repositories they have access to
+func (gh GitHubScraper) teams(client *GitHubClient, config v1.GitHub, organization Organization, repositories map[string]bool, results *v1.ScrapeResults) {
+	var teams []Team
+	err := client.list("/orgs/"+organization.Login+"/teams", func(item json.RawMessage) error {
+		var team Team
+		if err := json.Unmarshal(item, &team); err != nil {
+			return err
+		}
+		teams = append(teams, team)
+		return nil
+	})
+	if err != nil {
+		results.Errorf(err, "failed to get teams of %s", organization.Login)
+		return
+	}
+
+	for _, team := range teams {
+		id := v1.ExternalID{ExternalID: []string{organization.Login + "/" + team.Slug}, ExternalType: v1.GitHubTeam}
+		var relationships v1.RelationshipResults
+		team.Repositories = make(map[string]string)
+		err := client.list("/orgs/"+organization.Login+"/teams/"+team.Slug+"/repos", func(item json.RawMessage) error {
+			var repository struct {
+				FullName string `json:"full_name"`
+				RoleName string `json:"role_name"`
+			}
+			if err := json.Unmarshal(item, &repository); err != nil {
+				return err
+			}
+			team.Repositories[repository.FullName] = repository.RoleName
+			if repositories[repository.FullName] {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  id,
+					RelatedExternalID: v1.ExternalID{ExternalID: []string{repository.FullName}, ExternalType: v1.GitHubRepository},
+					Relationship:      "TeamRepository",
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			results.Errorf(err, "failed to get repositories of team %s", team.Slug)
+		}
+
+		*results = append(*results, v1.ScrapeResult{
+			BaseScraper:         config.BaseScraper,
+			Type:                "Team",
+			ExternalType:        v1.GitHubTeam,
+			ID:                  id.ExternalID[0],
+			Name:                team.Name,
+			Config:              team,
+			ParentExternalID:    organization.Login,
+			ParentExternalType:  v1.GitHubOrganization,
+			RelationshipResults: relationships,
+		})
+	}
+}
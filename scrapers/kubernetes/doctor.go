@@ -0,0 +1,29 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// Diagnose verifies that the kubernetes API of the cluster is reachable with the current kubeconfig
+func Diagnose(ctx *v1.ScrapeContext, clusterName string) v1.Diagnostic {
+	d := v1.Diagnostic{Check: strings.TrimSpace("kubernetes api " + clusterName)}
+	if ctx.Kommons == nil {
+		d.Error = "no kubernetes connection, kubernetes is disabled or the kubeconfig could not be loaded"
+		return d
+	}
+	client, err := ctx.Kommons.GetClientset()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Check = fmt.Sprintf("%s (%s)", d.Check, version.GitVersion)
+	return d
+}
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// eventChange returns the change recorded on the object involved in an event e.g. OOMKilling, FailedScheduling or
+// BackOff, events of the events.k8s.io group are skipped as they are the same events served by the core group
+func eventChange(obj *unstructured.Unstructured) *v1.ChangeResult {
+	if obj.GetAPIVersion() != "v1" {
+		return nil
+	}
+	var event k8sv1.Event
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &event); err != nil {
+		return nil
+	}
+	if event.InvolvedObject.UID == "" {
+		return nil
+	}
+
+	createdAt := event.LastTimestamp.Time
+	if createdAt.IsZero() {
+		createdAt = event.EventTime.Time
+	}
+	if createdAt.IsZero() {
+		createdAt = event.CreationTimestamp.Time
+	}
+	severity := "info"
+	if event.Type == k8sv1.EventTypeWarning {
+		severity = "warning"
+	}
+	// a repeated event keeps its uid and increments its count, a change is recorded for every count that is observed.
+	// Occurrences between two observations e.g. while config-db was down are only reflected in the count
+	count := event.Count
+	if event.Series != nil {
+		count = event.Series.Count
+	}
+	source := event.Source.Component
+	if source == "" {
+		source = event.ReportingController
+	}
+
+	return &v1.ChangeResult{
+		ExternalID:       string(event.InvolvedObject.UID),
+		ExternalType:     ExternalTypePrefix + event.InvolvedObject.Kind,
+		ExternalChangeID: fmt.Sprintf("%s/%d", event.UID, count),
+		ChangeType:       event.Reason,
+		Summary:          strings.TrimSpace(event.Message),
+		Severity:         severity,
+		Source:           ExternalTypePrefix + "Event",
+		CreatedAt:        &createdAt,
+		Details: map[string]interface{}{
+			"type":           event.Type,
+			"component":      source,
+			"host":           event.Source.Host,
+			"count":          count,
+			"fieldPath":      event.InvolvedObject.FieldPath,
+			"firstTimestamp": timeOrNil(event.FirstTimestamp.Time),
+			"lastTimestamp":  timeOrNil(event.LastTimestamp.Time),
+		},
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,208 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/commons/collections"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ContainerImage is an image run by the pods of the cluster, images are identified by their digest
+type ContainerImage struct {
+	Repository string   `json:"repository"`
+	Digest     string   `json:"digest"`
+	Tags       []string `json:"tags,omitempty"`
+	// References are the images of the pod specs that resolved to the digest e.g. nginx:1.25
+	References []string `json:"references,omitempty"`
+}
+
+// podImage is the image of a container status, the digest is empty until the image has been pulled
+type podImage struct {
+	Reference  string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// podImages returns the images of the containers of a pod that have a digest
+func podImages(obj *unstructured.Unstructured) []podImage {
+	var images []podImage
+	for _, field := range []string{"initContainerStatuses", "containerStatuses", "ephemeralContainerStatuses"} {
+		statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", field)
+		for _, status := range statuses {
+			spec, ok := status.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image, _, _ := unstructured.NestedString(spec, "image")
+			imageID, _, _ := unstructured.NestedString(spec, "imageID")
+			if parsed := parseImage(image, imageID); parsed.Digest != "" {
+				images = append(images, parsed)
+			}
+		}
+	}
+	return images
+}
+
+// parseImage parses the image of a container status and its image id, e.g. docker-pullable://nginx@sha256:...
+func parseImage(image, imageID string) podImage {
+	parsed := podImage{Reference: image}
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		imageID = imageID[i+3:]
+	}
+	if i := strings.Index(imageID, "@"); i >= 0 {
+		parsed.Repository, parsed.Digest = imageID[:i], imageID[i+1:]
+	} else if strings.HasPrefix(imageID, "sha256:") {
+		parsed.Digest = imageID
+	}
+
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, parsed.Tag = name[:i], name[i+1:]
+	}
+	if parsed.Repository == "" {
+		parsed.Repository = name
+	}
+	return parsed
+}
+
+// imageID returns the id of an image of a cluster, the same image run by several clusters is an item of each cluster
+func imageID(cluster, digest string) string {
+	return cluster + "/" + digest
+}
+
+// imageRelationships relates a pod to the images its containers run
+func imageRelationships(cluster string, obj *unstructured.Unstructured) v1.RelationshipResults {
+	if obj.GetKind() != "Pod" {
+		return nil
+	}
+	var relationships v1.RelationshipResults
+	seen := make(map[string]bool)
+	for _, image := range podImages(obj) {
+		if seen[image.Digest] {
+			continue
+		}
+		seen[image.Digest] = true
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID: v1.ExternalID{
+				ExternalID:   []string{string(obj.GetUID())},
+				ExternalType: ExternalTypePrefix + "Pod",
+			},
+			RelatedExternalID: v1.ExternalID{
+				ExternalID:   []string{imageID(cluster, image.Digest)},
+				ExternalType: ExternalTypePrefix + "ContainerImage",
+			},
+			Relationship: "PodContainerImage",
+		})
+	}
+	return relationships
+}
+
+// containerImages returns the images run by the pods of the cluster deduplicated by digest, and the digests of the
+// image references of the pod specs
+func containerImages(objs []*unstructured.Unstructured) ([]ContainerImage, map[string]string) {
+	images := make(map[string]*ContainerImage)
+	digests := make(map[string]string)
+	for _, obj := range objs {
+		if obj.GetKind() != "Pod" {
+			continue
+		}
+		for _, image := range podImages(obj) {
+			digests[image.Reference] = image.Digest
+			existing, ok := images[image.Digest]
+			if !ok {
+				existing = &ContainerImage{Repository: image.Repository, Digest: image.Digest}
+				images[image.Digest] = existing
+			}
+			if image.Tag != "" && !collections.Contains(existing.Tags, image.Tag) {
+				existing.Tags = append(existing.Tags, image.Tag)
+			}
+			if !collections.Contains(existing.References, image.Reference) {
+				existing.References = append(existing.References, image.Reference)
+			}
+		}
+	}
+
+	var list []ContainerImage
+	for _, image := range images {
+		sort.Strings(image.Tags)
+		sort.Strings(image.References)
+		list = append(list, *image)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Digest < list[j].Digest })
+	return list, digests
+}
+
+// vulnerabilityAnalysis attaches the vulnerabilities of the VulnerabilityReports of the Trivy operator to the
+// images they scanned, reports are matched by digest or by the image reference of the scanned container
+func vulnerabilityAnalysis(cluster string, objs []*unstructured.Unstructured, digests map[string]string, results *v1.ScrapeResults) {
+	seen := make(map[string]bool)
+	for _, obj := range objs {
+		if obj.GetKind() != "VulnerabilityReport" {
+			continue
+		}
+		digest, _, _ := unstructured.NestedString(obj.Object, "report", "artifact", "digest")
+		if digest == "" {
+			server, _, _ := unstructured.NestedString(obj.Object, "report", "registry", "server")
+			repository, _, _ := unstructured.NestedString(obj.Object, "report", "artifact", "repository")
+			tag, _, _ := unstructured.NestedString(obj.Object, "report", "artifact", "tag")
+			for _, reference := range []string{repository + ":" + tag, server + "/" + repository + ":" + tag} {
+				if digests[reference] != "" {
+					digest = digests[reference]
+					break
+				}
+			}
+		}
+		if digest == "" {
+			continue
+		}
+
+		vulnerabilities, _, _ := unstructured.NestedSlice(obj.Object, "report", "vulnerabilities")
+		for _, vulnerability := range vulnerabilities {
+			v, ok := vulnerability.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			field := func(name string) string {
+				value, _, _ := unstructured.NestedString(v, name)
+				return value
+			}
+			id := field("vulnerabilityID")
+			if id == "" || seen[digest+"/"+id] {
+				continue
+			}
+			seen[digest+"/"+id] = true
+
+			analysis := results.Analysis("trivy:"+id, ExternalTypePrefix+"ContainerImage", imageID(cluster, digest))
+			analysis.AnalysisType = "security"
+			analysis.Severity = trivySeverity(field("severity"))
+			analysis.Status = "open"
+			analysis.Summary = fmt.Sprintf("%s in %s %s", id, field("resource"), field("installedVersion"))
+			analysis.Message(field("title"))
+			if fixed := field("fixedVersion"); fixed != "" {
+				analysis.Message(fmt.Sprintf("fixed in %s %s", field("resource"), fixed))
+			}
+			analysis.Analysis = map[string]string{
+				"vulnerability":    id,
+				"resource":         field("resource"),
+				"installedVersion": field("installedVersion"),
+				"fixedVersion":     field("fixedVersion"),
+				"link":             field("primaryLink"),
+				"report":           obj.GetNamespace() + "/" + obj.GetName(),
+			}
+		}
+	}
+}
+
+func trivySeverity(severity string) string {
+	switch severity {
+	case "CRITICAL", "HIGH", "MEDIUM", "LOW":
+		return strings.ToLower(severity)
+	}
+	return "info"
+}
@@ -1,14 +1,29 @@
 package kubernetes
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/flanksource/commons/collections"
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
-	"github.com/flanksource/ketall"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/ketall/client"
+	"github.com/flanksource/ketall/filter"
 	"github.com/flanksource/ketall/options"
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type KubernetesScraper struct {
@@ -16,90 +31,273 @@ type KubernetesScraper struct {
 
 const ExternalTypePrefix = "Kubernetes::"
 
+// volatileFields are removed from objects as they change on every update of the object
+var volatileFields = [][]string{{"metadata", "managedFields"}, {"metadata", "resourceVersion"}}
+
+// statusChangeExclusions ignores the status of objects when detecting changes, it is updated by controllers
+// independently of the spec e.g. the heartbeats of nodes
+var statusChangeExclusions = []string{"$.status"}
+
+// ecrImage matches the repository of images pulled from ECR, e.g. 123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:v1
+var ecrImage = regexp.MustCompile(`^(\d{12}\.dkr\.ecr\.[a-z0-9-]+\.amazonaws\.com(\.cn)?/[^:@]+)`)
+
 // Scrape ...
 func (kubernetes KubernetesScraper) Scrape(ctx *v1.ScrapeContext, configs v1.ConfigScraper) v1.ScrapeResults {
 
 	results := v1.ScrapeResults{}
 	for _, config := range configs.Kubernetes {
-		if config.ClusterName == "" {
+		if config.ClusterName == "" && len(config.Contexts) == 0 {
 			logger.Fatalf("clusterName missing from kubernetes configuration")
 		}
 
-		opts := options.NewDefaultCmdOptions()
-		opts = updateOptions(opts, config)
+		kubeconfig, temporary, err := kubeconfigPath(ctx, config)
+		if err != nil {
+			results.Errorf(err, "failed to get the kubeconfig of %s", config.ClusterName)
+			continue
+		}
+		if temporary {
+			defer os.Remove(kubeconfig)
+		}
+		clusters, err := kubeconfigContexts(kubeconfig, config)
+		if err != nil {
+			results.Errorf(err, "failed to get the contexts of %s", config.ClusterName)
+			continue
+		}
 
-		objs := ketall.KetAll(opts)
+		// clusters are scraped concurrently, the results are kept in the order of the contexts
+		clusterResults := make([]v1.ScrapeResults, len(clusters))
+		var wg sync.WaitGroup
+		for i, cluster := range clusters {
+			wg.Add(1)
+			go func(i int, cluster kubeconfigContext) {
+				defer wg.Done()
+				clusterResults[i] = scrapeCluster(config, cluster, kubeconfig)
+			}(i, cluster)
+		}
+		wg.Wait()
+		for _, r := range clusterResults {
+			results = append(results, r...)
+		}
+	}
+	return results
+}
 
-		// {Namespace: {Kind: {Name: ID}}}
-		resourceIDMap := make(map[string]map[string]map[string]string)
+// kubeconfigContext is a cluster scraped using a context of the kubeconfig, the current context when empty
+type kubeconfigContext struct {
+	Name    string
+	Context string
+}
 
-		for _, obj := range objs {
-			if collections.Contains([]string{"Namespace", "Deployment", "Node"}, obj.GetKind()) {
-				if resourceIDMap[obj.GetNamespace()] == nil {
-					resourceIDMap[obj.GetNamespace()] = make(map[string]map[string]string)
-				}
-				if resourceIDMap[obj.GetNamespace()][obj.GetKind()] == nil {
-					resourceIDMap[obj.GetNamespace()][obj.GetKind()] = make(map[string]string)
-				}
-				resourceIDMap[obj.GetNamespace()][obj.GetKind()][obj.GetName()] = string(obj.GetUID())
+// kubeconfigPath returns the path of the kubeconfig of the config, a kubeconfig given as a value e.g. from a secret
+// is written to a temporary file, the default kubeconfig is used when it is empty
+func kubeconfigPath(ctx *v1.ScrapeContext, config v1.Kubernetes) (path string, temporary bool, err error) {
+	if config.Kubeconfig == nil {
+		return "", false, nil
+	}
+	value, err := secrets.GetEnvValue(ctx, *config.Kubeconfig, ctx.Namespace)
+	if err != nil || value == "" {
+		return "", false, err
+	}
+	if !strings.Contains(value, "\n") {
+		if _, err := os.Stat(value); err == nil {
+			return value, false, nil
+		}
+	}
+	file, err := os.CreateTemp("", "kubeconfig-")
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(value); err != nil {
+		os.Remove(file.Name())
+		return "", false, err
+	}
+	return file.Name(), true, nil
+}
+
+// kubeconfigContexts returns the clusters to scrape, either the current context named after the cluster name
+// or the contexts of the config named after the context, * matches every context of the kubeconfig
+func kubeconfigContexts(kubeconfig string, config v1.Kubernetes) ([]kubeconfigContext, error) {
+	if len(config.Contexts) == 0 {
+		return []kubeconfigContext{{Name: config.ClusterName}}, nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	kc, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []kubeconfigContext
+	for _, name := range config.Contexts {
+		if name != "*" {
+			if _, ok := kc.Contexts[name]; !ok {
+				return nil, fmt.Errorf("context %s not found in kubeconfig", name)
 			}
+			clusters = append(clusters, kubeconfigContext{Name: name, Context: name})
+			continue
 		}
+		var all []string
+		for context := range kc.Contexts {
+			all = append(all, context)
+		}
+		sort.Strings(all)
+		for _, context := range all {
+			clusters = append(clusters, kubeconfigContext{Name: context, Context: context})
+		}
+	}
+	return clusters, nil
+}
 
-		// Add Cluster object first
-		clusterID := "Kubernetes/Cluster/" + config.ClusterName
-		results = append(results, v1.ScrapeResult{
-			BaseScraper:  config.BaseScraper,
-			Name:         config.ClusterName,
-			Type:         "Cluster",
-			ExternalType: ExternalTypePrefix + "Cluster",
-			Config:       make(map[string]string),
-			ID:           clusterID,
-		})
+// getAll returns every object of the cluster, unlike ketall.KetAll it returns the error of an unreachable cluster
+// instead of exiting
+func getAll(opts *options.KetallOptions) ([]*unstructured.Unstructured, error) {
+	all, err := client.GetAllServerResources(opts)
+	if err != nil {
+		return nil, err
+	}
+	filtered, ok := filter.ApplyFilter(all, opts.Since).(*k8sv1.List)
+	if !ok {
+		return nil, nil
+	}
+	var objs []*unstructured.Unstructured
+	for _, item := range filtered.Items {
+		if obj, ok := item.Object.(*unstructured.Unstructured); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+func scrapeCluster(config v1.Kubernetes, cluster kubeconfigContext, kubeconfig string) v1.ScrapeResults {
+	results := v1.ScrapeResults{}
+
+	opts := options.NewDefaultCmdOptions()
+	opts = updateOptions(opts, config)
+	if kubeconfig != "" {
+		opts.GenericCliFlags.KubeConfig = &kubeconfig
+	}
+	if cluster.Context != "" {
+		opts.GenericCliFlags.Context = &cluster.Context
+	}
+	if len(config.Include) > 0 || len(config.Exclude) > 0 {
+		exclusions, err := discoverExclusions(opts, config)
+		if err != nil {
+			return results.Errorf(err, "failed to discover the resources of %s", cluster.Name)
+		}
+		opts.Exclusions = append(append([]string{}, opts.Exclusions...), exclusions...)
+	}
 
-		resourceIDMap[""]["Cluster"] = make(map[string]string)
-		resourceIDMap[""]["Cluster"][config.ClusterName] = clusterID
-		// For shorthand
-		resourceIDMap[""]["Cluster"]["selfRef"] = clusterID
+	var objs []*unstructured.Unstructured
+	var err error
+	if config.NamespaceSelector != nil {
+		objs, err = getSelected(opts, *config.NamespaceSelector)
+	} else {
+		objs, err = getAll(opts)
+	}
+	if err != nil {
+		return results.Errorf(err, "failed to get the resources of %s", cluster.Name)
+	}
 
+	base := config.BaseScraper
+	if !config.KeepVolatileFields {
+		base.Transform.ChangeExclusions = append(append([]string{}, base.Transform.ChangeExclusions...), statusChangeExclusions...)
 		for _, obj := range objs {
-			var relationships v1.RelationshipResults
-			if obj.GetKind() == "Pod" {
-				spec := obj.Object["spec"].(map[string]interface{})
-				if spec["nodeName"] != nil {
-					nodeName := spec["nodeName"].(string)
-					nodeID := resourceIDMap[""]["Node"][nodeName]
-					relationships = append(relationships, v1.RelationshipResult{
-						ConfigExternalID: v1.ExternalID{
-							ExternalID:   []string{string(obj.GetUID())},
-							ExternalType: ExternalTypePrefix + "Pod",
-						},
-						RelatedExternalID: v1.ExternalID{
-							ExternalID:   []string{nodeID},
-							ExternalType: ExternalTypePrefix + "Node",
-						},
-						Relationship: "NodePod",
-					})
-				}
+			for _, field := range volatileFields {
+				unstructured.RemoveNestedField(obj.Object, field...)
 			}
-			createdAt := obj.GetCreationTimestamp().Time
-			parentType, parentExternalID := getKubernetesParent(obj, resourceIDMap)
-			results = append(results, v1.ScrapeResult{
-				BaseScraper:         config.BaseScraper,
-				Name:                obj.GetName(),
-				Namespace:           obj.GetNamespace(),
-				Type:                obj.GetKind(),
-				ExternalType:        ExternalTypePrefix + obj.GetKind(),
-				CreatedAt:           &createdAt,
-				Config:              *obj,
-				ID:                  string(obj.GetUID()),
-				Aliases:             getKubernetesAlias(obj),
-				ParentExternalID:    parentExternalID,
-				ParentExternalType:  ExternalTypePrefix + parentType,
-				RelationshipResults: relationships,
-			})
+		}
+	}
+
+	// {Namespace: {Kind: {Name: ID}}}
+	resourceIDMap := map[string]map[string]map[string]string{"": {}}
 
+	for _, obj := range objs {
+		if collections.Contains([]string{"Namespace", "Deployment", "Node", "PersistentVolumeClaim", "PersistentVolume", "Endpoints"}, obj.GetKind()) {
+			if resourceIDMap[obj.GetNamespace()] == nil {
+				resourceIDMap[obj.GetNamespace()] = make(map[string]map[string]string)
+			}
+			if resourceIDMap[obj.GetNamespace()][obj.GetKind()] == nil {
+				resourceIDMap[obj.GetNamespace()][obj.GetKind()] = make(map[string]string)
+			}
+			resourceIDMap[obj.GetNamespace()][obj.GetKind()][obj.GetName()] = string(obj.GetUID())
+		}
+	}
+
+	// Add Cluster object first
+	clusterID := "Kubernetes/Cluster/" + cluster.Name
+	results = append(results, v1.ScrapeResult{
+		BaseScraper:  config.BaseScraper,
+		Name:         cluster.Name,
+		Type:         "Cluster",
+		ExternalType: ExternalTypePrefix + "Cluster",
+		Config:       make(map[string]string),
+		ID:           clusterID,
+	})
+
+	resourceIDMap[""]["Cluster"] = make(map[string]string)
+	resourceIDMap[""]["Cluster"][cluster.Name] = clusterID
+	// For shorthand
+	resourceIDMap[""]["Cluster"]["selfRef"] = clusterID
+
+	for _, obj := range objs {
+		// events are recorded as changes of the object they involve instead of as config items
+		if obj.GetKind() == "Event" {
+			if change := eventChange(obj); change != nil {
+				results.AddChange(*change)
+			}
+			continue
+		}
+
+		relationships := topologyRelationships(obj, resourceIDMap)
+		relationships = append(relationships, ecrRelationships(obj)...)
+		relationships = append(relationships, imageRelationships(cluster.Name, obj)...)
+		createdAt := obj.GetCreationTimestamp().Time
+		parentType, parentExternalID := getKubernetesParent(obj, resourceIDMap)
+		results = append(results, v1.ScrapeResult{
+			BaseScraper:         base,
+			Name:                obj.GetName(),
+			Namespace:           obj.GetNamespace(),
+			Type:                obj.GetKind(),
+			ExternalType:        ExternalTypePrefix + obj.GetKind(),
+			CreatedAt:           &createdAt,
+			Config:              *obj,
+			ID:                  string(obj.GetUID()),
+			Aliases:             getKubernetesAlias(obj),
+			Tags:                v1.JSONStringMap{"cluster": cluster.Name},
+			ParentExternalID:    parentExternalID,
+			ParentExternalType:  ExternalTypePrefix + parentType,
+			RelationshipResults: relationships,
+		})
+
+	}
+
+	images, digests := containerImages(objs)
+	for _, image := range images {
+		var aliases []string
+		for _, reference := range image.References {
+			aliases = append(aliases, imageID(cluster.Name, reference))
 		}
+		results = append(results, v1.ScrapeResult{
+			BaseScraper:        config.BaseScraper,
+			Name:               image.Repository + "@" + image.Digest,
+			Type:               "ContainerImage",
+			ExternalType:       ExternalTypePrefix + "ContainerImage",
+			Config:             image,
+			ID:                 imageID(cluster.Name, image.Digest),
+			Aliases:            aliases,
+			Tags:               v1.JSONStringMap{"cluster": cluster.Name},
+			ParentExternalID:   clusterID,
+			ParentExternalType: ExternalTypePrefix + "Cluster",
+		})
+	}
+	if config.VulnerabilityReports {
+		vulnerabilityAnalysis(cluster.Name, objs, digests, &results)
 	}
+	rbacAnalysis(config, objs, &results)
 	return results
 }
 
@@ -135,24 +333,283 @@ func getKubernetesParent(obj *unstructured.Unstructured, resourceIDMap map[strin
 	return parentConfigType, parentExternalID
 }
 
+// topologyRelationships relates an object to its owners and, following the spec, pods to their node and claims,
+// claims to their volume and services to the pods of their endpoints
+func topologyRelationships(obj *unstructured.Unstructured, resourceIDMap map[string]map[string]map[string]string) v1.RelationshipResults {
+	selfExternalID := v1.ExternalID{
+		ExternalID:   []string{string(obj.GetUID())},
+		ExternalType: ExternalTypePrefix + obj.GetKind(),
+	}
+	related := func(kind, id string) v1.ExternalID {
+		return v1.ExternalID{ExternalID: []string{id}, ExternalType: ExternalTypePrefix + kind}
+	}
+
+	var relationships v1.RelationshipResults
+	for _, ref := range obj.GetOwnerReferences() {
+		relationships = append(relationships, v1.RelationshipResult{
+			ConfigExternalID:  related(ref.Kind, string(ref.UID)),
+			RelatedExternalID: selfExternalID,
+			Relationship:      ref.Kind + obj.GetKind(),
+		})
+	}
+
+	switch obj.GetKind() {
+	case "Pod":
+		if nodeName, _, _ := unstructured.NestedString(obj.Object, "spec", "nodeName"); nodeName != "" {
+			if nodeID := resourceIDMap[""]["Node"][nodeName]; nodeID != "" {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  selfExternalID,
+					RelatedExternalID: related("Node", nodeID),
+					Relationship:      "NodePod",
+				})
+			}
+		}
+		volumes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumes")
+		for _, volume := range volumes {
+			spec, ok := volume.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			claimName, _, _ := unstructured.NestedString(spec, "persistentVolumeClaim", "claimName")
+			if claimID := resourceIDMap[obj.GetNamespace()]["PersistentVolumeClaim"][claimName]; claimID != "" {
+				relationships = append(relationships, v1.RelationshipResult{
+					ConfigExternalID:  selfExternalID,
+					RelatedExternalID: related("PersistentVolumeClaim", claimID),
+					Relationship:      "PodPersistentVolumeClaim",
+				})
+			}
+		}
+
+	case "PersistentVolumeClaim":
+		volumeName, _, _ := unstructured.NestedString(obj.Object, "spec", "volumeName")
+		if volumeID := resourceIDMap[""]["PersistentVolume"][volumeName]; volumeID != "" {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID:  selfExternalID,
+				RelatedExternalID: related("PersistentVolume", volumeID),
+				Relationship:      "PersistentVolumeClaimPersistentVolume",
+			})
+		}
+
+	case "Service":
+		// the endpoints of a service share its name
+		if endpointsID := resourceIDMap[obj.GetNamespace()]["Endpoints"][obj.GetName()]; endpointsID != "" {
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID:  selfExternalID,
+				RelatedExternalID: related("Endpoints", endpointsID),
+				Relationship:      "ServiceEndpoints",
+			})
+		}
+
+	case "Endpoints":
+		subsets, _, _ := unstructured.NestedSlice(obj.Object, "subsets")
+		seen := make(map[string]bool)
+		for _, subset := range subsets {
+			spec, ok := subset.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, field := range []string{"addresses", "notReadyAddresses"} {
+				addresses, _, _ := unstructured.NestedSlice(spec, field)
+				for _, address := range addresses {
+					target, ok := address.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					kind, _, _ := unstructured.NestedString(target, "targetRef", "kind")
+					uid, _, _ := unstructured.NestedString(target, "targetRef", "uid")
+					if kind != "Pod" || uid == "" || seen[uid] {
+						continue
+					}
+					seen[uid] = true
+					relationships = append(relationships, v1.RelationshipResult{
+						ConfigExternalID:  selfExternalID,
+						RelatedExternalID: related("Pod", uid),
+						Relationship:      "EndpointsPod",
+					})
+				}
+			}
+		}
+	}
+	return relationships
+}
+
+// ecrRelationships relates pods and the workloads with a pod template to the ECR repositories of their images,
+// the repositories are scraped by the AWS scraper with their URI as id
+func ecrRelationships(obj *unstructured.Unstructured) v1.RelationshipResults {
+	var path []string
+	switch obj.GetKind() {
+	case "Pod":
+		path = []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		path = []string{"spec", "template", "spec"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+
+	var relationships v1.RelationshipResults
+	seen := make(map[string]bool)
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, append(path, field)...)
+		for _, container := range containers {
+			spec, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image, _, _ := unstructured.NestedString(spec, "image")
+			match := ecrImage.FindStringSubmatch(image)
+			if match == nil || seen[match[1]] {
+				continue
+			}
+			seen[match[1]] = true
+			relationships = append(relationships, v1.RelationshipResult{
+				ConfigExternalID: v1.ExternalID{
+					ExternalID:   []string{string(obj.GetUID())},
+					ExternalType: ExternalTypePrefix + obj.GetKind(),
+				},
+				RelatedExternalID: v1.ExternalID{
+					ExternalID:   []string{match[1]},
+					ExternalType: v1.AWSECRRepository,
+				},
+				Relationship: "ECRImage",
+			})
+		}
+	}
+	return relationships
+}
+
 func getKubernetesAlias(obj *unstructured.Unstructured) []string {
 	return []string{strings.Join([]string{"Kubernetes", obj.GetKind(), obj.GetNamespace(), obj.GetName()}, "/")}
 }
 
+// discoverExclusions lists the resources served by the cluster, including custom resources, and returns the ones
+// whose group/kind is not included, ketall itself only excludes by resource name, kind or short name
+func discoverExclusions(opts *options.KetallOptions, config v1.Kubernetes) ([]string, error) {
+	client, err := opts.GenericCliFlags.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := client.ServerPreferredResources()
+	if err != nil && (resources == nil || !config.AllowIncomplete) {
+		return nil, err
+	}
+
+	var exclusions []string
+	for _, list := range resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if config.Includes(gv.Group, resource.Kind) {
+				continue
+			}
+			if gv.Group == "" {
+				exclusions = append(exclusions, resource.Name)
+			} else {
+				exclusions = append(exclusions, resource.Name+"."+gv.Group)
+			}
+		}
+	}
+	return exclusions, nil
+}
+
+// selectNamespaces returns the names of the namespaces of the cluster matching the selector, they are listed
+// independently of the scraped resources so that excluding the Namespace kind does not exclude their resources
+func selectNamespaces(opts *options.KetallOptions, selector v1.NamespaceSelector) (map[string]bool, error) {
+	if _, err := labels.Parse(selector.LabelSelector); err != nil {
+		return nil, fmt.Errorf("invalid namespace selector: %v", err)
+	}
+	restConfig, err := opts.GenericCliFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: selector.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := func(patterns []string, name string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	namespaces := make(map[string]bool)
+	for _, namespace := range list.Items {
+		if (len(selector.Names) == 0 || matches(selector.Names, namespace.Name)) && !matches(selector.Exclude, namespace.Name) {
+			namespaces[namespace.Name] = true
+		}
+	}
+	return namespaces, nil
+}
+
+// getSelected returns the cluster scoped objects and the objects of the namespaces matching the selector, the
+// namespaced objects are listed in each of the namespaces instead of across all of them
+func getSelected(opts *options.KetallOptions, selector v1.NamespaceSelector) ([]*unstructured.Unstructured, error) {
+	namespaces, err := selectNamespaces(opts, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+	if opts.Namespace == "" && opts.Scope != "namespace" {
+		clusterScoped := *opts
+		clusterScoped.Scope = "cluster"
+		all, err := getAll(&clusterScoped)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range all {
+			if obj.GetKind() != "Namespace" || namespaces[obj.GetName()] {
+				objs = append(objs, obj)
+			}
+		}
+	}
+	if opts.Scope == "cluster" {
+		return objs, nil
+	}
+
+	var names []string
+	for name := range namespaces {
+		if opts.Namespace == "" || opts.Namespace == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		namespaced := *opts
+		namespaced.Namespace = name
+		namespaced.Scope = "namespace"
+		all, err := getAll(&namespaced)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the resources of namespace %s: %v", name, err)
+		}
+		objs = append(objs, all...)
+	}
+	return objs, nil
+}
+
 func updateOptions(opts *options.KetallOptions, config v1.Kubernetes) *options.KetallOptions {
 	opts.AllowIncomplete = config.AllowIncomplete
 	opts.Namespace = config.Namespace
 	opts.Scope = config.Scope
 	opts.Selector = config.Selector
+	if config.LabelSelector != "" {
+		opts.Selector = config.LabelSelector
+	}
 	opts.FieldSelector = config.FieldSelector
 	opts.UseCache = config.UseCache
 	opts.MaxInflight = config.MaxInflight
 	opts.Exclusions = config.Exclusions
 	opts.Since = config.Since
-	//TODO: update kubeconfig reference if provided by user
-	// if config.Kubeconfig != nil {
-	// 	opts.Kubeconfig = config.Kubeconfig.GetValue()
-	// }
 	return opts
 }
 
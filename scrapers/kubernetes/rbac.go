@@ -0,0 +1,135 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/commons/collections"
+	v1 "github.com/flanksource/config-db/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RBAC analyzers, category and severity are defined in scrapers/analysis/rules.yaml
+const (
+	clusterAdminServiceAccount = "kubernetes-cluster-admin-service-account"
+	rbacWildcardVerbs          = "kubernetes-rbac-wildcard-verbs"
+	secretsReadAllNamespaces   = "kubernetes-secrets-read-all-namespaces"
+)
+
+// bootstrappingLabel marks the default roles and bindings of the cluster, they are not analyzed
+const bootstrappingLabel = "kubernetes.io/bootstrapping"
+
+// rbacAnalysis flags service accounts bound to cluster-admin, roles with wildcard verbs and cluster role bindings
+// granting read access to the secrets of every namespace, each rule can be disabled by adding its name to the
+// exclude list
+func rbacAnalysis(config v1.Kubernetes, objs []*unstructured.Unstructured, results *v1.ScrapeResults) {
+	analyze := func(analyzer string, obj *unstructured.Unstructured, messages []string) {
+		if len(messages) == 0 || collections.Contains(config.Exclude, analyzer) {
+			return
+		}
+		analysis := results.Analysis(analyzer, ExternalTypePrefix+obj.GetKind(), string(obj.GetUID()))
+		analysis.Summary = messages[0]
+		for _, msg := range messages {
+			analysis.Message(msg)
+		}
+	}
+
+	clusterRoles := make(map[string]rbacv1.ClusterRole)
+	for _, obj := range objs {
+		if obj.GetKind() != "ClusterRole" {
+			continue
+		}
+		var role rbacv1.ClusterRole
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &role) == nil {
+			clusterRoles[role.Name] = role
+		}
+	}
+
+	for _, obj := range objs {
+		if obj.GetLabels()[bootstrappingLabel] != "" {
+			continue
+		}
+		switch obj.GetKind() {
+		case "Role":
+			var role rbacv1.Role
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &role) == nil {
+				analyze(rbacWildcardVerbs, obj, wildcardVerbs(role.Kind, role.Name, role.Rules))
+			}
+		case "ClusterRole":
+			role := clusterRoles[obj.GetName()]
+			analyze(rbacWildcardVerbs, obj, wildcardVerbs(role.Kind, role.Name, role.Rules))
+		case "RoleBinding", "ClusterRoleBinding":
+			var binding rbacv1.ClusterRoleBinding
+			if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &binding) != nil {
+				continue
+			}
+			analyze(clusterAdminServiceAccount, obj, clusterAdminBindings(obj.GetKind(), binding))
+			if obj.GetKind() == "ClusterRoleBinding" && binding.RoleRef.Kind == "ClusterRole" {
+				if role, ok := clusterRoles[binding.RoleRef.Name]; ok && readsSecrets(role.Rules) {
+					analyze(secretsReadAllNamespaces, obj, []string{fmt.Sprintf("%s %s grants %s read access to the secrets of every namespace",
+						obj.GetKind(), binding.Name, subjectNames(binding.Subjects))})
+				}
+			}
+		}
+	}
+}
+
+func wildcardVerbs(kind, name string, rules []rbacv1.PolicyRule) []string {
+	var messages []string
+	for _, rule := range rules {
+		if !collections.Contains(rule.Verbs, rbacv1.VerbAll) {
+			continue
+		}
+		resources := append(append([]string{}, rule.Resources...), rule.NonResourceURLs...)
+		messages = append(messages, fmt.Sprintf("%s %s allows every verb on %s", kind, name, strings.Join(resources, ", ")))
+	}
+	return messages
+}
+
+func clusterAdminBindings(kind string, binding rbacv1.ClusterRoleBinding) []string {
+	if binding.RoleRef.Kind != "ClusterRole" || binding.RoleRef.Name != "cluster-admin" {
+		return nil
+	}
+	var messages []string
+	for _, subject := range binding.Subjects {
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			messages = append(messages, fmt.Sprintf("%s %s binds service account %s/%s to cluster-admin", kind, binding.Name, subject.Namespace, subject.Name))
+		}
+	}
+	return messages
+}
+
+// readsSecrets returns true if the rules allow getting, listing or watching secrets of the core group
+func readsSecrets(rules []rbacv1.PolicyRule) bool {
+	for _, rule := range rules {
+		if !collections.Contains(rule.APIGroups, "") && !collections.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+			continue
+		}
+		if !collections.Contains(rule.Resources, "secrets") && !collections.Contains(rule.Resources, rbacv1.ResourceAll) {
+			continue
+		}
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		for _, verb := range []string{"get", "list", "watch", rbacv1.VerbAll} {
+			if collections.Contains(rule.Verbs, verb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func subjectNames(subjects []rbacv1.Subject) string {
+	var names []string
+	for _, subject := range subjects {
+		name := subject.Name
+		if subject.Namespace != "" {
+			name = subject.Namespace + "/" + name
+		}
+		names = append(names, strings.ToLower(subject.Kind)+" "+name)
+	}
+	return strings.Join(names, ", ")
+}
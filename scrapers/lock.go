@@ -0,0 +1,45 @@
+package scrapers
+
+import (
+	"context"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/robfig/cron/v3"
+)
+
+// exclusive runs the job unless another replica sharing the database is running it
+func exclusive(job string, fn func()) func() {
+	return func() {
+		unlock, ok, err := db.TryLock(context.Background(), job)
+		if err != nil {
+			logger.Warnf("failed to lock %s, running it anyway: %v", job, err)
+			fn()
+			return
+		}
+		if !ok {
+			logger.Debugf("%s is running on another replica", job)
+			return
+		}
+		defer unlock()
+		fn()
+	}
+}
+
+// ranByAnotherReplica is true when the scraper started less than half its schedule period ago, the schedules of
+// replicas drift apart and the lock alone does not stop a replica from running a scrape that just finished
+func ranByAnotherReplica(scraper v1.ConfigScraper, schedule string, scheduledAt time.Time) bool {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return false
+	}
+	next := sched.Next(scheduledAt)
+	period := sched.Next(next).Sub(next)
+	run, err := db.LastScrapeRun(scraper.Name)
+	if err != nil || run == nil {
+		return false
+	}
+	return scheduledAt.Sub(run.StartTime) < period/2
+}
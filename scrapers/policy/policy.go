@@ -0,0 +1,194 @@
+// Package policy evaluates Rego policies against scraped config items
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils"
+	"github.com/ohler55/ojg/oj"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+
+	defaultSeverity = "warning"
+	defaultCategory = "compliance"
+)
+
+// severities in increasing order, the highest severity of the violations is used
+var severities = []string{"info", "low", "warning", "medium", "high", "critical"}
+
+// Policy is a compiled policy
+type Policy struct {
+	v1.Policy
+	query rego.PreparedEvalQuery
+}
+
+// Compile prepares the deny rule of every policy
+func Compile(ctx context.Context, policies []v1.Policy) ([]Policy, error) {
+	var compiled []Policy
+	for _, p := range policies {
+		module, err := ast.ParseModule(p.Name+".rego", p.Rego)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policy %s: %v", p.Name, err)
+		}
+		query, err := rego.New(
+			rego.Query(module.Package.Path.String()+".deny"),
+			rego.ParsedModule(module),
+		).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile policy %s: %v", p.Name, err)
+		}
+		if p.Severity == "" {
+			p.Severity = defaultSeverity
+		}
+		if p.Category == "" {
+			p.Category = defaultCategory
+		}
+		compiled = append(compiled, Policy{Policy: p, query: query})
+	}
+	return compiled, nil
+}
+
+// Evaluate returns an analysis result for every policy that applies to the config item, items without
+// violations get a resolved result that closes the analysis of a previous violation
+func Evaluate(ctx context.Context, policies []Policy, result v1.ScrapeResult) v1.ScrapeResults {
+	var results v1.ScrapeResults
+	if len(policies) == 0 || result.Config == nil {
+		return results
+	}
+	input, err := toInput(result.Config)
+	if err != nil {
+		return results.Errorf(err, "failed to evaluate policies on %s", result)
+	}
+	for _, p := range policies {
+		if len(p.Types) > 0 && !utils.MatchItems(result.Type, p.Types...) {
+			continue
+		}
+		violations, err := p.violations(ctx, input)
+		if err != nil {
+			results.Errorf(err, "failed to evaluate policy %s on %s", p.Name, result)
+			continue
+		}
+		analysis := results.Analysis(p.Name, result.ExternalType, result.ID)
+		analysis.AnalysisType = p.Category
+		analysis.Severity = p.Severity
+		analysis.Status = StatusResolved
+		if len(violations) == 0 {
+			continue
+		}
+		analysis.Status = StatusOpen
+		analysis.Severity = highest(violations, p.Severity)
+		analysis.Summary = violations[0].Message
+		var remediations []string
+		for _, v := range violations {
+			analysis.Message(v.Message)
+			if v.Remediation != "" {
+				remediations = append(remediations, v.Remediation)
+			}
+		}
+		if len(remediations) > 0 {
+			analysis.Analysis = map[string]string{"remediation": strings.Join(remediations, "\n")}
+		}
+	}
+	return results
+}
+
+type violation struct {
+	Message     string
+	Severity    string
+	Remediation string
+}
+
+func (p Policy) violations(ctx context.Context, input interface{}) ([]violation, error) {
+	rs, err := p.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, err
+	}
+	var violations []violation
+	for _, r := range rs {
+		for _, expr := range r.Expressions {
+			values, ok := expr.Value.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, value := range values {
+				violations = append(violations, toViolation(value))
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Message < violations[j].Message })
+	return violations, nil
+}
+
+func toViolation(value interface{}) violation {
+	switch v := value.(type) {
+	case string:
+		return violation{Message: v}
+	case map[string]interface{}:
+		out := violation{
+			Message:     str(v["msg"]),
+			Severity:    str(v["severity"]),
+			Remediation: str(v["remediation"]),
+		}
+		if out.Message == "" {
+			out.Message = str(v["message"])
+		}
+		return out
+	}
+	return violation{Message: fmt.Sprintf("%v", value)}
+}
+
+func str(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// highest returns the highest severity of the violations, using the default severity for violations without one
+func highest(violations []violation, severity string) string {
+	rank := func(s string) int {
+		for i, sev := range severities {
+			if sev == s {
+				return i
+			}
+		}
+		return -1
+	}
+	highest := ""
+	for _, v := range violations {
+		s := v.Severity
+		if s == "" {
+			s = severity
+		}
+		if highest == "" || rank(s) > rank(highest) {
+			highest = s
+		}
+	}
+	return highest
+}
+
+// toInput converts the config to the plain maps and slices expected by rego
+func toInput(config interface{}) (interface{}, error) {
+	var data string
+	switch c := config.(type) {
+	case string:
+		data = c
+	case []byte:
+		data = string(c)
+	default:
+		data = oj.JSON(c, &oj.Options{OmitNil: true, TimeFormat: "2006-01-02T15:04:05Z07:00"})
+	}
+	var input interface{}
+	err := json.Unmarshal([]byte(data), &input)
+	return input, err
+}
@@ -0,0 +1,177 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/kommons"
+	"golang.org/x/time/rate"
+)
+
+const (
+	helperHTTPTimeout = 10 * time.Second
+	// helperHTTPMaxBody is the most bytes read from a response
+	helperHTTPMaxBody = 1 << 20
+)
+
+// httpLimiter limits the requests made by all the scripts
+var httpLimiter = rate.NewLimiter(rate.Every(200*time.Millisecond), 5)
+
+var helperClient = &http.Client{Timeout: helperHTTPTimeout}
+
+// Helpers are the functions available to transform scripts and expressions
+type Helpers struct {
+	ctx *v1.ScrapeContext
+}
+
+func NewHelpers(ctx *v1.ScrapeContext) Helpers {
+	return Helpers{ctx: ctx}
+}
+
+// LookupConfig returns the config of the item of the type with the name that was already scraped for the tenant of
+// the scrape, or nil
+func (h Helpers) LookupConfig(configType, name string) (interface{}, error) {
+	tenant := ""
+	if h.ctx != nil {
+		tenant = h.ctx.Tenant
+	}
+	ci, err := db.FindConfigItemByName(configType, name, tenant)
+	if err != nil || ci == nil || ci.Config == nil {
+		return nil, err
+	}
+	var config interface{}
+	if err := json.Unmarshal([]byte(*ci.Config), &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// GetSecret returns the key of a kubernetes secret in the namespace of the scraper, the name is <secret>/<key>,
+// or the value of a secret store reference, e.g. vault://secret/data/app#token, only the secrets declared in the
+// secrets of the scraper can be read
+func (h Helpers) GetSecret(name string) (string, error) {
+	if !h.declared(name) {
+		return "", fmt.Errorf("secret %s is not declared in the secrets of the scraper", name)
+	}
+	if secrets.IsReference(name) {
+		return secrets.Resolve(h.context(), name)
+	}
+	if h.ctx == nil || h.ctx.Kommons == nil {
+		return "", fmt.Errorf("secrets require a kubernetes connection")
+	}
+	secret, key, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", fmt.Errorf("expected <secret>/<key>, got %s", name)
+	}
+	_, value, err := h.ctx.Kommons.GetEnvValue(kommons.EnvVar{
+		ValueFrom: &kommons.EnvVarSource{
+			SecretKeyRef: &kommons.SecretKeySelector{
+				LocalObjectReference: kommons.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}, h.ctx.GetNamespace())
+	return value, err
+}
+
+// HTTPGet fetches the URL, the requests of all scripts are rate limited
+func (h Helpers) HTTPGet(rawURL string, headers map[string]string) (map[string]interface{}, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err := httpLimiter.Wait(h.context()); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(h.context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := helperClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, helperHTTPMaxBody))
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{
+		"status": resp.StatusCode,
+		"body":   string(body),
+	}
+	var parsed interface{}
+	if json.Unmarshal(body, &parsed) == nil {
+		out["json"] = parsed
+	}
+	return out, nil
+}
+
+func (h Helpers) declared(name string) bool {
+	if h.ctx == nil || h.ctx.Scraper == nil {
+		return false
+	}
+	for _, secret := range h.ctx.Scraper.Secrets {
+		if secret == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Helpers) context() context.Context {
+	if h.ctx == nil || h.ctx.Context == nil {
+		return context.Background()
+	}
+	return h.ctx.Context
+}
+
+// Install adds lookupConfig, getSecret and http.get to a javascript runtime, errors are thrown as exceptions
+func (h Helpers) Install(vm *goja.Runtime) error {
+	throw := func(err error) {
+		panic(vm.NewGoError(err))
+	}
+	if err := vm.Set("lookupConfig", func(configType, name string) interface{} {
+		config, err := h.LookupConfig(configType, name)
+		if err != nil {
+			throw(err)
+		}
+		return config
+	}); err != nil {
+		return err
+	}
+	if err := vm.Set("getSecret", func(name string) string {
+		value, err := h.GetSecret(name)
+		if err != nil {
+			throw(err)
+		}
+		return value
+	}); err != nil {
+		return err
+	}
+	return vm.Set("http", map[string]interface{}{
+		"get": func(url string, headers map[string]string) interface{} {
+			resp, err := h.HTTPGet(url, headers)
+			if err != nil {
+				throw(err)
+			}
+			return resp
+		},
+	})
+}
@@ -31,14 +31,28 @@ type Extract struct {
 	Config         v1.BaseScraper
 	Excludes       []jp.Expr
 	Transform      Transform
+	// Steps are applied by the extractor of the scraper, not the extractors of the items
+	Steps  []step
+	redact *redactor
+	ctx    *v1.ScrapeContext
 }
 
+// WithContext returns the extractor with the scrape context used by the helpers of scripts
+func (e Extract) WithContext(ctx *v1.ScrapeContext) Extract {
+	e.ctx = ctx
+	return e
+}
+
+// WithoutItems returns the extractor of the items, the mapping is applied once by the extractor of the scraper
 func (e Extract) WithoutItems() Extract {
+	config := e.Config
+	config.Mapping = nil
 	return Extract{
+		ctx:       e.ctx,
 		ID:        e.ID,
 		Type:      e.Type,
 		Name:      e.Name,
-		Config:    e.Config,
+		Config:    config,
 		Excludes:  e.Excludes,
 		Transform: e.Transform,
 	}
@@ -98,6 +112,16 @@ func NewExtractor(config v1.BaseScraper) (Extract, error) {
 
 	extract.Transform.Script = config.Transform.Script
 
+	steps, err := newSteps(config.Transforms)
+	if err != nil {
+		return extract, err
+	}
+	extract.Steps = steps
+
+	if extract.redact, err = newRedactor(config.Transform.Redact); err != nil {
+		return extract, err
+	}
+
 	for _, mask := range config.Transform.Masks {
 		if mask.Selector.IsEmpty() {
 			continue
@@ -194,7 +218,7 @@ func (e Extract) Extract(inputs ...v1.ScrapeResult) ([]v1.ScrapeResult, error) {
 		var ongoingInput v1.ScrapeResults = []v1.ScrapeResult{input}
 		if !input.BaseScraper.Transform.Script.IsEmpty() {
 			logger.Debugf("Applying script transformation")
-			transformed, err := RunScript(input, input.BaseScraper.Transform.Script)
+			transformed, err := RunScript(e.ctx, input, input.BaseScraper.Transform.Script)
 			if err != nil {
 				return results, fmt.Errorf("failed to run script: %v", err)
 			}
@@ -220,7 +244,13 @@ func (e Extract) Extract(inputs ...v1.ScrapeResult) ([]v1.ScrapeResult, error) {
 		}
 	}
 
-	return results, nil
+	if results, err = applyMapping(e.Config.Mapping, results); err != nil {
+		return results, err
+	}
+	if results, err = e.applySteps(results); err != nil {
+		return results, err
+	}
+	return e.redact.apply(results)
 }
 
 func (e Extract) extractAttributes(input v1.ScrapeResult) (v1.ScrapeResult, error) {
@@ -0,0 +1,48 @@
+package processors
+
+import (
+	"fmt"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils/templating"
+)
+
+// applyMapping re-identifies the results with the templates of the mapping
+func applyMapping(mapping *v1.Mapping, results []v1.ScrapeResult) ([]v1.ScrapeResult, error) {
+	if mapping == nil {
+		return results, nil
+	}
+	for i := range results {
+		result := &results[i]
+		environment := map[string]interface{}{
+			"config": result.Config,
+			"result": *result,
+		}
+		for _, field := range []struct {
+			name     string
+			template v1.Template
+			value    *string
+		}{
+			{"id", mapping.ID, &result.ID},
+			{"name", mapping.Name, &result.Name},
+			{"type", mapping.Type, &result.Type},
+			{"parent", mapping.Parent, &result.ParentExternalID},
+			{"parentType", mapping.ParentType, &result.ParentExternalType},
+		} {
+			if field.template.IsEmpty() {
+				continue
+			}
+			value, err := templating.Template(environment, field.template)
+			if err != nil {
+				return nil, fmt.Errorf("failed to map the %s of %s: %v", field.name, result, err)
+			}
+			if value != "" {
+				*field.value = value
+			}
+		}
+		if result.ParentExternalID != "" && result.ParentExternalType == "" {
+			result.ParentExternalType = result.ExternalType
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,33 @@
+package processors
+
+import (
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func TestMappingOfItems(t *testing.T) {
+	extract, err := NewExtractor(v1.BaseScraper{
+		ID:      "$.name",
+		Type:    "Item",
+		Items:   "$.items[*]",
+		Mapping: &v1.Mapping{ID: v1.Template{Template: "{{.result.id}}-mapped"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the extractor: %v", err)
+	}
+	results, err := extract.Extract(v1.ScrapeResult{Config: map[string]interface{}{
+		"name":  "list",
+		"items": []interface{}{map[string]interface{}{"name": "a"}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	if len(ids) != 2 || ids[0] != "a-mapped" || ids[1] != "list-mapped" {
+		t.Errorf("expected the mapping to be applied once to every item, got %v", ids)
+	}
+}
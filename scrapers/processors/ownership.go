@@ -0,0 +1,119 @@
+package processors
+
+import (
+	"sort"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+// OwnerExternalTypePrefix is the prefix of the external type of the owner items
+const OwnerExternalTypePrefix = "Owner::"
+
+const defaultOwnerRelation = "owner"
+
+// InferOwnership adds the normalized tags to the results and relates them to their owners,
+// returning the owner config items that must be saved before the results
+func InferOwnership(ownership *v1.Ownership, results []v1.ScrapeResult) []v1.ScrapeResult {
+	if ownership == nil {
+		return nil
+	}
+	owners := make(map[string]v1.ScrapeResult)
+	for i := range results {
+		result := &results[i]
+		if result.Config == nil || result.ID == "" {
+			continue
+		}
+		// the tags can be shared by items cloned from the same result
+		tags := make(v1.JSONStringMap, len(result.Tags)+len(ownership.Tags))
+		for key, value := range result.Tags {
+			tags[key] = value
+		}
+		for tag, sources := range ownership.Tags {
+			if value := findTag(*result, sources); value != "" {
+				tags[tag] = value
+			}
+		}
+		if len(tags) > 0 {
+			result.Tags = tags
+		}
+
+		for _, owner := range ownership.Owners {
+			value := result.Tags[owner.Tag]
+			if value == "" || result.Type == owner.Type {
+				continue
+			}
+			id := strings.ToLower(owner.Tag + "/" + value)
+			externalType := OwnerExternalTypePrefix + owner.Type
+			if _, ok := owners[externalType+"/"+id]; !ok {
+				owners[externalType+"/"+id] = v1.ScrapeResult{
+					BaseScraper:  result.BaseScraper,
+					Type:         owner.Type,
+					ExternalType: externalType,
+					ID:           id,
+					Name:         value,
+					Config:       map[string]string{owner.Tag: value},
+					Tags:         v1.JSONStringMap{owner.Tag: value},
+				}
+			}
+			relation := owner.Relation
+			if relation == "" {
+				relation = defaultOwnerRelation
+			}
+			result.RelationshipResults = append(result.RelationshipResults, v1.RelationshipResult{
+				ConfigExternalID:  v1.ExternalID{ExternalID: []string{result.ID}, ExternalType: result.ExternalType},
+				RelatedExternalID: v1.ExternalID{ExternalID: []string{id}, ExternalType: externalType},
+				Relationship:      relation,
+			})
+		}
+	}
+
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var items []v1.ScrapeResult
+	for _, key := range keys {
+		items = append(items, owners[key])
+	}
+	return items
+}
+
+// findTag returns the value of the first source found in the tags, or in the labels of kubernetes objects
+func findTag(result v1.ScrapeResult, sources []string) string {
+	labels := configLabels(result.Config)
+	for _, source := range sources {
+		for _, tags := range []map[string]string{result.Tags, labels} {
+			for key, value := range tags {
+				if strings.EqualFold(key, source) && value != "" {
+					return value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// configLabels returns metadata.labels of the config
+func configLabels(config interface{}) map[string]string {
+	obj, ok := config.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	labels := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			labels[key] = s
+		}
+	}
+	return labels
+}
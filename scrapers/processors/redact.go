@@ -0,0 +1,179 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/ohler55/ojg/jp"
+)
+
+// RedactSecrets applies the built-in detectors to the configs of scrapers that do not configure redaction
+var RedactSecrets bool
+
+const defaultReplacement = "[REDACTED]"
+
+// valueDetectors match secrets in any string value
+var valueDetectors = map[string]*regexp.Regexp{
+	"aws_access_key": regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+	"private_key":    regexp.MustCompile(`-----BEGIN[A-Z ]* PRIVATE KEY-----[\s\S]*?-----END[A-Z ]* PRIVATE KEY-----`),
+	"jwt":            regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	"email":          regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+}
+
+// keyDetectors match the names of fields or environment variables whose whole value is a secret
+var keyDetectors = map[string]*regexp.Regexp{
+	"aws_secret_key": regexp.MustCompile(`(?i)secret_?access_?key`),
+	"password":       regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key)$`),
+}
+
+var allDetectors = []string{"aws_access_key", "aws_secret_key", "private_key", "jwt", "password", "kubernetes_secret", "email"}
+
+type redactor struct {
+	values           []*regexp.Regexp
+	keys             []*regexp.Regexp
+	jsonPaths        []jp.Expr
+	kubernetesSecret bool
+	replacement      string
+}
+
+func newRedactor(redact *v1.Redact) (*redactor, error) {
+	if redact == nil {
+		if !RedactSecrets {
+			return nil, nil
+		}
+		redact = &v1.Redact{}
+	}
+	r := &redactor{replacement: redact.Replacement}
+	if r.replacement == "" {
+		r.replacement = defaultReplacement
+	}
+	detectors := redact.Detectors
+	if len(detectors) == 0 {
+		detectors = allDetectors
+	}
+	for _, detector := range detectors {
+		if re, ok := valueDetectors[detector]; ok {
+			r.values = append(r.values, re)
+		} else if re, ok := keyDetectors[detector]; ok {
+			r.keys = append(r.keys, re)
+		} else if detector == "kubernetes_secret" {
+			r.kubernetesSecret = true
+		} else {
+			return nil, fmt.Errorf("unknown redact detector %s", detector)
+		}
+	}
+	for _, pattern := range redact.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse redact pattern %s: %v", pattern, err)
+		}
+		r.values = append(r.values, re)
+	}
+	for _, path := range redact.JSONPaths {
+		x, err := jp.ParseString(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse redact jsonpath %s: %v", path, err)
+		}
+		r.jsonPaths = append(r.jsonPaths, x)
+	}
+	return r, nil
+}
+
+func (r *redactor) apply(results []v1.ScrapeResult) ([]v1.ScrapeResult, error) {
+	if r == nil {
+		return results, nil
+	}
+	for i := range results {
+		config := results[i].Config
+		if config == nil {
+			continue
+		}
+		for _, x := range r.jsonPaths {
+			for _, value := range x.Get(config) {
+				if err := x.Set(config, r.replace(value)); err != nil {
+					return nil, fmt.Errorf("failed to redact %s: %v", x, err)
+				}
+			}
+		}
+		if r.kubernetesSecret {
+			r.redactKubernetesSecret(config)
+		}
+		results[i].Config = r.walk(config)
+	}
+	return results, nil
+}
+
+// walk redacts the values of secret keys and the secrets found in strings
+func (r *redactor) walk(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// environment variables of containers are {name, value} pairs
+		if name, ok := v["name"].(string); ok && r.isSecretKey(name) {
+			if _, ok := v["value"].(string); ok {
+				v["value"] = r.replace(v["value"])
+			}
+		}
+		for key, child := range v {
+			if s, ok := child.(string); ok && s != "" && r.isSecretKey(key) {
+				v[key] = r.replace(s)
+				continue
+			}
+			v[key] = r.walk(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.walk(child)
+		}
+		return v
+	case string:
+		for _, re := range r.values {
+			v = re.ReplaceAllStringFunc(v, func(match string) string { return r.replace(match) })
+		}
+		return v
+	}
+	return value
+}
+
+func (r *redactor) isSecretKey(key string) bool {
+	for _, re := range r.keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactKubernetesSecret redacts every value of the data of secrets
+func (r *redactor) redactKubernetesSecret(config interface{}) {
+	obj, ok := config.(map[string]interface{})
+	if !ok || obj["kind"] != "Secret" {
+		return
+	}
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range data {
+			data[key] = r.replace(value)
+		}
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			// kubectl apply stores the whole secret in this annotation
+			if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+				annotations["kubectl.kubernetes.io/last-applied-configuration"] = r.replacement
+			}
+		}
+	}
+}
+
+func (r *redactor) replace(value interface{}) string {
+	if strings.EqualFold(r.replacement, "md5sum") {
+		return md5SumHex(value)
+	}
+	return r.replacement
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/text"
 	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils/templating"
 )
 
 func LoadSharedLibrary(vm *goja.Runtime, source string) error {
@@ -29,11 +30,17 @@ func LoadSharedLibrary(vm *goja.Runtime, source string) error {
 	return nil
 }
 
-func RunScript(result v1.ScrapeResult, script v1.Script) ([]v1.ScrapeResult, error) {
+func RunScript(ctx *v1.ScrapeContext, result v1.ScrapeResult, script v1.Script) ([]v1.ScrapeResult, error) {
 	var out []v1.ScrapeResult
 	// javascript
 	if script.Javascript != "" {
 		vm := goja.New()
+		if err := templating.InstallRequire(vm); err != nil {
+			return nil, err
+		}
+		if err := NewHelpers(ctx).Install(vm); err != nil {
+			return nil, err
+		}
 		if err := vm.Set("config", result.Config); err != nil {
 			return nil, err
 		}
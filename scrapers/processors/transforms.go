@@ -0,0 +1,149 @@
+package processors
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/utils/templating"
+	"github.com/ohler55/ojg/jp"
+)
+
+type step struct {
+	v1.TransformStep
+	name string
+	mask *Mask
+}
+
+func newSteps(transforms []v1.TransformStep) ([]step, error) {
+	var steps []step
+	for i, t := range transforms {
+		s := step{TransformStep: t, name: t.Name}
+		if s.name == "" {
+			s.name = fmt.Sprintf("%d:%s", i, t.Kind())
+		}
+		switch t.Kind() {
+		case "":
+			return nil, fmt.Errorf("transform %d has no step, expected one of filter, mask, rename, relate or script", i)
+		case "mask":
+			x, err := jp.ParseString(t.Mask.JSONPath)
+			if err != nil {
+				return nil, fmt.Errorf("transform %s: failed to parse mask jsonpath: %s: %v", s.name, t.Mask.JSONPath, err)
+			}
+			s.mask = &Mask{SelectorType: t.Mask.Selector.Type, JSONPath: &x, Value: t.Mask.Value}
+		case "relate":
+			if t.Relate.ID == "" || t.Relate.ExternalType == "" {
+				return nil, fmt.Errorf("transform %s: relate requires an id and externalType", s.name)
+			}
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// applySteps runs the results through every step in order, the error identifies the step and item that failed
+func (e Extract) applySteps(results []v1.ScrapeResult) ([]v1.ScrapeResult, error) {
+	for _, s := range e.Steps {
+		var out []v1.ScrapeResult
+		for _, result := range results {
+			transformed, err := e.applyStep(s, result)
+			if err != nil {
+				return nil, fmt.Errorf("transform %s failed for %s: %v", s.name, result, err)
+			}
+			out = append(out, transformed...)
+		}
+		results = out
+	}
+	return results, nil
+}
+
+// render renders the go template of a step like the templates of the mapping
+func render(template string, vars map[string]interface{}) (string, error) {
+	return templating.Template(vars, v1.Template{Template: template})
+}
+
+func (e Extract) applyStep(s step, result v1.ScrapeResult) ([]v1.ScrapeResult, error) {
+	vars := map[string]interface{}{
+		"config": result.Config,
+		"result": result,
+	}
+	switch s.Kind() {
+	case "filter":
+		keep, err := render(s.Filter, vars)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(keep) != "true" {
+			return nil, nil
+		}
+
+	case "mask":
+		if s.mask.SelectorType != "" && s.mask.SelectorType != result.Type {
+			break
+		}
+		for _, y := range s.mask.JSONPath.Get(result.Config) {
+			value := s.mask.Value
+			if value == "md5sum" {
+				value = md5SumHex(y)
+			}
+			if err := s.mask.JSONPath.Set(result.Config, value); err != nil {
+				return nil, err
+			}
+		}
+
+	case "rename":
+		for _, field := range []struct {
+			template string
+			value    *string
+		}{
+			{s.Rename.Name, &result.Name},
+			{s.Rename.Type, &result.Type},
+			{s.Rename.Namespace, &result.Namespace},
+		} {
+			if field.template == "" {
+				continue
+			}
+			value, err := render(field.template, vars)
+			if err != nil {
+				return nil, err
+			}
+			*field.value = strings.TrimSpace(value)
+		}
+
+	case "relate":
+		id, err := render(s.Relate.ID, vars)
+		if err != nil {
+			return nil, err
+		}
+		if id = strings.TrimSpace(id); id == "" {
+			break
+		}
+		result.RelationshipResults = append(result.RelationshipResults, v1.RelationshipResult{
+			ConfigExternalID:  v1.ExternalID{ExternalID: []string{result.ID}, ExternalType: result.ExternalType},
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{id}, ExternalType: s.Relate.ExternalType},
+			Relationship:      s.Relate.Relationship,
+		})
+
+	case "script":
+		scripted, err := RunScript(e.ctx, result, *s.Script)
+		if err != nil {
+			return nil, err
+		}
+		// a single item keeps its identity, multiple items are identified from their config
+		if len(scripted) == 1 {
+			return []v1.ScrapeResult{result.Clone(scripted[0].Config)}, nil
+		}
+		var out []v1.ScrapeResult
+		for _, item := range scripted {
+			clone := result.Clone(item.Config)
+			clone.ID, clone.Name = "", ""
+			extracted, err := e.extractAttributes(clone)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, extracted)
+		}
+		return out, nil
+	}
+	return []v1.ScrapeResult{result}, nil
+}
@@ -0,0 +1,37 @@
+package processors
+
+import (
+	"testing"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+func TestTransformChain(t *testing.T) {
+	extract, err := NewExtractor(v1.BaseScraper{
+		Transforms: []v1.TransformStep{
+			{Filter: `{{ eq .config.state "running" }}`},
+			{Rename: &v1.Rename{Name: "{{ .result.id }}.{{ .config.zone }}", Namespace: "{{ .config.zone }}"}},
+			{Relate: &v1.Relate{ID: "{{ .config.vpc }}", ExternalType: "VPC", Relationship: "VPCInstance"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the extractor: %v", err)
+	}
+	results, err := extract.applySteps([]v1.ScrapeResult{
+		{ID: "web", Type: "Instance", ExternalType: "Instance", Config: map[string]interface{}{"state": "running", "zone": "a", "vpc": "vpc-1"}},
+		{ID: "old", Type: "Instance", ExternalType: "Instance", Config: map[string]interface{}{"state": "stopped", "zone": "b"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to apply the transforms: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("expected the stopped instance to be filtered, got %d results", len(results))
+	}
+	if results[0].Name != "web.a" || results[0].Namespace != "a" {
+		t.Errorf("expected web to be renamed to web.a in a, got %s in %s", results[0].Name, results[0].Namespace)
+	}
+	if len(results[0].RelationshipResults) != 1 || results[0].RelationshipResults[0].RelatedExternalID.String() != "VPC/vpc-1" {
+		t.Errorf("expected web to be related to vpc-1, got %v", results[0].RelationshipResults)
+	}
+}
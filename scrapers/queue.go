@@ -0,0 +1,167 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+)
+
+var (
+	// ScrapeQueue queues scheduled scrapes as tasks for the workers instead of running them
+	ScrapeQueue bool
+	// QueueWorkers is the number of workers consuming scrape tasks in this process
+	QueueWorkers int
+	// QueueMaxAttempts of a failed task before it is dead
+	QueueMaxAttempts = 3
+	// QueueTaskTimeout is how long a task can run before it is assumed that its worker stopped
+	QueueTaskTimeout = time.Hour
+)
+
+const (
+	queuePollInterval = 5 * time.Second
+	// queueTaskRetention of succeeded and dead tasks
+	queueTaskRetention = 7 * 24 * time.Hour
+)
+
+// queueTasks splits the scraper into a task per region of its AWS scrapers and a task for the other scrapers
+func queueTasks(scraper v1.ConfigScraper, id string) ([]models.ScrapeTask, error) {
+	var tasks []models.ScrapeTask
+	add := func(spec v1.ConfigScraper, region string) error {
+		data, err := json.Marshal(spec)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, models.ScrapeTask{Name: scraper.Name, ScraperID: id, Region: region, Spec: string(data)})
+		return nil
+	}
+
+	rest := scraper
+	rest.AWS = nil
+	for _, config := range scraper.AWS {
+		// the regions of "all" are only known once the account is scraped
+		if config.AWSConnection == nil || len(config.Region) < 2 || contains(config.Region, "all") {
+			rest.AWS = append(rest.AWS, config)
+			continue
+		}
+		for _, region := range config.Region {
+			connection := *config.AWSConnection
+			connection.Region = []string{region}
+			regional := config
+			regional.AWSConnection = &connection
+			if err := add(onlyAWS(scraper, regional), region); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !rest.IsEmpty() || len(rest.Kubernetes) > 0 || len(rest.KubernetesFile) > 0 || len(rest.Azure) > 0 || len(rest.AzureDevops) > 0 || len(rest.GitHub) > 0 || len(rest.GitHubActions) > 0 || len(rest.SQL) > 0 {
+		if err := add(rest, ""); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// onlyAWS returns the scraper with the settings that apply to all of its items and only the AWS scraper
+func onlyAWS(scraper v1.ConfigScraper, config v1.AWS) v1.ConfigScraper {
+	return v1.ConfigScraper{
+		Name:      scraper.Name,
+		LogLevel:  scraper.LogLevel,
+		Tenant:    scraper.Tenant,
+		Schedule:  scraper.Schedule,
+		AWS:       []v1.AWS{config},
+		Retention: scraper.Retention,
+		Policies:  scraper.Policies,
+		Ownership: scraper.Ownership,
+		Includes:  scraper.Includes,
+		Excludes:  scraper.Excludes,
+		Libraries: scraper.Libraries,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func enqueueScraper(scraper v1.ConfigScraper, id string) {
+	tasks, err := queueTasks(scraper, id)
+	if err == nil {
+		err = db.EnqueueScrapeTasks(tasks)
+	}
+	if err != nil {
+		logger.Errorf("Failed to queue %s: %v", scraper.Name, err)
+		return
+	}
+	logger.Infof("Queued %d tasks of %s", len(tasks), scraper.Name)
+}
+
+// StartQueue starts the workers consuming the scrape tasks, and the job that requeues the tasks of workers that stopped
+func StartQueue() {
+	if !ScrapeQueue && QueueWorkers == 0 {
+		return
+	}
+	requeue := func() {
+		if n, err := db.RequeueStaleScrapeTasks(QueueTaskTimeout); err != nil {
+			logger.Errorf("Failed to requeue stale scrape tasks: %v", err)
+		} else if n > 0 {
+			logger.Warnf("Requeued %d scrape tasks running for longer than %s", n, QueueTaskTimeout)
+		}
+		if err := db.DeleteScrapeTasks(time.Now().Add(-queueTaskRetention)); err != nil {
+			logger.Errorf("Failed to delete old scrape tasks: %v", err)
+		}
+	}
+	if _, err := cronManger.AddFunc("@every 5m", exclusive("queue", requeue)); err != nil {
+		logger.Errorf("Failed to schedule the scrape queue maintenance: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	for i := 0; i < QueueWorkers; i++ {
+		go runWorker(fmt.Sprintf("%s/%d", hostname, i))
+	}
+}
+
+func runWorker(worker string) {
+	for ShutdownContext.Err() == nil {
+		task, err := db.ClaimScrapeTask(worker)
+		if err != nil {
+			logger.Errorf("Failed to claim a scrape task: %v", err)
+		}
+		if task == nil {
+			time.Sleep(queuePollInterval)
+			continue
+		}
+		runTask(task)
+	}
+}
+
+func runTask(task *models.ScrapeTask) {
+	var scraper v1.ConfigScraper
+	err := json.Unmarshal([]byte(task.Spec), &scraper)
+	// the name and id are not part of the spec
+	scraper.Name, scraper.ID = task.Name, task.ScraperID
+	var summary ScrapeSummary
+	if err == nil {
+		logger.Infof("Running task %s of %s %s (attempt %d)", task.ID, task.Name, task.Region, task.Attempts)
+		summary, err = RunScraperWithSummary(scraper, nil)
+	}
+	if err != nil {
+		logger.Errorf("Task %s of %s failed: %v", task.ID, task.Name, err)
+	}
+	if err := db.CompleteScrapeTask(task, err, QueueMaxAttempts); errors.Is(err, db.ErrScrapeTaskNotClaimed) {
+		logger.Warnf("Task %s of %s was requeued before it completed", task.ID, task.Name)
+	} else if err != nil {
+		logger.Errorf("Failed to save scrape task %s: %v", task.ID, err)
+	}
+	notifyScrapeComplete(task.ScraperID, summary, err)
+}
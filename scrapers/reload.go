@@ -0,0 +1,192 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long the watcher waits for a file to stop changing before reloading it,
+// editors and kubernetes config map updates write a file in several steps
+const reloadDelay = time.Second
+
+var (
+	// fileScrapers are the specs of the scrapers loaded from each config file by their id, files are keyed by their
+	// cleaned path
+	fileScrapers = make(map[string]map[string]string)
+	fileLock     sync.Mutex
+)
+
+// FileScraperID identifies a scraper loaded from a file in the cron
+func FileScraperID(scraper v1.ConfigScraper) string {
+	return "file:" + scraper.Name
+}
+
+// ConfigFiles expands the directories in paths to the scraper files in them
+func ConfigFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isConfigFile(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func isConfigFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return !strings.HasPrefix(filepath.Base(name), ".")
+	}
+	return false
+}
+
+// LoadConfigFile schedules the scrapers of the file and removes the scrapers that are no longer in it,
+// the scrapers that are new or changed are returned so that they can be run
+func LoadConfigFile(file string) ([]v1.ConfigScraper, error) {
+	file = filepath.Clean(file)
+	var configs []v1.ConfigScraper
+	if _, err := os.Stat(file); err == nil {
+		if configs, err = v1.ParseConfigs(file); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	previous := fileScrapers[file]
+	delete(fileScrapers, file)
+	current := make(map[string]string)
+	var changed []v1.ConfigScraper
+	for _, scraper := range configs {
+		id := FileScraperID(scraper)
+		spec, _ := json.Marshal(scraper)
+		current[id] = string(spec)
+		if previous[id] == string(spec) {
+			continue
+		}
+		// a scraper moved from another file is owned by this file, so that reloading the other file keeps it
+		for _, scrapers := range fileScrapers {
+			delete(scrapers, id)
+		}
+		RemoveFromCron(id)
+		AddToCron(scraper, id)
+		changed = append(changed, scraper)
+	}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			logger.Infof("Removing scraper %s", strings.TrimPrefix(id, "file:"))
+			RemoveFromCron(id)
+		}
+	}
+	if len(current) == 0 {
+		delete(fileScrapers, file)
+	} else {
+		fileScrapers[file] = current
+	}
+	return changed, nil
+}
+
+// WatchConfigFiles reloads the scraper files in paths, and the files added to directories in paths, when they change.
+// The parent directories of files are watched as files are usually replaced rather than written to
+func WatchConfigFiles(paths []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs[path] = true
+		} else {
+			files[path] = true
+			path = filepath.Dir(path)
+		}
+		if err := watcher.Add(path); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		timers := make(map[string]*time.Timer)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				file := filepath.Clean(event.Name)
+				if filepath.Base(file) == "..data" {
+					// config maps mounted in pods are updated by swapping the ..data symlink
+					file = filepath.Dir(file)
+				} else if !files[file] && !(dirs[filepath.Dir(file)] && isConfigFile(file)) {
+					continue
+				}
+				if timer, ok := timers[file]; ok {
+					timer.Reset(reloadDelay)
+					continue
+				}
+				timers[file] = time.AfterFunc(reloadDelay, func() { reloadConfigFiles(file, files, dirs) })
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Errorf("config file watcher failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadConfigFiles reloads the file, or the watched files in it when it is a directory
+func reloadConfigFiles(path string, files, dirs map[string]bool) {
+	reload := []string{path}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		reload = nil
+		if dirs[path] {
+			reload, _ = ConfigFiles([]string{path})
+		}
+		for file := range files {
+			if filepath.Dir(file) == path {
+				reload = append(reload, file)
+			}
+		}
+	}
+	for _, file := range reload {
+		logger.Infof("Reloading %s", file)
+		changed, err := LoadConfigFile(file)
+		if err != nil {
+			// the scrapers loaded before keep running until the file is fixed
+			logger.Errorf("Failed to reload %s: %v", file, err)
+			continue
+		}
+		for _, scraper := range changed {
+			RunExclusive(scraper, FileScraperID(scraper))
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package scrapers
+
+import (
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/config-db/reports"
+)
+
+// ReportSchedule of the job that exports the posture report, reports are disabled when empty
+var ReportSchedule string
+
+// StartReports schedules the report export
+func StartReports() {
+	if ReportSchedule == "" || reports.Output == "" {
+		return
+	}
+	if _, err := cronManger.AddFunc(ReportSchedule, exclusive("report", exportReport)); err != nil {
+		logger.Errorf("Failed to schedule reports using %s: %v", ReportSchedule, err)
+	}
+}
+
+func exportReport() {
+	if err := reports.Export(); err != nil {
+		logger.Errorf("Failed to export report: %v", err)
+		return
+	}
+	logger.Infof("Exported report to %s", reports.Output)
+}
@@ -0,0 +1,129 @@
+package scrapers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+)
+
+// RetentionSchedule of the background job that prunes old changes and deleted config items
+var RetentionSchedule string
+
+// fileRetentionTypes maps the name of scrapers loaded from files to the config types of their last run, the items
+// of scrapers loaded from files are saved without a scraper id
+var fileRetentionTypes = sync.Map{}
+
+type retentionOverride struct {
+	name      string
+	retention v1.Retention
+	types     []string
+}
+
+// recordRetentionTypes remembers the config types returned by a scraper loaded from a file with a retention override
+func recordRetentionTypes(config v1.ConfigScraper, results []v1.ScrapeResult) {
+	if config.Retention == nil || config.ID != "" {
+		return
+	}
+	seen := make(map[string]bool)
+	var types []string
+	for _, result := range results {
+		if result.ExternalType != "" && !seen[result.ExternalType] {
+			seen[result.ExternalType] = true
+			types = append(types, result.ExternalType)
+		}
+	}
+	fileRetentionTypes.Store(config.Name, types)
+}
+
+// retentionOverrides returns the scrapers with a retention override from the persisted scrape configs and the
+// scheduled file scrapers, so that a retention that is changed or removed applies to the next run
+func retentionOverrides() []retentionOverride {
+	var overrides []retentionOverride
+	configs, err := db.GetScrapeConfigs()
+	if err != nil {
+		logger.Errorf("Failed to get the scrape configs of the retention: %v", err)
+	}
+	for _, config := range configs {
+		spec, err := config.V1ConfigScraper()
+		if err != nil || spec.Retention == nil {
+			continue
+		}
+		types, err := db.ScraperConfigTypes(config.ID.String())
+		if err != nil {
+			logger.Errorf("Failed to get the config types of scraper %s: %v", config.ID, err)
+			continue
+		}
+		overrides = append(overrides, retentionOverride{name: config.ID.String(), retention: *spec.Retention, types: types})
+	}
+
+	scheduled.Range(func(key, value any) bool {
+		scraper := value.(v1.ConfigScraper)
+		if !strings.HasPrefix(key.(string), "file:") || scraper.Retention == nil {
+			return true
+		}
+		if types, ok := fileRetentionTypes.Load(scraper.Name); ok {
+			overrides = append(overrides, retentionOverride{name: scraper.Name, retention: *scraper.Retention, types: types.([]string)})
+		}
+		return true
+	})
+	return overrides
+}
+
+// StartRetention schedules the retention job
+func StartRetention() {
+	if RetentionSchedule == "" {
+		return
+	}
+	if _, err := cronManger.AddFunc(RetentionSchedule, exclusive("retention", runRetention)); err != nil {
+		logger.Errorf("Failed to schedule retention using %s: %v", RetentionSchedule, err)
+	}
+}
+
+func runRetention() {
+	var overridden []string
+	for _, override := range retentionOverrides() {
+		if len(override.types) == 0 {
+			continue
+		}
+		overridden = append(overridden, override.types...)
+		prune(override.name, override.types, false, orDefault(override.retention.ChangeDays, db.ChangeRetentionDays),
+			orDefault(override.retention.DeletedItemDays, db.DeletedItemRetentionDays))
+	}
+	prune("global", overridden, true, db.ChangeRetentionDays, db.DeletedItemRetentionDays)
+
+	if compacted, err := db.CompactChanges(db.MaxChangesPerItem); err != nil {
+		logger.Errorf("Failed to compact config changes: %v", err)
+	} else if compacted > 0 {
+		logger.Infof("Compacted %d config changes", compacted)
+	}
+
+	if pruned, err := db.PruneAuditLog(db.AuditRetentionDays); err != nil {
+		logger.Errorf("Failed to prune the audit log: %v", err)
+	} else if pruned > 0 {
+		logger.Infof("Pruned %d audit log entries", pruned)
+	}
+}
+
+func prune(name string, types []string, exclude bool, changeDays, deletedItemDays int) {
+	changes, err := db.PruneChanges(changeDays, types, exclude)
+	if err != nil {
+		logger.Errorf("[%s] failed to prune config changes: %v", name, err)
+	}
+	items, err := db.PurgeDeletedItems(deletedItemDays, types, exclude)
+	if err != nil {
+		logger.Errorf("[%s] failed to purge deleted config items: %v", name, err)
+	}
+	if changes > 0 || items > 0 {
+		logger.Infof("[%s] retention deleted %d changes and %d config items", name, changes, items)
+	}
+}
+
+func orDefault(value, def int) int {
+	if value > 0 {
+		return value
+	}
+	return def
+}
@@ -1,28 +1,128 @@
 package scrapers
 
 import (
-	"context"
 	"fmt"
+	"time"
 
+	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+	"github.com/flanksource/config-db/db/ulid"
 	"github.com/flanksource/config-db/utils/kube"
 )
 
+// OnScrapeComplete is called after every scheduled or triggered run of a scraper with an id
+var OnScrapeComplete func(id string, summary ScrapeSummary, err error)
+
+// Connections caches the sessions and clients of connections, so that scrapers sharing a connection
+// and scheduled runs reuse them instead of re-authenticating
+var Connections = v1.NewConnectionCache(15 * time.Minute)
+
 func RunScraper(scraper v1.ConfigScraper) error {
+	_, err := RunScraperWithSummary(scraper, nil)
+	return err
+}
+
+// RunScraperForTargets runs the scraper, scrapers that support targets only scrape the given resources
+func RunScraperForTargets(scraper v1.ConfigScraper, targets []string) error {
+	_, err := RunScraperWithSummary(scraper, targets)
+	return err
+}
+
+// RunScraperWithSummary runs the scraper for the targets and returns a summary of the run,
+// every run is recorded in scrape_runs
+func RunScraperWithSummary(scraper v1.ConfigScraper, targets []string) (ScrapeSummary, error) {
+	summary, err := runScraper(scraper, targets)
+	if summary.StartTime.IsZero() {
+		summary.StartTime = time.Now()
+	}
+	run := models.ScrapeRun{
+		ID:           summary.RunID,
+		Name:         scraper.Name,
+		Tenant:       scraper.Tenant,
+		Status:       scrapeRunStatus(summary, err),
+		StartTime:    summary.StartTime,
+		EndTime:      time.Now(),
+		Items:        summary.Items,
+		Errors:       summary.Errors,
+		ErrorSummary: summary.ErrorMessages,
+		ItemErrors:   summary.ItemErrors,
+	}
+	if err != nil {
+		run.ErrorSummary = append(run.ErrorSummary, err.Error())
+	}
+	if err := db.PersistScrapeRun(&run); err != nil {
+		logger.Errorf("Error persisting scrape run: %v", err)
+	}
+	return summary, err
+}
+
+func scrapeRunStatus(summary ScrapeSummary, err error) string {
+	switch {
+	case err != nil:
+		return "failed"
+	case summary.Errors > 0:
+		return "partial"
+	default:
+		return "success"
+	}
+}
+
+func runScraper(scraper v1.ConfigScraper, targets []string) (ScrapeSummary, error) {
+	summary := ScrapeSummary{RunID: ulid.MustNew().AsUUID()}
 	kommonsClient, err := kube.NewKommonsClient()
 	if err != nil {
-		return fmt.Errorf("failed to get kubernetes client: %v", err)
+		return summary, fmt.Errorf("failed to get kubernetes client: %v", err)
+	}
+
+	if !startScrape() {
+		return summary, errShuttingDown
+	}
+	defer runningScrapes.Done()
+	ctx := &v1.ScrapeContext{Context: ShutdownContext, Kommons: kommonsClient, Scraper: &scraper, Targets: targets, Connections: Connections, RunID: summary.RunID, Tenant: scraper.Tenant, AfterSave: &v1.Hooks{}}
+	if len(targets) == 0 {
+		completed, err := db.GetScrapeCheckpoint(scraper.Name, checkpointMaxAge)
+		if err != nil {
+			logger.Warnf("failed to get the checkpoint of %s: %v", scraper.Name, err)
+		} else if len(completed) > 0 {
+			logger.Infof("Resuming %s after %d tasks completed before it was interrupted", scraper.Name, len(completed))
+		}
+		ctx.Checkpoint = v1.NewCheckpoint(completed)
 	}
 
-	ctx := &v1.ScrapeContext{Context: context.Background(), Kommons: kommonsClient, Scraper: &scraper}
 	var results []v1.ScrapeResult
-	if results, err = Run(ctx, scraper); err != nil {
-		return fmt.Errorf("Failed to run scraper %s: %v", scraper, err)
+	if results, err = run(ctx, &summary, scraper); err != nil {
+		return summary, fmt.Errorf("Failed to run scraper %v: %v", scraper, err)
+	}
+	// the results of an interrupted scrape are saved before the process exits
+	saveCtx := *ctx
+	saveCtx.Context = drainContext
+	if ctx.Cancelled() {
+		// the events of an interrupted scrape are received again as not every task scraped their changes
+		saveCtx.AfterSave = nil
 	}
-	if err = db.SaveResults(ctx, results); err != nil {
+	if err = db.SaveResults(&saveCtx, results); err != nil {
 		//FIXME cache results to save to db later
-		return fmt.Errorf("Failed to update db: %v", err)
+		return summary, fmt.Errorf("Failed to update db: %v", err)
+	}
+	if ctx.Checkpoint == nil {
+		return summary, nil
+	}
+	if ctx.Cancelled() {
+		if err := db.SaveScrapeCheckpoint(scraper.Name, ctx.Checkpoint.Completed()); err != nil {
+			logger.Errorf("failed to save the checkpoint of %s: %v", scraper.Name, err)
+		}
+		return summary, fmt.Errorf("scrape of %s was interrupted after %d tasks", scraper.Name, len(ctx.Checkpoint.Completed()))
+	}
+	if err := db.DeleteScrapeCheckpoint(scraper.Name); err != nil {
+		logger.Errorf("failed to delete the checkpoint of %s: %v", scraper.Name, err)
+	}
+	return summary, nil
+}
+
+func notifyScrapeComplete(id string, summary ScrapeSummary, err error) {
+	if id != "" && OnScrapeComplete != nil {
+		OnScrapeComplete(id, summary, err)
 	}
-	return nil
 }
@@ -3,32 +3,118 @@ package scrapers
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	v1 "github.com/flanksource/config-db/api/v1"
 	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/metrics"
 	"github.com/flanksource/config-db/scrapers/analysis"
 	"github.com/flanksource/config-db/scrapers/changes"
+	"github.com/flanksource/config-db/scrapers/policy"
 	"github.com/flanksource/config-db/scrapers/processors"
+	"github.com/flanksource/config-db/utils/templating"
 	"github.com/flanksource/duty/models"
 )
 
+// ScrapeSummary of a scraper run
+type ScrapeSummary struct {
+	// RunID is the id of the run in scrape_runs
+	RunID     string
+	StartTime time.Time
+	Time      time.Time
+	Items     int
+	Errors    int
+	LastError string
+	// ErrorMessages are the distinct errors of the run, up to maxErrorMessages
+	ErrorMessages []string
+	// ItemErrors are the errors of single items, up to maxItemErrors
+	ItemErrors []v1.ScrapeError
+}
+
+const (
+	maxErrorMessages = 10
+	maxItemErrors    = 100
+)
+
+func (s *ScrapeSummary) addError(err error) {
+	s.Errors++
+	s.LastError = err.Error()
+	if len(s.ErrorMessages) >= maxErrorMessages {
+		return
+	}
+	for _, msg := range s.ErrorMessages {
+		if msg == s.LastError {
+			return
+		}
+	}
+	s.ErrorMessages = append(s.ErrorMessages, s.LastError)
+}
+
+// addResultError records the error of a result, errors of results that identify an item are recorded as item errors
+// instead of error messages, as they are distinct for every item and would crowd out the other errors
+func (s *ScrapeSummary) addResultError(result v1.ScrapeResult) {
+	if result.ID == "" {
+		s.addError(result.Error)
+		return
+	}
+	s.Errors++
+	s.LastError = result.Error.Error()
+	if len(s.ItemErrors) < maxItemErrors {
+		s.ItemErrors = append(s.ItemErrors, v1.ScrapeError{
+			ExternalType: result.ExternalType,
+			ExternalID:   result.ID,
+			Message:      s.LastError,
+		})
+	}
+}
+
 // Run ...
 func Run(ctx *v1.ScrapeContext, configs ...v1.ConfigScraper) ([]v1.ScrapeResult, error) {
+	return run(ctx, &ScrapeSummary{}, configs...)
+}
+
+func run(ctx *v1.ScrapeContext, summary *ScrapeSummary, configs ...v1.ConfigScraper) ([]v1.ScrapeResult, error) {
+	summary.StartTime = time.Now()
 	cwd, _ := os.Getwd()
 	logger.Infof("Scraping files from (PWD: %s)", cwd)
 
 	results := []v1.ScrapeResult{}
 	for _, config := range configs {
+		configStart := len(results)
+		policies, err := policy.Compile(ctx, config.Policies)
+		if err != nil {
+			logger.Errorf("failed to compile policies: %v", err)
+			summary.addError(err)
+		}
+		if err := templating.RegisterLibraries(ctx, config.Libraries); err != nil {
+			logger.Errorf("failed to register libraries: %v", err)
+			summary.addError(err)
+		}
+		filter, err := newItemFilter(ctx, config)
+		if err != nil {
+			logger.Errorf("failed to compile filters: %v", err)
+			summary.addError(err)
+			continue
+		}
 		for _, scraper := range All {
+			if ctx.Cancelled() {
+				// the scrape was cancelled, what was scraped so far is returned to be saved
+				break
+			}
 			jobHistory := models.JobHistory{
 				Name: fmt.Sprintf("scraper:%T", scraper),
 			}
 			jobHistory.Start()
-			if err := db.PersistJobHistory(&jobHistory); err != nil {
+			if err := persistJobHistory(ctx, &jobHistory); err != nil {
 				logger.Errorf("Error persisting job history: %v", err)
 			}
-			for _, result := range scraper.Scrape(ctx, config) {
+			configType := scraperType(scraper)
+			start := time.Now()
+			scraped := scraper.Scrape(ctx, config)
+			metrics.Since(metrics.ScrapeDuration.WithLabelValues(config.Name, configType), start)
+			metrics.ScrapeResults.WithLabelValues(config.Name, configType).Add(float64(len(scraped)))
+			for _, result := range scraped {
 				if result.AnalysisResult != nil {
 					if rule, ok := analysis.Rules[result.AnalysisResult.Analyzer]; ok {
 						result.AnalysisResult.AnalysisType = rule.Category
@@ -47,6 +133,7 @@ func Run(ctx *v1.ScrapeContext, configs ...v1.ConfigScraper) ([]v1.ScrapeResult,
 						jobHistory.AddError(err.Error())
 						continue
 					}
+					extractor = extractor.WithContext(ctx)
 
 					scraped, err := extractor.Extract(result)
 					if err != nil {
@@ -55,19 +142,50 @@ func Run(ctx *v1.ScrapeContext, configs ...v1.ConfigScraper) ([]v1.ScrapeResult,
 						continue
 					}
 
-					results = append(results, scraped...)
+					for _, item := range scraped {
+						if keep, err := filter.Keep(item); err != nil {
+							logger.Errorf("failed to filter: %v", err)
+							jobHistory.AddError(err.Error())
+							continue
+						} else if !keep {
+							continue
+						}
+						results = append(results, item)
+						results = append(results, policy.Evaluate(ctx, policies, item)...)
+					}
 				}
 				if result.Error != nil {
 					jobHistory.AddError(result.Error.Error())
+					metrics.ScrapeErrors.WithLabelValues(config.Name, configType).Inc()
+					summary.addResultError(result)
 				} else {
 					jobHistory.IncrSuccess()
 				}
 			}
 			jobHistory.End()
-			if err := db.PersistJobHistory(&jobHistory); err != nil {
+			if err := persistJobHistory(ctx, &jobHistory); err != nil {
 				logger.Errorf("Error persisting job history: %v", err)
 			}
 		}
+		if owners := processors.InferOwnership(config.Ownership, results[configStart:]); len(owners) > 0 {
+			// owners are saved first, so that the relationships of the items to them can be resolved
+			results = append(results[:configStart], append(owners, results[configStart:]...)...)
+		}
+		recordRetentionTypes(config, results[configStart:])
+	}
+	summary.Time = time.Now()
+	for _, result := range results {
+		if result.Config != nil {
+			summary.Items++
+		}
 	}
 	return results, nil
 }
+
+// persistJobHistory saves the job history unless it is a dry run
+func persistJobHistory(ctx *v1.ScrapeContext, jobHistory *models.JobHistory) error {
+	if ctx.DryRun {
+		return nil
+	}
+	return db.PersistJobHistory(jobHistory)
+}
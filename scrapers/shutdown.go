@@ -0,0 +1,61 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+)
+
+// checkpointMaxAge is how long the checkpoint of an interrupted scrape is resumed from, older checkpoints
+// are ignored and the scraper starts over
+const checkpointMaxAge = 6 * time.Hour
+
+var (
+	// ShutdownContext is cancelled on shutdown, running scrapes stop starting new tasks and save what they scraped
+	ShutdownContext, cancelScrapes = context.WithCancel(context.Background())
+	// drainContext is used to save the results of the running scrapes, it is cancelled once the shutdown times out
+	drainContext, cancelDrain = context.WithCancel(context.Background())
+	runningScrapes            sync.WaitGroup
+
+	shutdownLock sync.Mutex
+	shuttingDown bool
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// startScrape registers a running scrape that the shutdown waits for, it returns false once the shutdown started
+func startScrape() bool {
+	shutdownLock.Lock()
+	defer shutdownLock.Unlock()
+	if shuttingDown {
+		return false
+	}
+	runningScrapes.Add(1)
+	return true
+}
+
+// Shutdown cancels the running scrapes and waits up to the timeout for them to save their partial results
+// and checkpoints, no more scrapes are started
+func Shutdown(timeout time.Duration) {
+	shutdownLock.Lock()
+	shuttingDown = true
+	shutdownLock.Unlock()
+
+	cronManger.Stop()
+	cancelScrapes()
+
+	done := make(chan struct{})
+	go func() {
+		runningScrapes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Warnf("Scrapes did not stop within %s", timeout)
+		cancelDrain()
+	}
+}
@@ -0,0 +1,76 @@
+package scrapers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/scrapers/aws"
+	"github.com/flanksource/config-db/utils/kube"
+)
+
+var (
+	triggerLock   sync.Mutex
+	triggerCancel = make(map[string]context.CancelFunc)
+)
+
+// StartTrigger starts a consumer that runs the scraper whenever its trigger receives messages,
+// an existing consumer with the same id is stopped first
+func StartTrigger(scraper v1.ConfigScraper, id string) {
+	if scraper.Trigger == nil || scraper.Trigger.SQS == nil {
+		return
+	}
+	StopTrigger(id)
+
+	// scrapers without an id are never removed, so their consumer does not need to be stopped
+	if id == "" {
+		go consumeTrigger(context.Background(), scraper, id)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	triggerLock.Lock()
+	triggerCancel[id] = cancel
+	triggerLock.Unlock()
+	go consumeTrigger(ctx, scraper, id)
+}
+
+func StopTrigger(id string) {
+	triggerLock.Lock()
+	defer triggerLock.Unlock()
+	if cancel, exists := triggerCancel[id]; exists {
+		cancel()
+		delete(triggerCancel, id)
+	}
+}
+
+func consumeTrigger(ctx context.Context, scraper v1.ConfigScraper, id string) {
+	kommonsClient, err := kube.NewKommonsClient()
+	if err != nil {
+		logger.Errorf("failed to get kubernetes client: %v", err)
+		return
+	}
+	scrapeCtx := &v1.ScrapeContext{Context: ctx, Kommons: kommonsClient, Scraper: &scraper, Connections: Connections}
+
+	for ctx.Err() == nil {
+		triggered, targets, err := aws.ReceiveTrigger(scrapeCtx, *scraper.Trigger.SQS)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("failed to receive trigger from %s: %v", scraper.Trigger.SQS.QueueURL, err)
+				time.Sleep(time.Minute)
+			}
+			continue
+		}
+		if !triggered {
+			continue
+		}
+		logger.Infof("Running scraper triggered by %s, targets=%v", scraper.Trigger.SQS.QueueURL, targets)
+		summary, err := RunScraperWithSummary(scraper, targets)
+		if err != nil {
+			logger.Errorf("Error running scraper: %v", err)
+		}
+		notifyScrapeComplete(id, summary, err)
+	}
+}
@@ -0,0 +1,28 @@
+package scrapers
+
+import (
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/config-db/upstream"
+)
+
+// UpstreamSchedule of the job that reconciles the config items to the hub in agent mode
+var UpstreamSchedule string
+
+// StartUpstream schedules the reconciliation when an upstream hub is configured
+func StartUpstream() {
+	if upstream.URL == "" {
+		return
+	}
+	if upstream.Agent == "" {
+		logger.Fatalf("--agent-name is required with --upstream-url")
+	}
+	if _, err := cronManger.AddFunc(UpstreamSchedule, exclusive("upstream", reconcileUpstream)); err != nil {
+		logger.Errorf("Failed to schedule upstream reconciliation using %s: %v", UpstreamSchedule, err)
+	}
+}
+
+func reconcileUpstream() {
+	if err := upstream.Reconcile(); err != nil {
+		logger.Errorf("Failed to reconcile with %s: %v", upstream.URL, err)
+	}
+}
@@ -0,0 +1,291 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/config/schemas"
+	"github.com/flanksource/config-db/secrets"
+	"github.com/flanksource/config-db/utils/templating"
+	"github.com/flanksource/kommons"
+	"github.com/robfig/cron/v3"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a mistake in a scraper file, the line is 0 when it is not known
+type ValidationError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", location, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, e.Message)
+}
+
+var scraperSchema *gojsonschema.Schema
+
+// loadScraperSchema compiles the embedded schema, objects of the schema are closed so that misspelled fields are reported
+func loadScraperSchema() (*gojsonschema.Schema, error) {
+	if scraperSchema != nil {
+		return scraperSchema, nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemas.Scraper, &schema); err != nil {
+		return nil, err
+	}
+	closeObjects(schema)
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	if err != nil {
+		return nil, err
+	}
+	scraperSchema = compiled
+	return scraperSchema, nil
+}
+
+func closeObjects(schema interface{}) {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		if _, ok := s["properties"]; ok {
+			if _, ok := s["additionalProperties"]; !ok && s["x-kubernetes-preserve-unknown-fields"] != true {
+				s["additionalProperties"] = false
+			}
+		}
+		for _, v := range s {
+			closeObjects(v)
+		}
+	case []interface{}:
+		for _, v := range s {
+			closeObjects(v)
+		}
+	}
+}
+
+// Validate checks the scraper file against the schema, compiles its templates and scripts, and resolves the secrets
+// it references, without scraping
+func Validate(ctx *v1.ScrapeContext, file string) []ValidationError {
+	data, err := readScraperFile(file)
+	if err != nil {
+		return []ValidationError{{File: file, Message: err.Error()}}
+	}
+	schema, err := loadScraperSchema()
+	if err != nil {
+		return []ValidationError{{File: file, Message: fmt.Sprintf("invalid schema: %v", err)}}
+	}
+
+	var errs []ValidationError
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err == io.EOF {
+			break
+		} else if err != nil {
+			return append(errs, ValidationError{File: file, Message: err.Error()})
+		}
+		errs = append(errs, validateDocument(ctx, file, schema, &document)...)
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+func readScraperFile(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func validateDocument(ctx *v1.ScrapeContext, file string, schema *gojsonschema.Schema, document *yaml.Node) []ValidationError {
+	var errs []ValidationError
+	report := func(field, message string) {
+		errs = append(errs, ValidationError{File: file, Line: fieldLine(document, field), Field: field, Message: message})
+	}
+
+	var obj interface{}
+	if err := document.Decode(&obj); err != nil {
+		report("", err.Error())
+		return errs
+	}
+	if obj == nil {
+		return nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		report("", err.Error())
+		return errs
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		report("", err.Error())
+		return errs
+	}
+	for _, e := range result.Errors() {
+		field := e.Field()
+		if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			field = ""
+		}
+		if property, ok := e.Details()["property"].(string); ok && e.Type() == "additional_property_not_allowed" {
+			field = joinField(field, property)
+		}
+		report(field, e.Description())
+	}
+	if !result.Valid() {
+		// the templates and secrets of an invalid scraper cannot be found reliably
+		return errs
+	}
+
+	var scraper v1.ConfigScraper
+	if err := json.Unmarshal(data, &scraper); err != nil {
+		report("", err.Error())
+		return errs
+	}
+	if scraper.Schedule != "" {
+		if _, err := cron.ParseStandard(scraper.Schedule); err != nil {
+			report("schedule", err.Error())
+		}
+	}
+	if scraper.Jitter != "" {
+		if _, err := time.ParseDuration(scraper.Jitter); err != nil {
+			report("jitter", err.Error())
+		}
+	}
+	walkFields(reflect.ValueOf(scraper), "", func(field string, value interface{}) {
+		if err := validateField(ctx, value); err != nil {
+			report(field, err.Error())
+		}
+	})
+	return errs
+}
+
+// validateField compiles templates and scripts and resolves secrets
+func validateField(ctx *v1.ScrapeContext, value interface{}) error {
+	switch v := value.(type) {
+	case v1.Template:
+		return templating.Compile(v)
+	case v1.Script:
+		return templating.CompileScript(v)
+	case v1.TransformStep:
+		if err := templating.Compile(v1.Template{Template: v.Filter}); err != nil {
+			return err
+		}
+		if v.Rename != nil {
+			for _, template := range []string{v.Rename.Name, v.Rename.Type, v.Rename.Namespace} {
+				if err := templating.Compile(v1.Template{Template: template}); err != nil {
+					return err
+				}
+			}
+		}
+		if v.Relate != nil {
+			return templating.Compile(v1.Template{Template: v.Relate.ID})
+		}
+	case kommons.EnvVar:
+		if v.ValueFrom == nil && !secrets.IsReference(v.Value) {
+			return nil
+		}
+		if _, err := secrets.GetEnvValue(ctx, v, ctx.GetNamespace()); err != nil {
+			return fmt.Errorf("failed to resolve secret: %v", err)
+		}
+	}
+	return nil
+}
+
+// walkFields calls fn with every struct in the value and the dotted path of its json field names
+func walkFields(value reflect.Value, path string, fn func(field string, value interface{})) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			walkFields(value.Elem(), path, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			walkFields(value.Index(i), joinField(path, strconv.Itoa(i)), fn)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			walkFields(value.MapIndex(key), joinField(path, fmt.Sprint(key.Interface())), fn)
+		}
+	case reflect.Struct:
+		if value.CanInterface() {
+			fn(path, value.Interface())
+		}
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				if field.Anonymous {
+					// embedded structs are inlined
+					walkFields(value.Field(i), path, fn)
+					continue
+				}
+				name = field.Name
+			}
+			walkFields(value.Field(i), joinField(path, name), fn)
+		}
+	}
+}
+
+func joinField(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// fieldLine returns the line of the dotted field in the document, or of its deepest parent that exists
+func fieldLine(document *yaml.Node, field string) int {
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+	if field == "" {
+		return line
+	}
+	for _, part := range strings.Split(field, ".") {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(part); err == nil && i < len(node.Content) {
+				next = node.Content[i]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+	}
+	return line
+}
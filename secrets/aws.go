@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsSecretsManager uses the default credential chain, the region of the secret can be included in its ARN
+type awsSecretsManager struct {
+	once   sync.Once
+	client *secretsmanager.Client
+	err    error
+}
+
+func (a *awsSecretsManager) Get(ctx context.Context, id, key string) (string, time.Duration, error) {
+	a.once.Do(func() {
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			a.err = err
+			return
+		}
+		a.client = secretsmanager.NewFromConfig(cfg)
+	})
+	if a.err != nil {
+		return "", 0, a.err
+	}
+
+	output, err := a.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &id})
+	if err != nil {
+		return "", 0, err
+	}
+	if output.SecretString == nil {
+		return "", 0, fmt.Errorf("secret %s is binary", id)
+	}
+	if key == "" {
+		return *output.SecretString, 0, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*output.SecretString), &fields); err != nil {
+		return "", 0, fmt.Errorf("secret %s is not a JSON object: %v", id, err)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", 0, fmt.Errorf("key %s not found in %s", key, id)
+	}
+	return fmt.Sprint(value), 0, nil
+}
@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	keyVaultResource   = "https://vault.azure.net"
+	keyVaultAPIVersion = "7.3"
+	imdsTokenURL       = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureKeyVault authenticates with the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET of a service
+// principal, or with the managed identity of the host when they are not set
+type azureKeyVault struct {
+	lock    sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (a *azureKeyVault) Get(ctx context.Context, path, _ string) (string, time.Duration, error) {
+	vaultName, secret, ok := strings.Cut(path, "/")
+	if !ok {
+		return "", 0, fmt.Errorf("expected <vault>/<secret>, got %s", path)
+	}
+	token, err := a.getToken(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	u := fmt.Sprintf("https://%s.vault.azure.net/secrets/%s?api-version=%s", vaultName, secret, keyVaultAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var response struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(req, &response); err != nil {
+		return "", 0, err
+	}
+	return response.Value, 0, nil
+}
+
+func (a *azureKeyVault) getToken(ctx context.Context) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token != "" && time.Now().Before(a.expires) {
+		return a.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if secret := os.Getenv("AZURE_CLIENT_SECRET"); secret != "" {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {os.Getenv("AZURE_CLIENT_ID")},
+			"client_secret": {secret},
+			"scope":         {keyVaultResource + "/.default"},
+		}
+		u := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", os.Getenv("AZURE_TENANT_ID"))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, imdsTokenURL+"?api-version=2018-02-01&resource="+url.QueryEscape(keyVaultResource), nil)
+		if err == nil {
+			req.Header.Set("Metadata", "true")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := doJSON(req, &response); err != nil {
+		return "", fmt.Errorf("failed to get an azure token: %v", err)
+	}
+	expiresIn, _ := strconv.Atoi(response.ExpiresIn.String())
+	a.token = response.AccessToken
+	a.expires = time.Now().Add(time.Duration(expiresIn) * time.Second * 8 / 10)
+	return a.token, nil
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
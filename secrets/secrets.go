@@ -0,0 +1,149 @@
+// Package secrets resolves the credentials of connections from external secret stores.
+//
+// A value of a connection that is a reference to a secret store is resolved by the backend of its scheme:
+//
+//	vault://secret/data/aws#access_key             HashiCorp Vault, KV v1 or v2
+//	aws-secretsmanager://prod/aws#access_key       AWS Secrets Manager, the key selects a field of a JSON secret
+//	azure-keyvault://my-vault/aws-secret-key       Azure Key Vault
+//
+// Resolved values are cached until the lease of the secret or --secret-cache-ttl expires, whichever is first.
+// References that do not start with one of --secret-allowed-prefixes are rejected when it is set, e.g.
+// --secret-allowed-prefixes=vault://secret/data/config-db/ restricts scrapers to the secrets under that path.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/kommons"
+	"github.com/spf13/pflag"
+)
+
+// Backend reads a secret from a secret store
+type Backend interface {
+	// Get returns the value of the key of the secret at path and how long it can be cached for, 0 uses the cache ttl
+	Get(ctx context.Context, path, key string) (string, time.Duration, error)
+}
+
+// CacheTTL is the longest time a resolved secret is cached
+var CacheTTL = 5 * time.Minute
+
+// AllowedPrefixes are the prefixes of the references that can be resolved, any reference can be resolved when empty
+var AllowedPrefixes []string
+
+var backends = map[string]Backend{
+	"vault":              &vault{},
+	"aws-secretsmanager": &awsSecretsManager{},
+	"azure-keyvault":     &azureKeyVault{},
+}
+
+// Register adds a backend for references with the scheme
+func Register(scheme string, backend Backend) {
+	backends[scheme] = backend
+}
+
+func Flags(flags *pflag.FlagSet) {
+	flags.DurationVar(&CacheTTL, "secret-cache-ttl", 5*time.Minute, "Longest time secrets from vault://, aws-secretsmanager:// and azure-keyvault:// references are cached")
+	flags.StringVar(&VaultAddr, "vault-addr", "", "Address of Vault, defaults to $VAULT_ADDR")
+	flags.StringVar(&VaultToken, "vault-token", "", "Vault token, defaults to $VAULT_TOKEN")
+	flags.StringVar(&VaultRole, "vault-role", "", "Vault role to login as with the kubernetes service account when there is no token")
+	flags.StringSliceVar(&AllowedPrefixes, "secret-allowed-prefixes", nil, "Prefixes of the secret store references scrapers can resolve, e.g. vault://secret/data/config-db/, all references are allowed when empty")
+}
+
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	cacheLock sync.Mutex
+	cache     = make(map[string]cached)
+)
+
+// IsReference returns true when the value is a reference to a secret store
+func IsReference(value string) bool {
+	_, _, _, ok := parseReference(value)
+	return ok
+}
+
+func parseReference(value string) (backend Backend, path, key string, ok bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found {
+		return nil, "", "", false
+	}
+	backend, ok = backends[scheme]
+	if !ok {
+		return nil, "", "", false
+	}
+	path, key, _ = strings.Cut(rest, "#")
+	return backend, path, key, true
+}
+
+// Resolve returns the value of a secret store reference, values that are not references are returned as is
+func Resolve(ctx context.Context, value string) (string, error) {
+	backend, path, key, ok := parseReference(value)
+	if !ok {
+		return value, nil
+	}
+	if !allowed(value) {
+		return "", fmt.Errorf("%s is not allowed by --secret-allowed-prefixes", value)
+	}
+
+	cacheLock.Lock()
+	entry, found := cache[value]
+	cacheLock.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	secret, lease, err := backend.Get(ctx, path, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", value, err)
+	}
+	ttl := CacheTTL
+	if lease > 0 && lease < ttl {
+		ttl = lease
+	}
+	cacheLock.Lock()
+	cache[value] = cached{value: secret, expires: time.Now().Add(ttl)}
+	cacheLock.Unlock()
+	return secret, nil
+}
+
+func allowed(reference string) bool {
+	if len(AllowedPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range AllowedPrefixes {
+		if strings.HasPrefix(reference, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEnvValue resolves an env var of a connection from a secret store, a kubernetes secret or config map, or its value
+func GetEnvValue(ctx *v1.ScrapeContext, env kommons.EnvVar, namespace string) (string, error) {
+	if IsReference(env.Value) {
+		var c context.Context = context.Background()
+		if ctx != nil && ctx.Context != nil {
+			c = ctx
+		}
+		return Resolve(c, env.Value)
+	}
+	if env.ValueFrom == nil {
+		return env.Value, nil
+	}
+	if ctx == nil || ctx.Kommons == nil {
+		return "", fmt.Errorf("%s requires a kubernetes connection", env.Name)
+	}
+	_, value, err := ctx.Kommons.GetEnvValueFromCache(env, namespace, CacheTTL)
+	return value, err
+}
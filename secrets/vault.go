@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	VaultAddr  string
+	VaultToken string
+	VaultRole  string
+)
+
+const serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type vault struct {
+	lock sync.Mutex
+	// token of the kubernetes login, renewed by logging in again before it expires
+	token   string
+	expires time.Time
+}
+
+func (v *vault) addr() string {
+	if VaultAddr != "" {
+		return strings.TrimSuffix(VaultAddr, "/")
+	}
+	return strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+}
+
+func (v *vault) Get(ctx context.Context, path, key string) (string, time.Duration, error) {
+	if v.addr() == "" {
+		return "", 0, fmt.Errorf("--vault-addr or VAULT_ADDR is required")
+	}
+	token, err := v.getToken(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	var response struct {
+		Data          map[string]interface{} `json:"data"`
+		LeaseDuration int                    `json:"lease_duration"`
+	}
+	if err := v.do(ctx, http.MethodGet, path, token, nil, &response); err != nil {
+		return "", 0, err
+	}
+	data := response.Data
+	// KV v2 nests the secret in data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	if key == "" {
+		key = "value"
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", 0, fmt.Errorf("key %s not found in %s", key, path)
+	}
+	return fmt.Sprint(value), time.Duration(response.LeaseDuration) * time.Second, nil
+}
+
+func (v *vault) getToken(ctx context.Context) (string, error) {
+	if VaultToken != "" {
+		return VaultToken, nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	if VaultRole == "" {
+		return "", fmt.Errorf("--vault-token, VAULT_TOKEN or --vault-role is required")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.token != "" && time.Now().Before(v.expires) {
+		return v.token, nil
+	}
+	jwt, err := os.ReadFile(serviceAccountToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %v", err)
+	}
+	var response struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	login := map[string]string{"role": VaultRole, "jwt": string(jwt)}
+	if err := v.do(ctx, http.MethodPost, "auth/kubernetes/login", "", login, &response); err != nil {
+		return "", fmt.Errorf("failed to login to vault: %v", err)
+	}
+	v.token = response.Auth.ClientToken
+	// renew at 80% of the lease, so that a token is never used after it expires
+	v.expires = time.Now().Add(time.Duration(response.Auth.LeaseDuration) * time.Second * 8 / 10)
+	return v.token, nil
+}
+
+func (v *vault) do(ctx context.Context, method, path, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr()+"/v1/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,395 @@
+// Package upstream reconciles the config items scraped by an agent to a central hub.
+//
+// The agent pushes the items updated or deleted, and the changes recorded, since the last successful reconciliation to
+// POST /upstream/push on the hub, items on the hub are owned by the agent that pushed them first
+// and pushes of the same item from another agent are reported as conflicts.
+package upstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	v1 "github.com/flanksource/config-db/api/v1"
+	"github.com/flanksource/config-db/db"
+	"github.com/flanksource/config-db/db/models"
+)
+
+var (
+	// URL of the hub, the agent mode is enabled when it is set
+	URL string
+	// Token is one of the ingest tokens of the hub
+	Token string
+	// Agent is the name of this agent on the hub
+	Agent string
+)
+
+const (
+	batchSize  = 200
+	maxRetries = 5
+	// SourcePrefix of the source of the items pushed by agents on the hub
+	SourcePrefix = "agent/"
+)
+
+// PushRequest is a batch of items reconciled by an agent
+type PushRequest struct {
+	Agent   string        `json:"agent"`
+	Items   []Item        `json:"items,omitempty"`
+	Changes []Change      `json:"changes,omitempty"`
+	Deleted []DeletedItem `json:"deleted,omitempty"`
+}
+
+// Item is a config item pushed to the hub, the parent and the relationships of the item are not part of the json
+// of a scrape result
+type Item struct {
+	ID                 string            `json:"id"`
+	Aliases            []string          `json:"aliases,omitempty"`
+	Type               string            `json:"type"`
+	ExternalType       string            `json:"external_type"`
+	Name               string            `json:"name,omitempty"`
+	Namespace          string            `json:"namespace,omitempty"`
+	Description        string            `json:"description,omitempty"`
+	Account            string            `json:"account,omitempty"`
+	Region             string            `json:"region,omitempty"`
+	Zone               string            `json:"zone,omitempty"`
+	Network            string            `json:"network,omitempty"`
+	Subnet             string            `json:"subnet,omitempty"`
+	Config             json.RawMessage   `json:"config,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	CreatedAt          *time.Time        `json:"created_at,omitempty"`
+	ParentExternalID   string            `json:"parent_external_id,omitempty"`
+	ParentExternalType string            `json:"parent_external_type,omitempty"`
+	Relationships      []Relationship    `json:"relationships,omitempty"`
+}
+
+// Relationship of an item to another item pushed by the agent
+type Relationship struct {
+	RelatedExternalID   string `json:"related_external_id"`
+	RelatedExternalType string `json:"related_external_type"`
+	Relationship        string `json:"relationship"`
+}
+
+// Change is a change of an item pushed by the agent
+type Change struct {
+	ExternalID       string                 `json:"external_id"`
+	ExternalType     string                 `json:"external_type"`
+	ExternalChangeID string                 `json:"external_change_id,omitempty"`
+	ChangeType       string                 `json:"change_type"`
+	Severity         string                 `json:"severity,omitempty"`
+	Source           string                 `json:"source,omitempty"`
+	Summary          string                 `json:"summary,omitempty"`
+	Patches          string                 `json:"patches,omitempty"`
+	Details          map[string]interface{} `json:"details,omitempty"`
+	CreatedAt        *time.Time             `json:"created_at,omitempty"`
+}
+
+type DeletedItem struct {
+	ExternalType string `json:"external_type"`
+	ExternalID   string `json:"external_id"`
+}
+
+// ScrapeResult returns the result that saves the item on the hub
+func (item Item) ScrapeResult() v1.ScrapeResult {
+	result := v1.ScrapeResult{
+		ID:                 item.ID,
+		Aliases:            item.Aliases,
+		Type:               item.Type,
+		ExternalType:       item.ExternalType,
+		Name:               item.Name,
+		Namespace:          item.Namespace,
+		Description:        item.Description,
+		Account:            item.Account,
+		Region:             item.Region,
+		Zone:               item.Zone,
+		Network:            item.Network,
+		Subnet:             item.Subnet,
+		Tags:               item.Tags,
+		CreatedAt:          item.CreatedAt,
+		ParentExternalID:   item.ParentExternalID,
+		ParentExternalType: item.ParentExternalType,
+	}
+	if len(item.Config) > 0 {
+		result.Config = item.Config
+	}
+	self := v1.ExternalID{ExternalID: []string{item.ID}, ExternalType: item.ExternalType}
+	for _, relationship := range item.Relationships {
+		result.RelationshipResults = append(result.RelationshipResults, v1.RelationshipResult{
+			ConfigExternalID:  self,
+			RelatedExternalID: v1.ExternalID{ExternalID: []string{relationship.RelatedExternalID}, ExternalType: relationship.RelatedExternalType},
+			Relationship:      relationship.Relationship,
+		})
+	}
+	return result
+}
+
+// ChangeResult returns the change result that saves the change on the hub
+func (change Change) ChangeResult() v1.ChangeResult {
+	return v1.ChangeResult{
+		ExternalID:       change.ExternalID,
+		ExternalType:     change.ExternalType,
+		ExternalChangeID: change.ExternalChangeID,
+		ChangeType:       change.ChangeType,
+		Severity:         change.Severity,
+		Source:           change.Source,
+		Summary:          change.Summary,
+		Patches:          change.Patches,
+		Details:          change.Details,
+		CreatedAt:        change.CreatedAt,
+	}
+}
+
+// PushResponse reports the items saved by the hub
+type PushResponse struct {
+	Accepted int `json:"accepted"`
+	Changes  int `json:"changes"`
+	Deleted  int `json:"deleted"`
+	// Conflicts are the ids of items owned by another agent
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+var (
+	lock sync.Mutex
+	// the watermarks of the last reconciled update, change and delete, rows with the same timestamp are ordered by id
+	lastUpdate  time.Time
+	lastUpdated string
+	lastChange  time.Time
+	lastChanged string
+	lastDelete  time.Time
+	lastDeleted string
+)
+
+var client = &http.Client{Timeout: time.Minute}
+
+// Reconcile pushes the items changed since the last reconciliation, after a restart all the items are pushed again
+func Reconcile() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	pushed := 0
+	for {
+		items, err := db.GetConfigItemsUpdatedSince(lastUpdate, lastUpdated, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		req := PushRequest{Agent: Agent}
+		if req.Items, err = toItems(items); err != nil {
+			return err
+		}
+		if err := push(req); err != nil {
+			return err
+		}
+		last := items[len(items)-1]
+		lastUpdate, lastUpdated = last.UpdatedAt, last.ID
+		pushed += len(items)
+	}
+
+	changed := 0
+	for {
+		changes, err := db.GetConfigChangesSince(lastChange, lastChanged, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			break
+		}
+		req := PushRequest{Agent: Agent}
+		for _, change := range changes {
+			req.Changes = append(req.Changes, toChange(change))
+		}
+		if err := push(req); err != nil {
+			return err
+		}
+		last := changes[len(changes)-1]
+		lastChange, lastChanged = *last.CreatedAt, last.ID
+		changed += len(changes)
+	}
+
+	deleted := 0
+	for {
+		items, err := db.GetConfigItemsDeletedSince(lastDelete, lastDeleted, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		req := PushRequest{Agent: Agent}
+		for _, item := range items {
+			req.Deleted = append(req.Deleted, DeletedItem{ExternalType: item.ExternalType, ExternalID: item.ExternalID})
+		}
+		if err := push(req); err != nil {
+			return err
+		}
+		last := items[len(items)-1]
+		lastDelete, lastDeleted = last.DeletedAt, last.ID
+		deleted += len(items)
+	}
+	if pushed > 0 || changed > 0 || deleted > 0 {
+		logger.Infof("Reconciled %d updated items, %d changes and %d deleted items to %s", pushed, changed, deleted, URL)
+	}
+	return nil
+}
+
+// push sends a batch to the hub, retrying with an exponential backoff on network and server errors
+func push(req PushRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		resp, retry, err := post(body)
+		if err == nil {
+			if len(resp.Conflicts) > 0 {
+				logger.Warnf("%d items are owned by another agent on the hub: %v", len(resp.Conflicts), resp.Conflicts)
+			}
+			return nil
+		}
+		if !retry || attempt == maxRetries {
+			return err
+		}
+		logger.Warnf("failed to push to %s (attempt %d/%d): %v", URL, attempt, maxRetries, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func post(body []byte) (*PushResponse, bool, error) {
+	httpReq, err := http.NewRequest(http.MethodPost, URL+"/upstream/push", bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+Token)
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, true, err
+	}
+	defer httpResp.Body.Close()
+	data, _ := io.ReadAll(httpResp.Body)
+	if httpResp.StatusCode >= 300 {
+		retry := httpResp.StatusCode >= 500 || httpResp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("hub returned %s: %s", httpResp.Status, data)
+	}
+	var resp PushResponse
+	err = json.Unmarshal(data, &resp)
+	return &resp, false, err
+}
+
+// toItems converts config items to the items that create them on the hub, their parents and related items are
+// referenced by the last of their external ids
+func toItems(configs []models.ConfigItem) ([]Item, error) {
+	ids := make([]string, 0, len(configs))
+	for _, config := range configs {
+		ids = append(ids, config.ID)
+	}
+	relationships, err := db.GetRelationshipsFrom(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var referenced []string
+	for _, config := range configs {
+		if config.ParentID != nil {
+			referenced = append(referenced, *config.ParentID)
+		}
+	}
+	for _, relationship := range relationships {
+		referenced = append(referenced, relationship.RelatedID)
+	}
+	references, err := db.GetConfigItems(referenced, "")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.ConfigItem, len(references))
+	for _, reference := range references {
+		byID[reference.ID] = reference
+	}
+
+	related := make(map[string][]Relationship)
+	for _, relationship := range relationships {
+		if target, ok := byID[relationship.RelatedID]; ok && len(target.ExternalID) > 0 {
+			related[relationship.ConfigID] = append(related[relationship.ConfigID], Relationship{
+				RelatedExternalID:   lastExternalID(target),
+				RelatedExternalType: deref(target.ExternalType),
+				Relationship:        relationship.Relation,
+			})
+		}
+	}
+
+	items := make([]Item, 0, len(configs))
+	for _, config := range configs {
+		item := toItem(config)
+		if config.ParentID != nil {
+			if parent, ok := byID[*config.ParentID]; ok && len(parent.ExternalID) > 0 {
+				item.ParentExternalID, item.ParentExternalType = lastExternalID(parent), deref(parent.ExternalType)
+			}
+		}
+		item.Relationships = related[config.ID]
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func toItem(config models.ConfigItem) Item {
+	createdAt := config.CreatedAt
+	item := Item{
+		Type:         config.ConfigType,
+		ExternalType: deref(config.ExternalType),
+		Name:         deref(config.Name),
+		Namespace:    deref(config.Namespace),
+		Description:  deref(config.Description),
+		Account:      deref(config.Account),
+		Region:       deref(config.Region),
+		Zone:         deref(config.Zone),
+		Network:      deref(config.Network),
+		Subnet:       deref(config.Subnet),
+		CreatedAt:    &createdAt,
+	}
+	// the id of the item is the last external id, the others are its aliases
+	if n := len(config.ExternalID); n > 0 {
+		item.ID = config.ExternalID[n-1]
+		item.Aliases = config.ExternalID[:n-1]
+	}
+	if config.Tags != nil {
+		item.Tags = *config.Tags
+	}
+	if config.Config != nil {
+		item.Config = json.RawMessage(*config.Config)
+	}
+	return item
+}
+
+func toChange(change db.ItemChange) Change {
+	return Change{
+		ExternalID:       change.ConfigExternalID,
+		ExternalType:     change.ConfigExternalType,
+		ExternalChangeID: change.ExternalChangeId,
+		ChangeType:       change.ChangeType,
+		Severity:         change.Severity,
+		Source:           change.Source,
+		Summary:          change.Summary,
+		Patches:          change.Patches,
+		Details:          change.Details,
+		CreatedAt:        change.CreatedAt,
+	}
+}
+
+func lastExternalID(config models.ConfigItem) string {
+	return config.ExternalID[len(config.ExternalID)-1]
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
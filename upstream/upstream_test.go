@@ -0,0 +1,37 @@
+package upstream
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPushRequestRoundTrip(t *testing.T) {
+	req := PushRequest{Agent: "agent", Items: []Item{{
+		ID:                 "pod",
+		Type:               "Pod",
+		ExternalType:       "Kubernetes::Pod",
+		Config:             json.RawMessage(`{"a":1}`),
+		ParentExternalID:   "namespace",
+		ParentExternalType: "Kubernetes::Namespace",
+		Relationships:      []Relationship{{RelatedExternalID: "node", RelatedExternalType: "Kubernetes::Node", Relationship: "NodePod"}},
+	}}}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PushRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	result := decoded.Items[0].ScrapeResult()
+	if result.ParentExternalID != "namespace" || result.ParentExternalType != "Kubernetes::Namespace" {
+		t.Errorf("expected the parent to be kept, got %s/%s", result.ParentExternalType, result.ParentExternalID)
+	}
+	if len(result.RelationshipResults) != 1 || result.RelationshipResults[0].RelatedExternalID.ExternalID[0] != "node" {
+		t.Errorf("expected the relationship to be kept, got %v", result.RelationshipResults)
+	}
+	if string(result.Config.(json.RawMessage)) != `{"a":1}` {
+		t.Errorf("unexpected config %s", result.Config)
+	}
+}
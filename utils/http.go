@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	rateLimitRetries = 3
+	rateLimitMaxWait = 5 * time.Minute
+)
+
+// RetryRateLimited retries the rate limited requests of the client after the time given by the Retry-After or
+// X-RateLimit-Reset header of the response, requests that are limited for longer than rateLimitMaxWait fail
+func RetryRateLimited(client *resty.Client) *resty.Client {
+	return client.
+		SetRetryCount(rateLimitRetries).
+		SetRetryMaxWaitTime(rateLimitMaxWait).
+		AddRetryCondition(func(response *resty.Response, err error) bool {
+			return err == nil && rateLimited(response)
+		}).
+		SetRetryAfter(func(_ *resty.Client, response *resty.Response) (time.Duration, error) {
+			wait := rateLimitWait(response)
+			if wait > rateLimitMaxWait {
+				return 0, fmt.Errorf("%s: rate limited for %s", response.Request.URL, wait.Round(time.Second))
+			}
+			return wait, nil
+		})
+}
+
+// rateLimited is true for 429 responses and for the 403 responses of GitHub once the rate limit is exhausted
+func rateLimited(response *resty.Response) bool {
+	if response == nil {
+		return false
+	}
+	return response.StatusCode() == http.StatusTooManyRequests ||
+		(response.StatusCode() == http.StatusForbidden && response.Header().Get("X-RateLimit-Remaining") == "0")
+}
+
+// rateLimitWait returns the time until the rate limit resets, 0 when the response does not tell
+func rateLimitWait(response *resty.Response) time.Duration {
+	if seconds, err := strconv.Atoi(response.Header().Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(response.Header().Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if wait := time.Until(time.Unix(reset, 0)); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
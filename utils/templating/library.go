@@ -0,0 +1,137 @@
+package templating
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	v1 "github.com/flanksource/config-db/api/v1"
+)
+
+const libraryFetchTimeout = 30 * time.Second
+
+type library struct {
+	spec    v1.Library
+	program *goja.Program
+}
+
+var (
+	librariesLock sync.RWMutex
+	libraries     = make(map[string]library)
+)
+
+// RegisterLibraries loads the libraries that are new or whose source changed, the loaded libraries
+// are cached until their definition changes
+func RegisterLibraries(ctx *v1.ScrapeContext, specs []v1.Library) error {
+	for _, spec := range specs {
+		librariesLock.RLock()
+		loaded, ok := libraries[spec.Name]
+		librariesLock.RUnlock()
+		if ok && sameLibrary(loaded.spec, spec) {
+			continue
+		}
+
+		source, err := loadLibrary(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("failed to load library %s: %v", spec.Name, err)
+		}
+		// the module is wrapped in a function like node does, so that its variables do not leak into the script
+		program, err := goja.Compile(spec.Name, "(function(module, exports, require) {\n"+source+"\n})", false)
+		if err != nil {
+			return fmt.Errorf("failed to compile library %s: %v", spec.Name, err)
+		}
+		librariesLock.Lock()
+		libraries[spec.Name] = library{spec: spec, program: program}
+		librariesLock.Unlock()
+	}
+	return nil
+}
+
+func sameLibrary(a, b v1.Library) bool {
+	if (a.ConfigMap == nil) != (b.ConfigMap == nil) || (a.ConfigMap != nil && *a.ConfigMap != *b.ConfigMap) {
+		return false
+	}
+	return a.File == b.File && a.URL == b.URL
+}
+
+func loadLibrary(ctx *v1.ScrapeContext, spec v1.Library) (string, error) {
+	switch {
+	case spec.File != "":
+		data, err := os.ReadFile(spec.File)
+		return string(data), err
+
+	case spec.ConfigMap != nil:
+		if ctx.Kommons == nil {
+			return "", fmt.Errorf("config maps require a kubernetes connection")
+		}
+		namespace := spec.ConfigMap.Namespace
+		if namespace == "" {
+			namespace = ctx.GetNamespace()
+		}
+		data, err := ctx.Kommons.GetConfigMapV2(ctx, namespace, spec.ConfigMap.Name)
+		if err != nil {
+			return "", err
+		}
+		source, ok := (*data)[spec.ConfigMap.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found in config map %s/%s", spec.ConfigMap.Key, namespace, spec.ConfigMap.Name)
+		}
+		return source, nil
+
+	case spec.URL != "":
+		client := http.Client{Timeout: libraryFetchTimeout}
+		resp, err := client.Get(spec.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s returned %s", spec.URL, resp.Status)
+		}
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+	return "", fmt.Errorf("one of file, configMap or url is required")
+}
+
+// InstallRequire adds a require function to the runtime that returns the exports of the registered libraries,
+// every library is evaluated at most once per runtime
+func InstallRequire(vm *goja.Runtime) error {
+	modules := make(map[string]goja.Value)
+	require := func(call goja.FunctionCall) goja.Value {
+		name := call.Argument(0).String()
+		if exports, ok := modules[name]; ok {
+			return exports
+		}
+		librariesLock.RLock()
+		lib, ok := libraries[name]
+		librariesLock.RUnlock()
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("library %s is not defined", name)))
+		}
+
+		wrapper, err := vm.RunProgram(lib.program)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("failed to load library %s: %v", name, err)))
+		}
+		fn, ok := goja.AssertFunction(wrapper)
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("library %s is not a module", name)))
+		}
+		module := vm.NewObject()
+		exports := vm.NewObject()
+		if err := module.Set("exports", exports); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if _, err := fn(goja.Undefined(), module, exports, vm.Get("require")); err != nil {
+			panic(vm.NewGoError(fmt.Errorf("failed to evaluate library %s: %v", name, err)))
+		}
+		modules[name] = module.Get("exports")
+		return modules[name]
+	}
+	return vm.Set("require", require)
+}
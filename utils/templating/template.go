@@ -11,6 +11,7 @@ import (
 
 	"github.com/antonmedv/expr"
 	"github.com/dop251/goja"
+	"github.com/ohler55/ojg/jp"
 	"github.com/pkg/errors"
 
 	"github.com/flanksource/commons/logger"
@@ -38,6 +39,9 @@ func Template(environment map[string]interface{}, template v1.Template) (string,
 	if template.Javascript != "" {
 		// FIXME: whitelist allowed files
 		vm := goja.New()
+		if err := InstallRequire(vm); err != nil {
+			return "", err
+		}
 		for k, v := range environment {
 			if err := vm.Set(k, v); err != nil {
 				return "", errors.Wrapf(err, "error setting %s", k)
@@ -90,8 +94,50 @@ func Template(environment map[string]interface{}, template v1.Template) (string,
 		return fmt.Sprint(output), nil
 	}
 
+	// jsonpath of the config
+	if template.JSONPath != "" {
+		expr, err := jp.ParseString(template.JSONPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse jsonpath %s: %v", template.JSONPath, err)
+		}
+		values := expr.Get(environment["config"])
+		if len(values) == 0 {
+			return "", nil
+		}
+		return fmt.Sprint(values[0]), nil
+	}
+
 	// if template.GSONPath != "" {
 	// 	return gjson.Get(jsonContent, template.GSONPath).String()
 	// }
 	return "", nil
 }
+
+// Compile checks that the template compiles without running it
+func Compile(template v1.Template) error {
+	switch {
+	case template.Javascript != "":
+		_, err := goja.Compile("", template.Javascript, false)
+		return err
+	case template.Template != "":
+		_, err := gotemplate.New("").Funcs(text.GetTemplateFuncs()).Parse(template.Template)
+		return err
+	case template.Expression != "":
+		_, err := expr.Compile(template.Expression)
+		return err
+	case template.JSONPath != "":
+		_, err := jp.ParseString(template.JSONPath)
+		return err
+	}
+	return nil
+}
+
+// CompileScript checks that the script compiles without running it
+func CompileScript(script v1.Script) error {
+	return Compile(v1.Template{
+		Template:   script.GoTemplate,
+		JSONPath:   script.JSONPath,
+		Expression: script.Expression,
+		Javascript: script.Javascript,
+	})
+}